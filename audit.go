@@ -0,0 +1,131 @@
+package wgctrl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// An AuditEvent describes a single change to the configuration of a device
+// made by a Client, for use with WithAudit.
+type AuditEvent struct {
+	// Time is the time at which the operation started.
+	Time time.Time
+
+	// Actor identifies the party which requested the operation, as set by
+	// NewAuditContext, or is empty if no actor was set.
+	Actor string
+
+	// Operation is the name of the Client method, such as "ConfigureDevice".
+	Operation string
+
+	// Device is the name of the device which was changed.
+	Device string
+
+	// Changes is a description of each change requested by the operation.
+	// Private and preshared keys are never included, and are described as
+	// "(hidden)", as with wg(8).
+	Changes []string
+
+	// Err is the result of the operation, or nil if it succeeded.
+	Err error
+}
+
+// An actorKey is the context key for the actor set by NewAuditContext.
+type actorKey struct{}
+
+// NewAuditContext returns a copy of ctx which identifies actor, such as a
+// user or service name, as the party which requested any changes made by a
+// Client using the context. Use Client.WithContext to associate the context
+// with a Client's operations.
+func NewAuditContext(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// audit reports an operation on device to the Client's audit function, if
+// any.
+func (c *Client) audit(start time.Time, op, device string, changes []string, err error) {
+	if c.auditFn == nil {
+		return
+	}
+
+	var actor string
+	if c.ctx != nil {
+		actor, _ = c.ctx.Value(actorKey{}).(string)
+	}
+
+	c.auditFn(AuditEvent{
+		Time:      start,
+		Actor:     actor,
+		Operation: op,
+		Device:    device,
+		Changes:   changes,
+		Err:       err,
+	})
+}
+
+// describeConfig returns a description of each change requested by cfg,
+// hiding any private and preshared keys.
+func describeConfig(cfg wgtypes.Config) []string {
+	var out []string
+	if cfg.PrivateKey != nil {
+		out = append(out, "private key: (hidden)")
+	}
+	if cfg.ListenPort != nil {
+		out = append(out, fmt.Sprintf("listen port: %d", *cfg.ListenPort))
+	}
+	if cfg.FirewallMark != nil {
+		out = append(out, fmt.Sprintf("fwmark: %d", *cfg.FirewallMark))
+	}
+	if cfg.ReplacePeers {
+		out = append(out, "replace peers")
+	}
+
+	for _, p := range cfg.Peers {
+		out = append(out, describePeerConfig(p))
+	}
+
+	return out
+}
+
+// describePeerConfig returns a single line description of p, hiding any
+// preshared key.
+func describePeerConfig(p wgtypes.PeerConfig) string {
+	if p.Remove {
+		return fmt.Sprintf("peer %s: remove", p.PublicKey)
+	}
+
+	var fields []string
+	if p.UpdateOnly {
+		fields = append(fields, "update only")
+	}
+	if p.PresharedKey != nil {
+		fields = append(fields, "preshared key (hidden)")
+	}
+	if p.Endpoint != nil {
+		fields = append(fields, "endpoint "+p.Endpoint.String())
+	}
+	if p.PersistentKeepaliveInterval != nil {
+		fields = append(fields, "persistent keepalive "+p.PersistentKeepaliveInterval.String())
+	}
+	if p.ReplaceAllowedIPs {
+		fields = append(fields, "replace allowed IPs")
+	}
+	if len(p.AllowedIPs) > 0 {
+		ips := make([]string, 0, len(p.AllowedIPs))
+		for _, ipn := range p.AllowedIPs {
+			ips = append(ips, ipn.String())
+		}
+
+		fields = append(fields, "allowed IPs "+strings.Join(ips, ", "))
+	}
+
+	if len(fields) == 0 {
+		return fmt.Sprintf("peer %s", p.PublicKey)
+	}
+
+	return fmt.Sprintf("peer %s: %s", p.PublicKey, strings.Join(fields, "; "))
+}
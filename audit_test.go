@@ -0,0 +1,86 @@
+package wgctrl
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientAudit(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		psk  = wgtest.MustPresharedKey()
+		pub1 = wgtest.MustPublicKey()
+		pub2 = wgtest.MustPublicKey()
+
+		port      = 51820
+		keepalive = 25 * time.Second
+	)
+
+	var events []AuditEvent
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+				return errFoo
+			},
+		}},
+		auditFn: func(e AuditEvent) { events = append(events, e) },
+	}
+
+	cfg := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:                   pub1,
+				PresharedKey:                &psk,
+				Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+				PersistentKeepaliveInterval: &keepalive,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("192.168.1.0/24"),
+					wgtest.MustCIDR("2001:db8::/64"),
+				},
+			},
+			{
+				PublicKey: pub2,
+				Remove:    true,
+			},
+		},
+	}
+
+	ctx := NewAuditContext(context.Background(), "alice")
+	_ = c.WithContext(ctx).ConfigureDevice("wg0", cfg)
+
+	want := []AuditEvent{{
+		Actor:     "alice",
+		Operation: "ConfigureDevice",
+		Device:    "wg0",
+		Changes: []string{
+			"private key: (hidden)",
+			"listen port: 51820",
+			"replace peers",
+			"peer " + pub1.String() + ": preshared key (hidden); endpoint 192.0.2.1:51820; " +
+				"persistent keepalive 25s; replace allowed IPs; allowed IPs 192.168.1.0/24, 2001:db8::/64",
+			"peer " + pub2.String() + ": remove",
+		},
+		Err: &ConfigError{Device: "wg0", Err: errFoo},
+	}}
+
+	opts := []cmp.Option{
+		cmpErrors,
+		cmpopts.IgnoreFields(AuditEvent{}, "Time"),
+	}
+
+	if diff := cmp.Diff(want, events, opts...); diff != "" {
+		t.Fatalf("unexpected audit events (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,91 @@
+package wgctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A BackendOrder specifies the order in which a Client probes its backends
+// for a device.
+type BackendOrder int
+
+// Possible BackendOrder values.
+const (
+	// KernelFirst probes kernel implementations before userspace ones.
+	KernelFirst BackendOrder = iota
+
+	// UserspaceFirst probes userspace implementations before kernel ones.
+	UserspaceFirst
+)
+
+// orderBackends reorders cs according to order, preserving the relative
+// order of kernel and userspace backends.
+func orderBackends(cs []wginternal.Client, order BackendOrder) []wginternal.Client {
+	if order != UserspaceFirst {
+		return cs
+	}
+
+	out := make([]wginternal.Client, 0, len(cs))
+	for _, user := range []bool{true, false} {
+		for _, wgc := range cs {
+			if _, ok := wgc.(*wguser.Client); ok == user {
+				out = append(out, wgc)
+			}
+		}
+	}
+
+	return out
+}
+
+// A DuplicateDeviceError is returned by a Client created with
+// WithDuplicateDeviceErrors when a device with the same name is served by
+// more than one backend, such as a kernel device and a userspace device in
+// another network namespace.
+type DuplicateDeviceError struct {
+	// Name is the name of the device.
+	Name string
+
+	// Backends are the names of each backend which serves the device, such
+	// as "wglinux" and "wguser".
+	Backends []string
+}
+
+// Error implements error.
+func (e *DuplicateDeviceError) Error() string {
+	return fmt.Sprintf("wgctrl: device %q is served by multiple backends: %s",
+		e.Name, strings.Join(e.Backends, ", "))
+}
+
+// checkDuplicate returns a *DuplicateDeviceError if the Client reports
+// duplicate devices and more than one backend serves the device name.
+func (c *Client) checkDuplicate(name string) error {
+	if !c.duplicates {
+		return nil
+	}
+
+	var backends []string
+	for _, wgc := range c.cs {
+		// Only the device's existence is needed, so retrieve no fields.
+		var d wgtypes.Device
+		err := deviceFieldsInto(wgc, name, &d, 0)
+		switch {
+		case err == nil:
+			backends = append(backends, backendName(wgc))
+		case errors.Is(err, os.ErrNotExist):
+		default:
+			return err
+		}
+	}
+
+	if len(backends) > 1 {
+		return &DuplicateDeviceError{Name: name, Backends: backends}
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package wgctrl
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestOrderBackends(t *testing.T) {
+	uc, err := wguser.New()
+	if err != nil {
+		t.Fatalf("failed to create userspace client: %v", err)
+	}
+
+	var (
+		k1 = &testClient{}
+		k2 = &testClient{}
+		cs = []wginternal.Client{k1, uc, k2}
+	)
+
+	tests := []struct {
+		name  string
+		order BackendOrder
+		want  []wginternal.Client
+	}{
+		{
+			name:  "kernel first",
+			order: KernelFirst,
+			want:  cs,
+		},
+		{
+			name:  "userspace first",
+			order: UserspaceFirst,
+			want:  []wginternal.Client{uc, k1, k2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := orderBackends(cs, tt.order)
+			if len(got) != len(tt.want) {
+				t.Fatalf("unexpected number of backends: %d", len(got))
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("unexpected backend at index %d: %s", i, backendName(got[i]))
+				}
+			}
+		})
+	}
+}
+
+func TestClientDuplicateDeviceErrors(t *testing.T) {
+	var (
+		found = func(name string) (*wgtypes.Device, error) {
+			return &wgtypes.Device{Name: name}, nil
+		}
+
+		notExist = func(_ string) (*wgtypes.Device, error) {
+			return nil, os.ErrNotExist
+		}
+	)
+
+	tests := []struct {
+		name       string
+		fns        []func(string) (*wgtypes.Device, error)
+		duplicates bool
+		ok         bool
+	}{
+		{
+			name: "duplicates ignored",
+			fns:  []func(string) (*wgtypes.Device, error){found, found},
+			ok:   true,
+		},
+		{
+			name:       "no duplicates",
+			fns:        []func(string) (*wgtypes.Device, error){notExist, found},
+			duplicates: true,
+			ok:         true,
+		},
+		{
+			name:       "duplicates",
+			fns:        []func(string) (*wgtypes.Device, error){found, notExist, found},
+			duplicates: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{duplicates: tt.duplicates}
+			for _, fn := range tt.fns {
+				c.cs = append(c.cs, &testClient{
+					DeviceFunc: fn,
+					ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+						return nil
+					},
+				})
+			}
+
+			_, err := c.Device("wg0")
+			cerr := c.ConfigureDevice("wg0", wgtypes.Config{})
+
+			if tt.ok {
+				if err != nil || cerr != nil {
+					t.Fatalf("failed to use device: %v, %v", err, cerr)
+				}
+
+				return
+			}
+
+			for _, err := range []error{err, cerr} {
+				var derr *DuplicateDeviceError
+				if !errors.As(err, &derr) {
+					t.Fatalf("expected DuplicateDeviceError, but got: %v", err)
+				}
+
+				want := &DuplicateDeviceError{
+					Name:     "wg0",
+					Backends: []string{"wgctrl.testClient", "wgctrl.testClient"},
+				}
+
+				if diff := cmp.Diff(want, derr); diff != "" {
+					t.Fatalf("unexpected error (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
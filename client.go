@@ -1,8 +1,11 @@
 package wgctrl
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"os"
+	"time"
 
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -16,18 +19,153 @@ type Client struct {
 	// Seamlessly use different wginternal.Client implementations to provide an
 	// interface similar to wg(8).
 	cs []wginternal.Client
+
+	// tracer and ctx are used to trace operations if set.
+	tracer Tracer
+	ctx    context.Context
+
+	// order specifies the order of retrieved devices and peers.
+	order SortOrder
+
+	// exclude specifies fields which are never retrieved.
+	exclude wginternal.FieldMask
+
+	// auditFn is called after each configuration change, if set.
+	auditFn func(AuditEvent)
+
+	// journal records each applied configuration, if set.
+	journal Journal
+
+	// duplicates reports devices served by more than one backend as errors.
+	duplicates bool
+
+	// pins records the backend which serves each device, if set.
+	pins *backendPins
+
+	// locks locates per-device lock files, if set.
+	locks *deviceLocks
+
+	// readOnly refuses operations which change the system.
+	readOnly bool
+
+	// rates limits the rate of operations on each device, if set.
+	rates *rateLimiter
+
+	// isolatePeers applies rejected configurations one peer at a time.
+	isolatePeers bool
 }
 
-// New creates a new Client.
-func New() (*Client, error) {
-	cs, err := newClients()
+// New creates a new Client, applying any ClientOptions.
+func New(options ...ClientOption) (*Client, error) {
+	var o clientOptions
+	for _, fn := range options {
+		fn(&o)
+	}
+
+	cs, err := newClients(&o)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
-		cs: cs,
-	}, nil
+	c := &Client{
+		cs:         orderBackends(cs, o.backendOrder),
+		tracer:     o.tracer,
+		order:      o.order,
+		auditFn:    o.audit,
+		journal:    o.journal,
+		duplicates: o.duplicates,
+		readOnly:   o.readOnly,
+
+		isolatePeers: o.isolatePeers,
+	}
+
+	if o.noSecrets {
+		c.exclude = wginternal.FieldSecrets
+	}
+
+	if o.rateLimits != nil {
+		c.rates = newRateLimiter(*o.rateLimits, o.metrics)
+	}
+
+	if o.pin {
+		c.pins = &backendPins{m: make(map[string]wginternal.Client)}
+	}
+
+	if o.lockDir != nil {
+		dir := *o.lockDir
+		if dir == "" {
+			dir = defaultLockDir
+		}
+
+		c.locks = &deviceLocks{dir: dir}
+	}
+
+	if o.cacheDiscovery {
+		if err := c.cacheDiscovery(); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	if o.preopen {
+		if err := c.preopen(); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+
+	// Wrap the backends after preopening, which requires access to their
+	// concrete types.
+	if o.metrics != nil {
+		c.cs = withMetrics(c.cs, o.metrics)
+	}
+
+	return c, nil
+}
+
+// A preopener is a wginternal.Client which can open all of its sockets ahead
+// of time.
+type preopener interface {
+	Preopen() error
+}
+
+// preopen opens all sockets for each wginternal.Client which supports it.
+func (c *Client) preopen() error {
+	for _, wgc := range c.cs {
+		p, ok := wgc.(preopener)
+		if !ok {
+			continue
+		}
+
+		if err := p.Preopen(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// A discoveryCacher is a wginternal.Client which can cache the list of
+// devices it discovers.
+type discoveryCacher interface {
+	CacheDiscovery() error
+}
+
+// cacheDiscovery enables device discovery caching for each
+// wginternal.Client which supports it.
+func (c *Client) cacheDiscovery() error {
+	for _, wgc := range c.cs {
+		dc, ok := wgc.(discoveryCacher)
+		if !ok {
+			continue
+		}
+
+		if err := dc.CacheDiscovery(); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // Close releases resources used by a Client.
@@ -42,17 +180,34 @@ func (c *Client) Close() error {
 }
 
 // Devices retrieves all WireGuard devices on this system.
-func (c *Client) Devices() ([]*wgtypes.Device, error) {
-	var out []*wgtypes.Device
+func (c *Client) Devices() (out []*wgtypes.Device, err error) {
+	span := c.startSpan("Devices")
+	defer func() {
+		var peers int
+		for _, d := range out {
+			peers += len(d.Peers)
+		}
+
+		span.SetInt(traceDevices, len(out))
+		span.SetInt(tracePeers, peers)
+		span.End(err)
+	}()
+
+	if err := c.limit(rateDump, ""); err != nil {
+		return nil, err
+	}
+
 	for _, wgc := range c.cs {
-		devs, err := wgc.Devices()
+		devs, err := devicesFields(wgc, wginternal.FieldAll&^c.exclude)
 		if err != nil {
 			return nil, err
 		}
 
+		c.pinDevices(wgc, devs)
 		out = append(out, devs...)
 	}
 
+	c.order.sortDevices(out)
 	return out, nil
 }
 
@@ -60,15 +215,47 @@ func (c *Client) Devices() ([]*wgtypes.Device, error) {
 //
 // If the device specified by name does not exist or is not a WireGuard device,
 // an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
-func (c *Client) Device(name string) (*wgtypes.Device, error) {
-	for _, wgc := range c.cs {
+func (c *Client) Device(name string) (d *wgtypes.Device, err error) {
+	span := c.startSpan("Device")
+	span.SetString(traceDevice, name)
+	defer func() {
+		if d != nil {
+			span.SetInt(tracePeers, len(d.Peers))
+		}
+		span.End(err)
+	}()
+
+	if c.exclude != 0 {
+		// Only some fields may be retrieved.
+		d = new(wgtypes.Device)
+		if err := c.deviceFieldsInto(span, name, d, wginternal.FieldAll); err != nil {
+			return nil, err
+		}
+
+		return d, nil
+	}
+
+	if err := c.limit(rateDump, name); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkDuplicate(name); err != nil {
+		return nil, err
+	}
+
+	for _, wgc := range c.backendsFor(name) {
 		d, err := wgc.Device(name)
 		switch {
 		case err == nil:
+			span.SetString(traceBackend, backendName(wgc))
+			c.pin(name, wgc)
+			c.order.sortDevice(d)
 			return d, nil
 		case errors.Is(err, os.ErrNotExist):
+			c.unpin(name, wgc)
 			continue
 		default:
+			span.SetString(traceBackend, backendName(wgc))
 			return nil, err
 		}
 	}
@@ -76,24 +263,206 @@ func (c *Client) Device(name string) (*wgtypes.Device, error) {
 	return nil, os.ErrNotExist
 }
 
+// DeviceInto is like Device, but stores the device in d. Where the backend
+// supports it, the storage of d's existing Peers and their Endpoints and
+// AllowedIPs is reused, so that repeatedly polling a device with many peers
+// does not allocate new Peers on each call.
+//
+// Because their storage may be reused, no values previously stored in d by
+// DeviceInto may be retained by the caller. If an error is returned, the
+// contents of d are unspecified.
+func (c *Client) DeviceInto(name string, d *wgtypes.Device) (err error) {
+	span := c.startSpan("DeviceInto")
+	defer func() { span.End(err) }()
+
+	return c.deviceFieldsInto(span, name, d, wginternal.FieldAll)
+}
+
+// deviceFieldsInto stores the fields selected by mask of the device name in
+// d, recording the result in span.
+func (c *Client) deviceFieldsInto(span Span, name string, d *wgtypes.Device, mask wginternal.FieldMask) error {
+	span.SetString(traceDevice, name)
+	mask &^= c.exclude
+
+	if err := c.limit(rateDump, name); err != nil {
+		return err
+	}
+
+	if err := c.checkDuplicate(name); err != nil {
+		return err
+	}
+
+	for _, wgc := range c.backendsFor(name) {
+		err := deviceFieldsInto(wgc, name, d, mask)
+		switch {
+		case err == nil:
+			span.SetString(traceBackend, backendName(wgc))
+			span.SetInt(tracePeers, len(d.Peers))
+			c.pin(name, wgc)
+			c.order.sortDevice(d)
+			return nil
+		case errors.Is(err, os.ErrNotExist):
+			c.unpin(name, wgc)
+			continue
+		default:
+			span.SetString(traceBackend, backendName(wgc))
+			return err
+		}
+	}
+
+	return os.ErrNotExist
+}
+
+// A deviceIntoer is a wginternal.Client which can reuse the storage of a
+// Device.
+type deviceIntoer interface {
+	DeviceInto(name string, d *wgtypes.Device) error
+}
+
+// A deviceFieldsIntoer is a wginternal.Client which can reuse the storage of
+// a Device, and avoid retrieving some of its fields.
+type deviceFieldsIntoer interface {
+	DeviceFieldsInto(name string, d *wgtypes.Device, mask wginternal.FieldMask) error
+}
+
+// A devicesFieldser is a wginternal.Client which can avoid retrieving some
+// fields of all of its Devices.
+type devicesFieldser interface {
+	DevicesFields(mask wginternal.FieldMask) ([]*wgtypes.Device, error)
+}
+
+// devicesFields uses c to retrieve the fields selected by mask of all of its
+// devices. If c does not support field masks, the fields which are not
+// selected are cleared.
+func devicesFields(c wginternal.Client, mask wginternal.FieldMask) ([]*wgtypes.Device, error) {
+	if mask == wginternal.FieldAll {
+		return c.Devices()
+	}
+
+	if df, ok := c.(devicesFieldser); ok {
+		return df.DevicesFields(mask)
+	}
+
+	ds, err := c.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range ds {
+		mask.Apply(d)
+	}
+
+	return ds, nil
+}
+
+// deviceFieldsInto uses c to store the fields selected by mask of the device
+// name in d, reusing the storage of d if c supports it. If c does not support
+// field masks, the fields which are not selected are cleared.
+func deviceFieldsInto(c wginternal.Client, name string, d *wgtypes.Device, mask wginternal.FieldMask) error {
+	if di, ok := c.(deviceFieldsIntoer); ok {
+		return di.DeviceFieldsInto(name, d, mask)
+	}
+
+	if di, ok := c.(deviceIntoer); ok {
+		if err := di.DeviceInto(name, d); err != nil {
+			return err
+		}
+	} else {
+		dev, err := c.Device(name)
+		if err != nil {
+			return err
+		}
+
+		*d = *dev
+	}
+
+	mask.Apply(d)
+	return nil
+}
+
 // ConfigureDevice configures a WireGuard device by its interface name.
 //
 // Because the zero value of some Go types may be significant to WireGuard for
 // Config fields, only fields which are not nil will be applied when
 // configuring a device.
 //
+// cfg is validated before it is applied. If cfg is invalid, or if the device
+// rejects it, a *ConfigError is returned which wraps the underlying error and
+// identifies the failed peer and field when they are known. If the Client was
+// created with WithPeerIsolation, the peer which caused the device to reject
+// cfg is identified, along with the peers which were applied before it.
+//
+// If the Client was created with WithDeviceLocks, the device's lock is held
+// while cfg is applied.
+//
+// If the Client was created with WithReadOnly, ErrReadOnly is returned.
+//
 // If the device specified by name does not exist or is not a WireGuard device,
 // an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
 func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
-	for _, wgc := range c.cs {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	return c.withDeviceLock(name, func() error {
+		return c.configureDevice(name, cfg, true)
+	})
+}
+
+// configureDevice configures the device name using cfg, and records cfg in
+// the Client's Journal if record is true.
+func (c *Client) configureDevice(name string, cfg wgtypes.Config, record bool) (err error) {
+	span := c.startSpan("ConfigureDevice")
+	span.SetString(traceDevice, name)
+	span.SetInt(tracePeers, len(cfg.Peers))
+	start := time.Now()
+	defer func() {
+		span.End(err)
+		if c.auditFn != nil {
+			c.audit(start, "ConfigureDevice", name, describeConfig(cfg), err)
+		}
+	}()
+
+	if err := ValidateConfig(cfg); err != nil {
+		var cerr *ConfigError
+		if errors.As(err, &cerr) {
+			cerr.Device = name
+		}
+
+		return err
+	}
+
+	if err := c.limit(rateConfigure, name); err != nil {
+		return err
+	}
+
+	if err := c.checkDuplicate(name); err != nil {
+		return err
+	}
+
+	for _, wgc := range c.backendsFor(name) {
 		err := wgc.ConfigureDevice(name, cfg)
 		switch {
 		case err == nil:
+			span.SetString(traceBackend, backendName(wgc))
+			c.pin(name, wgc)
+			if record && c.journal != nil {
+				if err := c.journal.Record(name, cfg); err != nil {
+					return fmt.Errorf("wgctrl: configuration was applied, but could not be recorded in journal: %w", err)
+				}
+			}
+
 			return nil
 		case errors.Is(err, os.ErrNotExist):
+			c.unpin(name, wgc)
 			continue
 		default:
-			return err
+			span.SetString(traceBackend, backendName(wgc))
+			if c.shouldIsolate(cfg, err) {
+				return c.isolateConfig(wgc, name, cfg, err)
+			}
+
+			return newConfigError(name, cfg, err)
 		}
 	}
 
@@ -149,6 +149,27 @@ func TestClientDevice(t *testing.T) {
 	}
 }
 
+func TestClientDeviceIntoFallback(t *testing.T) {
+	c := &Client{cs: []wginternal.Client{
+		&testClient{DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+			return nil, os.ErrNotExist
+		}},
+		&testClient{DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+			return okDevice, nil
+		}},
+	}}
+
+	// Backends which cannot reuse storage fall back to Device.
+	d := wgtypes.Device{Name: "stale", ListenPort: 51820}
+	if err := c.DeviceInto("wg0", &d); err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+
+	if diff := cmp.Diff(okDevice, &d); diff != "" {
+		t.Fatalf("unexpected device (-want +got):\n%s", diff)
+	}
+}
+
 func TestClientConfigureDevice(t *testing.T) {
 	type configFunc func(name string, cfg wgtypes.Config) error
 
@@ -179,7 +200,7 @@ func TestClientConfigureDevice(t *testing.T) {
 				},
 				willPanic,
 			},
-			err: errFoo,
+			err: &ConfigError{Err: errFoo},
 		},
 		{
 			name: "not found",
@@ -240,3 +261,16 @@ func (c *testClient) Device(name string) (*wgtypes.Device, error) {
 func (c *testClient) ConfigureDevice(name string, cfg wgtypes.Config) error {
 	return c.ConfigureDeviceFunc(name, cfg)
 }
+
+func TestPrivilegeError(t *testing.T) {
+	var err error = &PrivilegeError{Need: "CAP_NET_ADMIN"}
+	if !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission denied, but got: %v", err)
+	}
+
+	// The result of CheckPrivileges depends on the environment, but any error
+	// must be a permission error.
+	if err := CheckPrivileges(); err != nil && !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected nil or permission denied, but got: %v", err)
+	}
+}
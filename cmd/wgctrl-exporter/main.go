@@ -0,0 +1,112 @@
+// Command wgctrl-exporter serves statistics of WireGuard devices in the
+// Prometheus text exposition format.
+//
+// By default, metrics are served over plain HTTP at :9586/metrics. Use the
+// -tls-cert and -tls-key flags to serve HTTPS, and the -basic-auth-user and
+// -basic-auth-password-file flags to require HTTP basic authentication.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+)
+
+func main() {
+	var (
+		addr     = flag.String("listen", ":9586", "address on which to serve metrics")
+		path     = flag.String("path", "/metrics", "HTTP path on which to serve metrics")
+		devices  = flag.String("devices", "", "comma-separated list of devices to export; all devices if empty")
+		maxAge   = flag.Duration("cache", time.Second, "period for which sampled statistics are reused between scrapes")
+		certFile = flag.String("tls-cert", "", "TLS certificate file; enables HTTPS with -tls-key")
+		keyFile  = flag.String("tls-key", "", "TLS private key file; enables HTTPS with -tls-cert")
+		user     = flag.String("basic-auth-user", "", "require HTTP basic authentication with this user name")
+		passFile = flag.String("basic-auth-password-file", "", "file containing the password for -basic-auth-user")
+	)
+	flag.Parse()
+
+	if (*certFile == "") != (*keyFile == "") {
+		log.Fatal("-tls-cert and -tls-key must be specified together")
+	}
+	if (*user == "") != (*passFile == "") {
+		log.Fatal("-basic-auth-user and -basic-auth-password-file must be specified together")
+	}
+
+	c, err := wgctrl.New()
+	if err != nil {
+		log.Fatalf("failed to open wgctrl: %v", err)
+	}
+	defer c.Close()
+
+	s := wgstats.NewSampler(c, *maxAge)
+	if *devices != "" {
+		want := make(map[string]bool)
+		for _, d := range strings.Split(*devices, ",") {
+			want[strings.TrimSpace(d)] = true
+		}
+
+		s.Filter(func(name string) bool { return want[name] })
+	}
+
+	var h http.Handler = wgstats.Handler(s)
+	if *user != "" {
+		pass, err := os.ReadFile(*passFile)
+		if err != nil {
+			log.Fatalf("failed to read basic authentication password: %v", err)
+		}
+
+		h = basicAuth(h, *user, strings.TrimRight(string(pass), "\r\n"))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, h)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("serving metrics on %s%s", *addr, *path)
+	if *certFile != "" {
+		err = srv.ListenAndServeTLS(*certFile, *keyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+
+	c.Close()
+	log.Fatalf("failed to serve metrics: %v", err)
+}
+
+// basicAuth wraps h so that requests must carry HTTP basic authentication
+// credentials matching user and pass.
+func basicAuth(h http.Handler, user, pass string) http.Handler {
+	// Compare fixed-size digests so that the comparison does not leak the
+	// length of the credentials.
+	wantUser := sha256.Sum256([]byte(user))
+	wantPass := sha256.Sum256([]byte(pass))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		gotUser := sha256.Sum256([]byte(u))
+		gotPass := sha256.Sum256([]byte(p))
+
+		userOK := subtle.ConstantTimeCompare(gotUser[:], wantUser[:])
+		passOK := subtle.ConstantTimeCompare(gotPass[:], wantPass[:])
+		if !ok || userOK&passOK != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="wgctrl-exporter", charset="UTF-8"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasicAuth(t *testing.T) {
+	h := basicAuth(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), "prometheus", "secret")
+
+	tests := []struct {
+		name       string
+		user, pass string
+		auth       bool
+		code       int
+	}{
+		{
+			name: "no credentials",
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "bad user",
+			user: "root",
+			pass: "secret",
+			auth: true,
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "bad password",
+			user: "prometheus",
+			pass: "secre",
+			auth: true,
+			code: http.StatusUnauthorized,
+		},
+		{
+			name: "OK",
+			user: "prometheus",
+			pass: "secret",
+			auth: true,
+			code: http.StatusNoContent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.auth {
+				r.SetBasicAuth(tt.user, tt.pass)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.code {
+				t.Fatalf("unexpected status code: want %d, got %d", tt.code, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgconf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// setconf implements "wgctrl setconf", which replaces the configuration of a
+// device with the contents of a configuration file.
+func setconf(c *wgctrl.Client, args []string) error {
+	if len(args) != 2 {
+		return usageError("setconf")
+	}
+
+	cfg, err := readConfig(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := c.ConfigureDevice(args[0], cfg); err != nil {
+		return fmt.Errorf("failed to configure device %q: %v", args[0], err)
+	}
+
+	return nil
+}
+
+// syncconf implements "wgctrl syncconf", which applies only the differences
+// between the current configuration of a device and a configuration file, so
+// that the sessions of unchanged peers are not disrupted.
+func syncconf(c *wgctrl.Client, args []string) error {
+	if len(args) != 2 {
+		return usageError("syncconf")
+	}
+
+	cfg, err := readConfig(args[1])
+	if err != nil {
+		return err
+	}
+
+	if err := c.SyncConfig(args[0], cfg); err != nil {
+		return fmt.Errorf("failed to configure device %q: %v", args[0], err)
+	}
+
+	return nil
+}
+
+// readConfig reads a wg(8) configuration file from path.
+func readConfig(path string) (wgtypes.Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return wgtypes.Config{}, err
+	}
+	defer f.Close()
+
+	file, err := wgconf.Parse(f)
+	if err != nil {
+		return wgtypes.Config{}, err
+	}
+
+	return file.Config()
+}
@@ -1,20 +1,64 @@
-// Command wgctrl is a testing utility for interacting with WireGuard via package
-// wgctrl.
+// Command wgctrl is a utility for inspecting and configuring WireGuard
+// devices via package wgctrl. Its subcommands mirror those of wg(8), so that
+// it can be used in place of wg on platforms where wg is not packaged:
+//
+//	wgctrl show [-dump] [-graph dot|mermaid] [<interface>]
+//	wgctrl set <interface> [listen-port <port>] [fwmark <mark>] [private-key <file>]
+//	    [peer <public key> [remove] [preshared-key <file>] [endpoint <ip>:<port>]
+//	    [persistent-keepalive <seconds>|off] [allowed-ips <ip>/<cidr>[,...]]]...
+//	wgctrl setconf <interface> <configuration file>
+//	wgctrl syncconf <interface> <configuration file>
+//	wgctrl watch [-interval <duration>] [<interface>]
+//	wgctrl metrics [<interface>]
+//
+// If no subcommand is given, show is assumed.
 package main
 
 import (
-	"flag"
 	"fmt"
 	"log"
-	"net"
+	"os"
 	"strings"
 
 	"golang.zx2c4.com/wireguard/wgctrl"
-	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
+// A command is a wgctrl subcommand.
+type command struct {
+	usage string
+	run   func(c *wgctrl.Client, args []string) error
+}
+
+// commands are the subcommands of wgctrl, by name. They are registered in
+// init because their implementations refer to commands for usage errors.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"show":     {usage: "[-dump] [-graph dot|mermaid] [<interface>]", run: show},
+		"set":      {usage: "<interface> [listen-port <port>] [fwmark <mark>] [private-key <file>] [peer <public key> ...]...", run: set},
+		"setconf":  {usage: "<interface> <configuration file>", run: setconf},
+		"syncconf": {usage: "<interface> <configuration file>", run: syncconf},
+		"watch":    {usage: "[-interval <duration>] [<interface>]", run: watch},
+		"metrics":  {usage: "[<interface>]", run: metrics},
+	}
+}
+
 func main() {
-	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("wgctrl: ")
+
+	// Behave like "wgctrl show" if no subcommand is given, for compatibility
+	// with earlier versions of this utility.
+	name, args := "show", os.Args[1:]
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
+		} else if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+			usage()
+			return
+		}
+	}
 
 	c, err := wgctrl.New()
 	if err != nil {
@@ -22,72 +66,27 @@ func main() {
 	}
 	defer c.Close()
 
-	var devices []*wgtypes.Device
-	if device := flag.Arg(0); device != "" {
-		d, err := c.Device(device)
-		if err != nil {
-			log.Fatalf("failed to get device %q: %v", device, err)
-		}
-
-		devices = append(devices, d)
-	} else {
-		devices, err = c.Devices()
-		if err != nil {
-			log.Fatalf("failed to get devices: %v", err)
-		}
+	if err := commands[name].run(c, args); err != nil {
+		c.Close()
+		log.Fatalf("%s: %v", name, err)
 	}
-
-	for _, d := range devices {
-		printDevice(d)
-
-		for _, p := range d.Peers {
-			printPeer(p)
-		}
-	}
-}
-
-func printDevice(d *wgtypes.Device) {
-	const f = `interface: %s (%s)
-  public key: %s
-  private key: (hidden)
-  listening port: %d
-
-`
-
-	fmt.Printf(
-		f,
-		d.Name,
-		d.Type.String(),
-		d.PublicKey.String(),
-		d.ListenPort)
 }
 
-func printPeer(p wgtypes.Peer) {
-	const f = `peer: %s
-  endpoint: %s
-  allowed ips: %s
-  latest handshake: %s
-  transfer: %d B received, %d B sent
+// usage prints the usage of each subcommand.
+func usage() {
+	names := []string{"show", "set", "setconf", "syncconf", "watch", "metrics"}
 
-`
+	var b strings.Builder
+	b.WriteString("usage:\n")
+	for _, n := range names {
+		fmt.Fprintf(&b, "  wgctrl %s %s\n", n, commands[n].usage)
+	}
 
-	fmt.Printf(
-		f,
-		p.PublicKey.String(),
-		// TODO(mdlayher): get right endpoint with getnameinfo.
-		p.Endpoint.String(),
-		ipsString(p.AllowedIPs),
-		p.LastHandshakeTime.String(),
-		p.ReceiveBytes,
-		p.TransmitBytes,
-	)
+	fmt.Fprint(os.Stderr, b.String())
 }
 
-func ipsString(ipns []net.IPNet) string {
-	ss := make([]string, 0, len(ipns))
-	for _, ipn := range ipns {
-		ss = append(ss, ipn.String())
-	}
-
-	return strings.Join(ss, ", ")
+// usageError returns an error describing the correct usage of the named
+// subcommand.
+func usageError(name string) error {
+	return fmt.Errorf("usage: wgctrl %s %s", name, commands[name].usage)
 }
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+)
+
+// metrics implements "wgctrl metrics", which prints device and peer
+// statistics in the Prometheus text exposition format.
+func metrics(c *wgctrl.Client, args []string) error {
+	if len(args) > 1 {
+		return usageError("metrics")
+	}
+
+	s := wgstats.NewSampler(c, 0)
+	if len(args) == 1 && args[0] != "all" {
+		s.Filter(func(name string) bool { return name == args[0] })
+	}
+
+	snap, err := s.Sample()
+	if err != nil {
+		return err
+	}
+
+	return wgstats.WritePrometheus(os.Stdout, snap)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// set implements "wgctrl set".
+func set(c *wgctrl.Client, args []string) error {
+	if len(args) < 2 {
+		return usageError("set")
+	}
+
+	cfg, err := parseSet(args[1:], os.ReadFile)
+	if err != nil {
+		return err
+	}
+
+	if err := c.ConfigureDevice(args[0], cfg); err != nil {
+		return fmt.Errorf("failed to configure device %q: %v", args[0], err)
+	}
+
+	return nil
+}
+
+// parseSet parses the arguments of "wg set" which follow the interface name,
+// using readFile to read key files.
+func parseSet(args []string, readFile func(string) ([]byte, error)) (wgtypes.Config, error) {
+	var (
+		cfg  wgtypes.Config
+		peer *wgtypes.PeerConfig
+	)
+
+	// next consumes the value of the argument at index i.
+	next := func(i int) (string, error) {
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("%q requires a value", args[i])
+		}
+
+		return args[i+1], nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Options which take no value.
+		if arg == "remove" && peer != nil {
+			peer.Remove = true
+			continue
+		}
+
+		v, err := next(i)
+		if err != nil {
+			return wgtypes.Config{}, err
+		}
+		i++
+
+		switch {
+		case arg == "peer":
+			k, err := wgtypes.ParseKey(v)
+			if err != nil {
+				return wgtypes.Config{}, fmt.Errorf("invalid peer public key: %v", err)
+			}
+
+			cfg.Peers = append(cfg.Peers, wgtypes.PeerConfig{PublicKey: k})
+			peer = &cfg.Peers[len(cfg.Peers)-1]
+		case peer == nil && arg == "listen-port":
+			port, err := strconv.ParseUint(v, 10, 16)
+			if err != nil {
+				return wgtypes.Config{}, fmt.Errorf("invalid listen port: %v", err)
+			}
+
+			p := int(port)
+			cfg.ListenPort = &p
+		case peer == nil && arg == "fwmark":
+			mark, err := parseFwmark(v)
+			if err != nil {
+				return wgtypes.Config{}, err
+			}
+
+			cfg.FirewallMark = &mark
+		case peer == nil && arg == "private-key":
+			k, err := readKey(readFile, v)
+			if err != nil {
+				return wgtypes.Config{}, fmt.Errorf("invalid private key: %v", err)
+			}
+
+			cfg.PrivateKey = &k
+		case peer != nil && arg == "preshared-key":
+			k, err := readKey(readFile, v)
+			if err != nil {
+				return wgtypes.Config{}, fmt.Errorf("invalid preshared key: %v", err)
+			}
+
+			peer.PresharedKey = &k
+		case peer != nil && arg == "endpoint":
+			addr, err := net.ResolveUDPAddr("udp", v)
+			if err != nil {
+				return wgtypes.Config{}, fmt.Errorf("invalid endpoint: %v", err)
+			}
+
+			peer.Endpoint = addr
+		case peer != nil && arg == "persistent-keepalive":
+			var secs uint64
+			if v != "off" {
+				secs, err = strconv.ParseUint(v, 10, 16)
+				if err != nil {
+					return wgtypes.Config{}, fmt.Errorf("invalid persistent keepalive interval: %v", err)
+				}
+			}
+
+			d := time.Duration(secs) * time.Second
+			peer.PersistentKeepaliveInterval = &d
+		case peer != nil && arg == "allowed-ips":
+			ipns, err := wgtypes.ParseAllowedIPs(v)
+			if err != nil {
+				return wgtypes.Config{}, err
+			}
+
+			peer.ReplaceAllowedIPs = true
+			peer.AllowedIPs = ipns
+		default:
+			return wgtypes.Config{}, fmt.Errorf("invalid argument %q", arg)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseFwmark parses a firewall mark in decimal or hexadecimal, or "off".
+func parseFwmark(s string) (int, error) {
+	if s == "off" {
+		return 0, nil
+	}
+
+	mark, err := strconv.ParseUint(s, 0, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid fwmark: %v", err)
+	}
+
+	return int(mark), nil
+}
+
+// readKey reads a base64-encoded key from the file path. As with wg(8), an
+// empty file produces the zero key, which removes a preshared key.
+func readKey(readFile func(string) ([]byte, error), path string) (wgtypes.Key, error) {
+	b, err := readFile(path)
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+
+	s := strings.TrimSpace(string(b))
+	if s == "" {
+		return wgtypes.Key{}, nil
+	}
+
+	return wgtypes.ParseKey(s)
+}
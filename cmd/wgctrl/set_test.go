@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestParseSet(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+		psk  = wgtest.MustPresharedKey()
+	)
+
+	files := map[string]string{
+		"priv":  priv.String() + "\n",
+		"psk":   psk.String(),
+		"empty": "",
+	}
+
+	readFile := func(path string) ([]byte, error) {
+		s, ok := files[path]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+
+		return []byte(s), nil
+	}
+
+	var (
+		port  = 51820
+		mark  = 0x10
+		ka    = 25 * time.Second
+		zero  wgtypes.Key
+		other = wgtest.MustPublicKey()
+	)
+
+	tests := []struct {
+		name string
+		args []string
+		cfg  wgtypes.Config
+		ok   bool
+	}{
+		{
+			name: "interface",
+			args: []string{"listen-port", "51820", "fwmark", "0x10", "private-key", "priv"},
+			cfg: wgtypes.Config{
+				PrivateKey:   &priv,
+				ListenPort:   &port,
+				FirewallMark: &mark,
+			},
+			ok: true,
+		},
+		{
+			name: "peers",
+			args: []string{
+				"peer", pub.String(),
+				"preshared-key", "psk",
+				"endpoint", "192.0.2.1:51820",
+				"persistent-keepalive", "25",
+				"allowed-ips", "10.0.0.0/24,fd00::1",
+				"peer", other.String(), "remove", "preshared-key", "empty",
+			},
+			cfg: wgtypes.Config{
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   pub,
+						PresharedKey:                &psk,
+						Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+						PersistentKeepaliveInterval: &ka,
+						ReplaceAllowedIPs:           true,
+						AllowedIPs: []net.IPNet{
+							wgtest.MustCIDR("10.0.0.0/24"),
+							wgtest.MustCIDR("fd00::1/128"),
+						},
+					},
+					{
+						PublicKey:    other,
+						Remove:       true,
+						PresharedKey: &zero,
+					},
+				},
+			},
+			ok: true,
+		},
+		{
+			name: "missing value",
+			args: []string{"listen-port"},
+		},
+		{
+			name: "bad port",
+			args: []string{"listen-port", "65536"},
+		},
+		{
+			name: "peer option without peer",
+			args: []string{"endpoint", "192.0.2.1:51820"},
+		},
+		{
+			name: "interface option after peer",
+			args: []string{"peer", pub.String(), "listen-port", "1"},
+		},
+		{
+			name: "missing key file",
+			args: []string{"private-key", "nope"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parseSet(tt.args, readFile)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+				return
+			}
+
+			if diff := cmp.Diff(tt.cfg, cfg); diff != "" {
+				t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wggraph"
+	"golang.zx2c4.com/wireguard/wgctrl/wgshow"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// show implements "wgctrl show".
+func show(c *wgctrl.Client, args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	var (
+		dump  = fs.Bool("dump", false, "print devices in the tab-separated format of 'wg show dump'")
+		graph = fs.String("graph", "", "print devices as a graph in 'dot' or 'mermaid' format")
+	)
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 {
+		return usageError("show")
+	}
+
+	devices, err := devices(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *graph == "dot":
+		return wggraph.WriteDOT(os.Stdout, devices)
+	case *graph == "mermaid":
+		return wggraph.WriteMermaid(os.Stdout, devices)
+	case *graph != "":
+		return fmt.Errorf("unknown graph format %q", *graph)
+	case *dump:
+		return wgshow.DumpAll(os.Stdout, devices)
+	default:
+		return wgshow.ShowAll(os.Stdout, devices)
+	}
+}
+
+// devices retrieves the device name, or all devices if name is empty or
+// "all".
+func devices(c *wgctrl.Client, name string) ([]*wgtypes.Device, error) {
+	if name == "" || name == "all" {
+		devices, err := c.Devices()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get devices: %v", err)
+		}
+
+		return devices, nil
+	}
+
+	d, err := c.Device(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get device %q: %v", name, err)
+	}
+
+	return []*wgtypes.Device{d}, nil
+}
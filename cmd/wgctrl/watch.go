@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// watch implements "wgctrl watch", which polls devices and prints a line for
+// each change to their peers until interrupted.
+func watch(c *wgctrl.Client, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	interval := fs.Duration("interval", time.Second, "interval between polls of devices")
+	_ = fs.Parse(args)
+
+	if fs.NArg() > 1 || *interval <= 0 {
+		return usageError("watch")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	t := time.NewTicker(*interval)
+	defer t.Stop()
+
+	var prev []*wgtypes.Device
+	for {
+		cur, err := devices(c, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+
+		// On the first poll, every device is reported as added.
+		now := time.Now().Format(time.RFC3339)
+		for _, e := range watchEvents(prev, cur) {
+			fmt.Printf("%s %s\n", now, e)
+		}
+		prev = cur
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// watchEvents describes the changes between two sets of devices.
+func watchEvents(prev, cur []*wgtypes.Device) []string {
+	var events []string
+
+	before := make(map[string]*wgtypes.Device, len(prev))
+	for _, d := range prev {
+		before[d.Name] = d
+	}
+
+	for _, d := range cur {
+		b, ok := before[d.Name]
+		if !ok {
+			events = append(events, fmt.Sprintf("%s: device added", d.Name))
+			b = &wgtypes.Device{Name: d.Name}
+		}
+		delete(before, d.Name)
+
+		if b.ListenPort != d.ListenPort {
+			events = append(events, fmt.Sprintf("%s: listening port %d", d.Name, d.ListenPort))
+		}
+
+		events = append(events, peerEvents(d.Name, b.Peers, d.Peers)...)
+	}
+
+	for _, d := range prev {
+		if _, ok := before[d.Name]; ok {
+			events = append(events, fmt.Sprintf("%s: device removed", d.Name))
+		}
+	}
+
+	return events
+}
+
+// peerEvents describes the changes between two sets of peers of the device
+// name.
+func peerEvents(name string, prev, cur []wgtypes.Peer) []string {
+	var events []string
+
+	before := make(map[wgtypes.Key]wgtypes.Peer, len(prev))
+	for _, p := range prev {
+		before[p.PublicKey] = p
+	}
+
+	for _, p := range cur {
+		prefix := fmt.Sprintf("%s: peer %s", name, p.PublicKey)
+
+		b, ok := before[p.PublicKey]
+		if !ok {
+			events = append(events, prefix+": added")
+		}
+		delete(before, p.PublicKey)
+
+		if endpoint(b.Endpoint) != endpoint(p.Endpoint) {
+			events = append(events, fmt.Sprintf("%s: endpoint %s", prefix, endpoint(p.Endpoint)))
+		}
+		if !b.LastHandshakeTime.Equal(p.LastHandshakeTime) && !p.LastHandshakeTime.IsZero() {
+			events = append(events, fmt.Sprintf("%s: handshake at %s", prefix, p.LastHandshakeTime.Format(time.RFC3339)))
+		}
+	}
+
+	for _, p := range prev {
+		if _, ok := before[p.PublicKey]; ok {
+			events = append(events, fmt.Sprintf("%s: peer %s: removed", name, p.PublicKey))
+		}
+	}
+
+	return events
+}
+
+// endpoint formats addr, which may be nil.
+func endpoint(addr *net.UDPAddr) string {
+	if addr == nil {
+		return "(none)"
+	}
+
+	return addr.String()
+}
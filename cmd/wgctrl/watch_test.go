@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestWatchEvents(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+		c = wgtest.MustPublicKey()
+
+		hs = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	prev := []*wgtypes.Device{
+		{
+			Name:       "wg0",
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{
+				{PublicKey: a, Endpoint: wgtest.MustUDPAddr("192.0.2.1:51820")},
+				{PublicKey: b},
+			},
+		},
+		{Name: "wg1"},
+	}
+
+	cur := []*wgtypes.Device{
+		{
+			Name:       "wg0",
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{
+				{
+					PublicKey:         a,
+					Endpoint:          wgtest.MustUDPAddr("192.0.2.2:51820"),
+					LastHandshakeTime: hs,
+				},
+				{PublicKey: c},
+			},
+		},
+		{Name: "wg2", ListenPort: 1},
+	}
+
+	want := []string{
+		"wg0: peer " + a.String() + ": endpoint 192.0.2.2:51820",
+		"wg0: peer " + a.String() + ": handshake at 2020-01-01T00:00:00Z",
+		"wg0: peer " + c.String() + ": added",
+		"wg0: peer " + b.String() + ": removed",
+		"wg2: device added",
+		"wg2: listening port 1",
+		"wg1: device removed",
+	}
+
+	if diff := cmp.Diff(want, watchEvents(prev, cur)); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([]string(nil), watchEvents(cur, cur)); diff != "" {
+		t.Fatalf("unexpected events for unchanged devices (-want +got):\n%s", diff)
+	}
+}
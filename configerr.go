@@ -0,0 +1,124 @@
+package wgctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A ConfigError is returned by ConfigureDevice when a configuration could
+// not be applied, and identifies the part of the configuration which failed
+// when it is known. Configurations which fail validation are never applied.
+// Configurations rejected by the device may have been partially applied,
+// depending on the backend.
+type ConfigError struct {
+	// Device is the name of the device being configured.
+	Device string
+
+	// Peer is the public key of the peer whose configuration failed, or nil
+	// if the configuration of the device itself failed.
+	Peer *wgtypes.Key
+
+	// Field is the name of the invalid field of wgtypes.Config or
+	// wgtypes.PeerConfig, if known.
+	Field string
+
+	// Applied lists the peers whose configuration was applied one at a time
+	// before the failure, when the Client was created with
+	// WithPeerIsolation. It is empty otherwise.
+	Applied []wgtypes.Key
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error implements error.
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	b.WriteString("wgctrl: ")
+	if e.Device != "" {
+		fmt.Fprintf(&b, "device %s: ", e.Device)
+	}
+	if e.Peer != nil {
+		fmt.Fprintf(&b, "peer %s: ", *e.Peer)
+	}
+	b.WriteString(e.Err.Error())
+	if len(e.Applied) > 0 {
+		fmt.Fprintf(&b, " (%d peers applied)", len(e.Applied))
+	}
+
+	return b.String()
+}
+
+// Unwrap implements errors unwrapping.
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// newConfigError wraps err, returned by a backend which failed to apply cfg
+// to the device name, in a *ConfigError. The backend's error does not say
+// which part of cfg it rejected, so the peer is only identified when cfg
+// configures a single peer and none of the device's own fields.
+func newConfigError(name string, cfg wgtypes.Config, err error) error {
+	var cerr *ConfigError
+	if errors.As(err, &cerr) {
+		return err
+	}
+
+	cerr = &ConfigError{Device: name, Err: err}
+	if len(cfg.Peers) == 1 && cfg.PrivateKey == nil && cfg.ListenPort == nil &&
+		cfg.FirewallMark == nil && !cfg.ReplacePeers {
+		k := cfg.Peers[0].PublicKey
+		cerr.Peer = &k
+	}
+
+	return cerr
+}
+
+// shouldIsolate reports whether a failure to apply cfg, which returned err,
+// should be explained by applying cfg one peer at a time.
+func (c *Client) shouldIsolate(cfg wgtypes.Config, err error) bool {
+	return c.isolatePeers &&
+		len(cfg.Peers) > 1 &&
+		!cfg.ReplacePeers &&
+		!errors.Is(err, os.ErrNotExist) &&
+		!errors.Is(err, os.ErrPermission)
+}
+
+// isolateConfig applies cfg to the device name using wgc one part at a time
+// after wgc rejected it as a whole with error orig, so that the peer which
+// fails can be identified. The device's own fields are applied first,
+// followed by each peer in turn. A *ConfigError is always returned: it names
+// the failed peer and the peers applied before it, or if every peer was
+// applied, it lists them all and wraps orig.
+func (c *Client) isolateConfig(wgc wginternal.Client, name string, cfg wgtypes.Config, orig error) error {
+	base := cfg
+	base.Peers = nil
+	if base.PrivateKey != nil || base.ListenPort != nil || base.FirewallMark != nil {
+		if err := c.limit(rateConfigure, name); err != nil {
+			return &ConfigError{Device: name, Err: orig}
+		}
+		if err := wgc.ConfigureDevice(name, base); err != nil {
+			// The failure is not specific to any peer.
+			return &ConfigError{Device: name, Err: orig}
+		}
+	}
+
+	applied := make([]wgtypes.Key, 0, len(cfg.Peers))
+	for _, p := range cfg.Peers {
+		if err := c.limit(rateConfigure, name); err != nil {
+			return &ConfigError{Device: name, Applied: applied, Err: orig}
+		}
+
+		if err := wgc.ConfigureDevice(name, wgtypes.Config{Peers: []wgtypes.PeerConfig{p}}); err != nil {
+			k := p.PublicKey
+			return &ConfigError{Device: name, Peer: &k, Applied: applied, Err: err}
+		}
+
+		applied = append(applied, p.PublicKey)
+	}
+
+	return &ConfigError{Device: name, Applied: applied, Err: orig}
+}
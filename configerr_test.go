@@ -0,0 +1,186 @@
+package wgctrl
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientConfigureDeviceConfigError(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+		c = wgtest.MustPublicKey()
+
+		errInvalid = errors.New("invalid argument")
+	)
+
+	cfg := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{
+			{PublicKey: a, AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")}},
+			{PublicKey: b, AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")}},
+			{PublicKey: c, AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.3/32")}},
+		},
+	}
+
+	invalid := cfg
+	invalid.Peers = append([]wgtypes.PeerConfig(nil), cfg.Peers...)
+	invalid.Peers[1].AllowedIPs = []net.IPNet{{IP: net.IPv4(10, 0, 0, 2), Mask: net.IPMask{0xff, 0x00, 0xff, 0x00}}}
+
+	tests := []struct {
+		name    string
+		isolate bool
+		cfg     wgtypes.Config
+		fn      func(cfg wgtypes.Config) error
+		want    *ConfigError
+		calls   int
+	}{
+		{
+			name: "invalid",
+			cfg:  invalid,
+			fn: func(_ wgtypes.Config) error {
+				panic("configured an invalid configuration")
+			},
+			want: &ConfigError{Device: "wg0", Peer: &b, Field: "AllowedIPs"},
+		},
+		{
+			name: "rejected",
+			cfg:  cfg,
+			fn: func(_ wgtypes.Config) error {
+				return errInvalid
+			},
+			// Without peer isolation, the configuration is not reapplied to
+			// find the failed peer.
+			want:  &ConfigError{Device: "wg0", Err: errInvalid},
+			calls: 1,
+		},
+		{
+			name: "rejected peer",
+			cfg:  wgtypes.Config{Peers: cfg.Peers[1:2]},
+			fn: func(_ wgtypes.Config) error {
+				return errInvalid
+			},
+			want:  &ConfigError{Device: "wg0", Peer: &b, Err: errInvalid},
+			calls: 1,
+		},
+		{
+			name: "rejected device",
+			cfg:  wgtypes.Config{ReplacePeers: true, Peers: cfg.Peers[1:2]},
+			fn: func(_ wgtypes.Config) error {
+				return errInvalid
+			},
+			want:  &ConfigError{Device: "wg0", Err: errInvalid},
+			calls: 1,
+		},
+		{
+			name:    "isolated peer",
+			isolate: true,
+			cfg:     cfg,
+			fn: func(cfg wgtypes.Config) error {
+				for _, p := range cfg.Peers {
+					if p.PublicKey == b {
+						return errInvalid
+					}
+				}
+
+				return nil
+			},
+			want: &ConfigError{
+				Device:  "wg0",
+				Peer:    &b,
+				Applied: []wgtypes.Key{a},
+				Err:     errInvalid,
+			},
+			// All at once, then a and b.
+			calls: 3,
+		},
+		{
+			name:    "isolated all applied",
+			isolate: true,
+			cfg:     cfg,
+			fn: func(cfg wgtypes.Config) error {
+				if len(cfg.Peers) > 1 {
+					return errInvalid
+				}
+
+				return nil
+			},
+			// The original error is returned even though each peer was
+			// applied on its own.
+			want: &ConfigError{
+				Device:  "wg0",
+				Applied: []wgtypes.Key{a, b, c},
+				Err:     errInvalid,
+			},
+			calls: 4,
+		},
+		{
+			name:    "isolated device",
+			isolate: true,
+			cfg:     wgtypes.Config{ListenPort: new(int), Peers: cfg.Peers},
+			fn: func(cfg wgtypes.Config) error {
+				if cfg.ListenPort != nil {
+					return errInvalid
+				}
+
+				return nil
+			},
+			want: &ConfigError{Device: "wg0", Err: errInvalid},
+			// All at once, then the device.
+			calls: 2,
+		},
+		{
+			name:    "isolated replace peers",
+			isolate: true,
+			cfg:     wgtypes.Config{ReplacePeers: true, Peers: cfg.Peers},
+			fn: func(_ wgtypes.Config) error {
+				return errInvalid
+			},
+			// Peers are never replaced piecemeal.
+			want:  &ConfigError{Device: "wg0", Err: errInvalid},
+			calls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			client := &Client{
+				cs: []wginternal.Client{&testClient{
+					ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+						calls++
+						return tt.fn(cfg)
+					},
+				}},
+				isolatePeers: tt.isolate,
+			}
+
+			err := client.ConfigureDevice("wg0", tt.cfg)
+
+			var cerr *ConfigError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected ConfigError, but got: %v", err)
+			}
+			t.Logf("OK error: %v", err)
+
+			if tt.want.Err != nil && !errors.Is(err, tt.want.Err) {
+				t.Fatalf("expected underlying error %v, but got: %v", tt.want.Err, cerr.Err)
+			}
+
+			// The underlying errors are compared above.
+			cerr.Err, tt.want.Err = nil, nil
+			if diff := cmp.Diff(tt.want, cerr); diff != "" {
+				t.Fatalf("unexpected ConfigError (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.calls, calls); diff != "" {
+				t.Fatalf("unexpected number of calls (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
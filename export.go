@@ -0,0 +1,225 @@
+package wgctrl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A CSVColumn is a column written by WriteCSV and Client.ExportCSV. Each row
+// describes one peer.
+type CSVColumn int
+
+// Possible CSVColumn values. Their names match the columns read by
+// wgpeer.ImportPeers, where applicable.
+const (
+	// CSVDevice is the name of the peer's device.
+	CSVDevice CSVColumn = iota
+
+	// CSVPublicKey is the peer's public key.
+	CSVPublicKey
+
+	// CSVEndpoint is the peer's endpoint, or empty if it has none.
+	CSVEndpoint
+
+	// CSVAllowedIPs is the peer's allowed IPs, sorted and separated by
+	// commas.
+	CSVAllowedIPs
+
+	// CSVPersistentKeepalive is the peer's persistent keepalive interval
+	// in seconds.
+	CSVPersistentKeepalive
+
+	// CSVHasPresharedKey is "true" if the peer has a preshared key, and
+	// "false" otherwise. The key itself is never written.
+	CSVHasPresharedKey
+
+	// CSVLastHandshake is the time of the peer's most recent handshake in
+	// RFC 3339 format in UTC, or empty if it has never completed one.
+	CSVLastHandshake
+
+	// CSVReceiveBytes and CSVTransmitBytes are the number of bytes
+	// received from and transmitted to the peer.
+	CSVReceiveBytes
+	CSVTransmitBytes
+)
+
+// DefaultCSVColumns are the columns written by Client.ExportCSV if none are
+// specified.
+var DefaultCSVColumns = []CSVColumn{
+	CSVDevice,
+	CSVPublicKey,
+	CSVAllowedIPs,
+	CSVEndpoint,
+	CSVLastHandshake,
+	CSVReceiveBytes,
+	CSVTransmitBytes,
+}
+
+// String returns the name of a CSVColumn, as written in the header row.
+func (c CSVColumn) String() string {
+	switch c {
+	case CSVDevice:
+		return "device"
+	case CSVPublicKey:
+		return "public_key"
+	case CSVEndpoint:
+		return "endpoint"
+	case CSVAllowedIPs:
+		return "allowed_ips"
+	case CSVPersistentKeepalive:
+		return "persistent_keepalive"
+	case CSVHasPresharedKey:
+		return "has_preshared_key"
+	case CSVLastHandshake:
+		return "last_handshake"
+	case CSVReceiveBytes:
+		return "receive_bytes"
+	case CSVTransmitBytes:
+		return "transmit_bytes"
+	default:
+		return "unknown"
+	}
+}
+
+// fieldMask returns the fields of a Device required by c. Every column
+// requires the peers of a device, and so their public keys.
+func (c CSVColumn) fieldMask() FieldMask {
+	switch c {
+	case CSVAllowedIPs:
+		return FieldAllowedIPs
+	case CSVLastHandshake, CSVReceiveBytes, CSVTransmitBytes:
+		return FieldCounters
+	default:
+		return FieldPeers
+	}
+}
+
+// value formats the column c of the row for p on the device name.
+func (c CSVColumn) value(name string, p *wgtypes.Peer) string {
+	switch c {
+	case CSVDevice:
+		return name
+	case CSVPublicKey:
+		return p.PublicKey.String()
+	case CSVEndpoint:
+		if p.Endpoint == nil {
+			return ""
+		}
+		return p.Endpoint.String()
+	case CSVAllowedIPs:
+		ips := make([]net.IPNet, len(p.AllowedIPs))
+		copy(ips, p.AllowedIPs)
+		sortIPNets(ips)
+		return wgtypes.JoinAllowedIPs(ips)
+	case CSVPersistentKeepalive:
+		return strconv.Itoa(int(p.PersistentKeepaliveInterval / time.Second))
+	case CSVHasPresharedKey:
+		return strconv.FormatBool(p.HasPresharedKey)
+	case CSVLastHandshake:
+		if p.LastHandshakeTime.IsZero() {
+			return ""
+		}
+		return p.LastHandshakeTime.UTC().Format(time.RFC3339)
+	case CSVReceiveBytes:
+		return strconv.FormatInt(p.ReceiveBytes, 10)
+	case CSVTransmitBytes:
+		return strconv.FormatInt(p.TransmitBytes, 10)
+	default:
+		return ""
+	}
+}
+
+// WriteCSV writes a header row and a row for each peer of devices to w,
+// containing the specified columns. Rows are ordered by device name and then
+// by public key, regardless of the order of devices and peers, so that
+// exports taken at different times can be compared. Devices with no peers
+// produce no rows. devices are not modified.
+func WriteCSV(w io.Writer, devices []*wgtypes.Device, columns []CSVColumn) error {
+	type row struct {
+		name string
+		p    *wgtypes.Peer
+	}
+
+	var rows []row
+	for _, d := range devices {
+		for i := range d.Peers {
+			rows = append(rows, row{name: d.Name, p: &d.Peers[i]})
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].name != rows[j].name {
+			return rows[i].name < rows[j].name
+		}
+
+		return bytes.Compare(rows[i].p.PublicKey[:], rows[j].p.PublicKey[:]) < 0
+	})
+
+	cw := csv.NewWriter(w)
+	rec := make([]string, len(columns))
+	for i, c := range columns {
+		rec[i] = c.String()
+	}
+	if err := cw.Write(rec); err != nil {
+		return err
+	}
+
+	for _, r := range rows {
+		for i, c := range columns {
+			rec[i] = c.value(r.name, r.p)
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ExportCSV writes the peers of all devices to w as described by WriteCSV,
+// using DefaultCSVColumns if no columns are specified. Only the fields
+// required by columns are retrieved, as with DeviceFields, so that secrets
+// are never retrieved.
+func (c *Client) ExportCSV(w io.Writer, columns ...CSVColumn) (err error) {
+	span := c.startSpan("ExportCSV")
+	defer func() { span.End(err) }()
+
+	if len(columns) == 0 {
+		columns = DefaultCSVColumns
+	}
+
+	var mask FieldMask
+	for _, col := range columns {
+		mask |= col.fieldMask()
+	}
+
+	if err := c.limit(rateDump, ""); err != nil {
+		return err
+	}
+
+	var devices []*wgtypes.Device
+	for _, wgc := range c.cs {
+		ds, err := devicesFields(wgc, wginternal.FieldMask(mask)&^c.exclude)
+		if err != nil {
+			return err
+		}
+
+		devices = append(devices, ds...)
+	}
+
+	if err := WriteCSV(w, devices, columns); err != nil {
+		return fmt.Errorf("wgctrl: failed to write CSV: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package wgctrl
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientExportCSV(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+	)
+
+	// Order the keys as WriteCSV does.
+	if bytes.Compare(b[:], a[:]) < 0 {
+		a, b = b, a
+	}
+
+	hs := time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("", 3600))
+
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DevicesFunc: func() ([]*wgtypes.Device, error) {
+				return []*wgtypes.Device{
+					{
+						Name:       "wg1",
+						PrivateKey: wgtest.MustPrivateKey(),
+						Peers: []wgtypes.Peer{
+							{
+								PublicKey:         b,
+								PresharedKey:      wgtest.MustPresharedKey(),
+								HasPresharedKey:   true,
+								LastHandshakeTime: hs,
+								ReceiveBytes:      1,
+								TransmitBytes:     2,
+							},
+							{
+								PublicKey: a,
+								Endpoint:  wgtest.MustUDPAddr("192.0.2.1:51820"),
+								AllowedIPs: []net.IPNet{
+									wgtest.MustCIDR("fd00::/64"),
+									wgtest.MustCIDR("10.0.0.0/24"),
+								},
+							},
+						},
+					},
+					{Name: "wg2"},
+					{
+						Name:  "wg0",
+						Peers: []wgtypes.Peer{{PublicKey: b}},
+					},
+				}, nil
+			},
+		}},
+	}
+
+	tests := []struct {
+		name string
+		cols []CSVColumn
+		want string
+	}{
+		{
+			name: "default",
+			want: "device,public_key,allowed_ips,endpoint,last_handshake,receive_bytes,transmit_bytes\n" +
+				"wg0," + b.String() + ",,,,0,0\n" +
+				"wg1," + a.String() + ",\"10.0.0.0/24, fd00::/64\",192.0.2.1:51820,,0,0\n" +
+				"wg1," + b.String() + ",,,2020-01-02T02:04:05Z,1,2\n",
+		},
+		{
+			name: "selected",
+			cols: []CSVColumn{CSVPublicKey, CSVHasPresharedKey},
+			want: "public_key,has_preshared_key\n" +
+				b.String() + ",false\n" +
+				a.String() + ",false\n" +
+				b.String() + ",true\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := c.ExportCSV(&buf, tt.cols...); err != nil {
+				t.Fatalf("failed to export CSV: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, buf.String()); diff != "" {
+				t.Fatalf("unexpected CSV (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
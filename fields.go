@@ -0,0 +1,60 @@
+package wgctrl
+
+import (
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A FieldMask selects the fields of a Device retrieved by
+// Client.DeviceFields. FieldMasks may be combined using bitwise operators;
+// for example, FieldAll &^ FieldAllowedIPs selects all fields except the
+// allowed IPs of each peer.
+type FieldMask uint
+
+// Possible FieldMask values.
+const (
+	// FieldDevice selects the fields of a Device other than its Name, Type,
+	// and Peers.
+	FieldDevice = FieldMask(wginternal.FieldDevice)
+
+	// FieldPeers selects the configuration of each Peer: its preshared key,
+	// whether it has a preshared key, its endpoint, persistent keepalive
+	// interval, and protocol version.
+	FieldPeers = FieldMask(wginternal.FieldPeers)
+
+	// FieldCounters selects the last handshake time and the transmit and
+	// receive byte counters of each Peer.
+	FieldCounters = FieldMask(wginternal.FieldCounters)
+
+	// FieldAllowedIPs selects the allowed IPs of each Peer.
+	FieldAllowedIPs = FieldMask(wginternal.FieldAllowedIPs)
+
+	// FieldSecrets selects the PrivateKey of a Device and the PresharedKey of
+	// each Peer, if FieldDevice and FieldPeers are also selected. Secrets are
+	// never retrieved by a Client created using WithoutSecrets.
+	FieldSecrets = FieldMask(wginternal.FieldSecrets)
+
+	// FieldAll selects all fields, as retrieved by Client.Device.
+	FieldAll = FieldMask(wginternal.FieldAll)
+)
+
+// DeviceFields is like Device, but only retrieves the fields of the device
+// selected by mask. The device's Name and Type are always retrieved. If mask
+// selects any fields of peers, each Peer's PublicKey is also retrieved;
+// otherwise, the device has no Peers.
+//
+// Where the backend supports it, fields which are not selected are not parsed
+// or stored, which is much cheaper for devices with many peers. For example,
+// a monitoring tool which only requires handshake times may use FieldCounters
+// to avoid parsing a large table of allowed IPs.
+func (c *Client) DeviceFields(name string, mask FieldMask) (d *wgtypes.Device, err error) {
+	span := c.startSpan("DeviceFields")
+	defer func() { span.End(err) }()
+
+	d = new(wgtypes.Device)
+	if err := c.deviceFieldsInto(span, name, d, wginternal.FieldMask(mask)); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
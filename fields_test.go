@@ -0,0 +1,144 @@
+package wgctrl
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientDeviceFieldsFallback(t *testing.T) {
+	var (
+		peer = wgtest.MustPublicKey()
+		ipn  = wgtest.MustCIDR("192.0.2.0/24")
+	)
+
+	device := func() *wgtypes.Device {
+		return &wgtypes.Device{
+			Name:       "wg0",
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{{
+				PublicKey:         peer,
+				Endpoint:          wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+				LastHandshakeTime: time.Unix(1, 0),
+				ReceiveBytes:      1,
+				AllowedIPs:        []net.IPNet{ipn},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		mask FieldMask
+		d    *wgtypes.Device
+	}{
+		{
+			name: "all",
+			mask: FieldAll,
+			d:    device(),
+		},
+		{
+			name: "device",
+			mask: FieldDevice,
+			d: &wgtypes.Device{
+				Name:       "wg0",
+				ListenPort: 51820,
+			},
+		},
+		{
+			name: "counters",
+			mask: FieldCounters,
+			d: &wgtypes.Device{
+				Name: "wg0",
+				Peers: []wgtypes.Peer{{
+					PublicKey:         peer,
+					LastHandshakeTime: time.Unix(1, 0),
+					ReceiveBytes:      1,
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The test backend does not support field masks, so the Client
+			// must clear the fields which were not selected.
+			c := &Client{cs: []wginternal.Client{&testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return device(), nil
+				},
+			}}}
+
+			d, err := c.DeviceFields("wg0", tt.mask)
+			if err != nil {
+				t.Fatalf("failed to get device: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.d, d); diff != "" {
+				t.Fatalf("unexpected device (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientWithoutSecrets(t *testing.T) {
+	device := func() *wgtypes.Device {
+		return &wgtypes.Device{
+			Name:       "wg0",
+			PrivateKey: wgtest.MustPrivateKey(),
+			Peers: []wgtypes.Peer{{
+				PresharedKey: wgtest.MustPresharedKey(),
+			}},
+		}
+	}
+
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DevicesFunc: func() ([]*wgtypes.Device, error) {
+				return []*wgtypes.Device{device()}, nil
+			},
+			DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+				return device(), nil
+			},
+		}},
+		exclude: wginternal.FieldSecrets,
+	}
+
+	// Whether peers have preshared keys is reported without the keys.
+	want := &wgtypes.Device{
+		Name:  "wg0",
+		Peers: []wgtypes.Peer{{HasPresharedKey: true}},
+	}
+
+	devs, err := c.Devices()
+	if err != nil {
+		t.Fatalf("failed to get devices: %v", err)
+	}
+
+	if diff := cmp.Diff([]*wgtypes.Device{want}, devs); diff != "" {
+		t.Fatalf("unexpected devices (-want +got):\n%s", diff)
+	}
+
+	// Secrets are excluded even if they are explicitly requested.
+	for _, fn := range []func() (*wgtypes.Device, error){
+		func() (*wgtypes.Device, error) { return c.Device("wg0") },
+		func() (*wgtypes.Device, error) { return c.DeviceFields("wg0", FieldAll) },
+		func() (*wgtypes.Device, error) {
+			var d wgtypes.Device
+			return &d, c.DeviceInto("wg0", &d)
+		},
+	} {
+		d, err := fn()
+		if err != nil {
+			t.Fatalf("failed to get device: %v", err)
+		}
+
+		if diff := cmp.Diff(want, d); diff != "" {
+			t.Fatalf("unexpected device (-want +got):\n%s", diff)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package wgctrl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"sort"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ErrConflict is returned by ConfigureDeviceIf when a device was changed
+// since its generation was computed.
+var ErrConflict = errors.New("wgctrl: device was modified concurrently")
+
+// Generation returns a token which identifies the configuration of d, for
+// use with ConfigureDeviceIf. The token is a hash of the device's public
+// key, listening port, firewall mark, and the public key, persistent keepalive
+// interval, allowed IPs, and whether a preshared key is set for each of its
+// peers. The order of peers and allowed IPs does not affect the token.
+//
+// Private and preshared keys are not included, so that the token is the same
+// for a device retrieved with or without its secrets. A change of preshared
+// key which leaves a peer with a preshared key does not change the token.
+//
+// Peer endpoints and statistics are not included, since WireGuard updates
+// them as peers roam and exchange traffic, rather than as the device is
+// configured.
+func Generation(d *wgtypes.Device) string {
+	h := sha256.New()
+
+	var b [8]byte
+	putInt := func(v int64) {
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		h.Write(b[:])
+	}
+
+	h.Write(d.PublicKey[:])
+	putInt(int64(d.ListenPort))
+	putInt(int64(d.FirewallMark))
+
+	peers := make([]*wgtypes.Peer, 0, len(d.Peers))
+	for i := range d.Peers {
+		peers = append(peers, &d.Peers[i])
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return bytes.Compare(peers[i].PublicKey[:], peers[j].PublicKey[:]) < 0
+	})
+
+	for _, p := range peers {
+		h.Write(p.PublicKey[:])
+		if p.HasPresharedKey || p.PresharedKey != (wgtypes.Key{}) {
+			putInt(1)
+		} else {
+			putInt(0)
+		}
+		putInt(int64(p.PersistentKeepaliveInterval))
+
+		ips := make([]net.IPNet, len(p.AllowedIPs))
+		copy(ips, p.AllowedIPs)
+		sortIPNets(ips)
+
+		putInt(int64(len(ips)))
+		for _, ipn := range ips {
+			ip := ipn.IP.To16()
+			ones, _ := ipn.Mask.Size()
+
+			h.Write(ip)
+			putInt(int64(ones))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// ConfigureDeviceIf is like ConfigureDevice, but only applies cfg if the
+// Generation of the device specified by name is still generation, and
+// otherwise returns ErrConflict. This allows several controllers to manage
+// the same device using optimistic concurrency: each reads the device,
+// computes a change, and retries from the start on ErrConflict.
+//
+// The check and the change are only atomic with respect to other processes
+// which use WithDeviceLocks with the same directory.
+func (c *Client) ConfigureDeviceIf(name, generation string, cfg wgtypes.Config) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	return c.withDeviceLock(name, func() error {
+		d, err := c.Device(name)
+		if err != nil {
+			return err
+		}
+
+		if Generation(d) != generation {
+			return ErrConflict
+		}
+
+		return c.configureDevice(name, cfg, true)
+	})
+}
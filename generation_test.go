@@ -0,0 +1,153 @@
+package wgctrl
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestGeneration(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		a    = wgtest.MustPublicKey()
+		b    = wgtest.MustPublicKey()
+	)
+
+	device := func() *wgtypes.Device {
+		return &wgtypes.Device{
+			Name:       "wg0",
+			PrivateKey: priv,
+			PublicKey:  priv.PublicKey(),
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{
+				{
+					PublicKey: a,
+					AllowedIPs: []net.IPNet{
+						wgtest.MustCIDR("10.0.0.1/32"),
+						wgtest.MustCIDR("fd00::1/128"),
+					},
+				},
+				{PublicKey: b},
+			},
+		}
+	}
+
+	base := device()
+	gen := Generation(base)
+
+	tests := []struct {
+		name string
+		fn   func(d *wgtypes.Device)
+		same bool
+	}{
+		{
+			name: "unchanged",
+			same: true,
+		},
+		{
+			name: "reordered",
+			fn: func(d *wgtypes.Device) {
+				d.Peers[0], d.Peers[1] = d.Peers[1], d.Peers[0]
+				ips := d.Peers[1].AllowedIPs
+				ips[0], ips[1] = ips[1], ips[0]
+			},
+			same: true,
+		},
+		{
+			name: "runtime state",
+			fn: func(d *wgtypes.Device) {
+				d.Peers[0].Endpoint = wgtest.MustUDPAddr("192.0.2.1:51820")
+				d.Peers[0].LastHandshakeTime = time.Now()
+				d.Peers[0].ReceiveBytes = 1
+			},
+			same: true,
+		},
+		{
+			name: "without secrets",
+			fn:   func(d *wgtypes.Device) { d.PrivateKey = wgtypes.Key{} },
+			same: true,
+		},
+		{
+			name: "private key",
+			fn: func(d *wgtypes.Device) {
+				k := wgtest.MustPrivateKey()
+				d.PrivateKey, d.PublicKey = wgtypes.Key{}, k.PublicKey()
+			},
+		},
+		{
+			name: "preshared key",
+			fn:   func(d *wgtypes.Device) { d.Peers[1].HasPresharedKey = true },
+		},
+		{
+			name: "listen port",
+			fn:   func(d *wgtypes.Device) { d.ListenPort = 51821 },
+		},
+		{
+			name: "allowed IP",
+			fn: func(d *wgtypes.Device) {
+				d.Peers[0].AllowedIPs[0] = wgtest.MustCIDR("10.0.0.2/32")
+			},
+		},
+		{
+			name: "keepalive",
+			fn:   func(d *wgtypes.Device) { d.Peers[1].PersistentKeepaliveInterval = 25 * time.Second },
+		},
+		{
+			name: "peer removed",
+			fn:   func(d *wgtypes.Device) { d.Peers = d.Peers[:1] },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := device()
+			if tt.fn != nil {
+				tt.fn(d)
+			}
+
+			if got := Generation(d) == gen; got != tt.same {
+				t.Fatalf("unexpected generation equality: %v", got)
+			}
+		})
+	}
+}
+
+func TestClientConfigureDeviceIf(t *testing.T) {
+	d := &wgtypes.Device{Name: "wg0", ListenPort: 51820}
+	gen := Generation(d)
+
+	var configured int
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+				return d, nil
+			},
+			ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+				configured++
+				d = &wgtypes.Device{Name: "wg0", ListenPort: 51821}
+				return nil
+			},
+		}},
+	}
+
+	port := 51821
+	cfg := wgtypes.Config{ListenPort: &port}
+
+	if err := c.ConfigureDeviceIf("wg0", gen, cfg); err != nil {
+		t.Fatalf("failed to configure device: %v", err)
+	}
+
+	// The device has changed, so the stale generation must conflict.
+	if err := c.ConfigureDeviceIf("wg0", gen, cfg); !errors.Is(err, ErrConflict) {
+		t.Fatalf("expected ErrConflict, but got: %v", err)
+	}
+
+	if configured != 1 {
+		t.Fatalf("unexpected number of configurations: %d", configured)
+	}
+}
@@ -0,0 +1,195 @@
+package wgctrl
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A HostIssueKind is the kind of a HostIssue.
+type HostIssueKind int
+
+// Possible HostIssueKind values.
+const (
+	_ HostIssueKind = iota
+
+	// SharedListenPort indicates that multiple devices report the same
+	// listen port. The kernel only permits this when the devices are in
+	// separate network namespaces, and otherwise one of them is not
+	// receiving traffic.
+	SharedListenPort
+
+	// OverlappingAllowedIPs indicates that peers on different devices have
+	// overlapping allowed IPs, so that the host's routing table rather than
+	// WireGuard's cryptokey routing decides which tunnel is used.
+	OverlappingAllowedIPs
+)
+
+// A HostIssue is a potential misconfiguration spanning multiple WireGuard
+// devices, as reported by Client.ValidateHost.
+type HostIssue struct {
+	// Kind is the kind of issue.
+	Kind HostIssueKind
+
+	// Devices are the names of the devices involved in the issue.
+	Devices []string
+
+	// Port is the shared listen port, for SharedListenPort issues.
+	Port int
+
+	// Peers and AllowedIPs are the public keys of the peers and their
+	// allowed IPs which overlap, in the same order as Devices, for
+	// OverlappingAllowedIPs issues.
+	Peers      []wgtypes.Key
+	AllowedIPs []net.IPNet
+}
+
+// String returns a human-readable description of a HostIssue.
+func (i HostIssue) String() string {
+	switch i.Kind {
+	case SharedListenPort:
+		return fmt.Sprintf("devices %s share listen port %d",
+			strings.Join(i.Devices, ", "), i.Port)
+	case OverlappingAllowedIPs:
+		return fmt.Sprintf("allowed IPs %s (%s peer %s) and %s (%s peer %s) overlap",
+			&i.AllowedIPs[0], i.Devices[0], i.Peers[0],
+			&i.AllowedIPs[1], i.Devices[1], i.Peers[1])
+	default:
+		return "unknown host issue"
+	}
+}
+
+// ValidateHost performs sanity checks across all of the WireGuard devices on
+// this host, reporting devices which share a listen port and peers on
+// different devices with overlapping allowed IPs. Overlapping allowed IPs on
+// a single device are impossible, because WireGuard moves the prefix to the
+// most recently configured peer.
+//
+// A nil slice indicates that no issues were found.
+func (c *Client) ValidateHost() ([]HostIssue, error) {
+	devices, err := c.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	return validateHost(devices), nil
+}
+
+// validateHost produces HostIssues for devices.
+func validateHost(devices []*wgtypes.Device) []HostIssue {
+	var issues []HostIssue
+
+	ports := make(map[int][]string)
+	for _, d := range devices {
+		if d.ListenPort != 0 {
+			ports[d.ListenPort] = append(ports[d.ListenPort], d.Name)
+		}
+	}
+
+	for _, d := range devices {
+		names := ports[d.ListenPort]
+		if len(names) < 2 || names[0] != d.Name {
+			// Report each shared port once, with the first device.
+			continue
+		}
+
+		issues = append(issues, HostIssue{
+			Kind:    SharedListenPort,
+			Devices: names,
+			Port:    d.ListenPort,
+		})
+	}
+
+	return append(issues, overlaps(routes(devices))...)
+}
+
+// routes flattens the allowed IPs of each peer on devices into Routes sorted
+// by network address and then by prefix length, so that each prefix follows
+// all of the prefixes which contain it.
+func routes(devices []*wgtypes.Device) []Route {
+	var out []Route
+	for _, d := range devices {
+		for _, p := range d.Peers {
+			for _, ipn := range p.AllowedIPs {
+				out = append(out, Route{
+					Device: d.Name,
+					Peer:   p.PublicKey,
+					IPNet:  canonicalIPNet(ipn),
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].IPNet, out[j].IPNet
+		if len(a.IP) != len(b.IP) {
+			return len(a.IP) < len(b.IP)
+		}
+		if c := bytes.Compare(a.IP, b.IP); c != 0 {
+			return c < 0
+		}
+
+		ao, _ := a.Mask.Size()
+		bo, _ := b.Mask.Size()
+		return ao < bo
+	})
+
+	return out
+}
+
+// overlaps produces OverlappingAllowedIPs issues for peers on different
+// devices.
+func overlaps(routes []Route) []HostIssue {
+	var (
+		issues []HostIssue
+		stack  []Route
+	)
+
+	for _, o := range routes {
+		// Discard enclosing prefixes which end before this one begins. What
+		// remains on the stack contains this prefix.
+		for len(stack) > 0 && !contains(stack[len(stack)-1].IPNet, o.IPNet) {
+			stack = stack[:len(stack)-1]
+		}
+
+		for _, s := range stack {
+			if s.Device == o.Device {
+				continue
+			}
+
+			issues = append(issues, HostIssue{
+				Kind:       OverlappingAllowedIPs,
+				Devices:    []string{s.Device, o.Device},
+				Peers:      []wgtypes.Key{s.Peer, o.Peer},
+				AllowedIPs: []net.IPNet{s.IPNet, o.IPNet},
+			})
+		}
+
+		stack = append(stack, o)
+	}
+
+	return issues
+}
+
+// contains determines if outer contains all of inner.
+func contains(outer, inner net.IPNet) bool {
+	oo, obits := outer.Mask.Size()
+	io, ibits := inner.Mask.Size()
+
+	return obits == ibits && oo <= io && outer.Contains(inner.IP)
+}
+
+// canonicalIPNet returns ipn with its address masked, and with IPv4
+// addresses in their 4-byte form.
+func canonicalIPNet(ipn net.IPNet) net.IPNet {
+	ip := ipn.IP.To4()
+	if ip == nil || len(ipn.Mask) == net.IPv6len {
+		ip = ipn.IP.To16()
+	}
+
+	return net.IPNet{IP: ip.Mask(ipn.Mask), Mask: ipn.Mask}
+}
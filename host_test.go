@@ -0,0 +1,88 @@
+package wgctrl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func Test_validateHost(t *testing.T) {
+	var (
+		peerA = wgtest.MustPublicKey()
+		peerB = wgtest.MustPublicKey()
+		peerC = wgtest.MustPublicKey()
+	)
+
+	devices := []*wgtypes.Device{
+		{
+			Name:       "wg0",
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{{
+				PublicKey: peerA,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.0/16"),
+					wgtest.MustCIDR("fd00::/64"),
+				},
+			}},
+		},
+		{
+			Name:       "wg1",
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{{
+				PublicKey: peerB,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.1.0/24"),
+					wgtest.MustCIDR("10.1.0.0/24"),
+				},
+			}},
+		},
+		{
+			Name:       "wg2",
+			ListenPort: 51821,
+			Peers: []wgtypes.Peer{{
+				PublicKey: peerC,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("fd00::1/128"),
+					wgtest.MustCIDR("192.168.0.0/24"),
+				},
+			}},
+		},
+	}
+
+	want := []HostIssue{
+		{
+			Kind:    SharedListenPort,
+			Devices: []string{"wg0", "wg1"},
+			Port:    51820,
+		},
+		{
+			Kind:    OverlappingAllowedIPs,
+			Devices: []string{"wg0", "wg1"},
+			Peers:   []wgtypes.Key{peerA, peerB},
+			AllowedIPs: []net.IPNet{
+				wgtest.MustCIDR("10.0.0.0/16"),
+				wgtest.MustCIDR("10.0.1.0/24"),
+			},
+		},
+		{
+			Kind:    OverlappingAllowedIPs,
+			Devices: []string{"wg0", "wg2"},
+			Peers:   []wgtypes.Key{peerA, peerC},
+			AllowedIPs: []net.IPNet{
+				wgtest.MustCIDR("fd00::/64"),
+				wgtest.MustCIDR("fd00::1/128"),
+			},
+		},
+	}
+
+	if diff := cmp.Diff(want, validateHost(devices)); diff != "" {
+		t.Fatalf("unexpected issues (-want +got):\n%s", diff)
+	}
+
+	for _, i := range want {
+		t.Log(i)
+	}
+}
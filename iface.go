@@ -0,0 +1,121 @@
+package wgctrl
+
+import (
+	"errors"
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// An InterfaceConfig configures the network interface of a WireGuard device,
+// as wg-quick(8) does when it brings up a device.
+type InterfaceConfig struct {
+	// Addresses replace the interface's unicast addresses.
+	Addresses []net.IPNet
+
+	// MTU, if not 0, is the MTU of the interface.
+	MTU int
+
+	// Routes replace the static routes through the interface.
+	Routes []net.IPNet
+
+	// RouteAllowedIPs adds a route for the allowed IPs of each of the
+	// device's peers to Routes.
+	RouteAllowedIPs bool
+}
+
+// errConfigureInterfaceUnsupported is returned by ConfigureInterface on
+// platforms where it is not implemented.
+var errConfigureInterfaceUnsupported = errors.New("wgctrl: ConfigureInterface is only supported on Windows and macOS")
+
+// ConfigureInterface configures the addresses, MTU, and routes of the network
+// interface of the WireGuard device specified by name, using cfg. It is only
+// supported on Windows, where it uses the IP Helper API, and on macOS, where
+// it configures the utun interface of a userspace device directly rather than
+// running ifconfig(8) and route(8).
+//
+// If the device specified by name does not exist or is not a WireGuard device,
+// an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
+func (c *Client) ConfigureInterface(name string, cfg InterfaceConfig) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	d, err := c.Device(name)
+	if err != nil {
+		return err
+	}
+
+	return configureInterface(name, cfg.Addresses, cfg.MTU, interfaceRoutes(cfg, d.Peers))
+}
+
+// interfaceRoutes returns the routes configured by cfg for a device with
+// peers, without duplicates.
+func interfaceRoutes(cfg InterfaceConfig, peers []wgtypes.Peer) []net.IPNet {
+	var (
+		out  []net.IPNet
+		seen = make(map[string]bool)
+	)
+
+	add := func(n net.IPNet) {
+		n = net.IPNet{IP: n.IP.Mask(n.Mask), Mask: n.Mask}
+		if s := n.String(); !seen[s] {
+			seen[s] = true
+			out = append(out, n)
+		}
+	}
+
+	for _, r := range cfg.Routes {
+		add(r)
+	}
+
+	if cfg.RouteAllowedIPs {
+		for _, p := range peers {
+			for _, ip := range p.AllowedIPs {
+				add(ip)
+			}
+		}
+	}
+
+	return out
+}
+
+// errInterfaceDescriptionUnsupported is returned by InterfaceDescription and
+// SetInterfaceDescription on platforms where they are not implemented.
+var errInterfaceDescriptionUnsupported = errors.New("wgctrl: interface descriptions are only supported on Linux and Windows")
+
+// InterfaceDescription returns the human-readable description of the network
+// interface of the WireGuard device specified by name, as shown by standard
+// operating system tools: the interface alias (IFLA_IFALIAS) on Linux, and
+// the adapter description on Windows. If the interface has no description,
+// the empty string is returned.
+//
+// If the device specified by name does not exist or is not a WireGuard device,
+// an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
+func (c *Client) InterfaceDescription(name string) (string, error) {
+	if _, err := c.Device(name); err != nil {
+		return "", err
+	}
+
+	return interfaceDescription(name)
+}
+
+// SetInterfaceDescription sets the human-readable description of the network
+// interface of the WireGuard device specified by name, as described by
+// InterfaceDescription. On Linux, an empty description removes the alias. On
+// Windows, only the descriptions of kernel devices can be set, and the
+// description must not be empty.
+//
+// If the device specified by name does not exist or is not a WireGuard device,
+// an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
+func (c *Client) SetInterfaceDescription(name, description string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	if _, err := c.Device(name); err != nil {
+		return err
+	}
+
+	return setInterfaceDescription(name, description)
+}
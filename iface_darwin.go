@@ -0,0 +1,26 @@
+//go:build darwin
+// +build darwin
+
+package wgctrl
+
+import (
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgdarwin"
+)
+
+// configureInterface configures a utun network interface using ioctls and
+// the route socket.
+func configureInterface(name string, addrs []net.IPNet, mtu int, routes []net.IPNet) error {
+	return wgdarwin.ConfigureInterface(name, addrs, mtu, routes)
+}
+
+// interfaceDescription is not supported on this platform.
+func interfaceDescription(_ string) (string, error) {
+	return "", errInterfaceDescriptionUnsupported
+}
+
+// setInterfaceDescription is not supported on this platform.
+func setInterfaceDescription(_, _ string) error {
+	return errInterfaceDescriptionUnsupported
+}
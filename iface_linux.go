@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package wgctrl
+
+import (
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wglinux"
+)
+
+// configureInterface is not supported on this platform.
+func configureInterface(_ string, _ []net.IPNet, _ int, _ []net.IPNet) error {
+	return errConfigureInterfaceUnsupported
+}
+
+// interfaceDescription returns the alias of a network interface.
+func interfaceDescription(name string) (string, error) {
+	return wglinux.InterfaceAlias(name)
+}
+
+// setInterfaceDescription sets the alias of a network interface.
+func setInterfaceDescription(name, description string) error {
+	return wglinux.SetInterfaceAlias(name, description)
+}
@@ -0,0 +1,21 @@
+//go:build !linux && !windows && !darwin
+// +build !linux,!windows,!darwin
+
+package wgctrl
+
+import "net"
+
+// configureInterface is not supported on this platform.
+func configureInterface(_ string, _ []net.IPNet, _ int, _ []net.IPNet) error {
+	return errConfigureInterfaceUnsupported
+}
+
+// interfaceDescription is not supported on this platform.
+func interfaceDescription(_ string) (string, error) {
+	return "", errInterfaceDescriptionUnsupported
+}
+
+// setInterfaceDescription is not supported on this platform.
+func setInterfaceDescription(_, _ string) error {
+	return errInterfaceDescriptionUnsupported
+}
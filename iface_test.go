@@ -0,0 +1,55 @@
+package wgctrl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func Test_interfaceRoutes(t *testing.T) {
+	peers := []wgtypes.Peer{
+		{AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.2/32"), wgtest.MustCIDR("fd00::2/128")}},
+		{AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.2/32"), wgtest.MustCIDR("192.168.1.0/24")}},
+	}
+
+	tests := []struct {
+		name string
+		cfg  InterfaceConfig
+		want []net.IPNet
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "routes",
+			cfg: InterfaceConfig{Routes: []net.IPNet{
+				{IP: net.IPv4(10, 1, 2, 3), Mask: net.CIDRMask(16, 32)},
+			}},
+			want: []net.IPNet{wgtest.MustCIDR("10.1.0.0/16")},
+		},
+		{
+			name: "allowed IPs",
+			cfg: InterfaceConfig{
+				Routes:          []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+				RouteAllowedIPs: true,
+			},
+			want: []net.IPNet{
+				wgtest.MustCIDR("10.0.0.2/32"),
+				wgtest.MustCIDR("fd00::2/128"),
+				wgtest.MustCIDR("192.168.1.0/24"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := interfaceRoutes(tt.cfg, peers)
+			if diff := cmp.Diff(ipNetsString(tt.want), ipNetsString(got)); diff != "" {
+				t.Fatalf("unexpected routes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package wgctrl
+
+import (
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgwindows"
+)
+
+// configureInterface configures a network interface using the IP Helper API.
+func configureInterface(name string, addrs []net.IPNet, mtu int, routes []net.IPNet) error {
+	return wgwindows.ConfigureInterface(name, addrs, mtu, routes)
+}
+
+// interfaceDescription returns the description of a network adapter.
+func interfaceDescription(name string) (string, error) {
+	return wgwindows.InterfaceDescription(name)
+}
+
+// setInterfaceDescription sets the description of a network adapter.
+func setInterfaceDescription(name, description string) error {
+	return wgwindows.SetInterfaceDescription(name, description)
+}
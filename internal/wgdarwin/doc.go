@@ -0,0 +1,6 @@
+// Package wgdarwin provides internal access to the network configuration of
+// macOS utun interfaces used by userspace WireGuard devices.
+//
+// This package is internal-only and not meant for end users to consume.
+// Please use package wgctrl (an abstraction over this package) instead.
+package wgdarwin
@@ -0,0 +1,358 @@
+//go:build darwin
+// +build darwin
+
+package wgdarwin
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/net/route"
+	"golang.org/x/sys/unix"
+)
+
+// Address configuration ioctls which are not provided by package unix.
+const (
+	siocAIFADDRIn6 = 0x8080691a
+	siocDIFADDRIn6 = 0x81206919
+)
+
+// ifAliasReq is struct ifaliasreq.
+type ifAliasReq struct {
+	Name      [unix.IFNAMSIZ]byte
+	Addr      unix.RawSockaddrInet4
+	BroadAddr unix.RawSockaddrInet4
+	Mask      unix.RawSockaddrInet4
+}
+
+// ifReqAddr is struct ifreq, holding an IPv4 address.
+type ifReqAddr struct {
+	Name [unix.IFNAMSIZ]byte
+	Addr unix.RawSockaddrInet4
+}
+
+// in6AliasReq is struct in6_aliasreq.
+type in6AliasReq struct {
+	Name       [unix.IFNAMSIZ]byte
+	Addr       unix.RawSockaddrInet6
+	DstAddr    unix.RawSockaddrInet6
+	PrefixMask unix.RawSockaddrInet6
+	Flags      int32
+	Lifetime   in6AddrLifetime
+}
+
+// in6AddrLifetime is struct in6_addrlifetime.
+type in6AddrLifetime struct {
+	Expire    int64
+	Preferred int64
+	VLTime    uint32
+	PLTime    uint32
+}
+
+// in6IfReq is struct in6_ifreq, holding an IPv6 address.
+type in6IfReq struct {
+	Name [unix.IFNAMSIZ]byte
+	Addr unix.RawSockaddrInet6
+	_    [288 - unix.IFNAMSIZ - unix.SizeofSockaddrInet6]byte
+}
+
+// Fail to compile if the layouts above do not match the sizes encoded in
+// their ioctl numbers.
+var (
+	_ = [1]struct{}{}[unsafe.Sizeof(ifAliasReq{})-(unix.SIOCAIFADDR>>16&0x1fff)]
+	_ = [1]struct{}{}[unsafe.Sizeof(ifReqAddr{})-(unix.SIOCDIFADDR>>16&0x1fff)]
+	_ = [1]struct{}{}[unsafe.Sizeof(in6AliasReq{})-(siocAIFADDRIn6>>16&0x1fff)]
+	_ = [1]struct{}{}[unsafe.Sizeof(in6IfReq{})-(siocDIFADDRIn6>>16&0x1fff)]
+)
+
+// ConfigureInterface configures the utun network interface name. Its MTU is
+// set to mtu if not 0, its addresses other than IPv6 link-local addresses are
+// replaced by addrs, and the static routes through it are replaced by
+// routes.
+func ConfigureInterface(name string, addrs []net.IPNet, mtu int, routes []net.IPNet) error {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return fmt.Errorf("wgdarwin: interface %q: %w", name, os.ErrNotExist)
+	}
+
+	if mtu != 0 {
+		if err := setMTU(name, mtu); err != nil {
+			return fmt.Errorf("wgdarwin: failed to set MTU: %w", err)
+		}
+	}
+
+	if err := setAddresses(ifi, addrs); err != nil {
+		return fmt.Errorf("wgdarwin: failed to set addresses: %w", err)
+	}
+
+	if err := setRoutes(ifi, routes); err != nil {
+		return fmt.Errorf("wgdarwin: failed to set routes: %w", err)
+	}
+
+	return nil
+}
+
+// setMTU sets the MTU of the interface name.
+func setMTU(name string, mtu int) error {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	ifr, err := unix.IoctlGetIfreqMTU(fd, name)
+	if err != nil {
+		return err
+	}
+
+	ifr.MTU = int32(mtu)
+	return unix.IoctlSetIfreqMTU(fd, ifr)
+}
+
+// setAddresses replaces the addresses of ifi with addrs.
+func setAddresses(ifi *net.Interface, addrs []net.IPNet) error {
+	want := make(map[string]net.IPNet)
+	for _, a := range addrs {
+		want[a.String()] = a
+	}
+
+	have, err := ifi.Addrs()
+	if err != nil {
+		return err
+	}
+
+	for _, a := range have {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if _, ok := want[ipn.String()]; ok {
+			delete(want, ipn.String())
+			continue
+		}
+
+		if err := addressIoctl(ifi.Name, *ipn, false); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range addrs {
+		if _, ok := want[a.String()]; !ok {
+			continue
+		}
+
+		if err := addressIoctl(ifi.Name, a, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addressIoctl adds or deletes the address a of the interface name.
+func addressIoctl(name string, a net.IPNet, add bool) error {
+	if ip4 := a.IP.To4(); ip4 != nil {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+		if err != nil {
+			return err
+		}
+		defer unix.Close(fd)
+
+		if !add {
+			var req ifReqAddr
+			copy(req.Name[:], name)
+			req.Addr = sockaddr4(ip4)
+
+			return ioctl(fd, unix.SIOCDIFADDR, unsafe.Pointer(&req))
+		}
+
+		// utun interfaces are point-to-point, so use the address itself as
+		// the destination, as wg-quick(8) does.
+		var req ifAliasReq
+		copy(req.Name[:], name)
+		req.Addr = sockaddr4(ip4)
+		req.BroadAddr = sockaddr4(ip4)
+		req.Mask = sockaddr4(net.IP(a.Mask).To4())
+
+		return ioctl(fd, unix.SIOCAIFADDR, unsafe.Pointer(&req))
+	}
+
+	fd, err := unix.Socket(unix.AF_INET6, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	if !add {
+		var req in6IfReq
+		copy(req.Name[:], name)
+		req.Addr = sockaddr6(a.IP)
+
+		return ioctl(fd, siocDIFADDRIn6, unsafe.Pointer(&req))
+	}
+
+	// ND6_INFINITE_LIFETIME keeps the address valid and preferred.
+	var req in6AliasReq
+	copy(req.Name[:], name)
+	req.Addr = sockaddr6(a.IP)
+	req.PrefixMask = sockaddr6(net.IP(a.Mask))
+	req.Lifetime.VLTime = 0xffffffff
+	req.Lifetime.PLTime = 0xffffffff
+
+	return ioctl(fd, siocAIFADDRIn6, unsafe.Pointer(&req))
+}
+
+// setRoutes replaces the static routes through ifi with routes.
+func setRoutes(ifi *net.Interface, routes []net.IPNet) error {
+	want := make(map[string]net.IPNet)
+	for _, r := range routes {
+		r = net.IPNet{IP: r.IP.Mask(r.Mask), Mask: r.Mask}
+		want[r.String()] = r
+	}
+
+	rib, err := route.FetchRIB(unix.AF_UNSPEC, route.RIBTypeRoute, 0)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := route.ParseRIB(route.RIBTypeRoute, rib)
+	if err != nil {
+		return err
+	}
+
+	fd, err := unix.Socket(unix.AF_ROUTE, unix.SOCK_RAW, unix.AF_UNSPEC)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+
+	var seq int
+	for _, m := range msgs {
+		rm, ok := m.(*route.RouteMessage)
+		if !ok || rm.Index != ifi.Index || rm.Flags&unix.RTF_STATIC == 0 {
+			continue
+		}
+
+		dst, ok := routeDestination(rm)
+		if !ok {
+			continue
+		}
+		if _, ok := want[dst.String()]; ok {
+			delete(want, dst.String())
+			continue
+		}
+
+		seq++
+		if err := writeRoute(fd, ifi, unix.RTM_DELETE, seq, dst); err != nil && !errors.Is(err, unix.ESRCH) {
+			return err
+		}
+	}
+
+	for _, dst := range want {
+		seq++
+		if err := writeRoute(fd, ifi, unix.RTM_ADD, seq, dst); err != nil && !errors.Is(err, unix.EEXIST) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// routeDestination returns the destination of the route rm.
+func routeDestination(rm *route.RouteMessage) (net.IPNet, bool) {
+	if len(rm.Addrs) <= unix.RTAX_NETMASK {
+		return net.IPNet{}, false
+	}
+
+	switch dst := rm.Addrs[unix.RTAX_DST].(type) {
+	case *route.Inet4Addr:
+		mask := net.CIDRMask(32, 32)
+		if m, ok := rm.Addrs[unix.RTAX_NETMASK].(*route.Inet4Addr); ok {
+			mask = net.IPv4Mask(m.IP[0], m.IP[1], m.IP[2], m.IP[3])
+		}
+
+		return net.IPNet{IP: net.IP(dst.IP[:]).Mask(mask), Mask: mask}, true
+	case *route.Inet6Addr:
+		mask := net.CIDRMask(128, 128)
+		if m, ok := rm.Addrs[unix.RTAX_NETMASK].(*route.Inet6Addr); ok {
+			mask = net.IPMask(m.IP[:])
+		}
+
+		return net.IPNet{IP: net.IP(dst.IP[:]).Mask(mask), Mask: mask}, true
+	default:
+		return net.IPNet{}, false
+	}
+}
+
+// writeRoute sends a route message of type typ for the route to dst through
+// ifi to the route socket fd.
+func writeRoute(fd int, ifi *net.Interface, typ, seq int, dst net.IPNet) error {
+	var daddr, mask route.Addr
+	if ip4 := dst.IP.To4(); ip4 != nil {
+		d := &route.Inet4Addr{}
+		copy(d.IP[:], ip4)
+		m := &route.Inet4Addr{}
+		copy(m.IP[:], dst.Mask)
+		daddr, mask = d, m
+	} else {
+		d := &route.Inet6Addr{}
+		copy(d.IP[:], dst.IP.To16())
+		m := &route.Inet6Addr{}
+		copy(m.IP[:], dst.Mask)
+		daddr, mask = d, m
+	}
+
+	flags := unix.RTF_UP | unix.RTF_STATIC
+	if ones, bits := dst.Mask.Size(); ones == bits {
+		flags |= unix.RTF_HOST
+	}
+
+	m := &route.RouteMessage{
+		Version: unix.RTM_VERSION,
+		Type:    typ,
+		Flags:   flags,
+		Index:   ifi.Index,
+		ID:      uintptr(os.Getpid()),
+		Seq:     seq,
+		Addrs: []route.Addr{
+			unix.RTAX_DST:     daddr,
+			unix.RTAX_GATEWAY: &route.LinkAddr{Index: ifi.Index, Name: ifi.Name},
+			unix.RTAX_NETMASK: mask,
+		},
+	}
+
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	_, err = unix.Write(fd, b)
+	return err
+}
+
+// sockaddr4 returns the IPv4 address ip as a socket address.
+func sockaddr4(ip net.IP) unix.RawSockaddrInet4 {
+	sa := unix.RawSockaddrInet4{Len: unix.SizeofSockaddrInet4, Family: unix.AF_INET}
+	copy(sa.Addr[:], ip.To4())
+	return sa
+}
+
+// sockaddr6 returns the IPv6 address ip as a socket address.
+func sockaddr6(ip net.IP) unix.RawSockaddrInet6 {
+	sa := unix.RawSockaddrInet6{Len: unix.SizeofSockaddrInet6, Family: unix.AF_INET6}
+	copy(sa.Addr[:], ip.To16())
+	return sa
+}
+
+// ioctl performs the ioctl req with the argument arg on fd.
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
@@ -36,6 +36,9 @@ type Client struct {
 	close           func() error
 	ioctlIfgroupreq func(*wgh.Ifgroupreq) error
 	ioctlWGDataIO   func(uint, *wgh.WGDataIO) error
+
+	// observe is an optional ParseObserver set by SetParseObserver.
+	observe wginternal.ParseObserver
 }
 
 // New creates a new Client and returns whether or not the ioctl interface
@@ -154,7 +157,11 @@ func (c *Client) Device(name string) (*wgtypes.Device, error) {
 		data.Data = &mem[0]
 	}
 
+	start := time.Now()
 	dev, err := parseDevice(mem)
+	if c.observe != nil {
+		c.observe(len(mem), time.Since(start))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -164,6 +171,12 @@ func (c *Client) Device(name string) (*wgtypes.Device, error) {
 	return dev, nil
 }
 
+// SetParseObserver sets a function which is called each time the Client
+// parses a device from the kernel's name-value list.
+func (c *Client) SetParseObserver(fn wginternal.ParseObserver) {
+	c.observe = fn
+}
+
 // ConfigureDevice implements wginternal.Client.
 func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
 	// Check if there is a peer with the UpdateOnly flag set.
@@ -179,7 +192,11 @@ func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
 		}
 	}
 
-	m := unparseConfig(cfg)
+	m, err := unparseConfig(cfg)
+	if err != nil {
+		return err
+	}
+
 	mem, sz, err := nv.Marshal(m)
 	if err != nil {
 		return err
@@ -285,10 +302,10 @@ func parseEndpoint(ep []byte) *net.UDPAddr {
 	case unix.AF_INET6:
 		sa := (*unix.RawSockaddrInet6)(unsafe.Pointer(&ep[0]))
 
-		// TODO(mdlayher): IPv6 zone?
 		ep := &net.UDPAddr{
 			IP:   make(net.IP, net.IPv6len),
 			Port: ntohs(sa.Port),
+			Zone: wginternal.ZoneName(sa.Scope_id),
 		}
 		copy(ep.IP, sa.Addr[:])
 
@@ -299,7 +316,7 @@ func parseEndpoint(ep []byte) *net.UDPAddr {
 	}
 }
 
-func unparseEndpoint(ep net.UDPAddr) []byte {
+func unparseEndpoint(ep net.UDPAddr) ([]byte, error) {
 	var b []byte
 
 	if v4 := ep.IP.To4(); v4 != nil {
@@ -313,12 +330,19 @@ func unparseEndpoint(ep net.UDPAddr) []byte {
 		b = make([]byte, unsafe.Sizeof(unix.RawSockaddrInet6{}))
 		sa := (*unix.RawSockaddrInet6)(unsafe.Pointer(&b[0]))
 
+		// Link-local endpoints must carry their zone as a scope ID.
+		scope, err := wginternal.ZoneIndex(ep.Zone)
+		if err != nil {
+			return nil, fmt.Errorf("wgfreebsd: invalid endpoint: %v", err)
+		}
+
 		sa.Family = unix.AF_INET6
 		sa.Port = htons(ep.Port)
+		sa.Scope_id = scope
 		copy(sa.Addr[:], v6)
 	}
 
-	return b
+	return b, nil
 }
 
 // parseAllowedIP unpacks a net.IPNet from a WGAIP structure.
@@ -386,6 +410,7 @@ func parsePeer(v nv.List) wgtypes.Peer {
 	if v, ok := v["preshared-key"]; ok {
 		psk := (*wgtypes.Key)(v.([]byte))
 		p.PresharedKey = *psk
+		p.HasPresharedKey = p.PresharedKey != wgtypes.Key{}
 	}
 
 	if v, ok := v["last-handshake-time"]; ok {
@@ -459,7 +484,7 @@ func parseDevice(data []byte) (*wgtypes.Device, error) {
 }
 
 // unparsePeerConfig encodes a PeerConfig to a name-value list (nvlist).
-func unparsePeerConfig(cfg wgtypes.PeerConfig) nv.List {
+func unparsePeerConfig(cfg wgtypes.PeerConfig) (nv.List, error) {
 	m := nv.List{}
 
 	m["public-key"] = cfg.PublicKey[:]
@@ -473,7 +498,12 @@ func unparsePeerConfig(cfg wgtypes.PeerConfig) nv.List {
 	}
 
 	if v := cfg.Endpoint; v != nil {
-		m["endpoint"] = unparseEndpoint(*v)
+		ep, err := unparseEndpoint(*v)
+		if err != nil {
+			return nil, err
+		}
+
+		m["endpoint"] = ep
 	}
 
 	if cfg.ReplaceAllowedIPs {
@@ -494,11 +524,11 @@ func unparsePeerConfig(cfg wgtypes.PeerConfig) nv.List {
 		m["allowed-ips"] = aips
 	}
 
-	return m
+	return m, nil
 }
 
 // unparseDevice encodes the device configuration as a FreeBSD name-value list (nvlist).
-func unparseConfig(cfg wgtypes.Config) nv.List {
+func unparseConfig(cfg wgtypes.Config) (nv.List, error) {
 	m := nv.List{}
 
 	if v := cfg.PrivateKey; v != nil {
@@ -521,12 +551,16 @@ func unparseConfig(cfg wgtypes.Config) nv.List {
 		peers := []nv.List{}
 
 		for _, p := range v {
-			peer := unparsePeerConfig(p)
+			peer, err := unparsePeerConfig(p)
+			if err != nil {
+				return nil, err
+			}
+
 			peers = append(peers, peer)
 		}
 
 		m["peers"] = peers
 	}
 
-	return m
+	return m, nil
 }
@@ -0,0 +1,106 @@
+package wginternal
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// A Watcher reports whether a set of resources, such as the contents of a
+// directory, may have changed.
+type Watcher interface {
+	// Changed reports whether the resources may have changed since the
+	// previous call, without blocking. If Changed returns an error, the
+	// Watcher is no longer usable and is closed.
+	Changed() (bool, error)
+
+	// Close releases the Watcher's resources.
+	Close() error
+}
+
+// A ListCache caches the result of a function which lists resources, such as
+// the WireGuard devices on a system, until a Watcher reports that they may
+// have changed. A ListCache is safe for concurrent use.
+type ListCache struct {
+	list  func() ([]string, error)
+	watch func() (Watcher, error)
+
+	mu    sync.Mutex
+	w     Watcher
+	items []string
+	valid bool
+}
+
+// NewListCache creates a ListCache which caches the result of list, using the
+// Watcher returned by watch to invalidate its contents. If watch returns an
+// error which can be checked using errors.Is(err, os.ErrNotExist), such as
+// when a directory does not yet exist, the result of list is not cached until
+// a Watcher can be created.
+func NewListCache(list func() ([]string, error), watch func() (Watcher, error)) *ListCache {
+	return &ListCache{
+		list:  list,
+		watch: watch,
+	}
+}
+
+// List returns the cached result of list, calling it again if the resources
+// may have changed.
+func (lc *ListCache) List() ([]string, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.w != nil {
+		changed, err := lc.w.Changed()
+		if err != nil {
+			// The Watcher is unusable, so try to create a new one.
+			_ = lc.w.Close()
+			lc.w = nil
+		}
+		if changed || err != nil {
+			lc.valid = false
+		}
+	}
+
+	if lc.w == nil {
+		// The Watcher must be created before listing resources, so that
+		// no changes are missed.
+		w, err := lc.watch()
+		switch {
+		case err == nil:
+			lc.w = w
+		case errors.Is(err, os.ErrNotExist):
+			return lc.list()
+		default:
+			return nil, err
+		}
+	}
+
+	if !lc.valid {
+		items, err := lc.list()
+		if err != nil {
+			return nil, err
+		}
+
+		lc.items, lc.valid = items, true
+	}
+
+	// Callers may modify the returned slice.
+	out := make([]string, len(lc.items))
+	copy(out, lc.items)
+	return out, nil
+}
+
+// Close closes the ListCache's Watcher, if any.
+func (lc *ListCache) Close() error {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	if lc.w == nil {
+		return nil
+	}
+
+	err := lc.w.Close()
+	lc.w = nil
+	lc.valid = false
+	return err
+}
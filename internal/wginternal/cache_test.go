@@ -0,0 +1,102 @@
+package wginternal_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+func TestListCache(t *testing.T) {
+	var (
+		lists int
+		w     = &testWatcher{}
+	)
+
+	lc := wginternal.NewListCache(
+		func() ([]string, error) {
+			lists++
+			return []string{"wg0"}, nil
+		},
+		func() (wginternal.Watcher, error) {
+			return w, nil
+		},
+	)
+
+	list := func() {
+		t.Helper()
+
+		items, err := lc.List()
+		if err != nil {
+			t.Fatalf("failed to list: %v", err)
+		}
+
+		if diff := cmp.Diff([]string{"wg0"}, items); diff != "" {
+			t.Fatalf("unexpected items (-want +got):\n%s", diff)
+		}
+	}
+
+	// The list is cached until the Watcher reports a change, and is
+	// recreated if the Watcher fails.
+	list()
+	list()
+	w.changed = true
+	list()
+	w.changed = false
+	list()
+	w.err = errors.New("watcher failed")
+	list()
+	w.err = nil
+	list()
+
+	if diff := cmp.Diff(3, lists); diff != "" {
+		t.Fatalf("unexpected number of list calls (-want +got):\n%s", diff)
+	}
+
+	if err := lc.Close(); err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	if diff := cmp.Diff(2, w.closes); diff != "" {
+		t.Fatalf("unexpected number of Watcher closes (-want +got):\n%s", diff)
+	}
+}
+
+func TestListCacheNotExist(t *testing.T) {
+	var lists int
+	lc := wginternal.NewListCache(
+		func() ([]string, error) {
+			lists++
+			return nil, nil
+		},
+		func() (wginternal.Watcher, error) {
+			return nil, os.ErrNotExist
+		},
+	)
+
+	// Without a Watcher, nothing is cached.
+	for i := 0; i < 3; i++ {
+		if _, err := lc.List(); err != nil {
+			t.Fatalf("failed to list: %v", err)
+		}
+	}
+
+	if diff := cmp.Diff(3, lists); diff != "" {
+		t.Fatalf("unexpected number of list calls (-want +got):\n%s", diff)
+	}
+}
+
+type testWatcher struct {
+	changed bool
+	err     error
+	closes  int
+}
+
+func (w *testWatcher) Changed() (bool, error) { return w.changed, w.err }
+
+func (w *testWatcher) Close() error {
+	w.closes++
+	return nil
+}
@@ -3,6 +3,7 @@ package wginternal
 import (
 	"errors"
 	"io"
+	"time"
 
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
@@ -19,3 +20,7 @@ type Client interface {
 	Device(name string) (*wgtypes.Device, error)
 	ConfigureDevice(name string, cfg wgtypes.Config) error
 }
+
+// A ParseObserver is called by a Client after it parses n bytes of device
+// information from its backend, which took duration d.
+type ParseObserver func(n int, d time.Duration)
@@ -0,0 +1,86 @@
+package wginternal
+
+import (
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A FieldMask selects the fields of a Device which a Client retrieves.
+type FieldMask uint
+
+// Possible FieldMask values.
+const (
+	// FieldDevice selects the fields of a Device other than its Name, Type,
+	// and Peers.
+	FieldDevice FieldMask = 1 << iota
+
+	// FieldPeers selects the configuration of each Peer: its preshared key,
+	// whether it has a preshared key, its endpoint, persistent keepalive
+	// interval, and protocol version.
+	FieldPeers
+
+	// FieldCounters selects the last handshake time and the transmit and
+	// receive byte counters of each Peer.
+	FieldCounters
+
+	// FieldAllowedIPs selects the allowed IPs of each Peer.
+	FieldAllowedIPs
+
+	// FieldSecrets selects the PrivateKey of a Device and the PresharedKey of
+	// each Peer, if FieldDevice and FieldPeers are also selected.
+	FieldSecrets
+
+	// FieldAll selects all fields.
+	FieldAll = FieldDevice | FieldPeers | FieldCounters | FieldAllowedIPs | FieldSecrets
+)
+
+// Peers reports whether m selects any fields of a Device's Peers. If not, no
+// Peers are retrieved. Otherwise, each Peer's PublicKey is always retrieved.
+func (m FieldMask) Peers() bool {
+	return m&(FieldPeers|FieldCounters|FieldAllowedIPs) != 0
+}
+
+// Apply clears the fields of d which are not selected by m, for use by a
+// Client which cannot avoid retrieving them.
+func (m FieldMask) Apply(d *wgtypes.Device) {
+	if m&FieldDevice == 0 {
+		d.PublicKey = wgtypes.Key{}
+		d.ListenPort = 0
+		d.FirewallMark = 0
+	}
+	if m&FieldDevice == 0 || m&FieldSecrets == 0 {
+		d.PrivateKey = wgtypes.Key{}
+	}
+
+	if !m.Peers() {
+		d.Peers = nil
+		return
+	}
+
+	for i := range d.Peers {
+		p := &d.Peers[i]
+
+		p.HasPresharedKey = p.HasPresharedKey || p.PresharedKey != wgtypes.Key{}
+
+		if m&FieldPeers == 0 {
+			p.HasPresharedKey = false
+			p.Endpoint = nil
+			p.PersistentKeepaliveInterval = 0
+			p.ProtocolVersion = 0
+		}
+		if m&FieldPeers == 0 || m&FieldSecrets == 0 {
+			p.PresharedKey = wgtypes.Key{}
+		}
+
+		if m&FieldCounters == 0 {
+			p.LastHandshakeTime = time.Time{}
+			p.ReceiveBytes = 0
+			p.TransmitBytes = 0
+		}
+
+		if m&FieldAllowedIPs == 0 {
+			p.AllowedIPs = nil
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package wginternal
+
+import "time"
+
+// A DumpLimit identifies a limit on the retrieval of a device.
+type DumpLimit int
+
+// Possible DumpLimit values.
+const (
+	_ DumpLimit = iota
+	LimitPeers
+	LimitBytes
+	LimitDeadline
+)
+
+// String returns the string representation of a DumpLimit.
+func (l DumpLimit) String() string {
+	switch l {
+	case LimitPeers:
+		return "too many peers"
+	case LimitBytes:
+		return "too many bytes"
+	case LimitDeadline:
+		return "deadline exceeded"
+	default:
+		return "unknown limit"
+	}
+}
+
+// DumpLimits bounds the retrieval of a device. Each zero field is unlimited.
+type DumpLimits struct {
+	MaxPeers int
+	MaxBytes int
+	Deadline time.Time
+}
+
+// A TruncatedError is returned by a Client which stopped retrieving a device
+// because it exceeded a DumpLimit. The device passed to the Client contains
+// whatever was retrieved before the limit was exceeded.
+type TruncatedError struct {
+	Limit DumpLimit
+}
+
+// Error implements error.
+func (e *TruncatedError) Error() string { return "device retrieval truncated: " + e.Limit.String() }
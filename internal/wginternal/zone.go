@@ -0,0 +1,42 @@
+package wginternal
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// ZoneIndex returns the interface index used as the sockaddr_in6 scope ID for
+// an IPv6 zone, which may be either an interface name or a numeric index, as
+// in "fe80::1%eth0" or "fe80::1%2". An empty zone produces an index of 0.
+func ZoneIndex(zone string) (uint32, error) {
+	if zone == "" {
+		return 0, nil
+	}
+
+	if n, err := strconv.ParseUint(zone, 10, 32); err == nil {
+		return uint32(n), nil
+	}
+
+	ifi, err := net.InterfaceByName(zone)
+	if err != nil {
+		return 0, fmt.Errorf("invalid IPv6 zone %q: %v", zone, err)
+	}
+
+	return uint32(ifi.Index), nil
+}
+
+// ZoneName returns the IPv6 zone for a sockaddr_in6 scope ID. The interface's
+// name is used when it can be resolved, and the numeric index otherwise. An
+// index of 0 produces an empty zone.
+func ZoneName(index uint32) string {
+	if index == 0 {
+		return ""
+	}
+
+	if ifi, err := net.InterfaceByIndex(int(index)); err == nil {
+		return ifi.Name
+	}
+
+	return strconv.FormatUint(uint64(index), 10)
+}
@@ -0,0 +1,75 @@
+package wginternal_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+func TestZone(t *testing.T) {
+	ifis, err := net.Interfaces()
+	if err != nil {
+		t.Skipf("skipping, failed to list interfaces: %v", err)
+	}
+	if len(ifis) == 0 {
+		t.Skip("skipping, no network interfaces")
+	}
+	ifi := ifis[0]
+
+	tests := []struct {
+		name  string
+		zone  string
+		index uint32
+		ok    bool
+	}{
+		{
+			name: "empty",
+			ok:   true,
+		},
+		{
+			name:  "numeric",
+			zone:  "2",
+			index: 2,
+			ok:    true,
+		},
+		{
+			name:  "name",
+			zone:  ifi.Name,
+			index: uint32(ifi.Index),
+			ok:    true,
+		},
+		{
+			name: "unknown",
+			zone: "wgnotexist0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, err := wginternal.ZoneIndex(tt.zone)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to get zone index: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if err != nil {
+				t.Logf("OK error: %v", err)
+				return
+			}
+
+			if diff := cmp.Diff(tt.index, index); diff != "" {
+				t.Fatalf("unexpected zone index (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if diff := cmp.Diff(ifi.Name, wginternal.ZoneName(uint32(ifi.Index))); diff != "" {
+		t.Fatalf("unexpected zone name (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff("", wginternal.ZoneName(0)); diff != "" {
+		t.Fatalf("unexpected empty zone name (-want +got):\n%s", diff)
+	}
+}
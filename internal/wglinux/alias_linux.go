@@ -0,0 +1,131 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+)
+
+// InterfaceAlias returns the alias (IFLA_IFALIAS) of the interface name, or
+// the empty string if it has none.
+func InterfaceAlias(name string) (string, error) {
+	c, index, err := dialRoute(name)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	return interfaceAlias(c, index)
+}
+
+// SetInterfaceAlias sets the alias (IFLA_IFALIAS) of the interface name. An
+// empty alias removes it.
+func SetInterfaceAlias(name, alias string) error {
+	c, index, err := dialRoute(name)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return setInterfaceAlias(c, index, alias)
+}
+
+// dialRoute opens an rtnetlink connection and looks up the index of the
+// interface name.
+func dialRoute(name string) (*netlink.Conn, int, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, 0, os.ErrNotExist
+	}
+
+	c, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return c, ifi.Index, nil
+}
+
+// ifAliasSize is IFALIASZ, the size of the kernel's alias buffer including
+// its NUL terminator.
+const ifAliasSize = 256
+
+// interfaceAlias implements InterfaceAlias for the interface index using c.
+func interfaceAlias(c *netlink.Conn, index int) (string, error) {
+	msgs, err := c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_GETLINK,
+			Flags: netlink.Request,
+		},
+		Data: ifInfomsg(index),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWLINK {
+			continue
+		}
+		if len(m.Data) < unix.SizeofIfInfomsg {
+			return "", fmt.Errorf("wglinux: rtnetlink message is too short for ifinfomsg: %d", len(m.Data))
+		}
+
+		ad, err := netlink.NewAttributeDecoder(m.Data[unix.SizeofIfInfomsg:])
+		if err != nil {
+			return "", err
+		}
+
+		var alias string
+		for ad.Next() {
+			if ad.Type() == unix.IFLA_IFALIAS {
+				alias = ad.String()
+			}
+		}
+
+		return alias, ad.Err()
+	}
+
+	return "", os.ErrNotExist
+}
+
+// setInterfaceAlias implements SetInterfaceAlias for the interface index
+// using c.
+func setInterfaceAlias(c *netlink.Conn, index int, alias string) error {
+	if len(alias) >= ifAliasSize {
+		return fmt.Errorf("wglinux: interface alias must be shorter than %d bytes", ifAliasSize)
+	}
+
+	// The alias is not NUL-terminated, so that an empty alias removes it.
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(unix.IFLA_IFALIAS, []byte(alias))
+
+	attrs, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_SETLINK,
+			Flags: netlink.Request | netlink.Acknowledge,
+		},
+		Data: append(ifInfomsg(index), attrs...),
+	})
+	return err
+}
+
+// ifInfomsg produces a struct ifinfomsg for the interface index.
+func ifInfomsg(index int) []byte {
+	b := make([]byte, unix.SizeofIfInfomsg)
+	b[0] = unix.AF_UNSPEC
+	nlenc.PutUint32(b[4:8], uint32(index))
+	return b
+}
@@ -0,0 +1,67 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+func Test_interfaceAlias(t *testing.T) {
+	c := nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+		if diff := cmp.Diff(ifInfomsg(3), req[0].Data); diff != "" {
+			t.Fatalf("unexpected request (-want +got):\n%s", diff)
+		}
+
+		return []netlink.Message{{
+			Header: netlink.Header{Type: unix.RTM_NEWLINK, Sequence: req[0].Header.Sequence},
+			Data: append(ifInfomsg(3), nltest.MustMarshalAttributes([]netlink.Attribute{
+				{Type: unix.IFLA_IFNAME, Data: nlenc.Bytes("wg0")},
+				{Type: unix.IFLA_IFALIAS, Data: []byte("office VPN")},
+			})...),
+		}}, nil
+	})
+	defer c.Close()
+
+	alias, err := interfaceAlias(c, 3)
+	if err != nil {
+		t.Fatalf("failed to get alias: %v", err)
+	}
+
+	if diff := cmp.Diff("office VPN", alias); diff != "" {
+		t.Fatalf("unexpected alias (-want +got):\n%s", diff)
+	}
+}
+
+func Test_setInterfaceAlias(t *testing.T) {
+	for _, alias := range []string{"office VPN", ""} {
+		t.Run(alias, func(t *testing.T) {
+			c := nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+				want := append(ifInfomsg(3), nltest.MustMarshalAttributes([]netlink.Attribute{
+					{Type: unix.IFLA_IFALIAS, Data: []byte(alias)},
+				})...)
+
+				if diff := cmp.Diff(want, req[0].Data); diff != "" {
+					t.Fatalf("unexpected request (-want +got):\n%s", diff)
+				}
+
+				// Acknowledge the request.
+				return []netlink.Message{{
+					Header: netlink.Header{Type: netlink.Error, Sequence: req[0].Header.Sequence},
+					Data:   make([]byte, 4),
+				}}, nil
+			})
+			defer c.Close()
+
+			if err := setInterfaceAlias(c, 3, alias); err != nil {
+				t.Fatalf("failed to set alias: %v", err)
+			}
+		})
+	}
+}
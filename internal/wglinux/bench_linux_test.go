@@ -0,0 +1,200 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/genetlink"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// benchPeers are the numbers of peers used by benchmarks.
+var benchPeers = []int{1000, 10000, 100000}
+
+func BenchmarkParseDevice(b *testing.B) {
+	for _, n := range benchPeers {
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			msgs := syntheticMessages(n)
+
+			var size int64
+			for _, m := range msgs {
+				size += int64(len(m.Data))
+			}
+
+			b.SetBytes(size)
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := parseDevice(msgs); err != nil {
+					b.Fatalf("failed to parse device: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConfigAttrs(b *testing.B) {
+	for _, n := range benchPeers {
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			cfg := wgtest.SyntheticConfig(n)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				for _, batch := range buildBatches(cfg) {
+					if _, err := configAttrs("wg0", batch); err != nil {
+						b.Fatalf("failed to encode attributes: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Allocation budgets per peer for the hot paths measured by benchmarks. If a
+// change exceeds a budget, either the regression should be fixed or the budget
+// raised deliberately.
+const (
+	parseAllocsPerPeer  = 44
+	encodeAllocsPerPeer = 46
+)
+
+func TestLinuxAllocationBudgets(t *testing.T) {
+	const n = 1000
+
+	var (
+		msgs = syntheticMessages(n)
+		cfg  = wgtest.SyntheticConfig(n)
+	)
+
+	// Verify the synthetic messages before measuring them.
+	d, err := parseDevice(msgs)
+	if err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+	if len(d.Peers) != n {
+		t.Fatalf("expected %d peers, but got: %d", n, len(d.Peers))
+	}
+
+	parse := testing.AllocsPerRun(5, func() {
+		if _, err := parseDevice(msgs); err != nil {
+			panicf("failed to parse device: %v", err)
+		}
+	})
+
+	encode := testing.AllocsPerRun(5, func() {
+		for _, batch := range buildBatches(cfg) {
+			if _, err := configAttrs("wg0", batch); err != nil {
+				panicf("failed to encode attributes: %v", err)
+			}
+		}
+	})
+
+	t.Logf("allocations per peer: parse: %.1f, encode: %.1f", parse/n, encode/n)
+
+	if max := float64(parseAllocsPerPeer * n); parse > max {
+		t.Errorf("parse allocations exceed budget: %.0f > %.0f", parse, max)
+	}
+	if max := float64(encodeAllocsPerPeer * n); encode > max {
+		t.Errorf("encode allocations exceed budget: %.0f > %.0f", encode, max)
+	}
+}
+
+// syntheticMessages produces the messages of a netlink dump of a device with
+// n peers. The attributes used to configure a device are a superset of those
+// returned by the kernel, so the configuration batches stand in for the
+// kernel's paginated dump.
+func syntheticMessages(n int) []genetlink.Message {
+	var msgs []genetlink.Message
+	for _, batch := range buildBatches(wgtest.SyntheticConfig(n)) {
+		b, err := configAttrs("wg0", batch)
+		if err != nil {
+			panicf("failed to encode attributes: %v", err)
+		}
+
+		msgs = append(msgs, genetlink.Message{Data: b})
+	}
+
+	return msgs
+}
+
+func BenchmarkParseDeviceInto(b *testing.B) {
+	for _, n := range benchPeers {
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			msgs := syntheticMessages(n)
+
+			var d wgtypes.Device
+			if err := parseDeviceInto(msgs, &d, wginternal.FieldAll); err != nil {
+				b.Fatalf("failed to parse device: %v", err)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if err := parseDeviceInto(msgs, &d, wginternal.FieldAll); err != nil {
+					b.Fatalf("failed to parse device: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLinux_parseDeviceIntoReuse(t *testing.T) {
+	const n = 100
+
+	var (
+		msgs  = syntheticMessages(n)
+		small = syntheticMessages(n / 2)
+	)
+
+	want, err := parseDevice(msgs)
+	if err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+
+	// Parse a smaller and then a larger device into the same Device, as
+	// would happen if peers were added between polls.
+	var d wgtypes.Device
+	for _, m := range [][]genetlink.Message{small, msgs} {
+		if err := parseDeviceInto(m, &d, wginternal.FieldAll); err != nil {
+			t.Fatalf("failed to parse device: %v", err)
+		}
+	}
+
+	if diff := cmp.Diff(want, &d); diff != "" {
+		t.Fatalf("unexpected device (-want +got):\n%s", diff)
+	}
+
+	// Subsequent parsing of the same device should reuse peer storage, and
+	// allocate less than parsing into a new Device. Netlink attribute
+	// decoding still allocates for each peer.
+	peers := &d.Peers[0]
+	into := testing.AllocsPerRun(5, func() {
+		if err := parseDeviceInto(msgs, &d, wginternal.FieldAll); err != nil {
+			panicf("failed to parse device: %v", err)
+		}
+	})
+
+	if peers != &d.Peers[0] {
+		t.Fatal("peers were not reused")
+	}
+
+	fresh := testing.AllocsPerRun(5, func() {
+		if _, err := parseDevice(msgs); err != nil {
+			panicf("failed to parse device: %v", err)
+		}
+	})
+
+	if into >= fresh {
+		t.Fatalf("expected fewer than %.0f allocations, but got: %.0f", fresh, into)
+	}
+}
@@ -0,0 +1,78 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+// CacheDiscovery arranges for the Client to cache the list of WireGuard
+// interfaces, fetching it again only when rtnetlink reports that a link has
+// been added, removed, or changed.
+func (c *Client) CacheDiscovery() error {
+	if c.cache != nil {
+		return nil
+	}
+
+	c.cache = wginternal.NewListCache(c.interfaces, watchLinks)
+	c.interfaces = c.cache.List
+	return nil
+}
+
+// An rtnlWatcher is a wginternal.Watcher which receives rtnetlink link
+// notifications.
+type rtnlWatcher struct {
+	fd  int
+	buf []byte
+}
+
+// watchLinks creates a wginternal.Watcher for rtnetlink link notifications.
+func watchLinks() (wginternal.Watcher, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	sa := &unix.SockaddrNetlink{
+		Family: unix.AF_NETLINK,
+		Groups: unix.RTMGRP_LINK,
+	}
+
+	if err := unix.Bind(fd, sa); err != nil {
+		_ = unix.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+
+	return &rtnlWatcher{
+		fd:  fd,
+		buf: make([]byte, os.Getpagesize()),
+	}, nil
+}
+
+// Changed implements wginternal.Watcher.
+func (w *rtnlWatcher) Changed() (bool, error) {
+	var changed bool
+	for {
+		_, _, err := unix.Recvfrom(w.fd, w.buf, 0)
+		switch err {
+		case nil, unix.ENOBUFS:
+			// Either a notification was received, or notifications were
+			// dropped because the socket's buffer overflowed.
+			changed = true
+		case unix.EAGAIN:
+			return changed, nil
+		case unix.EINTR:
+		default:
+			return true, os.NewSyscallError("recvfrom", err)
+		}
+	}
+}
+
+// Close implements wginternal.Watcher.
+func (w *rtnlWatcher) Close() error {
+	return unix.Close(w.fd)
+}
@@ -0,0 +1,41 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLinux_ClientCacheDiscovery(t *testing.T) {
+	var calls int
+	c := &Client{
+		interfaces: func() ([]string, error) {
+			calls++
+			return []string{"wg0"}, nil
+		},
+	}
+
+	if err := c.CacheDiscovery(); err != nil {
+		t.Fatalf("failed to cache discovery: %v", err)
+	}
+	defer c.cache.Close()
+
+	// Without any link changes, the interfaces are only listed once.
+	for i := 0; i < 3; i++ {
+		ifis, err := c.interfaces()
+		if err != nil {
+			t.Fatalf("failed to list interfaces: %v", err)
+		}
+
+		if diff := cmp.Diff([]string{"wg0"}, ifis); diff != "" {
+			t.Fatalf("unexpected interfaces (-want +got):\n%s", diff)
+		}
+	}
+
+	if diff := cmp.Diff(1, calls); diff != "" {
+		t.Fatalf("unexpected number of interface listings (-want +got):\n%s", diff)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"os"
 	"syscall"
+	"time"
 
 	"github.com/mdlayher/genetlink"
 	"github.com/mdlayher/netlink"
@@ -24,17 +25,39 @@ type Client struct {
 	c      *genetlink.Conn
 	family genetlink.Family
 
+	// rtnl is an optional long-lived rtnetlink socket opened by Preopen.
+	rtnl *netlink.Conn
+
 	interfaces func() ([]string, error)
+
+	// dial opens the additional sockets used for limited dumps.
+	dial func() (*genetlink.Conn, error)
+
+	// cache caches the result of interfaces, if set.
+	cache *wginternal.ListCache
+
+	// observe is an optional ParseObserver set by SetParseObserver.
+	observe wginternal.ParseObserver
 }
 
 // New creates a new Client and returns whether or not the generic netlink
 // interface is available.
 func New() (*Client, bool, error) {
-	c, err := genetlink.Dial(nil)
+	c, err := dial()
 	if err != nil {
 		return nil, false, err
 	}
 
+	return initClient(c)
+}
+
+// dial opens a generic netlink socket.
+func dial() (*genetlink.Conn, error) {
+	c, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, err
+	}
+
 	// Best effort version of netlink.Config.Strict due to CentOS 7.
 	for _, o := range []netlink.ConnOption{
 		netlink.ExtendedAcknowledge,
@@ -43,7 +66,7 @@ func New() (*Client, bool, error) {
 		_ = c.SetOption(o, true)
 	}
 
-	return initClient(c)
+	return c, nil
 }
 
 // initClient is the internal Client constructor used in some tests.
@@ -66,16 +89,30 @@ func initClient(c *genetlink.Conn) (*Client, bool, error) {
 
 		// By default, gather only WireGuard interfaces using rtnetlink.
 		interfaces: rtnlInterfaces,
+		dial:       dial,
 	}, true, nil
 }
 
 // Close implements wginternal.Client.
 func (c *Client) Close() error {
+	if c.rtnl != nil {
+		_ = c.rtnl.Close()
+	}
+	if c.cache != nil {
+		_ = c.cache.Close()
+	}
+
 	return c.c.Close()
 }
 
 // Devices implements wginternal.Client.
 func (c *Client) Devices() ([]*wgtypes.Device, error) {
+	return c.DevicesFields(wginternal.FieldAll)
+}
+
+// DevicesFields is like Devices, but only parses the fields of each device
+// selected by mask.
+func (c *Client) DevicesFields(mask wginternal.FieldMask) ([]*wgtypes.Device, error) {
 	// By default, rtnetlink is used to fetch a list of all interfaces and then
 	// filter that list to only find WireGuard interfaces.
 	//
@@ -88,12 +125,12 @@ func (c *Client) Devices() ([]*wgtypes.Device, error) {
 
 	ds := make([]*wgtypes.Device, 0, len(ifis))
 	for _, ifi := range ifis {
-		d, err := c.Device(ifi)
-		if err != nil {
+		var d wgtypes.Device
+		if err := c.DeviceFieldsInto(ifi, &d, mask); err != nil {
 			return nil, err
 		}
 
-		ds = append(ds, d)
+		ds = append(ds, &d)
 	}
 
 	return ds, nil
@@ -101,9 +138,27 @@ func (c *Client) Devices() ([]*wgtypes.Device, error) {
 
 // Device implements wginternal.Client.
 func (c *Client) Device(name string) (*wgtypes.Device, error) {
+	var d wgtypes.Device
+	if err := c.DeviceInto(name, &d); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// DeviceInto is like Device, but stores the device in d, reusing the storage
+// of its existing Peers. If an error is returned, the contents of d are
+// unspecified.
+func (c *Client) DeviceInto(name string, d *wgtypes.Device) error {
+	return c.DeviceFieldsInto(name, d, wginternal.FieldAll)
+}
+
+// DeviceFieldsInto is like DeviceInto, but only parses the fields of the
+// device selected by mask.
+func (c *Client) DeviceFieldsInto(name string, d *wgtypes.Device, mask wginternal.FieldMask) error {
 	// Don't bother querying netlink with empty input.
 	if name == "" {
-		return nil, os.ErrNotExist
+		return os.ErrNotExist
 	}
 
 	// Fetching a device by interface index is possible as well, but we only
@@ -113,30 +168,48 @@ func (c *Client) Device(name string) (*wgtypes.Device, error) {
 		Data: nlenc.Bytes(name),
 	}})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	msgs, err := c.execute(unix.WG_CMD_GET_DEVICE, netlink.Request|netlink.Dump, b)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return parseDevice(msgs)
+	if c.observe == nil {
+		return parseDeviceInto(msgs, d, mask)
+	}
+
+	start := time.Now()
+	err = parseDeviceInto(msgs, d, mask)
+
+	var n int
+	for _, m := range msgs {
+		n += len(m.Data)
+	}
+	c.observe(n, time.Since(start))
+
+	return err
+}
+
+// SetParseObserver sets a function which is called each time the Client
+// parses a device from netlink messages.
+func (c *Client) SetParseObserver(fn wginternal.ParseObserver) {
+	c.observe = fn
 }
 
 // ConfigureDevice implements wginternal.Client.
 func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
-	// Large configurations are split into batches for use with netlink.
-	for _, b := range buildBatches(cfg) {
-		attrs, err := configAttrs(name, b)
-		if err != nil {
-			return err
-		}
+	msgs, err := EncodeConfig(name, cfg)
+	if err != nil {
+		return err
+	}
 
+	for _, m := range msgs {
 		// Request acknowledgement of our request from netlink, even though the
 		// output messages are unused.  The netlink package checks and trims the
 		// status code value.
-		if _, err := c.execute(unix.WG_CMD_SET_DEVICE, netlink.Request|netlink.Acknowledge, attrs); err != nil {
+		if _, err := c.execute(m.Header.Command, netlink.Request|netlink.Acknowledge, m.Data); err != nil {
 			return err
 		}
 	}
@@ -144,6 +217,28 @@ func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
 	return nil
 }
 
+// PlanConfigureDevice validates cfg and describes each netlink request which
+// ConfigureDevice would send to configure the device name, attribute by
+// attribute, without sending them.
+func (c *Client) PlanConfigureDevice(name string, cfg wgtypes.Config) ([]string, error) {
+	msgs, err := EncodeConfig(name, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		s, err := DescribeMessage(m)
+		if err != nil {
+			return nil, err
+		}
+
+		reqs = append(reqs, s)
+	}
+
+	return reqs, nil
+}
+
 // execute executes a single WireGuard netlink request with the specified command,
 // header flags, and attribute arguments.
 func (c *Client) execute(command uint8, flags netlink.HeaderFlags, attrb []byte) ([]genetlink.Message, error) {
@@ -270,3 +365,9 @@ func isWGKind(ok *bool) func(b []byte) error {
 		return ad.Err()
 	}
 }
+
+// RawNetlink calls fn with the Client's generic netlink connection and the
+// resolved WireGuard generic netlink family.
+func (c *Client) RawNetlink(fn func(conn *genetlink.Conn, family genetlink.Family) error) error {
+	return fn(c.c, c.family)
+}
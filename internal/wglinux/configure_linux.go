@@ -7,14 +7,41 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
-	"unsafe"
 
+	"github.com/mdlayher/genetlink"
 	"github.com/mdlayher/netlink"
 	"github.com/mdlayher/netlink/nlenc"
 	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
+// EncodeConfig produces the generic netlink messages which configure the
+// device specified by name using the non-nil fields in cfg. Large
+// configurations are split into several messages, which must be sent in
+// order.
+func EncodeConfig(name string, cfg wgtypes.Config) ([]genetlink.Message, error) {
+	batches := buildBatches(cfg)
+
+	msgs := make([]genetlink.Message, 0, len(batches))
+	for _, b := range batches {
+		attrs, err := configAttrs(name, b)
+		if err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, genetlink.Message{
+			Header: genetlink.Header{
+				Command: unix.WG_CMD_SET_DEVICE,
+				Version: unix.WG_GENL_VERSION,
+			},
+			Data: attrs,
+		})
+	}
+
+	return msgs, nil
+}
+
 // configAttrs creates the required encoded netlink attributes to configure
 // the device specified by name using the non-nil fields in cfg.
 func configAttrs(name string, cfg wgtypes.Config) ([]byte, error) {
@@ -220,29 +247,28 @@ func encodeSockaddr(endpoint net.UDPAddr) func() ([]byte, error) {
 
 		// Is this an IPv6 address?
 		if isIPv6(endpoint.IP) {
-			var addr [16]byte
-			copy(addr[:], endpoint.IP.To16())
-
-			sa := unix.RawSockaddrInet6{
-				Family: unix.AF_INET6,
-				Port:   sockaddrPort(endpoint.Port),
-				Addr:   addr,
+			// Link-local endpoints must carry their zone as a scope ID.
+			scope, err := wginternal.ZoneIndex(endpoint.Zone)
+			if err != nil {
+				return nil, fmt.Errorf("wglinux: invalid endpoint: %v", err)
 			}
 
-			return (*(*[unix.SizeofSockaddrInet6]byte)(unsafe.Pointer(&sa)))[:], nil
+			b := make([]byte, unix.SizeofSockaddrInet6)
+			nativeEndian.PutUint16(b[sockaddrFamilyOffset:], unix.AF_INET6)
+			binary.BigEndian.PutUint16(b[sockaddrPortOffset:], uint16(endpoint.Port))
+			copy(b[sockaddrInet6AddrOffset:], endpoint.IP.To16())
+			nativeEndian.PutUint32(b[sockaddrInet6ScopeIDOffset:], scope)
+
+			return b, nil
 		}
 
 		// IPv4 address handling.
-		var addr [4]byte
-		copy(addr[:], endpoint.IP.To4())
-
-		sa := unix.RawSockaddrInet4{
-			Family: unix.AF_INET,
-			Port:   sockaddrPort(endpoint.Port),
-			Addr:   addr,
-		}
+		b := make([]byte, unix.SizeofSockaddrInet4)
+		nativeEndian.PutUint16(b[sockaddrFamilyOffset:], unix.AF_INET)
+		binary.BigEndian.PutUint16(b[sockaddrPortOffset:], uint16(endpoint.Port))
+		copy(b[sockaddrInet4AddrOffset:], endpoint.IP.To4())
 
-		return (*(*[unix.SizeofSockaddrInet4]byte)(unsafe.Pointer(&sa)))[:], nil
+		return b, nil
 	}
 }
 
@@ -286,8 +312,17 @@ func isIPv6(ip net.IP) bool {
 	return isValidIP(ip) && ip.To4() == nil
 }
 
-// sockaddrPort interprets port as a big endian uint16 for use passing sockaddr
-// structures to the kernel.
-func sockaddrPort(port int) uint16 {
-	return binary.BigEndian.Uint16(nlenc.Uint16Bytes(uint16(port)))
-}
+// nativeEndian is the byte order of integers in netlink messages and raw
+// kernel structures. Unlike the nlenc functions, its methods do not require
+// their arguments to be aligned.
+var nativeEndian = nlenc.NativeEndian()
+
+// Offsets of the fields of the sockaddr_in and sockaddr_in6 structures. The
+// port is in network byte order, and other integers are in native byte order.
+const (
+	sockaddrFamilyOffset       = 0
+	sockaddrPortOffset         = 2
+	sockaddrInet4AddrOffset    = 4
+	sockaddrInet6AddrOffset    = 8
+	sockaddrInet6ScopeIDOffset = 24
+)
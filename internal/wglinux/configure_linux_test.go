@@ -146,7 +146,8 @@ func TestLinuxClientConfigureDevice(t *testing.T) {
 											0x00, 0x00, 0x00, 0x00,
 											0x00, 0x00, 0x00, 0x33,
 										},
-										Port: sockaddrPort(51820),
+										Port:     sockaddrPort(51820),
+										Scope_id: 2,
 									})))[:],
 								},
 								{
@@ -0,0 +1,175 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// DescribeMessage describes a WG_CMD_SET_DEVICE message produced by
+// EncodeConfig: a summary line followed by one line for each attribute,
+// indented by nesting level. Private and preshared keys are described as
+// "(hidden)".
+func DescribeMessage(m genetlink.Message) (string, error) {
+	if m.Header.Command != unix.WG_CMD_SET_DEVICE {
+		return "", fmt.Errorf("wglinux: cannot describe netlink command %d", m.Header.Command)
+	}
+
+	d := describer{indent: 2}
+	ad, err := netlink.NewAttributeDecoder(m.Data)
+	if err != nil {
+		return "", err
+	}
+
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.WGDEVICE_A_IFNAME:
+			d.line("WGDEVICE_A_IFNAME: %q", ad.String())
+		case unix.WGDEVICE_A_PRIVATE_KEY:
+			d.line("WGDEVICE_A_PRIVATE_KEY: (hidden)")
+		case unix.WGDEVICE_A_LISTEN_PORT:
+			d.line("WGDEVICE_A_LISTEN_PORT: %d", ad.Uint16())
+		case unix.WGDEVICE_A_FWMARK:
+			d.line("WGDEVICE_A_FWMARK: %#x", ad.Uint32())
+		case unix.WGDEVICE_A_FLAGS:
+			d.line("WGDEVICE_A_FLAGS: %s", flagsString(ad.Uint32(), []string{"REPLACE_PEERS"}))
+		case unix.WGDEVICE_A_PEERS:
+			d.line("WGDEVICE_A_PEERS:")
+			ad.Nested(d.array(d.peer))
+		default:
+			d.line("unknown attribute %d: %d bytes", ad.Type(), len(ad.Bytes()))
+		}
+	}
+
+	if err := ad.Err(); err != nil {
+		return "", fmt.Errorf("wglinux: failed to describe message: %v", err)
+	}
+
+	return fmt.Sprintf("netlink WG_CMD_SET_DEVICE: %d bytes, %d peers, %d allowed IPs\n%s",
+		len(m.Data), d.peers, d.ips, d.b.String()), nil
+}
+
+// A describer accumulates the description of netlink attributes.
+type describer struct {
+	b      strings.Builder
+	indent int
+	peers  int
+	ips    int
+}
+
+// line adds a line at the current indentation.
+func (d *describer) line(format string, v ...interface{}) {
+	d.b.WriteString(strings.Repeat("  ", d.indent-1))
+	fmt.Fprintf(&d.b, format, v...)
+	d.b.WriteByte('\n')
+}
+
+// array returns a function which describes each element of a netlink array
+// using fn.
+func (d *describer) array(fn func(ad *netlink.AttributeDecoder) error) func(ad *netlink.AttributeDecoder) error {
+	return func(ad *netlink.AttributeDecoder) error {
+		d.indent++
+		defer func() { d.indent-- }()
+
+		for ad.Next() {
+			d.line("[%d]:", ad.Type())
+
+			d.indent++
+			ad.Nested(fn)
+			d.indent--
+		}
+
+		return nil
+	}
+}
+
+// peer describes the attributes of a peer.
+func (d *describer) peer(ad *netlink.AttributeDecoder) error {
+	d.peers++
+
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.WGPEER_A_PUBLIC_KEY:
+			var k wgtypes.Key
+			ad.Do(parseKey(&k))
+			d.line("WGPEER_A_PUBLIC_KEY: %s", k)
+		case unix.WGPEER_A_FLAGS:
+			d.line("WGPEER_A_FLAGS: %s", flagsString(ad.Uint32(),
+				[]string{"REMOVE_ME", "REPLACE_ALLOWEDIPS", "UPDATE_ONLY"}))
+		case unix.WGPEER_A_PRESHARED_KEY:
+			d.line("WGPEER_A_PRESHARED_KEY: (hidden)")
+		case unix.WGPEER_A_ENDPOINT:
+			var addr net.UDPAddr
+			ad.Do(parseSockaddr(&addr))
+			d.line("WGPEER_A_ENDPOINT: %s", &addr)
+		case unix.WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL:
+			d.line("WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL: %d", ad.Uint16())
+		case unix.WGPEER_A_ALLOWEDIPS:
+			d.line("WGPEER_A_ALLOWEDIPS:")
+			ad.Nested(d.array(d.allowedIP))
+		default:
+			d.line("unknown attribute %d: %d bytes", ad.Type(), len(ad.Bytes()))
+		}
+	}
+
+	return nil
+}
+
+// allowedIP describes the attributes of an allowed IP.
+func (d *describer) allowedIP(ad *netlink.AttributeDecoder) error {
+	d.ips++
+
+	for ad.Next() {
+		switch ad.Type() {
+		case unix.WGALLOWEDIP_A_FAMILY:
+			family := ad.Uint16()
+			switch family {
+			case unix.AF_INET:
+				d.line("WGALLOWEDIP_A_FAMILY: AF_INET")
+			case unix.AF_INET6:
+				d.line("WGALLOWEDIP_A_FAMILY: AF_INET6")
+			default:
+				d.line("WGALLOWEDIP_A_FAMILY: %d", family)
+			}
+		case unix.WGALLOWEDIP_A_IPADDR:
+			var ip net.IP
+			ad.Do(parseAddr(&ip))
+			d.line("WGALLOWEDIP_A_IPADDR: %s", ip)
+		case unix.WGALLOWEDIP_A_CIDR_MASK:
+			d.line("WGALLOWEDIP_A_CIDR_MASK: %d", ad.Uint8())
+		default:
+			d.line("unknown attribute %d: %d bytes", ad.Type(), len(ad.Bytes()))
+		}
+	}
+
+	return nil
+}
+
+// flagsString describes the bits of flags, where names[i] is the name of bit
+// i.
+func flagsString(flags uint32, names []string) string {
+	var ss []string
+	for i, n := range names {
+		if flags&(1<<i) != 0 {
+			ss = append(ss, n)
+			flags &^= 1 << i
+		}
+	}
+
+	if flags != 0 {
+		ss = append(ss, fmt.Sprintf("%#x", flags))
+	}
+	if len(ss) == 0 {
+		return "0"
+	}
+
+	return strings.Join(ss, "|")
+}
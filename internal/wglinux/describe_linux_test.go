@@ -0,0 +1,216 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// Golden descriptions of the messages produced by EncodeConfig, which are
+// independent of the byte order of the platform which runs the tests. The
+// encoding of each attribute is verified by TestLinuxEncodingGolden, and the
+// bytes of each key, which are hidden or base64 encoded in the descriptions,
+// are verified against keys.
+func TestLinuxEncodeConfigGolden(t *testing.T) {
+	var (
+		priv = wgtest.MustHexKey("e84b5a6d2717c1003a13b431570353dbaca9146cf150c5f8575680feba52027a")
+		pub1 = wgtest.MustHexKey("b85996fecc9c7f1fc6d2572a76eda11d59bcd20be8e543b15ce4bd85a8e75a33")
+		pub2 = wgtest.MustHexKey("58402e695ba1772b1cc9309755f043251ea77fdcf10fbe63989ceb7e19321376")
+		psk  = wgtest.MustHexKey("188515093e952f5f22e865cef3012e72f8b5f0b598ac0309d5dacce3b70fcf52")
+
+		port      = 51820
+		fwmark    = 0x1
+		keepalive = 25 * time.Second
+	)
+
+	// A peer with more allowed IPs than fit in a single message.
+	ips := make([]net.IPNet, ipBatchChunk+1)
+	for i := range ips {
+		ips[i] = net.IPNet{
+			IP:   net.IPv4(10, 1, byte(i>>8), byte(i)).To4(),
+			Mask: net.CIDRMask(32, 32),
+		}
+	}
+
+	tests := []struct {
+		name string
+		cfg  wgtypes.Config
+		keys map[string][]wgtypes.Key
+	}{
+		{
+			name: "device",
+			cfg: wgtypes.Config{
+				PrivateKey:   &priv,
+				ListenPort:   &port,
+				FirewallMark: &fwmark,
+				ReplacePeers: true,
+			},
+			keys: map[string][]wgtypes.Key{
+				"WGDEVICE_A_PRIVATE_KEY": {priv},
+			},
+		},
+		{
+			name: "peers",
+			cfg: wgtypes.Config{
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   pub1,
+						PresharedKey:                &psk,
+						Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+						PersistentKeepaliveInterval: &keepalive,
+						ReplaceAllowedIPs:           true,
+						AllowedIPs: []net.IPNet{
+							wgtest.MustCIDR("10.0.0.1/32"),
+							wgtest.MustCIDR("2001:db8::/64"),
+						},
+					},
+					{
+						PublicKey:  pub2,
+						UpdateOnly: true,
+						Endpoint:   wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+					},
+				},
+			},
+			keys: map[string][]wgtypes.Key{
+				"WGPEER_A_PUBLIC_KEY":    {pub1, pub2},
+				"WGPEER_A_PRESHARED_KEY": {psk},
+			},
+		},
+		{
+			name: "remove",
+			cfg: wgtypes.Config{
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey: pub1,
+					Remove:    true,
+				}},
+			},
+			keys: map[string][]wgtypes.Key{
+				"WGPEER_A_PUBLIC_KEY": {pub1},
+			},
+		},
+		{
+			name: "batches",
+			cfg: wgtypes.Config{
+				ReplacePeers: true,
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:         pub1,
+					ReplaceAllowedIPs: true,
+					AllowedIPs:        ips,
+				}},
+			},
+			keys: map[string][]wgtypes.Key{
+				// The peer is repeated in each message.
+				"WGPEER_A_PUBLIC_KEY": {pub1, pub1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := EncodeConfig("wg0", tt.cfg)
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+
+			var b strings.Builder
+			for _, m := range msgs {
+				s, err := DescribeMessage(m)
+				if err != nil {
+					t.Fatalf("failed to describe: %v", err)
+				}
+
+				b.WriteString(s)
+			}
+
+			path := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+					t.Fatalf("failed to update golden file: %v", err)
+				}
+			}
+
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read golden file: %v", err)
+			}
+
+			if diff := cmp.Diff(string(want), b.String()); diff != "" {
+				t.Fatalf("unexpected description (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.keys, encodedKeys(t, msgs)); diff != "" {
+				t.Fatalf("unexpected keys (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// encodedKeys decodes the private, public, and preshared keys from msgs,
+// keyed by attribute name.
+func encodedKeys(t *testing.T, msgs []genetlink.Message) map[string][]wgtypes.Key {
+	t.Helper()
+
+	keys := make(map[string][]wgtypes.Key)
+	key := func(name string, ad *netlink.AttributeDecoder) {
+		var k wgtypes.Key
+		ad.Do(parseKey(&k))
+		keys[name] = append(keys[name], k)
+	}
+
+	peer := func(ad *netlink.AttributeDecoder) error {
+		for ad.Next() {
+			switch ad.Type() {
+			case unix.WGPEER_A_PUBLIC_KEY:
+				key("WGPEER_A_PUBLIC_KEY", ad)
+			case unix.WGPEER_A_PRESHARED_KEY:
+				key("WGPEER_A_PRESHARED_KEY", ad)
+			}
+		}
+
+		return nil
+	}
+
+	for _, m := range msgs {
+		ad, err := netlink.NewAttributeDecoder(m.Data)
+		if err != nil {
+			t.Fatalf("failed to create attribute decoder: %v", err)
+		}
+
+		for ad.Next() {
+			switch ad.Type() {
+			case unix.WGDEVICE_A_PRIVATE_KEY:
+				key("WGDEVICE_A_PRIVATE_KEY", ad)
+			case unix.WGDEVICE_A_PEERS:
+				ad.Nested(func(nad *netlink.AttributeDecoder) error {
+					for nad.Next() {
+						nad.Nested(peer)
+					}
+
+					return nil
+				})
+			}
+		}
+
+		if err := ad.Err(); err != nil {
+			t.Fatalf("failed to decode keys: %v", err)
+		}
+	}
+
+	return keys
+}
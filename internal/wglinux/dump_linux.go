@@ -0,0 +1,228 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// dumpBufferSize is the size of the buffer used to receive each datagram of
+// a limited dump, which is larger than the kernel will send.
+const dumpBufferSize = 64 * 1024
+
+// DeviceLimitedInto is like DeviceInto, but stops retrieving the device when
+// it exceeds l, in which case a *wginternal.TruncatedError is returned and d
+// contains the part of the device which was retrieved.
+func (c *Client) DeviceLimitedInto(name string, d *wgtypes.Device, l wginternal.DumpLimits) error {
+	if name == "" {
+		return os.ErrNotExist
+	}
+
+	// The kernel continues a dump only as it is read, and an abandoned dump
+	// would block further requests on the same socket, so each limited dump
+	// uses its own socket.
+	conn, err := c.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	if !l.Deadline.IsZero() {
+		if err := conn.SetReadDeadline(l.Deadline); err != nil {
+			return err
+		}
+	}
+
+	b, err := netlink.MarshalAttributes([]netlink.Attribute{{
+		Type: unix.WGDEVICE_A_IFNAME,
+		Data: nlenc.Bytes(name),
+	}})
+	if err != nil {
+		return err
+	}
+
+	req, err := conn.Send(genetlink.Message{
+		Header: genetlink.Header{
+			Command: unix.WG_CMD_GET_DEVICE,
+			Version: unix.WG_GENL_VERSION,
+		},
+		Data: b,
+	}, c.family.ID, netlink.Request|netlink.Dump)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, dumpBufferSize)
+	msgs, derr := dumpLimited(func() ([]syscall.NetlinkMessage, int, error) {
+		return recvDatagram(rc, buf)
+	}, req.Header.Sequence, l)
+
+	var terr *wginternal.TruncatedError
+	if derr != nil && !errors.As(derr, &terr) {
+		return derr
+	}
+
+	if err := parseDeviceInto(msgs, d, wginternal.FieldAll); err != nil {
+		return err
+	}
+
+	if l.MaxPeers > 0 && len(d.Peers) > l.MaxPeers {
+		d.Peers = d.Peers[:l.MaxPeers]
+	}
+
+	return derr
+}
+
+// dumpLimited receives the messages of a dump with sequence number seq using
+// recv, which returns the messages in a single datagram and its size. If a
+// limit in l is exceeded, the messages received so far are returned along
+// with a *wginternal.TruncatedError.
+func dumpLimited(recv func() ([]syscall.NetlinkMessage, int, error), seq uint32, l wginternal.DumpLimits) ([]genetlink.Message, error) {
+	var (
+		msgs  []genetlink.Message
+		bytes int
+		peers = make(map[wgtypes.Key]struct{})
+	)
+
+	for {
+		nms, n, err := recv()
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return msgs, &wginternal.TruncatedError{Limit: wginternal.LimitDeadline}
+			}
+
+			return nil, err
+		}
+
+		bytes += n
+		if l.MaxBytes > 0 && bytes > l.MaxBytes {
+			return msgs, &wginternal.TruncatedError{Limit: wginternal.LimitBytes}
+		}
+
+		for _, nm := range nms {
+			if nm.Header.Seq != seq {
+				return nil, fmt.Errorf("wglinux: unexpected netlink sequence number %d, want %d", nm.Header.Seq, seq)
+			}
+
+			switch nm.Header.Type {
+			case unix.NLMSG_DONE:
+				return msgs, nil
+			case unix.NLMSG_ERROR:
+				if len(nm.Data) < 4 {
+					return nil, errors.New("wglinux: short netlink error message")
+				}
+
+				// Convert "no such device" and "not a wireguard device" to an
+				// error compatible with os.ErrNotExist, as execute does.
+				switch errno := unix.Errno(-nlenc.Int32(nm.Data[:4])); errno {
+				case 0:
+					continue
+				case unix.ENODEV, unix.ENOTSUP:
+					return nil, os.ErrNotExist
+				default:
+					return nil, errno
+				}
+			}
+
+			var m genetlink.Message
+			if err := m.UnmarshalBinary(nm.Data); err != nil {
+				return nil, err
+			}
+			msgs = append(msgs, m)
+
+			if l.MaxPeers <= 0 {
+				continue
+			}
+
+			if err := dumpPeers(m.Data, peers); err != nil {
+				return nil, err
+			}
+			if len(peers) > l.MaxPeers {
+				return msgs, &wginternal.TruncatedError{Limit: wginternal.LimitPeers}
+			}
+		}
+	}
+}
+
+// dumpPeers adds the public key of each peer in the device attributes b to
+// peers.
+func dumpPeers(b []byte, peers map[wgtypes.Key]struct{}) error {
+	ad, err := netlink.NewAttributeDecoder(b)
+	if err != nil {
+		return err
+	}
+
+	for ad.Next() {
+		if ad.Type() != unix.WGDEVICE_A_PEERS {
+			continue
+		}
+
+		ad.Nested(func(nad *netlink.AttributeDecoder) error {
+			for nad.Next() {
+				nad.Nested(func(pad *netlink.AttributeDecoder) error {
+					for pad.Next() {
+						if pad.Type() != unix.WGPEER_A_PUBLIC_KEY {
+							continue
+						}
+
+						var k wgtypes.Key
+						pad.Do(parseKey(&k))
+						peers[k] = struct{}{}
+					}
+
+					return nil
+				})
+			}
+
+			return nil
+		})
+	}
+
+	return ad.Err()
+}
+
+// recvDatagram receives a single datagram from rc into b, honoring the
+// socket's read deadline.
+func recvDatagram(rc syscall.RawConn, b []byte) ([]syscall.NetlinkMessage, int, error) {
+	var (
+		n, flags int
+		rerr     error
+	)
+
+	err := rc.Read(func(fd uintptr) bool {
+		n, _, flags, _, rerr = unix.Recvmsg(int(fd), b, nil, unix.MSG_DONTWAIT)
+		return rerr != unix.EAGAIN
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if rerr != nil {
+		return nil, 0, os.NewSyscallError("recvmsg", rerr)
+	}
+	if flags&unix.MSG_TRUNC != 0 {
+		return nil, 0, errors.New("wglinux: netlink datagram was truncated")
+	}
+
+	nms, err := syscall.ParseNetlinkMessage(b[:n])
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return nms, n, nil
+}
@@ -0,0 +1,149 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+func Test_dumpLimited(t *testing.T) {
+	const (
+		seq   = 10
+		peers = 2000
+	)
+
+	msgs := syntheticMessages(peers)
+	if len(msgs) < 3 {
+		t.Fatalf("expected a multi-part dump, but got %d messages", len(msgs))
+	}
+
+	// Each message is received in its own datagram, followed by the end of
+	// the dump.
+	var (
+		datagrams [][]syscall.NetlinkMessage
+		size      int
+	)
+	for _, m := range msgs {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			t.Fatalf("failed to marshal message: %v", err)
+		}
+
+		datagrams = append(datagrams, []syscall.NetlinkMessage{{
+			Header: syscall.NlMsghdr{Type: familyID, Seq: seq},
+			Data:   b,
+		}})
+		size = len(b)
+	}
+	datagrams = append(datagrams, []syscall.NetlinkMessage{{
+		Header: syscall.NlMsghdr{Type: unix.NLMSG_DONE, Seq: seq},
+	}})
+
+	tests := []struct {
+		name  string
+		l     wginternal.DumpLimits
+		err   error
+		limit wginternal.DumpLimit
+		n     int
+	}{
+		{
+			name: "unlimited",
+			n:    len(msgs),
+		},
+		{
+			name: "within limits",
+			l:    wginternal.DumpLimits{MaxPeers: peers, MaxBytes: 100 * size * len(msgs)},
+			n:    len(msgs),
+		},
+		{
+			// The final peer exceeds the limit.
+			name:  "peers",
+			l:     wginternal.DumpLimits{MaxPeers: peers - 1},
+			limit: wginternal.LimitPeers,
+			n:     len(msgs),
+		},
+		{
+			name:  "bytes",
+			l:     wginternal.DumpLimits{MaxBytes: 1},
+			limit: wginternal.LimitBytes,
+		},
+		{
+			name:  "deadline",
+			l:     wginternal.DumpLimits{Deadline: time.Unix(1, 0)},
+			err:   os.ErrDeadlineExceeded,
+			limit: wginternal.LimitDeadline,
+			n:     1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var i int
+			recv := func() ([]syscall.NetlinkMessage, int, error) {
+				// Simulate a deadline after the first datagram.
+				if tt.err != nil && i == 1 {
+					return nil, 0, tt.err
+				}
+
+				d := datagrams[i]
+				i++
+
+				var n int
+				for _, m := range d {
+					n += len(m.Data)
+				}
+
+				return d, n, nil
+			}
+
+			got, err := dumpLimited(recv, seq, tt.l)
+
+			var terr *wginternal.TruncatedError
+			switch {
+			case tt.limit == 0 && err != nil:
+				t.Fatalf("failed to dump: %v", err)
+			case tt.limit != 0 && !errors.As(err, &terr):
+				t.Fatalf("expected TruncatedError, but got: %v", err)
+			case tt.limit != 0:
+				if diff := cmp.Diff(tt.limit, terr.Limit); diff != "" {
+					t.Fatalf("unexpected limit (-want +got):\n%s", diff)
+				}
+			}
+
+			if diff := cmp.Diff(tt.n, len(got)); diff != "" {
+				t.Fatalf("unexpected number of messages (-want +got):\n%s", diff)
+			}
+
+			// Partial results must still parse.
+			if _, err := parseDevice(got); err != nil {
+				t.Fatalf("failed to parse partial device: %v", err)
+			}
+		})
+	}
+}
+
+func Test_dumpLimitedNotExist(t *testing.T) {
+	recv := func() ([]syscall.NetlinkMessage, int, error) {
+		// A netlink error message with ENODEV.
+		b := nlenc.Int32Bytes(-int32(unix.ENODEV))
+
+		return []syscall.NetlinkMessage{{
+			Header: syscall.NlMsghdr{Type: unix.NLMSG_ERROR, Seq: 1},
+			Data:   b,
+		}}, len(b), nil
+	}
+
+	if _, err := dumpLimited(recv, 1, wginternal.DumpLimits{}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error, but got: %v", err)
+	}
+}
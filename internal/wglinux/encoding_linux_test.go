@@ -0,0 +1,260 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Golden byte vectors for each encoding, so that the encodings are verified
+// on little and big endian platforms regardless of the platform which runs
+// the tests.
+func TestLinuxEncodingGolden(t *testing.T) {
+	port := 51820
+
+	tests := []struct {
+		name   string
+		encode func() ([]byte, error)
+		le, be []byte
+	}{
+		{
+			name:   "sockaddr_in",
+			encode: encodeSockaddr(*wgtest.MustUDPAddr("192.0.2.1:51820")),
+			le: []byte{
+				0x02, 0x00, // AF_INET
+				0xca, 0x6c, // 51820
+				192, 0, 2, 1,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			be: []byte{
+				0x00, 0x02, // AF_INET
+				0xca, 0x6c, // 51820
+				192, 0, 2, 1,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+		},
+		{
+			name:   "sockaddr_in6",
+			encode: encodeSockaddr(*wgtest.MustUDPAddr("[2001:db8::1%4294967280]:51820")),
+			le: []byte{
+				0x0a, 0x00, // AF_INET6
+				0xca, 0x6c, // 51820
+				0, 0, 0, 0, // flow info
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+				0xf0, 0xff, 0xff, 0xff, // scope ID
+			},
+			be: []byte{
+				0x00, 0x0a, // AF_INET6
+				0xca, 0x6c, // 51820
+				0, 0, 0, 0, // flow info
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+				0xff, 0xff, 0xff, 0xf0, // scope ID
+			},
+		},
+		{
+			name: "listen port",
+			encode: func() ([]byte, error) {
+				return configAttrs("wg0", wgtypes.Config{ListenPort: &port})
+			},
+			le: []byte{
+				0x08, 0x00, 0x02, 0x00, 'w', 'g', '0', 0x00, // WGDEVICE_A_IFNAME
+				0x06, 0x00, 0x06, 0x00, 0x6c, 0xca, 0x00, 0x00, // WGDEVICE_A_LISTEN_PORT
+			},
+			be: []byte{
+				0x00, 0x08, 0x00, 0x02, 'w', 'g', '0', 0x00, // WGDEVICE_A_IFNAME
+				0x00, 0x06, 0x00, 0x06, 0xca, 0x6c, 0x00, 0x00, // WGDEVICE_A_LISTEN_PORT
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.le
+			if nativeEndian == binary.BigEndian {
+				want = tt.be
+			}
+
+			b, err := tt.encode()
+			if err != nil {
+				t.Fatalf("failed to encode: %v", err)
+			}
+
+			if diff := cmp.Diff(want, b); diff != "" {
+				t.Fatalf("unexpected bytes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLinuxDecodingGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		le, be []byte
+		decode func(b []byte) (interface{}, error)
+		want   interface{}
+	}{
+		{
+			name: "sockaddr_in6",
+			le: []byte{
+				0x0a, 0x00, 0xca, 0x6c, 0, 0, 0, 0,
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+				0xf0, 0xff, 0xff, 0xff,
+			},
+			be: []byte{
+				0x00, 0x0a, 0xca, 0x6c, 0, 0, 0, 0,
+				0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x01,
+				0xff, 0xff, 0xff, 0xf0,
+			},
+			decode: func(b []byte) (interface{}, error) {
+				var addr net.UDPAddr
+				err := parseSockaddr(&addr)(b)
+				return &addr, err
+			},
+			want: wgtest.MustUDPAddr("[2001:db8::1%4294967280]:51820"),
+		},
+		{
+			name: "timespec32",
+			le:   []byte{0x78, 0x56, 0x34, 0x12, 0x02, 0x00, 0x00, 0x00},
+			be:   []byte{0x12, 0x34, 0x56, 0x78, 0x00, 0x00, 0x00, 0x02},
+			decode: func(b []byte) (interface{}, error) {
+				var ts time.Time
+				err := parseTimespec(&ts)(b)
+				return ts, err
+			},
+			want: time.Unix(0x12345678, 2),
+		},
+		{
+			name: "timespec64",
+			le: []byte{
+				0x00, 0x0e, 0xf1, 0xae, 0x01, 0x00, 0x00, 0x00, // 7230000640
+				0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+			be: []byte{
+				0x00, 0x00, 0x00, 0x01, 0xae, 0xf1, 0x0e, 0x00, // 7230000640
+				0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02,
+			},
+			decode: func(b []byte) (interface{}, error) {
+				var ts time.Time
+				err := parseTimespec(&ts)(b)
+				return ts, err
+			},
+			// A time after 2038 requires a 64-bit seconds value.
+			want: time.Unix(7230000640, 2),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := tt.le
+			if nativeEndian == binary.BigEndian {
+				b = tt.be
+			}
+
+			// Decode from an unaligned offset to verify that decoding does
+			// not depend on the alignment of the input.
+			buf := make([]byte, len(b)+1)
+			copy(buf[1:], b)
+
+			got, err := tt.decode(buf[1:])
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected value (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLinuxTimespecABI(t *testing.T) {
+	// Build timespecs explicitly so that the test does not depend on the
+	// layout of unix.Timespec on the platform which runs the test.
+	ts32 := func(sec, nsec uint32) []byte {
+		b := make([]byte, 8)
+		nativeEndian.PutUint32(b[0:4], sec)
+		nativeEndian.PutUint32(b[4:8], nsec)
+		return b
+	}
+
+	ts64 := func(sec, nsec uint64) []byte {
+		b := make([]byte, 16)
+		nativeEndian.PutUint64(b[0:8], sec)
+		nativeEndian.PutUint64(b[8:16], nsec)
+		return b
+	}
+
+	tests := []struct {
+		name string
+		b    []byte
+		t    time.Time
+		ok   bool
+	}{
+		{
+			name: "32-bit",
+			b:    ts32(1600000000, 999999999),
+			t:    time.Unix(1600000000, 999999999),
+			ok:   true,
+		},
+		{
+			name: "64-bit",
+			b:    ts64(1600000000, 1),
+			t:    time.Unix(1600000000, 1),
+			ok:   true,
+		},
+		{
+			name: "64-bit after 2038",
+			b:    ts64(1<<32, 0),
+			t:    time.Unix(1<<32, 0),
+			ok:   true,
+		},
+		{
+			name: "32-bit nanoseconds out of range",
+			b:    ts32(1, uint32(time.Second)),
+		},
+		{
+			// A 32-bit timespec misinterpreted as a 64-bit timespec would
+			// produce nanoseconds from the following attribute's bytes.
+			name: "64-bit nanoseconds out of range",
+			b:    ts64(1, 1<<32),
+		},
+		{
+			name: "64-bit negative nanoseconds",
+			b:    ts64(1, ^uint64(0)),
+		},
+		{
+			name: "12 bytes",
+			b:    make([]byte, 12),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got time.Time
+			err := parseTimespec(&got)(tt.b)
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse timespec: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.t, got); diff != "" {
+				t.Fatalf("unexpected time (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
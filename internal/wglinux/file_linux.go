@@ -0,0 +1,137 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"context"
+	"os"
+	"syscall"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/socket"
+	"golang.org/x/sys/unix"
+)
+
+// NewFromFile creates a Client which communicates over an existing generic
+// netlink socket f, and returns whether or not the generic netlink interface
+// is available.
+//
+// The Linux kernel checks the privileges of the process which opened a netlink
+// socket rather than the process which uses it, so a socket opened by a
+// privileged process and passed to an unprivileged one can be used to
+// configure devices. f is duplicated, so the caller remains responsible for
+// closing it.
+func NewFromFile(f *os.File) (*Client, bool, error) {
+	s, err := socket.FileConn(f, "netlink")
+	if err != nil {
+		return nil, false, err
+	}
+
+	sa, err := s.Getsockname()
+	if err != nil {
+		_ = s.Close()
+		return nil, false, err
+	}
+
+	nsa, ok := sa.(*unix.SockaddrNetlink)
+	if !ok {
+		_ = s.Close()
+		return nil, false, os.NewSyscallError("getsockname", unix.EAFNOSUPPORT)
+	}
+
+	// The socket may not have been bound by its creator, in which case the
+	// kernel must assign a port ID now.
+	if nsa.Pid == 0 {
+		if err := s.Bind(&unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+			_ = s.Close()
+			return nil, false, err
+		}
+
+		if sa, err = s.Getsockname(); err != nil {
+			_ = s.Close()
+			return nil, false, err
+		}
+		nsa = sa.(*unix.SockaddrNetlink)
+	}
+
+	return initClient(genetlink.NewConn(netlink.NewConn(&fileSocket{s: s}, nsa.Pid)))
+}
+
+var _ netlink.Socket = &fileSocket{}
+
+// A fileSocket is a netlink.Socket backed by a *socket.Conn created from an
+// existing file descriptor.
+type fileSocket struct {
+	s *socket.Conn
+}
+
+// Close implements netlink.Socket.
+func (fs *fileSocket) Close() error { return fs.s.Close() }
+
+// Send implements netlink.Socket.
+func (fs *fileSocket) Send(m netlink.Message) error {
+	return fs.SendMessages([]netlink.Message{m})
+}
+
+// SendMessages implements netlink.Socket.
+func (fs *fileSocket) SendMessages(msgs []netlink.Message) error {
+	var buf []byte
+	for _, m := range msgs {
+		b, err := m.MarshalBinary()
+		if err != nil {
+			return err
+		}
+
+		buf = append(buf, b...)
+	}
+
+	_, err := fs.s.Sendmsg(context.Background(), buf, nil, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}, 0)
+	return err
+}
+
+// Receive implements netlink.Socket.
+func (fs *fileSocket) Receive() ([]netlink.Message, error) {
+	b := make([]byte, os.Getpagesize())
+	for {
+		// Peek at the buffer and grow it until all available messages fit.
+		n, _, _, _, err := fs.s.Recvmsg(context.Background(), b, nil, unix.MSG_PEEK)
+		if err != nil {
+			return nil, err
+		}
+		if n < len(b) {
+			break
+		}
+
+		b = make([]byte, len(b)*2)
+	}
+
+	n, _, _, _, err := fs.s.Recvmsg(context.Background(), b, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Netlink messages are padded to a 4 byte boundary.
+	n = (n + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+	raw, err := syscall.ParseNetlinkMessage(b[:n])
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]netlink.Message, 0, len(raw))
+	for _, r := range raw {
+		msgs = append(msgs, netlink.Message{
+			Header: netlink.Header{
+				Length:   r.Header.Len,
+				Type:     netlink.HeaderType(r.Header.Type),
+				Flags:    netlink.HeaderFlags(r.Header.Flags),
+				Sequence: r.Header.Seq,
+				PID:      r.Header.Pid,
+			},
+			Data: r.Data,
+		})
+	}
+
+	return msgs, nil
+}
@@ -0,0 +1,40 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestLinuxNewFromFile(t *testing.T) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_GENERIC)
+	if err != nil {
+		t.Skipf("skipping, failed to open generic netlink socket: %v", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "genetlink")
+	defer f.Close()
+
+	// Whether or not WireGuard is available, resolving the family requires a
+	// full round trip over the file-backed socket.
+	c, ok, err := NewFromFile(f)
+	if err != nil {
+		t.Fatalf("failed to create Client: %v", err)
+	}
+	if !ok {
+		t.Skip("skipping, the WireGuard generic netlink API is not available")
+	}
+	defer c.Close()
+
+	// Any request made by an unprivileged user is denied, so the device either
+	// does not exist or cannot be accessed.
+	_, err = c.Device("wgnotexist0")
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected not exist or permission denied, but got: %v", err)
+	}
+}
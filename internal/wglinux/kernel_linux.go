@@ -0,0 +1,104 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mdlayher/genetlink"
+	"golang.org/x/sys/unix"
+)
+
+// ModuleDir is the sysfs directory which describes the WireGuard kernel
+// module.
+const ModuleDir = "/sys/module/wireguard"
+
+// A Module describes the WireGuard kernel module, as reported by sysfs.
+type Module struct {
+	// Present reports whether the module is loaded or built into the kernel.
+	Present bool
+
+	// BuiltIn reports whether the module is built into the kernel, rather
+	// than loaded.
+	BuiltIn bool
+
+	// OutOfTree reports whether the module was built outside of the kernel
+	// tree, such as the wireguard-linux-compat DKMS module.
+	OutOfTree bool
+
+	// Version is the module's version, if reported.
+	Version string
+}
+
+// ReadModule reads information about the WireGuard kernel module from the
+// sysfs directory dir, typically ModuleDir.
+func ReadModule(dir string) (*Module, error) {
+	if _, err := os.Stat(dir); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Module{}, nil
+		}
+
+		return nil, err
+	}
+
+	m := &Module{Present: true}
+
+	// Only loadable modules report their initialization state.
+	if _, err := os.Stat(filepath.Join(dir, "initstate")); errors.Is(err, os.ErrNotExist) {
+		m.BuiltIn = true
+	}
+
+	// The 'O' taint flag indicates an out-of-tree module.
+	taint, err := readModuleFile(dir, "taint")
+	if err != nil {
+		return nil, err
+	}
+	m.OutOfTree = strings.ContainsRune(taint, 'O')
+
+	m.Version, err = readModuleFile(dir, "version")
+	if err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// readModuleFile reads the contents of file in dir, returning an empty string
+// if the file does not exist.
+func readModuleFile(dir, file string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
+// FamilyVersion returns the version of the WireGuard generic netlink family,
+// and whether the family is available.
+func FamilyVersion() (int, bool, error) {
+	c, err := genetlink.Dial(nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer c.Close()
+
+	f, err := c.GetFamily(unix.WG_GENL_NAME)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, false, nil
+		}
+
+		return 0, false, err
+	}
+
+	return int(f.Version), true, nil
+}
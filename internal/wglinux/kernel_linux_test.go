@@ -0,0 +1,77 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadModule(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		m     *Module
+	}{
+		{
+			name: "not present",
+		},
+		{
+			name:  "built in",
+			files: map[string]string{"version": "1.0.0\n"},
+			m:     &Module{Present: true, BuiltIn: true, Version: "1.0.0"},
+		},
+		{
+			name: "in tree",
+			files: map[string]string{
+				"initstate": "live\n",
+				"taint":     "\n",
+			},
+			m: &Module{Present: true},
+		},
+		{
+			name: "out of tree",
+			files: map[string]string{
+				"initstate": "live\n",
+				"taint":     "OE\n",
+				"version":   "1.0.20210606\n",
+			},
+			m: &Module{Present: true, OutOfTree: true, Version: "1.0.20210606"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "wireguard")
+			if tt.files != nil {
+				if err := os.Mkdir(dir, 0o755); err != nil {
+					t.Fatalf("failed to create module directory: %v", err)
+				}
+			}
+
+			for f, s := range tt.files {
+				if err := os.WriteFile(filepath.Join(dir, f), []byte(s), 0o644); err != nil {
+					t.Fatalf("failed to write %s: %v", f, err)
+				}
+			}
+
+			want := tt.m
+			if want == nil {
+				want = &Module{}
+			}
+
+			m, err := ReadModule(dir)
+			if err != nil {
+				t.Fatalf("failed to read module: %v", err)
+			}
+
+			if diff := cmp.Diff(want, m); diff != "" {
+				t.Fatalf("unexpected Module (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@
 package wglinux
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/mdlayher/genetlink"
 	"github.com/mdlayher/netlink"
 	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
@@ -19,45 +21,52 @@ import (
 // automatically merging peer lists from subsequent messages into the Device
 // from the first message.
 func parseDevice(msgs []genetlink.Message) (*wgtypes.Device, error) {
-	var first wgtypes.Device
-	knownPeers := make(map[wgtypes.Key]int)
+	var d wgtypes.Device
+	if err := parseDeviceInto(msgs, &d, wginternal.FieldAll); err != nil {
+		return nil, err
+	}
 
-	for i, m := range msgs {
-		d, err := parseDeviceLoop(m)
-		if err != nil {
-			return nil, err
-		}
+	return &d, nil
+}
 
-		if i == 0 {
-			// First message contains our target device.
-			first = *d
+// parseDeviceInto is like parseDevice, but parses into d and reuses the
+// storage of its existing Peers, their Endpoints, and their AllowedIPs. Only
+// the fields selected by mask are parsed.
+func parseDeviceInto(msgs []genetlink.Message, d *wgtypes.Device, mask wginternal.FieldMask) error {
+	*d = wgtypes.Device{
+		Type:  wgtypes.LinuxKernel,
+		Peers: d.Peers[:0],
+	}
 
-			// Gather the known peers so that we can merge
-			// them later if needed
-			for i := range first.Peers {
-				knownPeers[first.Peers[i].PublicKey] = i
-			}
+	// A peer's allowed IPs may be split across multiple messages, in which
+	// case the peer is repeated at the start of the next message.
+	knownPeers := make(map[wgtypes.Key]int, cap(d.Peers))
 
-			continue
+	for i, m := range msgs {
+		// Only peers in subsequent messages are merged into known peers.
+		if err := parseDeviceLoop(m, d, mask, knownPeers, i > 0); err != nil {
+			return err
 		}
-
-		// Any subsequent messages have their peer contents merged into the
-		// first "target" message.
-		mergeDevices(&first, d, knownPeers)
 	}
 
-	return &first, nil
+	return nil
 }
 
-// parseDeviceLoop parses a Device from a single generic netlink message.
-func parseDeviceLoop(m genetlink.Message) (*wgtypes.Device, error) {
+// parseDeviceLoop parses a single generic netlink message into d. If merge is
+// true, peers which are already known are merged rather than appended.
+func parseDeviceLoop(m genetlink.Message, d *wgtypes.Device, mask wginternal.FieldMask, knownPeers map[wgtypes.Key]int, merge bool) error {
 	ad, err := netlink.NewAttributeDecoder(m.Data)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	d := wgtypes.Device{Type: wgtypes.LinuxKernel}
 	for ad.Next() {
+		// The kernel always sends all fields, but those which were not
+		// requested are skipped without parsing.
+		if !wantDeviceAttr(ad.Type(), mask) {
+			continue
+		}
+
 		switch ad.Type() {
 		case unix.WGDEVICE_A_IFINDEX:
 			// Ignored; interface index isn't exposed at all in the userspace
@@ -77,12 +86,24 @@ func parseDeviceLoop(m genetlink.Message) (*wgtypes.Device, error) {
 			//
 			// Errors while parsing are propagated up to top-level ad.Err check.
 			ad.Nested(func(nad *netlink.AttributeDecoder) error {
-				// Initialize to the number of peers in this decoder and begin
-				// handling nested Peer attributes.
-				d.Peers = make([]wgtypes.Peer, 0, nad.Len())
 				for nad.Next() {
 					nad.Nested(func(nnad *netlink.AttributeDecoder) error {
-						d.Peers = append(d.Peers, parsePeer(nnad))
+						p := appendPeer(&d.Peers)
+						parsePeer(nnad, p, mask)
+
+						i, ok := knownPeers[p.PublicKey]
+						if !ok || !merge {
+							knownPeers[p.PublicKey] = len(d.Peers) - 1
+							return nil
+						}
+
+						// Peer is already known, append to its allowed IP
+						// networks and discard the duplicate. The duplicate's
+						// storage is now shared with the known peer, so it
+						// must not be reused.
+						d.Peers[i].AllowedIPs = append(d.Peers[i].AllowedIPs, p.AllowedIPs...)
+						*p = wgtypes.Peer{}
+						d.Peers = d.Peers[:len(d.Peers)-1]
 						return nil
 					})
 				}
@@ -92,24 +113,77 @@ func parseDeviceLoop(m genetlink.Message) (*wgtypes.Device, error) {
 		}
 	}
 
-	if err := ad.Err(); err != nil {
-		return nil, err
+	return ad.Err()
+}
+
+// appendPeer extends peers by one Peer and returns a pointer to it. If the
+// backing array of peers has spare capacity, the Peer previously stored there
+// is returned so that its storage may be reused.
+func appendPeer(peers *[]wgtypes.Peer) *wgtypes.Peer {
+	n := len(*peers)
+	if n < cap(*peers) {
+		*peers = (*peers)[:n+1]
+	} else {
+		*peers = append(*peers, wgtypes.Peer{})
 	}
 
-	return &d, nil
+	return &(*peers)[n]
+}
+
+// wantDeviceAttr reports whether the device attribute typ is selected by mask.
+func wantDeviceAttr(typ uint16, mask wginternal.FieldMask) bool {
+	switch typ {
+	case unix.WGDEVICE_A_PRIVATE_KEY:
+		return mask&wginternal.FieldDevice != 0 && mask&wginternal.FieldSecrets != 0
+	case unix.WGDEVICE_A_PUBLIC_KEY, unix.WGDEVICE_A_LISTEN_PORT, unix.WGDEVICE_A_FWMARK:
+		return mask&wginternal.FieldDevice != 0
+	case unix.WGDEVICE_A_PEERS:
+		return mask.Peers()
+	default:
+		return true
+	}
 }
 
-// parseAllowedIPs parses a wgtypes.Peer from a netlink attribute payload.
-func parsePeer(ad *netlink.AttributeDecoder) wgtypes.Peer {
-	var p wgtypes.Peer
+// wantPeerAttr reports whether the peer attribute typ is selected by mask.
+func wantPeerAttr(typ uint16, mask wginternal.FieldMask) bool {
+	switch typ {
+	case unix.WGPEER_A_PRESHARED_KEY, unix.WGPEER_A_ENDPOINT, unix.WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL,
+		unix.WGPEER_A_PROTOCOL_VERSION:
+		return mask&wginternal.FieldPeers != 0
+	case unix.WGPEER_A_LAST_HANDSHAKE_TIME, unix.WGPEER_A_RX_BYTES, unix.WGPEER_A_TX_BYTES:
+		return mask&wginternal.FieldCounters != 0
+	case unix.WGPEER_A_ALLOWEDIPS:
+		return mask&wginternal.FieldAllowedIPs != 0
+	default:
+		return true
+	}
+}
+
+// parsePeer parses a wgtypes.Peer from a netlink attribute payload into p,
+// reusing the storage of its Endpoint and AllowedIPs. Only the fields selected
+// by mask are parsed.
+func parsePeer(ad *netlink.AttributeDecoder, p *wgtypes.Peer, mask wginternal.FieldMask) {
+	var (
+		endpoint = p.Endpoint
+		ipns     = p.AllowedIPs[:0]
+	)
+
+	*p = wgtypes.Peer{AllowedIPs: ipns}
 	for ad.Next() {
+		if !wantPeerAttr(ad.Type(), mask) {
+			continue
+		}
+
 		switch ad.Type() {
 		case unix.WGPEER_A_PUBLIC_KEY:
 			ad.Do(parseKey(&p.PublicKey))
 		case unix.WGPEER_A_PRESHARED_KEY:
-			ad.Do(parseKey(&p.PresharedKey))
+			ad.Do(parsePresharedKey(p, mask&wginternal.FieldSecrets != 0))
 		case unix.WGPEER_A_ENDPOINT:
-			p.Endpoint = &net.UDPAddr{}
+			if endpoint == nil {
+				endpoint = &net.UDPAddr{}
+			}
+			p.Endpoint = endpoint
 			ad.Do(parseSockaddr(p.Endpoint))
 		case unix.WGPEER_A_PERSISTENT_KEEPALIVE_INTERVAL:
 			p.PersistentKeepaliveInterval = time.Duration(ad.Uint16()) * time.Second
@@ -125,16 +199,18 @@ func parsePeer(ad *netlink.AttributeDecoder) wgtypes.Peer {
 			p.ProtocolVersion = int(ad.Uint32())
 		}
 	}
-
-	return p
 }
 
 // parseAllowedIPs parses a slice of net.IPNet from a netlink attribute payload.
 func parseAllowedIPs(ipns *[]net.IPNet) func(ad *netlink.AttributeDecoder) error {
 	return func(ad *netlink.AttributeDecoder) error {
-		// Initialize to the number of allowed IPs and begin iterating through
-		// the netlink array to decode each one.
-		*ipns = make([]net.IPNet, 0, ad.Len())
+		// Initialize to the number of allowed IPs, unless existing storage is
+		// large enough, and begin iterating through the netlink array to
+		// decode each one.
+		if *ipns == nil || cap(*ipns) < ad.Len() {
+			*ipns = make([]net.IPNet, 0, ad.Len())
+		}
+
 		for ad.Next() {
 			// Allowed IP nested attributes.
 			ad.Nested(func(nad *netlink.AttributeDecoder) error {
@@ -144,6 +220,11 @@ func parseAllowedIPs(ipns *[]net.IPNet) func(ad *netlink.AttributeDecoder) error
 					family int
 				)
 
+				// Reuse the storage of a previous IP address, if any.
+				if n := len(*ipns); n < cap(*ipns) {
+					ipn.IP = (*ipns)[:n+1][n].IP
+				}
+
 				for nad.Next() {
 					switch nad.Type() {
 					case unix.WGALLOWEDIP_A_IPADDR:
@@ -190,13 +271,35 @@ func parseKey(key *wgtypes.Key) func(b []byte) error {
 	}
 }
 
+// parsePresharedKey determines whether p has a preshared key from raw key
+// bytes, storing the key itself only if secrets is true.
+func parsePresharedKey(p *wgtypes.Peer, secrets bool) func(b []byte) error {
+	return func(b []byte) error {
+		k, err := wgtypes.NewKey(b)
+		if err != nil {
+			return err
+		}
+
+		p.HasPresharedKey = k != wgtypes.Key{}
+		if secrets {
+			p.PresharedKey = k
+		}
+
+		return nil
+	}
+}
+
 // parseAddr parses a net.IP from raw in_addr or in6_addr struct bytes.
 func parseAddr(ip *net.IP) func(b []byte) error {
 	return func(b []byte) error {
 		switch len(b) {
 		case net.IPv4len, net.IPv6len:
 			// Okay to convert directly to net.IP; memory layout is identical.
-			*ip = make(net.IP, len(b))
+			// Reuse the storage of *ip if it is large enough.
+			if cap(*ip) < len(b) {
+				*ip = make(net.IP, len(b))
+			}
+			*ip = (*ip)[:len(b)]
 			copy(*ip, b)
 			return nil
 		default:
@@ -211,21 +314,24 @@ func parseSockaddr(endpoint *net.UDPAddr) func(b []byte) error {
 		switch len(b) {
 		case unix.SizeofSockaddrInet4:
 			// IPv4 address parsing.
-			sa := *(*unix.RawSockaddrInet4)(unsafe.Pointer(&b[0]))
+			ip := make(net.IP, net.IPv4len)
+			copy(ip, b[sockaddrInet4AddrOffset:])
 
 			*endpoint = net.UDPAddr{
-				IP:   net.IP(sa.Addr[:]).To4(),
-				Port: int(sockaddrPort(int(sa.Port))),
+				IP:   ip,
+				Port: int(binary.BigEndian.Uint16(b[sockaddrPortOffset:])),
 			}
 
 			return nil
 		case unix.SizeofSockaddrInet6:
 			// IPv6 address parsing.
-			sa := *(*unix.RawSockaddrInet6)(unsafe.Pointer(&b[0]))
+			ip := make(net.IP, net.IPv6len)
+			copy(ip, b[sockaddrInet6AddrOffset:])
 
 			*endpoint = net.UDPAddr{
-				IP:   net.IP(sa.Addr[:]),
-				Port: int(sockaddrPort(int(sa.Port))),
+				IP:   ip,
+				Port: int(binary.BigEndian.Uint16(b[sockaddrPortOffset:])),
+				Zone: wginternal.ZoneName(nativeEndian.Uint32(b[sockaddrInet6ScopeIDOffset:])),
 			}
 
 			return nil
@@ -255,29 +361,34 @@ const (
 // parseTimespec parses a time.Time from raw timespec bytes.
 func parseTimespec(t *time.Time) func(b []byte) error {
 	return func(b []byte) error {
-		// It would appear that WireGuard can return a __kernel_timespec which
-		// uses 64-bit integers, even on 32-bit platforms. Clarification of this
-		// behavior is being sought in:
-		// https://lists.zx2c4.com/pipermail/wireguard/2019-April/004088.html.
+		// WireGuard returns a __kernel_timespec which uses 64-bit integers,
+		// even on 32-bit platforms and for 32-bit processes on 64-bit kernels,
+		// but some kernels have used the native timespec. The layout is chosen
+		// by the size of the attribute rather than the size of unix.Timespec,
+		// so both variants are accepted on any platform.
 		//
-		// In the mean time, be liberal and accept 32-bit and 64-bit variants.
+		// The fields are decoded individually rather than by casting b to a
+		// structure, because b need not be aligned for 64-bit integers on
+		// 32-bit platforms.
 		var sec, nsec int64
 
 		switch len(b) {
 		case sizeofTimespec32:
-			ts := *(*timespec32)(unsafe.Pointer(&b[0]))
-
-			sec = int64(ts.Sec)
-			nsec = int64(ts.Nsec)
+			sec = int64(int32(nativeEndian.Uint32(b[0:4])))
+			nsec = int64(int32(nativeEndian.Uint32(b[4:8])))
 		case sizeofTimespec64:
-			ts := *(*timespec64)(unsafe.Pointer(&b[0]))
-
-			sec = ts.Sec
-			nsec = ts.Nsec
+			sec = int64(nativeEndian.Uint64(b[0:8]))
+			nsec = int64(nativeEndian.Uint64(b[8:16]))
 		default:
 			return fmt.Errorf("wglinux: unexpected timespec size: %d bytes, expected 8 or 16 bytes", len(b))
 		}
 
+		// A nanoseconds value out of range indicates that the layout was
+		// misinterpreted.
+		if nsec < 0 || nsec >= int64(time.Second) {
+			return fmt.Errorf("wglinux: invalid timespec nanoseconds: %d", nsec)
+		}
+
 		// Only set fields if UNIX timestamp value is greater than 0, so the
 		// caller will see a zero-value time.Time otherwise.
 		if sec > 0 || nsec > 0 {
@@ -287,17 +398,3 @@ func parseTimespec(t *time.Time) func(b []byte) error {
 		return nil
 	}
 }
-
-// mergeDevices merges Peer information from d into target.  mergeDevices is
-// used to deal with multiple incoming netlink messages for the same device.
-func mergeDevices(target, d *wgtypes.Device, knownPeers map[wgtypes.Key]int) {
-	for i := range d.Peers {
-		// Peer is already known, append to it's allowed IP networks
-		if peerIndex, ok := knownPeers[d.Peers[i].PublicKey]; ok {
-			target.Peers[peerIndex].AllowedIPs = append(target.Peers[peerIndex].AllowedIPs, d.Peers[i].AllowedIPs...)
-		} else { // New peer, add it to the target peers.
-			target.Peers = append(target.Peers, d.Peers[i])
-			knownPeers[d.Peers[i].PublicKey] = len(target.Peers) - 1
-		}
-	}
-}
@@ -4,6 +4,7 @@
 package wglinux
 
 import (
+	"encoding/binary"
 	"net"
 	"runtime"
 	"testing"
@@ -11,11 +12,13 @@ import (
 	"unsafe"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/mdlayher/genetlink"
 	"github.com/mdlayher/genetlink/genltest"
 	"github.com/mdlayher/netlink"
 	"github.com/mdlayher/netlink/nlenc"
 	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
@@ -276,8 +279,9 @@ func TestLinuxClientDevicesOK(t *testing.T) {
 					FirewallMark: 0xff,
 					Peers: []wgtypes.Peer{
 						{
-							PublicKey:    testKey,
-							PresharedKey: testKey,
+							PublicKey:       testKey,
+							PresharedKey:    testKey,
+							HasPresharedKey: true,
 							Endpoint: &net.UDPAddr{
 								IP:   net.IPv4(192, 168, 1, 1),
 								Port: 1111,
@@ -491,6 +495,36 @@ func TestLinuxClientDevicesOK(t *testing.T) {
 	}
 }
 
+func Test_sockaddrZoneRoundTrip(t *testing.T) {
+	// Use a scope ID which cannot belong to a real interface so that the
+	// numeric form of the zone is preserved.
+	want := wgtest.MustUDPAddr("[fe80::1%4294967280]:51820")
+
+	b, err := encodeSockaddr(*want)()
+	if err != nil {
+		t.Fatalf("failed to encode sockaddr: %v", err)
+	}
+
+	var got net.UDPAddr
+	if err := parseSockaddr(&got)(b); err != nil {
+		t.Fatalf("failed to parse sockaddr: %v", err)
+	}
+
+	if diff := cmp.Diff(want, &got); diff != "" {
+		t.Fatalf("unexpected endpoint (-want +got):\n%s", diff)
+	}
+
+	if _, err := encodeSockaddr(*wgtest.MustUDPAddr("[fe80::1%2]:51820"))(); err != nil {
+		t.Fatalf("failed to encode numeric zone: %v", err)
+	}
+
+	bad := *want
+	bad.Zone = "wgnotexist0"
+	if _, err := encodeSockaddr(bad)(); err == nil {
+		t.Fatal("expected an error for unknown zone, but none occurred")
+	}
+}
+
 func Test_parseTimespec(t *testing.T) {
 	var zero [sizeofTimespec64]byte
 
@@ -618,3 +652,46 @@ func Test_timespec64MemoryLayout(t *testing.T) {
 		t.Fatalf("unexpected timespec nanoseconds (-want +got):\n%s", diff)
 	}
 }
+
+func TestLinux_parseDeviceIntoFieldMask(t *testing.T) {
+	msgs := syntheticMessages(10)
+
+	tests := []struct {
+		name string
+		mask wginternal.FieldMask
+	}{
+		{name: "all", mask: wginternal.FieldAll},
+		{name: "device", mask: wginternal.FieldDevice},
+		{name: "peers", mask: wginternal.FieldAll &^ wginternal.FieldDevice},
+		{name: "counters", mask: wginternal.FieldCounters},
+		{name: "no allowed IPs", mask: wginternal.FieldAll &^ wginternal.FieldAllowedIPs},
+		{name: "no secrets", mask: wginternal.FieldAll &^ wginternal.FieldSecrets},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := parseDevice(msgs)
+			if err != nil {
+				t.Fatalf("failed to parse device: %v", err)
+			}
+			tt.mask.Apply(want)
+
+			var d wgtypes.Device
+			if err := parseDeviceInto(msgs, &d, tt.mask); err != nil {
+				t.Fatalf("failed to parse device: %v", err)
+			}
+
+			// Peers which were retrieved with no allowed IPs have empty
+			// rather than nil slices.
+			if diff := cmp.Diff(want, &d, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("unexpected device (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+// sockaddrPort interprets port as a big endian uint16 for use in the Port
+// field of raw sockaddr structures.
+func sockaddrPort(port int) uint16 {
+	return binary.BigEndian.Uint16(nlenc.Uint16Bytes(uint16(port)))
+}
@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Preopen opens a long-lived rtnetlink socket which is reused to discover
+// WireGuard interfaces, so that the Client opens no further sockets after
+// Preopen returns.
+func (c *Client) Preopen() error {
+	if c.rtnl != nil {
+		return nil
+	}
+
+	rc, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		return fmt.Errorf("wglinux: failed to open rtnetlink socket: %v", err)
+	}
+
+	c.rtnl = rc
+	c.interfaces = func() ([]string, error) {
+		return rtnlConnInterfaces(rc)
+	}
+
+	return nil
+}
+
+// rtnlConnInterfaces uses an existing rtnetlink connection to fetch a list of
+// WireGuard interfaces.
+func rtnlConnInterfaces(c *netlink.Conn) ([]string, error) {
+	msgs, err := c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  netlink.HeaderType(unix.RTM_GETLINK),
+			Flags: netlink.Request | netlink.Dump,
+		},
+		// An all-zero ifinfomsg requests links of any family.
+		Data: make([]byte, unix.SizeofIfInfomsg),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wglinux: failed to get list of interfaces from rtnetlink: %v", err)
+	}
+
+	// Reuse the parsing logic for the stdlib's message format.
+	smsgs := make([]syscall.NetlinkMessage, 0, len(msgs))
+	for _, m := range msgs {
+		smsgs = append(smsgs, syscall.NetlinkMessage{
+			Header: syscall.NlMsghdr{Type: uint16(m.Header.Type)},
+			Data:   m.Data,
+		})
+	}
+
+	return parseRTNLInterfaces(smsgs)
+}
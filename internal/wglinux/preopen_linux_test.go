@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package wglinux
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestLinux_rtnlConnInterfaces(t *testing.T) {
+	c, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		t.Skipf("skipping, failed to open rtnetlink socket: %v", err)
+	}
+	defer c.Close()
+
+	got, err := rtnlConnInterfaces(c)
+	if err != nil {
+		t.Fatalf("failed to get interfaces: %v", err)
+	}
+
+	// The long-lived socket must produce the same results as the stdlib.
+	want, err := rtnlInterfaces()
+	if err != nil {
+		t.Fatalf("failed to get interfaces from stdlib: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected interfaces (-want +got):\n%s", diff)
+	}
+}
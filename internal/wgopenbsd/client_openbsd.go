@@ -269,6 +269,7 @@ func parsePeer(pio *wgh.WGPeerIO) wgtypes.Peer {
 
 	if pio.Flags&wgh.WG_PEER_HAS_PSK != 0 {
 		p.PresharedKey = wgtypes.Key(pio.Psk)
+		p.HasPresharedKey = p.PresharedKey != wgtypes.Key{}
 	}
 
 	if pio.Flags&wgh.WG_PEER_HAS_PKA != 0 {
@@ -318,10 +319,10 @@ func parseEndpoint(ep [28]byte) *net.UDPAddr {
 	case unix.AF_INET6:
 		sa := *(*unix.RawSockaddrInet6)(unsafe.Pointer(&ep[0]))
 
-		// TODO(mdlayher): IPv6 zone?
 		ep := &net.UDPAddr{
 			IP:   make(net.IP, net.IPv6len),
 			Port: bePort(sa.Port),
+			Zone: wginternal.ZoneName(sa.Scope_id),
 		}
 		copy(ep.IP, sa.Addr[:])
 
@@ -0,0 +1,6 @@
+// Package wgsystemd implements the systemd socket activation protocol for
+// receiving file descriptors passed by a supervisor process.
+//
+// This package is internal-only and not meant for end users to consume.
+// Please use package wgctrl (an abstraction over this package) instead.
+package wgsystemd
@@ -0,0 +1,77 @@
+package wgsystemd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment variables used by the socket activation protocol, as described
+// in sd_listen_fds(3).
+const (
+	envPID     = "LISTEN_PID"
+	envFDs     = "LISTEN_FDS"
+	envFDNames = "LISTEN_FDNAMES"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// Files returns the files passed to this process using socket activation,
+// named using LISTEN_FDNAMES when present. If no files were passed, Files
+// returns nil. The socket activation environment variables are unset so that
+// they are not inherited by child processes.
+func Files() ([]*os.File, error) {
+	files, err := parse(os.Getenv, os.Getpid(), listenFDsStart)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range []string{envPID, envFDs, envFDNames} {
+		_ = os.Unsetenv(v)
+	}
+
+	return files, nil
+}
+
+// parse parses socket activation files beginning at file descriptor start
+// using the environment variable lookup function getenv for the process
+// specified by pid.
+func parse(getenv func(string) string, pid, start int) ([]*os.File, error) {
+	// The variables are only meant for this process if its PID matches.
+	spid := getenv(envPID)
+	if spid == "" {
+		return nil, nil
+	}
+	if p, err := strconv.Atoi(spid); err != nil || p != pid {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(getenv(envFDs))
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("wgsystemd: invalid %s value: %q", envFDs, getenv(envFDs))
+	}
+
+	var names []string
+	if s := getenv(envFDNames); s != "" {
+		names = strings.Split(s, ":")
+	}
+
+	files := make([]*os.File, 0, n)
+	for i := 0; i < n; i++ {
+		fd := start + i
+		if err := closeOnExec(fd); err != nil {
+			return nil, err
+		}
+
+		name := "LISTEN_FD_" + strconv.Itoa(fd)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		files = append(files, os.NewFile(uintptr(fd), name))
+	}
+
+	return files, nil
+}
@@ -0,0 +1,13 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package wgsystemd
+
+import (
+	"errors"
+)
+
+// closeOnExec is not supported on this platform.
+func closeOnExec(_ int) error {
+	return errors.New("wgsystemd: socket activation is not supported on this platform")
+}
@@ -0,0 +1,88 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package wgsystemd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+)
+
+func Test_parse(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		names []string
+		ok    bool
+	}{
+		{
+			name: "no environment",
+			ok:   true,
+		},
+		{
+			name: "other PID",
+			env: map[string]string{
+				envPID: "1",
+				envFDs: "1",
+			},
+			ok: true,
+		},
+		{
+			name: "bad FDs",
+			env: map[string]string{
+				envPID: "100",
+				envFDs: "foo",
+			},
+		},
+		{
+			name: "named",
+			env: map[string]string{
+				envPID:     "100",
+				envFDs:     "2",
+				envFDNames: "wg0:",
+			},
+			names: []string{"wg0", "LISTEN_FD_101"},
+			ok:    true,
+		},
+	}
+
+	// Use high-numbered duplicates of the null device in place of the file
+	// descriptors which would be passed by systemd.
+	const start = 100
+
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("failed to open null device: %v", err)
+	}
+	defer f.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := unix.Dup2(int(f.Fd()), start+i); err != nil {
+			t.Fatalf("failed to duplicate file descriptor: %v", err)
+		}
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parse(func(k string) string { return tt.env[k] }, 100, start)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			var names []string
+			for _, f := range files {
+				names = append(names, f.Name())
+			}
+
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Fatalf("unexpected file names (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
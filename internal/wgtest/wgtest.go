@@ -1,9 +1,10 @@
 package wgtest
 
 import (
-	"encoding/hex"
+	"encoding/binary"
 	"fmt"
 	"net"
+	"time"
 
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
@@ -20,16 +21,11 @@ func MustCIDR(s string) net.IPNet {
 
 // MustHexKey decodes a hex string s as a key or panics.
 func MustHexKey(s string) wgtypes.Key {
-	b, err := hex.DecodeString(s)
+	k, err := wgtypes.ParseKeyHex(s)
 	if err != nil {
 		panicf("wgtest: failed to decode hex key: %v", err)
 	}
 
-	k, err := wgtypes.NewKey(b)
-	if err != nil {
-		panicf("wgtest: failed to create key: %v", err)
-	}
-
 	return k
 }
 
@@ -68,6 +64,53 @@ func MustUDPAddr(s string) *net.UDPAddr {
 	return a
 }
 
+// SyntheticConfig produces a deterministic Config for a device with n peers,
+// for use in benchmarks. Each peer has a preshared key, an IPv4 endpoint, a
+// persistent keepalive interval, and one IPv4 and one IPv6 allowed IP.
+func SyntheticConfig(n int) wgtypes.Config {
+	var (
+		priv      = wgtypes.Key{0: 1}
+		port      = 51820
+		keepalive = 25 * time.Second
+	)
+
+	cfg := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		ReplacePeers: true,
+		Peers:        make([]wgtypes.PeerConfig, 0, n),
+	}
+
+	for i := 0; i < n; i++ {
+		// Keys and addresses are derived from the peer's index, which is
+		// much faster than generating keys.
+		var pub, psk wgtypes.Key
+		binary.BigEndian.PutUint32(pub[:], uint32(i))
+		pub[31] = 1
+		binary.BigEndian.PutUint32(psk[:], uint32(i))
+		psk[31] = 2
+
+		v4 := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i)).To4()
+		v6 := make(net.IP, net.IPv6len)
+		v6[0] = 0xfd
+		binary.BigEndian.PutUint32(v6[12:], uint32(i))
+
+		cfg.Peers = append(cfg.Peers, wgtypes.PeerConfig{
+			PublicKey:                   pub,
+			PresharedKey:                &psk,
+			Endpoint:                    &net.UDPAddr{IP: net.IPv4(192, 0, byte(i>>8), byte(i)).To4(), Port: 51820},
+			PersistentKeepaliveInterval: &keepalive,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs: []net.IPNet{
+				{IP: v4, Mask: net.CIDRMask(32, 32)},
+				{IP: v6, Mask: net.CIDRMask(128, 128)},
+			},
+		})
+	}
+
+	return cfg
+}
+
 func panicf(format string, a ...interface{}) {
 	panic(fmt.Sprintf(format, a...))
 }
@@ -0,0 +1,58 @@
+//go:build !race
+// +build !race
+
+package wguser
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+)
+
+// Allocation budgets per peer for the hot paths measured by benchmarks. If a
+// change exceeds a budget, either the regression should be fixed or the budget
+// raised deliberately. The race detector adds allocations, so the budgets are
+// only checked without it.
+const (
+	parseAllocsPerPeer = 28
+	writeAllocsPerPeer = 20
+)
+
+func TestAllocationBudgets(t *testing.T) {
+	const n = 1000
+
+	var (
+		res = syntheticGet(n)
+		cfg = wgtest.SyntheticConfig(n)
+	)
+
+	// Verify the synthetic response before measuring it.
+	d, err := parseDevice(bytes.NewReader(res))
+	if err != nil {
+		t.Fatalf("failed to parse device: %v", err)
+	}
+	if len(d.Peers) != n {
+		t.Fatalf("expected %d peers, but got: %d", n, len(d.Peers))
+	}
+
+	parse := testing.AllocsPerRun(5, func() {
+		if _, err := parseDevice(bytes.NewReader(res)); err != nil {
+			panicf("failed to parse device: %v", err)
+		}
+	})
+
+	write := testing.AllocsPerRun(5, func() {
+		writeConfig(io.Discard, cfg)
+	})
+
+	t.Logf("allocations per peer: parse: %.1f, write: %.1f", parse/n, write/n)
+
+	if max := float64(parseAllocsPerPeer * n); parse > max {
+		t.Errorf("parse allocations exceed budget: %.0f > %.0f", parse, max)
+	}
+	if max := float64(writeAllocsPerPeer * n); write > max {
+		t.Errorf("write allocations exceed budget: %.0f > %.0f", write, max)
+	}
+}
@@ -0,0 +1,55 @@
+package wguser
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+)
+
+// benchPeers are the numbers of peers used by benchmarks.
+var benchPeers = []int{1000, 10000, 100000}
+
+func BenchmarkParseDevice(b *testing.B) {
+	for _, n := range benchPeers {
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			res := syntheticGet(n)
+
+			b.SetBytes(int64(len(res)))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := parseDevice(bytes.NewReader(res)); err != nil {
+					b.Fatalf("failed to parse device: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkWriteConfig(b *testing.B) {
+	for _, n := range benchPeers {
+		b.Run(fmt.Sprintf("peers=%d", n), func(b *testing.B) {
+			cfg := wgtest.SyntheticConfig(n)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				writeConfig(io.Discard, cfg)
+			}
+		})
+	}
+}
+
+// syntheticGet produces a response to a get operation for a device with n
+// peers.
+func syntheticGet(n int) []byte {
+	var buf bytes.Buffer
+	writeConfig(&buf, wgtest.SyntheticConfig(n))
+	buf.WriteString("errno=0\n\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,25 @@
+package wguser
+
+import (
+	"errors"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+// errWatchRemoved is returned by a wginternal.Watcher when the watched
+// directory is removed.
+var errWatchRemoved = errors.New("wguser: watched directory was removed")
+
+// CacheDiscovery arranges for the Client to cache the list of userspace
+// devices, rescanning only when the operating system reports that the device
+// socket directory has changed. It has no effect if the platform cannot watch
+// the directory, or if the Client's discovery function has been replaced.
+func (c *Client) CacheDiscovery() error {
+	if c.watch == nil || c.cache != nil {
+		return nil
+	}
+
+	c.cache = wginternal.NewListCache(c.find, c.watch)
+	c.find = c.cache.List
+	return nil
+}
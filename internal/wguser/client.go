@@ -15,8 +15,17 @@ var _ wginternal.Client = &Client{}
 
 // A Client provides access to userspace WireGuard device information.
 type Client struct {
-	dial func(device string) (net.Conn, error)
-	find func() ([]string, error)
+	dial  func(device string) (net.Conn, error)
+	find  func() ([]string, error)
+	close func() error
+
+	// watch creates a Watcher for changes to the devices found by find, if
+	// supported, and cache caches the result of find, if set.
+	watch func() (wginternal.Watcher, error)
+	cache *wginternal.ListCache
+
+	// observe is an optional ParseObserver set by SetParseObserver.
+	observe wginternal.ParseObserver
 }
 
 // New creates a new Client.
@@ -25,16 +34,33 @@ func New() (*Client, error) {
 		// Operating system-specific functions which can identify and connect
 		// to userspace WireGuard devices. These functions can also be
 		// overridden for tests.
-		dial: dial,
-		find: find,
+		dial:  dial,
+		find:  find,
+		watch: watch,
 	}, nil
 }
 
 // Close implements wginternal.Client.
-func (c *Client) Close() error { return nil }
+func (c *Client) Close() error {
+	if c.cache != nil {
+		_ = c.cache.Close()
+	}
+
+	if c.close == nil {
+		return nil
+	}
+
+	return c.close()
+}
 
 // Devices implements wginternal.Client.
 func (c *Client) Devices() ([]*wgtypes.Device, error) {
+	return c.DevicesFields(wginternal.FieldAll)
+}
+
+// DevicesFields is like Devices, but only stores the fields of each device
+// selected by mask.
+func (c *Client) DevicesFields(mask wginternal.FieldMask) ([]*wgtypes.Device, error) {
 	devices, err := c.find()
 	if err != nil {
 		return nil, err
@@ -42,12 +68,12 @@ func (c *Client) Devices() ([]*wgtypes.Device, error) {
 
 	wgds := make([]*wgtypes.Device, 0, len(devices))
 	for _, d := range devices {
-		wgd, err := c.getDevice(d)
-		if err != nil {
+		var wgd wgtypes.Device
+		if err := c.getDeviceInto(d, &wgd, mask); err != nil {
 			return nil, err
 		}
 
-		wgds = append(wgds, wgd)
+		wgds = append(wgds, &wgd)
 	}
 
 	return wgds, nil
@@ -71,6 +97,32 @@ func (c *Client) Device(name string) (*wgtypes.Device, error) {
 	return nil, os.ErrNotExist
 }
 
+// DeviceInto is like Device, but stores the device in d, reusing the storage
+// of its existing Peers. If an error is returned, the contents of d are
+// unspecified.
+func (c *Client) DeviceInto(name string, d *wgtypes.Device) error {
+	return c.DeviceFieldsInto(name, d, wginternal.FieldAll)
+}
+
+// DeviceFieldsInto is like DeviceInto, but only stores the fields of the
+// device selected by mask.
+func (c *Client) DeviceFieldsInto(name string, d *wgtypes.Device, mask wginternal.FieldMask) error {
+	devices, err := c.find()
+	if err != nil {
+		return err
+	}
+
+	for _, dev := range devices {
+		if name != deviceName(dev) {
+			continue
+		}
+
+		return c.getDeviceInto(dev, d, mask)
+	}
+
+	return os.ErrNotExist
+}
+
 // ConfigureDevice implements wginternal.Client.
 func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
 	devices, err := c.find()
@@ -89,6 +141,24 @@ func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
 	return os.ErrNotExist
 }
 
+// PlanConfigureDevice describes the request which ConfigureDevice would send
+// to configure the device name, without sending it. Private and preshared
+// keys are hidden.
+func (c *Client) PlanConfigureDevice(name string, cfg wgtypes.Config) ([]string, error) {
+	devices, err := c.find()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range devices {
+		if name == deviceName(d) {
+			return []string{planConfig(cfg)}, nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
 // deviceName infers a device name from an absolute file path with extension.
 func deviceName(sock string) string {
 	return strings.TrimSuffix(filepath.Base(sock), filepath.Ext(sock))
@@ -2,10 +2,9 @@ package wguser
 
 import (
 	"bytes"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
@@ -29,30 +28,76 @@ func (c *Client) configureDevice(device string, cfg wgtypes.Config) error {
 
 	// Apply configuration for the device and then check the error number.
 	if _, err := io.Copy(conn, &buf); err != nil {
+		discardConn(conn)
 		return err
 	}
 
-	res := make([]byte, 32)
-	n, err := conn.Read(res)
+	line, err := readResponse(conn)
 	if err != nil {
+		discardConn(conn)
 		return err
 	}
 
 	// errno=0 indicates success, anything else returns an error number that
 	// matches definitions from errno.h.
-	str := strings.TrimSpace(string(res[:n]))
-	if str != "errno=0" {
-		// TODO(mdlayher): return actual errno on Linux?
-		return os.NewSyscallError("read", fmt.Errorf("wguser: %s", str))
+	return parseErrno("set", line)
+}
+
+// readResponse reads the errno line of a set response from r, and the empty
+// line which ends the response, so that no part of it is left unread on a
+// connection which is shared with later operations.
+func readResponse(r io.Reader) (string, error) {
+	// The response is read a byte at a time, as a buffered reader could
+	// consume data beyond the end of the response.
+	var (
+		line []byte
+		b    = make([]byte, 1)
+	)
+
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			if errors.Is(err, io.EOF) && len(line) > 0 {
+				// Tolerate devices which close the connection without
+				// ending the response.
+				return strings.TrimSpace(string(line)), nil
+			}
+
+			return "", err
+		}
+
+		line = append(line, b[0])
+		switch {
+		case bytes.HasSuffix(line, []byte("\n\n")):
+			return strings.TrimSpace(string(line)), nil
+		case len(line) > maxLineLength:
+			return "", fmt.Errorf("wguser: set response exceeds %d bytes", maxLineLength)
+		}
 	}
+}
 
-	return nil
+// planConfig returns the set operation which configureDevice would send to
+// apply cfg, with the values of any private and preshared keys hidden.
+func planConfig(cfg wgtypes.Config) string {
+	var buf bytes.Buffer
+	buf.WriteString("set=1\n")
+	writeConfig(&buf, cfg)
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, l := range lines {
+		for _, k := range []string{"private_key=", "preshared_key="} {
+			if strings.HasPrefix(l, k) {
+				lines[i] = k + "(hidden)\n"
+			}
+		}
+	}
+
+	return strings.Join(lines, "")
 }
 
 // writeConfig writes textual configuration to w as specified by cfg.
 func writeConfig(w io.Writer, cfg wgtypes.Config) {
 	if cfg.PrivateKey != nil {
-		fmt.Fprintf(w, "private_key=%s\n", hexKey(*cfg.PrivateKey))
+		fmt.Fprintf(w, "private_key=%s\n", cfg.PrivateKey.HexString())
 	}
 
 	if cfg.ListenPort != nil {
@@ -68,7 +113,7 @@ func writeConfig(w io.Writer, cfg wgtypes.Config) {
 	}
 
 	for _, p := range cfg.Peers {
-		fmt.Fprintf(w, "public_key=%s\n", hexKey(p.PublicKey))
+		fmt.Fprintf(w, "public_key=%s\n", p.PublicKey.HexString())
 
 		if p.Remove {
 			fmt.Fprintln(w, "remove=true")
@@ -79,7 +124,7 @@ func writeConfig(w io.Writer, cfg wgtypes.Config) {
 		}
 
 		if p.PresharedKey != nil {
-			fmt.Fprintf(w, "preshared_key=%s\n", hexKey(*p.PresharedKey))
+			fmt.Fprintf(w, "preshared_key=%s\n", p.PresharedKey.HexString())
 		}
 
 		if p.Endpoint != nil {
@@ -99,8 +144,3 @@ func writeConfig(w io.Writer, cfg wgtypes.Config) {
 		}
 	}
 }
-
-// hexKey encodes a wgtypes.Key into a hexadecimal string.
-func hexKey(k wgtypes.Key) string {
-	return hex.EncodeToString(k[:])
-}
@@ -158,3 +158,28 @@ func TestClientConfigureDeviceOK(t *testing.T) {
 		})
 	}
 }
+
+func Test_planConfig(t *testing.T) {
+	var (
+		priv = wgtest.MustHexKey("e84b5a6d2717c1003a13b431570353dbaca9146cf150c5f8575680feba52027a")
+		psk  = wgtest.MustHexKey("188515093e952f5f22e865cef3012e72f8b5f0b598ac0309d5dacce3b70fcf52")
+	)
+
+	cfg := wgtypes.Config{
+		PrivateKey: &priv,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:    wgtest.MustHexKey("b85996fecc9c7f1fc6d2572a76eda11d59bcd20be8e543b15ce4bd85a8e75a33"),
+			PresharedKey: &psk,
+		}},
+	}
+
+	const want = `set=1
+private_key=(hidden)
+public_key=b85996fecc9c7f1fc6d2572a76eda11d59bcd20be8e543b15ce4bd85a8e75a33
+preshared_key=(hidden)
+`
+
+	if got := planConfig(cfg); want != got {
+		t.Fatalf("unexpected plan:\nwant:\n%s\ngot:\n%s", want, got)
+	}
+}
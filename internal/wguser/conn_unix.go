@@ -16,13 +16,14 @@ func dial(device string) (net.Conn, error) {
 	return net.Dial("unix", device)
 }
 
+// socketDir is the directory which contains userspace device sockets. It
+// seems that /var/run is a common location between Linux and the BSDs, even
+// though it's a symlink on Linux.
+const socketDir = "/var/run/wireguard"
+
 // find is the default implementation of Client.find.
 func find() ([]string, error) {
-	return findUNIXSockets([]string{
-		// It seems that /var/run is a common location between Linux and the
-		// BSDs, even though it's a symlink on Linux.
-		"/var/run/wireguard",
-	})
+	return findUNIXSockets([]string{socketDir})
 }
 
 // findUNIXSockets looks for UNIX socket files in the specified directories.
@@ -0,0 +1,92 @@
+package wguser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// SetDialer replaces the Client's functions for discovering and connecting to
+// userspace devices. find returns a list of device names or paths, and dial
+// connects to a device using one of the values returned by find.
+func (c *Client) SetDialer(find func() ([]string, error), dial func(device string) (net.Conn, error)) {
+	c.find = find
+	c.dial = dial
+
+	// Devices found by find cannot be watched.
+	c.watch = nil
+}
+
+// UseAbstractSockets arranges for the Client to discover and connect to
+// userspace devices listening on Linux abstract namespace UNIX sockets whose
+// names begin with prefix, such as "wireguard/" for a device socket named
+// "@wireguard/wg0.sock".
+func (c *Client) UseAbstractSockets(prefix string) {
+	c.SetDialer(
+		func() ([]string, error) {
+			f, err := os.Open(procNetUnix)
+			if err != nil {
+				return nil, fmt.Errorf("wguser: abstract sockets are not supported on this system: %v", err)
+			}
+			defer f.Close()
+
+			return findAbstractSockets(f, prefix)
+		},
+		func(device string) (net.Conn, error) {
+			return net.Dial("unix", device)
+		},
+	)
+}
+
+// procNetUnix lists the UNIX sockets in the current network namespace.
+const procNetUnix = "/proc/net/unix"
+
+// soAcceptCon is the __SO_ACCEPTCON flag which indicates a listening socket
+// in procNetUnix.
+const soAcceptCon = 0x10000
+
+// findAbstractSockets parses the contents of procNetUnix from r and returns
+// the names of listening abstract sockets which begin with prefix, using the
+// "@" notation understood by package net.
+func findAbstractSockets(r io.Reader, prefix string) ([]string, error) {
+	var (
+		socks []string
+		seen  = make(map[string]bool)
+	)
+
+	s := bufio.NewScanner(r)
+	for first := true; s.Scan(); first = false {
+		// Skip the header line.
+		if first {
+			continue
+		}
+
+		// Fields: Num RefCount Protocol Flags Type St Inode [Path].
+		fields := strings.Fields(s.Text())
+		if len(fields) < 8 {
+			continue
+		}
+
+		var flags uint32
+		if _, err := fmt.Sscanf(fields[3], "%x", &flags); err != nil {
+			return nil, fmt.Errorf("wguser: invalid %s flags: %q", procNetUnix, fields[3])
+		}
+
+		path := fields[7]
+		if flags&soAcceptCon == 0 || !strings.HasPrefix(path, "@"+prefix) || seen[path] {
+			continue
+		}
+
+		seen[path] = true
+		socks = append(socks, path)
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return socks, nil
+}
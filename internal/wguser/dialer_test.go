@@ -0,0 +1,61 @@
+package wguser
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_findAbstractSockets(t *testing.T) {
+	const procNetUnix = `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 10001 @wireguard/wg0.sock
+0000000000000000: 00000002 00000000 00010000 0001 01 10002 @wireguard/wg0.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 10003 @wireguard/wg1.sock
+0000000000000000: 00000002 00000000 00010000 0001 01 10004 @other/wg2.sock
+0000000000000000: 00000002 00000000 00010000 0001 01 10005 /var/run/wireguard/wg3.sock
+0000000000000000: 00000003 00000000 00000000 0001 03 10006
+`
+
+	socks, err := findAbstractSockets(strings.NewReader(procNetUnix), "wireguard/")
+	if err != nil {
+		t.Fatalf("failed to find sockets: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"@wireguard/wg0.sock"}, socks); diff != "" {
+		t.Fatalf("unexpected sockets (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff("wg0", deviceName(socks[0])); diff != "" {
+		t.Fatalf("unexpected device name (-want +got):\n%s", diff)
+	}
+}
+
+func TestClientSetDialer(t *testing.T) {
+	errDial := errors.New("dial error")
+
+	var dialed string
+	c := &Client{}
+	c.SetDialer(
+		func() ([]string, error) { return []string{"wg0"}, nil },
+		func(device string) (net.Conn, error) {
+			dialed = device
+			return nil, errDial
+		},
+	)
+
+	if _, err := c.Device("wg1"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected is not exist error, but got: %v", err)
+	}
+
+	if _, err := c.Device("wg0"); !errors.Is(err, errDial) {
+		t.Fatalf("expected dial error, but got: %v", err)
+	}
+
+	if diff := cmp.Diff("wg0", dialed); diff != "" {
+		t.Fatalf("unexpected dialed device (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,42 @@
+package wguser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// errnoError produces a *wgtypes.UserspaceError for op from a non-zero errno
+// value reported by a device. wireguard-go reports negated error numbers while
+// other implementations report positive ones, so both are accepted.
+func errnoError(op string, errno int) error {
+	if errno < 0 {
+		errno = -errno
+	}
+
+	return &wgtypes.UserspaceError{
+		Op:    op,
+		Errno: errno,
+		Err:   errnoErr(errno),
+	}
+}
+
+// parseErrno parses an "errno=N" response line, returning nil if N is 0.
+func parseErrno(op, line string) error {
+	v, ok := strings.CutPrefix(line, "errno=")
+	if !ok {
+		return fmt.Errorf("wguser: unexpected %s response: %q", op, line)
+	}
+
+	errno, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("wguser: invalid errno in %s response: %q", op, line)
+	}
+	if errno == 0 {
+		return nil
+	}
+
+	return errnoError(op, errno)
+}
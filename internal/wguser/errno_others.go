@@ -0,0 +1,22 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris && !windows
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris,!windows
+
+package wguser
+
+import (
+	"errors"
+	"os"
+)
+
+// errnoErr maps a positive Linux error number to an error in the same way as
+// the kernel backends. Only the errors which identify a missing device are
+// mapped, since this platform has no errno.h of its own.
+func errnoErr(errno int) error {
+	switch errno {
+	case 19, 95: // ENODEV, EOPNOTSUPP
+		return os.ErrNotExist
+	default:
+		// The raw value is preserved by the caller.
+		return errors.New("unknown error")
+	}
+}
@@ -0,0 +1,105 @@
+package wguser
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientErrno(t *testing.T) {
+	tests := []struct {
+		name       string
+		res        []byte
+		errno      int
+		notExist   bool
+		permission bool
+	}{
+		{
+			name:     "negative ENODEV",
+			res:      []byte("errno=-19\n\n"),
+			errno:    19,
+			notExist: true,
+		},
+		{
+			name:       "positive EPERM",
+			res:        []byte("errno=1\n\n"),
+			errno:      1,
+			permission: true,
+		},
+		{
+			name:  "EINVAL",
+			res:   []byte("errno=-22\n\n"),
+			errno: 22,
+		},
+	}
+
+	ops := []struct {
+		op string
+		fn func(c *Client) error
+	}{
+		{
+			op: "get",
+			fn: func(c *Client) error {
+				_, err := c.Device(testDevice)
+				return err
+			},
+		},
+		{
+			op: "set",
+			fn: func(c *Client) error {
+				return c.ConfigureDevice(testDevice, wgtypes.Config{})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, op := range ops {
+			t.Run(tt.name+" "+op.op, func(t *testing.T) {
+				c, done := testClient(t, tt.res)
+				defer done()
+
+				err := op.fn(c)
+
+				var uerr *wgtypes.UserspaceError
+				if !errors.As(err, &uerr) {
+					t.Fatalf("expected UserspaceError, but got: %v", err)
+				}
+
+				want := struct {
+					Op                   string
+					Errno                int
+					NotExist, Permission bool
+				}{op.op, tt.errno, tt.notExist, tt.permission}
+
+				got := want
+				got.Op = uerr.Op
+				got.Errno = uerr.Errno
+				got.NotExist = errors.Is(err, os.ErrNotExist)
+				got.Permission = errors.Is(err, os.ErrPermission)
+
+				if diff := cmp.Diff(want, got); diff != "" {
+					t.Fatalf("unexpected error (-want +got):\n%s", diff)
+				}
+
+				t.Logf("OK error: %v", err)
+			})
+		}
+	}
+}
+
+func Test_parseErrnoInvalid(t *testing.T) {
+	for _, line := range []string{"", "errno", "errno=foo", "foo=0"} {
+		err := parseErrno("set", line)
+		if err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", line)
+		}
+
+		var uerr *wgtypes.UserspaceError
+		if errors.As(err, &uerr) {
+			t.Fatalf("expected generic error for %q, but got: %v", line, err)
+		}
+	}
+}
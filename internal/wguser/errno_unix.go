@@ -0,0 +1,25 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package wguser
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// errnoErr maps a positive error number from the host's errno.h to an error
+// in the same way as the kernel backends.
+func errnoErr(errno int) error {
+	switch err := unix.Errno(errno); err {
+	case unix.ENODEV, unix.ENOTSUP:
+		// Match the kernel backends' "no such device" and "not a WireGuard
+		// device" behavior.
+		return os.ErrNotExist
+	default:
+		// unix.Errno already implements errors.Is for os.ErrPermission and
+		// friends.
+		return err
+	}
+}
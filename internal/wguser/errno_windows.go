@@ -0,0 +1,37 @@
+//go:build windows
+// +build windows
+
+package wguser
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Userspace devices on Windows report error numbers using the Linux errno.h
+// definitions, so they are mapped to the syscall package's equivalent errors
+// by value.
+var linuxErrnos = map[int]error{
+	1:  syscall.EPERM,
+	5:  syscall.EIO,
+	13: syscall.EACCES,
+	19: os.ErrNotExist, // ENODEV
+	22: syscall.EINVAL,
+	55: syscall.ENOANO,
+	71: syscall.EPROTO,
+	95: os.ErrNotExist, // EOPNOTSUPP
+	98: syscall.EADDRINUSE,
+}
+
+// errnoErr maps a positive Linux error number to an error in the same way as
+// the kernel backends.
+func errnoErr(errno int) error {
+	if err, ok := linuxErrnos[errno]; ok {
+		return err
+	}
+
+	// The raw value is preserved by the caller, and must not be interpreted
+	// as a Windows error code.
+	return errors.New("unknown error")
+}
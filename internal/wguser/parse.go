@@ -3,14 +3,14 @@ package wguser
 import (
 	"bufio"
 	"bytes"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
-	"os"
 	"strconv"
 	"time"
 
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
@@ -20,35 +20,136 @@ import (
 // getDevice gathers device information from a device specified by its path
 // and returns a Device.
 func (c *Client) getDevice(device string) (*wgtypes.Device, error) {
+	var d wgtypes.Device
+	if err := c.getDeviceInto(device, &d, wginternal.FieldAll); err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// getDeviceInto is like getDevice, but stores the device in d, reusing the
+// storage of its existing Peers. Only the fields selected by mask are stored.
+func (c *Client) getDeviceInto(device string, d *wgtypes.Device, mask wginternal.FieldMask) error {
 	conn, err := c.dial(device)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer conn.Close()
 
 	// Get information about this device.
 	if _, err := io.WriteString(conn, "get=1\n\n"); err != nil {
-		return nil, err
+		return err
 	}
 
-	// Parse the device from the incoming data stream.
-	d, err := parseDevice(conn)
-	if err != nil {
-		return nil, err
+	// Parse the device from the incoming data stream, measuring the size of
+	// the response if requested.
+	var r io.Reader = conn
+	if c.observe != nil {
+		cr := &countingReader{r: conn}
+		start := time.Now()
+		defer func() { c.observe(cr.n, time.Since(start)) }()
+		r = cr
+	}
+
+	if _, err := parseDeviceInto(r, d, mask); err != nil {
+		// The parser stops at the first error, which may leave the remainder
+		// of the response unread.
+		discardConn(conn)
+		return err
 	}
 
 	// TODO(mdlayher): populate interface index too?
 	d.Name = deviceName(device)
 	d.Type = wgtypes.Userspace
 
-	return d, nil
+	return nil
 }
 
+// SetParseObserver sets a function which is called each time the Client
+// parses a device. The reported duration includes the time spent reading the
+// device's response.
+func (c *Client) SetParseObserver(fn wginternal.ParseObserver) {
+	c.observe = fn
+}
+
+// A countingReader counts the number of bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (cr *countingReader) Read(b []byte) (int, error) {
+	n, err := cr.r.Read(b)
+	cr.n += n
+	return n, err
+}
+
+// Limits which prevent a malicious or buggy device from causing unbounded
+// memory consumption while its response is parsed.
+const (
+	// maxLineLength is the maximum length of a single key=value line. The
+	// longest legitimate lines are IPv6 endpoints with zones, which are far
+	// shorter.
+	maxLineLength = 512
+
+	// maxPeers matches the Linux kernel's MAX_PEERS_PER_DEVICE.
+	maxPeers = 1 << 20
+
+	// maxAllowedIPs is the maximum number of allowed IPs across all peers.
+	maxAllowedIPs = 1 << 22
+)
+
 // parseDevice parses a Device and its Peers from an io.Reader.
 func parseDevice(r io.Reader) (*wgtypes.Device, error) {
-	var dp deviceParser
+	return parseDeviceInto(r, nil, wginternal.FieldAll)
+}
+
+// parseDeviceInto is like parseDevice, but parses into d if it is not nil,
+// and only stores the fields selected by mask.
+func parseDeviceInto(r io.Reader, d *wgtypes.Device, mask wginternal.FieldMask) (*wgtypes.Device, error) {
+	dp := deviceParser{
+		d:             d,
+		mask:          mask,
+		maxPeers:      maxPeers,
+		maxAllowedIPs: maxAllowedIPs,
+	}
+
+	return dp.parse(r)
+}
+
+// A deviceParser accumulates information about a Device and its Peers.
+type deviceParser struct {
+	// d is the Device to parse into, reusing the storage of its Peers. If
+	// nil, a new Device is allocated.
+	d   *wgtypes.Device
+	err error
+
+	// mask selects the fields which are stored in d. Others are skipped
+	// without parsing.
+	mask wginternal.FieldMask
+
+	parsePeers    bool
+	peers         int
+	allowedIPs    int
+	hsSec, hsNano int
+
+	maxPeers, maxAllowedIPs int
+}
+
+// parse streams key=value lines from r into the Device until an empty line
+// is reached, stopping early at the first error.
+func (dp *deviceParser) parse(r io.Reader) (*wgtypes.Device, error) {
+	if dp.d == nil {
+		dp.d = new(wgtypes.Device)
+	} else {
+		*dp.d = wgtypes.Device{Peers: dp.d.Peers[:0]}
+	}
+
 	s := bufio.NewScanner(r)
-	for s.Scan() {
+	s.Buffer(make([]byte, 0, 128), maxLineLength)
+
+	for dp.err == nil && s.Scan() {
 		b := s.Bytes()
 		if len(b) == 0 {
 			// Empty line, done parsing.
@@ -56,31 +157,25 @@ func parseDevice(r io.Reader) (*wgtypes.Device, error) {
 		}
 
 		// All data is in key=value format.
-		kvs := bytes.Split(b, []byte("="))
-		if len(kvs) != 2 {
+		k, v, ok := bytes.Cut(b, []byte("="))
+		if !ok || bytes.IndexByte(v, '=') != -1 {
 			return nil, fmt.Errorf("wguser: invalid key=value pair: %q", string(b))
 		}
 
-		dp.Parse(string(kvs[0]), string(kvs[1]))
+		dp.Parse(string(k), string(v))
 	}
 
 	if err := s.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("wguser: response line exceeds %d bytes", maxLineLength)
+		}
+
 		return nil, err
 	}
 
 	return dp.Device()
 }
 
-// A deviceParser accumulates information about a Device and its Peers.
-type deviceParser struct {
-	d   wgtypes.Device
-	err error
-
-	parsePeers    bool
-	peers         int
-	hsSec, hsNano int
-}
-
 // Device returns a Device or any errors that were encountered while parsing
 // a Device.
 func (dp *deviceParser) Device() (*wgtypes.Device, error) {
@@ -89,9 +184,17 @@ func (dp *deviceParser) Device() (*wgtypes.Device, error) {
 	}
 
 	// Compute remaining fields of the Device now that all parsing is done.
-	dp.d.PublicKey = dp.d.PrivateKey.PublicKey()
+	if dp.mask&wginternal.FieldDevice != 0 {
+		dp.d.PublicKey = dp.d.PrivateKey.PublicKey()
+	}
 
-	return &dp.d, nil
+	// The userspace protocol always sends the private key, which is only
+	// used to compute the public key if secrets were not requested.
+	if dp.mask&wginternal.FieldSecrets == 0 {
+		dp.d.PrivateKey = wgtypes.Key{}
+	}
+
+	return dp.d, nil
 }
 
 // Parse parses a single key/value pair into fields of a Device.
@@ -101,30 +204,51 @@ func (dp *deviceParser) Parse(key, value string) {
 		// 0 indicates success, anything else returns an error number that matches
 		// definitions from errno.h.
 		if errno := dp.parseInt(value); errno != 0 {
-			// TODO(mdlayher): return actual errno on Linux?
-			dp.err = os.NewSyscallError("read", fmt.Errorf("wguser: errno=%d", errno))
+			dp.err = errnoError("get", errno)
 			return
 		}
 	case "public_key":
 		// We've either found the first peer or the next peer.  Stop parsing
 		// Device fields and start parsing Peer fields, including the public
 		// key indicated here.
+		if dp.peers == dp.maxPeers {
+			dp.err = fmt.Errorf("wguser: device has more than %d peers", dp.maxPeers)
+			return
+		}
+
 		dp.parsePeers = true
+		if !dp.mask.Peers() {
+			return
+		}
+
 		dp.peers++
 
+		// Reuse the storage of a previous Peer's allowed IPs, if any.
+		var ipns []net.IPNet
+		if n := len(dp.d.Peers); n < cap(dp.d.Peers) {
+			ipns = dp.d.Peers[:n+1][n].AllowedIPs[:0]
+		}
+
 		dp.d.Peers = append(dp.d.Peers, wgtypes.Peer{
-			PublicKey: dp.parseKey(value),
+			PublicKey:  dp.parseKey(value),
+			AllowedIPs: ipns,
 		})
 		return
 	}
 
 	// Are we parsing peer fields?
 	if dp.parsePeers {
-		dp.peerParse(key, value)
+		if dp.mask.Peers() {
+			dp.peerParse(key, value)
+		}
 		return
 	}
 
 	// Device field parsing.
+	if dp.mask&wginternal.FieldDevice == 0 {
+		return
+	}
+
 	switch key {
 	case "private_key":
 		dp.d.PrivateKey = dp.parseKey(value)
@@ -142,10 +266,18 @@ func (dp *deviceParser) curPeer() *wgtypes.Peer {
 
 // peerParse parses a key/value field into the current Peer.
 func (dp *deviceParser) peerParse(key, value string) {
+	if !dp.wantPeerKey(key) {
+		return
+	}
+
 	p := dp.curPeer()
 	switch key {
 	case "preshared_key":
-		p.PresharedKey = dp.parseKey(value)
+		k := dp.parseKey(value)
+		p.HasPresharedKey = k != wgtypes.Key{}
+		if dp.mask&wginternal.FieldSecrets != 0 {
+			p.PresharedKey = k
+		}
 	case "endpoint":
 		p.Endpoint = dp.parseAddr(value)
 	case "last_handshake_time_sec":
@@ -167,6 +299,12 @@ func (dp *deviceParser) peerParse(key, value string) {
 	case "persistent_keepalive_interval":
 		p.PersistentKeepaliveInterval = time.Duration(dp.parseInt(value)) * time.Second
 	case "allowed_ip":
+		if dp.allowedIPs == dp.maxAllowedIPs {
+			dp.err = fmt.Errorf("wguser: device has more than %d allowed IPs", dp.maxAllowedIPs)
+			return
+		}
+		dp.allowedIPs++
+
 		cidr := dp.parseCIDR(value)
 		if cidr != nil {
 			p.AllowedIPs = append(p.AllowedIPs, *cidr)
@@ -176,19 +314,27 @@ func (dp *deviceParser) peerParse(key, value string) {
 	}
 }
 
+// wantPeerKey reports whether the peer field key is selected by the mask.
+func (dp *deviceParser) wantPeerKey(key string) bool {
+	switch key {
+	case "preshared_key", "endpoint", "persistent_keepalive_interval", "protocol_version":
+		return dp.mask&wginternal.FieldPeers != 0
+	case "last_handshake_time_sec", "last_handshake_time_nsec", "tx_bytes", "rx_bytes":
+		return dp.mask&wginternal.FieldCounters != 0
+	case "allowed_ip":
+		return dp.mask&wginternal.FieldAllowedIPs != 0
+	default:
+		return true
+	}
+}
+
 // parseKey parses a Key from a hex string.
 func (dp *deviceParser) parseKey(s string) wgtypes.Key {
 	if dp.err != nil {
 		return wgtypes.Key{}
 	}
 
-	b, err := hex.DecodeString(s)
-	if err != nil {
-		dp.err = err
-		return wgtypes.Key{}
-	}
-
-	key, err := wgtypes.NewKey(b)
+	key, err := wgtypes.ParseKeyHex(s)
 	if err != nil {
 		dp.err = err
 		return wgtypes.Key{}
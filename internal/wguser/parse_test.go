@@ -2,10 +2,12 @@ package wguser
 
 import (
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
@@ -79,6 +81,10 @@ func TestClientDevices(t *testing.T) {
 			name: "error",
 			res:  []byte("errno=2\n\n"),
 		},
+		{
+			name: "line too long",
+			res:  []byte("listen_port=" + strings.Repeat("1", maxLineLength) + "\n\n"),
+		},
 		{
 			name: "ok",
 			res:  []byte(okGet),
@@ -91,8 +97,9 @@ func TestClientDevices(t *testing.T) {
 				FirewallMark: 1,
 				Peers: []wgtypes.Peer{
 					{
-						PublicKey:    wgtypes.Key{0xb8, 0x59, 0x96, 0xfe, 0xcc, 0x9c, 0x7f, 0x1f, 0xc6, 0xd2, 0x57, 0x2a, 0x76, 0xed, 0xa1, 0x1d, 0x59, 0xbc, 0xd2, 0xb, 0xe8, 0xe5, 0x43, 0xb1, 0x5c, 0xe4, 0xbd, 0x85, 0xa8, 0xe7, 0x5a, 0x33},
-						PresharedKey: wgtypes.Key{0x18, 0x85, 0x15, 0x9, 0x3e, 0x95, 0x2f, 0x5f, 0x22, 0xe8, 0x65, 0xce, 0xf3, 0x1, 0x2e, 0x72, 0xf8, 0xb5, 0xf0, 0xb5, 0x98, 0xac, 0x3, 0x9, 0xd5, 0xda, 0xcc, 0xe3, 0xb7, 0xf, 0xcf, 0x52},
+						PublicKey:       wgtypes.Key{0xb8, 0x59, 0x96, 0xfe, 0xcc, 0x9c, 0x7f, 0x1f, 0xc6, 0xd2, 0x57, 0x2a, 0x76, 0xed, 0xa1, 0x1d, 0x59, 0xbc, 0xd2, 0xb, 0xe8, 0xe5, 0x43, 0xb1, 0x5c, 0xe4, 0xbd, 0x85, 0xa8, 0xe7, 0x5a, 0x33},
+						HasPresharedKey: true,
+						PresharedKey:    wgtypes.Key{0x18, 0x85, 0x15, 0x9, 0x3e, 0x95, 0x2f, 0x5f, 0x22, 0xe8, 0x65, 0xce, 0xf3, 0x1, 0x2e, 0x72, 0xf8, 0xb5, 0xf0, 0xb5, 0x98, 0xac, 0x3, 0x9, 0xd5, 0xda, 0xcc, 0xe3, 0xb7, 0xf, 0xcf, 0x52},
 						Endpoint: &net.UDPAddr{
 							IP:   net.ParseIP("abcd:23::33"),
 							Port: 51820,
@@ -174,3 +181,84 @@ func TestClientDevices(t *testing.T) {
 		})
 	}
 }
+
+func Test_deviceParserLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		dp   deviceParser
+	}{
+		{
+			name: "peers",
+			dp:   deviceParser{mask: wginternal.FieldAll, maxPeers: 2, maxAllowedIPs: maxAllowedIPs},
+		},
+		{
+			name: "allowed IPs",
+			dp:   deviceParser{mask: wginternal.FieldAll, maxPeers: maxPeers, maxAllowedIPs: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.dp.parse(strings.NewReader(okGet))
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func Test_parseDeviceIntoFieldMask(t *testing.T) {
+	tests := []struct {
+		name string
+		mask wginternal.FieldMask
+	}{
+		{name: "all", mask: wginternal.FieldAll},
+		{name: "device", mask: wginternal.FieldDevice},
+		{name: "peers", mask: wginternal.FieldAll &^ wginternal.FieldDevice},
+		{name: "counters", mask: wginternal.FieldCounters},
+		{name: "no allowed IPs", mask: wginternal.FieldAll &^ wginternal.FieldAllowedIPs},
+		{name: "no secrets", mask: wginternal.FieldAll &^ wginternal.FieldSecrets},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want, err := parseDevice(strings.NewReader(okGet))
+			if err != nil {
+				t.Fatalf("failed to parse device: %v", err)
+			}
+			tt.mask.Apply(want)
+
+			d, err := parseDeviceInto(strings.NewReader(okGet), nil, tt.mask)
+			if err != nil {
+				t.Fatalf("failed to parse device: %v", err)
+			}
+
+			if diff := cmp.Diff(want, d); diff != "" {
+				t.Fatalf("unexpected device (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientSetParseObserver(t *testing.T) {
+	res := []byte("listen_port=51820\nerrno=0\n\n")
+
+	c, done := testClient(t, res)
+	defer done()
+
+	var n, calls int
+	c.SetParseObserver(func(bytes int, _ time.Duration) {
+		n += bytes
+		calls++
+	})
+
+	if _, err := c.Device(testDevice); err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+
+	if diff := cmp.Diff([]int{1, len(res)}, []int{calls, n}); diff != "" {
+		t.Fatalf("unexpected calls and bytes (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,178 @@
+package wguser
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+)
+
+// Preopen discovers and dials all userspace devices which currently exist,
+// and arranges for all future operations to reuse those connections rather
+// than accessing the filesystem or dialing new connections.
+//
+// Devices created after Preopen is called are not visible to the Client.
+func (c *Client) Preopen() error {
+	devices, err := c.find()
+	if err != nil {
+		return err
+	}
+
+	conns := make(map[string]net.Conn, len(devices))
+	for _, d := range devices {
+		conn, err := c.dial(d)
+		if err != nil {
+			closeAll(conns)
+			return err
+		}
+
+		conns[d] = conn
+	}
+
+	c.useConns(conns, c.dial)
+	return nil
+}
+
+// UseFiles arranges for the Client to operate only on the userspace devices
+// connected to by each of files, such as sockets passed by a supervisor
+// process. The device name for each file is inferred from its Name, which may
+// be either a bare device name or the path of the device's socket.
+//
+// Each file is duplicated, so the caller remains responsible for closing
+// files. No filesystem access occurs after UseFiles returns. If a device sends
+// a malformed response, its connection is closed and cannot be replaced, so
+// later operations on the device fail.
+func (c *Client) UseFiles(files []*os.File) error {
+	conns := make(map[string]net.Conn, len(files))
+	for _, f := range files {
+		conn, err := net.FileConn(f)
+		if err != nil {
+			closeAll(conns)
+			return err
+		}
+
+		conns[f.Name()] = conn
+	}
+
+	c.useConns(conns, nil)
+	return nil
+}
+
+// useConns replaces the Client's discovery and dialing functions with ones
+// which reuse the long-lived connections in conns, keyed by device path. If
+// redial is not nil, it replaces connections which were discarded after an
+// error.
+func (c *Client) useConns(conns map[string]net.Conn, redial func(device string) (net.Conn, error)) {
+	devices := make([]string, 0, len(conns))
+	shared := make(map[string]*sharedConn, len(conns))
+	for d, conn := range conns {
+		devices = append(devices, d)
+		shared[d] = &sharedConn{conn: conn}
+	}
+
+	c.find = func() ([]string, error) {
+		return devices, nil
+	}
+	c.watch = nil
+
+	c.dial = func(device string) (net.Conn, error) {
+		sc, ok := shared[device]
+		if !ok {
+			return nil, &net.OpError{Op: "dial", Net: "unix", Err: errNotPreopened}
+		}
+
+		// Serialize operations on the shared connection until the caller
+		// closes its handle.
+		sc.mu.Lock()
+		if sc.conn == nil {
+			if redial == nil {
+				sc.mu.Unlock()
+				return nil, &net.OpError{Op: "dial", Net: "unix", Err: errDiscarded}
+			}
+
+			conn, err := redial(device)
+			if err != nil {
+				sc.mu.Unlock()
+				return nil, err
+			}
+
+			sc.conn = conn
+		}
+
+		return &connHandle{Conn: sc.conn, sc: sc}, nil
+	}
+
+	c.close = func() error {
+		var err error
+		for _, sc := range shared {
+			sc.mu.Lock()
+			if sc.conn != nil {
+				if cerr := sc.conn.Close(); cerr != nil && err == nil {
+					err = cerr
+				}
+				sc.conn = nil
+			}
+			sc.mu.Unlock()
+		}
+
+		return err
+	}
+}
+
+// closeAll closes all of conns, returning the first error encountered.
+func closeAll(conns map[string]net.Conn) error {
+	var err error
+	for _, conn := range conns {
+		if cerr := conn.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// Errors returned when dialing a device whose connection cannot be used.
+var (
+	errNotPreopened = errors.New("wguser: device was not preopened")
+	errDiscarded    = errors.New("wguser: device connection was closed after a malformed response")
+)
+
+// A sharedConn is a long-lived connection to a userspace device which is
+// shared by all operations on that device.
+type sharedConn struct {
+	mu sync.Mutex
+
+	// conn is nil if the connection was discarded.
+	conn net.Conn
+}
+
+// A connHandle is an exclusive handle to a sharedConn for the duration of a
+// single operation. Closing a connHandle releases the sharedConn for use by
+// other operations rather than closing it.
+type connHandle struct {
+	net.Conn
+	sc   *sharedConn
+	once sync.Once
+}
+
+// Close releases the underlying shared connection.
+func (ch *connHandle) Close() error {
+	ch.once.Do(ch.sc.mu.Unlock)
+	return nil
+}
+
+// discard closes the underlying shared connection, so that a response which
+// was not read in full cannot be mistaken for the response to a later
+// operation. It must be called before Close.
+func (ch *connHandle) discard() {
+	_ = ch.Conn.Close()
+	ch.sc.conn = nil
+}
+
+// discardConn discards conn if it is shared with other operations, after a
+// failed operation may have left part of a response unread.
+func discardConn(conn net.Conn) {
+	if ch, ok := conn.(*connHandle); ok {
+		ch.discard()
+	}
+}
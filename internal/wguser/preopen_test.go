@@ -0,0 +1,191 @@
+package wguser
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientPreopen(t *testing.T) {
+	l, dir, done := testListen(t, testDevice)
+	defer done()
+
+	// Serve any number of get requests on a single connection, as
+	// wireguard-go does.
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		br := bufio.NewReader(c)
+		for {
+			if _, err := br.ReadString('\n'); err != nil {
+				return
+			}
+			if _, err := br.ReadString('\n'); err != nil {
+				return
+			}
+
+			if _, err := io.WriteString(c, "listen_port=1\nerrno=0\n\n"); err != nil {
+				return
+			}
+		}
+	}()
+
+	c := &Client{
+		find: testFind(dir),
+		dial: dial,
+	}
+	defer c.Close()
+
+	if err := c.Preopen(); err != nil {
+		t.Fatalf("failed to preopen: %v", err)
+	}
+
+	// Once preopened, the device must remain accessible without the
+	// filesystem.
+	_ = os.RemoveAll(dir)
+
+	for i := 0; i < 3; i++ {
+		d, err := c.Device(testDevice)
+		if err != nil {
+			t.Fatalf("failed to get device: %v", err)
+		}
+
+		want := &wgtypes.Device{
+			Name:       testDevice,
+			Type:       wgtypes.Userspace,
+			ListenPort: 1,
+			PublicKey:  wgtypes.Key{}.PublicKey(),
+		}
+
+		if diff := cmp.Diff(want, d); diff != "" {
+			t.Fatalf("unexpected Device (-want +got):\n%s", diff)
+		}
+	}
+}
+
+func TestClientPreopenRedial(t *testing.T) {
+	l, dir, done := testListen(t, testDevice)
+	defer done()
+
+	// The first response ends early with an error, leaving its final empty
+	// line unread, and each connection after the first serves the device.
+	go func() {
+		for first := true; ; first = false {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(first bool) {
+				defer c.Close()
+
+				br := bufio.NewReader(c)
+				for {
+					if _, err := br.ReadString('\n'); err != nil {
+						return
+					}
+					if _, err := br.ReadString('\n'); err != nil {
+						return
+					}
+
+					res := "listen_port=1\nerrno=0\n\n"
+					if first {
+						res = "errno=-5\n\n"
+					}
+					if _, err := io.WriteString(c, res); err != nil {
+						return
+					}
+				}
+			}(first)
+		}
+	}()
+
+	c := &Client{
+		find: testFind(dir),
+		dial: dial,
+	}
+	defer c.Close()
+
+	if err := c.Preopen(); err != nil {
+		t.Fatalf("failed to preopen: %v", err)
+	}
+
+	if _, err := c.Device(testDevice); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	// The connection with the unread response is replaced rather than
+	// reused.
+	d, err := c.Device(testDevice)
+	if err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+
+	if diff := cmp.Diff(1, d.ListenPort); diff != "" {
+		t.Fatalf("unexpected listen port (-want +got):\n%s", diff)
+	}
+}
+
+func TestClientUseFiles(t *testing.T) {
+	l, _, done := testListen(t, testDevice)
+	defer done()
+
+	ul, ok := l.(*net.UnixListener)
+	if !ok {
+		t.Skip("skipping, files are only supported for UNIX sockets")
+	}
+
+	go func() {
+		c, err := ul.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		// Consume the get request and respond with a device.
+		br := bufio.NewReader(c)
+		_, _ = br.ReadString('\n')
+		_, _ = br.ReadString('\n')
+		_, _ = io.WriteString(c, "listen_port=2\nerrno=0\n\n")
+	}()
+
+	// A supervisor would typically pass this connection to another process.
+	conn, err := net.Dial("unix", ul.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	f, err := conn.(*net.UnixConn).File()
+	if err != nil {
+		t.Fatalf("failed to get file: %v", err)
+	}
+	defer f.Close()
+
+	c := &Client{}
+	defer c.Close()
+
+	// The file's name includes the socket path, so the device name can be
+	// inferred as usual.
+	if err := c.UseFiles([]*os.File{f}); err != nil {
+		t.Fatalf("failed to use files: %v", err)
+	}
+
+	d, err := c.Device(testDevice)
+	if err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+
+	if diff := cmp.Diff(2, d.ListenPort); diff != "" {
+		t.Fatalf("unexpected listen port (-want +got):\n%s", diff)
+	}
+}
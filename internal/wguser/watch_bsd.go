@@ -0,0 +1,82 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package wguser
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+// watch is the default implementation of Client.watch.
+func watch() (wginternal.Watcher, error) {
+	return watchDir(socketDir)
+}
+
+// A kqueueWatcher is a wginternal.Watcher which uses kqueue to detect changes
+// to the entries of a directory.
+type kqueueWatcher struct {
+	kq, fd int
+	events []unix.Kevent_t
+}
+
+// watchDir creates a wginternal.Watcher for the entries of dir.
+func watchDir(dir string) (wginternal.Watcher, error) {
+	fd, err := unix.Open(dir, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: dir, Err: err}
+	}
+
+	kq, err := unix.Kqueue()
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, os.NewSyscallError("kqueue", err)
+	}
+
+	var ev unix.Kevent_t
+	unix.SetKevent(&ev, fd, unix.EVFILT_VNODE, unix.EV_ADD|unix.EV_CLEAR)
+	ev.Fflags = unix.NOTE_WRITE | unix.NOTE_DELETE | unix.NOTE_RENAME
+
+	if _, err := unix.Kevent(kq, []unix.Kevent_t{ev}, nil, nil); err != nil {
+		_ = unix.Close(kq)
+		_ = unix.Close(fd)
+		return nil, os.NewSyscallError("kevent", err)
+	}
+
+	return &kqueueWatcher{
+		kq:     kq,
+		fd:     fd,
+		events: make([]unix.Kevent_t, 8),
+	}, nil
+}
+
+// Changed implements wginternal.Watcher.
+func (w *kqueueWatcher) Changed() (bool, error) {
+	for {
+		// A zero timeout polls for events without blocking.
+		n, err := unix.Kevent(w.kq, nil, w.events, &unix.Timespec{})
+		switch err {
+		case nil:
+		case unix.EINTR:
+			continue
+		default:
+			return true, os.NewSyscallError("kevent", err)
+		}
+
+		for _, ev := range w.events[:n] {
+			if ev.Fflags&(unix.NOTE_DELETE|unix.NOTE_RENAME) != 0 {
+				return true, errWatchRemoved
+			}
+		}
+
+		return n > 0, nil
+	}
+}
+
+// Close implements wginternal.Watcher.
+func (w *kqueueWatcher) Close() error {
+	_ = unix.Close(w.fd)
+	return unix.Close(w.kq)
+}
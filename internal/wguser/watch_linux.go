@@ -0,0 +1,77 @@
+//go:build linux
+// +build linux
+
+package wguser
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+// watch is the default implementation of Client.watch.
+func watch() (wginternal.Watcher, error) {
+	return watchDir(socketDir)
+}
+
+// An inotifyWatcher is a wginternal.Watcher which uses inotify to detect
+// changes to the entries of a directory.
+type inotifyWatcher struct {
+	fd  int
+	buf []byte
+}
+
+// watchDir creates a wginternal.Watcher for the entries of dir.
+func watchDir(dir string) (wginternal.Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_NONBLOCK | unix.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+
+	const mask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO |
+		unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+	if _, err := unix.InotifyAddWatch(fd, dir, mask); err != nil {
+		_ = unix.Close(fd)
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+
+	return &inotifyWatcher{
+		fd:  fd,
+		buf: make([]byte, 4096),
+	}, nil
+}
+
+// Changed implements wginternal.Watcher.
+func (w *inotifyWatcher) Changed() (bool, error) {
+	var changed bool
+	for {
+		n, err := unix.Read(w.fd, w.buf)
+		switch err {
+		case nil:
+		case unix.EAGAIN:
+			return changed, nil
+		case unix.EINTR:
+			continue
+		default:
+			return true, os.NewSyscallError("read", err)
+		}
+
+		changed = true
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&w.buf[off]))
+			if ev.Mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF|unix.IN_IGNORED) != 0 {
+				return true, errWatchRemoved
+			}
+
+			off += unix.SizeofInotifyEvent + int(ev.Len)
+		}
+	}
+}
+
+// Close implements wginternal.Watcher.
+func (w *inotifyWatcher) Close() error {
+	return unix.Close(w.fd)
+}
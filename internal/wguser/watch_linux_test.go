@@ -0,0 +1,63 @@
+//go:build linux
+// +build linux
+
+package wguser
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinux_watchDir(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := watchDir(dir)
+	if err != nil {
+		t.Fatalf("failed to watch directory: %v", err)
+	}
+	defer w.Close()
+
+	changed := func(want bool) {
+		t.Helper()
+
+		got, err := w.Changed()
+		if err != nil {
+			t.Fatalf("failed to check for changes: %v", err)
+		}
+		if want != got {
+			t.Fatalf("unexpected change: want %v, got %v", want, got)
+		}
+	}
+
+	changed(false)
+
+	l, err := net.Listen("unix", filepath.Join(dir, "wg0.sock"))
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	changed(true)
+	changed(false)
+
+	// Closing the listener removes the socket file.
+	_ = l.Close()
+	changed(true)
+
+	if err := os.Remove(dir); err != nil {
+		t.Fatalf("failed to remove directory: %v", err)
+	}
+
+	if _, err := w.Changed(); !errors.Is(err, errWatchRemoved) {
+		t.Fatalf("expected removed directory error, but got: %v", err)
+	}
+}
+
+func TestLinux_watchDirNotExist(t *testing.T) {
+	_, err := watchDir(filepath.Join(t.TempDir(), "wireguard"))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected does not exist error, but got: %v", err)
+	}
+}
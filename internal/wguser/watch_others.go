@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package wguser
+
+import "golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+
+// watch is the default implementation of Client.watch. Changes to userspace
+// devices cannot be watched on this platform.
+var watch func() (wginternal.Watcher, error)
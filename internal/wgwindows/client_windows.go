@@ -1,6 +1,7 @@
 package wgwindows
 
 import (
+	"fmt"
 	"net"
 	"os"
 	"time"
@@ -187,9 +188,14 @@ func (c *Client) Device(name string) (*wgtypes.Device, error) {
 		}
 		if p.Flags&ioctl.PeerHasPresharedKey != 0 {
 			peer.PresharedKey = p.PresharedKey
+			peer.HasPresharedKey = peer.PresharedKey != wgtypes.Key{}
 		}
 		if p.Flags&ioctl.PeerHasEndpoint != 0 {
-			peer.Endpoint = &net.UDPAddr{IP: p.Endpoint.IP(), Port: int(p.Endpoint.Port())}
+			peer.Endpoint = &net.UDPAddr{
+				IP:   p.Endpoint.IP(),
+				Port: int(p.Endpoint.Port()),
+				Zone: wginternal.ZoneName(p.Endpoint.ScopeID()),
+			}
 		}
 		if p.Flags&ioctl.PeerHasPersistentKeepalive != 0 {
 			peer.PersistentKeepaliveInterval = time.Duration(p.PersistentKeepalive) * time.Second
@@ -275,8 +281,15 @@ func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
 			peer.PresharedKey = *cfg.Peers[i].PresharedKey
 		}
 		if cfg.Peers[i].Endpoint != nil {
+			// Link-local endpoints must carry their zone as a scope ID.
+			scope, err := wginternal.ZoneIndex(cfg.Peers[i].Endpoint.Zone)
+			if err != nil {
+				return fmt.Errorf("wgwindows: invalid endpoint: %v", err)
+			}
+
 			peer.Flags |= ioctl.PeerHasEndpoint
 			peer.Endpoint.SetIP(cfg.Peers[i].Endpoint.IP, uint16(cfg.Peers[i].Endpoint.Port))
+			peer.Endpoint.SetScopeID(scope)
 		}
 		if cfg.Peers[i].PersistentKeepaliveInterval != nil {
 			peer.Flags |= ioctl.PeerHasPersistentKeepalive
@@ -0,0 +1,74 @@
+package wgwindows
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// gaaFlagIncludeAllInterfaces is GAA_FLAG_INCLUDE_ALL_INTERFACES, which
+// includes adapters which are not bound to an address family.
+const gaaFlagIncludeAllInterfaces = 0x100
+
+// InterfaceDescription returns the description of the network adapter whose
+// alias is name.
+func InterfaceDescription(name string) (string, error) {
+	// Start with the recommended 15KiB buffer, and grow it as requested.
+	size := uint32(15 * 1024)
+	for {
+		b := make([]byte, size)
+		aa := (*windows.IpAdapterAddresses)(unsafe.Pointer(&b[0]))
+
+		err := windows.GetAdaptersAddresses(windows.AF_UNSPEC, gaaFlagIncludeAllInterfaces, 0, aa, &size)
+		if errors.Is(err, windows.ERROR_BUFFER_OVERFLOW) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		for ; aa != nil; aa = aa.Next {
+			if windows.UTF16PtrToString(aa.FriendlyName) == name {
+				return windows.UTF16PtrToString(aa.Description), nil
+			}
+		}
+
+		return "", os.ErrNotExist
+	}
+}
+
+// SetInterfaceDescription sets the description of the WireGuardNT adapter
+// name, by setting the friendly name of its device.
+func SetInterfaceDescription(name, description string) error {
+	if description == "" {
+		return errors.New("wgwindows: interface description must not be empty")
+	}
+
+	devInfo, err := windows.SetupDiGetClassDevsEx(&deviceClassNetGUID, enumerator, 0, windows.DIGCF_PRESENT, 0, "")
+	if err != nil {
+		return err
+	}
+	defer windows.SetupDiDestroyDeviceInfoList(devInfo)
+
+	for i := 0; ; i++ {
+		devInfoData, err := windows.SetupDiEnumDeviceInfo(devInfo, i)
+		if err != nil {
+			if err == windows.ERROR_NO_MORE_ITEMS {
+				return os.ErrNotExist
+			}
+			continue
+		}
+
+		prop, err := windows.SetupDiGetDeviceProperty(devInfo, devInfoData, &devpkeyWgName)
+		if err != nil {
+			continue
+		}
+		if adapterName, ok := prop.(string); !ok || adapterName != name {
+			continue
+		}
+
+		return devInfo.SetDeviceRegistryPropertyString(devInfoData, windows.SPDRP_FRIENDLYNAME, description)
+	}
+}
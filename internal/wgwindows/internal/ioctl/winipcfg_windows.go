@@ -73,6 +73,23 @@ func (addr *RawSockaddrInet) IP() net.IP {
 	return nil
 }
 
+// SetScopeID sets the scope ID of an IPv6 address. It has no effect on other
+// address families.
+func (addr *RawSockaddrInet) SetScopeID(id uint32) {
+	if addr.Family == windows.AF_INET6 {
+		(*windows.RawSockaddrInet6)(unsafe.Pointer(addr)).Scope_id = id
+	}
+}
+
+// ScopeID returns the scope ID if the address is IPv6, or 0 otherwise.
+func (addr *RawSockaddrInet) ScopeID() uint32 {
+	if addr.Family == windows.AF_INET6 {
+		return (*windows.RawSockaddrInet6)(unsafe.Pointer(addr)).Scope_id
+	}
+
+	return 0
+}
+
 // Port returns the port if the address if IPv4 or IPv6, or 0 if neither.
 func (addr *RawSockaddrInet) Port() uint16 {
 	switch addr.Family {
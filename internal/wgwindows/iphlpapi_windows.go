@@ -0,0 +1,347 @@
+package wgwindows
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgwindows/internal/ioctl"
+)
+
+var (
+	modiphlpapi = windows.NewLazySystemDLL("iphlpapi.dll")
+
+	procConvertInterfaceAliasToLuid     = modiphlpapi.NewProc("ConvertInterfaceAliasToLuid")
+	procInitializeIpInterfaceEntry      = modiphlpapi.NewProc("InitializeIpInterfaceEntry")
+	procGetIpInterfaceEntry             = modiphlpapi.NewProc("GetIpInterfaceEntry")
+	procSetIpInterfaceEntry             = modiphlpapi.NewProc("SetIpInterfaceEntry")
+	procInitializeUnicastIpAddressEntry = modiphlpapi.NewProc("InitializeUnicastIpAddressEntry")
+	procGetUnicastIpAddressTable        = modiphlpapi.NewProc("GetUnicastIpAddressTable")
+	procCreateUnicastIpAddressEntry     = modiphlpapi.NewProc("CreateUnicastIpAddressEntry")
+	procDeleteUnicastIpAddressEntry     = modiphlpapi.NewProc("DeleteUnicastIpAddressEntry")
+	procInitializeIpForwardEntry        = modiphlpapi.NewProc("InitializeIpForwardEntry")
+	procGetIpForwardTable2              = modiphlpapi.NewProc("GetIpForwardTable2")
+	procCreateIpForwardEntry2           = modiphlpapi.NewProc("CreateIpForwardEntry2")
+	procDeleteIpForwardEntry2           = modiphlpapi.NewProc("DeleteIpForwardEntry2")
+	procFreeMibTable                    = modiphlpapi.NewProc("FreeMibTable")
+)
+
+// Constants from the Windows SDK's netioapi.h and nldef.h.
+const (
+	ipDadStatePreferred = 4
+	mibIPProtoNetMgmt   = 3
+)
+
+// mibIPInterfaceRow is MIB_IPINTERFACE_ROW.
+type mibIPInterfaceRow struct {
+	Family                               uint16
+	_                                    [6]byte
+	InterfaceLUID                        uint64
+	InterfaceIndex                       uint32
+	MaxReassemblySize                    uint32
+	InterfaceIdentifier                  uint64
+	MinRouterAdvertisementInterval       uint32
+	MaxRouterAdvertisementInterval       uint32
+	AdvertisingEnabled                   bool
+	ForwardingEnabled                    bool
+	WeakHostSend                         bool
+	WeakHostReceive                      bool
+	UseAutomaticMetric                   bool
+	UseNeighborUnreachabilityDetection   bool
+	ManagedAddressConfigurationSupported bool
+	OtherStatefulConfigurationSupported  bool
+	AdvertiseDefaultRoute                bool
+	_                                    [3]byte
+	RouterDiscoveryBehavior              int32
+	DadTransmits                         uint32
+	BaseReachableTime                    uint32
+	RetransmitTime                       uint32
+	PathMTUDiscoveryTimeout              uint32
+	LinkLocalAddressBehavior             int32
+	LinkLocalAddressTimeout              uint32
+	ZoneIndices                          [16]uint32
+	SitePrefixLength                     uint32
+	Metric                               uint32
+	NLMTU                                uint32
+	Connected                            bool
+	SupportsWakeUpPatterns               bool
+	SupportsNeighborDiscovery            bool
+	SupportsRouterDiscovery              bool
+	ReachableTime                        uint32
+	TransmitOffload                      uint8
+	ReceiveOffload                       uint8
+	DisableDefaultRoutes                 bool
+	_                                    [1]byte
+}
+
+// mibUnicastIPAddressRow is MIB_UNICASTIPADDRESS_ROW.
+type mibUnicastIPAddressRow struct {
+	Address            ioctl.RawSockaddrInet
+	_                  [4]byte
+	InterfaceLUID      uint64
+	InterfaceIndex     uint32
+	PrefixOrigin       uint32
+	SuffixOrigin       uint32
+	ValidLifetime      uint32
+	PreferredLifetime  uint32
+	OnLinkPrefixLength uint8
+	SkipAsSource       bool
+	_                  [2]byte
+	DadState           uint32
+	ScopeID            uint32
+	CreationTimeStamp  int64
+}
+
+// ipAddressPrefix is IP_ADDRESS_PREFIX.
+type ipAddressPrefix struct {
+	Prefix       ioctl.RawSockaddrInet
+	PrefixLength uint8
+	_            [3]byte
+}
+
+// mibIPForwardRow2 is MIB_IPFORWARD_ROW2.
+type mibIPForwardRow2 struct {
+	InterfaceLUID        uint64
+	InterfaceIndex       uint32
+	DestinationPrefix    ipAddressPrefix
+	NextHop              ioctl.RawSockaddrInet
+	SitePrefixLength     uint8
+	_                    [3]byte
+	ValidLifetime        uint32
+	PreferredLifetime    uint32
+	Metric               uint32
+	Protocol             uint32
+	Loopback             bool
+	AutoconfigureAddress bool
+	Publish              bool
+	Immortal             bool
+	Age                  uint32
+	Origin               uint32
+}
+
+// Fail to compile if the layouts above do not match the Windows SDK's on
+// both 32-bit and 64-bit platforms.
+var (
+	_ = [1]struct{}{}[unsafe.Sizeof(mibIPInterfaceRow{})-168]
+	_ = [1]struct{}{}[unsafe.Sizeof(mibUnicastIPAddressRow{})-80]
+	_ = [1]struct{}{}[unsafe.Sizeof(mibIPForwardRow2{})-104]
+)
+
+// mibTableOffset is the offset of the first row of a MIB_*_TABLE2, after its
+// NumEntries field and the padding which aligns the rows.
+const mibTableOffset = 8
+
+// ConfigureInterface configures the network interface of the WireGuard device
+// name using the IP Helper API. Its MTU is set to mtu if not 0, its unicast
+// addresses are replaced by addrs, and the static routes through it are
+// replaced by routes.
+func ConfigureInterface(name string, addrs []net.IPNet, mtu int, routes []net.IPNet) error {
+	luid, err := interfaceLUID(name)
+	if err != nil {
+		return err
+	}
+
+	if mtu != 0 {
+		if err := setMTU(luid, mtu); err != nil {
+			return fmt.Errorf("wgwindows: failed to set MTU: %w", err)
+		}
+	}
+
+	if err := setAddresses(luid, addrs); err != nil {
+		return fmt.Errorf("wgwindows: failed to set addresses: %w", err)
+	}
+
+	if err := setRoutes(luid, routes); err != nil {
+		return fmt.Errorf("wgwindows: failed to set routes: %w", err)
+	}
+
+	return nil
+}
+
+// interfaceLUID returns the LUID of the interface with the alias name.
+func interfaceLUID(name string) (uint64, error) {
+	alias, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	var luid uint64
+	if err := call(procConvertInterfaceAliasToLuid, uintptr(unsafe.Pointer(alias)), uintptr(unsafe.Pointer(&luid))); err != nil {
+		return 0, fmt.Errorf("wgwindows: interface %q: %w", name, os.ErrNotExist)
+	}
+
+	return luid, nil
+}
+
+// setMTU sets the MTU of the interface luid for each enabled address family.
+func setMTU(luid uint64, mtu int) error {
+	for _, family := range []uint16{windows.AF_INET, windows.AF_INET6} {
+		// IPv6 requires an MTU of at least 1280.
+		if family == windows.AF_INET6 && mtu < 1280 {
+			continue
+		}
+
+		var row mibIPInterfaceRow
+		_, _, _ = procInitializeIpInterfaceEntry.Call(uintptr(unsafe.Pointer(&row)))
+		row.Family = family
+		row.InterfaceLUID = luid
+
+		err := call(procGetIpInterfaceEntry, uintptr(unsafe.Pointer(&row)))
+		if errors.Is(err, windows.ERROR_NOT_FOUND) {
+			// The address family is disabled on this interface.
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		row.NLMTU = uint32(mtu)
+		if family == windows.AF_INET {
+			// SetIpInterfaceEntry requires this for IPv4.
+			row.SitePrefixLength = 0
+		}
+
+		if err := call(procSetIpInterfaceEntry, uintptr(unsafe.Pointer(&row))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setAddresses replaces the unicast addresses of the interface luid with
+// addrs.
+func setAddresses(luid uint64, addrs []net.IPNet) error {
+	want := make(map[string]net.IPNet)
+	for _, a := range addrs {
+		want[a.String()] = a
+	}
+
+	var table unsafe.Pointer
+	if err := call(procGetUnicastIpAddressTable, windows.AF_UNSPEC, uintptr(unsafe.Pointer(&table))); err != nil {
+		return err
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	n := *(*uint32)(table)
+	rows := unsafe.Slice((*mibUnicastIPAddressRow)(unsafe.Add(table, mibTableOffset)), n)
+	for i := range rows {
+		r := &rows[i]
+		if r.InterfaceLUID != luid {
+			continue
+		}
+
+		ip := net.IPNet{IP: r.Address.IP(), Mask: net.CIDRMask(int(r.OnLinkPrefixLength), 8*len(r.Address.IP()))}
+		if _, ok := want[ip.String()]; ok {
+			delete(want, ip.String())
+			continue
+		}
+
+		if err := call(procDeleteUnicastIpAddressEntry, uintptr(unsafe.Pointer(r))); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range addrs {
+		if _, ok := want[a.String()]; !ok {
+			continue
+		}
+
+		var row mibUnicastIPAddressRow
+		_, _, _ = procInitializeUnicastIpAddressEntry.Call(uintptr(unsafe.Pointer(&row)))
+		if err := row.Address.SetIP(a.IP, 0); err != nil {
+			return err
+		}
+
+		ones, _ := a.Mask.Size()
+		row.InterfaceLUID = luid
+		row.OnLinkPrefixLength = uint8(ones)
+		row.DadState = ipDadStatePreferred
+
+		if err := call(procCreateUnicastIpAddressEntry, uintptr(unsafe.Pointer(&row))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setRoutes replaces the static routes through the interface luid with
+// routes. Routes which Windows creates for the interface's own addresses are
+// left alone.
+func setRoutes(luid uint64, routes []net.IPNet) error {
+	want := make(map[string]net.IPNet)
+	for _, r := range routes {
+		r = net.IPNet{IP: r.IP.Mask(r.Mask), Mask: r.Mask}
+		want[r.String()] = r
+	}
+
+	var table unsafe.Pointer
+	if err := call(procGetIpForwardTable2, windows.AF_UNSPEC, uintptr(unsafe.Pointer(&table))); err != nil {
+		return err
+	}
+	defer procFreeMibTable.Call(uintptr(table))
+
+	n := *(*uint32)(table)
+	rows := unsafe.Slice((*mibIPForwardRow2)(unsafe.Add(table, mibTableOffset)), n)
+	for i := range rows {
+		r := &rows[i]
+		if r.InterfaceLUID != luid || r.Protocol != mibIPProtoNetMgmt {
+			continue
+		}
+
+		ip := r.DestinationPrefix.Prefix.IP()
+		dst := net.IPNet{IP: ip, Mask: net.CIDRMask(int(r.DestinationPrefix.PrefixLength), 8*len(ip))}
+		if _, ok := want[dst.String()]; ok {
+			delete(want, dst.String())
+			continue
+		}
+
+		if err := call(procDeleteIpForwardEntry2, uintptr(unsafe.Pointer(r))); err != nil {
+			return err
+		}
+	}
+
+	for _, dst := range want {
+		var row mibIPForwardRow2
+		_, _, _ = procInitializeIpForwardEntry.Call(uintptr(unsafe.Pointer(&row)))
+		if err := row.DestinationPrefix.Prefix.SetIP(dst.IP, 0); err != nil {
+			return err
+		}
+
+		// WireGuard interfaces are point-to-point, so routes are on-link.
+		nextHop := net.IPv6zero
+		if dst.IP.To4() != nil {
+			nextHop = net.IPv4zero
+		}
+		if err := row.NextHop.SetIP(nextHop, 0); err != nil {
+			return err
+		}
+
+		ones, _ := dst.Mask.Size()
+		row.InterfaceLUID = luid
+		row.DestinationPrefix.PrefixLength = uint8(ones)
+		row.Metric = 0
+
+		err := call(procCreateIpForwardEntry2, uintptr(unsafe.Pointer(&row)))
+		if err != nil && !errors.Is(err, windows.ERROR_OBJECT_ALREADY_EXISTS) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// call calls an IP Helper function which returns a Windows error code.
+func call(p *windows.LazyProc, args ...uintptr) error {
+	r, _, _ := p.Call(args...)
+	if r != 0 {
+		return windows.Errno(r)
+	}
+
+	return nil
+}
@@ -0,0 +1,233 @@
+package wgctrl
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Journal records each configuration applied to a device, so that the
+// configurations can be re-applied using Client.Replay, for example after a
+// reboot and before an orchestrator is able to reconfigure the device. Use
+// WithJournal to set a Client's Journal.
+type Journal interface {
+	// Record records that cfg was applied to device.
+	Record(device string, cfg wgtypes.Config) error
+
+	// Configs returns the configurations recorded for device, in the order
+	// in which they were applied.
+	Configs(device string) ([]wgtypes.Config, error)
+}
+
+// JournalConfig contains options for a FileJournal.
+type JournalConfig struct {
+	// Key, if not nil, is used to encrypt each entry in the journal using
+	// NaCl secretbox, so that key material is not stored in plaintext.
+	Key *[32]byte
+
+	// Redact removes private and preshared keys from each configuration
+	// before it is recorded. When a redacted journal is replayed, the
+	// device's existing keys are left unchanged.
+	Redact bool
+}
+
+// A FileJournal is a Journal which appends entries to a file.
+type FileJournal struct {
+	mu     sync.Mutex
+	f      *os.File
+	key    *[32]byte
+	redact bool
+}
+
+var _ Journal = &FileJournal{}
+
+// OpenFileJournal opens the journal stored in the file at path, creating it if
+// necessary. If cfg is nil, a default configuration is used, and entries are
+// stored unencrypted.
+func OpenFileJournal(path string, cfg *JournalConfig) (*FileJournal, error) {
+	if cfg == nil {
+		cfg = &JournalConfig{}
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileJournal{
+		f:      f,
+		key:    cfg.Key,
+		redact: cfg.Redact,
+	}, nil
+}
+
+// Close closes the journal's file.
+func (j *FileJournal) Close() error { return j.f.Close() }
+
+// A journalEntry is a single entry in a FileJournal.
+type journalEntry struct {
+	Time   time.Time      `json:"time"`
+	Device string         `json:"device"`
+	Config wgtypes.Config `json:"config"`
+}
+
+// Record implements Journal. Each entry is synced to stable storage before
+// Record returns.
+func (j *FileJournal) Record(device string, cfg wgtypes.Config) error {
+	if j.redact {
+		cfg = redactConfig(cfg)
+	}
+
+	b, err := json.Marshal(journalEntry{
+		Time:   time.Now(),
+		Device: device,
+		Config: cfg,
+	})
+	if err != nil {
+		return err
+	}
+
+	if j.key != nil {
+		var nonce [24]byte
+		if _, err := rand.Read(nonce[:]); err != nil {
+			return err
+		}
+
+		box := secretbox.Seal(nonce[:], b, &nonce, j.key)
+		b = []byte(base64.StdEncoding.EncodeToString(box))
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	return j.f.Sync()
+}
+
+// Configs implements Journal.
+func (j *FileJournal) Configs(device string) ([]wgtypes.Config, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var cfgs []wgtypes.Config
+	s := bufio.NewScanner(j.f)
+	s.Buffer(nil, 64<<20)
+	for n := 1; s.Scan(); n++ {
+		b := bytes.TrimSpace(s.Bytes())
+		if len(b) == 0 {
+			continue
+		}
+
+		e, err := j.parseEntry(b)
+		if err != nil {
+			return nil, fmt.Errorf("wgctrl: journal entry %d: %w", n, err)
+		}
+
+		if e.Device == device {
+			cfgs = append(cfgs, e.Config)
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return cfgs, nil
+}
+
+// errJournalDecrypt is returned when a journal entry cannot be decrypted.
+var errJournalDecrypt = errors.New("failed to decrypt entry")
+
+// parseEntry parses a single line of the journal.
+func (j *FileJournal) parseEntry(b []byte) (*journalEntry, error) {
+	if j.key != nil {
+		box, err := base64.StdEncoding.DecodeString(string(b))
+		if err != nil || len(box) < 24 {
+			return nil, errJournalDecrypt
+		}
+
+		var nonce [24]byte
+		copy(nonce[:], box)
+
+		out, ok := secretbox.Open(nil, box[24:], &nonce, j.key)
+		if !ok {
+			return nil, errJournalDecrypt
+		}
+
+		b = out
+	}
+
+	var e journalEntry
+	if err := json.Unmarshal(b, &e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// redactConfig returns a copy of cfg with any private and preshared keys
+// removed.
+func redactConfig(cfg wgtypes.Config) wgtypes.Config {
+	cfg.PrivateKey = nil
+
+	peers := make([]wgtypes.PeerConfig, len(cfg.Peers))
+	copy(peers, cfg.Peers)
+	for i := range peers {
+		peers[i].PresharedKey = nil
+	}
+
+	if cfg.Peers != nil {
+		cfg.Peers = peers
+	}
+
+	return cfg
+}
+
+// Replay re-applies each configuration recorded in the Client's Journal for
+// the device specified by name, in the order they were originally applied,
+// restoring the device's last known configuration. Configurations which are
+// replayed are not recorded in the Journal again.
+//
+// Replay returns an error if the Client has no Journal.
+func (c *Client) Replay(name string) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	if c.journal == nil {
+		return errors.New("wgctrl: Client has no Journal")
+	}
+
+	cfgs, err := c.journal.Configs(name)
+	if err != nil {
+		return err
+	}
+
+	return c.withDeviceLock(name, func() error {
+		for i, cfg := range cfgs {
+			if err := c.configureDevice(name, cfg, false); err != nil {
+				return fmt.Errorf("wgctrl: failed to replay configuration %d: %w", i, err)
+			}
+		}
+
+		return nil
+	})
+}
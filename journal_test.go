@@ -0,0 +1,152 @@
+package wgctrl
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientJournalReplay(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		psk  = wgtest.MustPresharedKey()
+		port = 51820
+		key  = [32]byte{1}
+
+		cfgs = []wgtypes.Config{
+			{
+				PrivateKey: &priv,
+				ListenPort: &port,
+			},
+			{
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:    wgtest.MustPublicKey(),
+					PresharedKey: &psk,
+					AllowedIPs:   []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")},
+				}},
+			},
+		}
+	)
+
+	tests := []struct {
+		name string
+		cfg  *JournalConfig
+		want []wgtypes.Config
+	}{
+		{
+			name: "plaintext",
+			want: cfgs,
+		},
+		{
+			name: "redacted",
+			cfg:  &JournalConfig{Redact: true},
+			want: []wgtypes.Config{
+				{ListenPort: &port},
+				{Peers: []wgtypes.PeerConfig{{
+					PublicKey:  cfgs[1].Peers[0].PublicKey,
+					AllowedIPs: cfgs[1].Peers[0].AllowedIPs,
+				}}},
+			},
+		},
+		{
+			name: "encrypted",
+			cfg:  &JournalConfig{Key: &key},
+			want: cfgs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "journal")
+			j, err := OpenFileJournal(path, tt.cfg)
+			if err != nil {
+				t.Fatalf("failed to open journal: %v", err)
+			}
+			defer j.Close()
+
+			var applied []wgtypes.Config
+			c := &Client{
+				cs: []wginternal.Client{&testClient{
+					ConfigureDeviceFunc: func(name string, cfg wgtypes.Config) error {
+						if name != "wg0" {
+							return os.ErrNotExist
+						}
+
+						applied = append(applied, cfg)
+						return nil
+					},
+				}},
+				journal: j,
+			}
+
+			for _, cfg := range cfgs {
+				if err := c.ConfigureDevice("wg0", cfg); err != nil {
+					t.Fatalf("failed to configure device: %v", err)
+				}
+			}
+
+			// Failed configurations are not recorded.
+			if err := c.ConfigureDevice("wg1", cfgs[0]); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			if tt.cfg != nil {
+				b, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("failed to read journal: %v", err)
+				}
+
+				if bytes.Contains(b, []byte(`"PrivateKey":[`)) {
+					t.Fatalf("journal contains a private key:\n%s", b)
+				}
+			}
+
+			// Replay the journal as if after a reboot, which must not record
+			// the configurations again.
+			applied = nil
+			if err := c.Replay("wg0"); err != nil {
+				t.Fatalf("failed to replay: %v", err)
+			}
+			if err := c.Replay("wg0"); err != nil {
+				t.Fatalf("failed to replay: %v", err)
+			}
+
+			if diff := cmp.Diff(append(tt.want, tt.want...), applied); diff != "" {
+				t.Fatalf("unexpected replayed configurations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFileJournalWrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j, err := OpenFileJournal(path, &JournalConfig{Key: &[32]byte{1}})
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	defer j.Close()
+
+	if err := j.Record("wg0", wgtypes.Config{}); err != nil {
+		t.Fatalf("failed to record: %v", err)
+	}
+
+	j2, err := OpenFileJournal(path, &JournalConfig{Key: &[32]byte{2}})
+	if err != nil {
+		t.Fatalf("failed to open journal: %v", err)
+	}
+	defer j2.Close()
+
+	if _, err := j2.Configs("wg0"); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	} else {
+		t.Logf("OK error: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package wgctrl
+
+import "errors"
+
+// A KernelDriver identifies the origin of a kernel WireGuard implementation.
+type KernelDriver int
+
+// Possible KernelDriver values.
+const (
+	// KernelDriverUnknown indicates that the origin of the implementation
+	// could not be determined, or that none is available.
+	KernelDriverUnknown KernelDriver = iota
+
+	// KernelDriverBuiltIn indicates an implementation built into the kernel.
+	KernelDriverBuiltIn
+
+	// KernelDriverInTree indicates a loadable module distributed with the
+	// kernel.
+	KernelDriverInTree
+
+	// KernelDriverOutOfTree indicates a loadable module built outside of the
+	// kernel tree, such as the wireguard-linux-compat DKMS module.
+	KernelDriverOutOfTree
+)
+
+// String returns the string representation of a KernelDriver.
+func (kd KernelDriver) String() string {
+	switch kd {
+	case KernelDriverBuiltIn:
+		return "built-in"
+	case KernelDriverInTree:
+		return "in-tree module"
+	case KernelDriverOutOfTree:
+		return "out-of-tree module"
+	default:
+		return "unknown"
+	}
+}
+
+// KernelInfo describes the availability of a kernel WireGuard implementation,
+// as reported by KernelSupport.
+type KernelInfo struct {
+	// Available reports whether the kernel implementation can be used to
+	// control WireGuard devices.
+	Available bool
+
+	// Driver identifies the origin of the kernel implementation.
+	Driver KernelDriver
+
+	// ModuleVersion is the version reported by the kernel module, if any.
+	ModuleVersion string
+
+	// FamilyVersion is the version of the WireGuard generic netlink family,
+	// if Available.
+	FamilyVersion int
+}
+
+// errKernelSupportUnsupported is returned by KernelSupport on platforms where
+// detection is not implemented.
+var errKernelSupportUnsupported = errors.New("wgctrl: KernelSupport is only supported on Linux")
+
+// KernelSupport reports whether a kernel WireGuard implementation is
+// available, and if so, the origin and version of its driver. This enables
+// installers to diagnose a missing or outdated kernel module precisely,
+// rather than guessing based on errors returned by a Client.
+//
+// KernelSupport is currently only supported on Linux. Elevated privileges are
+// not required.
+func KernelSupport() (*KernelInfo, error) {
+	return kernelSupport()
+}
@@ -0,0 +1,164 @@
+package wgctrl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A DumpLimit identifies a limit in DumpLimits.
+type DumpLimit int
+
+// Possible DumpLimit values.
+const (
+	LimitPeers    = DumpLimit(wginternal.LimitPeers)
+	LimitBytes    = DumpLimit(wginternal.LimitBytes)
+	LimitDeadline = DumpLimit(wginternal.LimitDeadline)
+)
+
+// String returns the string representation of a DumpLimit.
+func (l DumpLimit) String() string { return wginternal.DumpLimit(l).String() }
+
+// DumpLimits bounds the retrieval of a device by Client.DeviceLimited, so
+// that a very large or adversarial peer table cannot stall or exhaust the
+// memory of a management daemon. Each zero field is unlimited.
+type DumpLimits struct {
+	// MaxPeers is the maximum number of peers retrieved.
+	MaxPeers int
+
+	// MaxBytes is the maximum number of bytes of device information read
+	// from the backend. It is only enforced on Linux, where devices are
+	// retrieved using netlink.
+	MaxBytes int
+
+	// Timeout is the maximum duration of the retrieval. On Linux, it
+	// interrupts the retrieval; other backends can only report that it was
+	// exceeded once the device has been retrieved.
+	Timeout time.Duration
+
+	// Partial specifies that a TruncatedError should carry the part of the
+	// device which was retrieved before a limit was exceeded.
+	Partial bool
+}
+
+// A TruncatedError is returned by Client.DeviceLimited when the retrieval of
+// a device exceeded one of its DumpLimits.
+type TruncatedError struct {
+	Device string
+	Limit  DumpLimit
+
+	// Partial is the part of the device which was retrieved, if partial
+	// results were requested. At most MaxPeers of its Peers are retained,
+	// and the allowed IPs of its final Peer may be incomplete.
+	Partial *wgtypes.Device
+}
+
+// Error implements error.
+func (e *TruncatedError) Error() string {
+	return fmt.Sprintf("wgctrl: retrieval of device %q truncated: %s", e.Device, e.Limit)
+}
+
+// DeviceLimited is like Device, but stops retrieving the device when it
+// exceeds l, in which case a *TruncatedError is returned.
+func (c *Client) DeviceLimited(name string, l DumpLimits) (d *wgtypes.Device, err error) {
+	span := c.startSpan("DeviceLimited")
+	span.SetString(traceDevice, name)
+	defer func() {
+		if d != nil {
+			span.SetInt(tracePeers, len(d.Peers))
+		}
+		span.End(err)
+	}()
+
+	il := wginternal.DumpLimits{
+		MaxPeers: l.MaxPeers,
+		MaxBytes: l.MaxBytes,
+	}
+	if l.Timeout > 0 {
+		il.Deadline = time.Now().Add(l.Timeout)
+	}
+
+	if err := c.limit(rateDump, name); err != nil {
+		return nil, err
+	}
+
+	if err := c.checkDuplicate(name); err != nil {
+		return nil, err
+	}
+
+	for _, wgc := range c.backendsFor(name) {
+		d := new(wgtypes.Device)
+		err := deviceLimitedInto(wgc, name, d, il)
+
+		var terr *wginternal.TruncatedError
+		switch {
+		case err == nil:
+			span.SetString(traceBackend, backendName(wgc))
+			c.pin(name, wgc)
+			c.finishDevice(d)
+			return d, nil
+		case errors.As(err, &terr):
+			span.SetString(traceBackend, backendName(wgc))
+			c.pin(name, wgc)
+
+			te := &TruncatedError{Device: name, Limit: DumpLimit(terr.Limit)}
+			if l.Partial {
+				c.finishDevice(d)
+				te.Partial = d
+			}
+
+			return nil, te
+		case errors.Is(err, os.ErrNotExist):
+			c.unpin(name, wgc)
+			continue
+		default:
+			span.SetString(traceBackend, backendName(wgc))
+			return nil, err
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// finishDevice clears the fields of d which the Client excludes, and sorts
+// it.
+func (c *Client) finishDevice(d *wgtypes.Device) {
+	if c.exclude != 0 {
+		(wginternal.FieldAll &^ c.exclude).Apply(d)
+	}
+
+	c.order.sortDevice(d)
+}
+
+// A deviceLimitedIntoer is a wginternal.Client which can stop retrieving a
+// device when it exceeds DumpLimits.
+type deviceLimitedIntoer interface {
+	DeviceLimitedInto(name string, d *wgtypes.Device, l wginternal.DumpLimits) error
+}
+
+// deviceLimitedInto uses c to store the device name in d, subject to l. If c
+// cannot stop retrieving a device early, l is enforced once the device has
+// been retrieved.
+func deviceLimitedInto(c wginternal.Client, name string, d *wgtypes.Device, l wginternal.DumpLimits) error {
+	if dl, ok := c.(deviceLimitedIntoer); ok {
+		return dl.DeviceLimitedInto(name, d, l)
+	}
+
+	if err := deviceFieldsInto(c, name, d, wginternal.FieldAll); err != nil {
+		return err
+	}
+
+	if l.MaxPeers > 0 && len(d.Peers) > l.MaxPeers {
+		d.Peers = d.Peers[:l.MaxPeers]
+		return &wginternal.TruncatedError{Limit: wginternal.LimitPeers}
+	}
+	if !l.Deadline.IsZero() && time.Now().After(l.Deadline) {
+		return &wginternal.TruncatedError{Limit: wginternal.LimitDeadline}
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package wgctrl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientDeviceLimited(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+		c = wgtest.MustPublicKey()
+	)
+
+	device := func() *wgtypes.Device {
+		return &wgtypes.Device{
+			Name:  "wg0",
+			Peers: []wgtypes.Peer{{PublicKey: a}, {PublicKey: b}, {PublicKey: c}},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		wgc   wginternal.Client
+		l     DumpLimits
+		want  *wgtypes.Device
+		limit DumpLimit
+		part  *wgtypes.Device
+	}{
+		{
+			name: "unlimited",
+			wgc: &testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) { return device(), nil },
+			},
+			want: device(),
+		},
+		{
+			name: "within limits",
+			wgc: &testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) { return device(), nil },
+			},
+			l:    DumpLimits{MaxPeers: 3},
+			want: device(),
+		},
+		{
+			name: "peers",
+			wgc: &testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) { return device(), nil },
+			},
+			l:     DumpLimits{MaxPeers: 2},
+			limit: LimitPeers,
+		},
+		{
+			name: "peers partial",
+			wgc: &testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) { return device(), nil },
+			},
+			l:     DumpLimits{MaxPeers: 2, Partial: true},
+			limit: LimitPeers,
+			part: &wgtypes.Device{
+				Name:  "wg0",
+				Peers: []wgtypes.Peer{{PublicKey: a}, {PublicKey: b}},
+			},
+		},
+		{
+			name: "backend limit",
+			wgc: &testLimitedClient{
+				testClient: &testClient{},
+				fn: func(d *wgtypes.Device, l wginternal.DumpLimits) error {
+					if l.MaxBytes != 100 {
+						return errors.New("unexpected limits")
+					}
+
+					*d = wgtypes.Device{Name: "wg0", Peers: []wgtypes.Peer{{PublicKey: a}}}
+					return &wginternal.TruncatedError{Limit: wginternal.LimitBytes}
+				},
+			},
+			l:     DumpLimits{MaxBytes: 100, Partial: true},
+			limit: LimitBytes,
+			part: &wgtypes.Device{
+				Name:  "wg0",
+				Peers: []wgtypes.Peer{{PublicKey: a}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{cs: []wginternal.Client{tt.wgc}}
+
+			d, err := c.DeviceLimited("wg0", tt.l)
+			if tt.limit == 0 {
+				if err != nil {
+					t.Fatalf("failed to get device: %v", err)
+				}
+
+				if diff := cmp.Diff(tt.want, d); diff != "" {
+					t.Fatalf("unexpected Device (-want +got):\n%s", diff)
+				}
+
+				return
+			}
+
+			var terr *TruncatedError
+			if !errors.As(err, &terr) {
+				t.Fatalf("expected TruncatedError, but got: %v", err)
+			}
+
+			want := &TruncatedError{Device: "wg0", Limit: tt.limit, Partial: tt.part}
+			if diff := cmp.Diff(want, terr); diff != "" {
+				t.Fatalf("unexpected TruncatedError (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+type testLimitedClient struct {
+	*testClient
+	fn func(d *wgtypes.Device, l wginternal.DumpLimits) error
+}
+
+func (c *testLimitedClient) DeviceLimitedInto(_ string, d *wgtypes.Device, l wginternal.DumpLimits) error {
+	return c.fn(d, l)
+}
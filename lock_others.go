@@ -0,0 +1,28 @@
+//go:build !linux && !darwin && !freebsd && !openbsd && !netbsd && !dragonfly && !windows
+// +build !linux,!darwin,!freebsd,!openbsd,!netbsd,!dragonfly,!windows
+
+package wgctrl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// defaultLockDir is the directory used by WithDeviceLocks if none is
+// specified.
+var defaultLockDir = filepath.Join(os.TempDir(), "wgctrl")
+
+// errLockUnsupported is returned by LockDevice on platforms without file
+// locking.
+var errLockUnsupported = errors.New("wgctrl: device locks are not supported on this platform")
+
+// lockFile is not supported on this platform.
+func lockFile(_ *os.File) error {
+	return errLockUnsupported
+}
+
+// unlockFile is not supported on this platform.
+func unlockFile(_ *os.File) error {
+	return errLockUnsupported
+}
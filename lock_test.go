@@ -0,0 +1,98 @@
+package wgctrl
+
+import (
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientDeviceLocks(t *testing.T) {
+	dir := t.TempDir()
+
+	configured := make(chan struct{}, 1)
+	newClient := func() *Client {
+		return &Client{
+			cs: []wginternal.Client{&testClient{
+				ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+					configured <- struct{}{}
+					return nil
+				},
+			}},
+			locks: &deviceLocks{dir: dir},
+		}
+	}
+
+	a, b := newClient(), newClient()
+
+	l, err := a.LockDevice("wg0")
+	if err != nil {
+		t.Fatalf("failed to lock device: %v", err)
+	}
+
+	// Another Client using the same directory must wait for the lock, while
+	// a different device is unaffected.
+	done := make(chan error)
+	go func() { done <- b.ConfigureDevice("wg0", wgtypes.Config{}) }()
+
+	if err := b.ConfigureDevice("wg1", wgtypes.Config{}); err != nil {
+		t.Fatalf("failed to configure unlocked device: %v", err)
+	}
+	<-configured
+
+	select {
+	case <-configured:
+		t.Fatal("device was configured while locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := l.ConfigureDevice(wgtypes.Config{}); err != nil {
+		t.Fatalf("failed to configure locked device: %v", err)
+	}
+	<-configured
+
+	if err := l.Unlock(); err != nil {
+		t.Fatalf("failed to unlock device: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("failed to configure device: %v", err)
+	}
+	<-configured
+}
+
+func TestClientLockDeviceErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		c      *Client
+		device string
+	}{
+		{
+			name:   "no locks",
+			c:      &Client{},
+			device: "wg0",
+		},
+		{
+			name:   "path separator",
+			c:      &Client{locks: &deviceLocks{dir: t.TempDir()}},
+			device: "../wg0",
+		},
+		{
+			name:   "empty",
+			c:      &Client{locks: &deviceLocks{dir: t.TempDir()}},
+			device: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.c.LockDevice(tt.device)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+//go:build linux || darwin || freebsd || openbsd || netbsd || dragonfly
+// +build linux darwin freebsd openbsd netbsd dragonfly
+
+package wgctrl
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// defaultLockDir is the directory used by WithDeviceLocks if none is
+// specified, alongside the userspace device sockets in /var/run/wireguard.
+const defaultLockDir = "/var/run/wgctrl"
+
+// lockFile acquires an exclusive lock on f, blocking until it is available.
+func lockFile(f *os.File) error {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	return unix.Flock(int(f.Fd()), unix.LOCK_UN)
+}
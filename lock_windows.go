@@ -0,0 +1,27 @@
+//go:build windows
+// +build windows
+
+package wgctrl
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultLockDir is the directory used by WithDeviceLocks if none is
+// specified.
+var defaultLockDir = filepath.Join(os.Getenv("ProgramData"), "wgctrl")
+
+// lockFile acquires an exclusive lock on f, blocking until it is available.
+func lockFile(f *os.File) error {
+	var ol windows.Overlapped
+	return windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, &ol)
+}
+
+// unlockFile releases the lock on f.
+func unlockFile(f *os.File) error {
+	var ol windows.Overlapped
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
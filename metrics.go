@@ -0,0 +1,173 @@
+package wgctrl
+
+import (
+	"errors"
+	"expvar"
+	"os"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Metrics receives measurements of the operations a Client performs against
+// its backends, such as the Linux kernel's netlink interface or userspace
+// device sockets. Metrics methods may be called concurrently.
+//
+// Backends are identified by the name of their package, such as "wglinux" or
+// "wguser".
+type Metrics interface {
+	// Call is called after each operation on a backend, where op is one of
+	// "devices", "device", or "configure". A Client tries each backend in
+	// turn when looking up or configuring a device, so errors compatible
+	// with os.ErrNotExist are expected in normal operation.
+	Call(backend, op string, d time.Duration, err error)
+
+	// Parse is called after a backend parses n bytes of device information,
+	// which took duration d. Not all backends report parsing.
+	Parse(backend string, n int, d time.Duration)
+}
+
+// An ExpvarMetrics is a Metrics which records counters in an expvar.Map.
+// It implements expvar.Var, and can be published using expvar.Publish.
+//
+// For each backend and operation, the keys "<backend>.<op>.calls",
+// "<backend>.<op>.errors", and "<backend>.<op>.seconds" count calls, errors
+// other than os.ErrNotExist, and total time spent. The keys
+// "<backend>.parse.calls", "<backend>.parse.bytes", and
+// "<backend>.parse.seconds" count parsing of device information.
+type ExpvarMetrics struct {
+	m expvar.Map
+}
+
+var _ interface {
+	Metrics
+	RateLimitMetrics
+	expvar.Var
+} = &ExpvarMetrics{}
+
+// NewExpvarMetrics creates an ExpvarMetrics.
+func NewExpvarMetrics() *ExpvarMetrics {
+	var em ExpvarMetrics
+	em.m.Init()
+	return &em
+}
+
+// Call implements Metrics.
+func (em *ExpvarMetrics) Call(backend, op string, d time.Duration, err error) {
+	prefix := backend + "." + op
+	em.m.Add(prefix+".calls", 1)
+	em.m.AddFloat(prefix+".seconds", d.Seconds())
+
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		em.m.Add(prefix+".errors", 1)
+	}
+}
+
+// Parse implements Metrics.
+func (em *ExpvarMetrics) Parse(backend string, n int, d time.Duration) {
+	prefix := backend + ".parse"
+	em.m.Add(prefix+".calls", 1)
+	em.m.Add(prefix+".bytes", int64(n))
+	em.m.AddFloat(prefix+".seconds", d.Seconds())
+}
+
+// RateLimited implements RateLimitMetrics. The keys "ratelimit.<op>.queued",
+// "ratelimit.<op>.rejected", and "ratelimit.<op>.seconds" count operations
+// which were queued, rejected or abandoned while queued, and the total time
+// spent queued.
+func (em *ExpvarMetrics) RateLimited(op, _ string, d time.Duration, rejected bool, err error) {
+	prefix := "ratelimit." + op
+	if rejected || err != nil {
+		em.m.Add(prefix+".rejected", 1)
+	}
+	if !rejected {
+		em.m.Add(prefix+".queued", 1)
+		em.m.AddFloat(prefix+".seconds", d.Seconds())
+	}
+}
+
+// Get returns the current value of the counter key, or nil if it has not been
+// recorded.
+func (em *ExpvarMetrics) Get(key string) expvar.Var { return em.m.Get(key) }
+
+// String implements expvar.Var.
+func (em *ExpvarMetrics) String() string { return em.m.String() }
+
+// A parseObserverSetter is a wginternal.Client which can report parsing
+// measurements.
+type parseObserverSetter interface {
+	SetParseObserver(fn wginternal.ParseObserver)
+}
+
+// withMetrics wraps each of cs so that its operations are reported to m.
+func withMetrics(cs []wginternal.Client, m Metrics) []wginternal.Client {
+	out := make([]wginternal.Client, 0, len(cs))
+	for _, c := range cs {
+		backend := backendName(c)
+
+		if s, ok := c.(parseObserverSetter); ok {
+			s.SetParseObserver(func(n int, d time.Duration) {
+				m.Parse(backend, n, d)
+			})
+		}
+
+		out = append(out, &metricsClient{
+			Client:  c,
+			backend: backend,
+			m:       m,
+		})
+	}
+
+	return out
+}
+
+// A metricsClient is a wginternal.Client which reports its operations to a
+// Metrics.
+type metricsClient struct {
+	wginternal.Client
+	backend string
+	m       Metrics
+}
+
+func (c *metricsClient) Devices() ([]*wgtypes.Device, error) {
+	start := time.Now()
+	ds, err := c.Client.Devices()
+	c.m.Call(c.backend, "devices", time.Since(start), err)
+	return ds, err
+}
+
+func (c *metricsClient) DevicesFields(mask wginternal.FieldMask) ([]*wgtypes.Device, error) {
+	start := time.Now()
+	ds, err := devicesFields(c.Client, mask)
+	c.m.Call(c.backend, "devices", time.Since(start), err)
+	return ds, err
+}
+
+func (c *metricsClient) Device(name string) (*wgtypes.Device, error) {
+	start := time.Now()
+	d, err := c.Client.Device(name)
+	c.m.Call(c.backend, "device", time.Since(start), err)
+	return d, err
+}
+
+func (c *metricsClient) DeviceFieldsInto(name string, d *wgtypes.Device, mask wginternal.FieldMask) error {
+	start := time.Now()
+	err := deviceFieldsInto(c.Client, name, d, mask)
+	c.m.Call(c.backend, "device", time.Since(start), err)
+	return err
+}
+
+func (c *metricsClient) DeviceLimitedInto(name string, d *wgtypes.Device, l wginternal.DumpLimits) error {
+	start := time.Now()
+	err := deviceLimitedInto(c.Client, name, d, l)
+	c.m.Call(c.backend, "device", time.Since(start), err)
+	return err
+}
+
+func (c *metricsClient) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	start := time.Now()
+	err := c.Client.ConfigureDevice(name, cfg)
+	c.m.Call(c.backend, "configure", time.Since(start), err)
+	return err
+}
@@ -0,0 +1,76 @@
+package wgctrl
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientMetrics(t *testing.T) {
+	em := NewExpvarMetrics()
+
+	c := &Client{
+		cs: withMetrics([]wginternal.Client{
+			&testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return nil, os.ErrNotExist
+				},
+				ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+					return errFoo
+				},
+			},
+			&testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return okDevice, nil
+				},
+			},
+		}, em),
+	}
+
+	if _, err := c.Device("wg0"); err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+	if err := c.ConfigureDevice("wg0", wgtypes.Config{}); !errors.Is(err, errFoo) {
+		t.Fatalf("expected configure error, but got: %v", err)
+	}
+
+	// Not found errors are expected when multiple backends are in use, so
+	// they are not counted as errors.
+	want := map[string]string{
+		"wgctrl.testClient.device.calls":     "2",
+		"wgctrl.testClient.device.errors":    "<nil>",
+		"wgctrl.testClient.configure.calls":  "1",
+		"wgctrl.testClient.configure.errors": "1",
+	}
+
+	got := make(map[string]string)
+	for k := range want {
+		v := em.Get(k)
+		if v == nil {
+			got[k] = "<nil>"
+			continue
+		}
+
+		got[k] = v.String()
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected metrics (-want +got):\n%s", diff)
+	}
+}
+
+func TestExpvarMetricsParse(t *testing.T) {
+	em := NewExpvarMetrics()
+	em.Parse("wglinux", 100, time.Second)
+	em.Parse("wglinux", 50, time.Second)
+
+	want := `{"wglinux.parse.bytes": 150, "wglinux.parse.calls": 2, "wglinux.parse.seconds": 2}`
+	if diff := cmp.Diff(want, em.String()); diff != "" {
+		t.Fatalf("unexpected metrics (-want +got):\n%s", diff)
+	}
+}
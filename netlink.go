@@ -0,0 +1,50 @@
+package wgctrl
+
+import (
+	"errors"
+
+	"github.com/mdlayher/genetlink"
+)
+
+// A rawNetlinker is a wginternal.Client which exposes its generic netlink
+// connection.
+type rawNetlinker interface {
+	RawNetlink(fn func(conn *genetlink.Conn, family genetlink.Family) error) error
+}
+
+// errNoNetlink is returned by RawNetlink when a Client has no generic netlink
+// backend.
+var errNoNetlink = errors.New("wgctrl: Client has no generic netlink backend")
+
+// RawNetlink calls fn with the generic netlink connection used by the Client
+// to communicate with the Linux kernel WireGuard implementation, and the
+// resolved WireGuard generic netlink family. This is an escape hatch for
+// advanced users who need to issue commands which this package does not
+// support, such as experimental kernel features, without opening another
+// socket.
+//
+// fn must not close conn, and must not retain conn or use it after fn
+// returns. Messages sent using conn may be received by concurrent operations
+// on the Client, so the Client must not be used concurrently with fn.
+//
+// RawNetlink returns ErrReadOnly if the Client was created with WithReadOnly.
+// It returns an error if the Client has no generic netlink backend,
+// such as on platforms other than Linux or when the WireGuard kernel module is
+// not available.
+func (c *Client) RawNetlink(fn func(conn *genetlink.Conn, family genetlink.Family) error) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	for _, wgc := range c.cs {
+		if mc, ok := wgc.(*metricsClient); ok {
+			wgc = mc.Client
+		}
+
+		if rn, ok := wgc.(rawNetlinker); ok {
+			return rn.RawNetlink(fn)
+		}
+	}
+
+	return errNoNetlink
+}
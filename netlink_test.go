@@ -0,0 +1,55 @@
+package wgctrl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/genetlink"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+func TestClientRawNetlink(t *testing.T) {
+	family := genetlink.Family{ID: 0x10, Version: 1, Name: "wireguard"}
+
+	c := &Client{
+		cs: []wginternal.Client{
+			&testClient{},
+			withMetrics([]wginternal.Client{&netlinkTestClient{family: family}}, NewExpvarMetrics())[0],
+		},
+	}
+
+	var got genetlink.Family
+	err := c.RawNetlink(func(_ *genetlink.Conn, f genetlink.Family) error {
+		got = f
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to use raw netlink: %v", err)
+	}
+
+	if diff := cmp.Diff(family, got); diff != "" {
+		t.Fatalf("unexpected family (-want +got):\n%s", diff)
+	}
+}
+
+func TestClientRawNetlinkUnsupported(t *testing.T) {
+	c := &Client{cs: []wginternal.Client{&testClient{}}}
+
+	err := c.RawNetlink(func(_ *genetlink.Conn, _ genetlink.Family) error {
+		panic("shouldn't be called")
+	})
+	if !errors.Is(err, errNoNetlink) {
+		t.Fatalf("expected no netlink error, but got: %v", err)
+	}
+}
+
+// A netlinkTestClient is a testClient which implements rawNetlinker.
+type netlinkTestClient struct {
+	testClient
+	family genetlink.Family
+}
+
+func (c *netlinkTestClient) RawNetlink(fn func(conn *genetlink.Conn, family genetlink.Family) error) error {
+	return fn(nil, c.family)
+}
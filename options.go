@@ -0,0 +1,408 @@
+package wgctrl
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgsystemd"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
+)
+
+// A ClientOption configures optional behavior for a Client created by New.
+type ClientOption func(o *clientOptions)
+
+// clientOptions is the set of options applied to a Client by New.
+type clientOptions struct {
+	// netlinkFile is a pre-opened generic netlink socket, used on Linux.
+	netlinkFile *os.File
+
+	// preopen specifies that all sockets should be opened by New.
+	preopen bool
+
+	// userspaceFiles and socketActivation specify pre-opened connections to
+	// userspace devices.
+	userspaceFiles   []*os.File
+	socketActivation bool
+
+	// userspaceFind and userspaceDial override userspace device discovery
+	// and dialing, and userspaceAbstract enables abstract sockets.
+	userspaceFind     func() ([]string, error)
+	userspaceDial     func(device string) (net.Conn, error)
+	userspaceAbstract *string
+
+	// metrics receives measurements of backend operations.
+	metrics Metrics
+
+	// tracer starts spans for Client operations.
+	tracer Tracer
+
+	// order specifies the order of retrieved devices and peers.
+	order SortOrder
+
+	// noSecrets specifies that private and preshared keys are not retrieved.
+	noSecrets bool
+
+	// audit is called after each configuration change.
+	audit func(AuditEvent)
+
+	// journal records each applied configuration.
+	journal Journal
+
+	// backendOrder and duplicates control how backends are probed.
+	backendOrder BackendOrder
+	duplicates   bool
+
+	// pin specifies that devices are pinned to the backend which serves them.
+	pin bool
+	// cacheDiscovery specifies that backends cache the devices they find.
+	cacheDiscovery bool
+
+	// lockDir is the directory of per-device lock files, if set.
+	lockDir *string
+
+	// readOnly specifies that the Client may not change the system.
+	readOnly bool
+
+	// rateLimits limits the rate of operations on each device, if set.
+	rateLimits *RateLimits
+
+	// isolatePeers specifies that rejected configurations are applied one
+	// peer at a time to identify the failed peer.
+	isolatePeers bool
+}
+
+// WithNetlinkFile instructs a Client to communicate with the Linux kernel
+// WireGuard implementation using the pre-opened generic netlink socket f,
+// rather than opening its own socket.
+//
+// Linux checks the privileges of the process which opened a netlink socket,
+// so a privileged process may open the socket and pass it to an unprivileged
+// process, for example over a UNIX socket using SCM_RIGHTS. f is duplicated by
+// New, so the caller remains responsible for closing it.
+//
+// WithNetlinkFile is only supported on Linux; New returns an error on other
+// platforms if it is set.
+func WithNetlinkFile(f *os.File) ClientOption {
+	return func(o *clientOptions) {
+		o.netlinkFile = f
+	}
+}
+
+// WithPreopen instructs New to open every socket the Client will need for its
+// lifetime, such as netlink sockets and connections to userspace devices. After
+// New returns, the Client performs no further filesystem access and opens no
+// new sockets.
+//
+// This enables a pattern for security-hardened daemons: create a Client with
+// WithPreopen while privileged, then drop privileges or enter a strict
+// sandbox such as seccomp or Landlock, and continue to use the Client.
+//
+// Userspace devices which are created after New returns are not visible to a
+// Client created with WithPreopen. Kernel devices remain visible.
+func WithPreopen() ClientOption {
+	return func(o *clientOptions) {
+		o.preopen = true
+	}
+}
+
+// WithUserspaceFiles instructs a Client to operate only on the userspace
+// devices connected to by each of files, rather than discovering userspace
+// devices using the filesystem. This allows a sandboxed process with no
+// access to the userspace device socket directory to control devices using
+// connections established by a supervisor process.
+//
+// The device name for each file is inferred from its Name, which may be either
+// a bare device name such as "wg0" or the path of the device's socket. Files
+// are duplicated by New, so the caller remains responsible for closing them.
+func WithUserspaceFiles(files ...*os.File) ClientOption {
+	return func(o *clientOptions) {
+		o.userspaceFiles = append(o.userspaceFiles, files...)
+	}
+}
+
+// WithSocketActivation is like WithUserspaceFiles, but uses connections to
+// userspace devices which were passed to this process by a supervisor such
+// as systemd, using the protocol described in sd_listen_fds(3). The device
+// name for each connection is taken from LISTEN_FDNAMES.
+func WithSocketActivation() ClientOption {
+	return func(o *clientOptions) {
+		o.socketActivation = true
+	}
+}
+
+// WithUserspaceDialer instructs a Client to discover userspace devices using
+// find, and to connect to them using dial. find returns a list of device
+// names, such as "wg0", and dial connects to one of those devices, returning
+// a connection which speaks the userspace configuration protocol.
+//
+// This allows userspace devices to be reached through arbitrary transports,
+// such as an exec channel provided by a container runtime.
+func WithUserspaceDialer(find func() ([]string, error), dial func(device string) (net.Conn, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.userspaceFind = find
+		o.userspaceDial = dial
+	}
+}
+
+// WithAbstractUserspaceSockets instructs a Client to discover and connect to
+// userspace devices listening on Linux abstract namespace UNIX sockets whose
+// names begin with prefix. For example, the prefix "wireguard/" matches a
+// device socket named "@wireguard/wg0.sock", which is the device "wg0".
+//
+// Abstract sockets are not bound to the filesystem, so they remain reachable
+// from containers which share a network namespace with the device but not
+// its filesystem.
+func WithAbstractUserspaceSockets(prefix string) ClientOption {
+	return func(o *clientOptions) {
+		o.userspaceAbstract = &prefix
+	}
+}
+
+// WithMetrics instructs a Client to report measurements of the operations it
+// performs against each of its backends to m, so that operators can observe
+// the health of the Client itself. NewExpvarMetrics provides a Metrics which
+// can be published using package expvar.
+func WithMetrics(m Metrics) ClientOption {
+	return func(o *clientOptions) {
+		o.metrics = m
+	}
+}
+
+// WithTracer instructs a Client to start a span using t for each call to
+// Devices, Device, and ConfigureDevice. Spans record the device name, the
+// backend which handled the operation, the number of peers retrieved or
+// configured, and any error.
+func WithTracer(t Tracer) ClientOption {
+	return func(o *clientOptions) {
+		o.tracer = t
+	}
+}
+
+// WithSortOrder instructs a Client to sort the devices and peers it retrieves
+// according to order, so that tools which compare successive results do not
+// observe spurious changes. Unless order is SortNone, Devices sorts devices by
+// name, and each peer's allowed IPs are sorted by address and prefix length.
+//
+// By default, a Client uses SortNone, and devices and peers are returned in
+// the order produced by each backend.
+func WithSortOrder(order SortOrder) ClientOption {
+	return func(o *clientOptions) {
+		o.order = order
+	}
+}
+
+// WithoutSecrets instructs a Client to never return private key material:
+// the PrivateKey of each Device and the PresharedKey of each Peer are always
+// zero, regardless of the FieldMask passed to DeviceFields. This is intended
+// for monitoring services which must not hold secrets.
+//
+// Where the backend supports it, keys are skipped without being parsed or
+// copied. The Linux kernel and the userspace protocol send keys to any
+// privileged caller, so WithoutSecrets cannot prevent their transmission.
+// The userspace protocol provides no public key, so a device's private key
+// is parsed in order to compute its public key, and then discarded.
+func WithoutSecrets() ClientOption {
+	return func(o *clientOptions) {
+		o.noSecrets = true
+	}
+}
+
+// WithAudit instructs a Client to call fn after each call to ConfigureDevice,
+// with an AuditEvent which describes the requested changes and their result,
+// so that changes may be recorded in an audit trail. Private and preshared
+// keys are never included in an AuditEvent. Use NewAuditContext and
+// Client.WithContext to identify the party which requested the changes.
+//
+// fn is called synchronously, and may be called concurrently if the Client
+// is used concurrently.
+func WithAudit(fn func(AuditEvent)) ClientOption {
+	return func(o *clientOptions) {
+		o.audit = fn
+	}
+}
+
+// WithJournal instructs a Client to record each configuration successfully
+// applied by ConfigureDevice in j, so that it may be re-applied using
+// Client.Replay. If a configuration is applied but cannot be recorded,
+// ConfigureDevice returns an error.
+//
+// OpenFileJournal provides a Journal which stores configurations in a file,
+// optionally redacted or encrypted.
+func WithJournal(j Journal) ClientOption {
+	return func(o *clientOptions) {
+		o.journal = j
+	}
+}
+
+// WithBackendOrder instructs a Client to probe its backends for a device in
+// the specified order. By default, a Client uses KernelFirst, so that if a
+// kernel device and a userspace device have the same name, the kernel device
+// is used.
+func WithBackendOrder(order BackendOrder) ClientOption {
+	return func(o *clientOptions) {
+		o.backendOrder = order
+	}
+}
+
+// WithDuplicateDeviceErrors instructs a Client to return a
+// *DuplicateDeviceError when retrieving or configuring a device whose name is
+// served by more than one backend, rather than using the first backend which
+// serves it. Devices always returns the devices from every backend.
+//
+// Each backend must be probed for the device on every call, so this option
+// adds overhead to each operation on a single device.
+func WithDuplicateDeviceErrors() ClientOption {
+	return func(o *clientOptions) {
+		o.duplicates = true
+	}
+}
+
+// WithBackendPinning instructs a Client to remember the backend which serves
+// each device it retrieves or configures, and to probe that backend first on
+// subsequent calls for the device. This avoids probing every backend on each
+// call, and prevents a device from moving between backends if another
+// backend later serves a device with the same name. If the pinned backend
+// reports that the device no longer exists, the pin is removed and each
+// backend is probed again.
+//
+// Client.PinnedBackends reports the backend pinned for each device.
+func WithBackendPinning() ClientOption {
+	return func(o *clientOptions) {
+		o.pin = true
+	}
+}
+
+// WithDiscoveryCache instructs a Client to cache the list of devices found by
+// each backend, so that Devices and Device do not rescan the system on every
+// call. The cache is invalidated when the operating system reports a change:
+// on Linux, using rtnetlink link notifications for kernel devices and inotify
+// for the userspace device socket directory, and on the BSDs and macOS, using
+// kqueue for the userspace device socket directory.
+//
+// Devices found using other mechanisms, such as WithUserspaceDialer or
+// WithAbstractUserspaceSockets, are not cached.
+func WithDiscoveryCache() ClientOption {
+	return func(o *clientOptions) {
+		o.cacheDiscovery = true
+	}
+}
+
+// WithDeviceLocks instructs a Client to hold an advisory lock on each device
+// while configuring it, so that cooperating processes on the same host which
+// use the same dir do not interleave conflicting updates. Each lock is an
+// exclusive lock on a file named after the device in dir, which is created
+// if necessary. If dir is empty, /var/run/wgctrl is used, or on Windows,
+// wgctrl in the ProgramData directory.
+//
+// ConfigureDevice, SyncConfig, ConfigureDeviceIf, and Client.Replay acquire
+// the lock, blocking until it is available. SyncConfig and
+// ConfigureDeviceIf hold the lock while retrieving the device as well as
+// while configuring it. Use Client.LockDevice to hold the lock across
+// several operations.
+//
+// The locks are advisory: processes which do not use the same dir, such as
+// wg(8), are not prevented from configuring a device.
+func WithDeviceLocks(dir string) ClientOption {
+	return func(o *clientOptions) {
+		o.lockDir = &dir
+	}
+}
+
+// WithReadOnly instructs a Client to refuse any operation which would change
+// the system, such as ConfigureDevice, returning ErrReadOnly instead. A
+// read-only Client is suitable for handing to monitoring or plugin code which
+// must not reconfigure devices.
+//
+// The restriction is enforced by the Client rather than by the operating
+// system: the Linux kernel, WireGuardNT, and the userspace protocol all
+// require the same access to retrieve a device as to configure it, so the
+// process still needs the privileges reported by CheckPrivileges. For the same
+// reason, RawNetlink is refused, since fn could send any command.
+func WithReadOnly() ClientOption {
+	return func(o *clientOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithRateLimits instructs a Client to limit the rate of operations on each
+// device according to l, to protect the kernel and userspace implementations
+// from controllers which configure or retrieve devices in a tight loop. When
+// a device's limit is exceeded, the operation is queued or rejected with a
+// *RateLimitError according to l.Policy. Queued operations are abandoned when
+// the context set by Client.WithContext is done.
+//
+// If the Metrics passed to WithMetrics implements RateLimitMetrics, each
+// queued or rejected operation is reported to it.
+func WithRateLimits(l RateLimits) ClientOption {
+	return func(o *clientOptions) {
+		o.rateLimits = &l
+	}
+}
+
+// WithPeerIsolation instructs a Client to identify which peer caused a device
+// to reject a configuration of several peers. When the device rejects such a
+// configuration, the Client applies the device's own fields and then each
+// peer in turn, stopping at the first peer which fails. The *ConfigError
+// returned by ConfigureDevice then names the failed peer and lists the peers
+// in Applied which were applied before it.
+//
+// The configuration is still reported as failed even if every peer is
+// applied individually, and it is not recorded in the Client's Journal.
+// Configurations which set ReplacePeers are never applied one peer at a time,
+// since the peers they remove cannot be isolated without replacing the peers
+// of the device piecemeal.
+//
+// Each additional request counts against the limits set by WithRateLimits.
+func WithPeerIsolation() ClientOption {
+	return func(o *clientOptions) {
+		o.isolatePeers = true
+	}
+}
+
+// newUserspaceClient creates a wguser.Client configured according to o.
+func newUserspaceClient(o *clientOptions) (*wguser.Client, error) {
+	c, err := wguser.New()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case o.userspaceFind != nil && o.userspaceDial != nil:
+		c.SetDialer(o.userspaceFind, o.userspaceDial)
+	case o.userspaceAbstract != nil:
+		c.UseAbstractSockets(*o.userspaceAbstract)
+	}
+
+	files := o.userspaceFiles
+	if o.socketActivation {
+		sfs, err := wgsystemd.Files()
+		if err != nil {
+			return nil, err
+		}
+
+		// The socket activation files are only used to create connections,
+		// which duplicate their file descriptors.
+		defer func() {
+			for _, f := range sfs {
+				_ = f.Close()
+			}
+		}()
+
+		files = append(files, sfs...)
+	}
+
+	if len(files) == 0 && !o.socketActivation {
+		return c, nil
+	}
+
+	if err := c.UseFiles(files); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// errNetlinkFileUnsupported is returned by New when WithNetlinkFile is used on
+// a platform other than Linux.
+var errNetlinkFileUnsupported = errors.New("wgctrl: WithNetlinkFile is only supported on Linux")
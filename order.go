@@ -0,0 +1,130 @@
+package wgctrl
+
+import (
+	"bytes"
+	"net"
+	"sort"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A SortOrder specifies the order of the devices and peers retrieved by a
+// Client. Use WithSortOrder to set a Client's SortOrder.
+type SortOrder int
+
+// Possible SortOrder values.
+const (
+	// SortNone preserves the order in which each backend returns devices and
+	// peers. For the Linux kernel, this is the order in which peers were
+	// added to a device.
+	SortNone SortOrder = iota
+
+	// SortByPublicKey sorts peers by their public keys.
+	SortByPublicKey
+
+	// SortByAllowedIP sorts peers by their first allowed IP, and then by
+	// their public keys. Peers with no allowed IPs are sorted last.
+	SortByAllowedIP
+)
+
+// String returns the string representation of a SortOrder.
+func (o SortOrder) String() string {
+	switch o {
+	case SortNone:
+		return "none"
+	case SortByPublicKey:
+		return "public key"
+	case SortByAllowedIP:
+		return "allowed IP"
+	default:
+		return "unknown"
+	}
+}
+
+// sortDevices sorts ds and each of their peers according to o.
+func (o SortOrder) sortDevices(ds []*wgtypes.Device) {
+	if o == SortNone {
+		return
+	}
+
+	sort.SliceStable(ds, func(i, j int) bool {
+		return ds[i].Name < ds[j].Name
+	})
+
+	for _, d := range ds {
+		o.sortDevice(d)
+	}
+}
+
+// sortDevice sorts the peers of d according to o.
+func (o SortOrder) sortDevice(d *wgtypes.Device) {
+	if o == SortNone || d == nil {
+		return
+	}
+
+	// Allowed IPs are sorted first so that each peer's first allowed IP is
+	// the lowest when sorting by allowed IP.
+	for i := range d.Peers {
+		sortIPNets(d.Peers[i].AllowedIPs)
+	}
+
+	ps := d.Peers
+	switch o {
+	case SortByPublicKey:
+		sort.Slice(ps, func(i, j int) bool {
+			return bytes.Compare(ps[i].PublicKey[:], ps[j].PublicKey[:]) < 0
+		})
+	case SortByAllowedIP:
+		sort.Slice(ps, func(i, j int) bool {
+			a, b := ps[i].AllowedIPs, ps[j].AllowedIPs
+			switch {
+			case len(a) == 0 && len(b) == 0:
+			case len(a) == 0:
+				return false
+			case len(b) == 0:
+				return true
+			default:
+				if c := compareIPNets(a[0], b[0]); c != 0 {
+					return c < 0
+				}
+			}
+
+			return bytes.Compare(ps[i].PublicKey[:], ps[j].PublicKey[:]) < 0
+		})
+	}
+}
+
+// sortIPNets sorts ipns by address and then by prefix length, with IPv4
+// addresses before IPv6 addresses.
+func sortIPNets(ipns []net.IPNet) {
+	sort.Slice(ipns, func(i, j int) bool {
+		return compareIPNets(ipns[i], ipns[j]) < 0
+	})
+}
+
+// compareIPNets compares a and b as described by sortIPNets, returning -1, 0,
+// or 1.
+func compareIPNets(a, b net.IPNet) int {
+	a4, b4 := a.IP.To4(), b.IP.To4()
+	switch {
+	case a4 != nil && b4 == nil:
+		return -1
+	case a4 == nil && b4 != nil:
+		return 1
+	}
+
+	if c := bytes.Compare(a.IP.To16(), b.IP.To16()); c != 0 {
+		return c
+	}
+
+	ao, _ := a.Mask.Size()
+	bo, _ := b.Mask.Size()
+	switch {
+	case ao < bo:
+		return -1
+	case ao > bo:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,112 @@
+package wgctrl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientSortOrder(t *testing.T) {
+	var (
+		k1 = wgtypes.Key{1}
+		k2 = wgtypes.Key{2}
+		k3 = wgtypes.Key{3}
+
+		v4  = wgtest.MustCIDR("192.0.2.0/24")
+		v4b = wgtest.MustCIDR("192.0.2.0/25")
+		v4c = wgtest.MustCIDR("198.51.100.1/32")
+		v6  = wgtest.MustCIDR("2001:db8::/32")
+	)
+
+	// Each backend returns devices and peers in an arbitrary order.
+	devices := func() []*wgtypes.Device {
+		return []*wgtypes.Device{
+			{
+				Name: "wg1",
+				Peers: []wgtypes.Peer{
+					{PublicKey: k1, AllowedIPs: []net.IPNet{v6, v4c}},
+					{PublicKey: k3},
+					{PublicKey: k2, AllowedIPs: []net.IPNet{v4b, v4}},
+				},
+			},
+			{Name: "wg0"},
+		}
+	}
+
+	tests := []struct {
+		name  string
+		order SortOrder
+		names []string
+		peers []wgtypes.Peer
+	}{
+		{
+			name:  "none",
+			order: SortNone,
+			names: []string{"wg1", "wg0"},
+			peers: devices()[0].Peers,
+		},
+		{
+			name:  "public key",
+			order: SortByPublicKey,
+			names: []string{"wg0", "wg1"},
+			peers: []wgtypes.Peer{
+				{PublicKey: k1, AllowedIPs: []net.IPNet{v4c, v6}},
+				{PublicKey: k2, AllowedIPs: []net.IPNet{v4, v4b}},
+				{PublicKey: k3},
+			},
+		},
+		{
+			name:  "allowed IP",
+			order: SortByAllowedIP,
+			names: []string{"wg0", "wg1"},
+			peers: []wgtypes.Peer{
+				{PublicKey: k2, AllowedIPs: []net.IPNet{v4, v4b}},
+				{PublicKey: k1, AllowedIPs: []net.IPNet{v4c, v6}},
+				{PublicKey: k3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{
+				cs: []wginternal.Client{&testClient{
+					DevicesFunc: func() ([]*wgtypes.Device, error) {
+						return devices(), nil
+					},
+					DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+						return devices()[0], nil
+					},
+				}},
+				order: tt.order,
+			}
+
+			devs, err := c.Devices()
+			if err != nil {
+				t.Fatalf("failed to get devices: %v", err)
+			}
+
+			var names []string
+			for _, d := range devs {
+				names = append(names, d.Name)
+			}
+
+			if diff := cmp.Diff(tt.names, names); diff != "" {
+				t.Fatalf("unexpected device names (-want +got):\n%s", diff)
+			}
+
+			d, err := c.Device("wg1")
+			if err != nil {
+				t.Fatalf("failed to get device: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.peers, d.Peers); diff != "" {
+				t.Fatalf("unexpected peers (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
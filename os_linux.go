@@ -4,18 +4,26 @@
 package wgctrl
 
 import (
+	"golang.org/x/sys/unix"
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wglinux"
-	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
 )
 
 // newClients configures wginternal.Clients for Linux systems.
-func newClients() ([]wginternal.Client, error) {
+func newClients(o *clientOptions) ([]wginternal.Client, error) {
 	var clients []wginternal.Client
 
 	// Linux has an in-kernel WireGuard implementation. Determine if it is
-	// available and make use of it if so.
-	kc, ok, err := wglinux.New()
+	// available and make use of it if so, optionally using a netlink socket
+	// supplied by the caller.
+	newKernel := wglinux.New
+	if o.netlinkFile != nil {
+		newKernel = func() (*wglinux.Client, bool, error) {
+			return wglinux.NewFromFile(o.netlinkFile)
+		}
+	}
+
+	kc, ok, err := newKernel()
 	if err != nil {
 		return nil, err
 	}
@@ -25,7 +33,7 @@ func newClients() ([]wginternal.Client, error) {
 
 	// Although it isn't recommended to use userspace implementations on Linux,
 	// it can be used. We make use of it in integration tests as well.
-	uc, err := wguser.New()
+	uc, err := newUserspaceClient(o)
 	if err != nil {
 		return nil, err
 	}
@@ -34,3 +42,50 @@ func newClients() ([]wginternal.Client, error) {
 	clients = append(clients, uc)
 	return clients, nil
 }
+
+// checkPrivileges verifies that the process has CAP_NET_ADMIN, which the
+// kernel requires for all WireGuard generic netlink operations.
+func checkPrivileges() error {
+	var (
+		hdr  = unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+		data [2]unix.CapUserData
+	)
+
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return err
+	}
+
+	// Capabilities are stored as a 64-bit set split across two 32-bit words.
+	if data[unix.CAP_NET_ADMIN/32].Effective&(1<<(unix.CAP_NET_ADMIN%32)) == 0 {
+		return &PrivilegeError{Need: "CAP_NET_ADMIN"}
+	}
+
+	return nil
+}
+
+// kernelSupport detects the Linux kernel WireGuard module using sysfs and
+// generic netlink.
+func kernelSupport() (*KernelInfo, error) {
+	m, err := wglinux.ReadModule(wglinux.ModuleDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ki := &KernelInfo{ModuleVersion: m.Version}
+	switch {
+	case !m.Present:
+	case m.BuiltIn:
+		ki.Driver = KernelDriverBuiltIn
+	case m.OutOfTree:
+		ki.Driver = KernelDriverOutOfTree
+	default:
+		ki.Driver = KernelDriverInTree
+	}
+
+	ki.FamilyVersion, ki.Available, err = wglinux.FamilyVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	return ki, nil
+}
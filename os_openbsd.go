@@ -4,13 +4,18 @@
 package wgctrl
 
 import (
+	"os"
+
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wgopenbsd"
-	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
 )
 
 // newClients configures wginternal.Clients for OpenBSD systems.
-func newClients() ([]wginternal.Client, error) {
+func newClients(o *clientOptions) ([]wginternal.Client, error) {
+	if o.netlinkFile != nil {
+		return nil, errNetlinkFileUnsupported
+	}
+
 	var clients []wginternal.Client
 
 	// OpenBSD has an in-kernel WireGuard implementation. Determine if it is
@@ -23,7 +28,7 @@ func newClients() ([]wginternal.Client, error) {
 		clients = append(clients, kc)
 	}
 
-	uc, err := wguser.New()
+	uc, err := newUserspaceClient(o)
 	if err != nil {
 		return nil, err
 	}
@@ -31,3 +36,18 @@ func newClients() ([]wginternal.Client, error) {
 	clients = append(clients, uc)
 	return clients, nil
 }
+
+// checkPrivileges verifies that the process is running as root, which is
+// required to access WireGuard devices on this platform.
+func checkPrivileges() error {
+	if os.Geteuid() != 0 {
+		return &PrivilegeError{Need: "root"}
+	}
+
+	return nil
+}
+
+// kernelSupport is not supported on this platform.
+func kernelSupport() (*KernelInfo, error) {
+	return nil, errKernelSupportUnsupported
+}
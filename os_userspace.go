@@ -4,17 +4,37 @@
 package wgctrl
 
 import (
+	"os"
+
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
-	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
 )
 
 // newClients configures wginternal.Clients for systems which only support
 // userspace WireGuard implementations.
-func newClients() ([]wginternal.Client, error) {
-	c, err := wguser.New()
+func newClients(o *clientOptions) ([]wginternal.Client, error) {
+	if o.netlinkFile != nil {
+		return nil, errNetlinkFileUnsupported
+	}
+
+	c, err := newUserspaceClient(o)
 	if err != nil {
 		return nil, err
 	}
 
 	return []wginternal.Client{c}, nil
 }
+
+// checkPrivileges verifies that the process is running as root, which is
+// required to access WireGuard devices on this platform.
+func checkPrivileges() error {
+	if os.Geteuid() != 0 {
+		return &PrivilegeError{Need: "root"}
+	}
+
+	return nil
+}
+
+// kernelSupport is not supported on this platform.
+func kernelSupport() (*KernelInfo, error) {
+	return nil, errKernelSupportUnsupported
+}
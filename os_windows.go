@@ -4,20 +4,24 @@
 package wgctrl
 
 import (
+	"golang.org/x/sys/windows"
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
-	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
 	"golang.zx2c4.com/wireguard/wgctrl/internal/wgwindows"
 )
 
 // newClients configures wginternal.Clients for Windows systems.
-func newClients() ([]wginternal.Client, error) {
+func newClients(o *clientOptions) ([]wginternal.Client, error) {
+	if o.netlinkFile != nil {
+		return nil, errNetlinkFileUnsupported
+	}
+
 	var clients []wginternal.Client
 
 	// Windows has an in-kernel WireGuard implementation.
 	kc := wgwindows.New()
 	clients = append(clients, kc)
 
-	uc, err := wguser.New()
+	uc, err := newUserspaceClient(o)
 	if err != nil {
 		return nil, err
 	}
@@ -25,3 +29,18 @@ func newClients() ([]wginternal.Client, error) {
 	clients = append(clients, uc)
 	return clients, nil
 }
+
+// checkPrivileges verifies that the process is running with elevated
+// Administrator privileges, which are required to access WireGuard devices.
+func checkPrivileges() error {
+	if !windows.GetCurrentProcessToken().IsElevated() {
+		return &PrivilegeError{Need: "elevated Administrator privileges"}
+	}
+
+	return nil
+}
+
+// kernelSupport is not supported on this platform.
+func kernelSupport() (*KernelInfo, error) {
+	return nil, errKernelSupportUnsupported
+}
@@ -0,0 +1,100 @@
+package wgctrl
+
+import (
+	"sync"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// backendPins records the backend which last served each device, for a
+// Client created with WithBackendPinning.
+type backendPins struct {
+	mu sync.Mutex
+	m  map[string]wginternal.Client
+}
+
+// backendsFor returns the backends to probe for the device name: its pinned
+// backend, if any, followed by each other backend in order.
+func (c *Client) backendsFor(name string) []wginternal.Client {
+	if c.pins == nil {
+		return c.cs
+	}
+
+	c.pins.mu.Lock()
+	pinned, ok := c.pins.m[name]
+	c.pins.mu.Unlock()
+	if !ok {
+		return c.cs
+	}
+
+	out := make([]wginternal.Client, 0, len(c.cs))
+	out = append(out, pinned)
+	for _, wgc := range c.cs {
+		if wgc != pinned {
+			out = append(out, wgc)
+		}
+	}
+
+	return out
+}
+
+// pin records that wgc serves the device name.
+func (c *Client) pin(name string, wgc wginternal.Client) {
+	if c.pins == nil {
+		return
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+	c.pins.m[name] = wgc
+}
+
+// pinDevices records that wgc serves each of devs, unless another backend
+// is already pinned for a device.
+func (c *Client) pinDevices(wgc wginternal.Client, devs []*wgtypes.Device) {
+	if c.pins == nil {
+		return
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+	for _, d := range devs {
+		if _, ok := c.pins.m[d.Name]; !ok {
+			c.pins.m[d.Name] = wgc
+		}
+	}
+}
+
+// unpin removes the record that wgc serves the device name, because the
+// device no longer exists in wgc.
+func (c *Client) unpin(name string, wgc wginternal.Client) {
+	if c.pins == nil {
+		return
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+	if c.pins.m[name] == wgc {
+		delete(c.pins.m, name)
+	}
+}
+
+// PinnedBackends returns the name of the backend which is pinned to serve
+// each device, such as "wglinux" or "wguser", for a Client created with
+// WithBackendPinning. It returns nil if pinning is not enabled.
+func (c *Client) PinnedBackends() map[string]string {
+	if c.pins == nil {
+		return nil
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+
+	out := make(map[string]string, len(c.pins.m))
+	for name, wgc := range c.pins.m {
+		out[name] = backendName(wgc)
+	}
+
+	return out
+}
@@ -0,0 +1,81 @@
+package wgctrl
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientBackendPinning(t *testing.T) {
+	// Both backends serve wg0, but the second is pinned once the first no
+	// longer serves it.
+	var (
+		exists = true
+		calls  [2]int
+	)
+
+	c := &Client{
+		cs: []wginternal.Client{
+			&testClient{DeviceFunc: func(name string) (*wgtypes.Device, error) {
+				calls[0]++
+				if !exists {
+					return nil, os.ErrNotExist
+				}
+
+				return &wgtypes.Device{Name: name}, nil
+			}},
+			&userspaceTestClient{testClient{DeviceFunc: func(name string) (*wgtypes.Device, error) {
+				calls[1]++
+				return &wgtypes.Device{Name: name}, nil
+			}}},
+		},
+		pins: &backendPins{m: make(map[string]wginternal.Client)},
+	}
+
+	device := func() {
+		t.Helper()
+
+		if _, err := c.Device("wg0"); err != nil {
+			t.Fatalf("failed to get device: %v", err)
+		}
+	}
+
+	device()
+	if diff := cmp.Diff(map[string]string{"wg0": "wgctrl.testClient"}, c.PinnedBackends()); diff != "" {
+		t.Fatalf("unexpected pinned backends (-want +got):\n%s", diff)
+	}
+
+	// The device moves to the second backend, which is then pinned and used
+	// for all subsequent calls.
+	exists = false
+	device()
+	exists = true
+	device()
+	device()
+
+	if diff := cmp.Diff(map[string]string{"wg0": "wgctrl.userspaceTestClient"}, c.PinnedBackends()); diff != "" {
+		t.Fatalf("unexpected pinned backends (-want +got):\n%s", diff)
+	}
+
+	if diff := cmp.Diff([2]int{2, 3}, calls); diff != "" {
+		t.Fatalf("unexpected backend calls (-want +got):\n%s", diff)
+	}
+
+	// Plans are computed by the pinned backend.
+	p, err := c.PlanConfigureDevice("wg0", wgtypes.Config{})
+	if err != nil {
+		t.Fatalf("failed to plan: %v", err)
+	}
+
+	if diff := cmp.Diff("wgctrl.userspaceTestClient", p.Backend); diff != "" {
+		t.Fatalf("unexpected plan backend (-want +got):\n%s", diff)
+	}
+}
+
+// A userspaceTestClient is a testClient with a distinct backend name.
+type userspaceTestClient struct {
+	testClient
+}
@@ -0,0 +1,324 @@
+package wgctrl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Plan describes the changes that ConfigureDevice would make to a device,
+// as computed by Client.PlanConfigureDevice.
+type Plan struct {
+	// Device and Backend are the names of the device and the backend which
+	// would configure it, such as "wglinux".
+	Device, Backend string
+
+	// Changes describes each change to the device. Each change begins with
+	// "+" for an added peer, "-" for a removed peer, or "~" for a modified
+	// field. Private and preshared keys are described as "(hidden)".
+	Changes []string
+
+	// Requests describes each request the backend would send to apply the
+	// configuration. It is empty if the backend cannot describe its
+	// requests.
+	Requests []string
+}
+
+// String returns a human-readable description of the Plan.
+func (p *Plan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "device %s (%s):\n", p.Device, p.Backend)
+
+	if len(p.Changes) == 0 {
+		b.WriteString("  no changes\n")
+	}
+	for _, c := range p.Changes {
+		fmt.Fprintf(&b, "  %s\n", c)
+	}
+
+	if len(p.Requests) > 0 {
+		fmt.Fprintf(&b, "requests: %d\n", len(p.Requests))
+	}
+	for _, r := range p.Requests {
+		// Indent multi-line requests, such as for userspace devices.
+		r = strings.TrimSuffix(r, "\n")
+		fmt.Fprintf(&b, "  %s\n", strings.ReplaceAll(r, "\n", "\n  "))
+	}
+
+	return b.String()
+}
+
+// A configPlanner is a wginternal.Client which can describe the requests it
+// would send to configure a device.
+type configPlanner interface {
+	PlanConfigureDevice(name string, cfg wgtypes.Config) ([]string, error)
+}
+
+// PlanConfigureDevice validates cfg and computes the changes that
+// ConfigureDevice would make to the device specified by name, without
+// applying them. This enables a "dry run" to preview a risky change to a
+// device before it is applied.
+//
+// The Plan is computed from the current state of the device, which may change
+// before the configuration is applied.
+//
+// If the device specified by name does not exist or is not a WireGuard device,
+// an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
+func (c *Client) PlanConfigureDevice(name string, cfg wgtypes.Config) (*Plan, error) {
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := c.limit(rateDump, name); err != nil {
+		return nil, err
+	}
+
+	for _, wgc := range c.backendsFor(name) {
+		d, err := wgc.Device(name)
+		switch {
+		case err == nil:
+		case errors.Is(err, os.ErrNotExist):
+			continue
+		default:
+			return nil, err
+		}
+
+		p := &Plan{
+			Device:  name,
+			Backend: backendName(wgc),
+			Changes: planChanges(d, cfg),
+		}
+
+		if mc, ok := wgc.(*metricsClient); ok {
+			wgc = mc.Client
+		}
+
+		if cp, ok := wgc.(configPlanner); ok {
+			reqs, err := cp.PlanConfigureDevice(name, cfg)
+			if err != nil {
+				return nil, err
+			}
+
+			p.Requests = reqs
+		}
+
+		return p, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// ValidateConfig checks cfg for values which cannot be applied to a device,
+// returning a *ConfigError which identifies the invalid field. The same checks
+// are made by ConfigureDevice before it applies a configuration.
+func ValidateConfig(cfg wgtypes.Config) error {
+	if cfg.ListenPort != nil && (*cfg.ListenPort < 0 || *cfg.ListenPort > 65535) {
+		return &ConfigError{Field: "ListenPort", Err: fmt.Errorf("invalid listen port: %d", *cfg.ListenPort)}
+	}
+	if cfg.FirewallMark != nil && (*cfg.FirewallMark < 0 || int64(*cfg.FirewallMark) > 0xffffffff) {
+		return &ConfigError{Field: "FirewallMark", Err: fmt.Errorf("invalid firewall mark: %d", *cfg.FirewallMark)}
+	}
+
+	for _, p := range cfg.Peers {
+		perr := func(field string, err error) error {
+			k := p.PublicKey
+			return &ConfigError{Peer: &k, Field: field, Err: err}
+		}
+
+		if p.PublicKey == (wgtypes.Key{}) {
+			return perr("PublicKey", errors.New("peer has no public key"))
+		}
+		if p.Endpoint != nil && p.Endpoint.IP == nil {
+			return perr("Endpoint", errors.New("endpoint has no IP address"))
+		}
+
+		if ka := p.PersistentKeepaliveInterval; ka != nil && (*ka < 0 || *ka > 65535*time.Second) {
+			return perr("PersistentKeepaliveInterval", fmt.Errorf("invalid persistent keepalive interval: %s", *ka))
+		}
+
+		for _, ipn := range p.AllowedIPs {
+			if !validIPNet(ipn) {
+				return perr("AllowedIPs", fmt.Errorf("invalid allowed IP: %s", ipn.String()))
+			}
+		}
+	}
+
+	return nil
+}
+
+// validIPNet reports whether ipn has a valid IPv4 or IPv6 address and mask.
+func validIPNet(ipn net.IPNet) bool {
+	ones, bits := ipn.Mask.Size()
+	if ones == 0 && bits == 0 {
+		// Non-canonical mask.
+		return false
+	}
+
+	if ip := ipn.IP.To4(); ip != nil {
+		return bits == 32
+	}
+
+	return ipn.IP.To16() != nil && bits == 128
+}
+
+// planChanges describes the changes that applying cfg would make to d.
+func planChanges(d *wgtypes.Device, cfg wgtypes.Config) []string {
+	var out []string
+	if cfg.PrivateKey != nil && *cfg.PrivateKey != d.PrivateKey {
+		out = append(out, "~ private key: (hidden)")
+	}
+	if cfg.ListenPort != nil && *cfg.ListenPort != d.ListenPort {
+		out = append(out, fmt.Sprintf("~ listen port: %d -> %d", d.ListenPort, *cfg.ListenPort))
+	}
+	if cfg.FirewallMark != nil && *cfg.FirewallMark != d.FirewallMark {
+		out = append(out, fmt.Sprintf("~ fwmark: %d -> %d", d.FirewallMark, *cfg.FirewallMark))
+	}
+
+	peers := make(map[wgtypes.Key]*wgtypes.Peer, len(d.Peers))
+	for i := range d.Peers {
+		peers[d.Peers[i].PublicKey] = &d.Peers[i]
+	}
+
+	if cfg.ReplacePeers {
+		// Any peers which are not reconfigured are removed.
+		keep := make(map[wgtypes.Key]bool, len(cfg.Peers))
+		for _, p := range cfg.Peers {
+			keep[p.PublicKey] = !p.Remove && !p.UpdateOnly
+		}
+
+		for _, p := range d.Peers {
+			if !keep[p.PublicKey] {
+				out = append(out, fmt.Sprintf("- peer %s", p.PublicKey))
+				delete(peers, p.PublicKey)
+			}
+		}
+	}
+
+	for _, pc := range cfg.Peers {
+		p, ok := peers[pc.PublicKey]
+		switch {
+		case pc.Remove:
+			if ok {
+				out = append(out, fmt.Sprintf("- peer %s", pc.PublicKey))
+				delete(peers, pc.PublicKey)
+			}
+		case !ok && pc.UpdateOnly:
+			// The peer does not exist and will not be created.
+		case !ok:
+			out = append(out, fmt.Sprintf("+ peer %s", pc.PublicKey))
+			out = append(out, planPeer(&wgtypes.Peer{}, pc, false, "  + ")...)
+		default:
+			// Peers which are kept when replacing peers are recreated
+			// from only their configuration.
+			out = append(out, planPeer(p, pc, cfg.ReplacePeers, fmt.Sprintf("~ peer %s: ", pc.PublicKey))...)
+		}
+	}
+
+	return out
+}
+
+// planPeer describes the changes that applying pc would make to p, with each
+// change beginning with prefix. If recreate is set, p is removed and created
+// again, so none of its existing configuration is kept.
+func planPeer(p *wgtypes.Peer, pc wgtypes.PeerConfig, recreate bool, prefix string) []string {
+	base := p
+	if recreate {
+		base = &wgtypes.Peer{}
+	}
+
+	var out []string
+	psk := base.PresharedKey
+	if pc.PresharedKey != nil {
+		psk = *pc.PresharedKey
+	}
+	if psk != p.PresharedKey {
+		out = append(out, prefix+"preshared key: (hidden)")
+	}
+
+	endpoint := base.Endpoint
+	if pc.Endpoint != nil {
+		endpoint = pc.Endpoint
+	}
+	if before, after := endpointString(p.Endpoint), endpointString(endpoint); before != after {
+		out = append(out, prefix+"endpoint: "+changed(before, after))
+	}
+
+	ka := base.PersistentKeepaliveInterval
+	if pc.PersistentKeepaliveInterval != nil {
+		ka = *pc.PersistentKeepaliveInterval
+	}
+	if ka != p.PersistentKeepaliveInterval {
+		out = append(out, prefix+"persistent keepalive: "+changed(p.PersistentKeepaliveInterval.String(), ka.String()))
+	}
+
+	// Allowed IPs are either replaced or appended to the existing set.
+	var ips []net.IPNet
+	if !pc.ReplaceAllowedIPs {
+		ips = append(ips, base.AllowedIPs...)
+	}
+	for _, ipn := range pc.AllowedIPs {
+		if !containsIPNet(ips, ipn) {
+			ips = append(ips, ipn)
+		}
+	}
+
+	before, after := ipNetsString(p.AllowedIPs), ipNetsString(ips)
+	if before != after {
+		out = append(out, prefix+"allowed IPs: "+changed(before, after))
+	}
+
+	return out
+}
+
+// endpointString returns the string form of addr, or "(none)".
+func endpointString(addr *net.UDPAddr) string {
+	if addr == nil {
+		return "(none)"
+	}
+
+	return addr.String()
+}
+
+// changed describes a change from before to after, omitting before if the
+// value was not previously set.
+func changed(before, after string) string {
+	if before == "" || before == "<nil>" || before == "0s" || before == "(none)" {
+		return after
+	}
+
+	return before + " -> " + after
+}
+
+// containsIPNet reports whether ipns contains ipn.
+func containsIPNet(ipns []net.IPNet, ipn net.IPNet) bool {
+	for _, x := range ipns {
+		if x.String() == ipn.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ipNetsString returns a sorted, comma-separated list of ipns, or "(none)".
+func ipNetsString(ipns []net.IPNet) string {
+	if len(ipns) == 0 {
+		return "(none)"
+	}
+
+	sorted := make([]net.IPNet, len(ipns))
+	copy(sorted, ipns)
+	sortIPNets(sorted)
+
+	ss := make([]string, 0, len(sorted))
+	for _, ipn := range sorted {
+		ss = append(ss, ipn.String())
+	}
+
+	return strings.Join(ss, ", ")
+}
@@ -0,0 +1,172 @@
+package wgctrl
+
+import (
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientPlanConfigureDevice(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		psk  = wgtest.MustPresharedKey()
+		pub1 = wgtest.MustPublicKey()
+		pub2 = wgtest.MustPublicKey()
+		pub3 = wgtest.MustPublicKey()
+
+		port      = 51821
+		keepalive = 25 * time.Second
+	)
+
+	device := &wgtypes.Device{
+		Name:       "wg0",
+		PrivateKey: priv,
+		ListenPort: 51820,
+		Peers: []wgtypes.Peer{
+			{
+				PublicKey:  pub1,
+				Endpoint:   wgtest.MustUDPAddr("192.0.2.1:51820"),
+				AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")},
+			},
+			{PublicKey: pub2},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		cfg     wgtypes.Config
+		ok      bool
+		changes []string
+	}{
+		{
+			name: "bad listen port",
+			cfg:  wgtypes.Config{ListenPort: func() *int { v := 65536; return &v }()},
+		},
+		{
+			name: "bad allowed IP",
+			cfg: wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+				PublicKey:  pub1,
+				AllowedIPs: []net.IPNet{{IP: net.IPv4(10, 0, 0, 1)}},
+			}}},
+		},
+		{
+			name: "no changes",
+			cfg: wgtypes.Config{
+				PrivateKey: &priv,
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:  pub1,
+					AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")},
+				}},
+			},
+			ok: true,
+		},
+		{
+			name: "changes",
+			cfg: wgtypes.Config{
+				ListenPort: &port,
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   pub1,
+						PresharedKey:                &psk,
+						Endpoint:                    wgtest.MustUDPAddr("192.0.2.2:51820"),
+						PersistentKeepaliveInterval: &keepalive,
+						AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+					},
+					{
+						PublicKey: pub2,
+						Remove:    true,
+					},
+					{
+						PublicKey:  pub3,
+						AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.3/32")},
+					},
+				},
+			},
+			ok: true,
+			changes: []string{
+				"~ listen port: 51820 -> 51821",
+				"~ peer " + pub1.String() + ": preshared key: (hidden)",
+				"~ peer " + pub1.String() + ": endpoint: 192.0.2.1:51820 -> 192.0.2.2:51820",
+				"~ peer " + pub1.String() + ": persistent keepalive: 25s",
+				"~ peer " + pub1.String() + ": allowed IPs: 10.0.0.1/32 -> 10.0.0.1/32, 10.0.0.2/32",
+				"- peer " + pub2.String(),
+				"+ peer " + pub3.String(),
+				"  + allowed IPs: 10.0.0.3/32",
+			},
+		},
+		{
+			name: "replace peers",
+			cfg: wgtypes.Config{
+				ReplacePeers: true,
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:         pub1,
+					ReplaceAllowedIPs: true,
+				}},
+			},
+			ok: true,
+			changes: []string{
+				"- peer " + pub2.String(),
+				"~ peer " + pub1.String() + ": endpoint: 192.0.2.1:51820 -> (none)",
+				"~ peer " + pub1.String() + ": allowed IPs: 10.0.0.1/32 -> (none)",
+			},
+		},
+		{
+			name: "replace peers append allowed IPs",
+			cfg: wgtypes.Config{
+				ReplacePeers: true,
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:  pub1,
+					Endpoint:   wgtest.MustUDPAddr("192.0.2.1:51820"),
+					AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+				}},
+			},
+			ok: true,
+			changes: []string{
+				"- peer " + pub2.String(),
+				"~ peer " + pub1.String() + ": allowed IPs: 10.0.0.1/32 -> 10.0.0.2/32",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{cs: []wginternal.Client{
+				&testClient{
+					DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+						return nil, os.ErrNotExist
+					},
+				},
+				&testClient{
+					DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+						return device, nil
+					},
+					ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+						panic("shouldn't be called")
+					},
+				},
+			}}
+
+			p, err := c.PlanConfigureDevice("wg0", tt.cfg)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to plan: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if err != nil {
+				t.Logf("OK error: %v", err)
+				return
+			}
+
+			if diff := cmp.Diff(tt.changes, p.Changes); diff != "" {
+				t.Fatalf("unexpected changes (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package wgctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A PortInUseError indicates that a UDP port is unavailable for use as a
+// WireGuard device's listen port. PortInUseErrors can be checked using
+// `errors.Is(err, syscall.EADDRINUSE)`.
+type PortInUseError struct {
+	// Port is the UDP port which is in use.
+	Port int
+
+	// Device is the name of the WireGuard device listening on Port, or
+	// empty if Port is in use by another socket.
+	Device string
+}
+
+// Error implements error.
+func (e *PortInUseError) Error() string {
+	if e.Device != "" {
+		return fmt.Sprintf("wgctrl: UDP port %d is in use by device %q", e.Port, e.Device)
+	}
+
+	return fmt.Sprintf("wgctrl: UDP port %d is in use", e.Port)
+}
+
+// Is implements errors.Is comparison for syscall.EADDRINUSE.
+func (e *PortInUseError) Is(target error) bool {
+	return target == errAddrInUse
+}
+
+// CheckListenPort determines whether UDP port is available for use as a
+// listen port, returning a *PortInUseError if another WireGuard device or any
+// other socket on this host is using it.
+//
+// Because other processes may bind the port at any time, the result is only
+// advisory.
+func (c *Client) CheckListenPort(port int) error {
+	devices, err := c.Devices()
+	if err != nil {
+		return err
+	}
+
+	for _, d := range devices {
+		if d.ListenPort == port {
+			return &PortInUseError{Port: port, Device: d.Name}
+		}
+	}
+
+	// Try to bind the port on all addresses; any failure means that the
+	// device could not bind it either.
+	l, err := net.ListenPacket("udp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return &PortInUseError{Port: port}
+	}
+
+	return l.Close()
+}
+
+// EnsureListenPort configures the device specified by name to listen on the
+// preferred UDP port, and returns the port the device is listening on.
+//
+// If the preferred port is already in use, or preferred is 0, the device is
+// instead configured with an ephemeral port chosen by the operating system.
+// Orchestrators which run many devices on one host can use this to avoid
+// silent port collisions.
+func (c *Client) EnsureListenPort(name string, preferred int) (int, error) {
+	if err := c.checkWritable(); err != nil {
+		return 0, err
+	}
+
+	d, err := c.Device(name)
+	if err != nil {
+		return 0, err
+	}
+	if preferred != 0 && d.ListenPort == preferred {
+		return preferred, nil
+	}
+
+	if preferred != 0 {
+		err := c.CheckListenPort(preferred)
+		if err == nil {
+			// The port was free, but could be taken before the device binds
+			// it, so check the configuration error too.
+			err = c.ConfigureDevice(name, wgtypes.Config{ListenPort: &preferred})
+			if err == nil {
+				return preferred, nil
+			}
+		}
+		if !errors.Is(err, errAddrInUse) {
+			return 0, err
+		}
+	}
+
+	var ephemeral int
+	if err := c.ConfigureDevice(name, wgtypes.Config{ListenPort: &ephemeral}); err != nil {
+		return 0, err
+	}
+
+	// The chosen port is only known after the device has bound it.
+	d, err = c.Device(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return d.ListenPort, nil
+}
+
+// ChangeListenPortOptions configure Client.ChangeListenPort.
+type ChangeListenPortOptions struct {
+	// Firewall, if set, is called to update firewall rules which depend on
+	// the listen port: with the old and new ports before the port is
+	// changed, and with the new and old ports if the change is rolled back.
+	Firewall func(from, to int) error
+
+	// Timeout is the time allowed for active peers to reach the device on
+	// its new port. If zero, the change is not verified.
+	Timeout time.Duration
+
+	// Interval is the interval at which the device is polled during
+	// verification. If zero, one second is used.
+	Interval time.Duration
+}
+
+// activeHandshakeAge is the maximum age of the last handshake of a peer which
+// is considered active before a listen port change.
+const activeHandshakeAge = 3 * time.Minute
+
+// ChangeListenPort changes the listen port of the device specified by name
+// to port, verifies that the device's active peers can reach it on the new
+// port, and rolls back to the previous port if they cannot.
+//
+// A peer is active if it completed a handshake within the last 3 minutes. It
+// reaches the device on the new port once it completes a new handshake or
+// sends any data after the change, which happens as soon as the device
+// sends it traffic or a keepalive from the new port. If any active peer does
+// not do so within opts.Timeout, or ctx is canceled first, the previous port
+// and firewall rules are restored and an error is returned.
+func (c *Client) ChangeListenPort(ctx context.Context, name string, port int, opts ChangeListenPortOptions) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	d, err := c.Device(name)
+	if err != nil {
+		return err
+	}
+
+	old := d.ListenPort
+	if old == port {
+		return nil
+	}
+
+	if err := c.CheckListenPort(port); err != nil {
+		return err
+	}
+
+	if opts.Firewall != nil {
+		if err := opts.Firewall(old, port); err != nil {
+			return fmt.Errorf("wgctrl: failed to update firewall for listen port %d: %w", port, err)
+		}
+	}
+
+	// rollback restores the previous port and firewall rules after err.
+	rollback := func(err error) error {
+		rerr := c.ConfigureDevice(name, wgtypes.Config{ListenPort: &old})
+		if rerr == nil && opts.Firewall != nil {
+			rerr = opts.Firewall(port, old)
+		}
+		if rerr != nil {
+			return fmt.Errorf("%w; additionally failed to restore listen port %d: %v", err, old, rerr)
+		}
+
+		return err
+	}
+
+	if err := c.ConfigureDevice(name, wgtypes.Config{ListenPort: &port}); err != nil {
+		if opts.Firewall == nil {
+			return err
+		}
+
+		if ferr := opts.Firewall(port, old); ferr != nil {
+			return fmt.Errorf("%w; additionally failed to restore firewall: %v", err, ferr)
+		}
+
+		return err
+	}
+
+	if opts.Timeout == 0 {
+		return nil
+	}
+
+	// Find the peers which were active before the change, and record their
+	// receive counters once the new port is in use, so that traffic which
+	// arrived on the old port is not mistaken for progress. The counters
+	// must advance or be accompanied by a new handshake.
+	start := time.Now()
+	active := make(map[wgtypes.Key]bool)
+	for _, p := range d.Peers {
+		if !p.LastHandshakeTime.IsZero() && start.Sub(p.LastHandshakeTime) <= activeHandshakeAge {
+			active[p.PublicKey] = true
+		}
+	}
+
+	d, err = c.Device(name)
+	if err != nil {
+		return rollback(err)
+	}
+
+	pending := make(map[wgtypes.Key]int64)
+	for _, p := range d.Peers {
+		if active[p.PublicKey] {
+			pending[p.PublicKey] = p.ReceiveBytes
+		}
+	}
+
+	interval := opts.Interval
+	if interval == 0 {
+		interval = time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for len(pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return rollback(fmt.Errorf("wgctrl: %d active peer(s) of device %q did not reach listen port %d: %w",
+				len(pending), name, port, ctx.Err()))
+		case <-t.C:
+		}
+
+		d, err := c.Device(name)
+		if err != nil {
+			return rollback(err)
+		}
+
+		for _, p := range d.Peers {
+			rx, ok := pending[p.PublicKey]
+			if ok && (p.LastHandshakeTime.After(start) || p.ReceiveBytes > rx) {
+				delete(pending, p.PublicKey)
+			}
+		}
+	}
+
+	return nil
+}
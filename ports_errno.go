@@ -0,0 +1,9 @@
+//go:build !plan9
+// +build !plan9
+
+package wgctrl
+
+import "syscall"
+
+// errAddrInUse is the error matched by PortInUseError.
+var errAddrInUse error = syscall.EADDRINUSE
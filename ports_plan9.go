@@ -0,0 +1,10 @@
+//go:build plan9
+// +build plan9
+
+package wgctrl
+
+import "errors"
+
+// errAddrInUse is the error matched by PortInUseError, as Plan 9 has no
+// syscall.EADDRINUSE.
+var errAddrInUse = errors.New("address already in use")
@@ -0,0 +1,221 @@
+package wgctrl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientEnsureListenPort(t *testing.T) {
+	// Hold a UDP port open so it cannot be used by the device.
+	l, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer l.Close()
+
+	busy := l.LocalAddr().(*net.UDPAddr).Port
+
+	const ephemeral = 40000
+
+	tests := []struct {
+		name      string
+		preferred int
+		others    []*wgtypes.Device
+		port      int
+	}{
+		{
+			name:      "already listening",
+			preferred: 51820,
+			port:      51820,
+		},
+		{
+			name:      "ephemeral",
+			preferred: 0,
+			port:      ephemeral,
+		},
+		{
+			name:      "socket conflict",
+			preferred: busy,
+			port:      ephemeral,
+		},
+		{
+			name:      "device conflict",
+			preferred: 51821,
+			others:    []*wgtypes.Device{{Name: "wg1", ListenPort: 51821}},
+			port:      ephemeral,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &wgtypes.Device{Name: "wg0", ListenPort: 51820}
+
+			c := &Client{cs: []wginternal.Client{&testClient{
+				DevicesFunc: func() ([]*wgtypes.Device, error) {
+					return append([]*wgtypes.Device{d}, tt.others...), nil
+				},
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return d, nil
+				},
+				ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+					d.ListenPort = *cfg.ListenPort
+					if d.ListenPort == 0 {
+						d.ListenPort = ephemeral
+					}
+
+					return nil
+				},
+			}}}
+
+			port, err := c.EnsureListenPort("wg0", tt.preferred)
+			if err != nil {
+				t.Fatalf("failed to ensure listen port: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.port, port); diff != "" {
+				t.Fatalf("unexpected port (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestPortInUseError(t *testing.T) {
+	var err error = &PortInUseError{Port: 51820, Device: "wg0"}
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Fatalf("expected address in use, but got: %v", err)
+	}
+}
+
+func TestClientChangeListenPort(t *testing.T) {
+	// Find a port which is free for the device to use.
+	l, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	port := l.LocalAddr().(*net.UDPAddr).Port
+	_ = l.Close()
+
+	var (
+		active = wgtest.MustPublicKey()
+		idle   = wgtest.MustPublicKey()
+	)
+
+	tests := []struct {
+		name     string
+		progress bool
+		stale    bool
+		firewall []string
+		ok       bool
+		final    int
+	}{
+		{
+			name:     "verified",
+			progress: true,
+			firewall: []string{"51820->" + strconv.Itoa(port)},
+			ok:       true,
+			final:    port,
+		},
+		{
+			name: "rolled back",
+			firewall: []string{
+				"51820->" + strconv.Itoa(port),
+				strconv.Itoa(port) + "->51820",
+			},
+			final: 51820,
+		},
+		{
+			// Traffic which arrived on the old port while the firewall was
+			// updated does not verify the new port.
+			name:  "stale traffic",
+			stale: true,
+			firewall: []string{
+				"51820->" + strconv.Itoa(port),
+				strconv.Itoa(port) + "->51820",
+			},
+			final: 51820,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &wgtypes.Device{
+				Name:       "wg0",
+				ListenPort: 51820,
+				Peers: []wgtypes.Peer{
+					{
+						PublicKey:         active,
+						LastHandshakeTime: time.Now().Add(-time.Minute),
+						ReceiveBytes:      100,
+					},
+					{
+						PublicKey:         idle,
+						LastHandshakeTime: time.Now().Add(-time.Hour),
+					},
+				},
+			}
+
+			c := &Client{cs: []wginternal.Client{&testClient{
+				DevicesFunc: func() ([]*wgtypes.Device, error) {
+					return []*wgtypes.Device{d}, nil
+				},
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					// Copy the device so the Client cannot observe the
+					// peer making progress until it polls again.
+					dc := *d
+					dc.Peers = append([]wgtypes.Peer(nil), d.Peers...)
+					if tt.progress && d.ListenPort == port {
+						d.Peers[0].ReceiveBytes += 100
+					}
+
+					return &dc, nil
+				},
+				ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+					d.ListenPort = *cfg.ListenPort
+					return nil
+				},
+			}}}
+
+			var firewall []string
+			err := c.ChangeListenPort(context.Background(), "wg0", port, ChangeListenPortOptions{
+				Firewall: func(from, to int) error {
+					firewall = append(firewall, strconv.Itoa(from)+"->"+strconv.Itoa(to))
+					if tt.stale && from == 51820 {
+						d.Peers[0].ReceiveBytes += 100
+					}
+
+					return nil
+				},
+				Timeout:  100 * time.Millisecond,
+				Interval: 10 * time.Millisecond,
+			})
+			if tt.ok && err != nil {
+				t.Fatalf("failed to change listen port: %v", err)
+			}
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.firewall, firewall); diff != "" {
+				t.Fatalf("unexpected firewall updates (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.final, d.ListenPort); diff != "" {
+				t.Fatalf("unexpected final port (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
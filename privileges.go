@@ -0,0 +1,39 @@
+package wgctrl
+
+import (
+	"fmt"
+	"os"
+)
+
+// A PrivilegeError indicates that the calling process lacks the privileges
+// required to control WireGuard devices. PrivilegeErrors can be checked using
+// `errors.Is(err, os.ErrPermission)`.
+type PrivilegeError struct {
+	// Need describes the missing privilege, such as "CAP_NET_ADMIN".
+	Need string
+}
+
+// Error implements error.
+func (e *PrivilegeError) Error() string {
+	return fmt.Sprintf("wgctrl: insufficient privileges, need %s", e.Need)
+}
+
+// Is implements errors.Is comparison for os.ErrPermission.
+func (e *PrivilegeError) Is(target error) bool {
+	return target == os.ErrPermission
+}
+
+// CheckPrivileges determines whether the calling process has the privileges
+// required to control WireGuard devices on this platform, returning a
+// *PrivilegeError describing the missing privilege if not.
+//
+// On Linux, CAP_NET_ADMIN is required. On Windows, the process must be
+// running with elevated Administrator privileges. On other platforms, the
+// process must be running as root.
+//
+// CheckPrivileges is advisory: devices may still be accessible without these
+// privileges, such as userspace devices with permissive socket permissions,
+// or a Client created using WithNetlinkFile.
+func CheckPrivileges() error {
+	return checkPrivileges()
+}
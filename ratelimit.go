@@ -0,0 +1,255 @@
+package wgctrl
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// A RateLimitPolicy specifies what a Client does when an operation exceeds
+// its rate limit.
+type RateLimitPolicy int
+
+// Possible RateLimitPolicy values.
+const (
+	// RateLimitQueue delays the operation until it is within the limit, or
+	// until the context set by Client.WithContext is done.
+	RateLimitQueue RateLimitPolicy = iota
+
+	// RateLimitReject fails the operation immediately with a
+	// *RateLimitError.
+	RateLimitReject
+)
+
+// RateLimits configures the token buckets used by WithRateLimits. Each device
+// has a bucket for each kind of operation, which holds up to Burst tokens and
+// is refilled at Rate tokens per second. Each operation takes a token. A zero
+// RateLimit is unlimited.
+type RateLimits struct {
+	// Configure limits operations which configure a device, including those
+	// made by SyncConfig, ConfigureDeviceIf, and Replay.
+	Configure RateLimit
+
+	// Dump limits operations which retrieve devices. Devices and ExportCSV,
+	// which retrieve every device, share the bucket of the device with an
+	// empty name.
+	Dump RateLimit
+
+	// Policy specifies what happens when a bucket is empty.
+	Policy RateLimitPolicy
+}
+
+// A RateLimit is the rate and burst size of a token bucket.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// A RateLimitError is returned when an operation is rejected by the
+// RateLimitReject policy, or when the context of a Client is done while an
+// operation is queued.
+type RateLimitError struct {
+	Op, Device string
+
+	// Err is the context's error if the operation was queued, or nil if it
+	// was rejected.
+	Err error
+}
+
+// Error implements error.
+func (e *RateLimitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("wgctrl: %s of device %q exceeded rate limit: %v", e.Op, e.Device, e.Err)
+	}
+
+	return fmt.Sprintf("wgctrl: %s of device %q exceeded rate limit", e.Op, e.Device)
+}
+
+// Unwrap returns the context's error, if any.
+func (e *RateLimitError) Unwrap() error { return e.Err }
+
+// RateLimitMetrics is an optional interface which may be implemented by a
+// Metrics passed to WithMetrics to observe rate limiting.
+type RateLimitMetrics interface {
+	// RateLimited is called when an operation op, either "configure" or
+	// "dump", on device exceeds its rate limit. If rejected is false, the
+	// operation was queued for d, and err reports whether it was then
+	// abandoned.
+	RateLimited(op, device string, d time.Duration, rejected bool, err error)
+}
+
+// Operations subject to rate limiting.
+const (
+	rateConfigure = "configure"
+	rateDump      = "dump"
+)
+
+// rateSweepInterval is the minimum interval between removals of idle token
+// buckets.
+const rateSweepInterval = time.Minute
+
+// A rateLimiter holds a token bucket for each operation and device.
+type rateLimiter struct {
+	limits  RateLimits
+	metrics RateLimitMetrics
+	now     func() time.Time
+
+	mu      sync.Mutex
+	buckets map[[2]string]*tokenBucket
+	swept   time.Time
+}
+
+// newRateLimiter creates a rateLimiter which reports to m, if it implements
+// RateLimitMetrics.
+func newRateLimiter(l RateLimits, m Metrics) *rateLimiter {
+	rl := &rateLimiter{
+		limits:  l,
+		now:     time.Now,
+		buckets: make(map[[2]string]*tokenBucket),
+	}
+
+	if rm, ok := m.(RateLimitMetrics); ok {
+		rl.metrics = rm
+	}
+
+	return rl
+}
+
+// wait takes a token for op on device, waiting until ctx is done if the
+// policy permits.
+func (rl *rateLimiter) wait(ctx context.Context, op, device string) error {
+	limit := rl.limits.Dump
+	if op == rateConfigure {
+		limit = rl.limits.Configure
+	}
+	if limit.Rate <= 0 {
+		return nil
+	}
+
+	rl.mu.Lock()
+	now := rl.now()
+	if now.Sub(rl.swept) >= rateSweepInterval {
+		rl.sweep(now)
+	}
+
+	b, ok := rl.buckets[[2]string{op, device}]
+	if !ok {
+		b = newTokenBucket(limit, now)
+		rl.buckets[[2]string{op, device}] = b
+	}
+
+	d, ok := b.take(now, rl.limits.Policy == RateLimitQueue)
+	rl.mu.Unlock()
+
+	switch {
+	case !ok:
+		rl.report(op, device, 0, true, nil)
+		return &RateLimitError{Op: op, Device: device}
+	case d == 0:
+		return nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		rl.report(op, device, d, false, nil)
+		return nil
+	case <-ctx.Done():
+		// Return the token so that abandoned operations do not delay
+		// others.
+		rl.mu.Lock()
+		b.tokens = math.Min(b.tokens+1, b.burst)
+		rl.mu.Unlock()
+
+		rl.report(op, device, d, false, ctx.Err())
+		return &RateLimitError{Op: op, Device: device, Err: ctx.Err()}
+	}
+}
+
+// sweep removes the buckets which have refilled completely at time now, so
+// that buckets for devices which are no longer used, such as those which
+// have been deleted, do not accumulate. A full bucket is equivalent to a new
+// one, so this does not affect rate limiting. The caller must hold mu.
+func (rl *rateLimiter) sweep(now time.Time) {
+	for k, b := range rl.buckets {
+		if b.full(now) {
+			delete(rl.buckets, k)
+		}
+	}
+
+	rl.swept = now
+}
+
+// report reports a rate limited operation, if the rateLimiter has metrics.
+func (rl *rateLimiter) report(op, device string, d time.Duration, rejected bool, err error) {
+	if rl.metrics != nil {
+		rl.metrics.RateLimited(op, device, d, rejected, err)
+	}
+}
+
+// A tokenBucket is a token bucket which permits reservations of future
+// tokens, allowing its balance to become negative.
+type tokenBucket struct {
+	rate, burst, tokens float64
+	last                time.Time
+}
+
+// newTokenBucket creates a full tokenBucket at time now.
+func newTokenBucket(l RateLimit, now time.Time) *tokenBucket {
+	burst := float64(l.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &tokenBucket{
+		rate:   l.Rate,
+		burst:  burst,
+		tokens: burst,
+		last:   now,
+	}
+}
+
+// take takes a token at time now. If no token is available and reserve is
+// true, it reserves one and returns the time until it is available;
+// otherwise, it reports false.
+func (b *tokenBucket) take(now time.Time, reserve bool) (time.Duration, bool) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(b.tokens+elapsed.Seconds()*b.rate, b.burst)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	if !reserve {
+		return 0, false
+	}
+
+	b.tokens--
+	return time.Duration((-b.tokens) / b.rate * float64(time.Second)), true
+}
+
+// full reports whether the bucket will have refilled to its burst size at
+// time now.
+func (b *tokenBucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// limit takes a token for op on device if the Client has rate limits.
+func (c *Client) limit(op, device string) error {
+	if c.rates == nil {
+		return nil
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return c.rates.wait(ctx, op, device)
+}
@@ -0,0 +1,189 @@
+package wgctrl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	b := newTokenBucket(RateLimit{Rate: 2, Burst: 2}, now)
+
+	type result struct {
+		D  time.Duration
+		OK bool
+	}
+
+	take := func(at time.Duration, reserve bool) result {
+		d, ok := b.take(now.Add(at), reserve)
+		return result{D: d, OK: ok}
+	}
+
+	got := []result{
+		// The burst is available immediately.
+		take(0, false),
+		take(0, false),
+		take(0, false),
+
+		// A reservation waits for the next token, and the one after it.
+		take(0, true),
+		take(0, true),
+
+		// The bucket refills at Rate, and never beyond Burst.
+		take(10*time.Second, false),
+		take(10*time.Second, false),
+		take(10*time.Second, false),
+	}
+
+	want := []result{
+		{OK: true},
+		{OK: true},
+		{OK: false},
+		{D: 500 * time.Millisecond, OK: true},
+		{D: time.Second, OK: true},
+		{OK: true},
+		{OK: true},
+		{OK: false},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected results (-want +got):\n%s", diff)
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	rl := newRateLimiter(RateLimits{
+		Configure: RateLimit{Rate: 1, Burst: 2},
+		Policy:    RateLimitReject,
+	}, nil)
+	rl.now = func() time.Time { return now }
+
+	configure := func(device string) {
+		t.Helper()
+
+		if err := rl.wait(context.Background(), rateConfigure, device); err != nil {
+			t.Fatalf("failed to take token for %s: %v", device, err)
+		}
+	}
+
+	configure("wg0")
+
+	// When the buckets are next swept, wg0 has refilled, but wg1 has not.
+	now = now.Add(rateSweepInterval - time.Second)
+	configure("wg1")
+	configure("wg1")
+	now = now.Add(time.Second)
+	configure("wg2")
+
+	if diff := cmp.Diff(2, len(rl.buckets)); diff != "" {
+		t.Fatalf("unexpected number of buckets (-want +got):\n%s", diff)
+	}
+	if _, ok := rl.buckets[[2]string{rateConfigure, "wg0"}]; ok {
+		t.Fatal("idle bucket for wg0 was not removed")
+	}
+}
+
+func TestClientRateLimitsReject(t *testing.T) {
+	var configured int
+	m := NewExpvarMetrics()
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DeviceFunc: func(name string) (*wgtypes.Device, error) {
+				return &wgtypes.Device{Name: name}, nil
+			},
+			ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+				configured++
+				return nil
+			},
+		}},
+		rates: newRateLimiter(RateLimits{
+			Configure: RateLimit{Rate: 1e-9, Burst: 1},
+			Dump:      RateLimit{Rate: 1e-9, Burst: 2},
+			Policy:    RateLimitReject,
+		}, m),
+	}
+
+	// Each device has its own buckets.
+	for _, name := range []string{"wg0", "wg1"} {
+		if err := c.ConfigureDevice(name, wgtypes.Config{}); err != nil {
+			t.Fatalf("failed to configure %s: %v", name, err)
+		}
+	}
+
+	err := c.ConfigureDevice("wg0", wgtypes.Config{})
+	var rerr *RateLimitError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a RateLimitError, but got: %v", err)
+	}
+
+	t.Logf("OK error: %v", err)
+
+	if diff := cmp.Diff(&RateLimitError{Op: "configure", Device: "wg0"}, rerr); diff != "" {
+		t.Fatalf("unexpected error (-want +got):\n%s", diff)
+	}
+	if configured != 2 {
+		t.Fatalf("unexpected number of configurations: %d", configured)
+	}
+
+	// Dumps are limited separately from configuration.
+	for i := 0; i < 2; i++ {
+		if _, err := c.Device("wg0"); err != nil {
+			t.Fatalf("failed to get device: %v", err)
+		}
+	}
+	if _, err := c.DeviceFields("wg0", FieldPeers); !errors.As(err, &rerr) {
+		t.Fatalf("expected a RateLimitError, but got: %v", err)
+	}
+
+	for _, key := range []string{"ratelimit.configure.rejected", "ratelimit.dump.rejected"} {
+		if v := m.Get(key); v == nil || v.String() != "1" {
+			t.Fatalf("unexpected value for %s: %v", key, v)
+		}
+	}
+}
+
+func TestClientRateLimitsQueue(t *testing.T) {
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+				return nil
+			},
+		}},
+		rates: newRateLimiter(RateLimits{
+			Configure: RateLimit{Rate: 100, Burst: 1},
+		}, nil),
+	}
+
+	// The second operation is queued for about 10ms.
+	for i := 0; i < 2; i++ {
+		if err := c.ConfigureDevice("wg0", wgtypes.Config{}); err != nil {
+			t.Fatalf("failed to configure: %v", err)
+		}
+	}
+
+	// A queued operation is abandoned when the context is done.
+	c.rates.limits.Configure.Rate = 1e-9
+	c.rates.buckets = make(map[[2]string]*tokenBucket)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cc := c.WithContext(ctx)
+	if err := cc.ConfigureDevice("wg0", wgtypes.Config{}); err != nil {
+		t.Fatalf("failed to configure: %v", err)
+	}
+
+	err := cc.ConfigureDevice("wg0", wgtypes.Config{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, but got: %v", err)
+	}
+
+	t.Logf("OK error: %v", err)
+}
@@ -0,0 +1,16 @@
+package wgctrl
+
+import "errors"
+
+// ErrReadOnly is returned by operations which would change the system when
+// they are called on a Client created with WithReadOnly.
+var ErrReadOnly = errors.New("wgctrl: Client is read-only")
+
+// checkWritable returns ErrReadOnly if the Client may not change the system.
+func (c *Client) checkWritable() error {
+	if c.readOnly {
+		return ErrReadOnly
+	}
+
+	return nil
+}
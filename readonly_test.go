@@ -0,0 +1,99 @@
+package wgctrl
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mdlayher/genetlink"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientReadOnly(t *testing.T) {
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DeviceFunc: func(name string) (*wgtypes.Device, error) {
+				return &wgtypes.Device{Name: name}, nil
+			},
+			ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+				panic("configured a device using a read-only Client")
+			},
+		}},
+		readOnly: true,
+		locks:    &deviceLocks{dir: t.TempDir()},
+	}
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{
+			name: "ConfigureDevice",
+			fn:   func() error { return c.ConfigureDevice("wg0", wgtypes.Config{}) },
+		},
+		{
+			name: "ConfigureDeviceIf",
+			fn: func() error {
+				return c.ConfigureDeviceIf("wg0", Generation(&wgtypes.Device{}), wgtypes.Config{})
+			},
+		},
+		{
+			name: "SyncConfig",
+			fn:   func() error { return c.SyncConfig("wg0", wgtypes.Config{}) },
+		},
+		{
+			name: "Replay",
+			fn:   func() error { return c.Replay("wg0") },
+		},
+		{
+			name: "LockDevice",
+			fn: func() error {
+				_, err := c.LockDevice("wg0")
+				return err
+			},
+		},
+		{
+			name: "ConfigureInterface",
+			fn:   func() error { return c.ConfigureInterface("wg0", InterfaceConfig{}) },
+		},
+		{
+			name: "SetInterfaceDescription",
+			fn:   func() error { return c.SetInterfaceDescription("wg0", "test") },
+		},
+		{
+			name: "EnsureListenPort",
+			fn: func() error {
+				_, err := c.EnsureListenPort("wg0", 51820)
+				return err
+			},
+		},
+		{
+			name: "ChangeListenPort",
+			fn: func() error {
+				return c.ChangeListenPort(context.Background(), "wg0", 51820, ChangeListenPortOptions{})
+			},
+		},
+		{
+			name: "RawNetlink",
+			fn: func() error {
+				return c.RawNetlink(func(_ *genetlink.Conn, _ genetlink.Family) error {
+					panic("called fn using a read-only Client")
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, ErrReadOnly) {
+				t.Fatalf("expected ErrReadOnly, but got: %v", err)
+			}
+		})
+	}
+
+	// Retrieving devices is unaffected.
+	if _, err := c.Device("wg0"); err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+}
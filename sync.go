@@ -0,0 +1,171 @@
+package wgctrl
+
+import (
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// SyncConfig configures the device specified by name so that it matches
+// cfg, as with "wg syncconf". Unlike ConfigureDevice with ReplacePeers set,
+// only the differences between the device and cfg are applied: peers which
+// are absent from cfg are removed, and peers whose configuration is unchanged
+// are left untouched, so that their sessions are not interrupted.
+//
+// cfg is interpreted as the complete configuration of the device. For its
+// peers, a nil PresharedKey removes any preshared key, a nil
+// PersistentKeepaliveInterval disables persistent keepalives, and
+// AllowedIPs is the exact set of allowed IPs. A nil Endpoint leaves the
+// current endpoint of a peer unchanged, since WireGuard updates endpoints as
+// peers roam. The Remove, UpdateOnly, ReplacePeers, and ReplaceAllowedIPs
+// fields of cfg are ignored.
+//
+// If the device specified by name does not exist or is not a WireGuard device,
+// an error is returned which can be checked using `errors.Is(err, os.ErrNotExist)`.
+func (c *Client) SyncConfig(name string, cfg wgtypes.Config) error {
+	if err := c.checkWritable(); err != nil {
+		return err
+	}
+
+	if err := ValidateConfig(cfg); err != nil {
+		return err
+	}
+
+	return c.withDeviceLock(name, func() error {
+		d, err := c.Device(name)
+		if err != nil {
+			return err
+		}
+
+		diff, ok := syncDiff(d, cfg)
+		if !ok {
+			// Nothing to do.
+			return nil
+		}
+
+		return c.configureDevice(name, diff, true)
+	})
+}
+
+// syncDiff computes the Config which changes d to match cfg, and reports
+// whether any changes are necessary.
+func syncDiff(d *wgtypes.Device, cfg wgtypes.Config) (wgtypes.Config, bool) {
+	var (
+		diff    wgtypes.Config
+		changed bool
+	)
+
+	if k := cfg.PrivateKey; k != nil && *k != d.PrivateKey {
+		diff.PrivateKey = k
+		changed = true
+	}
+	if p := cfg.ListenPort; p != nil && *p != d.ListenPort {
+		diff.ListenPort = p
+		changed = true
+	}
+	if m := cfg.FirewallMark; m != nil && *m != d.FirewallMark {
+		diff.FirewallMark = m
+		changed = true
+	}
+
+	current := make(map[wgtypes.Key]*wgtypes.Peer, len(d.Peers))
+	for i := range d.Peers {
+		current[d.Peers[i].PublicKey] = &d.Peers[i]
+	}
+
+	want := make(map[wgtypes.Key]bool, len(cfg.Peers))
+	for _, pc := range cfg.Peers {
+		want[pc.PublicKey] = true
+
+		p, ok := current[pc.PublicKey]
+		if !ok {
+			diff.Peers = append(diff.Peers, wgtypes.PeerConfig{
+				PublicKey:                   pc.PublicKey,
+				Name:                        pc.Name,
+				PresharedKey:                pc.PresharedKey,
+				Endpoint:                    pc.Endpoint,
+				PersistentKeepaliveInterval: pc.PersistentKeepaliveInterval,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs:                  pc.AllowedIPs,
+			})
+			continue
+		}
+
+		if upc, ok := syncPeer(p, pc); ok {
+			diff.Peers = append(diff.Peers, upc)
+		}
+	}
+
+	for _, p := range d.Peers {
+		if !want[p.PublicKey] {
+			diff.Peers = append(diff.Peers, wgtypes.PeerConfig{
+				PublicKey: p.PublicKey,
+				Remove:    true,
+			})
+		}
+	}
+
+	return diff, changed || len(diff.Peers) > 0
+}
+
+// syncPeer computes the PeerConfig which changes the existing peer p to
+// match pc, and reports whether any changes are necessary.
+func syncPeer(p *wgtypes.Peer, pc wgtypes.PeerConfig) (wgtypes.PeerConfig, bool) {
+	upc := wgtypes.PeerConfig{
+		PublicKey:  p.PublicKey,
+		Name:       pc.Name,
+		UpdateOnly: true,
+	}
+	var changed bool
+
+	var psk wgtypes.Key
+	if pc.PresharedKey != nil {
+		psk = *pc.PresharedKey
+	}
+
+	// If secrets were not retrieved, the peer may have a preshared key which
+	// is not known, and which must be removed if none is wanted.
+	unknown := p.HasPresharedKey && p.PresharedKey == (wgtypes.Key{})
+	if psk != p.PresharedKey || (unknown && psk == (wgtypes.Key{})) {
+		upc.PresharedKey = &psk
+		changed = true
+	}
+
+	if pc.Endpoint != nil && (p.Endpoint == nil || pc.Endpoint.String() != p.Endpoint.String()) {
+		upc.Endpoint = pc.Endpoint
+		changed = true
+	}
+
+	var keepalive time.Duration
+	if pc.PersistentKeepaliveInterval != nil {
+		keepalive = *pc.PersistentKeepaliveInterval
+	}
+	if keepalive != p.PersistentKeepaliveInterval {
+		upc.PersistentKeepaliveInterval = &keepalive
+		changed = true
+	}
+
+	if allowedIPsString(pc.AllowedIPs) != allowedIPsString(p.AllowedIPs) {
+		upc.ReplaceAllowedIPs = true
+		upc.AllowedIPs = pc.AllowedIPs
+		changed = true
+	}
+
+	return upc, changed
+}
+
+// allowedIPsString returns a sorted, comma-separated list of ipns as a device
+// stores them: with each address masked to its prefix, and without
+// duplicates.
+func allowedIPsString(ipns []net.IPNet) string {
+	masked := make([]net.IPNet, 0, len(ipns))
+	for _, ipn := range ipns {
+		ipn = net.IPNet{IP: ipn.IP.Mask(ipn.Mask), Mask: ipn.Mask}
+		if !containsIPNet(masked, ipn) {
+			masked = append(masked, ipn)
+		}
+	}
+
+	return ipNetsString(masked)
+}
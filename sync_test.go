@@ -0,0 +1,202 @@
+package wgctrl
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientSyncConfig(t *testing.T) {
+	var (
+		priv      = wgtest.MustPrivateKey()
+		psk       = wgtest.MustPresharedKey()
+		unchanged = wgtest.MustPublicKey()
+		updated   = wgtest.MustPublicKey()
+		removed   = wgtest.MustPublicKey()
+		added     = wgtest.MustPublicKey()
+
+		port      = 51820
+		newPort   = 51821
+		keepalive = 25 * time.Second
+	)
+
+	device := &wgtypes.Device{
+		Name:       "wg0",
+		PrivateKey: priv,
+		ListenPort: port,
+		Peers: []wgtypes.Peer{
+			{
+				PublicKey:                   unchanged,
+				PresharedKey:                psk,
+				HasPresharedKey:             true,
+				Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+				PersistentKeepaliveInterval: keepalive,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.1/32"),
+					wgtest.MustCIDR("10.0.1.0/24"),
+				},
+			},
+			{
+				PublicKey:       updated,
+				PresharedKey:    psk,
+				HasPresharedKey: true,
+				Endpoint:        wgtest.MustUDPAddr("192.0.2.2:51820"),
+				AllowedIPs:      []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+			},
+			{PublicKey: removed},
+		},
+	}
+
+	tests := []struct {
+		name string
+		cfg  wgtypes.Config
+		want *wgtypes.Config
+	}{
+		{
+			name: "no changes",
+			cfg: wgtypes.Config{
+				PrivateKey: &priv,
+				ListenPort: &port,
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   unchanged,
+						PresharedKey:                &psk,
+						PersistentKeepaliveInterval: &keepalive,
+						// Order of allowed IPs is not significant.
+						AllowedIPs: []net.IPNet{
+							wgtest.MustCIDR("10.0.1.0/24"),
+							wgtest.MustCIDR("10.0.0.1/32"),
+						},
+					},
+					{
+						PublicKey:    updated,
+						PresharedKey: &psk,
+						Endpoint:     wgtest.MustUDPAddr("192.0.2.2:51820"),
+						AllowedIPs:   []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+					},
+					{PublicKey: removed},
+				},
+			},
+		},
+		{
+			name: "changes",
+			cfg: wgtypes.Config{
+				PrivateKey: &priv,
+				ListenPort: &newPort,
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   unchanged,
+						PresharedKey:                &psk,
+						PersistentKeepaliveInterval: &keepalive,
+						AllowedIPs: []net.IPNet{
+							wgtest.MustCIDR("10.0.0.1/32"),
+							wgtest.MustCIDR("10.0.1.0/24"),
+						},
+					},
+					{
+						PublicKey:                   updated,
+						Endpoint:                    wgtest.MustUDPAddr("192.0.2.3:51820"),
+						PersistentKeepaliveInterval: &keepalive,
+						AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("10.0.0.3/32")},
+					},
+					{
+						PublicKey:  added,
+						AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.4/32")},
+					},
+				},
+			},
+			want: &wgtypes.Config{
+				ListenPort: &newPort,
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   updated,
+						UpdateOnly:                  true,
+						PresharedKey:                &wgtypes.Key{},
+						Endpoint:                    wgtest.MustUDPAddr("192.0.2.3:51820"),
+						PersistentKeepaliveInterval: &keepalive,
+						ReplaceAllowedIPs:           true,
+						AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("10.0.0.3/32")},
+					},
+					{
+						PublicKey:         added,
+						ReplaceAllowedIPs: true,
+						AllowedIPs:        []net.IPNet{wgtest.MustCIDR("10.0.0.4/32")},
+					},
+					{
+						PublicKey: removed,
+						Remove:    true,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *wgtypes.Config
+			c := &Client{
+				cs: []wginternal.Client{&testClient{
+					DeviceFunc: func(_ string) (*wgtypes.Device, error) { return device, nil },
+					ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+						got = &cfg
+						return nil
+					},
+				}},
+			}
+
+			if err := c.SyncConfig("wg0", tt.cfg); err != nil {
+				t.Fatalf("failed to sync config: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSyncPeerUnknownPresharedKey(t *testing.T) {
+	// When secrets were not retrieved, a peer's preshared key is unknown and
+	// must be removed if none is wanted, but cannot be compared with a
+	// wanted key.
+	var (
+		pub  = wgtest.MustPublicKey()
+		psk  = wgtest.MustPresharedKey()
+		peer = &wgtypes.Peer{PublicKey: pub, HasPresharedKey: true}
+	)
+
+	for _, want := range []*wgtypes.Key{nil, &psk} {
+		pc, ok := syncPeer(peer, wgtypes.PeerConfig{PublicKey: pub, PresharedKey: want})
+		if !ok || pc.PresharedKey == nil {
+			t.Fatalf("expected preshared key to be set for %v", want)
+		}
+	}
+}
+
+func TestSyncPeerUnmaskedAllowedIPs(t *testing.T) {
+	// Devices store allowed IPs masked to their prefix, so an unmasked
+	// address is not a change.
+	var (
+		pub  = wgtest.MustPublicKey()
+		peer = &wgtypes.Peer{
+			PublicKey:  pub,
+			AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.0/24")},
+		}
+	)
+
+	_, ok := syncPeer(peer, wgtypes.PeerConfig{
+		PublicKey: pub,
+		AllowedIPs: []net.IPNet{
+			{IP: net.IPv4(10, 0, 0, 1), Mask: net.CIDRMask(24, 32)},
+			wgtest.MustCIDR("10.0.0.0/24"),
+		},
+	})
+	if ok {
+		t.Fatal("expected no changes, but the peer was changed")
+	}
+}
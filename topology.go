@@ -0,0 +1,90 @@
+package wgctrl
+
+import (
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Route is an allowed IP prefix of a peer on a WireGuard device.
+type Route struct {
+	// Device is the name of the device.
+	Device string
+
+	// Peer is the public key of the peer which owns IPNet.
+	Peer wgtypes.Key
+
+	// IPNet is the allowed IP prefix.
+	IPNet net.IPNet
+}
+
+// Ref returns the PeerRef of the Route's peer, for combining the Topologies of
+// several hosts. host identifies this host.
+func (r Route) Ref(host string) wgtypes.PeerRef {
+	return wgtypes.PeerRef{Host: host, Device: r.Device, PublicKey: r.Peer}
+}
+
+// A Topology is a host-wide view of which peer on which WireGuard device owns
+// each allowed IP prefix.
+type Topology struct {
+	// Routes are the allowed IPs of every peer on every device, sorted by
+	// network address and then by prefix length.
+	Routes []Route
+
+	// Conflicts are the overlapping allowed IPs between peers on different
+	// devices, as reported by ValidateHost.
+	Conflicts []HostIssue
+}
+
+// Topology retrieves all of the WireGuard devices on this host and produces
+// a Topology from them.
+func (c *Client) Topology() (*Topology, error) {
+	devices, err := c.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTopology(devices), nil
+}
+
+// NewTopology produces a Topology from devices, which may be snapshots
+// retrieved at any time.
+func NewTopology(devices []*wgtypes.Device) *Topology {
+	rs := routes(devices)
+	return &Topology{
+		Routes:    rs,
+		Conflicts: overlaps(rs),
+	}
+}
+
+// LookupRoute returns the Route a packet destined for ip will take, and
+// reports whether any Route matched.
+//
+// As with WireGuard's cryptokey routing, the Route with the most specific
+// prefix containing ip is chosen. Between devices, the host's routing table
+// makes the final decision, so when multiple devices have a peer with the same
+// most specific prefix, the Route from the first device is returned and the
+// ambiguity is reported in Conflicts.
+func (t *Topology) LookupRoute(ip net.IP) (Route, bool) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	var (
+		best     Route
+		bestOnes = -1
+	)
+
+	for _, r := range t.Routes {
+		// Only consider prefixes of the same address family.
+		if len(r.IPNet.IP) != len(ip) || !r.IPNet.Contains(ip) {
+			continue
+		}
+
+		if ones, _ := r.IPNet.Mask.Size(); ones > bestOnes {
+			best, bestOnes = r, ones
+		}
+	}
+
+	return best, bestOnes != -1
+}
@@ -0,0 +1,91 @@
+package wgctrl
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestTopologyLookupRoute(t *testing.T) {
+	var (
+		peerA = wgtest.MustPublicKey()
+		peerB = wgtest.MustPublicKey()
+		peerC = wgtest.MustPublicKey()
+	)
+
+	topo := NewTopology([]*wgtypes.Device{
+		{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{
+				{
+					PublicKey:  peerA,
+					AllowedIPs: []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+				},
+				{
+					PublicKey: peerB,
+					AllowedIPs: []net.IPNet{
+						wgtest.MustCIDR("10.0.0.0/8"),
+						wgtest.MustCIDR("fd00::/8"),
+					},
+				},
+			},
+		},
+		{
+			Name: "wg1",
+			Peers: []wgtypes.Peer{{
+				PublicKey:  peerC,
+				AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.1.0.0/16")},
+			}},
+		},
+	})
+
+	tests := []struct {
+		ip string
+		r  Route
+		ok bool
+	}{
+		{
+			ip: "192.0.2.1",
+			r:  Route{Device: "wg0", Peer: peerA, IPNet: wgtest.MustCIDR("0.0.0.0/0")},
+			ok: true,
+		},
+		{
+			ip: "10.2.0.1",
+			r:  Route{Device: "wg0", Peer: peerB, IPNet: wgtest.MustCIDR("10.0.0.0/8")},
+			ok: true,
+		},
+		{
+			ip: "10.1.2.3",
+			r:  Route{Device: "wg1", Peer: peerC, IPNet: wgtest.MustCIDR("10.1.0.0/16")},
+			ok: true,
+		},
+		{
+			ip: "fd00::1",
+			r:  Route{Device: "wg0", Peer: peerB, IPNet: wgtest.MustCIDR("fd00::/8")},
+			ok: true,
+		},
+		{
+			ip: "2001:db8::1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			r, ok := topo.LookupRoute(net.ParseIP(tt.ip))
+			if diff := cmp.Diff(tt.ok, ok); diff != "" {
+				t.Fatalf("unexpected match (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.r, r); diff != "" {
+				t.Fatalf("unexpected route (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if len(topo.Routes) != 4 || len(topo.Conflicts) != 2 {
+		t.Fatalf("unexpected topology: %d routes, %d conflicts", len(topo.Routes), len(topo.Conflicts))
+	}
+}
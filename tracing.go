@@ -0,0 +1,91 @@
+package wgctrl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+)
+
+// A Tracer starts spans which describe the operations of a Client, such as
+// Device and ConfigureDevice. Tracer avoids a dependency on any particular
+// tracing library; an adapter for OpenTelemetry or a similar library only
+// needs to start a span as a child of the span in a context.
+//
+// Use WithTracer to set a Client's Tracer, and Client.WithContext to associate
+// a Client's spans with the caller's trace.
+type Tracer interface {
+	// Start starts a span named name as a child of any span in ctx.
+	Start(ctx context.Context, name string) Span
+}
+
+// A Span is a single traced operation started by a Tracer.
+type Span interface {
+	// SetString and SetInt set an attribute of the span.
+	SetString(key, value string)
+	SetInt(key string, value int)
+
+	// End ends the span, recording err if the operation failed.
+	End(err error)
+}
+
+// Attribute keys set on spans started by a Client.
+const (
+	// The name of the device passed to an operation.
+	traceDevice = "wireguard.device"
+
+	// The backend which performed an operation, such as "wglinux".
+	traceBackend = "wireguard.backend"
+
+	// The number of devices retrieved by Devices.
+	traceDevices = "wireguard.devices"
+
+	// The number of peers retrieved or configured by an operation.
+	tracePeers = "wireguard.peers"
+)
+
+// WithContext returns a shallow copy of c whose operations use ctx as the
+// parent of any spans started by its Tracer. The copy shares its backends with
+// c, so closing either Client closes both.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("wgctrl: nil context")
+	}
+
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// startSpan starts a span for the operation name using the Client's Tracer,
+// or returns a no-op span if the Client has no Tracer.
+func (c *Client) startSpan(name string) Span {
+	if c.tracer == nil {
+		return noopSpan{}
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return c.tracer.Start(ctx, "wgctrl."+name)
+}
+
+// A noopSpan is a Span which does nothing.
+type noopSpan struct{}
+
+func (noopSpan) SetString(_, _ string)  {}
+func (noopSpan) SetInt(_ string, _ int) {}
+func (noopSpan) End(_ error)            {}
+
+// backendName returns the name of the package which implements c, such as
+// "wglinux" for *wglinux.Client.
+func backendName(c wginternal.Client) string {
+	if mc, ok := c.(*metricsClient); ok {
+		return mc.backend
+	}
+
+	return strings.TrimSuffix(strings.TrimPrefix(fmt.Sprintf("%T", c), "*"), ".Client")
+}
@@ -0,0 +1,105 @@
+package wgctrl
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientTracing(t *testing.T) {
+	var tr testTracer
+	c := &Client{
+		cs: []wginternal.Client{
+			&testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return nil, os.ErrNotExist
+				},
+				ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+					return errFoo
+				},
+			},
+			&testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return &wgtypes.Device{Name: "wg0", Peers: make([]wgtypes.Peer, 2)}, nil
+				},
+			},
+		},
+		tracer: &tr,
+	}
+
+	ctx := context.WithValue(context.Background(), testParentKey{}, "parent")
+
+	_, _ = c.WithContext(ctx).Device("wg0")
+	_ = c.ConfigureDevice("wg0", wgtypes.Config{Peers: []wgtypes.PeerConfig{
+		{PublicKey: wgtest.MustPublicKey()},
+		{PublicKey: wgtest.MustPublicKey()},
+		{PublicKey: wgtest.MustPublicKey()},
+	}})
+
+	want := []*testSpan{
+		{
+			Name:   "wgctrl.Device",
+			Parent: "parent",
+			Attrs: map[string]interface{}{
+				"wireguard.device":  "wg0",
+				"wireguard.backend": "wgctrl.testClient",
+				"wireguard.peers":   2,
+			},
+			Ended: true,
+		},
+		{
+			Name: "wgctrl.ConfigureDevice",
+			Attrs: map[string]interface{}{
+				"wireguard.device":  "wg0",
+				"wireguard.backend": "wgctrl.testClient",
+				"wireguard.peers":   3,
+			},
+			Err:   (&ConfigError{Device: "wg0", Err: errFoo}).Error(),
+			Ended: true,
+		},
+	}
+
+	if diff := cmp.Diff(want, tr.spans); diff != "" {
+		t.Fatalf("unexpected spans (-want +got):\n%s", diff)
+	}
+}
+
+type testParentKey struct{}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string) Span {
+	parent, _ := ctx.Value(testParentKey{}).(string)
+
+	s := &testSpan{
+		Name:   name,
+		Parent: parent,
+		Attrs:  make(map[string]interface{}),
+	}
+	tr.spans = append(tr.spans, s)
+	return s
+}
+
+type testSpan struct {
+	Name, Parent string
+	Attrs        map[string]interface{}
+	Err          string
+	Ended        bool
+}
+
+func (s *testSpan) SetString(key, value string)  { s.Attrs[key] = value }
+func (s *testSpan) SetInt(key string, value int) { s.Attrs[key] = value }
+
+func (s *testSpan) End(err error) {
+	if err != nil {
+		s.Err = err.Error()
+	}
+	s.Ended = true
+}
@@ -0,0 +1,10 @@
+// Package uapitest provides a conformance test suite for implementations of
+// the WireGuard cross-platform userspace configuration protocol, commonly
+// known as the UAPI.
+//
+// Authors of alternative userspace WireGuard implementations can use Run from
+// their own tests to verify that their implementation handles the full range
+// of get and set operations in the same way as wireguard-go and package
+// wgctrl expect. The protocol is described here:
+// https://www.wireguard.com/xplatform/#cross-platform-userspace-implementation.
+package uapitest // import "golang.zx2c4.com/wireguard/wgctrl/uapitest"
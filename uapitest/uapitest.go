@@ -0,0 +1,407 @@
+package uapitest
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wguser"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// deviceName is the device name used by the wguser.Client which drives tests.
+const deviceName = "uapitest"
+
+// A DialFunc connects to the userspace device under test. Each call must
+// return a new connection to the same device.
+type DialFunc func() (net.Conn, error)
+
+// Run runs the conformance suite against the userspace device reachable
+// using dial. The device's configuration is reset before each test, so the
+// device must not be in use for any other purpose. The device is left with an
+// empty configuration when Run returns.
+//
+// Implementations may leave a connection unusable after returning a non-zero
+// errno, so Run never reuses a connection after an operation.
+func Run(t *testing.T, dial DialFunc) {
+	t.Helper()
+
+	c, err := wguser.New()
+	if err != nil {
+		t.Fatalf("failed to create userspace client: %v", err)
+	}
+	defer c.Close()
+
+	c.SetDialer(
+		func() ([]string, error) { return []string{deviceName}, nil },
+		func(_ string) (net.Conn, error) { return dial() },
+	)
+
+	s := &suite{c: c, dial: dial}
+
+	tests := []struct {
+		name string
+		fn   func(t *testing.T)
+	}{
+		{name: "get empty", fn: s.testGetEmpty},
+		{name: "private key", fn: s.testPrivateKey},
+		{name: "listen port and fwmark", fn: s.testListenPortFirewallMark},
+		{name: "add peers", fn: s.testAddPeers},
+		{name: "append allowed IPs", fn: s.testAppendAllowedIPs},
+		{name: "replace allowed IPs", fn: s.testReplaceAllowedIPs},
+		{name: "clear preshared key", fn: s.testClearPresharedKey},
+		{name: "update only absent", fn: s.testUpdateOnlyAbsent},
+		{name: "update only present", fn: s.testUpdateOnlyPresent},
+		{name: "remove peer", fn: s.testRemovePeer},
+		{name: "replace peers", fn: s.testReplacePeers},
+		{name: "errno", fn: s.testErrno},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.reset(t)
+			defer s.reset(t)
+
+			tt.fn(t)
+		})
+	}
+}
+
+// A suite contains the state for a run of the conformance suite.
+type suite struct {
+	c    *wguser.Client
+	dial DialFunc
+}
+
+func (s *suite) testGetEmpty(t *testing.T) {
+	s.diff(t, &wgtypes.Device{PublicKey: wgtypes.Key{}.PublicKey()})
+}
+
+func (s *suite) testPrivateKey(t *testing.T) {
+	priv := wgtest.MustPrivateKey()
+	s.configure(t, wgtypes.Config{PrivateKey: &priv})
+
+	s.diff(t, &wgtypes.Device{
+		PrivateKey: priv,
+		PublicKey:  priv.PublicKey(),
+	})
+}
+
+func (s *suite) testListenPortFirewallMark(t *testing.T) {
+	var (
+		port = freePort(t)
+		mark = 0x1234
+	)
+
+	s.configure(t, wgtypes.Config{
+		ListenPort:   &port,
+		FirewallMark: &mark,
+	})
+
+	s.diff(t, &wgtypes.Device{
+		PublicKey:    wgtypes.Key{}.PublicKey(),
+		ListenPort:   port,
+		FirewallMark: mark,
+	})
+}
+
+func (s *suite) testAddPeers(t *testing.T) {
+	cfg := wgtypes.Config{Peers: []wgtypes.PeerConfig{
+		testPeerConfig("192.0.2.1:51820", "10.0.0.1/32", "fd00::1/128"),
+		testPeerConfig("[2001:db8::1]:51820", "10.0.1.0/24"),
+	}}
+
+	s.configure(t, cfg)
+	s.diff(t, deviceFor(cfg))
+}
+
+func (s *suite) testAppendAllowedIPs(t *testing.T) {
+	pc := testPeerConfig("192.0.2.1:51820", "10.0.0.1/32")
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}})
+
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey:  pc.PublicKey,
+		AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+	}}})
+
+	pc.AllowedIPs = append(pc.AllowedIPs, wgtest.MustCIDR("10.0.0.2/32"))
+	s.diff(t, deviceFor(wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}}))
+}
+
+func (s *suite) testReplaceAllowedIPs(t *testing.T) {
+	pc := testPeerConfig("192.0.2.1:51820", "10.0.0.1/32")
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}})
+
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey:         pc.PublicKey,
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+	}}})
+
+	pc.AllowedIPs = []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")}
+	s.diff(t, deviceFor(wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}}))
+}
+
+func (s *suite) testClearPresharedKey(t *testing.T) {
+	pc := testPeerConfig("192.0.2.1:51820")
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}})
+
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey:    pc.PublicKey,
+		PresharedKey: &wgtypes.Key{},
+	}}})
+
+	pc.PresharedKey = nil
+	s.diff(t, deviceFor(wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}}))
+}
+
+func (s *suite) testUpdateOnlyAbsent(t *testing.T) {
+	pc := testPeerConfig("192.0.2.1:51820")
+	pc.UpdateOnly = true
+
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}})
+	s.diff(t, &wgtypes.Device{PublicKey: wgtypes.Key{}.PublicKey()})
+}
+
+func (s *suite) testUpdateOnlyPresent(t *testing.T) {
+	pc := testPeerConfig("192.0.2.1:51820")
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}})
+
+	keepalive := 10 * time.Second
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey:                   pc.PublicKey,
+		UpdateOnly:                  true,
+		PersistentKeepaliveInterval: &keepalive,
+	}}})
+
+	pc.PersistentKeepaliveInterval = &keepalive
+	s.diff(t, deviceFor(wgtypes.Config{Peers: []wgtypes.PeerConfig{pc}}))
+}
+
+func (s *suite) testRemovePeer(t *testing.T) {
+	var (
+		a = testPeerConfig("192.0.2.1:51820")
+		b = testPeerConfig("192.0.2.2:51820")
+	)
+
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{a, b}})
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey: a.PublicKey,
+		Remove:    true,
+	}}})
+
+	s.diff(t, deviceFor(wgtypes.Config{Peers: []wgtypes.PeerConfig{b}}))
+}
+
+func (s *suite) testReplacePeers(t *testing.T) {
+	var (
+		a = testPeerConfig("192.0.2.1:51820")
+		b = testPeerConfig("192.0.2.2:51820")
+	)
+
+	s.configure(t, wgtypes.Config{Peers: []wgtypes.PeerConfig{a}})
+	s.configure(t, wgtypes.Config{
+		ReplacePeers: true,
+		Peers:        []wgtypes.PeerConfig{b},
+	})
+
+	s.diff(t, deviceFor(wgtypes.Config{Peers: []wgtypes.PeerConfig{b}}))
+}
+
+func (s *suite) testErrno(t *testing.T) {
+	pub := "public_key=" + wgtest.MustPublicKey().HexString() + "\n"
+
+	tests := []struct {
+		name, req string
+	}{
+		{name: "unknown device key", req: "set=1\nfoo=bar\n\n"},
+		{name: "invalid private key", req: "set=1\nprivate_key=zz\n\n"},
+		{name: "short private key", req: "set=1\nprivate_key=abcd\n\n"},
+		{name: "invalid listen port", req: "set=1\nlisten_port=foo\n\n"},
+		{name: "invalid fwmark", req: "set=1\nfwmark=foo\n\n"},
+		{name: "invalid replace peers", req: "set=1\nreplace_peers=foo\n\n"},
+		{name: "peer key before public key", req: "set=1\nendpoint=192.0.2.1:51820\n\n"},
+		{name: "unknown peer key", req: "set=1\n" + pub + "foo=bar\n\n"},
+		{name: "invalid endpoint", req: "set=1\n" + pub + "endpoint=foo\n\n"},
+		{name: "invalid allowed IP", req: "set=1\n" + pub + "allowed_ip=foo\n\n"},
+		{name: "invalid keepalive", req: "set=1\n" + pub + "persistent_keepalive_interval=foo\n\n"},
+		{name: "invalid protocol version", req: "set=1\n" + pub + "protocol_version=9999\n\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errno := s.exchange(t, tt.req)
+			if errno == "0" {
+				t.Fatalf("expected non-zero errno for request:\n%s", tt.req)
+			}
+		})
+	}
+}
+
+// reset clears all configuration from the device.
+func (s *suite) reset(t *testing.T) {
+	t.Helper()
+
+	var (
+		zero int
+		priv wgtypes.Key
+	)
+
+	s.configure(t, wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &zero,
+		FirewallMark: &zero,
+		ReplacePeers: true,
+	})
+}
+
+// configure applies cfg to the device.
+func (s *suite) configure(t *testing.T, cfg wgtypes.Config) {
+	t.Helper()
+
+	if err := s.c.ConfigureDevice(deviceName, cfg); err != nil {
+		t.Fatalf("failed to configure device: %v", err)
+	}
+}
+
+// diff retrieves the device and compares it against want. Fields which are
+// determined by the implementation at runtime, such as peer statistics, are
+// ignored. A zero ListenPort in want matches any port, since an
+// implementation may bind a random port when its listen port is set to zero.
+func (s *suite) diff(t *testing.T, want *wgtypes.Device) {
+	t.Helper()
+
+	got, err := s.c.Device(deviceName)
+	if err != nil {
+		t.Fatalf("failed to get device: %v", err)
+	}
+
+	if want.ListenPort == 0 {
+		want.ListenPort = got.ListenPort
+	}
+
+	for _, d := range []*wgtypes.Device{want, got} {
+		d.Name = ""
+		d.Type = wgtypes.Unknown
+		normalize(d.Peers)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected device (-want +got):\n%s", diff)
+	}
+}
+
+// exchange sends a raw request to the device over a new connection and
+// returns the value of the errno key in the response.
+func (s *suite) exchange(t *testing.T, req string) string {
+	t.Helper()
+
+	c, err := s.dial()
+	if err != nil {
+		t.Fatalf("failed to dial device: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := io.WriteString(c, req); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+
+	var errno string
+	sc := bufio.NewScanner(c)
+	for sc.Scan() && sc.Text() != "" {
+		kv := strings.SplitN(sc.Text(), "=", 2)
+		if len(kv) == 2 && kv[0] == "errno" {
+			errno = kv[1]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+
+	if errno == "" {
+		t.Fatalf("response did not contain errno for request:\n%s", req)
+	}
+
+	return errno
+}
+
+// normalize zeroes runtime-determined fields and sorts peers and their
+// allowed IPs for comparison.
+func normalize(ps []wgtypes.Peer) {
+	for i := range ps {
+		p := &ps[i]
+		p.LastHandshakeTime = time.Time{}
+		p.ReceiveBytes = 0
+		p.TransmitBytes = 0
+		p.ProtocolVersion = 0
+
+		sort.Slice(p.AllowedIPs, func(i, j int) bool {
+			return p.AllowedIPs[i].String() < p.AllowedIPs[j].String()
+		})
+	}
+
+	sort.Slice(ps, func(i, j int) bool {
+		return bytes.Compare(ps[i].PublicKey[:], ps[j].PublicKey[:]) < 0
+	})
+}
+
+// deviceFor produces the Device expected after applying cfg to an empty
+// device.
+func deviceFor(cfg wgtypes.Config) *wgtypes.Device {
+	d := &wgtypes.Device{PublicKey: wgtypes.Key{}.PublicKey()}
+	for _, pc := range cfg.Peers {
+		p := wgtypes.Peer{
+			PublicKey:  pc.PublicKey,
+			Endpoint:   pc.Endpoint,
+			AllowedIPs: pc.AllowedIPs,
+		}
+		if pc.PresharedKey != nil {
+			p.PresharedKey = *pc.PresharedKey
+			p.HasPresharedKey = p.PresharedKey != wgtypes.Key{}
+		}
+		if pc.PersistentKeepaliveInterval != nil {
+			p.PersistentKeepaliveInterval = *pc.PersistentKeepaliveInterval
+		}
+
+		d.Peers = append(d.Peers, p)
+	}
+
+	return d
+}
+
+// testPeerConfig produces a PeerConfig with a random public and preshared
+// key, and the specified endpoint and allowed IPs.
+func testPeerConfig(endpoint string, ips ...string) wgtypes.PeerConfig {
+	psk := wgtest.MustPresharedKey()
+	pc := wgtypes.PeerConfig{
+		PublicKey:    wgtest.MustPublicKey(),
+		PresharedKey: &psk,
+		Endpoint:     wgtest.MustUDPAddr(endpoint),
+	}
+
+	for _, ip := range ips {
+		pc.AllowedIPs = append(pc.AllowedIPs, wgtest.MustCIDR(ip))
+	}
+
+	return pc
+}
+
+// freePort returns a UDP port which was unused at the time of the call.
+func freePort(t *testing.T) int {
+	t.Helper()
+
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find free UDP port: %v", err)
+	}
+	defer c.Close()
+
+	return c.LocalAddr().(*net.UDPAddr).Port
+}
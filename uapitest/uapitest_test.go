@@ -0,0 +1,28 @@
+package uapitest_test
+
+import (
+	"net"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/tuntest"
+	"golang.zx2c4.com/wireguard/wgctrl/uapitest"
+)
+
+func TestRunWireGuardGo(t *testing.T) {
+	// Verify the conformance suite against an in-process wireguard-go device,
+	// which serves as the reference userspace implementation.
+	dev := device.NewDevice(
+		tuntest.NewChannelTUN().TUN(),
+		conn.NewDefaultBind(),
+		device.NewLogger(device.LogLevelSilent, ""),
+	)
+	defer dev.Close()
+
+	uapitest.Run(t, func() (net.Conn, error) {
+		c, s := net.Pipe()
+		go dev.IpcHandle(s)
+		return c, nil
+	})
+}
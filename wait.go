@@ -0,0 +1,106 @@
+package wgctrl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Polling intervals used by WaitForChange.
+const (
+	waitMinInterval = 100 * time.Millisecond
+	waitMaxInterval = 5 * time.Second
+)
+
+// A Condition reports whether WaitForChange should return. d is the current
+// state of the device, or nil if it does not exist, and events are the
+// changes to the device since it was last observed, as reported by a
+// Watcher. When a Condition is first called, events is empty.
+type Condition func(d *wgtypes.Device, events []Event) bool
+
+// EventCondition returns a Condition which holds once an Event matching f
+// occurs. For example, EventCondition(EventFilter{Types:
+// []EventType{PeerHandshake}, Peers: []wgtypes.Key{k}}) waits for the peer k
+// to complete a handshake.
+func EventCondition(f EventFilter) Condition {
+	return func(_ *wgtypes.Device, events []Event) bool {
+		for _, e := range events {
+			if f.Matches(e) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// DeviceCondition returns a Condition which holds once fn reports true for the
+// device, which is nil if it does not exist.
+func DeviceCondition(fn func(d *wgtypes.Device) bool) Condition {
+	return func(d *wgtypes.Device, _ []Event) bool { return fn(d) }
+}
+
+// WaitForChange blocks until cond holds for the device specified by name, and
+// returns the device at that time, or nil if it does not exist. This
+// simplifies scripted workflows such as verifying that a newly provisioned
+// peer completes a handshake.
+//
+// The device is polled using the same change detection as a Watcher. The
+// polling interval starts small and grows while the device is unchanged, and
+// is reset whenever an Event occurs. WaitForChange returns ctx.Err() if ctx is
+// done first, or any error other than os.ErrNotExist which occurs while
+// retrieving the device.
+func (c *Client) WaitForChange(ctx context.Context, name string, cond Condition) (*wgtypes.Device, error) {
+	var (
+		prev     []*wgtypes.Device
+		events   []Event
+		interval = waitMinInterval
+	)
+
+	for first := true; ; first = false {
+		d, err := c.Device(name)
+		switch {
+		case err == nil:
+		case errors.Is(err, os.ErrNotExist):
+			d = nil
+		default:
+			return nil, err
+		}
+
+		var cur []*wgtypes.Device
+		if d != nil {
+			cur = []*wgtypes.Device{d}
+		}
+
+		if !first {
+			events = deviceEvents(time.Now(), prev, cur)
+		}
+		prev = cur
+
+		if cond(d, events) {
+			return d, nil
+		}
+
+		// Poll quickly while the device is changing, and back off while it
+		// is not.
+		if len(events) > 0 {
+			interval = waitMinInterval
+		} else if !first {
+			interval = interval * 3 / 2
+			if interval > waitMaxInterval {
+				interval = waitMaxInterval
+			}
+		}
+
+		t := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package wgctrl
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestClientWaitForChange(t *testing.T) {
+	var (
+		pub   = wgtest.MustPublicKey()
+		calls int
+	)
+
+	// The device does not exist at first, then gains a peer, which then
+	// completes a handshake.
+	c := &Client{cs: []wginternal.Client{&testClient{
+		DeviceFunc: func(name string) (*wgtypes.Device, error) {
+			calls++
+			switch calls {
+			case 1:
+				return nil, os.ErrNotExist
+			case 2:
+				return &wgtypes.Device{Name: name, Peers: []wgtypes.Peer{{PublicKey: pub}}}, nil
+			default:
+				return &wgtypes.Device{Name: name, Peers: []wgtypes.Peer{{
+					PublicKey:         pub,
+					LastHandshakeTime: time.Unix(1600000000, 0),
+				}}}, nil
+			}
+		},
+	}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	d, err := c.WaitForChange(ctx, "wg0", EventCondition(EventFilter{
+		Types: []EventType{PeerHandshake},
+		Peers: []wgtypes.Key{pub},
+	}))
+	if err != nil {
+		t.Fatalf("failed to wait: %v", err)
+	}
+
+	if calls != 3 || d == nil || d.Peers[0].LastHandshakeTime.IsZero() {
+		t.Fatalf("unexpected device after %d calls: %v", calls, d)
+	}
+
+	// A Condition which already holds returns immediately.
+	calls = 2
+	d, err = c.WaitForChange(ctx, "wg0", DeviceCondition(func(d *wgtypes.Device) bool {
+		return d != nil && len(d.Peers) == 1
+	}))
+	if err != nil {
+		t.Fatalf("failed to wait: %v", err)
+	}
+	if calls != 3 || d == nil {
+		t.Fatalf("unexpected device after %d calls: %v", calls, d)
+	}
+}
+
+func TestClientWaitForChangeErrors(t *testing.T) {
+	errBackend := errors.New("backend failure")
+
+	tests := []struct {
+		name string
+		err  error
+		ctx  func() context.Context
+		want error
+	}{
+		{
+			name: "context",
+			err:  os.ErrNotExist,
+			ctx: func() context.Context {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx
+			},
+			want: context.Canceled,
+		},
+		{
+			name: "backend",
+			err:  errBackend,
+			ctx:  context.Background,
+			want: errBackend,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{cs: []wginternal.Client{&testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+					return nil, tt.err
+				},
+			}}}
+
+			_, err := c.WaitForChange(tt.ctx(), "wg0", DeviceCondition(func(d *wgtypes.Device) bool {
+				return d != nil
+			}))
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, but got: %v", tt.want, err)
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package wgctrl
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// An EventType is the type of change reported by an Event.
+type EventType int
+
+// Possible EventType values.
+const (
+	_ EventType = iota
+	DeviceAdded
+	DeviceRemoved
+	DeviceChanged
+	PeerAdded
+	PeerRemoved
+	PeerEndpointChanged
+	PeerHandshake
+)
+
+// String returns the string representation of an EventType.
+func (t EventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "device added"
+	case DeviceRemoved:
+		return "device removed"
+	case DeviceChanged:
+		return "device changed"
+	case PeerAdded:
+		return "peer added"
+	case PeerRemoved:
+		return "peer removed"
+	case PeerEndpointChanged:
+		return "peer endpoint changed"
+	case PeerHandshake:
+		return "peer handshake"
+	default:
+		return "unknown"
+	}
+}
+
+// An Event is a change to a device or one of its peers, observed by a
+// Watcher.
+type Event struct {
+	Type   EventType
+	Time   time.Time
+	Device string
+
+	// PublicKey identifies the peer for peer events, and is the zero Key for
+	// device events.
+	PublicKey wgtypes.Key
+
+	// Endpoint is the peer's endpoint after the change, if known.
+	Endpoint *net.UDPAddr
+
+	// LastHandshakeTime is the time of the peer's most recent handshake.
+	LastHandshakeTime time.Time
+}
+
+// An EventFilter selects the Events delivered to a subscriber. Each empty
+// field matches all Events.
+type EventFilter struct {
+	Devices []string
+	Types   []EventType
+	Peers   []wgtypes.Key
+}
+
+// Matches reports whether f selects e. A filter with Peers matches device
+// events only if Types selects them explicitly.
+func (f EventFilter) Matches(e Event) bool {
+	if len(f.Devices) > 0 && !containsString(f.Devices, e.Device) {
+		return false
+	}
+
+	if len(f.Types) > 0 {
+		var ok bool
+		for _, t := range f.Types {
+			ok = ok || t == e.Type
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if len(f.Peers) > 0 {
+		if e.PublicKey == (wgtypes.Key{}) {
+			return len(f.Types) > 0
+		}
+
+		var ok bool
+		for _, k := range f.Peers {
+			ok = ok || k == e.PublicKey
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A Backpressure specifies what a Watcher does when a subscriber's buffer is
+// full.
+type Backpressure int
+
+// Possible Backpressure values.
+const (
+	// DropOldest discards the oldest buffered Event to make room for the
+	// newest one, so a slow subscriber never delays the others.
+	DropOldest Backpressure = iota
+
+	// Block waits for the subscriber to receive Events, delaying delivery
+	// to all subscribers and further polling.
+	Block
+)
+
+// DefaultEventBuffer is the default number of Events buffered for each
+// subscriber.
+const DefaultEventBuffer = 64
+
+// SubscribeOptions configures a Subscription.
+type SubscribeOptions struct {
+	// Filter selects the Events delivered to the subscriber.
+	Filter EventFilter
+
+	// Buffer is the number of Events buffered for the subscriber. If zero,
+	// DefaultEventBuffer is used.
+	Buffer int
+
+	// Backpressure specifies what happens when the buffer is full.
+	Backpressure Backpressure
+}
+
+// A Subscription receives the Events selected by its filter from a Watcher.
+type Subscription struct {
+	// C delivers Events. It is closed when the Subscription is closed or
+	// the Watcher stops running.
+	C <-chan Event
+
+	c       chan Event
+	opts    SubscribeOptions
+	w       *Watcher
+	done    chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of Events discarded because the subscriber's
+// buffer was full.
+func (s *Subscription) Dropped() uint64 { return s.dropped.Load() }
+
+// Close stops the delivery of Events and closes C. It is safe to call Close
+// more than once, and concurrently with delivery.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		// Unblock any pending delivery before waiting for it to finish.
+		close(s.done)
+
+		s.w.mu.Lock()
+		delete(s.w.subs, s)
+		s.w.mu.Unlock()
+
+		s.mu.Lock()
+		close(s.c)
+		s.mu.Unlock()
+	})
+}
+
+// deliver sends e to the subscriber according to its Backpressure.
+func (s *Subscription) deliver(ctx context.Context, e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+
+	if s.opts.Backpressure == Block {
+		select {
+		case s.c <- e:
+		case <-s.done:
+		case <-ctx.Done():
+		}
+		return
+	}
+
+	for {
+		select {
+		case s.c <- e:
+			return
+		default:
+		}
+
+		select {
+		case <-s.c:
+			s.dropped.Add(1)
+		default:
+		}
+	}
+}
+
+// A Watcher polls a Client's devices and delivers an Event for each change
+// to any number of subscribers, so that a single Client can feed several
+// consumers with different interests. Its methods are safe for concurrent
+// use.
+type Watcher struct {
+	c        *Client
+	interval time.Duration
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	prev    []*wgtypes.Device
+	stopped bool
+}
+
+// NewWatcher creates a Watcher which polls the devices of c every interval
+// once Run is called.
+func (c *Client) NewWatcher(interval time.Duration) *Watcher {
+	return &Watcher{
+		c:        c,
+		interval: interval,
+		subs:     make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe adds a subscriber to w. The Subscription should be closed when
+// it is no longer needed. If Run has already returned, the Subscription is
+// closed immediately.
+func (w *Watcher) Subscribe(opts SubscribeOptions) *Subscription {
+	n := opts.Buffer
+	if n <= 0 {
+		n = DefaultEventBuffer
+	}
+
+	c := make(chan Event, n)
+	s := &Subscription{
+		C:    c,
+		c:    c,
+		opts: opts,
+		w:    w,
+		done: make(chan struct{}),
+	}
+
+	w.mu.Lock()
+	stopped := w.stopped
+	if !stopped {
+		w.subs[s] = struct{}{}
+	}
+	w.mu.Unlock()
+
+	if stopped {
+		s.Close()
+	}
+
+	return s
+}
+
+// Run polls devices and delivers Events until ctx is canceled or retrieving
+// devices fails, and then closes all Subscriptions. On its first poll, every
+// existing device and peer is reported as added.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer func() {
+		w.mu.Lock()
+		w.stopped = true
+		subs := make([]*Subscription, 0, len(w.subs))
+		for s := range w.subs {
+			subs = append(subs, s)
+		}
+		w.mu.Unlock()
+
+		for _, s := range subs {
+			s.Close()
+		}
+	}()
+
+	t := time.NewTicker(w.interval)
+	defer t.Stop()
+
+	for {
+		if err := w.poll(ctx, time.Now()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// poll retrieves devices and delivers the Events since the previous poll.
+func (w *Watcher) poll(ctx context.Context, now time.Time) error {
+	cur, err := w.c.Devices()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	events := deviceEvents(now, w.prev, cur)
+	w.prev = cur
+
+	subs := make([]*Subscription, 0, len(w.subs))
+	for s := range w.subs {
+		subs = append(subs, s)
+	}
+	w.mu.Unlock()
+
+	for _, e := range events {
+		for _, s := range subs {
+			if s.opts.Filter.Matches(e) {
+				s.deliver(ctx, e)
+			}
+		}
+	}
+
+	return nil
+}
+
+// deviceEvents describes the changes between two sets of devices at time
+// now, ordered by device and then by peer.
+func deviceEvents(now time.Time, prev, cur []*wgtypes.Device) []Event {
+	before := make(map[string]*wgtypes.Device, len(prev))
+	for _, d := range prev {
+		before[d.Name] = d
+	}
+
+	after := make(map[string]*wgtypes.Device, len(cur))
+	for _, d := range cur {
+		after[d.Name] = d
+	}
+
+	names := make([]string, 0, len(before)+len(after))
+	for n := range before {
+		names = append(names, n)
+	}
+	for n := range after {
+		if before[n] == nil {
+			names = append(names, n)
+		}
+	}
+	sort.Strings(names)
+
+	var events []Event
+	for _, n := range names {
+		b, a := before[n], after[n]
+		switch {
+		case a == nil:
+			events = append(events, Event{Type: DeviceRemoved, Time: now, Device: n})
+			continue
+		case b == nil:
+			events = append(events, Event{Type: DeviceAdded, Time: now, Device: n})
+			b = &wgtypes.Device{Name: n}
+		case b.PublicKey != a.PublicKey || b.ListenPort != a.ListenPort || b.FirewallMark != a.FirewallMark:
+			events = append(events, Event{Type: DeviceChanged, Time: now, Device: n})
+		}
+
+		events = append(events, peerEvents(now, n, b.Peers, a.Peers)...)
+	}
+
+	return events
+}
+
+// peerEvents describes the changes between two sets of peers of the device
+// name at time now, ordered by public key.
+func peerEvents(now time.Time, name string, prev, cur []wgtypes.Peer) []Event {
+	before := make(map[wgtypes.Key]*wgtypes.Peer, len(prev))
+	for i := range prev {
+		before[prev[i].PublicKey] = &prev[i]
+	}
+
+	var events []Event
+	for i := range cur {
+		p := &cur[i]
+		e := Event{
+			Time:              now,
+			Device:            name,
+			PublicKey:         p.PublicKey,
+			Endpoint:          p.Endpoint,
+			LastHandshakeTime: p.LastHandshakeTime,
+		}
+
+		b, ok := before[p.PublicKey]
+		delete(before, p.PublicKey)
+		if !ok {
+			e.Type = PeerAdded
+			events = append(events, e)
+			b = &wgtypes.Peer{}
+		}
+
+		if udpAddrString(b.Endpoint) != udpAddrString(p.Endpoint) && p.Endpoint != nil {
+			e.Type = PeerEndpointChanged
+			events = append(events, e)
+		}
+		if !b.LastHandshakeTime.Equal(p.LastHandshakeTime) && !p.LastHandshakeTime.IsZero() {
+			e.Type = PeerHandshake
+			events = append(events, e)
+		}
+	}
+
+	for _, p := range before {
+		events = append(events, Event{
+			Type:      PeerRemoved,
+			Time:      now,
+			Device:    name,
+			PublicKey: p.PublicKey,
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return bytes.Compare(events[i].PublicKey[:], events[j].PublicKey[:]) < 0
+	})
+
+	return events
+}
+
+// udpAddrString formats addr, which may be nil.
+func udpAddrString(addr *net.UDPAddr) string {
+	if addr == nil {
+		return ""
+	}
+
+	return addr.String()
+}
+
+// containsString reports whether ss contains s.
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,266 @@
+package wgctrl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestDeviceEvents(t *testing.T) {
+	var (
+		a   = wgtest.MustPublicKey()
+		b   = wgtest.MustPublicKey()
+		ep  = wgtest.MustUDPAddr("192.0.2.1:51820")
+		hs  = time.Unix(1600000000, 0)
+		now = hs.Add(time.Minute)
+	)
+
+	// Order the keys as deviceEvents does.
+	if string(b[:]) < string(a[:]) {
+		a, b = b, a
+	}
+
+	prev := []*wgtypes.Device{
+		{
+			Name:       "wg0",
+			ListenPort: 51820,
+			Peers:      []wgtypes.Peer{{PublicKey: a}, {PublicKey: b}},
+		},
+		{Name: "wg1"},
+	}
+
+	cur := []*wgtypes.Device{
+		{
+			Name:       "wg0",
+			ListenPort: 51821,
+			Peers: []wgtypes.Peer{{
+				PublicKey:         a,
+				Endpoint:          ep,
+				LastHandshakeTime: hs,
+			}},
+		},
+		{
+			Name:  "wg2",
+			Peers: []wgtypes.Peer{{PublicKey: b}},
+		},
+	}
+
+	want := []Event{
+		{Type: DeviceChanged, Time: now, Device: "wg0"},
+		{
+			Type:              PeerEndpointChanged,
+			Time:              now,
+			Device:            "wg0",
+			PublicKey:         a,
+			Endpoint:          ep,
+			LastHandshakeTime: hs,
+		},
+		{
+			Type:              PeerHandshake,
+			Time:              now,
+			Device:            "wg0",
+			PublicKey:         a,
+			Endpoint:          ep,
+			LastHandshakeTime: hs,
+		},
+		{Type: PeerRemoved, Time: now, Device: "wg0", PublicKey: b},
+		{Type: DeviceRemoved, Time: now, Device: "wg1"},
+		{Type: DeviceAdded, Time: now, Device: "wg2"},
+		{Type: PeerAdded, Time: now, Device: "wg2", PublicKey: b},
+	}
+
+	if diff := cmp.Diff(want, deviceEvents(now, prev, cur)); diff != "" {
+		t.Fatalf("unexpected Events (-want +got):\n%s", diff)
+	}
+}
+
+func TestEventFilterMatches(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+	)
+
+	tests := []struct {
+		name string
+		f    EventFilter
+		e    Event
+		ok   bool
+	}{
+		{
+			name: "empty",
+			e:    Event{Type: DeviceAdded, Device: "wg0"},
+			ok:   true,
+		},
+		{
+			name: "device",
+			f:    EventFilter{Devices: []string{"wg1"}},
+			e:    Event{Type: DeviceAdded, Device: "wg0"},
+		},
+		{
+			name: "type",
+			f:    EventFilter{Types: []EventType{PeerHandshake, PeerAdded}},
+			e:    Event{Type: PeerAdded, Device: "wg0", PublicKey: a},
+			ok:   true,
+		},
+		{
+			name: "peer",
+			f:    EventFilter{Peers: []wgtypes.Key{a}},
+			e:    Event{Type: PeerAdded, Device: "wg0", PublicKey: b},
+		},
+		{
+			name: "peer device event",
+			f:    EventFilter{Peers: []wgtypes.Key{a}},
+			e:    Event{Type: DeviceRemoved, Device: "wg0"},
+		},
+		{
+			name: "peer explicit device event",
+			f: EventFilter{
+				Types: []EventType{DeviceRemoved},
+				Peers: []wgtypes.Key{a},
+			},
+			e:  Event{Type: DeviceRemoved, Device: "wg0"},
+			ok: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.ok, tt.f.Matches(tt.e)); diff != "" {
+				t.Fatalf("unexpected match (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWatcherSubscribers(t *testing.T) {
+	pub := wgtest.MustPublicKey()
+
+	devices := []*wgtypes.Device{{Name: "wg0"}}
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DevicesFunc: func() ([]*wgtypes.Device, error) {
+				return devices, nil
+			},
+		}},
+	}
+
+	w := c.NewWatcher(time.Hour)
+
+	// A blocking subscriber receives every event, while a subscriber with a
+	// small buffer only retains the most recent ones.
+	var (
+		all  = w.Subscribe(SubscribeOptions{Buffer: 1, Backpressure: Block})
+		peer = w.Subscribe(SubscribeOptions{
+			Filter: EventFilter{Peers: []wgtypes.Key{pub}},
+			Buffer: 1,
+		})
+		wg1 = w.Subscribe(SubscribeOptions{
+			Filter: EventFilter{Devices: []string{"wg1"}},
+		})
+	)
+	defer wg1.Close()
+
+	// poll polls devices while receiving from the blocking subscriber.
+	poll := func() []EventType {
+		done := make(chan error)
+		go func() { done <- w.poll(context.Background(), time.Now()) }()
+
+		var types []EventType
+		for {
+			select {
+			case e := <-all.C:
+				types = append(types, e.Type)
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("failed to poll: %v", err)
+				}
+
+				for len(all.C) > 0 {
+					types = append(types, (<-all.C).Type)
+				}
+
+				return types
+			}
+		}
+	}
+
+	if diff := cmp.Diff([]EventType{DeviceAdded}, poll()); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	devices = []*wgtypes.Device{{
+		Name: "wg0",
+		Peers: []wgtypes.Peer{{
+			PublicKey:         pub,
+			Endpoint:          wgtest.MustUDPAddr("192.0.2.1:51820"),
+			LastHandshakeTime: time.Now(),
+		}},
+	}}
+
+	want := []EventType{PeerAdded, PeerEndpointChanged, PeerHandshake}
+	if diff := cmp.Diff(want, poll()); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	// PeerAdded and PeerEndpointChanged were dropped to make room for
+	// PeerHandshake.
+	if e := <-peer.C; e.Type != PeerHandshake {
+		t.Fatalf("unexpected event: %v", e.Type)
+	}
+	if n := peer.Dropped(); n != 2 {
+		t.Fatalf("unexpected number of dropped events: %d", n)
+	}
+	if n := len(wg1.C); n != 0 {
+		t.Fatalf("unexpected events for wg1: %d", n)
+	}
+
+	// Closed subscribers no longer receive events, and their channels are
+	// closed.
+	peer.Close()
+	all.Close()
+	if _, ok := <-all.C; ok {
+		t.Fatal("expected closed channel")
+	}
+
+	if err := w.poll(context.Background(), time.Now()); err != nil {
+		t.Fatalf("failed to poll: %v", err)
+	}
+}
+
+func TestWatcherSubscribeAfterRun(t *testing.T) {
+	c := &Client{
+		cs: []wginternal.Client{&testClient{
+			DevicesFunc: func() ([]*wgtypes.Device, error) {
+				return nil, nil
+			},
+		}},
+	}
+
+	w := c.NewWatcher(time.Hour)
+	before := w.Subscribe(SubscribeOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.Run(ctx); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	// Subscribers are closed when Run returns, including those which
+	// subscribe after it has returned.
+	after := w.Subscribe(SubscribeOptions{})
+	for _, s := range []*Subscription{before, after} {
+		select {
+		case _, ok := <-s.C:
+			if ok {
+				t.Fatal("expected closed channel")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for closed channel")
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package wgacct
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A StatusType is the type of an accounting Record, as carried by the
+// Acct-Status-Type attribute.
+type StatusType int
+
+// Possible StatusType values.
+const (
+	Start         StatusType = 1
+	Stop          StatusType = 2
+	InterimUpdate StatusType = 3
+)
+
+// String returns the string representation of a StatusType.
+func (s StatusType) String() string {
+	switch s {
+	case Start:
+		return "Start"
+	case Stop:
+		return "Stop"
+	case InterimUpdate:
+		return "Interim-Update"
+	default:
+		return "unknown"
+	}
+}
+
+// A TerminateCause is the reason a session stopped, as carried by the
+// Acct-Terminate-Cause attribute.
+type TerminateCause int
+
+// TerminateCause values used by an Exporter.
+const (
+	// CauseIdleTimeout indicates that the peer's handshakes stalled.
+	CauseIdleTimeout TerminateCause = 4
+
+	// CauseAdminReset indicates that the peer or its device was removed.
+	CauseAdminReset TerminateCause = 6
+
+	// CausePortReinit indicates that the peer's counters were reset, such
+	// as when its device was recreated.
+	CausePortReinit TerminateCause = 21
+)
+
+// A Record is an accounting record for a peer's session.
+type Record struct {
+	// Status is the type of the record.
+	Status StatusType
+
+	// SessionID uniquely identifies the session.
+	SessionID string
+
+	// Device and PublicKey identify the peer.
+	Device    string
+	PublicKey wgtypes.Key
+
+	// Endpoint is the peer's endpoint, if known.
+	Endpoint string
+
+	// Time is the time of the event which produced the record.
+	Time time.Time
+
+	// SessionTime is the duration of the session at Time.
+	SessionTime time.Duration
+
+	// InputOctets and OutputOctets are the octets received from and sent to
+	// the peer during the session.
+	InputOctets, OutputOctets int64
+
+	// TerminateCause is the reason the session stopped, for Stop records.
+	TerminateCause TerminateCause
+}
+
+// A Sender delivers accounting Records.
+type Sender interface {
+	Send(ctx context.Context, r Record) error
+}
+
+// A SenderFunc adapts a function to a Sender.
+type SenderFunc func(ctx context.Context, r Record) error
+
+// Send implements Sender.
+func (fn SenderFunc) Send(ctx context.Context, r Record) error { return fn(ctx, r) }
+
+// Defaults for Exporter fields.
+const (
+	DefaultIdleTimeout = 3 * time.Minute
+)
+
+// An Exporter produces accounting Records for the peers of WireGuard devices
+// from successive observations, and delivers them to a Sender. Its methods
+// are safe for concurrent use.
+type Exporter struct {
+	// Sender receives each Record.
+	Sender Sender
+
+	// Interim, if not zero, is the interval between interim update Records
+	// for each session.
+	Interim time.Duration
+
+	// IdleTimeout is the age of a peer's last handshake after which its
+	// session stops. If zero, DefaultIdleTimeout is used.
+	IdleTimeout time.Duration
+
+	mu       sync.Mutex
+	tracker  wgpeer.StatsTracker
+	sessions map[sessionKey]*session
+}
+
+// A sessionKey identifies a peer on a device.
+type sessionKey struct {
+	device string
+	peer   wgtypes.Key
+}
+
+// A session is the state of a peer's active session.
+type session struct {
+	id         string
+	start      time.Time
+	lastRecord time.Time
+	endpoint   string
+	rx, tx     int64
+}
+
+// Observe records the state of the peers of devices at time now, and sends
+// a Record for each session which started, stopped, or is due an interim
+// update. Devices which are not in devices are treated as removed. If the
+// Sender fails, the remaining Records are still sent and the errors are
+// returned together.
+func (e *Exporter) Observe(ctx context.Context, now time.Time, devices []*wgtypes.Device) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.sessions == nil {
+		e.sessions = make(map[sessionKey]*session)
+	}
+
+	idle := e.IdleTimeout
+	if idle == 0 {
+		idle = DefaultIdleTimeout
+	}
+
+	peers := make(map[sessionKey]*wgtypes.Peer)
+	for _, d := range devices {
+		for i := range d.Peers {
+			peers[sessionKey{device: d.Name, peer: d.Peers[i].PublicKey}] = &d.Peers[i]
+		}
+	}
+
+	var records []Record
+	for _, delta := range e.tracker.Observe(devices) {
+		k := sessionKey{device: delta.Device, peer: delta.PublicKey}
+		p := peers[k]
+		active := !p.LastHandshakeTime.IsZero() && now.Sub(p.LastHandshakeTime) <= idle
+
+		s, ok := e.sessions[k]
+		if ok {
+			if delta.Reset {
+				// The counters since the reset belong to a new session.
+				records = append(records, e.stop(k, s, now, CausePortReinit))
+				ok = false
+			} else {
+				s.rx += delta.ReceiveBytes
+				s.tx += delta.TransmitBytes
+				if p.Endpoint != nil {
+					s.endpoint = p.Endpoint.String()
+				}
+
+				switch {
+				case !active:
+					records = append(records, e.stop(k, s, now, CauseIdleTimeout))
+				case e.Interim != 0 && now.Sub(s.lastRecord) >= e.Interim:
+					s.lastRecord = now
+					records = append(records, s.record(k, InterimUpdate, now))
+				}
+			}
+		}
+
+		if !ok && active {
+			// Traffic since the previous observation belongs to the
+			// handshake which started the session.
+			s := &session{
+				id:         sessionID(),
+				start:      now,
+				lastRecord: now,
+				rx:         delta.ReceiveBytes,
+				tx:         delta.TransmitBytes,
+			}
+			if p.Endpoint != nil {
+				s.endpoint = p.Endpoint.String()
+			}
+
+			e.sessions[k] = s
+			records = append(records, s.record(k, Start, now))
+		}
+	}
+
+	var removed []Record
+	for k, s := range e.sessions {
+		if peers[k] == nil {
+			removed = append(removed, e.stop(k, s, now, CauseAdminReset))
+		}
+	}
+
+	sort.Slice(removed, func(i, j int) bool {
+		if removed[i].Device != removed[j].Device {
+			return removed[i].Device < removed[j].Device
+		}
+
+		return bytes.Compare(removed[i].PublicKey[:], removed[j].PublicKey[:]) < 0
+	})
+	records = append(records, removed...)
+
+	var errs []error
+	for _, r := range records {
+		if err := e.Sender.Send(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Run observes the devices of c every interval until ctx is canceled. Errors
+// from the Sender do not stop Run.
+func (e *Exporter) Run(ctx context.Context, c wgpeer.Client, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		devices, err := c.Devices()
+		if err != nil {
+			return err
+		}
+
+		_ = e.Observe(ctx, time.Now(), devices)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// stop ends the session s of the peer k, returning its Stop Record.
+func (e *Exporter) stop(k sessionKey, s *session, now time.Time, cause TerminateCause) Record {
+	delete(e.sessions, k)
+
+	r := s.record(k, Stop, now)
+	r.TerminateCause = cause
+	return r
+}
+
+// record produces a Record of type status for the session s of the peer k.
+func (s *session) record(k sessionKey, status StatusType, now time.Time) Record {
+	return Record{
+		Status:       status,
+		SessionID:    s.id,
+		Device:       k.device,
+		PublicKey:    k.peer,
+		Endpoint:     s.endpoint,
+		Time:         now,
+		SessionTime:  now.Sub(s.start),
+		InputOctets:  s.rx,
+		OutputOctets: s.tx,
+	}
+}
+
+// sessionID generates a random session ID.
+func sessionID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("wgacct: failed to generate session ID: " + err.Error())
+	}
+
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,217 @@
+package wgacct_test
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgacct"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestExporterSessions(t *testing.T) {
+	var (
+		pub      = wgtest.MustPublicKey()
+		endpoint = wgtest.MustUDPAddr("192.0.2.1:51820")
+		t0       = time.Unix(1600000000, 0)
+	)
+
+	device := func(handshake time.Time, rx, tx int64) []*wgtypes.Device {
+		return []*wgtypes.Device{{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{{
+				PublicKey:         pub,
+				Endpoint:          endpoint,
+				LastHandshakeTime: handshake,
+				ReceiveBytes:      rx,
+				TransmitBytes:     tx,
+			}},
+		}}
+	}
+
+	var got []wgacct.Record
+	e := &wgacct.Exporter{
+		Sender: wgacct.SenderFunc(func(_ context.Context, r wgacct.Record) error {
+			got = append(got, r)
+			return nil
+		}),
+		Interim: 10 * time.Minute,
+	}
+
+	record := func(status wgacct.StatusType, now time.Time, session time.Duration, rx, tx int64, cause wgacct.TerminateCause) wgacct.Record {
+		return wgacct.Record{
+			Status:         status,
+			Device:         "wg0",
+			PublicKey:      pub,
+			Endpoint:       endpoint.String(),
+			Time:           now,
+			SessionTime:    session,
+			InputOctets:    rx,
+			OutputOctets:   tx,
+			TerminateCause: cause,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		devices []*wgtypes.Device
+		want    []wgacct.Record
+	}{
+		{
+			name:    "no handshake",
+			now:     t0,
+			devices: device(time.Time{}, 0, 0),
+		},
+		{
+			name:    "start",
+			now:     t0.Add(time.Minute),
+			devices: device(t0.Add(time.Minute), 100, 200),
+			want: []wgacct.Record{
+				record(wgacct.Start, t0.Add(time.Minute), 0, 100, 200, 0),
+			},
+		},
+		{
+			name:    "active",
+			now:     t0.Add(5 * time.Minute),
+			devices: device(t0.Add(4*time.Minute), 300, 400),
+		},
+		{
+			name:    "interim",
+			now:     t0.Add(11 * time.Minute),
+			devices: device(t0.Add(10*time.Minute), 1000, 2000),
+			want: []wgacct.Record{
+				record(wgacct.InterimUpdate, t0.Add(11*time.Minute), 10*time.Minute, 1000, 2000, 0),
+			},
+		},
+		{
+			name:    "reset",
+			now:     t0.Add(12 * time.Minute),
+			devices: device(t0.Add(12*time.Minute), 10, 20),
+			want: []wgacct.Record{
+				record(wgacct.Stop, t0.Add(12*time.Minute), 11*time.Minute, 1000, 2000, wgacct.CausePortReinit),
+				record(wgacct.Start, t0.Add(12*time.Minute), 0, 10, 20, 0),
+			},
+		},
+		{
+			name:    "idle",
+			now:     t0.Add(20 * time.Minute),
+			devices: device(t0.Add(12*time.Minute), 50, 60),
+			want: []wgacct.Record{
+				record(wgacct.Stop, t0.Add(20*time.Minute), 8*time.Minute, 50, 60, wgacct.CauseIdleTimeout),
+			},
+		},
+		{
+			name:    "restart",
+			now:     t0.Add(21 * time.Minute),
+			devices: device(t0.Add(21*time.Minute), 70, 80),
+			want: []wgacct.Record{
+				record(wgacct.Start, t0.Add(21*time.Minute), 0, 20, 20, 0),
+			},
+		},
+		{
+			name: "removed",
+			now:  t0.Add(22 * time.Minute),
+			want: []wgacct.Record{
+				record(wgacct.Stop, t0.Add(22*time.Minute), time.Minute, 20, 20, wgacct.CauseAdminReset),
+			},
+		},
+	}
+
+	var sessions []string
+	for _, tt := range tests {
+		got = nil
+		if err := e.Observe(context.Background(), tt.now, tt.devices); err != nil {
+			t.Fatalf("%s: failed to observe: %v", tt.name, err)
+		}
+
+		for _, r := range got {
+			if r.Status == wgacct.Start {
+				sessions = append(sessions, r.SessionID)
+			}
+		}
+
+		if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreFields(wgacct.Record{}, "SessionID")); diff != "" {
+			t.Fatalf("%s: unexpected Records (-want +got):\n%s", tt.name, diff)
+		}
+	}
+
+	if len(sessions) != 3 || sessions[0] == sessions[1] || sessions[1] == sessions[2] {
+		t.Fatalf("expected 3 distinct session IDs, but got: %v", sessions)
+	}
+}
+
+func TestExporterSenderErrors(t *testing.T) {
+	errSend := errors.New("send failed")
+
+	var n int
+	e := &wgacct.Exporter{
+		Sender: wgacct.SenderFunc(func(_ context.Context, _ wgacct.Record) error {
+			n++
+			return errSend
+		}),
+	}
+
+	now := time.Now()
+	devices := []*wgtypes.Device{{
+		Name: "wg0",
+		Peers: []wgtypes.Peer{
+			{PublicKey: wgtest.MustPublicKey(), LastHandshakeTime: now},
+			{PublicKey: wgtest.MustPublicKey(), LastHandshakeTime: now},
+		},
+	}}
+
+	err := e.Observe(context.Background(), now, devices)
+	if !errors.Is(err, errSend) {
+		t.Fatalf("expected send error, but got: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 sends, but got: %d", n)
+	}
+}
+
+func TestRecordAttributes(t *testing.T) {
+	pub := wgtest.MustPublicKey()
+
+	r := wgacct.Record{
+		Status:         wgacct.Stop,
+		SessionID:      "0011223344556677",
+		Device:         "wg0",
+		PublicKey:      pub,
+		Endpoint:       "192.0.2.1:51820",
+		Time:           time.Unix(1600000000, 0),
+		SessionTime:    90 * time.Second,
+		InputOctets:    5<<32 + 1,
+		OutputOctets:   2,
+		TerminateCause: wgacct.CauseIdleTimeout,
+	}
+
+	u32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+
+	want := []wgacct.Attribute{
+		{Type: wgacct.AttrAcctStatusType, Value: u32(2)},
+		{Type: wgacct.AttrAcctSessionID, Value: []byte("0011223344556677")},
+		{Type: wgacct.AttrUserName, Value: []byte(pub.String())},
+		{Type: wgacct.AttrNASPortID, Value: []byte("wg0")},
+		{Type: wgacct.AttrCallingStationID, Value: []byte("192.0.2.1:51820")},
+		{Type: wgacct.AttrEventTimestamp, Value: u32(1600000000)},
+		{Type: wgacct.AttrAcctSessionTime, Value: u32(90)},
+		{Type: wgacct.AttrAcctInputOctets, Value: u32(1)},
+		{Type: wgacct.AttrAcctOutputOctets, Value: u32(2)},
+		{Type: wgacct.AttrAcctInputGigawords, Value: u32(5)},
+		{Type: wgacct.AttrAcctTerminateCause, Value: u32(4)},
+	}
+
+	if diff := cmp.Diff(want, r.Attributes()); diff != "" {
+		t.Fatalf("unexpected Attributes (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,11 @@
+// Package wgacct exports per-peer accounting records for WireGuard devices,
+// in the structure used by RADIUS accounting (RFC 2866).
+//
+// WireGuard has no notion of sessions, so an Exporter derives them from
+// handshakes: a session starts when a peer completes a handshake, and stops
+// when its handshakes stall or it is removed. Start, interim update, and stop
+// Records carry the octets counted by a wgpeer.StatsTracker during the
+// session, and are delivered to a pluggable Sender. RADIUSSender sends them
+// to a RADIUS accounting server, and Record.Attributes allows them to be
+// encoded for any other RADIUS client.
+package wgacct // import "golang.zx2c4.com/wireguard/wgctrl/wgacct"
@@ -0,0 +1,239 @@
+package wgacct
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// RADIUS attribute types used in accounting Records, from RFC 2865, RFC 2866,
+// and RFC 2869.
+const (
+	AttrUserName            = 1
+	AttrCallingStationID    = 31
+	AttrNASIdentifier       = 32
+	AttrAcctStatusType      = 40
+	AttrAcctInputOctets     = 42
+	AttrAcctOutputOctets    = 43
+	AttrAcctSessionID       = 44
+	AttrAcctSessionTime     = 46
+	AttrAcctTerminateCause  = 49
+	AttrAcctInputGigawords  = 52
+	AttrAcctOutputGigawords = 53
+	AttrEventTimestamp      = 55
+	AttrNASPortID           = 87
+)
+
+// An Attribute is a RADIUS attribute.
+type Attribute struct {
+	Type  uint8
+	Value []byte
+}
+
+// Attributes encodes r as RADIUS accounting attributes. The peer's public
+// key is the User-Name, its device is the NAS-Port-Id, and its endpoint is
+// the Calling-Station-Id. Octet counts of 4GiB or more are split between the
+// octets and gigawords attributes.
+func (r Record) Attributes() []Attribute {
+	u32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+
+	attrs := []Attribute{
+		{Type: AttrAcctStatusType, Value: u32(uint32(r.Status))},
+		{Type: AttrAcctSessionID, Value: []byte(r.SessionID)},
+		{Type: AttrUserName, Value: []byte(r.PublicKey.String())},
+		{Type: AttrNASPortID, Value: []byte(r.Device)},
+	}
+
+	if r.Endpoint != "" {
+		attrs = append(attrs, Attribute{Type: AttrCallingStationID, Value: []byte(r.Endpoint)})
+	}
+
+	attrs = append(attrs, Attribute{Type: AttrEventTimestamp, Value: u32(uint32(r.Time.Unix()))})
+
+	if r.Status == Start {
+		return attrs
+	}
+
+	attrs = append(attrs,
+		Attribute{Type: AttrAcctSessionTime, Value: u32(uint32(r.SessionTime / time.Second))},
+		Attribute{Type: AttrAcctInputOctets, Value: u32(uint32(r.InputOctets))},
+		Attribute{Type: AttrAcctOutputOctets, Value: u32(uint32(r.OutputOctets))},
+	)
+
+	if gw := uint32(r.InputOctets >> 32); gw > 0 {
+		attrs = append(attrs, Attribute{Type: AttrAcctInputGigawords, Value: u32(gw)})
+	}
+	if gw := uint32(r.OutputOctets >> 32); gw > 0 {
+		attrs = append(attrs, Attribute{Type: AttrAcctOutputGigawords, Value: u32(gw)})
+	}
+
+	if r.Status == Stop {
+		attrs = append(attrs, Attribute{Type: AttrAcctTerminateCause, Value: u32(uint32(r.TerminateCause))})
+	}
+
+	return attrs
+}
+
+// RADIUS packet codes for accounting.
+const (
+	codeAccountingRequest  = 4
+	codeAccountingResponse = 5
+)
+
+// DefaultRetransmit is the default interval between Accounting-Request
+// retransmissions.
+const DefaultRetransmit = 2 * time.Second
+
+// A RADIUSSender is a Sender which sends each Record to a RADIUS accounting
+// server in an Accounting-Request, retransmitting it until the server
+// responds or the context passed to Send is canceled.
+type RADIUSSender struct {
+	// NASIdentifier, if set, identifies this host to the server.
+	NASIdentifier string
+
+	// Retransmit is the interval between retransmissions of a request. If
+	// zero, DefaultRetransmit is used.
+	Retransmit time.Duration
+
+	mu     sync.Mutex
+	c      net.Conn
+	secret []byte
+	id     uint8
+}
+
+var _ Sender = &RADIUSSender{}
+
+// DialRADIUS creates a RADIUSSender which sends Records to the accounting
+// server at addr, in host:port form, using the shared secret.
+func DialRADIUS(addr string, secret []byte) (*RADIUSSender, error) {
+	c, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("wgacct: failed to dial RADIUS server: %v", err)
+	}
+
+	return &RADIUSSender{c: c, secret: secret}, nil
+}
+
+// Close closes the RADIUSSender's connection.
+func (s *RADIUSSender) Close() error { return s.c.Close() }
+
+// Send implements Sender. Sends are serialized, so that each response can be
+// matched to its request.
+func (s *RADIUSSender) Send(ctx context.Context, r Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	attrs := r.Attributes()
+	if s.NASIdentifier != "" {
+		attrs = append(attrs, Attribute{Type: AttrNASIdentifier, Value: []byte(s.NASIdentifier)})
+	}
+
+	s.id++
+	req, err := accountingRequest(s.id, attrs, s.secret)
+	if err != nil {
+		return err
+	}
+
+	retransmit := s.Retransmit
+	if retransmit == 0 {
+		retransmit = DefaultRetransmit
+	}
+
+	b := make([]byte, 4096)
+	for {
+		if _, err := s.c.Write(req); err != nil {
+			return fmt.Errorf("wgacct: failed to send Accounting-Request: %v", err)
+		}
+
+		deadline := time.Now().Add(retransmit)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := s.c.SetReadDeadline(deadline); err != nil {
+			return err
+		}
+
+		for {
+			n, err := s.c.Read(b)
+			if err != nil {
+				var nerr net.Error
+				if !errors.As(err, &nerr) || !nerr.Timeout() {
+					return fmt.Errorf("wgacct: failed to read Accounting-Response: %v", err)
+				}
+
+				break
+			}
+
+			// Ignore stray packets and responses to earlier requests.
+			if validResponse(b[:n], req, s.secret) {
+				return nil
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("wgacct: no Accounting-Response for session %s: %w", r.SessionID, err)
+		}
+	}
+}
+
+// accountingRequest produces an Accounting-Request packet with identifier id
+// carrying attrs, authenticated using secret as described in RFC 2866.
+func accountingRequest(id uint8, attrs []Attribute, secret []byte) ([]byte, error) {
+	b := make([]byte, 20, 4096)
+	b[0] = codeAccountingRequest
+	b[1] = id
+
+	for _, a := range attrs {
+		if len(a.Value) > 253 {
+			return nil, fmt.Errorf("wgacct: RADIUS attribute %d is too long", a.Type)
+		}
+
+		b = append(b, a.Type, byte(2+len(a.Value)))
+		b = append(b, a.Value...)
+	}
+
+	if len(b) > 4096 {
+		return nil, errors.New("wgacct: Accounting-Request is too long")
+	}
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+
+	// The authenticator is computed over the packet with a zero
+	// authenticator.
+	h := md5.New()
+	h.Write(b)
+	h.Write(secret)
+	copy(b[4:20], h.Sum(nil))
+
+	return b, nil
+}
+
+// validResponse reports whether b is an authentic Accounting-Response to req.
+func validResponse(b, req, secret []byte) bool {
+	if len(b) < 20 || b[0] != codeAccountingResponse || b[1] != req[1] {
+		return false
+	}
+
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	if n < 20 || n > len(b) {
+		return false
+	}
+
+	// The response authenticator is computed using the request's
+	// authenticator.
+	h := md5.New()
+	h.Write(b[:4])
+	h.Write(req[4:20])
+	h.Write(b[20:n])
+	h.Write(secret)
+
+	return string(h.Sum(nil)) == string(b[4:20])
+}
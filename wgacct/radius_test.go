@@ -0,0 +1,125 @@
+package wgacct_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgacct"
+)
+
+func TestRADIUSSender(t *testing.T) {
+	secret := []byte("secret")
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer pc.Close()
+
+	reqC := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, 4096)
+		for i := 0; ; i++ {
+			n, addr, err := pc.ReadFrom(b)
+			if err != nil {
+				return
+			}
+
+			// Drop the first request to force a retransmission.
+			if i == 0 {
+				continue
+			}
+
+			req := append([]byte(nil), b[:n]...)
+			reqC <- req
+
+			// Accounting-Response with no attributes.
+			res := make([]byte, 20)
+			res[0] = 5
+			res[1] = req[1]
+			binary.BigEndian.PutUint16(res[2:4], 20)
+
+			h := md5.New()
+			h.Write(res[:4])
+			h.Write(req[4:20])
+			h.Write(secret)
+			copy(res[4:20], h.Sum(nil))
+
+			_, _ = pc.WriteTo(res, addr)
+		}
+	}()
+
+	s, err := wgacct.DialRADIUS(pc.LocalAddr().String(), secret)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer s.Close()
+	s.NASIdentifier = "vpn1"
+	s.Retransmit = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	r := wgacct.Record{
+		Status:    wgacct.Start,
+		SessionID: "0011223344556677",
+		Device:    "wg0",
+		PublicKey: wgtest.MustPublicKey(),
+		Time:      time.Unix(1600000000, 0),
+	}
+
+	if err := s.Send(ctx, r); err != nil {
+		t.Fatalf("failed to send: %v", err)
+	}
+
+	req := <-reqC
+	if req[0] != 4 {
+		t.Fatalf("unexpected packet code: %d", req[0])
+	}
+	if n := int(binary.BigEndian.Uint16(req[2:4])); n != len(req) {
+		t.Fatalf("unexpected packet length: %d, want %d", n, len(req))
+	}
+
+	// Verify the request authenticator.
+	zero := append([]byte(nil), req...)
+	copy(zero[4:20], make([]byte, 16))
+	h := md5.New()
+	h.Write(zero)
+	h.Write(secret)
+	if !bytes.Equal(h.Sum(nil), req[4:20]) {
+		t.Fatal("invalid request authenticator")
+	}
+
+	if !bytes.Contains(req[20:], append([]byte{wgacct.AttrNASIdentifier, 6}, "vpn1"...)) {
+		t.Fatal("request has no NAS-Identifier")
+	}
+}
+
+func TestRADIUSSenderTimeout(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer pc.Close()
+
+	s, err := wgacct.DialRADIUS(pc.LocalAddr().String(), []byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := s.Send(ctx, wgacct.Record{Status: wgacct.Start}); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	} else {
+		t.Logf("OK error: %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package wgagent_test
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgagent"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+const testToken = "secret"
+
+func TestClientServer(t *testing.T) {
+	var (
+		d = &wgtypes.Device{
+			Name:       "wg0",
+			Type:       wgtypes.LinuxKernel,
+			PrivateKey: wgtest.MustPrivateKey(),
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{{
+				PublicKey:                   wgtest.MustPublicKey(),
+				Endpoint:                    wgtest.MustUDPAddr("[fe80::1%2]:51820"),
+				LastHandshakeTime:           time.Unix(1, 2),
+				PersistentKeepaliveInterval: 25 * time.Second,
+				AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("10.0.0.0/24")},
+			}},
+		}
+
+		port = 1234
+		cfg  = wgtypes.Config{
+			ListenPort: &port,
+			Peers: []wgtypes.PeerConfig{{
+				PublicKey:  wgtest.MustPublicKey(),
+				AllowedIPs: []net.IPNet{wgtest.MustCIDR("fd00::/64")},
+			}},
+		}
+
+		gotCfg wgtypes.Config
+	)
+
+	c := testClient(t, &testBackend{
+		DevicesFunc: func() ([]*wgtypes.Device, error) {
+			return []*wgtypes.Device{d}, nil
+		},
+		DeviceFunc: func(name string) (*wgtypes.Device, error) {
+			if name != d.Name {
+				return nil, os.ErrNotExist
+			}
+
+			return d, nil
+		},
+		ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+			gotCfg = cfg
+			return nil
+		},
+	}, testToken)
+
+	devices, err := c.Devices()
+	if err != nil {
+		t.Fatalf("failed to get devices: %v", err)
+	}
+
+	if diff := cmp.Diff([]*wgtypes.Device{d}, devices); diff != "" {
+		t.Fatalf("unexpected devices (-want +got):\n%s", diff)
+	}
+
+	if _, err := c.Device("wg1"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected is not exist error, but got: %v", err)
+	}
+
+	if err := c.ConfigureDevice(d.Name, cfg); err != nil {
+		t.Fatalf("failed to configure device: %v", err)
+	}
+
+	if diff := cmp.Diff(cfg, gotCfg); diff != "" {
+		t.Fatalf("unexpected configuration (-want +got):\n%s", diff)
+	}
+}
+
+func TestClientBadToken(t *testing.T) {
+	s := wgagent.NewServer(&testBackend{}, &wgagent.Config{Token: testToken})
+	addr := testServe(t, s)
+
+	_, err := wgagent.Dial("tcp", addr, nil, &wgagent.Config{Token: "wrong"})
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	t.Logf("OK error: %v", err)
+}
+
+func TestServerHelloTooLarge(t *testing.T) {
+	s := wgagent.NewServer(&testBackend{}, &wgagent.Config{Token: testToken})
+	addr := testServe(t, s)
+
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer c.Close()
+
+	// The server must stop reading before the request ends, and close the
+	// connection without a response.
+	go func() {
+		_, _ = io.WriteString(c, `{"op":"hello","token":"`+strings.Repeat("a", 1<<20)+`"}`+"\n")
+	}()
+
+	if err := c.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set deadline: %v", err)
+	}
+
+	b, err := io.ReadAll(c)
+	if err != nil && !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("failed to read response: %v", err)
+	}
+	if len(b) != 0 {
+		t.Fatalf("unexpected response: %q", b)
+	}
+}
+
+func testClient(t *testing.T, b wgagent.Backend, token string) *wgagent.Client {
+	t.Helper()
+
+	s := wgagent.NewServer(b, &wgagent.Config{Token: token})
+	addr := testServe(t, s)
+
+	c, err := wgagent.Dial("tcp", addr, nil, &wgagent.Config{Token: token})
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Close() })
+
+	return c
+}
+
+func testServe(t *testing.T, s *wgagent.Server) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := s.Serve(l); !errors.Is(err, wgagent.ErrServerClosed) {
+			panic("failed to serve: " + err.Error())
+		}
+	}()
+
+	t.Cleanup(func() {
+		_ = s.Close()
+		<-done
+	})
+
+	return l.Addr().String()
+}
+
+type testBackend struct {
+	DevicesFunc         func() ([]*wgtypes.Device, error)
+	DeviceFunc          func(name string) (*wgtypes.Device, error)
+	ConfigureDeviceFunc func(name string, cfg wgtypes.Config) error
+}
+
+func (b *testBackend) Devices() ([]*wgtypes.Device, error) { return b.DevicesFunc() }
+func (b *testBackend) Device(name string) (*wgtypes.Device, error) {
+	return b.DeviceFunc(name)
+}
+
+func (b *testBackend) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	return b.ConfigureDeviceFunc(name, cfg)
+}
@@ -0,0 +1,125 @@
+package wgagent
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wginternal"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var _ wginternal.Client = &Client{}
+
+// A Client forwards WireGuard device operations to a Server.
+type Client struct {
+	mu  sync.Mutex
+	c   net.Conn
+	enc *json.Encoder
+	dec *json.Decoder
+}
+
+// Dial dials a Server at the specified network address, such as a "unix"
+// socket path or "tcp" host:port. If tlsConfig is not nil, the connection
+// is secured using TLS. If cfg is nil, a default configuration is used.
+func Dial(network, addr string, tlsConfig *tls.Config, cfg *Config) (*Client, error) {
+	var (
+		c   net.Conn
+		err error
+	)
+	if tlsConfig != nil {
+		c, err = tls.Dial(network, addr, tlsConfig)
+	} else {
+		c, err = net.Dial(network, addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewClient(c, cfg)
+}
+
+// NewClient creates a Client which communicates with a Server over an
+// existing connection c. The Client takes ownership of c and closes it when
+// the Client is closed or authentication fails.
+func NewClient(c net.Conn, cfg *Config) (*Client, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	client := &Client{
+		c:   c,
+		enc: json.NewEncoder(c),
+		dec: json.NewDecoder(c),
+	}
+
+	if _, err := client.do(request{
+		Op:      opHello,
+		Version: protocolVersion,
+		Token:   cfg.Token,
+	}); err != nil {
+		_ = c.Close()
+		return nil, fmt.Errorf("wgagent: failed to authenticate: %w", err)
+	}
+
+	return client, nil
+}
+
+// Close implements wginternal.Client.
+func (c *Client) Close() error {
+	return c.c.Close()
+}
+
+// Devices implements wginternal.Client.
+func (c *Client) Devices() ([]*wgtypes.Device, error) {
+	res, err := c.do(request{Op: opDevices})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Devices, nil
+}
+
+// Device implements wginternal.Client.
+func (c *Client) Device(name string) (*wgtypes.Device, error) {
+	res, err := c.do(request{Op: opDevice, Name: name})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(res.Devices) != 1 {
+		return nil, os.ErrNotExist
+	}
+
+	return res.Devices[0], nil
+}
+
+// ConfigureDevice implements wginternal.Client.
+func (c *Client) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	_, err := c.do(request{Op: opConfigure, Name: name, Config: &cfg})
+	return err
+}
+
+// do sends a single request to the Server and waits for its response.
+func (c *Client) do(req request) (*response, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.enc.Encode(req); err != nil {
+		return nil, err
+	}
+
+	var res response
+	if err := c.dec.Decode(&res); err != nil {
+		return nil, err
+	}
+
+	if err := res.Error.Err(); err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
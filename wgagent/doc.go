@@ -0,0 +1,22 @@
+// Package wgagent implements a small broker daemon which exposes WireGuard
+// device control to other processes, and a matching client.
+//
+// A Server is typically run by a privileged process which owns a
+// *wgctrl.Client, and listens on a UNIX socket or a TLS-wrapped TCP listener.
+// Unprivileged processes then use Dial or NewClient to obtain a Client which
+// implements the same methods as *wgctrl.Client, with each operation
+// forwarded to the Server.
+//
+// Changes which must be made together on several hosts, such as rotating the
+// keys on both ends of a tunnel, may be staged on each Server using
+// Client.Prepare and applied using Client.Commit once all of them are
+// prepared. CommitAll coordinates such a two-phase commit.
+//
+// ConnectivityMatrix uses Clients for several hosts to report the health of
+// the tunnel between each pair of them. Each Link identifies its peer using a
+// wgtypes.PeerRef, which LookupPeer resolves against the same hosts.
+//
+// Clients authenticate to a Server using a shared secret token. The wire
+// protocol is newline-delimited JSON and is only guaranteed to be compatible
+// between identical versions of this package.
+package wgagent // import "golang.zx2c4.com/wireguard/wgctrl/wgagent"
@@ -0,0 +1,261 @@
+package wgagent
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Host is a WireGuard device on a host reachable by a Client, for use with
+// ConnectivityMatrix.
+type Host struct {
+	// Name identifies the host in a Matrix.
+	Name string
+
+	Client *Client
+	Device string
+}
+
+// Ref returns the PeerRef of the Host's device.
+func (h Host) Ref() wgtypes.PeerRef {
+	return wgtypes.PeerRef{Host: h.Name, Device: h.Device}
+}
+
+// LookupPeer retrieves the peer identified by ref from the one of hosts whose
+// Name and Device match ref. If ref refers to a device, the device's public
+// key and listen port are returned as a Peer. If no host or peer matches, an
+// error compatible with os.ErrNotExist is returned.
+func LookupPeer(hosts []Host, ref wgtypes.PeerRef) (*wgtypes.Peer, error) {
+	for _, h := range hosts {
+		if h.Ref() != ref.DeviceRef() {
+			continue
+		}
+
+		d, err := h.Client.Device(h.Device)
+		if err != nil {
+			return nil, fmt.Errorf("wgagent: failed to get device %q on host %q: %w", h.Device, h.Name, err)
+		}
+
+		if ref.IsDevice() {
+			return &wgtypes.Peer{PublicKey: d.PublicKey}, nil
+		}
+
+		for i := range d.Peers {
+			if d.Peers[i].PublicKey == ref.PublicKey {
+				return &d.Peers[i], nil
+			}
+		}
+
+		return nil, fmt.Errorf("wgagent: no peer %s: %w", ref, os.ErrNotExist)
+	}
+
+	return nil, fmt.Errorf("wgagent: no host for %s: %w", ref, os.ErrNotExist)
+}
+
+// MatrixOptions configure ConnectivityMatrix.
+type MatrixOptions struct {
+	// MaxHandshakeAge is the maximum age of the last handshake of a healthy
+	// Link. If zero, a default of 3 minutes is used.
+	MaxHandshakeAge time.Duration
+
+	// Nudge, if not zero, enables a persistent keepalive of one second on
+	// every Link for this duration before the matrix is measured, so that
+	// traffic flows even between idle hosts, and a healthy Link must have
+	// received data. The original keepalive intervals are then restored.
+	Nudge time.Duration
+}
+
+// A Matrix reports the health of the tunnels between each pair of hosts.
+type Matrix struct {
+	// Hosts are the names of the hosts, in the order they were given.
+	Hosts []string
+
+	// Links[i][j] is the Link from Hosts[i] to Hosts[j], or nil if i == j
+	// or Hosts[i] has no peer for Hosts[j].
+	Links [][]*Link
+}
+
+// A Link reports the health of the tunnel from one host to another, as
+// observed by the first host.
+type Link struct {
+	From, To string
+
+	// Peer identifies the peer on the From host's device which represents
+	// the To host.
+	Peer wgtypes.PeerRef
+
+	Endpoint          string
+	LastHandshakeTime time.Time
+
+	// ReceiveBytes and TransmitBytes are the number of bytes received and
+	// transmitted during the nudge, if any.
+	ReceiveBytes, TransmitBytes int64
+
+	// Healthy reports whether the last handshake is recent enough and, if
+	// the hosts were nudged, whether data was received.
+	Healthy bool
+}
+
+// ConnectivityMatrix builds the Matrix of tunnel health between each pair of
+// hosts. A host's peer is matched to another host by the public key of the
+// other host's device.
+func ConnectivityMatrix(ctx context.Context, hosts []Host, opts MatrixOptions) (*Matrix, error) {
+	maxAge := opts.MaxHandshakeAge
+	if maxAge == 0 {
+		maxAge = 3 * time.Minute
+	}
+
+	before, err := hostDevices(hosts)
+	if err != nil {
+		return nil, err
+	}
+
+	after := before
+	if opts.Nudge > 0 {
+		restore, err := nudge(ctx, hosts, before, opts.Nudge)
+		if err != nil {
+			return nil, err
+		}
+
+		after, err = hostDevices(hosts)
+		restore()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	index := make(map[wgtypes.Key]int, len(hosts))
+	for i, d := range before {
+		index[d.PublicKey] = i
+	}
+
+	m := &Matrix{
+		Hosts: make([]string, 0, len(hosts)),
+		Links: make([][]*Link, len(hosts)),
+	}
+
+	now := time.Now()
+	for i, h := range hosts {
+		m.Hosts = append(m.Hosts, h.Name)
+		m.Links[i] = make([]*Link, len(hosts))
+
+		prev := make(map[wgtypes.Key]wgtypes.Peer, len(before[i].Peers))
+		for _, p := range before[i].Peers {
+			prev[p.PublicKey] = p
+		}
+
+		for _, p := range after[i].Peers {
+			j, ok := index[p.PublicKey]
+			if !ok || j == i {
+				continue
+			}
+
+			l := &Link{
+				From:              h.Name,
+				To:                hosts[j].Name,
+				Peer:              wgtypes.PeerRef{Host: h.Name, Device: h.Device, PublicKey: p.PublicKey},
+				LastHandshakeTime: p.LastHandshakeTime,
+			}
+			if p.Endpoint != nil {
+				l.Endpoint = p.Endpoint.String()
+			}
+			if opts.Nudge > 0 {
+				l.ReceiveBytes = p.ReceiveBytes - prev[p.PublicKey].ReceiveBytes
+				l.TransmitBytes = p.TransmitBytes - prev[p.PublicKey].TransmitBytes
+			}
+
+			l.Healthy = !p.LastHandshakeTime.IsZero() &&
+				now.Sub(p.LastHandshakeTime) <= maxAge &&
+				(opts.Nudge == 0 || l.ReceiveBytes > 0)
+
+			m.Links[i][j] = l
+		}
+	}
+
+	return m, nil
+}
+
+// hostDevices retrieves the device of each of hosts.
+func hostDevices(hosts []Host) ([]*wgtypes.Device, error) {
+	ds := make([]*wgtypes.Device, 0, len(hosts))
+	for _, h := range hosts {
+		d, err := h.Client.Device(h.Device)
+		if err != nil {
+			return nil, fmt.Errorf("wgagent: failed to get device %q on host %q: %w", h.Device, h.Name, err)
+		}
+
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
+
+// nudge enables a one second persistent keepalive on the peers of each host
+// which belong to the other hosts and waits for d. On success, it returns a
+// function which restores the original keepalive intervals.
+func nudge(ctx context.Context, hosts []Host, devices []*wgtypes.Device, d time.Duration) (func(), error) {
+	keys := make(map[wgtypes.Key]bool, len(devices))
+	for _, dev := range devices {
+		keys[dev.PublicKey] = true
+	}
+
+	var (
+		nudged  []int
+		configs = make([][]wgtypes.PeerConfig, len(hosts))
+	)
+
+	// Restoring is best effort: a host which cannot be reached to restore
+	// its intervals has most likely failed entirely.
+	restore := func() {
+		for _, i := range nudged {
+			_ = hosts[i].Client.ConfigureDevice(hosts[i].Device, wgtypes.Config{Peers: configs[i]})
+		}
+	}
+
+	second := time.Second
+	for i, h := range hosts {
+		var pcs []wgtypes.PeerConfig
+		for _, p := range devices[i].Peers {
+			if !keys[p.PublicKey] || p.PublicKey == devices[i].PublicKey {
+				continue
+			}
+
+			interval := p.PersistentKeepaliveInterval
+			configs[i] = append(configs[i], wgtypes.PeerConfig{
+				PublicKey:                   p.PublicKey,
+				UpdateOnly:                  true,
+				PersistentKeepaliveInterval: &interval,
+			})
+			pcs = append(pcs, wgtypes.PeerConfig{
+				PublicKey:                   p.PublicKey,
+				UpdateOnly:                  true,
+				PersistentKeepaliveInterval: &second,
+			})
+		}
+
+		if len(pcs) == 0 {
+			continue
+		}
+
+		if err := h.Client.ConfigureDevice(h.Device, wgtypes.Config{Peers: pcs}); err != nil {
+			restore()
+			return nil, fmt.Errorf("wgagent: failed to nudge device %q on host %q: %w", h.Device, h.Name, err)
+		}
+
+		nudged = append(nudged, i)
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		restore()
+		return nil, ctx.Err()
+	case <-t.C:
+		return restore, nil
+	}
+}
@@ -0,0 +1,167 @@
+package wgagent_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgagent"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestConnectivityMatrix(t *testing.T) {
+	var (
+		keys = []wgtypes.Key{
+			wgtest.MustPublicKey(),
+			wgtest.MustPublicKey(),
+			wgtest.MustPublicKey(),
+		}
+		names = []string{"a", "b", "c"}
+		now   = time.Now()
+	)
+
+	// The tunnel between a and c is down: neither end has completed a
+	// handshake, and no data flows even when nudged.
+	down := func(i, j int) bool { return (i == 0 && j == 2) || (i == 2 && j == 0) }
+
+	var (
+		hosts      []wgagent.Host
+		mu         sync.Mutex
+		keepalives = make(map[int][]time.Duration)
+	)
+
+	for i := range names {
+		i := i
+
+		dev := &wgtypes.Device{Name: "wg0", PublicKey: keys[i]}
+		for j := range names {
+			if j == i {
+				continue
+			}
+
+			p := wgtypes.Peer{
+				PublicKey:                   keys[j],
+				PersistentKeepaliveInterval: 25 * time.Second,
+			}
+			if !down(i, j) {
+				p.LastHandshakeTime = now
+			}
+
+			dev.Peers = append(dev.Peers, p)
+		}
+
+		b := &testBackend{
+			DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				// Each retrieval of a nudged peer on a working tunnel
+				// observes more traffic.
+				for k, p := range dev.Peers {
+					if p.PersistentKeepaliveInterval == time.Second && !down(i, indexOf(keys, p.PublicKey)) {
+						dev.Peers[k].ReceiveBytes += 32
+						dev.Peers[k].TransmitBytes += 32
+					}
+				}
+
+				d := *dev
+				d.Peers = append([]wgtypes.Peer(nil), dev.Peers...)
+				return &d, nil
+			},
+			ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+				mu.Lock()
+				defer mu.Unlock()
+
+				for _, pc := range cfg.Peers {
+					for k := range dev.Peers {
+						if dev.Peers[k].PublicKey == pc.PublicKey {
+							dev.Peers[k].PersistentKeepaliveInterval = *pc.PersistentKeepaliveInterval
+							keepalives[i] = append(keepalives[i], *pc.PersistentKeepaliveInterval)
+						}
+					}
+				}
+
+				return nil
+			},
+		}
+
+		hosts = append(hosts, wgagent.Host{
+			Name:   names[i],
+			Client: testClient(t, b, "token"),
+			Device: "wg0",
+		})
+	}
+
+	m, err := wgagent.ConnectivityMatrix(context.Background(), hosts, wgagent.MatrixOptions{
+		Nudge: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to build matrix: %v", err)
+	}
+
+	if diff := cmp.Diff(names, m.Hosts); diff != "" {
+		t.Fatalf("unexpected hosts (-want +got):\n%s", diff)
+	}
+
+	for i := range names {
+		for j := range names {
+			l := m.Links[i][j]
+			if i == j {
+				if l != nil {
+					t.Fatalf("unexpected link from %s to itself", names[i])
+				}
+				continue
+			}
+
+			if diff := cmp.Diff(!down(i, j), l.Healthy); diff != "" {
+				t.Fatalf("unexpected health of link %s -> %s (-want +got):\n%s", l.From, l.To, diff)
+			}
+
+			ref := wgtypes.PeerRef{Host: names[i], Device: "wg0", PublicKey: keys[j]}
+			if diff := cmp.Diff(ref, l.Peer); diff != "" {
+				t.Fatalf("unexpected peer of link %s -> %s (-want +got):\n%s", l.From, l.To, diff)
+			}
+		}
+	}
+
+	// Each host's peers were nudged and then restored to their original
+	// keepalive interval.
+	want := []time.Duration{time.Second, time.Second, 25 * time.Second, 25 * time.Second}
+	for i := range names {
+		if diff := cmp.Diff(want, keepalives[i]); diff != "" {
+			t.Fatalf("unexpected keepalive changes on %s (-want +got):\n%s", names[i], diff)
+		}
+	}
+
+	p, err := wgagent.LookupPeer(hosts, m.Links[0][1].Peer)
+	if err != nil {
+		t.Fatalf("failed to look up peer: %v", err)
+	}
+	if diff := cmp.Diff(keys[1], p.PublicKey); diff != "" {
+		t.Fatalf("unexpected peer (-want +got):\n%s", diff)
+	}
+
+	for _, ref := range []wgtypes.PeerRef{
+		{Host: "d", Device: "wg0"},
+		{Host: "a", Device: "wg0", PublicKey: keys[0]},
+	} {
+		if _, err := wgagent.LookupPeer(hosts, ref); !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected is not exist error for %s, but got: %v", ref, err)
+		}
+	}
+}
+
+func indexOf(keys []wgtypes.Key, k wgtypes.Key) int {
+	for i := range keys {
+		if keys[i] == k {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,98 @@
+package wgagent
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// protocolVersion is the version of the wire protocol spoken by this package.
+// Version 2 encodes a Config using its canonical JSON representation, as
+// produced by wgtypes.Config.MarshalJSON.
+const protocolVersion = 2
+
+// Operations which may be specified in a request.
+const (
+	opHello     = "hello"
+	opDevices   = "devices"
+	opDevice    = "device"
+	opConfigure = "configure"
+	opPrepare   = "prepare"
+	opCommit    = "commit"
+	opAbort     = "abort"
+)
+
+// Limits on the requests read by a Server. A hello request is small, and must
+// be sent promptly, so that unauthenticated clients cannot hold connections
+// open or cause large allocations.
+const (
+	maxHelloSize   = 4 << 10
+	maxRequestSize = 64 << 20
+	helloTimeout   = 10 * time.Second
+)
+
+// Error kinds which are mapped back to well-known errors by a Client.
+const (
+	kindNotExist   = "notexist"
+	kindPermission = "permission"
+)
+
+// A request is a single request sent from a Client to a Server.
+type request struct {
+	Op      string          `json:"op"`
+	Version int             `json:"version,omitempty"`
+	Token   string          `json:"token,omitempty"`
+	Name    string          `json:"name,omitempty"`
+	Config  *wgtypes.Config `json:"config,omitempty"`
+	ID      string          `json:"id,omitempty"`
+}
+
+// A response is a single response sent from a Server to a Client.
+type response struct {
+	Devices []*wgtypes.Device `json:"devices,omitempty"`
+	ID      string            `json:"id,omitempty"`
+	Error   *wireError        `json:"error,omitempty"`
+}
+
+// A wireError is an error transmitted from a Server to a Client.
+type wireError struct {
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// newWireError packs err for transmission to a Client.
+func newWireError(err error) *wireError {
+	if err == nil {
+		return nil
+	}
+
+	we := &wireError{Message: err.Error()}
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		we.Kind = kindNotExist
+	case errors.Is(err, os.ErrPermission):
+		we.Kind = kindPermission
+	}
+
+	return we
+}
+
+// Err unpacks a wireError into an error which can be checked with errors.Is
+// for well-known error kinds.
+func (we *wireError) Err() error {
+	if we == nil {
+		return nil
+	}
+
+	switch we.Kind {
+	case kindNotExist:
+		return os.ErrNotExist
+	case kindPermission:
+		return fmt.Errorf("wgagent: %s: %w", we.Message, os.ErrPermission)
+	default:
+		return fmt.Errorf("wgagent: %s", we.Message)
+	}
+}
@@ -0,0 +1,265 @@
+package wgagent
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Backend is the set of operations a Server exposes to its clients.
+// *wgctrl.Client satisfies Backend.
+type Backend interface {
+	Devices() ([]*wgtypes.Device, error)
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// Config contains options for a Server or Client.
+type Config struct {
+	// Token is a shared secret which a Client must present to a Server before
+	// any operations are permitted.
+	//
+	// If Token is empty on a Server, any Client may connect and access to the
+	// Server must be restricted by other means, such as UNIX socket file
+	// permissions.
+	Token string
+
+	// PrepareTimeout is the time for which a Server retains a configuration
+	// staged by Client.Prepare. If the configuration is not committed within
+	// PrepareTimeout, it is discarded. If zero, a default of 1 minute is used.
+	PrepareTimeout time.Duration
+}
+
+// A Server serves Backend operations to Clients.
+type Server struct {
+	b       Backend
+	token   []byte
+	timeout time.Duration
+
+	// staged holds configurations staged by prepare requests, which may be
+	// committed from any connection.
+	stagedMu sync.Mutex
+	staged   map[string]stagedConfig
+
+	mu     sync.Mutex
+	ls     map[net.Listener]struct{}
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer creates a Server which serves operations using b. If cfg is nil,
+// a default configuration is used.
+func NewServer(b Backend, cfg *Config) *Server {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	timeout := cfg.PrepareTimeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+
+	return &Server{
+		b:       b,
+		token:   []byte(cfg.Token),
+		timeout: timeout,
+		staged:  make(map[string]stagedConfig),
+		ls:      make(map[net.Listener]struct{}),
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// ErrServerClosed is returned by Server.Serve after Server.Close is called.
+var ErrServerClosed = errors.New("wgagent: server closed")
+
+// Serve accepts and serves connections on l until l is closed or Close is
+// called. To serve TLS connections, wrap l using tls.NewListener.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l, nil) {
+		return ErrServerClosed
+	}
+	defer s.untrack(l, nil)
+
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return ErrServerClosed
+			}
+
+			return err
+		}
+
+		if !s.track(nil, c) {
+			_ = c.Close()
+			return ErrServerClosed
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.untrack(nil, c)
+			s.serveConn(c)
+		}()
+	}
+}
+
+// Close closes all listeners and connections and waits for any in-flight
+// operations to complete.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.ls {
+		_ = l.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// track begins tracking l or c, returning false if the Server is closed.
+func (s *Server) track(l net.Listener, c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	if l != nil {
+		s.ls[l] = struct{}{}
+	}
+	if c != nil {
+		s.conns[c] = struct{}{}
+	}
+
+	return true
+}
+
+// untrack stops tracking and closes l or c.
+func (s *Server) untrack(l net.Listener, c net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if l != nil {
+		delete(s.ls, l)
+		_ = l.Close()
+	}
+	if c != nil {
+		delete(s.conns, c)
+		_ = c.Close()
+	}
+}
+
+// serveConn serves requests on a single connection until an error occurs.
+func (s *Server) serveConn(c net.Conn) {
+	// Each request may read at most the limit set before decoding it.
+	lr := &io.LimitedReader{R: c, N: maxHelloSize}
+	dec := json.NewDecoder(lr)
+	enc := json.NewEncoder(c)
+
+	// The first request on any connection must authenticate the client.
+	if err := c.SetReadDeadline(time.Now().Add(helloTimeout)); err != nil {
+		return
+	}
+
+	var hello request
+	if err := dec.Decode(&hello); err != nil {
+		return
+	}
+	if err := s.authenticate(hello); err != nil {
+		_ = enc.Encode(response{Error: newWireError(err)})
+		return
+	}
+	if err := c.SetReadDeadline(time.Time{}); err != nil {
+		return
+	}
+	if err := enc.Encode(response{}); err != nil {
+		return
+	}
+
+	for {
+		lr.N = maxRequestSize
+
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+
+		if err := enc.Encode(s.handle(req)); err != nil {
+			return
+		}
+	}
+}
+
+// Errors returned to clients during authentication.
+var (
+	errBadHello   = errors.New("expected hello request")
+	errBadVersion = errors.New("unsupported protocol version")
+	errBadToken   = errors.New("invalid token")
+)
+
+// authenticate verifies the initial hello request from a client.
+func (s *Server) authenticate(req request) error {
+	switch {
+	case req.Op != opHello:
+		return errBadHello
+	case req.Version != protocolVersion:
+		return errBadVersion
+	case len(s.token) > 0 && subtle.ConstantTimeCompare(s.token, []byte(req.Token)) != 1:
+		return errBadToken
+	}
+
+	return nil
+}
+
+// handle executes a single request against the Backend.
+func (s *Server) handle(req request) response {
+	switch req.Op {
+	case opDevices:
+		ds, err := s.b.Devices()
+		return response{Devices: ds, Error: newWireError(err)}
+	case opDevice:
+		d, err := s.b.Device(req.Name)
+		if err != nil {
+			return response{Error: newWireError(err)}
+		}
+
+		return response{Devices: []*wgtypes.Device{d}}
+	case opConfigure:
+		if req.Config == nil {
+			return response{Error: &wireError{Message: "missing configuration"}}
+		}
+
+		return response{Error: newWireError(s.b.ConfigureDevice(req.Name, *req.Config))}
+	case opPrepare:
+		if req.Config == nil {
+			return response{Error: &wireError{Message: "missing configuration"}}
+		}
+
+		id, err := s.prepare(req.Name, *req.Config)
+		return response{ID: id, Error: newWireError(err)}
+	case opCommit:
+		return response{Error: newWireError(s.commit(req.ID))}
+	case opAbort:
+		s.abort(req.ID)
+		return response{}
+	default:
+		return response{Error: &wireError{Message: "unknown operation: " + req.Op}}
+	}
+}
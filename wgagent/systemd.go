@@ -0,0 +1,37 @@
+package wgagent
+
+import (
+	"net"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgsystemd"
+)
+
+// SystemdListeners returns the listeners passed to this process by a
+// supervisor such as systemd, using the socket activation protocol described
+// in sd_listen_fds(3). Each listener may be passed to Server.Serve. If no
+// listeners were passed, SystemdListeners returns nil.
+func SystemdListeners() ([]net.Listener, error) {
+	files, err := wgsystemd.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	ls := make([]net.Listener, 0, len(files))
+	for _, f := range files {
+		// FileListener duplicates the file descriptor, so the original file
+		// is no longer needed.
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range ls {
+				_ = l.Close()
+			}
+
+			return nil, err
+		}
+
+		ls = append(ls, l)
+	}
+
+	return ls, nil
+}
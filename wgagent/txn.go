@@ -0,0 +1,160 @@
+package wgagent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A stagedConfig is a configuration staged by a prepare request.
+type stagedConfig struct {
+	name    string
+	cfg     wgtypes.Config
+	expires time.Time
+}
+
+// errNotPrepared is returned when committing a configuration which was never
+// prepared, or which has expired or been aborted.
+var errNotPrepared = errors.New("configuration is not prepared or has expired")
+
+// prepare checks that cfg is valid and that the device name exists, and
+// stages cfg to be applied to it by a later commit, returning the staged
+// configuration's ID.
+func (s *Server) prepare(name string, cfg wgtypes.Config) (string, error) {
+	if err := wgctrl.ValidateConfig(cfg); err != nil {
+		return "", err
+	}
+	if _, err := s.b.Device(name); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	now := time.Now()
+
+	s.stagedMu.Lock()
+	defer s.stagedMu.Unlock()
+
+	// Discard any expired configurations which were never committed.
+	for k, sc := range s.staged {
+		if now.After(sc.expires) {
+			delete(s.staged, k)
+		}
+	}
+
+	s.staged[id] = stagedConfig{
+		name:    name,
+		cfg:     cfg,
+		expires: now.Add(s.timeout),
+	}
+
+	return id, nil
+}
+
+// commit applies the configuration staged with id.
+func (s *Server) commit(id string) error {
+	s.stagedMu.Lock()
+	sc, ok := s.staged[id]
+	delete(s.staged, id)
+	s.stagedMu.Unlock()
+
+	if !ok || time.Now().After(sc.expires) {
+		return errNotPrepared
+	}
+
+	return s.b.ConfigureDevice(sc.name, sc.cfg)
+}
+
+// abort discards the configuration staged with id, if any.
+func (s *Server) abort(id string) {
+	s.stagedMu.Lock()
+	defer s.stagedMu.Unlock()
+
+	delete(s.staged, id)
+}
+
+// Prepare stages cfg to be applied to the device specified by name by a later
+// call to Commit, without applying it. The Server checks that the device
+// exists and returns an ID which identifies the staged configuration.
+//
+// A staged configuration is discarded if it is not committed within the
+// Server's PrepareTimeout. Use Abort to discard it sooner.
+func (c *Client) Prepare(name string, cfg wgtypes.Config) (string, error) {
+	res, err := c.do(request{Op: opPrepare, Name: name, Config: &cfg})
+	if err != nil {
+		return "", err
+	}
+
+	return res.ID, nil
+}
+
+// Commit applies the configuration staged by Prepare with the specified ID.
+// A staged configuration may only be committed once.
+func (c *Client) Commit(id string) error {
+	_, err := c.do(request{Op: opCommit, ID: id})
+	return err
+}
+
+// Abort discards the configuration staged by Prepare with the specified ID.
+// Aborting a configuration which does not exist is not an error.
+func (c *Client) Abort(id string) error {
+	_, err := c.do(request{Op: opAbort, ID: id})
+	return err
+}
+
+// A Change is a configuration to be applied to a device by a Client as part
+// of a call to CommitAll.
+type Change struct {
+	Client *Client
+	Device string
+	Config wgtypes.Config
+}
+
+// CommitAll applies each of changes using a two-phase commit: every change is
+// first prepared, and changes are only committed once all of them were
+// prepared successfully. If any change cannot be prepared, all prepared
+// changes are aborted and none are applied.
+//
+// This narrows the window in which changes which must be made together, such
+// as rotating the keys on both ends of a tunnel, are only partially applied.
+// It does not eliminate it: if a commit fails after another commit
+// succeeded, CommitAll returns an error describing which changes were
+// applied, and the caller must reconcile them.
+func CommitAll(changes ...Change) error {
+	ids := make([]string, 0, len(changes))
+	for i, ch := range changes {
+		id, err := ch.Client.Prepare(ch.Device, ch.Config)
+		if err != nil {
+			for j, id := range ids {
+				_ = changes[j].Client.Abort(id)
+			}
+
+			return fmt.Errorf("wgagent: failed to prepare change %d for device %q: %w", i, ch.Device, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	for i, ch := range changes {
+		if err := ch.Client.Commit(ids[i]); err != nil {
+			// Abort the remaining changes so they are not committed later.
+			for j := i + 1; j < len(changes); j++ {
+				_ = changes[j].Client.Abort(ids[j])
+			}
+
+			return fmt.Errorf("wgagent: failed to commit change %d for device %q after committing %d changes: %w",
+				i, ch.Device, i, err)
+		}
+	}
+
+	return nil
+}
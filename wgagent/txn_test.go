@@ -0,0 +1,131 @@
+package wgagent_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgagent"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestCommitAll(t *testing.T) {
+	port := 51820
+	cfg := wgtypes.Config{ListenPort: &port}
+
+	tests := []struct {
+		name    string
+		devices []string
+		invalid bool
+		ok      bool
+		applied []string
+	}{
+		{
+			name:    "OK",
+			devices: []string{"wg0", "wg1"},
+			ok:      true,
+			applied: []string{"wg0", "wg1"},
+		},
+		{
+			name:    "prepare failed",
+			devices: []string{"wg0", "wg2"},
+		},
+		{
+			name:    "invalid config",
+			devices: []string{"wg0", "wg1"},
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each host has a single device, and records the devices which
+			// were configured.
+			var applied []string
+			newHost := func(device string) *wgagent.Client {
+				return testClient(t, &testBackend{
+					DeviceFunc: func(name string) (*wgtypes.Device, error) {
+						if name != device {
+							return nil, os.ErrNotExist
+						}
+
+						return &wgtypes.Device{Name: name}, nil
+					},
+					ConfigureDeviceFunc: func(name string, _ wgtypes.Config) error {
+						applied = append(applied, name)
+						return nil
+					},
+				}, testToken)
+			}
+
+			// An invalid configuration must be rejected when it is
+			// prepared, before any host applies its configuration.
+			last := cfg
+			if tt.invalid {
+				last = wgtypes.Config{Peers: []wgtypes.PeerConfig{{}}}
+			}
+
+			changes := []wgagent.Change{
+				{Client: newHost("wg0"), Device: tt.devices[0], Config: cfg},
+				{Client: newHost("wg1"), Device: tt.devices[1], Config: last},
+			}
+
+			err := wgagent.CommitAll(changes...)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to commit: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if err != nil {
+				t.Logf("OK error: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.applied, applied); diff != "" {
+				t.Fatalf("unexpected applied devices (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientCommitNotPrepared(t *testing.T) {
+	b := &testBackend{
+		DeviceFunc: func(name string) (*wgtypes.Device, error) {
+			return &wgtypes.Device{Name: name}, nil
+		},
+		ConfigureDeviceFunc: func(_ string, _ wgtypes.Config) error {
+			panic("shouldn't be called")
+		},
+	}
+
+	s := wgagent.NewServer(b, &wgagent.Config{PrepareTimeout: time.Nanosecond})
+	c, err := wgagent.Dial("tcp", testServe(t, s), nil, nil)
+	if err != nil {
+		t.Fatalf("failed to dial server: %v", err)
+	}
+	defer c.Close()
+
+	aborted, err := c.Prepare("wg0", wgtypes.Config{})
+	if err != nil {
+		t.Fatalf("failed to prepare: %v", err)
+	}
+	if err := c.Abort(aborted); err != nil {
+		t.Fatalf("failed to abort: %v", err)
+	}
+
+	expired, err := c.Prepare("wg0", wgtypes.Config{})
+	if err != nil {
+		t.Fatalf("failed to prepare: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+
+	for _, id := range []string{aborted, expired, "unknown"} {
+		err := c.Commit(id)
+		if err == nil {
+			t.Fatalf("expected an error committing %q, but none occurred", id)
+		}
+
+		t.Logf("OK error: %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package wgbundle
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// signaturePrefix is prepended to the payload of a Bundle when it is signed,
+// so that a signature over a Bundle cannot be mistaken for a signature over
+// any other message made with the same key.
+const signaturePrefix = "wgbundle v1\n"
+
+// A Bundle is the desired configuration of a set of devices.
+type Bundle struct {
+	// Serial identifies the Bundle, and must increase each time a new
+	// Bundle is published.
+	Serial uint64 `json:"serial"`
+
+	// Expires, if not zero, is the time after which the Bundle must not be
+	// applied.
+	Expires time.Time `json:"expires"`
+
+	// Devices maps device names to their complete configurations, as
+	// applied by SyncConfig. Devices which are not present are left
+	// untouched.
+	Devices map[string]wgtypes.Config `json:"devices"`
+}
+
+// Expired reports whether b has expired at time now.
+func (b *Bundle) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && now.After(b.Expires)
+}
+
+// ErrUnknownKey is returned when a signature was made by a key which is not
+// in a Keyring.
+var ErrUnknownKey = errors.New("wgbundle: bundle is signed by an unknown key")
+
+// KeyID returns the identifier of a public key used in signatures: the first
+// 8 bytes of its SHA-256 hash in hex.
+func KeyID(key ed25519.PublicKey) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// A Keyring is a set of trusted public keys indexed by their key IDs.
+type Keyring map[string]ed25519.PublicKey
+
+// NewKeyring creates a Keyring which trusts keys.
+func NewKeyring(keys ...ed25519.PublicKey) Keyring {
+	kr := make(Keyring, len(keys))
+	for _, k := range keys {
+		kr[KeyID(k)] = k
+	}
+
+	return kr
+}
+
+// A signature is a detached signature, which is also embedded in an
+// envelope.
+type signature struct {
+	KeyID     string `json:"keyId"`
+	Signature []byte `json:"signature"`
+}
+
+// An envelope is the attached form of a signed Bundle. Payload is the JSON
+// encoding of the Bundle, which is kept as bytes so that the signature is
+// verified over exactly what was signed.
+type envelope struct {
+	Payload []byte `json:"payload"`
+	signature
+}
+
+// Marshal encodes b as the payload which is signed by SignDetached.
+func Marshal(b *Bundle) ([]byte, error) {
+	payload, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("wgbundle: failed to encode bundle: %v", err)
+	}
+
+	return payload, nil
+}
+
+// Sign encodes and signs b using key, producing a single JSON document which
+// contains both the Bundle and its signature.
+func Sign(b *Bundle, key ed25519.PrivateKey) ([]byte, error) {
+	payload, err := Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope{
+		Payload:   payload,
+		signature: sign(payload, key),
+	})
+}
+
+// Verify verifies a signed Bundle produced by Sign using the keys in kr, and
+// decodes it.
+func Verify(data []byte, kr Keyring) (*Bundle, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("wgbundle: invalid signed bundle: %v", err)
+	}
+
+	return verify(env.Payload, env.signature, kr)
+}
+
+// SignDetached signs payload, as produced by Marshal, using key, and
+// returns the encoded signature.
+func SignDetached(payload []byte, key ed25519.PrivateKey) ([]byte, error) {
+	return json.Marshal(sign(payload, key))
+}
+
+// VerifyDetached verifies the signature sig, as produced by SignDetached, of
+// payload using the keys in kr, and decodes the Bundle in payload.
+func VerifyDetached(payload, sig []byte, kr Keyring) (*Bundle, error) {
+	var s signature
+	if err := json.Unmarshal(sig, &s); err != nil {
+		return nil, fmt.Errorf("wgbundle: invalid signature: %v", err)
+	}
+
+	return verify(payload, s, kr)
+}
+
+// sign signs payload using key.
+func sign(payload []byte, key ed25519.PrivateKey) signature {
+	return signature{
+		KeyID:     KeyID(key.Public().(ed25519.PublicKey)),
+		Signature: ed25519.Sign(key, signed(payload)),
+	}
+}
+
+// verify verifies s over payload using the keys in kr, and decodes payload.
+func verify(payload []byte, s signature, kr Keyring) (*Bundle, error) {
+	key, ok := kr[s.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, s.KeyID)
+	}
+
+	if len(key) != ed25519.PublicKeySize || !ed25519.Verify(key, signed(payload), s.Signature) {
+		return nil, errors.New("wgbundle: bundle signature is invalid")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(payload))
+	dec.DisallowUnknownFields()
+
+	var b Bundle
+	if err := dec.Decode(&b); err != nil {
+		return nil, fmt.Errorf("wgbundle: invalid bundle: %v", err)
+	}
+
+	return &b, nil
+}
+
+// signed returns the message signed for payload.
+func signed(payload []byte) []byte {
+	return append([]byte(signaturePrefix), payload...)
+}
@@ -0,0 +1,158 @@
+package wgbundle_test
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgbundle"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSignVerify(t *testing.T) {
+	var (
+		oldPub, oldPriv = mustKey()
+		newPub, newPriv = mustKey()
+		port            = 51820
+	)
+
+	b := &wgbundle.Bundle{
+		Serial:  1,
+		Expires: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Devices: map[string]wgtypes.Config{
+			"wg0": {
+				ListenPort: &port,
+				Peers:      []wgtypes.PeerConfig{{PublicKey: wgtest.MustPublicKey()}},
+			},
+		},
+	}
+
+	// Both keys are trusted during a rotation.
+	kr := wgbundle.NewKeyring(oldPub, newPub)
+
+	payload, err := wgbundle.Marshal(b)
+	if err != nil {
+		t.Fatalf("failed to marshal bundle: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		verify func() (*wgbundle.Bundle, error)
+	}{
+		{
+			name: "attached",
+			verify: func() (*wgbundle.Bundle, error) {
+				data, err := wgbundle.Sign(b, oldPriv)
+				if err != nil {
+					return nil, err
+				}
+
+				return wgbundle.Verify(data, kr)
+			},
+		},
+		{
+			name: "detached",
+			verify: func() (*wgbundle.Bundle, error) {
+				sig, err := wgbundle.SignDetached(payload, newPriv)
+				if err != nil {
+					return nil, err
+				}
+
+				return wgbundle.VerifyDetached(payload, sig, kr)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.verify()
+			if err != nil {
+				t.Fatalf("failed to verify bundle: %v", err)
+			}
+
+			if diff := cmp.Diff(b, got); diff != "" {
+				t.Fatalf("unexpected Bundle (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if !b.Expired(b.Expires.Add(time.Second)) || b.Expired(b.Expires) {
+		t.Fatal("unexpected expiry")
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	pub, priv := mustKey()
+	_, other := mustKey()
+
+	payload, err := wgbundle.Marshal(&wgbundle.Bundle{Serial: 1})
+	if err != nil {
+		t.Fatalf("failed to marshal bundle: %v", err)
+	}
+
+	mustSign := func(payload []byte, key ed25519.PrivateKey) []byte {
+		sig, err := wgbundle.SignDetached(payload, key)
+		if err != nil {
+			t.Fatalf("failed to sign bundle: %v", err)
+		}
+
+		return sig
+	}
+
+	tests := []struct {
+		name         string
+		payload, sig []byte
+		err          error
+	}{
+		{
+			name:    "unknown key",
+			payload: payload,
+			sig:     mustSign(payload, other),
+			err:     wgbundle.ErrUnknownKey,
+		},
+		{
+			name:    "tampered",
+			payload: bytes.Replace(payload, []byte(`"serial":1`), []byte(`"serial":9`), 1),
+			sig:     mustSign(payload, priv),
+		},
+		{
+			name:    "unknown field",
+			payload: []byte(`{"serial":1,"foo":true}`),
+			sig:     mustSign([]byte(`{"serial":1,"foo":true}`), priv),
+		},
+		{
+			name:    "bad signature",
+			payload: payload,
+			sig:     []byte("foo"),
+		},
+	}
+
+	kr := wgbundle.NewKeyring(pub)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := wgbundle.VerifyDetached(tt.payload, tt.sig, kr)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func mustKey() (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	return pub, priv
+}
@@ -0,0 +1,16 @@
+// Package wgbundle defines signed bundles of WireGuard device
+// configurations, so that configurations can be distributed over untrusted
+// channels such as HTTP servers, gossip protocols, or shared files, while
+// hosts only apply configurations which were signed by a trusted key.
+//
+// A Bundle is encoded as JSON and signed with an Ed25519 key. The signature
+// may be attached, using Sign and Verify, which produce and consume a single
+// JSON envelope containing the payload, or detached, using SignDetached and
+// VerifyDetached, which keep the encoded Bundle and its signature in
+// separate files. Each signature identifies its key by a key ID, so that a
+// Keyring may hold several trusted keys while keys are rotated.
+//
+// Verification only establishes that a Bundle is authentic. Consumers should
+// also reject Bundles which have expired, or whose Serial is lower than that
+// of a Bundle they have already applied, to prevent replay of old Bundles.
+package wgbundle // import "golang.zx2c4.com/wireguard/wgctrl/wgbundle"
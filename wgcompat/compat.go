@@ -0,0 +1,25 @@
+package wgcompat
+
+import (
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var _ Client = &wgctrl.Client{}
+
+// A Client is the method set of the original wgctrl.Client, which
+// *wgctrl.Client implements in both modules.
+type Client interface {
+	Close() error
+	Devices() ([]*wgtypes.Device, error)
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// New creates a new *wgctrl.Client with the default options. It has the same
+// signature as wgctrl.New in the original module, for callers which store it
+// in a variable or pass it as a value, as this module's wgctrl.New also
+// accepts ClientOptions.
+func New() (*wgctrl.Client, error) {
+	return wgctrl.New()
+}
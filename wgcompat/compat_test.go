@@ -0,0 +1,91 @@
+package wgcompat_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgcompat"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// The original module's API, pinned so that changes to it fail to compile.
+var (
+	_ wgcompat.Client                = &wgctrl.Client{}
+	_ func() (*wgctrl.Client, error) = wgcompat.New
+
+	_ func() (wgtypes.Key, error)         = wgtypes.GenerateKey
+	_ func() (wgtypes.Key, error)         = wgtypes.GeneratePrivateKey
+	_ func(b []byte) (wgtypes.Key, error) = wgtypes.NewKey
+	_ func(s string) (wgtypes.Key, error) = wgtypes.ParseKey
+	_ func(k wgtypes.Key) wgtypes.Key     = wgtypes.Key.PublicKey
+	_ func(k wgtypes.Key) string          = wgtypes.Key.String
+	_ func(dt wgtypes.DeviceType) string  = wgtypes.DeviceType.String
+	_ [wgtypes.KeyLen]byte                = wgtypes.Key{}
+	_ []wgtypes.DeviceType                = []wgtypes.DeviceType{wgtypes.Unknown, wgtypes.LinuxKernel, wgtypes.OpenBSDKernel, wgtypes.FreeBSDKernel, wgtypes.WindowsKernel, wgtypes.Userspace}
+)
+
+func TestOriginalTypes(t *testing.T) {
+	// Each field of the original structures, with its original type. The
+	// values are irrelevant.
+	var (
+		key   wgtypes.Key
+		port  int
+		ka    time.Duration
+		addr  *net.UDPAddr
+		ipns  []net.IPNet
+		peers []wgtypes.Peer
+	)
+
+	_ = wgtypes.Device{
+		Name:         "",
+		Type:         wgtypes.Unknown,
+		PrivateKey:   key,
+		PublicKey:    key,
+		ListenPort:   port,
+		FirewallMark: port,
+		Peers:        peers,
+	}
+
+	_ = wgtypes.Peer{
+		PublicKey:                   key,
+		PresharedKey:                key,
+		Endpoint:                    addr,
+		PersistentKeepaliveInterval: ka,
+		LastHandshakeTime:           time.Time{},
+		ReceiveBytes:                int64(0),
+		TransmitBytes:               int64(0),
+		AllowedIPs:                  ipns,
+		ProtocolVersion:             port,
+	}
+
+	_ = wgtypes.Config{
+		PrivateKey:   &key,
+		ListenPort:   &port,
+		FirewallMark: &port,
+		ReplacePeers: false,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   key,
+			Remove:                      false,
+			UpdateOnly:                  false,
+			PresharedKey:                &key,
+			Endpoint:                    addr,
+			PersistentKeepaliveInterval: &ka,
+			ReplaceAllowedIPs:           false,
+			AllowedIPs:                  ipns,
+		}},
+	}
+}
+
+func TestNew(t *testing.T) {
+	c, err := wgcompat.New()
+	if err != nil {
+		t.Skipf("skipping, failed to create client: %v", err)
+	}
+
+	var cc wgcompat.Client = c
+	if err := cc.Close(); err != nil {
+		t.Fatalf("failed to close client: %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+// Package wgcompat pins the API of the original wgctrl module, so that code
+// written against it keeps building when it switches to this module.
+//
+// This module keeps the import path golang.zx2c4.com/wireguard/wgctrl, so
+// switching between the two modules needs no import rewriting or adapters: a
+// downstream module selects one or the other using a replace directive in its
+// go.mod file, and continues to import packages wgctrl and wgtypes. Because
+// both modules share an import path, only one of them can be part of a build.
+//
+// This package exists only in this module, so code which imports it does not
+// build against the original module. Instead, its Client interface and New
+// function have exactly the signatures of the original module, and its tests
+// fail to compile if this module changes any part of the original API, so
+// that code which uses only that API builds against either module.
+//
+// This module adds fields to some wgtypes structures, such as PeerConfig.Name
+// and Peer.HasPresharedKey, so structure literals must name their fields to
+// build against both modules.
+package wgcompat // import "golang.zx2c4.com/wireguard/wgctrl/wgcompat"
@@ -0,0 +1,355 @@
+package wgconf
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A File is a parsed WireGuard configuration file.
+type File struct {
+	Interface Interface
+	Peers     []Peer
+
+	// Comments are any comments which follow the last section of the file.
+	Comments []string
+}
+
+// A Field is a key and value which is not recognized by this package, such as
+// the Address key used by wg-quick(8).
+type Field struct {
+	Key, Value string
+}
+
+// An Interface is the [Interface] section of a File.
+type Interface struct {
+	PrivateKey   *wgtypes.Key
+	ListenPort   *int
+	FirewallMark *int
+
+	// Comments are the comments which appear within the section, or
+	// immediately before its header. Each comment includes its leading '#'.
+	Comments []string
+
+	// Extra contains any unrecognized keys, in the order they appeared.
+	Extra []Field
+}
+
+// A Peer is a [Peer] section of a File.
+type Peer struct {
+	PublicKey    wgtypes.Key
+	PresharedKey *wgtypes.Key
+
+	// Name is the peer's display name, as set by a "# Name =" comment in the
+	// section. Such a comment is not included in Comments.
+	Name string
+
+	// Endpoint is the peer's endpoint as written, which may be a host name
+	// rather than an IP address.
+	Endpoint string
+
+	PersistentKeepaliveInterval *time.Duration
+	AllowedIPs                  []net.IPNet
+
+	// Comments are the comments which appear within the section, or
+	// immediately before its header. Each comment includes its leading '#'.
+	Comments []string
+
+	// Extra contains any unrecognized keys, in the order they appeared.
+	Extra []Field
+}
+
+// Parse parses a configuration file from r.
+func Parse(r io.Reader) (*File, error) {
+	var (
+		f File
+
+		// The section which is currently being parsed, and comments which
+		// have not yet been attached to a section.
+		section  string
+		comments []string
+	)
+
+	// attach attaches any pending comments to the current section.
+	attach := func() {
+		switch section {
+		case "interface":
+			f.Interface.Comments = append(f.Interface.Comments, comments...)
+		case "peer":
+			p := &f.Peers[len(f.Peers)-1]
+			for _, c := range comments {
+				if name, ok := parseName(c); ok && p.Name == "" {
+					p.Name = name
+					continue
+				}
+
+				p.Comments = append(p.Comments, c)
+			}
+		default:
+			// Comments before the first section are attached to it.
+			return
+		}
+
+		comments = nil
+	}
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+
+		// Comments may also follow a key and value on the same line.
+		if i := strings.IndexByte(line, '#'); i != -1 {
+			comments = append(comments, strings.TrimSpace(line[i:]))
+			line = strings.TrimSpace(line[:i])
+		}
+
+		switch {
+		case line == "":
+			continue
+		case strings.EqualFold(line, "[Interface]"):
+			section = "interface"
+		case strings.EqualFold(line, "[Peer]"):
+			section = "peer"
+			f.Peers = append(f.Peers, Peer{})
+		case strings.HasPrefix(line, "["):
+			return nil, fmt.Errorf("wgconf: line %d: unknown section: %s", n, line)
+		default:
+			k, v, ok := strings.Cut(line, "=")
+			if !ok {
+				return nil, fmt.Errorf("wgconf: line %d: expected key = value: %q", n, line)
+			}
+			k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+
+			var err error
+			switch section {
+			case "interface":
+				err = f.Interface.parse(k, v)
+			case "peer":
+				err = f.Peers[len(f.Peers)-1].parse(k, v)
+			default:
+				err = fmt.Errorf("key %q is not in a section", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("wgconf: line %d: %v", n, err)
+			}
+		}
+
+		attach()
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	f.Comments = comments
+	return &f, nil
+}
+
+// parseName parses a display name from a comment of the form "# Name = foo",
+// as used by many tools which manage configuration files.
+func parseName(comment string) (string, bool) {
+	k, v, ok := strings.Cut(strings.TrimPrefix(comment, "#"), "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(k), "name") {
+		return "", false
+	}
+
+	v = strings.TrimSpace(v)
+	return v, v != ""
+}
+
+// parse parses a single key and value in an [Interface] section.
+func (ifi *Interface) parse(k, v string) error {
+	switch strings.ToLower(k) {
+	case "privatekey":
+		key, err := wgtypes.ParseKeyStrict(v)
+		if err != nil {
+			return err
+		}
+		ifi.PrivateKey = &key
+	case "listenport":
+		port, err := strconv.ParseUint(v, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid listen port: %v", err)
+		}
+		p := int(port)
+		ifi.ListenPort = &p
+	case "fwmark":
+		var mark uint64
+		if v != "off" {
+			var err error
+			mark, err = strconv.ParseUint(v, 0, 32)
+			if err != nil {
+				return fmt.Errorf("invalid fwmark: %v", err)
+			}
+		}
+		m := int(mark)
+		ifi.FirewallMark = &m
+	default:
+		ifi.Extra = append(ifi.Extra, Field{Key: k, Value: v})
+	}
+
+	return nil
+}
+
+// parse parses a single key and value in a [Peer] section.
+func (p *Peer) parse(k, v string) error {
+	switch strings.ToLower(k) {
+	case "publickey":
+		key, err := wgtypes.ParseKeyStrict(v)
+		if err != nil {
+			return err
+		}
+		p.PublicKey = key
+	case "presharedkey":
+		key, err := wgtypes.ParseKeyStrict(v)
+		if err != nil {
+			return err
+		}
+		p.PresharedKey = &key
+	case "endpoint":
+		if _, _, err := net.SplitHostPort(v); err != nil {
+			return fmt.Errorf("invalid endpoint: %v", err)
+		}
+		p.Endpoint = v
+	case "persistentkeepalive":
+		var secs uint64
+		if v != "off" {
+			var err error
+			secs, err = strconv.ParseUint(v, 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid persistent keepalive: %v", err)
+			}
+		}
+		d := time.Duration(secs) * time.Second
+		p.PersistentKeepaliveInterval = &d
+	case "allowedips":
+		ipns, err := wgtypes.ParseAllowedIPs(v)
+		if err != nil {
+			return err
+		}
+		p.AllowedIPs = append(p.AllowedIPs, ipns...)
+	default:
+		p.Extra = append(p.Extra, Field{Key: k, Value: v})
+	}
+
+	return nil
+}
+
+// Marshal formats f as a configuration file. Comments are written following
+// the header of the section to which they are attached.
+func (f *File) Marshal() []byte {
+	var b bytes.Buffer
+
+	b.WriteString("[Interface]\n")
+	writeComments(&b, f.Interface.Comments)
+	ifi := f.Interface
+	if ifi.PrivateKey != nil {
+		fmt.Fprintf(&b, "PrivateKey = %s\n", ifi.PrivateKey)
+	}
+	if ifi.ListenPort != nil {
+		fmt.Fprintf(&b, "ListenPort = %d\n", *ifi.ListenPort)
+	}
+	if ifi.FirewallMark != nil {
+		fmt.Fprintf(&b, "FwMark = %#x\n", *ifi.FirewallMark)
+	}
+	writeExtra(&b, ifi.Extra)
+
+	for _, p := range f.Peers {
+		b.WriteString("\n[Peer]\n")
+		if p.Name != "" {
+			fmt.Fprintf(&b, "# Name = %s\n", p.Name)
+		}
+		writeComments(&b, p.Comments)
+		fmt.Fprintf(&b, "PublicKey = %s\n", p.PublicKey)
+		if p.PresharedKey != nil {
+			fmt.Fprintf(&b, "PresharedKey = %s\n", p.PresharedKey)
+		}
+		if len(p.AllowedIPs) > 0 {
+			fmt.Fprintf(&b, "AllowedIPs = %s\n", wgtypes.JoinAllowedIPs(p.AllowedIPs))
+		}
+		if p.Endpoint != "" {
+			fmt.Fprintf(&b, "Endpoint = %s\n", p.Endpoint)
+		}
+		if p.PersistentKeepaliveInterval != nil {
+			fmt.Fprintf(&b, "PersistentKeepalive = %d\n", int(p.PersistentKeepaliveInterval.Seconds()))
+		}
+		writeExtra(&b, p.Extra)
+	}
+
+	if len(f.Comments) > 0 {
+		b.WriteString("\n")
+		writeComments(&b, f.Comments)
+	}
+
+	return b.Bytes()
+}
+
+// writeComments writes each of comments on its own line.
+func writeComments(w io.Writer, comments []string) {
+	for _, c := range comments {
+		fmt.Fprintln(w, c)
+	}
+}
+
+// writeExtra writes each of fields as a key and value.
+func writeExtra(w io.Writer, fields []Field) {
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s = %s\n", f.Key, f.Value)
+	}
+}
+
+// Config produces a Config which fully configures a device as described by
+// f, replacing any existing peers and their allowed IPs. Peer endpoints which
+// are host names are resolved.
+func (f *File) Config() (wgtypes.Config, error) {
+	cfg := wgtypes.Config{
+		PrivateKey:   f.Interface.PrivateKey,
+		ListenPort:   f.Interface.ListenPort,
+		FirewallMark: f.Interface.FirewallMark,
+		ReplacePeers: true,
+		Peers:        make([]wgtypes.PeerConfig, 0, len(f.Peers)),
+	}
+
+	for _, p := range f.Peers {
+		pc, err := p.PeerConfig()
+		if err != nil {
+			return wgtypes.Config{}, err
+		}
+
+		cfg.Peers = append(cfg.Peers, pc)
+	}
+
+	return cfg, nil
+}
+
+// PeerConfig produces a PeerConfig which fully configures the peer described
+// by p, replacing any existing allowed IPs. If the endpoint is a host name,
+// it is resolved.
+func (p *Peer) PeerConfig() (wgtypes.PeerConfig, error) {
+	pc := wgtypes.PeerConfig{
+		PublicKey:                   p.PublicKey,
+		Name:                        p.Name,
+		PresharedKey:                p.PresharedKey,
+		PersistentKeepaliveInterval: p.PersistentKeepaliveInterval,
+		ReplaceAllowedIPs:           true,
+		AllowedIPs:                  p.AllowedIPs,
+	}
+
+	if p.Endpoint != "" {
+		addr, err := net.ResolveUDPAddr("udp", p.Endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("wgconf: peer %s: %v", p.PublicKey, err)
+		}
+
+		pc.Endpoint = addr
+	}
+
+	return pc, nil
+}
@@ -0,0 +1,175 @@
+package wgconf_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgconf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	priv = wgtest.MustPrivateKey()
+	pub  = wgtest.MustPublicKey()
+	psk  = wgtest.MustPresharedKey()
+)
+
+func TestParseRoundTrip(t *testing.T) {
+	in := `# Office VPN, managed by ops.
+[Interface]
+PrivateKey = ` + priv.String() + `
+ListenPort = 51820
+Address = 10.0.0.1/24
+DNS = 10.0.0.53
+
+# Name = laptop
+[Peer]
+PublicKey = ` + pub.String() + ` # added 2021-01-01
+PresharedKey = ` + psk.String() + `
+AllowedIPs = 10.0.0.2/32, fd00::2/128
+Endpoint = vpn.example.com:51820
+PersistentKeepalive = 25
+X-Owner = alice
+
+# end of file
+`
+
+	f, err := wgconf.Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	var (
+		port = 51820
+		ka   = 25 * time.Second
+	)
+
+	want := &wgconf.File{
+		Interface: wgconf.Interface{
+			PrivateKey: &priv,
+			ListenPort: &port,
+			Comments:   []string{"# Office VPN, managed by ops."},
+			Extra: []wgconf.Field{
+				{Key: "Address", Value: "10.0.0.1/24"},
+				{Key: "DNS", Value: "10.0.0.53"},
+			},
+		},
+		Peers: []wgconf.Peer{{
+			PublicKey:                   pub,
+			PresharedKey:                &psk,
+			Endpoint:                    "vpn.example.com:51820",
+			PersistentKeepaliveInterval: &ka,
+			AllowedIPs: []net.IPNet{
+				wgtest.MustCIDR("10.0.0.2/32"),
+				wgtest.MustCIDR("fd00::2/128"),
+			},
+			Name:     "laptop",
+			Comments: []string{"# added 2021-01-01"},
+			Extra:    []wgconf.Field{{Key: "X-Owner", Value: "alice"}},
+		}},
+		Comments: []string{"# end of file"},
+	}
+
+	if diff := cmp.Diff(want, f); diff != "" {
+		t.Fatalf("unexpected File (-want +got):\n%s", diff)
+	}
+
+	// Formatting and parsing again must preserve all annotations.
+	f2, err := wgconf.Parse(strings.NewReader(string(f.Marshal())))
+	if err != nil {
+		t.Fatalf("failed to parse formatted file: %v", err)
+	}
+
+	if diff := cmp.Diff(f, f2); diff != "" {
+		t.Fatalf("unexpected round-tripped File (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name, in string
+	}{
+		{
+			name: "no section",
+			in:   "PrivateKey = " + priv.String(),
+		},
+		{
+			name: "unknown section",
+			in:   "[Foo]",
+		},
+		{
+			name: "no value",
+			in:   "[Interface]\nPrivateKey",
+		},
+		{
+			name: "bad key",
+			in:   "[Interface]\nPrivateKey = foo",
+		},
+		{
+			name: "bad port",
+			in:   "[Interface]\nListenPort = 65536",
+		},
+		{
+			name: "bad endpoint",
+			in:   "[Peer]\nEndpoint = 192.0.2.1",
+		},
+		{
+			name: "bad allowed IP",
+			in:   "[Peer]\nAllowedIPs = 192.0.2.1/33",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := wgconf.Parse(strings.NewReader(tt.in))
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func TestFileConfig(t *testing.T) {
+	in := `[Interface]
+FwMark = off
+
+[Peer]
+# name=phone
+PublicKey = ` + pub.String() + `
+Endpoint = 192.0.2.1:51820
+AllowedIPs = 0.0.0.0/0
+`
+
+	f, err := wgconf.Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	cfg, err := f.Config()
+	if err != nil {
+		t.Fatalf("failed to produce Config: %v", err)
+	}
+
+	mark := 0
+	want := wgtypes.Config{
+		FirewallMark: &mark,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:         pub,
+			Name:              "phone",
+			Endpoint:          wgtest.MustUDPAddr("192.0.2.1:51820"),
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+		}},
+	}
+
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,7 @@
+// Package wgconf parses and formats WireGuard configuration files, as used by
+// wg(8) setconf and wg-quick(8).
+//
+// Parsing and formatting a file preserves any comments and unrecognized keys,
+// such as the Address and DNS keys used by wg-quick, so that tools which
+// modify a configuration file do not discard annotations made by operators.
+package wgconf // import "golang.zx2c4.com/wireguard/wgctrl/wgconf"
@@ -0,0 +1,16 @@
+// Package wgendpoint provides helpers for managing the endpoints of WireGuard
+// peers which are reachable at more than one address, or from behind NAT.
+//
+// A Resolver periodically re-resolves peer endpoints which are specified by
+// host name, in the style of wireguard-tools' reresolve-dns.sh script, and
+// moves each peer between its IPv4 and IPv6 address candidates when
+// handshakes stall on dual-stack networks.
+//
+// Discover uses STUN to learn a device's external address, which can be
+// exchanged with peers through a rendezvous system and then passed to Punch
+// to establish a tunnel between two peers which are both behind NAT.
+//
+// MDNS advertises a device's endpoint on the local network using mDNS and
+// DNS-SD, and points peers discovered on the same network at their local
+// addresses so that traffic between them stays on the LAN.
+package wgendpoint // import "golang.zx2c4.com/wireguard/wgctrl/wgendpoint"
@@ -0,0 +1,395 @@
+package wgendpoint
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// MDNSService is the DNS-SD service type under which WireGuard endpoints are
+// advertised.
+const MDNSService = "_wireguard._udp.local."
+
+// Defaults used by MDNS.
+const (
+	DefaultMDNSInterval = time.Minute
+	mdnsTTL             = 120
+)
+
+// mdnsGroup is the IPv4 mDNS multicast group from RFC 6762.
+var mdnsGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// mdnsCacheFlush is the mDNS cache-flush bit, set in the class of records
+// which are unique to their owner.
+const mdnsCacheFlush = 0x8000
+
+// A LANPeer is a WireGuard endpoint discovered on the local network.
+type LANPeer struct {
+	// PublicKey is the public key of the advertising device.
+	PublicKey wgtypes.Key
+
+	// Endpoint is the device's address on the local network and its listen
+	// port.
+	Endpoint *net.UDPAddr
+}
+
+// An MDNS advertises the endpoint of a WireGuard device on the local network
+// using mDNS and DNS-SD, and discovers the endpoints advertised by its peers,
+// so that peers on the same network can connect directly instead of through a
+// relay or a NAT hairpin.
+//
+// Each device is advertised as an instance of MDNSService whose TXT record
+// carries its public key. When a peer of the device is discovered, its
+// endpoint is updated to the address it advertised from. Only IPv4 is
+// supported.
+//
+// Advertisements are not authenticated: any host on the local network can
+// direct a peer's endpoint elsewhere. This only affects availability, as
+// WireGuard authenticates every packet, and the endpoint roams back once the
+// peer sends from its real address.
+type MDNS struct {
+	// Client and Device identify the advertised device.
+	Client Client
+	Device string
+
+	// Interface is the network interface used for mDNS. If nil, the system
+	// chooses one.
+	Interface *net.Interface
+
+	// Interval is the interval between announcements and queries. If zero,
+	// DefaultMDNSInterval is used.
+	Interval time.Duration
+
+	// OnDiscover, if set, is called for each LANPeer discovered, including
+	// those which are not peers of the device.
+	OnDiscover func(p LANPeer)
+}
+
+// Run announces the device and queries for its peers every interval, and
+// answers queries and applies discovered endpoints until ctx is canceled.
+func (m *MDNS) Run(ctx context.Context) error {
+	conn, err := net.ListenMulticastUDP("udp4", m.Interface, mdnsGroup)
+	if err != nil {
+		return fmt.Errorf("wgendpoint: failed to join mDNS group: %v", err)
+	}
+	defer conn.Close()
+
+	errC := make(chan error, 1)
+	go func() { errC <- m.receive(conn) }()
+	defer func() {
+		// Interrupt receive and wait for it to return.
+		_ = conn.Close()
+		<-errC
+	}()
+
+	interval := m.Interval
+	if interval == 0 {
+		interval = DefaultMDNSInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := m.announce(conn, true); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errC:
+			errC <- nil
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// announce sends the device's records to the mDNS group, along with a query
+// for other instances of MDNSService if query is set.
+func (m *MDNS) announce(conn net.PacketConn, query bool) error {
+	d, err := m.Client.Device(m.Device)
+	if err != nil {
+		return err
+	}
+
+	b, err := mdnsAnnouncement(d.PublicKey, d.ListenPort, m.addrs(), query)
+	if err != nil {
+		return err
+	}
+
+	if _, err := conn.WriteTo(b, mdnsGroup); err != nil {
+		return fmt.Errorf("wgendpoint: failed to send mDNS announcement: %v", err)
+	}
+
+	return nil
+}
+
+// receive handles mDNS messages until conn is closed.
+func (m *MDNS) receive(conn net.PacketConn) error {
+	b := make([]byte, 9000)
+	for {
+		n, addr, err := conn.ReadFrom(b)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("wgendpoint: failed to receive mDNS message: %v", err)
+		}
+
+		src, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+
+		// Malformed messages and messages for other services are ignored.
+		query, peers := parseMDNS(b[:n], src.IP)
+		if query {
+			if err := m.announce(conn, false); err != nil {
+				return err
+			}
+		}
+		if err := m.discover(peers); err != nil {
+			return err
+		}
+	}
+}
+
+// discover updates the endpoints of the device's peers which are among peers.
+func (m *MDNS) discover(peers []LANPeer) error {
+	if len(peers) == 0 {
+		return nil
+	}
+
+	d, err := m.Client.Device(m.Device)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[wgtypes.Key]*net.UDPAddr, len(d.Peers))
+	for _, p := range d.Peers {
+		current[p.PublicKey] = p.Endpoint
+	}
+
+	var pcfg []wgtypes.PeerConfig
+	for _, p := range peers {
+		if p.PublicKey == d.PublicKey {
+			continue
+		}
+
+		if m.OnDiscover != nil {
+			m.OnDiscover(p)
+		}
+
+		ep, ok := current[p.PublicKey]
+		if !ok || equal(ep, p.Endpoint) {
+			continue
+		}
+
+		pcfg = append(pcfg, wgtypes.PeerConfig{
+			PublicKey:  p.PublicKey,
+			UpdateOnly: true,
+			Endpoint:   p.Endpoint,
+		})
+	}
+
+	if len(pcfg) == 0 {
+		return nil
+	}
+
+	return m.Client.ConfigureDevice(m.Device, wgtypes.Config{Peers: pcfg})
+}
+
+// addrs returns the IPv4 addresses of Interface, if set.
+func (m *MDNS) addrs() []net.IP {
+	if m.Interface == nil {
+		return nil
+	}
+
+	addrs, err := m.Interface.Addrs()
+	if err != nil {
+		return nil
+	}
+
+	var ips []net.IP
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok && ipn.IP.To4() != nil {
+			ips = append(ips, ipn.IP.To4())
+		}
+	}
+
+	return ips
+}
+
+// mdnsInstance returns the DNS-SD instance and host names for publicKey.
+func mdnsInstance(publicKey wgtypes.Key) (instance, host string) {
+	instance = base64.RawURLEncoding.EncodeToString(publicKey[:]) + "." + MDNSService
+	host = "wg-" + hex.EncodeToString(publicKey[:8]) + ".local."
+	return instance, host
+}
+
+// mdnsAnnouncement builds an mDNS response advertising the device with
+// publicKey and port at addrs, which also queries for other instances of
+// MDNSService if query is set.
+func mdnsAnnouncement(publicKey wgtypes.Key, port int, addrs []net.IP, query bool) ([]byte, error) {
+	instance, host := mdnsInstance(publicKey)
+
+	var (
+		service = dnsmessage.MustNewName(MDNSService)
+		inst    = dnsmessage.MustNewName(instance)
+		target  = dnsmessage.MustNewName(host)
+	)
+
+	hdr := func(name dnsmessage.Name, typ dnsmessage.Type, unique bool) dnsmessage.ResourceHeader {
+		class := dnsmessage.ClassINET
+		if unique {
+			class |= mdnsCacheFlush
+		}
+
+		return dnsmessage.ResourceHeader{Name: name, Type: typ, Class: class, TTL: mdnsTTL}
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{Response: true, Authoritative: true})
+	b.EnableCompression()
+
+	err := func() error {
+		if query {
+			if err := b.StartQuestions(); err != nil {
+				return err
+			}
+
+			err := b.Question(dnsmessage.Question{
+				Name:  service,
+				Type:  dnsmessage.TypePTR,
+				Class: dnsmessage.ClassINET,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := b.StartAnswers(); err != nil {
+			return err
+		}
+		if err := b.PTRResource(hdr(service, dnsmessage.TypePTR, false), dnsmessage.PTRResource{PTR: inst}); err != nil {
+			return err
+		}
+		if err := b.SRVResource(hdr(inst, dnsmessage.TypeSRV, true), dnsmessage.SRVResource{Port: uint16(port), Target: target}); err != nil {
+			return err
+		}
+		if err := b.TXTResource(hdr(inst, dnsmessage.TypeTXT, true), dnsmessage.TXTResource{TXT: []string{"pk=" + publicKey.String()}}); err != nil {
+			return err
+		}
+
+		for _, ip := range addrs {
+			var a dnsmessage.AResource
+			copy(a.A[:], ip.To4())
+			if err := b.AResource(hdr(target, dnsmessage.TypeA, true), a); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}()
+	if err != nil {
+		return nil, fmt.Errorf("wgendpoint: failed to build mDNS announcement: %v", err)
+	}
+
+	out, err := b.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("wgendpoint: failed to build mDNS announcement: %v", err)
+	}
+
+	return out, nil
+}
+
+// parseMDNS parses an mDNS message received from src. It reports whether the
+// message queries for MDNSService, and returns the LANPeers it advertises,
+// whose endpoints use src as their address.
+func parseMDNS(b []byte, src net.IP) (query bool, peers []LANPeer) {
+	var p dnsmessage.Parser
+	h, err := p.Start(b)
+	if err != nil {
+		return false, nil
+	}
+
+	qs, err := p.AllQuestions()
+	if err != nil {
+		return false, nil
+	}
+
+	for _, q := range qs {
+		if strings.EqualFold(q.Name.String(), MDNSService) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) {
+			query = true
+		}
+	}
+
+	if !h.Response {
+		return query, nil
+	}
+
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return query, nil
+	}
+
+	// Match each instance's SRV and TXT records by name.
+	var (
+		ports = make(map[string]uint16)
+		keys  = make(map[string]wgtypes.Key)
+		names []string
+	)
+
+	for _, r := range answers {
+		name := strings.ToLower(r.Header.Name.String())
+		if !strings.HasSuffix(name, "."+MDNSService) {
+			continue
+		}
+
+		switch body := r.Body.(type) {
+		case *dnsmessage.SRVResource:
+			ports[name] = body.Port
+		case *dnsmessage.TXTResource:
+			for _, s := range body.TXT {
+				k, ok := strings.CutPrefix(s, "pk=")
+				if !ok {
+					continue
+				}
+
+				key, err := wgtypes.ParseKey(k)
+				if err != nil {
+					continue
+				}
+
+				if _, ok := keys[name]; !ok {
+					names = append(names, name)
+				}
+				keys[name] = key
+			}
+		}
+	}
+
+	for _, name := range names {
+		port, ok := ports[name]
+		if !ok || port == 0 {
+			continue
+		}
+
+		peers = append(peers, LANPeer{
+			PublicKey: keys[name],
+			Endpoint:  &net.UDPAddr{IP: src, Port: int(port)},
+		})
+	}
+
+	return query, peers
+}
@@ -0,0 +1,109 @@
+package wgendpoint
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestMDNSAnnouncement(t *testing.T) {
+	var (
+		pub = wgtest.MustPublicKey()
+		src = net.IPv4(192, 168, 1, 10)
+	)
+
+	b, err := mdnsAnnouncement(pub, 51820, []net.IP{src}, true)
+	if err != nil {
+		t.Fatalf("failed to build announcement: %v", err)
+	}
+
+	query, peers := parseMDNS(b, src)
+	if !query {
+		t.Fatal("expected a query for the service")
+	}
+
+	want := []LANPeer{{
+		PublicKey: pub,
+		Endpoint:  &net.UDPAddr{IP: src, Port: 51820},
+	}}
+
+	if diff := cmp.Diff(want, peers); diff != "" {
+		t.Fatalf("unexpected peers (-want +got):\n%s", diff)
+	}
+
+	// Announcements sent in answer to a query carry no question.
+	b, err = mdnsAnnouncement(pub, 51820, nil, false)
+	if err != nil {
+		t.Fatalf("failed to build announcement: %v", err)
+	}
+	if query, _ := parseMDNS(b, src); query {
+		t.Fatal("unexpected query for the service")
+	}
+
+	if query, peers := parseMDNS([]byte("not mDNS"), src); query || peers != nil {
+		t.Fatalf("unexpected result for malformed message: %v, %v", query, peers)
+	}
+}
+
+func TestMDNSDiscover(t *testing.T) {
+	var (
+		self    = wgtest.MustPublicKey()
+		peer    = wgtest.MustPublicKey()
+		unknown = wgtest.MustPublicKey()
+		ep      = wgtest.MustUDPAddr("192.168.1.10:51820")
+	)
+
+	c := &testClient{d: &wgtypes.Device{
+		PublicKey: self,
+		Peers: []wgtypes.Peer{{
+			PublicKey: peer,
+			Endpoint:  wgtest.MustUDPAddr("203.0.113.1:51820"),
+		}},
+	}}
+
+	var discovered []LANPeer
+	m := &MDNS{
+		Client:     c,
+		Device:     "wg0",
+		OnDiscover: func(p LANPeer) { discovered = append(discovered, p) },
+	}
+
+	peers := []LANPeer{
+		{PublicKey: self, Endpoint: wgtest.MustUDPAddr("192.168.1.2:51820")},
+		{PublicKey: peer, Endpoint: ep},
+		{PublicKey: unknown, Endpoint: wgtest.MustUDPAddr("192.168.1.11:51820")},
+	}
+
+	if err := m.discover(peers); err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+
+	want := []wgtypes.Config{{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:  peer,
+			UpdateOnly: true,
+			Endpoint:   ep,
+		}},
+	}}
+
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(peers[1:], discovered); diff != "" {
+		t.Fatalf("unexpected discovered peers (-want +got):\n%s", diff)
+	}
+
+	// A peer which already uses the discovered endpoint is left alone.
+	c.d.Peers[0].Endpoint = ep
+	c.cfgs = nil
+
+	if err := m.discover(peers); err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+	if len(c.cfgs) != 0 {
+		t.Fatalf("unexpected configurations: %d", len(c.cfgs))
+	}
+}
@@ -0,0 +1,144 @@
+package wgendpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Defaults used by Punch.
+const (
+	defaultPunchKeepalive = 25 * time.Second
+	defaultPollInterval   = 250 * time.Millisecond
+)
+
+// A PunchConfig configures a NAT hole punching attempt with Punch.
+type PunchConfig struct {
+	// PublicKey identifies the peer on the device.
+	PublicKey wgtypes.Key
+
+	// Endpoint is the peer's external address, typically learned from a
+	// rendezvous server which both peers have contacted.
+	Endpoint *net.UDPAddr
+
+	// PersistentKeepaliveInterval keeps the NAT mappings open once the
+	// hole is punched. If zero, a default of 25 seconds is used.
+	PersistentKeepaliveInterval time.Duration
+
+	// PollInterval is how often the device is checked for a completed
+	// handshake. If zero, a default of 250 milliseconds is used.
+	PollInterval time.Duration
+}
+
+// Punch performs the classic WireGuard NAT-to-NAT traversal technique: both
+// peers simultaneously point their endpoint at the other's external address
+// with a persistent keepalive enabled, so that each side's outgoing packets
+// open a NAT mapping for the other side's packets.
+//
+// Punch must be called on both hosts at roughly the same time. It returns nil
+// once a handshake completes with the peer, or an error when ctx is canceled
+// before that happens. The peer's endpoint and keepalive are left in place in
+// either case.
+func Punch(ctx context.Context, c Client, device string, cfg PunchConfig) error {
+	if cfg.Endpoint == nil {
+		return fmt.Errorf("wgendpoint: hole punching requires an endpoint")
+	}
+
+	keepalive := cfg.PersistentKeepaliveInterval
+	if keepalive == 0 {
+		keepalive = defaultPunchKeepalive
+	}
+
+	poll := cfg.PollInterval
+	if poll == 0 {
+		poll = defaultPollInterval
+	}
+
+	// Only a handshake which completes after this point indicates success.
+	start := time.Now()
+
+	// Enabling a persistent keepalive sends a keepalive immediately, which
+	// also initiates a handshake when no session exists.
+	err := c.ConfigureDevice(device, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey:                   cfg.PublicKey,
+		UpdateOnly:                  true,
+		Endpoint:                    cfg.Endpoint,
+		PersistentKeepaliveInterval: &keepalive,
+	}}})
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTicker(poll)
+	defer t.Stop()
+
+	for {
+		p, err := peer(c, device, cfg.PublicKey)
+		if err != nil {
+			return err
+		}
+		if !p.LastHandshakeTime.Before(start) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wgendpoint: no handshake with peer %s via %s: %w",
+				cfg.PublicKey, cfg.Endpoint, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+// Nudge asks a device to send a keepalive to a peer right away, which also
+// initiates a handshake if no session exists.
+//
+// Neither the kernel nor the userspace configuration protocols expose this
+// directly, but the Linux kernel and wireguard-go both send a keepalive when a
+// non-zero persistent keepalive interval is applied to a running device. Nudge
+// relies on this by re-applying the peer's interval, or by briefly enabling
+// and then disabling a keepalive for peers which have none. Backends which do
+// not behave this way will not send a packet.
+func Nudge(c Client, device string, publicKey wgtypes.Key) error {
+	p, err := peer(c, device, publicKey)
+	if err != nil {
+		return err
+	}
+
+	set := func(d time.Duration) error {
+		return c.ConfigureDevice(device, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   publicKey,
+			UpdateOnly:                  true,
+			PersistentKeepaliveInterval: &d,
+		}}})
+	}
+
+	if p.PersistentKeepaliveInterval != 0 {
+		return set(p.PersistentKeepaliveInterval)
+	}
+
+	if err := set(time.Second); err != nil {
+		return err
+	}
+
+	return set(0)
+}
+
+// peer retrieves the peer identified by publicKey from a device.
+func peer(c Client, device string, publicKey wgtypes.Key) (*wgtypes.Peer, error) {
+	d, err := c.Device(device)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range d.Peers {
+		if p.PublicKey == publicKey {
+			return &p, nil
+		}
+	}
+
+	return nil, fmt.Errorf("wgendpoint: device %q has no peer %s", device, publicKey)
+}
@@ -0,0 +1,99 @@
+package wgendpoint
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestPunch(t *testing.T) {
+	var (
+		key = wgtest.MustPublicKey()
+		ep  = wgtest.MustUDPAddr("198.51.100.1:41641")
+	)
+
+	c := &testClient{
+		d: &wgtypes.Device{Peers: []wgtypes.Peer{{PublicKey: key}}},
+		onGet: func(d *wgtypes.Device, n int) {
+			// Complete the handshake on the third poll.
+			if n == 3 {
+				d.Peers[0].LastHandshakeTime = time.Now()
+			}
+		},
+	}
+
+	err := Punch(context.Background(), c, "wg0", PunchConfig{
+		PublicKey:    key,
+		Endpoint:     ep,
+		PollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("failed to punch: %v", err)
+	}
+
+	keepalive := defaultPunchKeepalive
+	want := []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{
+		PublicKey:                   key,
+		UpdateOnly:                  true,
+		Endpoint:                    ep,
+		PersistentKeepaliveInterval: &keepalive,
+	}}}}
+
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+}
+
+func TestPunchTimeout(t *testing.T) {
+	key := wgtest.MustPublicKey()
+	c := &testClient{d: &wgtypes.Device{Peers: []wgtypes.Peer{{PublicKey: key}}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := Punch(ctx, c, "wg0", PunchConfig{
+		PublicKey:    key,
+		Endpoint:     wgtest.MustUDPAddr("198.51.100.1:41641"),
+		PollInterval: time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, but got: %v", err)
+	}
+}
+
+func TestNudge(t *testing.T) {
+	var (
+		on  = wgtest.MustPublicKey()
+		off = wgtest.MustPublicKey()
+	)
+
+	c := &testClient{d: &wgtypes.Device{Peers: []wgtypes.Peer{
+		{PublicKey: on, PersistentKeepaliveInterval: 25 * time.Second},
+		{PublicKey: off},
+	}}}
+
+	for _, k := range []wgtypes.Key{on, off} {
+		if err := Nudge(c, "wg0", k); err != nil {
+			t.Fatalf("failed to nudge: %v", err)
+		}
+	}
+
+	var got []time.Duration
+	for _, cfg := range c.cfgs {
+		got = append(got, *cfg.Peers[0].PersistentKeepaliveInterval)
+	}
+
+	want := []time.Duration{25 * time.Second, time.Second, 0}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected keepalive intervals (-want +got):\n%s", diff)
+	}
+
+	if err := Nudge(c, "wg0", wgtest.MustPublicKey()); err == nil {
+		t.Fatal("expected an error for unknown peer, but none occurred")
+	}
+}
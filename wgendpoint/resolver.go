@@ -0,0 +1,279 @@
+package wgendpoint
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Client is a type which can retrieve and configure WireGuard devices, such
+// as *wgctrl.Client.
+type Client interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// A Family is an IP address family preference for a peer endpoint.
+type Family int
+
+// Possible Family values.
+const (
+	// Any uses address candidates in the order they are returned by the
+	// resolver.
+	Any Family = iota
+
+	// IPv4 prefers IPv4 address candidates, falling back to IPv6.
+	IPv4
+
+	// IPv6 prefers IPv6 address candidates, falling back to IPv4.
+	IPv6
+)
+
+// String returns the string representation of a Family.
+func (f Family) String() string {
+	switch f {
+	case Any:
+		return "any"
+	case IPv4:
+		return "IPv4"
+	case IPv6:
+		return "IPv6"
+	default:
+		return "unknown"
+	}
+}
+
+// defaultStallTimeout matches the interval used by reresolve-dns.sh to
+// determine that a peer's handshake has gone stale.
+const defaultStallTimeout = 135 * time.Second
+
+// A Peer is a WireGuard peer whose endpoint is specified by host name.
+type Peer struct {
+	// PublicKey identifies the peer on the device.
+	PublicKey wgtypes.Key
+
+	// Endpoint is the peer's endpoint in host:port form, such as
+	// "vpn.example.com:51820".
+	Endpoint string
+
+	// Prefer is the peer's preferred address family.
+	Prefer Family
+}
+
+// A Resolver re-resolves the endpoints of Peers on a single WireGuard device.
+//
+// When a peer has not completed a handshake within StallTimeout, the Resolver
+// moves it to its next address candidate, starting with those of the
+// preferred address family. Peers with recent handshakes are left alone.
+type Resolver struct {
+	// Client is used to retrieve and configure Device.
+	Client Client
+
+	// Device is the name of the WireGuard device.
+	Device string
+
+	// Peers are the peers whose endpoints are managed.
+	Peers []Peer
+
+	// StallTimeout is the amount of time after a peer's last handshake, or
+	// after an endpoint change, before a peer is moved to its next address
+	// candidate. If zero, a default of 135 seconds is used.
+	StallTimeout time.Duration
+
+	// LookupIP resolves host names to addresses for the "ip" network. If
+	// nil, net.DefaultResolver.LookupIP is used.
+	LookupIP func(ctx context.Context, network, host string) ([]net.IP, error)
+
+	// now is the current time source, which can be swapped out in tests.
+	now func() time.Time
+
+	// changed tracks the last time each peer's endpoint was changed.
+	changed map[wgtypes.Key]time.Time
+}
+
+// Run calls Check every interval until ctx is canceled. Errors from Check
+// are passed to onError, if not nil, and do not stop the Resolver.
+func (r *Resolver) Run(ctx context.Context, interval time.Duration, onError func(err error)) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := r.Check(ctx); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Check performs a single pass over each of the Resolver's Peers, resolving
+// and updating the endpoint of any peer whose handshake has stalled.
+func (r *Resolver) Check(ctx context.Context) error {
+	d, err := r.Client.Device(r.Device)
+	if err != nil {
+		return err
+	}
+
+	peers := make(map[wgtypes.Key]wgtypes.Peer, len(d.Peers))
+	for _, p := range d.Peers {
+		peers[p.PublicKey] = p
+	}
+
+	if r.changed == nil {
+		r.changed = make(map[wgtypes.Key]time.Time)
+	}
+
+	var (
+		now  = r.timeNow()
+		pcfg []wgtypes.PeerConfig
+	)
+
+	for _, rp := range r.Peers {
+		p, ok := peers[rp.PublicKey]
+		if !ok || !r.stalled(p, now) {
+			continue
+		}
+
+		cands, err := r.Candidates(ctx, rp)
+		if err != nil {
+			return err
+		}
+
+		next := Next(cands, p.Endpoint)
+		if next == nil || equal(next, p.Endpoint) {
+			continue
+		}
+
+		r.changed[rp.PublicKey] = now
+		pcfg = append(pcfg, wgtypes.PeerConfig{
+			PublicKey:  rp.PublicKey,
+			UpdateOnly: true,
+			Endpoint:   next,
+		})
+	}
+
+	if len(pcfg) == 0 {
+		return nil
+	}
+
+	return r.Client.ConfigureDevice(r.Device, wgtypes.Config{Peers: pcfg})
+}
+
+// Candidates resolves the endpoint address candidates for p, ordered by its
+// address family preference.
+func (r *Resolver) Candidates(ctx context.Context, p Peer) ([]*net.UDPAddr, error) {
+	host, sport, err := net.SplitHostPort(p.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("wgendpoint: invalid endpoint %q: %v", p.Endpoint, err)
+	}
+
+	port, err := strconv.Atoi(sport)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, fmt.Errorf("wgendpoint: invalid port in endpoint %q", p.Endpoint)
+	}
+
+	lookup := r.LookupIP
+	if lookup == nil {
+		lookup = net.DefaultResolver.LookupIP
+	}
+
+	ips, err := lookup(ctx, "ip", host)
+	if err != nil {
+		return nil, fmt.Errorf("wgendpoint: failed to resolve %q: %v", host, err)
+	}
+
+	cands := make([]*net.UDPAddr, 0, len(ips))
+	for _, ip := range Sort(ips, p.Prefer) {
+		cands = append(cands, &net.UDPAddr{IP: ip, Port: port})
+	}
+
+	return cands, nil
+}
+
+// stalled determines if p has not completed a handshake recently enough, and
+// has not had its endpoint changed recently enough, as of now.
+func (r *Resolver) stalled(p wgtypes.Peer, now time.Time) bool {
+	timeout := r.StallTimeout
+	if timeout == 0 {
+		timeout = defaultStallTimeout
+	}
+
+	if now.Sub(p.LastHandshakeTime) < timeout {
+		return false
+	}
+
+	// Give a new endpoint a full timeout to complete a handshake.
+	changed, ok := r.changed[p.PublicKey]
+	return !ok || now.Sub(changed) >= timeout
+}
+
+// timeNow returns the current time.
+func (r *Resolver) timeNow() time.Time {
+	if r.now != nil {
+		return r.now()
+	}
+
+	return time.Now()
+}
+
+// Sort returns a copy of ips with addresses of the prefer address family
+// first, otherwise preserving the order of ips.
+func Sort(ips []net.IP, prefer Family) []net.IP {
+	out := make([]net.IP, 0, len(ips))
+	if prefer == Any {
+		return append(out, ips...)
+	}
+
+	for _, pass := range []bool{true, false} {
+		for _, ip := range ips {
+			if (family(ip) == prefer) == pass {
+				out = append(out, ip)
+			}
+		}
+	}
+
+	return out
+}
+
+// Next returns the candidate which follows current in cands, wrapping
+// around to the first candidate. If current is not one of cands, the first
+// candidate is returned. If cands is empty, Next returns nil.
+func Next(cands []*net.UDPAddr, current *net.UDPAddr) *net.UDPAddr {
+	if len(cands) == 0 {
+		return nil
+	}
+
+	for i, c := range cands {
+		if equal(c, current) {
+			return cands[(i+1)%len(cands)]
+		}
+	}
+
+	return cands[0]
+}
+
+// family returns the address Family of ip.
+func family(ip net.IP) Family {
+	if ip.To4() != nil {
+		return IPv4
+	}
+
+	return IPv6
+}
+
+// equal determines if a and b are the same UDP address.
+func equal(a, b *net.UDPAddr) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.IP.Equal(b.IP) && a.Port == b.Port && a.Zone == b.Zone
+}
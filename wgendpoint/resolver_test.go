@@ -0,0 +1,158 @@
+package wgendpoint
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSort(t *testing.T) {
+	ips := []net.IP{
+		net.ParseIP("2001:db8::1"),
+		net.ParseIP("192.0.2.1"),
+		net.ParseIP("2001:db8::2"),
+		net.ParseIP("192.0.2.2"),
+	}
+
+	tests := []struct {
+		prefer Family
+		want   []string
+	}{
+		{
+			prefer: Any,
+			want:   []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"},
+		},
+		{
+			prefer: IPv4,
+			want:   []string{"192.0.2.1", "192.0.2.2", "2001:db8::1", "2001:db8::2"},
+		},
+		{
+			prefer: IPv6,
+			want:   []string{"2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefer.String(), func(t *testing.T) {
+			var got []string
+			for _, ip := range Sort(ips, tt.prefer) {
+				got = append(got, ip.String())
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected order (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResolverCheck(t *testing.T) {
+	var (
+		now   = time.Unix(1600000000, 0)
+		stale = now.Add(-time.Hour)
+
+		fresh  = wgtest.MustPublicKey()
+		stuck  = wgtest.MustPublicKey()
+		absent = wgtest.MustPublicKey()
+
+		v4 = wgtest.MustUDPAddr("192.0.2.1:51820")
+		v6 = wgtest.MustUDPAddr("[2001:db8::1]:51820")
+	)
+
+	c := &testClient{d: &wgtypes.Device{Peers: []wgtypes.Peer{
+		{PublicKey: fresh, Endpoint: v6, LastHandshakeTime: now.Add(-time.Second)},
+		{PublicKey: stuck, Endpoint: v6, LastHandshakeTime: stale},
+	}}}
+
+	r := &Resolver{
+		Client: c,
+		Device: "wg0",
+		Peers: []Peer{
+			{PublicKey: fresh, Endpoint: "vpn.example.com:51820", Prefer: IPv6},
+			{PublicKey: stuck, Endpoint: "vpn.example.com:51820", Prefer: IPv6},
+			{PublicKey: absent, Endpoint: "vpn.example.com:51820"},
+		},
+		LookupIP: func(_ context.Context, network, host string) ([]net.IP, error) {
+			return []net.IP{v4.IP, v6.IP}, nil
+		},
+		now: func() time.Time { return now },
+	}
+
+	// The stuck peer falls back from its preferred IPv6 endpoint to IPv4.
+	if err := r.Check(context.Background()); err != nil {
+		t.Fatalf("failed to check: %v", err)
+	}
+
+	want := []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{
+		PublicKey:  stuck,
+		UpdateOnly: true,
+		Endpoint:   v4,
+	}}}}
+
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+
+	// The new endpoint is given a full timeout before changing again.
+	c.d.Peers[1].Endpoint = v4
+	now = now.Add(time.Minute)
+	if err := r.Check(context.Background()); err != nil {
+		t.Fatalf("failed to check: %v", err)
+	}
+	if len(c.cfgs) != 1 {
+		t.Fatalf("expected no further configuration, but got: %d", len(c.cfgs))
+	}
+
+	now = now.Add(defaultStallTimeout)
+	c.d.Peers[0].LastHandshakeTime = now
+	if err := r.Check(context.Background()); err != nil {
+		t.Fatalf("failed to check: %v", err)
+	}
+
+	if diff := cmp.Diff(stuck, c.cfgs[1].Peers[0].PublicKey); diff != "" {
+		t.Fatalf("unexpected peer (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(v6, c.cfgs[1].Peers[0].Endpoint); diff != "" {
+		t.Fatalf("unexpected wrapped endpoint (-want +got):\n%s", diff)
+	}
+}
+
+func TestResolverCandidatesError(t *testing.T) {
+	r := &Resolver{LookupIP: func(_ context.Context, _, _ string) ([]net.IP, error) {
+		return nil, nil
+	}}
+
+	for _, ep := range []string{"foo", "foo:bar", "foo:0", "foo:65536"} {
+		if _, err := r.Candidates(context.Background(), Peer{Endpoint: ep}); err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", ep)
+		}
+	}
+}
+
+type testClient struct {
+	d    *wgtypes.Device
+	cfgs []wgtypes.Config
+
+	// gets counts calls to Device, and onGet is invoked on each call.
+	gets  int
+	onGet func(d *wgtypes.Device, n int)
+}
+
+func (c *testClient) Device(_ string) (*wgtypes.Device, error) {
+	c.gets++
+	if c.onGet != nil {
+		c.onGet(c.d, c.gets)
+	}
+
+	return c.d, nil
+}
+
+func (c *testClient) ConfigureDevice(_ string, cfg wgtypes.Config) error {
+	c.cfgs = append(c.cfgs, cfg)
+	return nil
+}
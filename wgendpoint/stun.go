@@ -0,0 +1,259 @@
+package wgendpoint
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// STUN protocol constants from RFC 5389.
+const (
+	stunHeaderLen        = 20
+	stunMagicCookie      = 0x2112a442
+	stunBindingRequest   = 0x0001
+	stunBindingSuccess   = 0x0101
+	stunMappedAddress    = 0x0001
+	stunXORMappedAddress = 0x0020
+
+	stunFamilyIPv4 = 0x01
+	stunFamilyIPv6 = 0x02
+)
+
+// stunRetransmit is the interval between STUN binding request retransmissions.
+const stunRetransmit = 500 * time.Millisecond
+
+// A Mapping is an external address discovered using STUN.
+type Mapping struct {
+	// Addr is the external address as seen by the STUN server.
+	Addr *net.UDPAddr
+
+	// LocalPort is the local UDP port which the binding request was sent
+	// from.
+	LocalPort int
+
+	// ListenPort reports whether LocalPort is the requested listen port. If
+	// false, the request was sent from an ephemeral port and the port of Addr
+	// only applies to the listen port behind NATs which preserve ports.
+	ListenPort bool
+}
+
+// DiscoverDevice discovers the external address of a WireGuard device's
+// listen port using the STUN server at server, as with Discover.
+func DiscoverDevice(ctx context.Context, c Client, device, server string) (*Mapping, error) {
+	d, err := c.Device(device)
+	if err != nil {
+		return nil, err
+	}
+
+	return Discover(ctx, server, d.ListenPort)
+}
+
+// Discover sends STUN binding requests to server, in host:port form, and
+// returns the external address of the local UDP port listenPort, for use by
+// endpoint exchange systems.
+//
+// Discover first attempts to bind listenPort directly, which succeeds when no
+// device is listening yet. It then attempts a parallel socket with
+// SO_REUSEPORT where the platform supports it, which only succeeds when the
+// existing socket also permits port reuse. Otherwise, the request is sent from
+// an ephemeral port and the returned Mapping reports this. Requests are
+// retransmitted until a response arrives or ctx is canceled.
+func Discover(ctx context.Context, server string, listenPort int) (*Mapping, error) {
+	raddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return nil, fmt.Errorf("wgendpoint: failed to resolve STUN server: %v", err)
+	}
+
+	conn, isListen, err := listenSTUN(ctx, listenPort)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	addr, err := stunExchange(ctx, conn, raddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mapping{
+		Addr:       addr,
+		LocalPort:  conn.LocalAddr().(*net.UDPAddr).Port,
+		ListenPort: isListen,
+	}, nil
+}
+
+// listenSTUN opens a UDP socket on port, with port reuse if necessary, or on
+// an ephemeral port if both attempts fail.
+func listenSTUN(ctx context.Context, port int) (net.PacketConn, bool, error) {
+	if port != 0 {
+		addr := ":" + strconv.Itoa(port)
+		if c, err := net.ListenPacket("udp", addr); err == nil {
+			return c, true, nil
+		}
+
+		lc := net.ListenConfig{Control: reusePort}
+		if c, err := lc.ListenPacket(ctx, "udp", addr); err == nil {
+			return c, true, nil
+		}
+	}
+
+	c, err := net.ListenPacket("udp", ":0")
+	if err != nil {
+		return nil, false, fmt.Errorf("wgendpoint: failed to open STUN socket: %v", err)
+	}
+
+	return c, false, nil
+}
+
+// stunExchange performs a STUN binding transaction with raddr over c.
+func stunExchange(ctx context.Context, c net.PacketConn, raddr net.Addr) (*net.UDPAddr, error) {
+	var txid [12]byte
+	if _, err := rand.Read(txid[:]); err != nil {
+		return nil, fmt.Errorf("wgendpoint: failed to generate STUN transaction ID: %v", err)
+	}
+
+	req := stunRequest(txid)
+	b := make([]byte, 1500)
+
+	for {
+		if _, err := c.WriteTo(req, raddr); err != nil {
+			return nil, fmt.Errorf("wgendpoint: failed to send STUN request: %v", err)
+		}
+
+		deadline := time.Now().Add(stunRetransmit)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := c.SetReadDeadline(deadline); err != nil {
+			return nil, err
+		}
+
+		for {
+			n, _, err := c.ReadFrom(b)
+			if err != nil {
+				var nerr net.Error
+				if !errors.As(err, &nerr) || !nerr.Timeout() {
+					return nil, fmt.Errorf("wgendpoint: failed to read STUN response: %v", err)
+				}
+
+				break
+			}
+
+			// Ignore stray packets and responses to other transactions.
+			if addr, err := parseSTUNResponse(b[:n], txid); err == nil {
+				return addr, nil
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("wgendpoint: no STUN response from %s: %w", raddr, err)
+		}
+	}
+}
+
+// stunRequest produces a STUN binding request with transaction ID txid.
+func stunRequest(txid [12]byte) []byte {
+	b := make([]byte, stunHeaderLen)
+	binary.BigEndian.PutUint16(b[0:2], stunBindingRequest)
+	binary.BigEndian.PutUint16(b[2:4], 0)
+	binary.BigEndian.PutUint32(b[4:8], stunMagicCookie)
+	copy(b[8:20], txid[:])
+
+	return b
+}
+
+// parseSTUNResponse parses the mapped address from a STUN binding success
+// response to the transaction txid.
+func parseSTUNResponse(b []byte, txid [12]byte) (*net.UDPAddr, error) {
+	if len(b) < stunHeaderLen {
+		return nil, errors.New("wgendpoint: short STUN message")
+	}
+	if binary.BigEndian.Uint16(b[0:2]) != stunBindingSuccess {
+		return nil, errors.New("wgendpoint: not a STUN binding success response")
+	}
+	if binary.BigEndian.Uint32(b[4:8]) != stunMagicCookie || string(b[8:20]) != string(txid[:]) {
+		return nil, errors.New("wgendpoint: STUN transaction mismatch")
+	}
+
+	n := int(binary.BigEndian.Uint16(b[2:4]))
+	if len(b) < stunHeaderLen+n {
+		return nil, errors.New("wgendpoint: truncated STUN message")
+	}
+
+	var mapped *net.UDPAddr
+	attrs := b[stunHeaderLen : stunHeaderLen+n]
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:2])
+		alen := int(binary.BigEndian.Uint16(attrs[2:4]))
+		if len(attrs) < 4+alen {
+			return nil, errors.New("wgendpoint: truncated STUN attribute")
+		}
+		v := attrs[4 : 4+alen]
+
+		switch typ {
+		case stunXORMappedAddress:
+			// Prefer XOR-MAPPED-ADDRESS, which survives NATs that rewrite
+			// addresses in payloads.
+			return parseSTUNAddress(v, b[4:20])
+		case stunMappedAddress:
+			addr, err := parseSTUNAddress(v, nil)
+			if err != nil {
+				return nil, err
+			}
+			mapped = addr
+		}
+
+		// Attributes are padded to a multiple of 4 bytes.
+		next := 4 + (alen+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if mapped == nil {
+		return nil, errors.New("wgendpoint: STUN response has no mapped address")
+	}
+
+	return mapped, nil
+}
+
+// parseSTUNAddress parses a (XOR-)MAPPED-ADDRESS attribute value. If xor is
+// not nil, the port and address are XORed with the magic cookie and
+// transaction ID stored in xor.
+func parseSTUNAddress(v, xor []byte) (*net.UDPAddr, error) {
+	if len(v) < 4 {
+		return nil, errors.New("wgendpoint: short STUN address")
+	}
+
+	var ipLen int
+	switch v[1] {
+	case stunFamilyIPv4:
+		ipLen = net.IPv4len
+	case stunFamilyIPv6:
+		ipLen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("wgendpoint: unknown STUN address family: %d", v[1])
+	}
+	if len(v) != 4+ipLen {
+		return nil, errors.New("wgendpoint: invalid STUN address length")
+	}
+
+	port := binary.BigEndian.Uint16(v[2:4])
+	ip := make(net.IP, ipLen)
+	copy(ip, v[4:])
+
+	if xor != nil {
+		port ^= uint16(stunMagicCookie >> 16)
+		for i := range ip {
+			ip[i] ^= xor[i]
+		}
+	}
+
+	return &net.UDPAddr{IP: ip, Port: int(port)}, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package wgendpoint
+
+import (
+	"errors"
+	"syscall"
+)
+
+// reusePort reports that SO_REUSEPORT is not supported on this platform.
+func reusePort(_, _ string, _ syscall.RawConn) error {
+	return errors.New("wgendpoint: SO_REUSEPORT is not supported on this platform")
+}
@@ -0,0 +1,152 @@
+package wgendpoint
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+)
+
+func TestDiscover(t *testing.T) {
+	srv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer srv.Close()
+
+	// Drop the first request to exercise retransmission, then reply to the
+	// second with the client's address.
+	go func() {
+		b := make([]byte, 1500)
+		for i := 0; ; i++ {
+			n, addr, err := srv.ReadFrom(b)
+			if err != nil {
+				return
+			}
+			if i == 0 {
+				continue
+			}
+
+			var txid [12]byte
+			copy(txid[:], b[8:n])
+			_, _ = srv.WriteTo(stunResponse(txid, stunXORMappedAddress, addr.(*net.UDPAddr)), addr)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m, err := Discover(ctx, srv.LocalAddr().String(), 0)
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+
+	want := &Mapping{
+		Addr:      &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1).To4(), Port: m.LocalPort},
+		LocalPort: m.LocalPort,
+	}
+
+	if diff := cmp.Diff(want, m); diff != "" {
+		t.Fatalf("unexpected mapping (-want +got):\n%s", diff)
+	}
+}
+
+func Test_parseSTUNResponse(t *testing.T) {
+	var (
+		txid  = [12]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+		other = [12]byte{12}
+		v4    = wgtest.MustUDPAddr("192.0.2.1:41641")
+		v6    = wgtest.MustUDPAddr("[2001:db8::1]:41641")
+	)
+
+	tests := []struct {
+		name string
+		b    []byte
+		addr *net.UDPAddr
+	}{
+		{
+			name: "short",
+			b:    []byte{0x01, 0x01},
+		},
+		{
+			name: "request",
+			b:    stunRequest(txid),
+		},
+		{
+			name: "transaction mismatch",
+			b:    stunResponse(other, stunXORMappedAddress, v4),
+		},
+		{
+			name: "XOR IPv4",
+			b:    stunResponse(txid, stunXORMappedAddress, v4),
+			addr: v4,
+		},
+		{
+			name: "XOR IPv6",
+			b:    stunResponse(txid, stunXORMappedAddress, v6),
+			addr: v6,
+		},
+		{
+			name: "mapped IPv4",
+			b:    stunResponse(txid, stunMappedAddress, v4),
+			addr: v4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, err := parseSTUNResponse(tt.b, txid)
+			if tt.addr == nil {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+
+			if !addr.IP.Equal(tt.addr.IP) || addr.Port != tt.addr.Port {
+				t.Fatalf("unexpected address: want %s, got %s", tt.addr, addr)
+			}
+		})
+	}
+}
+
+// stunResponse produces a STUN binding success response for txid with a
+// single address attribute of type typ.
+func stunResponse(txid [12]byte, typ uint16, addr *net.UDPAddr) []byte {
+	b := stunRequest(txid)
+	binary.BigEndian.PutUint16(b[0:2], stunBindingSuccess)
+
+	family, ip := byte(stunFamilyIPv4), addr.IP.To4()
+	if ip == nil {
+		family, ip = stunFamilyIPv6, addr.IP.To16()
+	}
+
+	v := make([]byte, 4+len(ip))
+	v[1] = family
+	binary.BigEndian.PutUint16(v[2:4], uint16(addr.Port))
+	copy(v[4:], ip)
+
+	if typ == stunXORMappedAddress {
+		binary.BigEndian.PutUint16(v[2:4], uint16(addr.Port)^uint16(stunMagicCookie>>16))
+		for i := range ip {
+			v[4+i] ^= b[4+i]
+		}
+	}
+
+	attr := make([]byte, 4, 4+len(v))
+	binary.BigEndian.PutUint16(attr[0:2], typ)
+	binary.BigEndian.PutUint16(attr[2:4], uint16(len(v)))
+	attr = append(attr, v...)
+
+	binary.BigEndian.PutUint16(b[2:4], uint16(len(attr)))
+	return append(b, attr...)
+}
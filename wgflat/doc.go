@@ -0,0 +1,12 @@
+// Package wgflat provides flattened representations of WireGuard device
+// configurations and device state for Terraform providers, HCL, and other
+// configuration languages which cannot express binary or pointer fields.
+//
+// The Config, PeerConfig, Device, and Peer types contain only strings,
+// booleans, integers, and lists of strings. Keys are base64 strings,
+// durations are strings such as "25s", and optional numeric settings are
+// strings which are empty when unset, so that a zero value can be
+// distinguished from an unset one. Their fields carry hcl, tfsdk, and json
+// struct tags with snake_case names, and they can be converted to and from
+// the types in package wgtypes.
+package wgflat // import "golang.zx2c4.com/wireguard/wgctrl/wgflat"
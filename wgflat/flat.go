@@ -0,0 +1,300 @@
+package wgflat
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Config is the flattened representation of a wgtypes.Config.
+type Config struct {
+	// PrivateKey is a base64-encoded private key, or empty if unset.
+	PrivateKey string `hcl:"private_key,optional" tfsdk:"private_key" json:"private_key,omitempty"`
+
+	// ListenPort is a decimal port number, or empty if unset.
+	ListenPort string `hcl:"listen_port,optional" tfsdk:"listen_port" json:"listen_port,omitempty"`
+
+	// FirewallMark is a decimal firewall mark, "off" to clear the firewall
+	// mark, or empty if unset.
+	FirewallMark string `hcl:"firewall_mark,optional" tfsdk:"firewall_mark" json:"firewall_mark,omitempty"`
+
+	ReplacePeers bool         `hcl:"replace_peers,optional" tfsdk:"replace_peers" json:"replace_peers,omitempty"`
+	Peers        []PeerConfig `hcl:"peer,block" tfsdk:"peer" json:"peer,omitempty"`
+}
+
+// A PeerConfig is the flattened representation of a wgtypes.PeerConfig.
+type PeerConfig struct {
+	PublicKey    string `hcl:"public_key" tfsdk:"public_key" json:"public_key"`
+	Name         string `hcl:"name,optional" tfsdk:"name" json:"name,omitempty"`
+	Remove       bool   `hcl:"remove,optional" tfsdk:"remove" json:"remove,omitempty"`
+	UpdateOnly   bool   `hcl:"update_only,optional" tfsdk:"update_only" json:"update_only,omitempty"`
+	PresharedKey string `hcl:"preshared_key,optional" tfsdk:"preshared_key" json:"preshared_key,omitempty"`
+
+	// Endpoint is a host and port, such as "vpn.example.com:51820", or empty
+	// if unset. Host names are resolved by ToPeerConfig.
+	Endpoint string `hcl:"endpoint,optional" tfsdk:"endpoint" json:"endpoint,omitempty"`
+
+	// PersistentKeepaliveInterval is a duration such as "25s", "off" to
+	// disable persistent keepalive, or empty if unset.
+	PersistentKeepaliveInterval string `hcl:"persistent_keepalive_interval,optional" tfsdk:"persistent_keepalive_interval" json:"persistent_keepalive_interval,omitempty"`
+
+	ReplaceAllowedIPs bool     `hcl:"replace_allowed_ips,optional" tfsdk:"replace_allowed_ips" json:"replace_allowed_ips,omitempty"`
+	AllowedIPs        []string `hcl:"allowed_ips,optional" tfsdk:"allowed_ips" json:"allowed_ips,omitempty"`
+}
+
+// A Device is the flattened representation of a wgtypes.Device.
+type Device struct {
+	Name         string `hcl:"name" tfsdk:"name" json:"name"`
+	Type         string `hcl:"type,optional" tfsdk:"type" json:"type,omitempty"`
+	PrivateKey   string `hcl:"private_key,optional" tfsdk:"private_key" json:"private_key,omitempty"`
+	PublicKey    string `hcl:"public_key,optional" tfsdk:"public_key" json:"public_key,omitempty"`
+	ListenPort   int64  `hcl:"listen_port,optional" tfsdk:"listen_port" json:"listen_port,omitempty"`
+	FirewallMark int64  `hcl:"firewall_mark,optional" tfsdk:"firewall_mark" json:"firewall_mark,omitempty"`
+	Peers        []Peer `hcl:"peer,block" tfsdk:"peer" json:"peer,omitempty"`
+}
+
+// A Peer is the flattened representation of a wgtypes.Peer.
+type Peer struct {
+	PublicKey                   string `hcl:"public_key" tfsdk:"public_key" json:"public_key"`
+	PresharedKey                string `hcl:"preshared_key,optional" tfsdk:"preshared_key" json:"preshared_key,omitempty"`
+	HasPresharedKey             bool   `hcl:"has_preshared_key,optional" tfsdk:"has_preshared_key" json:"has_preshared_key,omitempty"`
+	Endpoint                    string `hcl:"endpoint,optional" tfsdk:"endpoint" json:"endpoint,omitempty"`
+	PersistentKeepaliveInterval string `hcl:"persistent_keepalive_interval,optional" tfsdk:"persistent_keepalive_interval" json:"persistent_keepalive_interval,omitempty"`
+
+	// LastHandshakeTime is an RFC 3339 timestamp, or empty if no handshake
+	// has occurred.
+	LastHandshakeTime string `hcl:"last_handshake_time,optional" tfsdk:"last_handshake_time" json:"last_handshake_time,omitempty"`
+
+	ReceiveBytes    int64    `hcl:"receive_bytes,optional" tfsdk:"receive_bytes" json:"receive_bytes,omitempty"`
+	TransmitBytes   int64    `hcl:"transmit_bytes,optional" tfsdk:"transmit_bytes" json:"transmit_bytes,omitempty"`
+	AllowedIPs      []string `hcl:"allowed_ips,optional" tfsdk:"allowed_ips" json:"allowed_ips,omitempty"`
+	ProtocolVersion int64    `hcl:"protocol_version,optional" tfsdk:"protocol_version" json:"protocol_version,omitempty"`
+}
+
+// FromConfig returns the flattened representation of cfg.
+func FromConfig(cfg wgtypes.Config) Config {
+	c := Config{ReplacePeers: cfg.ReplacePeers}
+
+	if cfg.PrivateKey != nil {
+		c.PrivateKey = cfg.PrivateKey.String()
+	}
+	if cfg.ListenPort != nil {
+		c.ListenPort = strconv.Itoa(*cfg.ListenPort)
+	}
+	if cfg.FirewallMark != nil {
+		if *cfg.FirewallMark == 0 {
+			c.FirewallMark = "off"
+		} else {
+			c.FirewallMark = strconv.FormatUint(uint64(uint32(*cfg.FirewallMark)), 10)
+		}
+	}
+
+	for _, p := range cfg.Peers {
+		pc := PeerConfig{
+			PublicKey:         p.PublicKey.String(),
+			Name:              p.Name,
+			Remove:            p.Remove,
+			UpdateOnly:        p.UpdateOnly,
+			ReplaceAllowedIPs: p.ReplaceAllowedIPs,
+			AllowedIPs:        formatAllowedIPs(p.AllowedIPs),
+		}
+
+		if p.PresharedKey != nil {
+			pc.PresharedKey = p.PresharedKey.String()
+		}
+		if p.Endpoint != nil {
+			pc.Endpoint = p.Endpoint.String()
+		}
+		if ka := p.PersistentKeepaliveInterval; ka != nil {
+			if *ka == 0 {
+				pc.PersistentKeepaliveInterval = "off"
+			} else {
+				pc.PersistentKeepaliveInterval = ka.String()
+			}
+		}
+
+		c.Peers = append(c.Peers, pc)
+	}
+
+	return c
+}
+
+// ToConfig converts c to a wgtypes.Config, validating each of its fields and
+// resolving the endpoints of its peers.
+func (c Config) ToConfig() (wgtypes.Config, error) {
+	cfg := wgtypes.Config{ReplacePeers: c.ReplacePeers}
+
+	if c.PrivateKey != "" {
+		k, err := wgtypes.ParseKeyStrict(c.PrivateKey)
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("wgflat: invalid private key: %v", err)
+		}
+
+		cfg.PrivateKey = &k
+	}
+
+	if c.ListenPort != "" {
+		port, err := strconv.ParseUint(c.ListenPort, 10, 16)
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("wgflat: invalid listen port: %v", err)
+		}
+
+		p := int(port)
+		cfg.ListenPort = &p
+	}
+
+	switch c.FirewallMark {
+	case "":
+	case "off":
+		fwmark := 0
+		cfg.FirewallMark = &fwmark
+	default:
+		mark, err := strconv.ParseUint(c.FirewallMark, 10, 32)
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("wgflat: invalid firewall mark: %v", err)
+		}
+
+		fwmark := int(mark)
+		cfg.FirewallMark = &fwmark
+	}
+
+	for i, pc := range c.Peers {
+		p, err := pc.ToPeerConfig()
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("wgflat: peer %d: %v", i, err)
+		}
+
+		cfg.Peers = append(cfg.Peers, p)
+	}
+
+	return cfg, nil
+}
+
+// ToPeerConfig converts pc to a wgtypes.PeerConfig, validating each of its
+// fields and resolving its endpoint.
+func (pc PeerConfig) ToPeerConfig() (wgtypes.PeerConfig, error) {
+	if pc.PublicKey == "" {
+		return wgtypes.PeerConfig{}, fmt.Errorf("missing public key")
+	}
+
+	pub, err := wgtypes.ParseKeyStrict(pc.PublicKey)
+	if err != nil {
+		return wgtypes.PeerConfig{}, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	p := wgtypes.PeerConfig{
+		PublicKey:         pub,
+		Name:              pc.Name,
+		Remove:            pc.Remove,
+		UpdateOnly:        pc.UpdateOnly,
+		ReplaceAllowedIPs: pc.ReplaceAllowedIPs,
+	}
+
+	if pc.PresharedKey != "" {
+		k, err := wgtypes.ParseKeyStrict(pc.PresharedKey)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid preshared key: %v", err)
+		}
+
+		p.PresharedKey = &k
+	}
+
+	if pc.Endpoint != "" {
+		addr, err := net.ResolveUDPAddr("udp", pc.Endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid endpoint: %v", err)
+		}
+
+		p.Endpoint = addr
+	}
+
+	switch pc.PersistentKeepaliveInterval {
+	case "":
+	case "off":
+		var d time.Duration
+		p.PersistentKeepaliveInterval = &d
+	default:
+		d, err := time.ParseDuration(pc.PersistentKeepaliveInterval)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid persistent keepalive interval: %v", err)
+		}
+		if d < 0 || d > 65535*time.Second || d%time.Second != 0 {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid persistent keepalive interval: %s", d)
+		}
+
+		p.PersistentKeepaliveInterval = &d
+	}
+
+	for _, s := range pc.AllowedIPs {
+		_, ipn, err := net.ParseCIDR(s)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid allowed IP: %v", err)
+		}
+
+		p.AllowedIPs = append(p.AllowedIPs, *ipn)
+	}
+
+	return p, nil
+}
+
+// FromDevice returns the flattened representation of d. Keys which are not
+// set, such as a private key which was not retrieved, are empty.
+func FromDevice(d *wgtypes.Device) Device {
+	dev := Device{
+		Name:         d.Name,
+		Type:         d.Type.String(),
+		PrivateKey:   keyString(d.PrivateKey),
+		PublicKey:    keyString(d.PublicKey),
+		ListenPort:   int64(d.ListenPort),
+		FirewallMark: int64(uint32(d.FirewallMark)),
+	}
+
+	for _, p := range d.Peers {
+		peer := Peer{
+			PublicKey:       p.PublicKey.String(),
+			PresharedKey:    keyString(p.PresharedKey),
+			HasPresharedKey: p.HasPresharedKey,
+			ReceiveBytes:    p.ReceiveBytes,
+			TransmitBytes:   p.TransmitBytes,
+			AllowedIPs:      formatAllowedIPs(p.AllowedIPs),
+			ProtocolVersion: int64(p.ProtocolVersion),
+		}
+
+		if p.Endpoint != nil {
+			peer.Endpoint = p.Endpoint.String()
+		}
+		if p.PersistentKeepaliveInterval != 0 {
+			peer.PersistentKeepaliveInterval = p.PersistentKeepaliveInterval.String()
+		}
+		if !p.LastHandshakeTime.IsZero() {
+			peer.LastHandshakeTime = p.LastHandshakeTime.UTC().Format(time.RFC3339Nano)
+		}
+
+		dev.Peers = append(dev.Peers, peer)
+	}
+
+	return dev
+}
+
+// formatAllowedIPs formats a list of allowed IPs in CIDR notation.
+func formatAllowedIPs(ipns []net.IPNet) []string {
+	var ss []string
+	for _, ipn := range ipns {
+		ss = append(ss, ipn.String())
+	}
+
+	return ss
+}
+
+// keyString returns the base64 representation of k, or the empty string if k
+// is the zero key.
+func keyString(k wgtypes.Key) string {
+	if k == (wgtypes.Key{}) {
+		return ""
+	}
+
+	return k.String()
+}
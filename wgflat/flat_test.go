@@ -0,0 +1,201 @@
+package wgflat_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgflat"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+		psk  = wgtest.MustPresharedKey()
+		port = 0
+		mark = 0
+		ka   = 25 * time.Second
+		off  time.Duration
+	)
+
+	cfg := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		FirewallMark: &mark,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:                   pub,
+				Name:                        "laptop",
+				PresharedKey:                &psk,
+				Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+				PersistentKeepaliveInterval: &ka,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.2/32"),
+					wgtest.MustCIDR("fd00::/64"),
+				},
+			},
+			{
+				PublicKey:                   wgtest.MustPublicKey(),
+				PersistentKeepaliveInterval: &off,
+			},
+		},
+	}
+
+	c := wgflat.FromConfig(cfg)
+
+	// Zero values must remain distinguishable from unset values.
+	if c.ListenPort != "0" || c.FirewallMark != "off" || c.Peers[1].PersistentKeepaliveInterval != "off" {
+		t.Fatalf("zero values were not preserved: %+v", c)
+	}
+
+	got, err := c.ToConfig()
+	if err != nil {
+		t.Fatalf("failed to convert Config: %v", err)
+	}
+
+	if diff := cmp.Diff(cfg, got); diff != "" {
+		t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+	}
+}
+
+func TestFromDevice(t *testing.T) {
+	pub := wgtest.MustPublicKey()
+
+	d := &wgtypes.Device{
+		Name:         "wg0",
+		Type:         wgtypes.LinuxKernel,
+		PublicKey:    pub,
+		ListenPort:   51820,
+		FirewallMark: 1,
+		Peers: []wgtypes.Peer{{
+			PublicKey:                   pub,
+			HasPresharedKey:             true,
+			Endpoint:                    wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+			PersistentKeepaliveInterval: 25 * time.Second,
+			LastHandshakeTime:           time.Unix(1600000000, 0),
+			ReceiveBytes:                1,
+			TransmitBytes:               2,
+			AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+			ProtocolVersion:             1,
+		}},
+	}
+
+	want := wgflat.Device{
+		Name:         "wg0",
+		Type:         "Linux kernel",
+		PublicKey:    pub.String(),
+		ListenPort:   51820,
+		FirewallMark: 1,
+		Peers: []wgflat.Peer{{
+			PublicKey:                   pub.String(),
+			HasPresharedKey:             true,
+			Endpoint:                    "[2001:db8::1]:51820",
+			PersistentKeepaliveInterval: "25s",
+			LastHandshakeTime:           "2020-09-13T12:26:40Z",
+			ReceiveBytes:                1,
+			TransmitBytes:               2,
+			AllowedIPs:                  []string{"0.0.0.0/0"},
+			ProtocolVersion:             1,
+		}},
+	}
+
+	if diff := cmp.Diff(want, wgflat.FromDevice(d)); diff != "" {
+		t.Fatalf("unexpected Device (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	pub := wgtest.MustPublicKey().String()
+
+	tests := []struct {
+		name string
+		c    wgflat.Config
+	}{
+		{
+			name: "bad private key",
+			c:    wgflat.Config{PrivateKey: "foo"},
+		},
+		{
+			name: "bad listen port",
+			c:    wgflat.Config{ListenPort: "65536"},
+		},
+		{
+			name: "bad firewall mark",
+			c:    wgflat.Config{FirewallMark: "-1"},
+		},
+		{
+			name: "missing public key",
+			c:    wgflat.Config{Peers: []wgflat.PeerConfig{{Remove: true}}},
+		},
+		{
+			name: "bad endpoint",
+			c: wgflat.Config{Peers: []wgflat.PeerConfig{{
+				PublicKey: pub,
+				Endpoint:  "192.0.2.1",
+			}}},
+		},
+		{
+			name: "bad keepalive",
+			c: wgflat.Config{Peers: []wgflat.PeerConfig{{
+				PublicKey:                   pub,
+				PersistentKeepaliveInterval: "1500ms",
+			}}},
+		},
+		{
+			name: "bad allowed IP",
+			c: wgflat.Config{Peers: []wgflat.PeerConfig{{
+				PublicKey:  pub,
+				AllowedIPs: []string{"10.0.0.1"},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.c.ToConfig()
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func TestFieldKinds(t *testing.T) {
+	// Configuration languages must be able to express every field, so no
+	// field may be a pointer, map, or byte array.
+	for _, v := range []interface{}{
+		wgflat.Config{},
+		wgflat.PeerConfig{},
+		wgflat.Device{},
+		wgflat.Peer{},
+	} {
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			for _, tag := range []string{"hcl", "tfsdk", "json"} {
+				if f.Tag.Get(tag) == "" {
+					t.Errorf("%s.%s: missing %s struct tag", typ.Name(), f.Name, tag)
+				}
+			}
+
+			switch k := f.Type.Kind(); k {
+			case reflect.String, reflect.Bool, reflect.Int64:
+			case reflect.Slice:
+				if ek := f.Type.Elem().Kind(); ek != reflect.String && ek != reflect.Struct {
+					t.Errorf("%s.%s: unsupported element kind: %s", typ.Name(), f.Name, ek)
+				}
+			default:
+				t.Errorf("%s.%s: unsupported kind: %s", typ.Name(), f.Name, k)
+			}
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// Package wgflow summarizes the traffic of WireGuard peers as flow records,
+// in the manner of NetFlow and IPFIX, so that tunnel usage can be ingested
+// by network observability pipelines without packet capture.
+//
+// A Summarizer observes devices periodically, and produces a Flow for each
+// peer which sent or received traffic during the interval since its previous
+// observation. Package ipfix encodes Flows as IPFIX messages.
+package wgflow // import "golang.zx2c4.com/wireguard/wgctrl/wgflow"
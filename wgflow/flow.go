@@ -0,0 +1,119 @@
+package wgflow
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Flow summarizes the traffic exchanged with a peer during an interval.
+type Flow struct {
+	// Device and PublicKey identify the peer.
+	Device    string
+	PublicKey wgtypes.Key
+
+	// Endpoint is the peer's endpoint at the end of the interval, if known.
+	Endpoint *net.UDPAddr
+
+	// Start and End bound the interval.
+	Start, End time.Time
+
+	// ReceiveBytes and TransmitBytes are the bytes received from and sent
+	// to the peer during the interval.
+	ReceiveBytes, TransmitBytes int64
+}
+
+// A Summarizer produces Flows from successive observations of devices. The
+// zero value is ready to use, and its methods are safe for concurrent use.
+type Summarizer struct {
+	mu      sync.Mutex
+	tracker wgpeer.StatsTracker
+	last    map[string]time.Time
+}
+
+// Observe records the state of the peers of devices at time now, and returns
+// a Flow for each peer which sent or received traffic since the previous
+// observation of its device. The first observation of a device produces no
+// Flows, because the interval which its counters cover is unknown.
+func (s *Summarizer) Observe(now time.Time, devices []*wgtypes.Device) []Flow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.last == nil {
+		s.last = make(map[string]time.Time)
+	}
+
+	peers := make(map[string]map[wgtypes.Key]*wgtypes.Peer, len(devices))
+	for _, d := range devices {
+		m := make(map[wgtypes.Key]*wgtypes.Peer, len(d.Peers))
+		for i := range d.Peers {
+			m[d.Peers[i].PublicKey] = &d.Peers[i]
+		}
+
+		peers[d.Name] = m
+	}
+
+	var flows []Flow
+	for _, delta := range s.tracker.Observe(devices) {
+		if delta.ReceiveBytes == 0 && delta.TransmitBytes == 0 {
+			continue
+		}
+
+		start, ok := s.last[delta.Device]
+		if !ok {
+			continue
+		}
+
+		flows = append(flows, Flow{
+			Device:        delta.Device,
+			PublicKey:     delta.PublicKey,
+			Endpoint:      peers[delta.Device][delta.PublicKey].Endpoint,
+			Start:         start,
+			End:           now,
+			ReceiveBytes:  delta.ReceiveBytes,
+			TransmitBytes: delta.TransmitBytes,
+		})
+	}
+
+	for name := range s.last {
+		if peers[name] == nil {
+			delete(s.last, name)
+		}
+	}
+	for name := range peers {
+		s.last[name] = now
+	}
+
+	return flows
+}
+
+// Run observes the devices of c every interval until ctx is canceled, and
+// passes the resulting Flows, if any, to export. Run stops if export returns
+// an error.
+func (s *Summarizer) Run(ctx context.Context, c wgpeer.Client, interval time.Duration, export func([]Flow) error) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		devices, err := c.Devices()
+		if err != nil {
+			return err
+		}
+
+		if flows := s.Observe(time.Now(), devices); len(flows) > 0 {
+			if err := export(flows); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
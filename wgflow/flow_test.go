@@ -0,0 +1,105 @@
+package wgflow_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgflow"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSummarizer(t *testing.T) {
+	var (
+		a  = wgtest.MustPublicKey()
+		b  = wgtest.MustPublicKey()
+		ep = wgtest.MustUDPAddr("192.0.2.1:51820")
+		t0 = time.Unix(1600000000, 0)
+	)
+
+	// Order the keys as Observe does.
+	if string(b[:]) < string(a[:]) {
+		a, b = b, a
+	}
+
+	device := func(arx, brx int64) []*wgtypes.Device {
+		return []*wgtypes.Device{{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{
+				{PublicKey: a, Endpoint: ep, ReceiveBytes: arx, TransmitBytes: 2 * arx},
+				{PublicKey: b, ReceiveBytes: brx},
+			},
+		}}
+	}
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		devices []*wgtypes.Device
+		want    []wgflow.Flow
+	}{
+		{
+			name:    "baseline",
+			now:     t0,
+			devices: device(100, 0),
+		},
+		{
+			name:    "traffic",
+			now:     t0.Add(time.Minute),
+			devices: device(150, 10),
+			want: []wgflow.Flow{
+				{
+					Device:        "wg0",
+					PublicKey:     a,
+					Endpoint:      ep,
+					Start:         t0,
+					End:           t0.Add(time.Minute),
+					ReceiveBytes:  50,
+					TransmitBytes: 100,
+				},
+				{
+					Device:       "wg0",
+					PublicKey:    b,
+					Start:        t0,
+					End:          t0.Add(time.Minute),
+					ReceiveBytes: 10,
+				},
+			},
+		},
+		{
+			name:    "idle",
+			now:     t0.Add(2 * time.Minute),
+			devices: device(150, 10),
+		},
+		{
+			name:    "one peer",
+			now:     t0.Add(3 * time.Minute),
+			devices: device(150, 30),
+			want: []wgflow.Flow{{
+				Device:       "wg0",
+				PublicKey:    b,
+				Start:        t0.Add(2 * time.Minute),
+				End:          t0.Add(3 * time.Minute),
+				ReceiveBytes: 20,
+			}},
+		},
+		{
+			name: "removed",
+			now:  t0.Add(4 * time.Minute),
+		},
+		{
+			name:    "recreated",
+			now:     t0.Add(5 * time.Minute),
+			devices: device(5, 0),
+		},
+	}
+
+	var s wgflow.Summarizer
+	for _, tt := range tests {
+		got := s.Observe(tt.now, tt.devices)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Fatalf("%s: unexpected Flows (-want +got):\n%s", tt.name, diff)
+		}
+	}
+}
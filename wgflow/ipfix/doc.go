@@ -0,0 +1,7 @@
+// Package ipfix encodes wgflow.Flows as IPFIX messages (RFC 7011).
+//
+// Each Flow is encoded as a bidirectional flow record (RFC 5103), in which
+// the forward direction is the traffic received from the peer's endpoint.
+// The peer's device and public key are carried by the interfaceName and
+// userName information elements.
+package ipfix // import "golang.zx2c4.com/wireguard/wgctrl/wgflow/ipfix"
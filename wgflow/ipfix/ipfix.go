@@ -0,0 +1,277 @@
+package ipfix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgflow"
+)
+
+// Template IDs for IPv4 and IPv6 endpoints.
+const (
+	TemplateIPv4 = 256
+	TemplateIPv6 = 257
+)
+
+// DefaultMaxMessageSize is the default maximum size of an IPFIX message,
+// which allows a message to be sent in a single UDP datagram on most paths.
+const DefaultMaxMessageSize = 1400
+
+const (
+	version = 10
+
+	headerLen = 16
+	setLen    = 4
+
+	templateSetID = 2
+
+	// variableLength indicates a variable length field in a template.
+	variableLength = 0xffff
+
+	// reversePEN is the private enterprise number of reverse information
+	// elements.
+	reversePEN = 29305
+
+	protocolUDP = 17
+)
+
+// Information elements used in templates.
+const (
+	ieOctetDeltaCount       = 1
+	ieProtocolIdentifier    = 4
+	ieSourceTransportPort   = 7
+	ieSourceIPv4Address     = 8
+	ieSourceIPv6Address     = 27
+	ieInterfaceName         = 82
+	ieFlowStartMilliseconds = 152
+	ieFlowEndMilliseconds   = 153
+	ieUserName              = 371
+)
+
+// A field is a field specifier of a template.
+type field struct {
+	id     uint16
+	length uint16
+	pen    uint32
+}
+
+// templateFields returns the fields of the template for addresses of length
+// addrLen.
+func templateFields(addrLen int) []field {
+	addr := field{id: ieSourceIPv4Address, length: net.IPv4len}
+	if addrLen == net.IPv6len {
+		addr = field{id: ieSourceIPv6Address, length: net.IPv6len}
+	}
+
+	return []field{
+		{id: ieFlowStartMilliseconds, length: 8},
+		{id: ieFlowEndMilliseconds, length: 8},
+		addr,
+		{id: ieSourceTransportPort, length: 2},
+		{id: ieProtocolIdentifier, length: 1},
+		{id: ieOctetDeltaCount, length: 8},
+		{id: ieOctetDeltaCount, length: 8, pen: reversePEN},
+		{id: ieInterfaceName, length: variableLength},
+		{id: ieUserName, length: variableLength},
+	}
+}
+
+// An Encoder writes IPFIX messages to an output stream. Each message is
+// written with a single call to Write, so the output may be a UDP socket.
+type Encoder struct {
+	// DomainID is the observation domain ID of each message.
+	DomainID uint32
+
+	// MaxMessageSize is the maximum size of a message. If zero,
+	// DefaultMaxMessageSize is used.
+	MaxMessageSize int
+
+	// TemplateRefresh is the interval at which templates are sent again.
+	// If zero, templates are sent in every message, as is advisable when
+	// writing to UDP.
+	TemplateRefresh time.Duration
+
+	w io.Writer
+
+	seq      uint32
+	template time.Time
+}
+
+// NewEncoder creates an Encoder which writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes flows as one or more IPFIX messages with export time now.
+func (e *Encoder) Encode(now time.Time, flows []wgflow.Flow) error {
+	max := e.MaxMessageSize
+	if max == 0 {
+		max = DefaultMaxMessageSize
+	}
+
+	templates := e.template.IsZero() || e.TemplateRefresh == 0 ||
+		now.Sub(e.template) >= e.TemplateRefresh
+
+	for len(flows) > 0 || templates {
+		b := make([]byte, headerLen, max)
+		if templates {
+			b = appendTemplates(b)
+			e.template = now
+			templates = false
+		}
+
+		var n int
+		b, n = appendData(b, flows, max)
+		if n == 0 && len(flows) > 0 && len(b) == headerLen {
+			return errors.New("ipfix: flow record exceeds maximum message size")
+		}
+
+		binary.BigEndian.PutUint16(b[0:2], version)
+		binary.BigEndian.PutUint16(b[2:4], uint16(len(b)))
+		binary.BigEndian.PutUint32(b[4:8], uint32(now.Unix()))
+		binary.BigEndian.PutUint32(b[8:12], e.seq)
+		binary.BigEndian.PutUint32(b[12:16], e.DomainID)
+
+		if _, err := e.w.Write(b); err != nil {
+			return fmt.Errorf("ipfix: failed to write message: %v", err)
+		}
+
+		e.seq += uint32(n)
+		flows = flows[n:]
+	}
+
+	return nil
+}
+
+// appendTemplates appends a template set with both templates to b.
+func appendTemplates(b []byte) []byte {
+	start := len(b)
+	b = append(b, make([]byte, setLen)...)
+
+	for _, t := range []struct {
+		id      uint16
+		addrLen int
+	}{
+		{id: TemplateIPv4, addrLen: net.IPv4len},
+		{id: TemplateIPv6, addrLen: net.IPv6len},
+	} {
+		fields := templateFields(t.addrLen)
+
+		b = binary.BigEndian.AppendUint16(b, t.id)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(fields)))
+		for _, f := range fields {
+			id := f.id
+			if f.pen != 0 {
+				id |= 0x8000
+			}
+
+			b = binary.BigEndian.AppendUint16(b, id)
+			b = binary.BigEndian.AppendUint16(b, f.length)
+			if f.pen != 0 {
+				b = binary.BigEndian.AppendUint32(b, f.pen)
+			}
+		}
+	}
+
+	return endSet(b, start, templateSetID)
+}
+
+// appendData appends data sets for as many flows as fit in a message of
+// size max to b, returning the number of flows appended. Consecutive flows
+// with the same template share a set.
+func appendData(b []byte, flows []wgflow.Flow, max int) ([]byte, int) {
+	var (
+		n     int
+		start = -1
+		setID uint16
+	)
+
+	for _, f := range flows {
+		ip, id := address(f.Endpoint)
+		r := appendRecord(nil, f, ip)
+
+		need := len(r)
+		if start == -1 || id != setID {
+			need += setLen
+		}
+		if len(b)+need > max {
+			break
+		}
+
+		if start == -1 || id != setID {
+			if start != -1 {
+				b = endSet(b, start, setID)
+			}
+
+			start = len(b)
+			setID = id
+			b = append(b, make([]byte, setLen)...)
+		}
+
+		b = append(b, r...)
+		n++
+	}
+
+	if start != -1 {
+		b = endSet(b, start, setID)
+	}
+
+	return b, n
+}
+
+// endSet fills in the header of the set with ID id which begins at start.
+func endSet(b []byte, start int, id uint16) []byte {
+	binary.BigEndian.PutUint16(b[start:start+2], id)
+	binary.BigEndian.PutUint16(b[start+2:start+4], uint16(len(b)-start))
+	return b
+}
+
+// address returns the IP address of an endpoint and the ID of the template
+// used to encode it. A missing endpoint is encoded as the unspecified IPv4
+// address.
+func address(ep *net.UDPAddr) (net.IP, uint16) {
+	if ep == nil {
+		return net.IPv4zero.To4(), TemplateIPv4
+	}
+	if ip4 := ep.IP.To4(); ip4 != nil {
+		return ip4, TemplateIPv4
+	}
+
+	return ep.IP.To16(), TemplateIPv6
+}
+
+// appendRecord appends the data record for f, with endpoint address ip, to b.
+func appendRecord(b []byte, f wgflow.Flow, ip net.IP) []byte {
+	var port uint16
+	if f.Endpoint != nil {
+		port = uint16(f.Endpoint.Port)
+	}
+
+	b = binary.BigEndian.AppendUint64(b, uint64(f.Start.UnixMilli()))
+	b = binary.BigEndian.AppendUint64(b, uint64(f.End.UnixMilli()))
+	b = append(b, ip...)
+	b = binary.BigEndian.AppendUint16(b, port)
+	b = append(b, protocolUDP)
+	b = binary.BigEndian.AppendUint64(b, uint64(f.ReceiveBytes))
+	b = binary.BigEndian.AppendUint64(b, uint64(f.TransmitBytes))
+	b = appendString(b, f.Device)
+	b = appendString(b, f.PublicKey.String())
+
+	return b
+}
+
+// appendString appends a variable length string field to b.
+func appendString(b []byte, s string) []byte {
+	if len(s) < 255 {
+		b = append(b, byte(len(s)))
+	} else {
+		b = append(b, 255)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	}
+
+	return append(b, s...)
+}
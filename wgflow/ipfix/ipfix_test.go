@@ -0,0 +1,244 @@
+package ipfix_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgflow"
+	"golang.zx2c4.com/wireguard/wgctrl/wgflow/ipfix"
+)
+
+// A message is a decoded IPFIX message.
+type message struct {
+	Seq, Domain uint32
+	Sets        []uint16
+	Records     []record
+}
+
+// A record is a decoded data record.
+type record struct {
+	Template     uint16
+	Start, End   uint64
+	IP           string
+	Port         uint16
+	RX, TX       uint64
+	Device, Peer string
+}
+
+// messageWriter decodes each message written to it.
+type messageWriter struct {
+	t    *testing.T
+	msgs []message
+}
+
+func (w *messageWriter) Write(b []byte) (int, error) {
+	w.t.Helper()
+
+	if v := binary.BigEndian.Uint16(b[0:2]); v != 10 {
+		w.t.Fatalf("unexpected version: %d", v)
+	}
+	if n := int(binary.BigEndian.Uint16(b[2:4])); n != len(b) {
+		w.t.Fatalf("unexpected message length: %d, want %d", n, len(b))
+	}
+
+	m := message{
+		Seq:    binary.BigEndian.Uint32(b[8:12]),
+		Domain: binary.BigEndian.Uint32(b[12:16]),
+	}
+
+	for s := b[16:]; len(s) > 0; {
+		id := binary.BigEndian.Uint16(s[0:2])
+		n := int(binary.BigEndian.Uint16(s[2:4]))
+		m.Sets = append(m.Sets, id)
+
+		if id >= 256 {
+			m.Records = append(m.Records, decodeRecords(id, s[4:n])...)
+		}
+
+		s = s[n:]
+	}
+
+	w.msgs = append(w.msgs, m)
+	return len(b), nil
+}
+
+func decodeRecords(id uint16, b []byte) []record {
+	str := func() string {
+		n := int(b[0])
+		s := string(b[1 : 1+n])
+		b = b[1+n:]
+		return s
+	}
+
+	ipLen := net.IPv4len
+	if id == ipfix.TemplateIPv6 {
+		ipLen = net.IPv6len
+	}
+
+	var rs []record
+	for len(b) > 0 {
+		r := record{
+			Template: id,
+			Start:    binary.BigEndian.Uint64(b[0:8]),
+			End:      binary.BigEndian.Uint64(b[8:16]),
+			IP:       net.IP(b[16 : 16+ipLen]).String(),
+		}
+		b = b[16+ipLen:]
+
+		r.Port = binary.BigEndian.Uint16(b[0:2])
+		// Skip the protocol.
+		r.RX = binary.BigEndian.Uint64(b[3:11])
+		r.TX = binary.BigEndian.Uint64(b[11:19])
+		b = b[19:]
+
+		r.Device = str()
+		r.Peer = str()
+		rs = append(rs, r)
+	}
+
+	return rs
+}
+
+func TestEncoder(t *testing.T) {
+	var (
+		a  = wgtest.MustPublicKey()
+		b  = wgtest.MustPublicKey()
+		t0 = time.Unix(1600000000, 0)
+		t1 = t0.Add(time.Minute)
+	)
+
+	flows := []wgflow.Flow{
+		{
+			Device:        "wg0",
+			PublicKey:     a,
+			Endpoint:      wgtest.MustUDPAddr("192.0.2.1:51820"),
+			Start:         t0,
+			End:           t1,
+			ReceiveBytes:  100,
+			TransmitBytes: 200,
+		},
+		{
+			Device:       "wg0",
+			PublicKey:    b,
+			Endpoint:     wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+			Start:        t0,
+			End:          t1,
+			ReceiveBytes: 1 << 40,
+		},
+		{
+			Device:        "wg1",
+			PublicKey:     a,
+			Start:         t0,
+			End:           t1,
+			TransmitBytes: 1,
+		},
+	}
+
+	w := &messageWriter{t: t}
+	e := ipfix.NewEncoder(w)
+	e.DomainID = 7
+	e.TemplateRefresh = time.Hour
+
+	if err := e.Encode(t1, flows); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	// The templates were sent recently, so only data is sent.
+	if err := e.Encode(t1.Add(time.Minute), flows[:1]); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	start, end := uint64(t0.UnixMilli()), uint64(t1.UnixMilli())
+	want := []message{
+		{
+			Domain: 7,
+			Sets:   []uint16{2, ipfix.TemplateIPv4, ipfix.TemplateIPv6, ipfix.TemplateIPv4},
+			Records: []record{
+				{
+					Template: ipfix.TemplateIPv4, Start: start, End: end,
+					IP: "192.0.2.1", Port: 51820, RX: 100, TX: 200,
+					Device: "wg0", Peer: a.String(),
+				},
+				{
+					Template: ipfix.TemplateIPv6, Start: start, End: end,
+					IP: "2001:db8::1", Port: 51820, RX: 1 << 40,
+					Device: "wg0", Peer: b.String(),
+				},
+				{
+					Template: ipfix.TemplateIPv4, Start: start, End: end,
+					IP: "0.0.0.0", TX: 1,
+					Device: "wg1", Peer: a.String(),
+				},
+			},
+		},
+		{
+			Seq:    3,
+			Domain: 7,
+			Sets:   []uint16{ipfix.TemplateIPv4},
+			Records: []record{{
+				Template: ipfix.TemplateIPv4, Start: start, End: end,
+				IP: "192.0.2.1", Port: 51820, RX: 100, TX: 200,
+				Device: "wg0", Peer: a.String(),
+			}},
+		},
+	}
+
+	if diff := cmp.Diff(want, w.msgs); diff != "" {
+		t.Fatalf("unexpected messages (-want +got):\n%s", diff)
+	}
+}
+
+func TestEncoderMaxMessageSize(t *testing.T) {
+	flow := wgflow.Flow{
+		Device:    "wg0",
+		PublicKey: wgtest.MustPublicKey(),
+		Endpoint:  wgtest.MustUDPAddr("192.0.2.1:51820"),
+	}
+
+	flows := make([]wgflow.Flow, 100)
+	for i := range flows {
+		flows[i] = flow
+	}
+
+	w := &messageWriter{t: t}
+	e := ipfix.NewEncoder(w)
+	e.MaxMessageSize = 512
+
+	if err := e.Encode(time.Now(), flows); err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	var n int
+	for i, m := range w.msgs {
+		if m.Seq != uint32(n) {
+			t.Fatalf("message %d: unexpected sequence number: %d, want %d", i, m.Seq, n)
+		}
+
+		n += len(m.Records)
+	}
+
+	if n != len(flows) {
+		t.Fatalf("unexpected number of records: %d, want %d", n, len(flows))
+	}
+	if len(w.msgs) < 2 {
+		t.Fatalf("expected flows to be split across messages, but got %d", len(w.msgs))
+	}
+}
+
+func TestEncoderRecordTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	e := ipfix.NewEncoder(&buf)
+	e.MaxMessageSize = 64
+
+	err := e.Encode(time.Now(), []wgflow.Flow{{Device: "wg0"}})
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	t.Logf("OK error: %v", err)
+}
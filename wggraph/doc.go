@@ -0,0 +1,8 @@
+// Package wggraph produces graph descriptions of WireGuard networks in the
+// GraphViz DOT and Mermaid formats.
+//
+// Each device and peer is a node identified by its public key, so that
+// Device snapshots gathered from multiple hosts are joined into a single graph
+// where a peer on one host is the device on another. Each tunnel is an edge
+// annotated with its transfer counters and most recent handshake.
+package wggraph // import "golang.zx2c4.com/wireguard/wgctrl/wggraph"
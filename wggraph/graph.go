@@ -0,0 +1,174 @@
+package wggraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// timeNow is the current time source, which can be swapped out in tests.
+var timeNow = time.Now
+
+// WriteDOT writes a GraphViz DOT description of devices to w.
+func WriteDOT(w io.Writer, devices []*wgtypes.Device) error {
+	g := newGraph(devices, timeNow())
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "graph wireguard {")
+	for _, n := range g.nodes {
+		fmt.Fprintf(bw, "\t%s [label=%s];\n", n.id, dotQuote(n.label))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "\t%s -- %s [label=%s];\n", e.a, e.b, dotQuote(e.label))
+	}
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+// WriteMermaid writes a Mermaid flowchart description of devices to w.
+func WriteMermaid(w io.Writer, devices []*wgtypes.Device) error {
+	g := newGraph(devices, timeNow())
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "graph LR")
+	for _, n := range g.nodes {
+		fmt.Fprintf(bw, "\t%s[%s]\n", n.id, mermaidQuote(n.label))
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(bw, "\t%s ---|%s| %s\n", e.a, mermaidQuote(e.label), e.b)
+	}
+
+	return bw.Flush()
+}
+
+// A graph is an undirected graph of nodes and edges in a stable order.
+type graph struct {
+	nodes []node
+	edges []edge
+
+	ids   map[wgtypes.Key]string
+	pairs map[[2]string]bool
+}
+
+// A node is a device or peer. Each line of label is a separate line of text.
+type node struct {
+	id    string
+	label []string
+}
+
+// An edge is a tunnel between two nodes.
+type edge struct {
+	a, b  string
+	label []string
+}
+
+// newGraph builds a graph from devices, computing relative times using now.
+func newGraph(devices []*wgtypes.Device, now time.Time) *graph {
+	g := &graph{
+		ids:   make(map[wgtypes.Key]string),
+		pairs: make(map[[2]string]bool),
+	}
+
+	// Add all devices first so that peers which are devices in other
+	// snapshots are labeled as devices.
+	for _, d := range devices {
+		g.node(d.PublicKey, []string{d.Name, shortKey(d.PublicKey)})
+	}
+
+	for _, d := range devices {
+		a := g.ids[d.PublicKey]
+		for _, p := range d.Peers {
+			label := []string{shortKey(p.PublicKey)}
+			if p.Endpoint != nil {
+				label = append(label, p.Endpoint.String())
+			}
+
+			b := g.node(p.PublicKey, label)
+
+			// Both ends of a tunnel may be present when snapshots from
+			// multiple hosts are used, so only keep the first.
+			pair := [2]string{a, b}
+			if b < a {
+				pair = [2]string{b, a}
+			}
+			if g.pairs[pair] {
+				continue
+			}
+			g.pairs[pair] = true
+
+			g.edges = append(g.edges, edge{
+				a:     a,
+				b:     b,
+				label: tunnelLabel(p, now),
+			})
+		}
+	}
+
+	return g
+}
+
+// node adds a node for key with label if one does not already exist, and
+// returns its ID.
+func (g *graph) node(key wgtypes.Key, label []string) string {
+	if id, ok := g.ids[key]; ok {
+		return id
+	}
+
+	id := fmt.Sprintf("n%d", len(g.nodes))
+	g.ids[key] = id
+	g.nodes = append(g.nodes, node{id: id, label: label})
+
+	return id
+}
+
+// tunnelLabel produces the annotations for the tunnel to p.
+func tunnelLabel(p wgtypes.Peer, now time.Time) []string {
+	label := []string{fmt.Sprintf("rx %s, tx %s",
+		prettyBytes(p.ReceiveBytes), prettyBytes(p.TransmitBytes))}
+
+	if p.LastHandshakeTime.IsZero() {
+		return append(label, "no handshake")
+	}
+
+	ago := now.Sub(p.LastHandshakeTime).Round(time.Second)
+	return append(label, fmt.Sprintf("handshake %s ago", ago))
+}
+
+// shortKey returns an abbreviated form of k for display.
+func shortKey(k wgtypes.Key) string {
+	return k.String()[:8] + "…"
+}
+
+// prettyBytes produces a human-readable byte count string using binary
+// prefixes.
+func prettyBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit && exp < 3; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.2f %ciB", float64(b)/float64(div), "KMGT"[exp])
+}
+
+// dotQuote produces a quoted DOT string from lines.
+func dotQuote(lines []string) string {
+	// Lines are separated by DOT's \n escape sequence.
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(strings.Join(lines, "\n")) + `"`
+}
+
+// mermaidQuote produces a quoted Mermaid string from lines.
+func mermaidQuote(lines []string) string {
+	return `"` + strings.ReplaceAll(strings.Join(lines, "<br/>"), `"`, "#quot;") + `"`
+}
@@ -0,0 +1,90 @@
+package wggraph
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	testNow = time.Unix(1600000000, 0)
+
+	testKeyA = wgtest.MustHexKey("b85996fecc9c7f1fc6d2572a76eda11d59bcd20be8e543b15ce4bd85a8e75a33")
+	testKeyB = wgtest.MustHexKey("58402e695ba1772b1cc9309755f043251ea77fdcf10fbe63989ceb7e19321376")
+	testKeyC = wgtest.MustHexKey("662e14fd594556f522604703340351258903b64f35553763f19426ab2a515c58")
+
+	// Snapshots from two hosts which both include the tunnel between A and B.
+	testDevices = []*wgtypes.Device{
+		{
+			Name:      "wg0",
+			PublicKey: testKeyA,
+			Peers: []wgtypes.Peer{
+				{
+					PublicKey:         testKeyB,
+					Endpoint:          wgtest.MustUDPAddr("192.0.2.2:51820"),
+					ReceiveBytes:      1536,
+					TransmitBytes:     3 * 1024 * 1024,
+					LastHandshakeTime: testNow.Add(-65 * time.Second),
+				},
+				{PublicKey: testKeyC},
+			},
+		},
+		{
+			Name:      "wg1",
+			PublicKey: testKeyB,
+			Peers:     []wgtypes.Peer{{PublicKey: testKeyA}},
+		},
+	}
+)
+
+func TestWrite(t *testing.T) {
+	timeNow = func() time.Time { return testNow }
+	defer func() { timeNow = time.Now }()
+
+	tests := []struct {
+		name string
+		fn   func(b *bytes.Buffer) error
+		want string
+	}{
+		{
+			name: "DOT",
+			fn:   func(b *bytes.Buffer) error { return WriteDOT(b, testDevices) },
+			want: `graph wireguard {
+	n0 [label="wg0\nuFmW/syc…"];
+	n1 [label="wg1\nWEAuaVuh…"];
+	n2 [label="Zi4U/VlF…"];
+	n0 -- n1 [label="rx 1.50 KiB, tx 3.00 MiB\nhandshake 1m5s ago"];
+	n0 -- n2 [label="rx 0 B, tx 0 B\nno handshake"];
+}
+`,
+		},
+		{
+			name: "Mermaid",
+			fn:   func(b *bytes.Buffer) error { return WriteMermaid(b, testDevices) },
+			want: `graph LR
+	n0["wg0<br/>uFmW/syc…"]
+	n1["wg1<br/>WEAuaVuh…"]
+	n2["Zi4U/VlF…"]
+	n0 ---|"rx 1.50 KiB, tx 3.00 MiB<br/>handshake 1m5s ago"| n1
+	n0 ---|"rx 0 B, tx 0 B<br/>no handshake"| n2
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.fn(&b); err != nil {
+				t.Fatalf("failed to write graph: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, b.String()); diff != "" {
+				t.Fatalf("unexpected graph (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+// Package wghandshake reports completed WireGuard handshakes as they occur.
+//
+// A Watcher polls devices for changes to each peer's LastHandshakeTime. On
+// Linux, when built with the wgctrl_kprobe build tag and run with
+// CAP_SYS_ADMIN, it also attaches a kprobe to the kernel module's session
+// setup through tracefs, and polls immediately whenever a handshake
+// completes, so that handshakes are reported in near-real-time rather than
+// after the next polling interval. If kernel tracing is unavailable, the
+// Watcher silently falls back to polling.
+package wghandshake // import "golang.zx2c4.com/wireguard/wgctrl/wghandshake"
@@ -0,0 +1,153 @@
+package wghandshake
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// DefaultInterval is the default interval between polls of devices.
+const DefaultInterval = 5 * time.Second
+
+// An Event reports that a peer completed a handshake.
+type Event struct {
+	Device    string
+	PublicKey wgtypes.Key
+	Time      time.Time
+}
+
+// A Client retrieves WireGuard devices. It is implemented by *wgctrl.Client.
+type Client interface {
+	Devices() ([]*wgtypes.Device, error)
+}
+
+// A Watcher reports each handshake completed by the peers of a Client's
+// devices.
+type Watcher struct {
+	// Interval is the interval between polls of devices. When kernel
+	// tracing is in use, polls are also triggered by each handshake. If
+	// zero, DefaultInterval is used.
+	Interval time.Duration
+
+	// Poll disables kernel tracing, even where it is available.
+	Poll bool
+
+	// OnHandshake is called for each handshake, in the order in which they
+	// were observed. It is called synchronously from Run.
+	OnHandshake func(Event)
+
+	mu     sync.Mutex
+	traced bool
+	last   map[peerKey]time.Time
+}
+
+// A peerKey identifies a peer on a device.
+type peerKey struct {
+	device string
+	peer   wgtypes.Key
+}
+
+// A trigger signals that a handshake may have completed.
+type trigger interface {
+	C() <-chan struct{}
+	Close() error
+}
+
+// Run polls the devices of c and reports handshakes until ctx is canceled
+// or retrieving devices fails. Handshakes which completed before Run was
+// called are not reported.
+func (w *Watcher) Run(ctx context.Context, c Client) error {
+	interval := w.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	var traced <-chan struct{}
+	if !w.Poll {
+		if tr, err := openTrigger(); err == nil {
+			defer tr.Close()
+			traced = tr.C()
+		}
+	}
+
+	w.mu.Lock()
+	w.traced = traced != nil
+	w.mu.Unlock()
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := w.poll(c); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		case <-traced:
+		}
+	}
+}
+
+// Traced reports whether a running Watcher is using kernel tracing.
+func (w *Watcher) Traced() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.traced
+}
+
+// poll retrieves devices and reports the handshakes since the previous poll.
+func (w *Watcher) poll(c Client) error {
+	devices, err := c.Devices()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	events := w.observe(devices)
+	w.mu.Unlock()
+
+	if w.OnHandshake == nil {
+		return nil
+	}
+
+	for _, e := range events {
+		w.OnHandshake(e)
+	}
+
+	return nil
+}
+
+// observe records the last handshake of each peer of devices, and returns
+// an Event for each which advanced. The first observation of the Watcher
+// produces no Events.
+func (w *Watcher) observe(devices []*wgtypes.Device) []Event {
+	baseline := w.last == nil
+
+	last := make(map[peerKey]time.Time)
+	var events []Event
+	for _, d := range devices {
+		for _, p := range d.Peers {
+			k := peerKey{device: d.Name, peer: p.PublicKey}
+			last[k] = p.LastHandshakeTime
+
+			if baseline || p.LastHandshakeTime.IsZero() || !p.LastHandshakeTime.After(w.last[k]) {
+				continue
+			}
+
+			events = append(events, Event{
+				Device:    d.Name,
+				PublicKey: p.PublicKey,
+				Time:      p.LastHandshakeTime,
+			})
+		}
+	}
+
+	w.last = last
+	return events
+}
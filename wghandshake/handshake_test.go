@@ -0,0 +1,89 @@
+package wghandshake_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wghandshake"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestWatcherRun(t *testing.T) {
+	var (
+		a  = wgtest.MustPublicKey()
+		b  = wgtest.MustPublicKey()
+		t0 = time.Unix(1600000000, 0)
+	)
+
+	// Each poll returns the next set of handshake times for peers a and b,
+	// and the final poll fails to stop the Watcher.
+	polls := [][2]time.Time{
+		{t0, {}},
+		{t0, {}},
+		{t0.Add(time.Minute), t0.Add(time.Minute)},
+		{t0.Add(time.Minute), t0.Add(2 * time.Minute)},
+	}
+
+	errDone := errors.New("done")
+	c := &testClient{
+		DevicesFunc: func() ([]*wgtypes.Device, error) {
+			if len(polls) == 0 {
+				return nil, errDone
+			}
+
+			hs := polls[0]
+			polls = polls[1:]
+
+			return []*wgtypes.Device{{
+				Name: "wg0",
+				Peers: []wgtypes.Peer{
+					{PublicKey: a, LastHandshakeTime: hs[0]},
+					{PublicKey: b, LastHandshakeTime: hs[1]},
+				},
+			}}, nil
+		},
+	}
+
+	var (
+		mu  sync.Mutex
+		got []wghandshake.Event
+	)
+
+	w := &wghandshake.Watcher{
+		Interval: time.Millisecond,
+		Poll:     true,
+		OnHandshake: func(e wghandshake.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, e)
+		},
+	}
+
+	if err := w.Run(context.Background(), c); !errors.Is(err, errDone) {
+		t.Fatalf("unexpected Run error: %v", err)
+	}
+	if w.Traced() {
+		t.Fatal("expected polling only")
+	}
+
+	want := []wghandshake.Event{
+		{Device: "wg0", PublicKey: a, Time: t0.Add(time.Minute)},
+		{Device: "wg0", PublicKey: b, Time: t0.Add(time.Minute)},
+		{Device: "wg0", PublicKey: b, Time: t0.Add(2 * time.Minute)},
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected Events (-want +got):\n%s", diff)
+	}
+}
+
+type testClient struct {
+	DevicesFunc func() ([]*wgtypes.Device, error)
+}
+
+func (c *testClient) Devices() ([]*wgtypes.Device, error) { return c.DevicesFunc() }
@@ -0,0 +1,187 @@
+//go:build linux && wgctrl_kprobe
+// +build linux,wgctrl_kprobe
+
+package wghandshake
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// kprobeSymbol is called by the kernel module when a handshake completes and
+// a new session begins, on both the initiator and the responder.
+const kprobeSymbol = "wg_noise_handshake_begin_session"
+
+// tracefsDirs are the possible mount points of tracefs.
+var tracefsDirs = []string{"/sys/kernel/tracing", "/sys/kernel/debug/tracing"}
+
+// A kprobeTrigger is a trigger which reads kprobe hits from a private tracefs
+// instance, so that it does not interfere with other users of the global
+// trace buffer.
+type kprobeTrigger struct {
+	root, group, instance string
+
+	pipe *os.File
+	c    chan struct{}
+	done chan struct{}
+}
+
+// openTrigger attaches a kprobe to the kernel module's session setup.
+func openTrigger() (trigger, error) {
+	if err := checkCapability(); err != nil {
+		return nil, err
+	}
+
+	root, err := tracefs()
+	if err != nil {
+		return nil, err
+	}
+
+	group := fmt.Sprintf("wgctrl_%d", os.Getpid())
+	t := &kprobeTrigger{
+		root:     root,
+		group:    group,
+		instance: filepath.Join(root, "instances", group),
+		c:        make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	if err := t.attach(); err != nil {
+		_ = t.detach()
+		return nil, err
+	}
+
+	go t.read()
+	return t, nil
+}
+
+// C implements trigger.
+func (t *kprobeTrigger) C() <-chan struct{} { return t.c }
+
+// Close implements trigger.
+func (t *kprobeTrigger) Close() error {
+	// Closing the pipe interrupts read.
+	err := t.pipe.Close()
+	<-t.done
+
+	if derr := t.detach(); err == nil {
+		err = derr
+	}
+
+	return err
+}
+
+// attach creates and enables the kprobe event in a new tracefs instance, and
+// opens the instance's trace pipe.
+func (t *kprobeTrigger) attach() error {
+	def := fmt.Sprintf("p:%s/handshake %s\n", t.group, kprobeSymbol)
+	if err := appendFile(filepath.Join(t.root, "kprobe_events"), def); err != nil {
+		return fmt.Errorf("wghandshake: failed to create kprobe: %v", err)
+	}
+
+	if err := os.Mkdir(t.instance, 0o755); err != nil {
+		return fmt.Errorf("wghandshake: failed to create tracefs instance: %v", err)
+	}
+
+	enable := filepath.Join(t.instance, "events", t.group, "handshake", "enable")
+	if err := os.WriteFile(enable, []byte("1\n"), 0); err != nil {
+		return fmt.Errorf("wghandshake: failed to enable kprobe: %v", err)
+	}
+
+	// Open the pipe in non-blocking mode so that reads use the runtime's
+	// poller, and can be interrupted by Close.
+	fd, err := unix.Open(filepath.Join(t.instance, "trace_pipe"), unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("wghandshake: failed to open trace pipe: %v", err)
+	}
+
+	t.pipe = os.NewFile(uintptr(fd), "trace_pipe")
+	return nil
+}
+
+// detach removes whatever attach created, ignoring anything which does not
+// exist.
+func (t *kprobeTrigger) detach() error {
+	var errs []error
+	if err := os.Remove(t.instance); err != nil && !errors.Is(err, os.ErrNotExist) {
+		errs = append(errs, err)
+	}
+
+	undef := fmt.Sprintf("-:%s/handshake\n", t.group)
+	if err := appendFile(filepath.Join(t.root, "kprobe_events"), undef); err != nil && !errors.Is(err, unix.ENOENT) {
+		errs = append(errs, err)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("wghandshake: failed to remove kprobe: %w", err)
+	}
+
+	return nil
+}
+
+// read signals C for each kprobe hit until the pipe is closed. Hits which
+// occur while a signal is pending are coalesced.
+func (t *kprobeTrigger) read() {
+	defer close(t.done)
+
+	s := bufio.NewScanner(t.pipe)
+	for s.Scan() {
+		select {
+		case t.c <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// checkCapability verifies that the process may use tracefs.
+func checkCapability() error {
+	hdr := unix.CapUserHeader{Version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]unix.CapUserData
+	if err := unix.Capget(&hdr, &data[0]); err != nil {
+		return fmt.Errorf("wghandshake: failed to get capabilities: %v", err)
+	}
+
+	if data[unix.CAP_SYS_ADMIN/32].Effective&(1<<(unix.CAP_SYS_ADMIN%32)) == 0 {
+		return errors.New("wghandshake: kernel tracing requires CAP_SYS_ADMIN")
+	}
+
+	return nil
+}
+
+// tracefs returns the mount point of tracefs.
+func tracefs() (string, error) {
+	for _, dir := range tracefsDirs {
+		var st unix.Statfs_t
+		if err := unix.Statfs(dir, &st); err != nil {
+			continue
+		}
+
+		if st.Type == unix.TRACEFS_MAGIC {
+			return dir, nil
+		}
+	}
+
+	return "", fmt.Errorf("wghandshake: tracefs is not mounted at %s", strings.Join(tracefsDirs, " or "))
+}
+
+// appendFile appends s to the file at path, as required to add to and remove
+// from the kprobe_events list.
+func appendFile(path, s string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.WriteString(s); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,11 @@
+//go:build !linux || !wgctrl_kprobe
+// +build !linux !wgctrl_kprobe
+
+package wghandshake
+
+import "errors"
+
+// openTrigger reports that kernel tracing is not supported.
+func openTrigger() (trigger, error) {
+	return nil, errors.New("wghandshake: kernel tracing is not supported in this build")
+}
@@ -0,0 +1,9 @@
+// Package wgipam allocates addresses to new peers from a device's subnet.
+//
+// An Allocator hands out the lowest free /32 or /128 within a subnet. An
+// address is free if it is not covered by the AllowedIPs of any peer of the
+// device, and is not reserved in a Store. Each allocation reserves the
+// address in the Store before it is returned, and the Store's Reserve
+// operation is atomic, so that concurrent allocations never hand out the same
+// address, even from different processes sharing a DirStore.
+package wgipam // import "golang.zx2c4.com/wireguard/wgctrl/wgipam"
@@ -0,0 +1,186 @@
+package wgipam
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ErrExhausted is returned by Allocator.Allocate when no free addresses
+// remain in the subnet.
+var ErrExhausted = errors.New("wgipam: no free addresses remain in subnet")
+
+// An Allocator allocates addresses to peers from a subnet. Its methods are
+// safe for concurrent use if its Store is.
+type Allocator struct {
+	prefix netip.Prefix
+	store  Store
+}
+
+// New creates an Allocator which allocates addresses from subnet, recording
+// reservations in store. The addresses of the device itself, and any others
+// which must not be allocated, should be reserved in store beforehand.
+//
+// The first address of the subnet is never allocated, nor is the last
+// address of an IPv4 subnet, since they are the network and broadcast
+// addresses; except in /31 and /32 subnets, which have none.
+func New(subnet net.IPNet, store Store) (*Allocator, error) {
+	addr, ok := netip.AddrFromSlice(subnet.IP)
+	if !ok {
+		return nil, fmt.Errorf("wgipam: invalid subnet: %s", subnet.String())
+	}
+
+	ones, bits := subnet.Mask.Size()
+	addr = addr.Unmap()
+	if bits == 0 || bits != addr.BitLen() {
+		return nil, fmt.Errorf("wgipam: invalid subnet: %s", subnet.String())
+	}
+
+	return &Allocator{
+		prefix: netip.PrefixFrom(addr, ones).Masked(),
+		store:  store,
+	}, nil
+}
+
+// Allocate reserves a free address for peer and returns it as a /32 or /128
+// suitable for the peer's AllowedIPs. Addresses covered by the AllowedIPs of
+// the peers of d are in use, unless they are only covered by prefixes which
+// are shorter than the subnet, such as a default route. d may be nil.
+//
+// If an address is already reserved for peer, or peer is a peer of d with an
+// address in the subnet, that address is returned again, so that a failed
+// workflow may be retried without leaking addresses. If no addresses are
+// free, Allocate returns ErrExhausted.
+func (a *Allocator) Allocate(d *wgtypes.Device, peer wgtypes.Key) (net.IPNet, error) {
+	used, err := a.store.Reservations()
+	if err != nil {
+		return net.IPNet{}, fmt.Errorf("wgipam: failed to list reservations: %w", err)
+	}
+
+	for addr, p := range used {
+		if p == peer && a.prefix.Contains(addr) {
+			return a.ipNet(addr), nil
+		}
+	}
+
+	// Peers may route prefixes within the subnet, which are skipped as a
+	// whole.
+	var routed []netip.Prefix
+	if d != nil {
+		for _, p := range d.Peers {
+			for _, ipn := range p.AllowedIPs {
+				rp, ok := a.within(ipn)
+				if !ok {
+					continue
+				}
+
+				if p.PublicKey == peer && rp.IsSingleIP() {
+					return a.ipNet(rp.Addr()), nil
+				}
+
+				routed = append(routed, rp)
+			}
+		}
+	}
+
+	first, last := a.bounds()
+	for addr := first; addr.IsValid() && addr.Compare(last) <= 0; {
+		if next, ok := skipRouted(addr, routed); ok {
+			addr = next
+			continue
+		}
+
+		if _, ok := used[addr]; ok {
+			addr = addr.Next()
+			continue
+		}
+
+		switch err := a.store.Reserve(addr, peer); {
+		case err == nil:
+			return a.ipNet(addr), nil
+		case errors.Is(err, ErrReserved):
+			// Reserved concurrently, try the next address.
+			addr = addr.Next()
+		default:
+			return net.IPNet{}, fmt.Errorf("wgipam: failed to reserve %s: %w", addr, err)
+		}
+	}
+
+	return net.IPNet{}, ErrExhausted
+}
+
+// Release releases the reservation of the address in ipn, such as when its
+// peer is removed.
+func (a *Allocator) Release(ipn net.IPNet) error {
+	addr, ok := netip.AddrFromSlice(ipn.IP)
+	if !ok {
+		return fmt.Errorf("wgipam: invalid address: %s", ipn.String())
+	}
+
+	return a.store.Release(addr.Unmap())
+}
+
+// bounds returns the first and last addresses which may be allocated.
+func (a *Allocator) bounds() (first, last netip.Addr) {
+	first = a.prefix.Addr()
+	last = lastAddr(a.prefix)
+
+	hostBits := first.BitLen() - a.prefix.Bits()
+	if hostBits <= 1 {
+		return first, last
+	}
+
+	first = first.Next()
+	if first.Is4() {
+		last = last.Prev()
+	}
+
+	return first, last
+}
+
+// within converts ipn to a prefix, and reports whether it lies within the
+// subnet and is at least as long.
+func (a *Allocator) within(ipn net.IPNet) (netip.Prefix, bool) {
+	addr, ok := netip.AddrFromSlice(ipn.IP)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+
+	ones, _ := ipn.Mask.Size()
+	p := netip.PrefixFrom(addr.Unmap(), ones).Masked()
+	return p, p.IsValid() && p.Bits() >= a.prefix.Bits() && a.prefix.Contains(p.Addr())
+}
+
+// ipNet returns addr as a single-address net.IPNet.
+func (a *Allocator) ipNet(addr netip.Addr) net.IPNet {
+	return net.IPNet{
+		IP:   net.IP(addr.AsSlice()),
+		Mask: net.CIDRMask(addr.BitLen(), addr.BitLen()),
+	}
+}
+
+// skipRouted returns the address after the last prefix in routed which
+// contains addr, and reports whether any did.
+func skipRouted(addr netip.Addr, routed []netip.Prefix) (netip.Addr, bool) {
+	for _, p := range routed {
+		if p.Contains(addr) {
+			return lastAddr(p).Next(), true
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// lastAddr returns the last address in p.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 0x80 >> (i % 8)
+	}
+
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,154 @@
+package wgipam_test
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgipam"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestAllocatorAllocate(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+		c = wgtest.MustPublicKey()
+	)
+
+	d := &wgtypes.Device{
+		Peers: []wgtypes.Peer{
+			{
+				PublicKey: a,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.2/32"),
+					// Routed subnet within the device's subnet.
+					wgtest.MustCIDR("10.0.0.4/30"),
+					// Routes which are broader than the subnet are ignored.
+					wgtest.MustCIDR("0.0.0.0/0"),
+				},
+			},
+		},
+	}
+
+	var store wgipam.MemoryStore
+	// The device's own address.
+	if err := store.Reserve(netip.MustParseAddr("10.0.0.1"), wgtypes.Key{}); err != nil {
+		t.Fatalf("failed to reserve device address: %v", err)
+	}
+
+	alloc, err := wgipam.New(wgtest.MustCIDR("10.0.0.0/28"), &store)
+	if err != nil {
+		t.Fatalf("failed to create allocator: %v", err)
+	}
+
+	allocate := func(peer wgtypes.Key) string {
+		t.Helper()
+
+		ipn, err := alloc.Allocate(d, peer)
+		if err != nil {
+			t.Fatalf("failed to allocate: %v", err)
+		}
+
+		return ipn.String()
+	}
+
+	// The existing address of a is returned again, b gets the lowest free
+	// address, and c skips the routed subnet.
+	got := []string{allocate(a), allocate(b), allocate(c), allocate(b)}
+	want := []string{"10.0.0.2/32", "10.0.0.3/32", "10.0.0.8/32", "10.0.0.3/32"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected addresses (-want +got):\n%s", diff)
+	}
+
+	// The remaining addresses, excluding the broadcast address.
+	for i := 9; i <= 14; i++ {
+		if _, err := alloc.Allocate(d, wgtest.MustPublicKey()); err != nil {
+			t.Fatalf("failed to allocate address %d: %v", i, err)
+		}
+	}
+
+	if _, err := alloc.Allocate(d, wgtest.MustPublicKey()); !errors.Is(err, wgipam.ErrExhausted) {
+		t.Fatalf("expected ErrExhausted, but got: %v", err)
+	}
+
+	if err := alloc.Release(wgtest.MustCIDR("10.0.0.8/32")); err != nil {
+		t.Fatalf("failed to release address: %v", err)
+	}
+	if diff := cmp.Diff("10.0.0.8/32", allocate(wgtest.MustPublicKey())); diff != "" {
+		t.Fatalf("unexpected address (-want +got):\n%s", diff)
+	}
+}
+
+func TestAllocatorIPv6(t *testing.T) {
+	d := &wgtypes.Device{
+		Peers: []wgtypes.Peer{{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []net.IPNet{wgtest.MustCIDR("fd00::/80")},
+		}},
+	}
+
+	alloc, err := wgipam.New(wgtest.MustCIDR("fd00::/64"), &wgipam.MemoryStore{})
+	if err != nil {
+		t.Fatalf("failed to create allocator: %v", err)
+	}
+
+	ipn, err := alloc.Allocate(d, wgtest.MustPublicKey())
+	if err != nil {
+		t.Fatalf("failed to allocate: %v", err)
+	}
+
+	if diff := cmp.Diff("fd00::1:0:0:0/128", ipn.String()); diff != "" {
+		t.Fatalf("unexpected address (-want +got):\n%s", diff)
+	}
+}
+
+func TestDirStoreConcurrent(t *testing.T) {
+	dir := t.TempDir()
+
+	// Each allocator uses its own DirStore, as separate processes would.
+	const n = 16
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		addrs = make(map[string]bool)
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			alloc, err := wgipam.New(wgtest.MustCIDR("fd00::/120"), &wgipam.DirStore{Dir: dir})
+			if err != nil {
+				panic(err)
+			}
+
+			ipn, err := alloc.Allocate(nil, wgtest.MustPublicKey())
+			if err != nil {
+				panic(err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			addrs[ipn.String()] = true
+		}()
+	}
+	wg.Wait()
+
+	if len(addrs) != n {
+		t.Fatalf("expected %d distinct addresses, but got %d", n, len(addrs))
+	}
+
+	rs, err := (&wgipam.DirStore{Dir: dir}).Reservations()
+	if err != nil {
+		t.Fatalf("failed to list reservations: %v", err)
+	}
+	if len(rs) != n {
+		t.Fatalf("expected %d reservations, but got %d", n, len(rs))
+	}
+}
@@ -0,0 +1,164 @@
+package wgipam
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ErrReserved is returned by Store.Reserve when an address is already
+// reserved.
+var ErrReserved = errors.New("wgipam: address is already reserved")
+
+// A Store records the addresses reserved for peers.
+type Store interface {
+	// Reserve reserves addr for peer. If addr is already reserved, for any
+	// peer, it returns ErrReserved. Reserve must be atomic with respect to
+	// other calls to Reserve.
+	Reserve(addr netip.Addr, peer wgtypes.Key) error
+
+	// Release removes the reservation of addr. Releasing an address which
+	// is not reserved is not an error.
+	Release(addr netip.Addr) error
+
+	// Reservations returns every reserved address and the peer it is
+	// reserved for.
+	Reservations() (map[netip.Addr]wgtypes.Key, error)
+}
+
+// A MemoryStore is an in-memory Store which is safe for concurrent use. Its
+// zero value is ready to use.
+type MemoryStore struct {
+	mu sync.Mutex
+	m  map[netip.Addr]wgtypes.Key
+}
+
+var _ Store = &MemoryStore{}
+
+// Reserve implements Store.
+func (s *MemoryStore) Reserve(addr netip.Addr, peer wgtypes.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.m[addr]; ok {
+		return ErrReserved
+	}
+	if s.m == nil {
+		s.m = make(map[netip.Addr]wgtypes.Key)
+	}
+
+	s.m[addr] = peer
+	return nil
+}
+
+// Release implements Store.
+func (s *MemoryStore) Release(addr netip.Addr) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m, addr)
+	return nil
+}
+
+// Reservations implements Store.
+func (s *MemoryStore) Reservations() (map[netip.Addr]wgtypes.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[netip.Addr]wgtypes.Key, len(s.m))
+	for addr, peer := range s.m {
+		out[addr] = peer
+	}
+
+	return out, nil
+}
+
+// A DirStore is a Store which records each reservation as a file in a
+// directory, named after the address and containing the peer's public key.
+// Files are created exclusively, so that processes sharing the directory
+// never reserve the same address.
+type DirStore struct {
+	// Dir is the directory, which is created if necessary.
+	Dir string
+}
+
+var _ Store = &DirStore{}
+
+// Reserve implements Store.
+func (s *DirStore) Reserve(addr netip.Addr, peer wgtypes.Key) error {
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path(addr), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return ErrReserved
+		}
+		return err
+	}
+
+	if _, err := f.WriteString(peer.String() + "\n"); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	return f.Close()
+}
+
+// Release implements Store.
+func (s *DirStore) Release(addr netip.Addr) error {
+	if err := os.Remove(s.path(addr)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Reservations implements Store.
+func (s *DirStore) Reservations() (map[netip.Addr]wgtypes.Key, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[netip.Addr]wgtypes.Key{}, nil
+		}
+		return nil, err
+	}
+
+	out := make(map[netip.Addr]wgtypes.Key, len(entries))
+	for _, e := range entries {
+		addr, err := netip.ParseAddr(strings.ReplaceAll(e.Name(), "_", ":"))
+		if err != nil {
+			// Not a reservation.
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(s.Dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		peer, err := wgtypes.ParseKey(strings.TrimSpace(string(b)))
+		if err != nil {
+			// A reservation which is still being written holds its address
+			// with no peer.
+			peer = wgtypes.Key{}
+		}
+
+		out[addr] = peer
+	}
+
+	return out, nil
+}
+
+// path returns the path of the file which reserves addr. Colons are not
+// permitted in file names on Windows, so they are replaced by underscores.
+func (s *DirStore) path(addr netip.Addr) string {
+	return filepath.Join(s.Dir, strings.ReplaceAll(addr.String(), ":", "_"))
+}
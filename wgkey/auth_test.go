@@ -0,0 +1,60 @@
+package wgkey_test
+
+import (
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgkey"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestDeriveAuthKey(t *testing.T) {
+	var (
+		alice = wgtest.MustPrivateKey()
+		bob   = wgtest.MustPrivateKey()
+		eve   = wgtest.MustPrivateKey()
+	)
+
+	derive := func(priv, pub wgtypes.Key, purpose string) wgkey.AuthKey {
+		t.Helper()
+
+		k, err := wgkey.DeriveAuthKey(priv, pub, purpose)
+		if err != nil {
+			t.Fatalf("failed to derive key: %v", err)
+		}
+
+		return k
+	}
+
+	ab := derive(alice, bob.PublicKey(), "test")
+	if ba := derive(bob, alice.PublicKey(), "test"); ab != ba {
+		t.Fatal("peers derived different keys")
+	}
+
+	if ab == derive(alice, bob.PublicKey(), "other") {
+		t.Fatal("keys for different purposes are identical")
+	}
+	if ab == derive(alice, eve.PublicKey(), "test") {
+		t.Fatal("keys for different peers are identical")
+	}
+
+	msg := []byte("rotate keys at 12:00")
+	mac := ab.MAC(msg)
+	if !ab.Verify(msg, mac) {
+		t.Fatal("failed to verify MAC")
+	}
+
+	ae := derive(alice, eve.PublicKey(), "test")
+	if ae.Verify(msg, mac) || ab.Verify([]byte("rotate keys at 13:00"), mac) {
+		t.Fatal("verified an invalid MAC")
+	}
+}
+
+func TestDeriveAuthKeyLowOrder(t *testing.T) {
+	// The all-zero public key is a low-order point.
+	if _, err := wgkey.DeriveAuthKey(wgtest.MustPrivateKey(), wgtypes.Key{}, "test"); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	} else {
+		t.Logf("OK error: %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+// Package wgkey provides helpers for storing, retrieving, and using WireGuard
+// keys outside of a device's configuration.
+//
+// Encode and Decode wrap a key in a PEM container with optional metadata
+// and passphrase encryption, for use with secret distribution pipelines
+// which do not accept raw base64 keys.
+//
+// A Provider retrieves keys by name from a source such as the environment,
+// files, or an external secret manager.
+//
+// DeriveAuthKey derives a key shared by two peers from their WireGuard keys,
+// which may be used to authenticate control messages exchanged between them.
+package wgkey // import "golang.zx2c4.com/wireguard/wgctrl/wgkey"
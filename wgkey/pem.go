@@ -0,0 +1,295 @@
+package wgkey
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Kind is the kind of key stored in a Block.
+type Kind int
+
+// Possible Kind values.
+const (
+	_ Kind = iota
+	Private
+	Public
+	Preshared
+)
+
+// String returns the string representation of a Kind.
+func (k Kind) String() string {
+	switch k {
+	case Private:
+		return "private"
+	case Public:
+		return "public"
+	case Preshared:
+		return "preshared"
+	default:
+		return "unknown"
+	}
+}
+
+// pemType returns the PEM block type used for keys of kind k.
+func (k Kind) pemType() string {
+	switch k {
+	case Private:
+		return "WIREGUARD PRIVATE KEY"
+	case Public:
+		return "WIREGUARD PUBLIC KEY"
+	case Preshared:
+		return "WIREGUARD PRESHARED KEY"
+	default:
+		return ""
+	}
+}
+
+// A Block is a key and its metadata, stored in a PEM container.
+type Block struct {
+	// Kind is the kind of Key.
+	Kind Kind
+
+	// Key is the key itself.
+	Key wgtypes.Key
+
+	// Created, if not zero, is the time at which the key was created.
+	Created time.Time
+
+	// Comment is an optional comment describing the key, such as its
+	// purpose or the name of its owner. It must not contain newlines, and
+	// leading and trailing whitespace is removed by Encode.
+	Comment string
+}
+
+// PEM headers used by Encode and Decode.
+const (
+	headerCreated    = "Created"
+	headerComment    = "Comment"
+	headerEncryption = "Encryption"
+	headerSalt       = "Salt"
+)
+
+// encScrypt identifies passphrase encryption using scrypt and
+// XChaCha20-Poly1305, with the block's metadata as additional data.
+const encScrypt = "scrypt-xchacha20poly1305"
+
+// scrypt parameters recommended for interactive use as of 2017.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const (
+	saltSize  = 16
+	nonceSize = chacha20poly1305.NonceSizeX
+)
+
+// Errors returned by Decode.
+var (
+	// ErrPassphrase is returned when an encrypted Block cannot be decrypted
+	// using the specified passphrase, or when its metadata has been
+	// modified.
+	ErrPassphrase = errors.New("wgkey: incorrect passphrase")
+
+	// ErrNoPassphrase is returned when an encrypted Block is decoded without
+	// a passphrase.
+	ErrNoPassphrase = errors.New("wgkey: key is encrypted, but no passphrase was specified")
+)
+
+// Encode encodes b as PEM. If passphrase is not empty, the key is encrypted
+// using a key derived from passphrase. Metadata is never encrypted, but it is
+// authenticated along with an encrypted key, so that it cannot be modified
+// without the passphrase.
+func Encode(b *Block, passphrase []byte) ([]byte, error) {
+	typ := b.Kind.pemType()
+	if typ == "" {
+		return nil, fmt.Errorf("wgkey: invalid key kind: %d", b.Kind)
+	}
+	if strings.ContainsAny(b.Comment, "\r\n") {
+		// A newline would begin another PEM header.
+		return nil, errors.New("wgkey: comment must not contain newlines")
+	}
+
+	// PEM headers are trimmed when decoded, so trim the comment before it
+	// is authenticated.
+	comment := strings.TrimSpace(b.Comment)
+
+	p := &pem.Block{
+		Type:    typ,
+		Headers: make(map[string]string),
+		Bytes:   b.Key[:],
+	}
+
+	if !b.Created.IsZero() {
+		p.Headers[headerCreated] = b.Created.UTC().Format(time.RFC3339)
+	}
+	if comment != "" {
+		p.Headers[headerComment] = comment
+	}
+
+	if len(passphrase) > 0 {
+		salt := make([]byte, saltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return nil, err
+		}
+
+		aead, err := newAEAD(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+
+		p.Headers[headerEncryption] = encScrypt
+		p.Headers[headerSalt] = base64.StdEncoding.EncodeToString(salt)
+		p.Bytes = aead.Seal(nonce, nonce, b.Key[:], metadata(p))
+	}
+
+	out := pem.EncodeToMemory(p)
+	if out == nil {
+		return nil, errors.New("wgkey: failed to encode PEM block")
+	}
+
+	return out, nil
+}
+
+// Decode decodes the first PEM-encoded WireGuard key in data, using
+// passphrase to decrypt the key if it is encrypted.
+func Decode(data, passphrase []byte) (*Block, error) {
+	for {
+		var p *pem.Block
+		p, data = pem.Decode(data)
+		if p == nil {
+			return nil, errors.New("wgkey: no PEM-encoded WireGuard key found")
+		}
+
+		for _, k := range []Kind{Private, Public, Preshared} {
+			if p.Type == k.pemType() {
+				return decodeBlock(k, p, passphrase)
+			}
+		}
+	}
+}
+
+// decodeBlock decodes a PEM block containing a key of kind k.
+func decodeBlock(k Kind, p *pem.Block, passphrase []byte) (*Block, error) {
+	b := &Block{
+		Kind:    k,
+		Comment: p.Headers[headerComment],
+	}
+
+	if s, ok := p.Headers[headerCreated]; ok {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("wgkey: invalid creation time: %v", err)
+		}
+
+		b.Created = t
+	}
+
+	key := p.Bytes
+	switch enc := p.Headers[headerEncryption]; enc {
+	case "":
+	case encScrypt:
+		if len(passphrase) == 0 {
+			return nil, ErrNoPassphrase
+		}
+
+		salt, err := base64.StdEncoding.DecodeString(p.Headers[headerSalt])
+		if err != nil || len(salt) != saltSize {
+			return nil, errors.New("wgkey: invalid salt")
+		}
+		if len(key) < nonceSize {
+			return nil, errors.New("wgkey: encrypted key is too short")
+		}
+
+		aead, err := newAEAD(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+
+		out, err := aead.Open(nil, key[:nonceSize], key[nonceSize:], metadata(p))
+		if err != nil {
+			return nil, ErrPassphrase
+		}
+
+		key = out
+	default:
+		return nil, fmt.Errorf("wgkey: unsupported encryption: %q", enc)
+	}
+
+	wk, err := wgtypes.NewKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Key = wk
+	return b, nil
+}
+
+// newAEAD creates an XChaCha20-Poly1305 AEAD using a key derived from
+// passphrase and salt.
+func newAEAD(passphrase, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return chacha20poly1305.NewX(key)
+}
+
+// metadata returns the additional data which authenticates the type and
+// headers of p. Header values cannot contain newlines, so each is on its own
+// line.
+func metadata(p *pem.Block) []byte {
+	var b strings.Builder
+	b.WriteString(p.Type)
+	for _, h := range []string{headerCreated, headerComment, headerEncryption, headerSalt} {
+		v, ok := p.Headers[h]
+		if !ok {
+			b.WriteString("\n-")
+			continue
+		}
+
+		b.WriteString("\n+")
+		b.WriteString(v)
+	}
+
+	return []byte(b.String())
+}
+
+// Load reads and decodes the key in the file at path, as with Decode.
+func Load(path string, passphrase []byte) (*Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(data, passphrase)
+}
+
+// Save encodes b as with Encode and writes it to the file at path, which is
+// created with mode 0600 if it does not exist.
+func Save(path string, b *Block, passphrase []byte) error {
+	data, err := Encode(b, passphrase)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
@@ -0,0 +1,189 @@
+package wgkey_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgkey"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	psk := wgtest.MustPresharedKey()
+
+	tests := []struct {
+		name       string
+		b, want    *wgkey.Block
+		passphrase string
+	}{
+		{
+			name: "private",
+			b: &wgkey.Block{
+				Kind:    wgkey.Private,
+				Key:     wgtest.MustPrivateKey(),
+				Created: time.Date(2023, time.April, 1, 0, 0, 0, 0, time.UTC),
+				Comment: "alice@example.com",
+			},
+		},
+		{
+			name: "public",
+			b: &wgkey.Block{
+				Kind: wgkey.Public,
+				Key:  wgtest.MustPublicKey(),
+			},
+		},
+		{
+			name: "encrypted preshared",
+			b: &wgkey.Block{
+				Kind:    wgkey.Preshared,
+				Key:     wgtest.MustPresharedKey(),
+				Comment: "tunnel",
+			},
+			passphrase: "hunter2",
+		},
+		{
+			name: "encrypted padded comment",
+			b: &wgkey.Block{
+				Kind:    wgkey.Preshared,
+				Key:     psk,
+				Comment: " comment ",
+			},
+			want: &wgkey.Block{
+				Kind:    wgkey.Preshared,
+				Key:     psk,
+				Comment: "comment",
+			},
+			passphrase: "hunter2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "key.pem")
+			if err := wgkey.Save(path, tt.b, []byte(tt.passphrase)); err != nil {
+				t.Fatalf("failed to save key: %v", err)
+			}
+
+			b, err := wgkey.Load(path, []byte(tt.passphrase))
+			if err != nil {
+				t.Fatalf("failed to load key: %v", err)
+			}
+
+			want := tt.want
+			if want == nil {
+				want = tt.b
+			}
+
+			if diff := cmp.Diff(want, b); diff != "" {
+				t.Fatalf("unexpected block (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEncodeFormat(t *testing.T) {
+	b, err := wgkey.Encode(&wgkey.Block{
+		Kind:    wgkey.Public,
+		Key:     wgtest.MustHexKey("b85996fecc9c7f1fc6d2572a76eda11d59bcd20be8e543b15ce4bd85a8e75a33"),
+		Comment: "peer",
+	}, nil)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	const want = `-----BEGIN WIREGUARD PUBLIC KEY-----
+Comment: peer
+
+uFmW/sycfx/G0lcqdu2hHVm80gvo5UOxXOS9hajnWjM=
+-----END WIREGUARD PUBLIC KEY-----
+`
+
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Fatalf("unexpected PEM (-want +got):\n%s", diff)
+	}
+}
+
+func TestEncodeInvalidComment(t *testing.T) {
+	for _, c := range []string{"x\nEvil: 1", "x\rEvil: 1"} {
+		_, err := wgkey.Encode(&wgkey.Block{
+			Kind:    wgkey.Public,
+			Key:     wgtest.MustPublicKey(),
+			Comment: c,
+		}, nil)
+		if err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", c)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	enc, err := wgkey.Encode(&wgkey.Block{
+		Kind:    wgkey.Private,
+		Key:     wgtest.MustPrivateKey(),
+		Comment: "alice",
+	}, []byte("correct"))
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+
+	// Metadata is authenticated along with an encrypted key.
+	retyped := strings.ReplaceAll(string(enc), "PRIVATE", "PRESHARED")
+	recommented := strings.Replace(string(enc), "Comment: alice", "Comment: mallory", 1)
+
+	tests := []struct {
+		name       string
+		data       string
+		passphrase string
+		err        error
+	}{
+		{
+			name: "no key",
+			data: "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----\n",
+		},
+		{
+			name: "no passphrase",
+			data: string(enc),
+			err:  wgkey.ErrNoPassphrase,
+		},
+		{
+			name:       "wrong passphrase",
+			data:       string(enc),
+			passphrase: "wrong",
+			err:        wgkey.ErrPassphrase,
+		},
+		{
+			name:       "modified type",
+			data:       retyped,
+			passphrase: "correct",
+			err:        wgkey.ErrPassphrase,
+		},
+		{
+			name:       "modified comment",
+			data:       recommented,
+			passphrase: "correct",
+			err:        wgkey.ErrPassphrase,
+		},
+		{
+			name: "short key",
+			data: "-----BEGIN WIREGUARD PUBLIC KEY-----\nAAAA\n-----END WIREGUARD PUBLIC KEY-----\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := wgkey.Decode([]byte(tt.data), []byte(tt.passphrase))
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, but got: %v", tt.err, err)
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
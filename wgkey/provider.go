@@ -0,0 +1,100 @@
+package wgkey
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Provider retrieves keys by name, so that configurations may refer to keys
+// by name rather than by value. Providers only retrieve keys; they never
+// perform cryptographic operations using them.
+//
+// A Provider backed by a secret manager such as HashiCorp Vault or a cloud
+// KMS typically reads a base64-encoded key from a secret named by name, and
+// decodes it using wgtypes.ParseKey. Use ctx to bound any network requests.
+type Provider interface {
+	// Key retrieves the key called name. If no such key exists, an error
+	// compatible with os.ErrNotExist is returned.
+	Key(ctx context.Context, name string) (wgtypes.Key, error)
+}
+
+// A ProviderFunc is a function which implements Provider.
+type ProviderFunc func(ctx context.Context, name string) (wgtypes.Key, error)
+
+// Key implements Provider.
+func (fn ProviderFunc) Key(ctx context.Context, name string) (wgtypes.Key, error) {
+	return fn(ctx, name)
+}
+
+// An EnvProvider is a Provider which retrieves base64-encoded keys from
+// environment variables. The key called name is stored in the variable
+// Prefix + name, such as "WG_KEY_wg0" with the prefix "WG_KEY_".
+type EnvProvider struct {
+	Prefix string
+}
+
+var _ Provider = EnvProvider{}
+
+// Key implements Provider.
+func (p EnvProvider) Key(_ context.Context, name string) (wgtypes.Key, error) {
+	v, ok := os.LookupEnv(p.Prefix + name)
+	if !ok {
+		return wgtypes.Key{}, fmt.Errorf("wgkey: environment variable %q: %w", p.Prefix+name, os.ErrNotExist)
+	}
+
+	k, err := wgtypes.ParseKey(strings.TrimSpace(v))
+	if err != nil {
+		return wgtypes.Key{}, fmt.Errorf("wgkey: environment variable %q: %v", p.Prefix+name, err)
+	}
+
+	return k, nil
+}
+
+// A FileProvider is a Provider which retrieves keys from files in a
+// directory. The key called name is stored in the file Dir/name, either as a
+// base64-encoded key as produced by wg(8), or in a PEM container as produced
+// by Encode.
+type FileProvider struct {
+	// Dir is the directory which contains key files.
+	Dir string
+
+	// Passphrase, if set, is used to decrypt keys in PEM containers.
+	Passphrase []byte
+}
+
+var _ Provider = FileProvider{}
+
+// Key implements Provider.
+func (p FileProvider) Key(_ context.Context, name string) (wgtypes.Key, error) {
+	// Key names must not refer to files outside of Dir.
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return wgtypes.Key{}, fmt.Errorf("wgkey: invalid key name: %q", name)
+	}
+
+	b, err := os.ReadFile(filepath.Join(p.Dir, name))
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN ")) {
+		blk, err := Decode(b, p.Passphrase)
+		if err != nil {
+			return wgtypes.Key{}, err
+		}
+
+		return blk.Key, nil
+	}
+
+	k, err := wgtypes.ParseKey(string(bytes.TrimSpace(b)))
+	if err != nil {
+		return wgtypes.Key{}, fmt.Errorf("wgkey: key file %q: %v", name, err)
+	}
+
+	return k, nil
+}
@@ -0,0 +1,82 @@
+package wgkey_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgkey"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestProviders(t *testing.T) {
+	var (
+		k1 = wgtest.MustPrivateKey()
+		k2 = wgtest.MustPrivateKey()
+	)
+
+	t.Setenv("WGKEY_TEST_wg0", k1.String())
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "wg0"), []byte(k1.String()+"\n"), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	err := wgkey.Save(filepath.Join(dir, "wg1"), &wgkey.Block{Kind: wgkey.Private, Key: k2}, nil)
+	if err != nil {
+		t.Fatalf("failed to save key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		p    wgkey.Provider
+		keys map[string]bool
+	}{
+		{
+			name: "env",
+			p:    wgkey.EnvProvider{Prefix: "WGKEY_TEST_"},
+			keys: map[string]bool{"wg0": true, "wg1": false},
+		},
+		{
+			name: "file",
+			p:    wgkey.FileProvider{Dir: dir},
+			keys: map[string]bool{"wg0": true, "wg1": true, "wg2": false},
+		},
+	}
+
+	want := map[string]wgtypes.Key{"wg0": k1, "wg1": k2}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, ok := range tt.keys {
+				k, err := tt.p.Key(context.Background(), name)
+				if !ok {
+					if !errors.Is(err, os.ErrNotExist) {
+						t.Fatalf("expected is not exist error for %q, but got: %v", name, err)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("failed to get key %q: %v", name, err)
+				}
+
+				if diff := cmp.Diff(want[name], k); diff != "" {
+					t.Fatalf("unexpected key %q (-want +got):\n%s", name, diff)
+				}
+			}
+		})
+	}
+}
+
+func TestFileProviderInvalidName(t *testing.T) {
+	p := wgkey.FileProvider{Dir: t.TempDir()}
+
+	for _, name := range []string{"", "..", "../wg0", "a/b"} {
+		if _, err := p.Key(context.Background(), name); err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", name)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+// Package wglint checks WireGuard device configurations for common mistakes
+// before they are applied.
+//
+// Lint runs a set of Rules against one or more Devices and reports Findings.
+// Rules which examine a single device, such as DuplicateEndpoints, catch
+// mistakes within one configuration, while rules such as KeepaliveBothEnds
+// and MTUMismatch compare the configurations of devices which are peers of
+// each other, and so are most useful when every end of a tunnel is linted
+// together. Callers can supply their own Rules alongside DefaultRules.
+package wglint // import "golang.zx2c4.com/wireguard/wgctrl/wglint"
@@ -0,0 +1,158 @@
+package wglint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgconf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Device is the configuration of a device to be linted.
+type Device struct {
+	// Name identifies the device in Findings.
+	Name string
+
+	Config wgtypes.Config
+
+	// MTU is the MTU of the device's network interface, or 0 if unknown.
+	MTU int
+}
+
+// publicKey returns the public key of d, and false if it has no private key.
+func (d *Device) publicKey() (wgtypes.Key, bool) {
+	if d.Config.PrivateKey == nil {
+		return wgtypes.Key{}, false
+	}
+
+	return d.Config.PrivateKey.PublicKey(), true
+}
+
+// FromFile produces a Device named name from a configuration file, taking
+// its MTU from the MTU key used by wg-quick(8), if present.
+func FromFile(name string, f *wgconf.File) (Device, error) {
+	cfg, err := f.Config()
+	if err != nil {
+		return Device{}, err
+	}
+
+	d := Device{Name: name, Config: cfg}
+	for _, field := range f.Interface.Extra {
+		if !strings.EqualFold(field.Key, "MTU") {
+			continue
+		}
+
+		mtu, err := strconv.Atoi(field.Value)
+		if err != nil || mtu <= 0 {
+			return Device{}, fmt.Errorf("wglint: invalid MTU %q", field.Value)
+		}
+
+		d.MTU = mtu
+	}
+
+	return d, nil
+}
+
+// A Severity indicates how likely a Finding is to cause problems.
+type Severity int
+
+// Possible Severity values.
+const (
+	// Warning indicates a configuration which is likely to cause an outage
+	// or a security problem.
+	Warning Severity = iota
+
+	// Hint indicates a configuration which works, but may be improved.
+	Hint
+)
+
+// String returns the string representation of a Severity.
+func (s Severity) String() string {
+	switch s {
+	case Warning:
+		return "warning"
+	case Hint:
+		return "hint"
+	default:
+		return "unknown"
+	}
+}
+
+// A Finding is a potential problem reported by a Rule.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Device   string
+
+	// Peer is the public key of the peer involved, or the zero key if the
+	// Finding concerns the device as a whole.
+	Peer wgtypes.Key
+
+	Message string
+}
+
+// String returns a human-readable description of a Finding.
+func (f Finding) String() string {
+	if f.Peer == (wgtypes.Key{}) {
+		return fmt.Sprintf("%s: %s: %s (%s)", f.Device, f.Severity, f.Message, f.Rule)
+	}
+
+	return fmt.Sprintf("%s: peer %s: %s: %s (%s)", f.Device, f.Peer, f.Severity, f.Message, f.Rule)
+}
+
+// A Rule checks a set of Devices and reports any Findings.
+type Rule struct {
+	// Name identifies the rule in Findings.
+	Name string
+
+	// Description explains what the rule checks and why.
+	Description string
+
+	// Check reports Findings for devices. Lint sets the Rule field of each
+	// Finding to Name.
+	Check func(devices []Device) []Finding
+}
+
+// DefaultRules returns the Rules used by Lint when none are specified.
+func DefaultRules() []Rule {
+	return []Rule{
+		DefaultRouteWithoutFwmark,
+		KeepaliveBothEnds,
+		MissingPresharedKey,
+		MTUMismatch,
+		DuplicateEndpoints,
+	}
+}
+
+// Lint checks devices using rules, or DefaultRules if rules is empty. The
+// Findings are ordered by device, then by rule, and then by peer.
+//
+// A nil slice indicates that no problems were found.
+func Lint(devices []Device, rules ...Rule) []Finding {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+
+	var fs []Finding
+	for _, r := range rules {
+		for _, f := range r.Check(devices) {
+			f.Rule = r.Name
+			fs = append(fs, f)
+		}
+	}
+
+	sort.SliceStable(fs, func(i, j int) bool {
+		if fs[i].Device != fs[j].Device {
+			return fs[i].Device < fs[j].Device
+		}
+		if fs[i].Rule != fs[j].Rule {
+			return fs[i].Rule < fs[j].Rule
+		}
+
+		return fs[i].Peer.String() < fs[j].Peer.String()
+	})
+
+	return fs
+}
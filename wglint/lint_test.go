@@ -0,0 +1,261 @@
+package wglint_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgconf"
+	"golang.zx2c4.com/wireguard/wgctrl/wglint"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestLint(t *testing.T) {
+	var (
+		aPriv = wgtest.MustPrivateKey()
+		bPriv = wgtest.MustPrivateKey()
+		aPub  = aPriv.PublicKey()
+		bPub  = bPriv.PublicKey()
+		cPub  = wgtest.MustPublicKey()
+		psk   = wgtest.MustPresharedKey()
+		ka    = 25 * time.Second
+		mark  = 1
+	)
+
+	tests := []struct {
+		name    string
+		rule    wglint.Rule
+		devices []wglint.Device
+		want    []wglint.Finding
+	}{
+		{
+			name: "default route with fwmark",
+			rule: wglint.DefaultRouteWithoutFwmark,
+			devices: []wglint.Device{{
+				Name: "a",
+				Config: wgtypes.Config{
+					FirewallMark: &mark,
+					Peers: []wgtypes.PeerConfig{{
+						PublicKey:  bPub,
+						AllowedIPs: []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+					}},
+				},
+			}},
+		},
+		{
+			name: "default route without fwmark",
+			rule: wglint.DefaultRouteWithoutFwmark,
+			devices: []wglint.Device{{
+				Name: "a",
+				Config: wgtypes.Config{
+					Peers: []wgtypes.PeerConfig{{
+						PublicKey: bPub,
+						AllowedIPs: []net.IPNet{
+							wgtest.MustCIDR("10.0.0.0/8"),
+							wgtest.MustCIDR("::/0"),
+						},
+					}},
+				},
+			}},
+			want: []wglint.Finding{{
+				Rule:     "default-route-without-fwmark",
+				Severity: wglint.Warning,
+				Device:   "a",
+				Peer:     bPub,
+				Message:  "allowed IPs include default route ::/0, but no firewall mark is set",
+			}},
+		},
+		{
+			name: "keepalive both ends",
+			rule: wglint.KeepaliveBothEnds,
+			devices: []wglint.Device{
+				{
+					Name: "a",
+					Config: wgtypes.Config{
+						PrivateKey: &aPriv,
+						Peers: []wgtypes.PeerConfig{
+							{PublicKey: bPub, PersistentKeepaliveInterval: &ka},
+							{PublicKey: cPub, PersistentKeepaliveInterval: &ka},
+						},
+					},
+				},
+				{
+					Name: "b",
+					Config: wgtypes.Config{
+						PrivateKey: &bPriv,
+						Peers:      []wgtypes.PeerConfig{{PublicKey: aPub, PersistentKeepaliveInterval: &ka}},
+					},
+				},
+			},
+			want: []wglint.Finding{
+				{
+					Rule:     "keepalive-both-ends",
+					Severity: wglint.Hint,
+					Device:   "a",
+					Peer:     bPub,
+					Message:  "persistent keepalive is enabled on both ends of the tunnel with b",
+				},
+				{
+					Rule:     "keepalive-both-ends",
+					Severity: wglint.Hint,
+					Device:   "b",
+					Peer:     aPub,
+					Message:  "persistent keepalive is enabled on both ends of the tunnel with a",
+				},
+			},
+		},
+		{
+			name: "missing preshared key",
+			rule: wglint.MissingPresharedKey,
+			devices: []wglint.Device{{
+				Name: "a",
+				Config: wgtypes.Config{
+					Peers: []wgtypes.PeerConfig{
+						{PublicKey: bPub, PresharedKey: &psk},
+						{PublicKey: cPub},
+						{PublicKey: aPub, Remove: true},
+					},
+				},
+			}},
+			want: []wglint.Finding{{
+				Rule:     "missing-preshared-key",
+				Severity: wglint.Hint,
+				Device:   "a",
+				Peer:     cPub,
+				Message:  "no preshared key is configured",
+			}},
+		},
+		{
+			name: "MTU mismatch",
+			rule: wglint.MTUMismatch,
+			devices: []wglint.Device{
+				{
+					Name:   "a",
+					MTU:    1420,
+					Config: wgtypes.Config{PrivateKey: &aPriv, Peers: []wgtypes.PeerConfig{{PublicKey: bPub}}},
+				},
+				{
+					Name:   "b",
+					MTU:    1280,
+					Config: wgtypes.Config{PrivateKey: &bPriv, Peers: []wgtypes.PeerConfig{{PublicKey: aPub}}},
+				},
+			},
+			want: []wglint.Finding{
+				{
+					Rule:     "mtu-mismatch",
+					Severity: wglint.Hint,
+					Device:   "a",
+					Peer:     bPub,
+					Message:  "MTU 1420 differs from MTU 1280 of b",
+				},
+				{
+					Rule:     "mtu-mismatch",
+					Severity: wglint.Hint,
+					Device:   "b",
+					Peer:     aPub,
+					Message:  "MTU 1280 differs from MTU 1420 of a",
+				},
+			},
+		},
+		{
+			name: "duplicate endpoints",
+			rule: wglint.DuplicateEndpoints,
+			devices: []wglint.Device{{
+				Name: "a",
+				Config: wgtypes.Config{
+					Peers: []wgtypes.PeerConfig{
+						{PublicKey: bPub, Endpoint: wgtest.MustUDPAddr("192.0.2.1:51820")},
+						{PublicKey: cPub, Endpoint: wgtest.MustUDPAddr("192.0.2.1:51820")},
+					},
+				},
+			}},
+			want: []wglint.Finding{{
+				Rule:     "duplicate-endpoints",
+				Severity: wglint.Warning,
+				Device:   "a",
+				Peer:     cPub,
+				Message:  "endpoint 192.0.2.1:51820 is also used by peer " + bPub.String(),
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := wglint.Lint(tt.devices, tt.rule)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected findings (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLintCustomRule(t *testing.T) {
+	rule := wglint.Rule{
+		Name: "no-listen-port",
+		Check: func(devices []wglint.Device) []wglint.Finding {
+			var fs []wglint.Finding
+			for _, d := range devices {
+				if d.Config.ListenPort == nil {
+					fs = append(fs, wglint.Finding{Device: d.Name, Message: "no listen port"})
+				}
+			}
+
+			return fs
+		},
+	}
+
+	got := wglint.Lint([]wglint.Device{{Name: "b"}, {Name: "a"}}, rule)
+
+	want := []string{
+		"a: warning: no listen port (no-listen-port)",
+		"b: warning: no listen port (no-listen-port)",
+	}
+
+	var ss []string
+	for _, f := range got {
+		ss = append(ss, f.String())
+	}
+
+	if diff := cmp.Diff(want, ss); diff != "" {
+		t.Fatalf("unexpected findings (-want +got):\n%s", diff)
+	}
+}
+
+func TestFromFile(t *testing.T) {
+	in := `[Interface]
+PrivateKey = ` + wgtest.MustPrivateKey().String() + `
+MTU = 1280
+
+[Peer]
+PublicKey = ` + wgtest.MustPublicKey().String() + `
+AllowedIPs = 0.0.0.0/0
+`
+
+	f, err := wgconf.Parse(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	d, err := wglint.FromFile("wg0", f)
+	if err != nil {
+		t.Fatalf("failed to create device: %v", err)
+	}
+
+	if diff := cmp.Diff(1280, d.MTU); diff != "" {
+		t.Fatalf("unexpected MTU (-want +got):\n%s", diff)
+	}
+
+	// All default rules run when none are specified.
+	var rules []string
+	for _, f := range wglint.Lint([]wglint.Device{d}) {
+		rules = append(rules, f.Rule)
+	}
+
+	want := []string{"default-route-without-fwmark", "missing-preshared-key"}
+	if diff := cmp.Diff(want, rules); diff != "" {
+		t.Fatalf("unexpected rules (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,197 @@
+package wglint
+
+import (
+	"fmt"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// DefaultRouteWithoutFwmark reports peers whose allowed IPs include a default
+// route on devices without a firewall mark.
+var DefaultRouteWithoutFwmark = Rule{
+	Name: "default-route-without-fwmark",
+	Description: "A peer which routes all traffic through the tunnel also attracts the " +
+		"tunnel's own encrypted packets unless they are marked with a firewall mark " +
+		"and routed around the tunnel, causing a routing loop. wg-quick(8) sets a " +
+		"firewall mark automatically, but other tools do not.",
+	Check: func(devices []Device) []Finding {
+		var fs []Finding
+		for _, d := range devices {
+			if d.Config.FirewallMark != nil && *d.Config.FirewallMark != 0 {
+				continue
+			}
+
+			for _, p := range d.Config.Peers {
+				for _, ipn := range p.AllowedIPs {
+					if ones, _ := ipn.Mask.Size(); ones != 0 {
+						continue
+					}
+
+					fs = append(fs, Finding{
+						Severity: Warning,
+						Device:   d.Name,
+						Peer:     p.PublicKey,
+						Message:  fmt.Sprintf("allowed IPs include default route %s, but no firewall mark is set", &ipn),
+					})
+				}
+			}
+		}
+
+		return fs
+	},
+}
+
+// KeepaliveBothEnds reports tunnels with persistent keepalives enabled on both
+// ends.
+var KeepaliveBothEnds = Rule{
+	Name: "keepalive-both-ends",
+	Description: "Persistent keepalives are only needed on the end of a tunnel behind " +
+		"NAT or a stateful firewall. Enabling them on both ends doubles the idle " +
+		"traffic and usually indicates a copied configuration.",
+	Check: func(devices []Device) []Finding {
+		var fs []Finding
+		forEachTunnel(devices, func(a, b *Device, ap, bp *wgtypes.PeerConfig) {
+			if keepalive(ap) && keepalive(bp) {
+				fs = append(fs, Finding{
+					Severity: Hint,
+					Device:   a.Name,
+					Peer:     ap.PublicKey,
+					Message:  fmt.Sprintf("persistent keepalive is enabled on both ends of the tunnel with %s", b.Name),
+				})
+			}
+		})
+
+		return fs
+	},
+}
+
+// MissingPresharedKey reports peers without a preshared key.
+var MissingPresharedKey = Rule{
+	Name: "missing-preshared-key",
+	Description: "A preshared key adds a layer of symmetric encryption which protects " +
+		"recorded traffic against future attacks on the public key cryptography.",
+	Check: func(devices []Device) []Finding {
+		var fs []Finding
+		for _, d := range devices {
+			for _, p := range d.Config.Peers {
+				if p.Remove || (p.PresharedKey != nil && *p.PresharedKey != (wgtypes.Key{})) {
+					continue
+				}
+
+				fs = append(fs, Finding{
+					Severity: Hint,
+					Device:   d.Name,
+					Peer:     p.PublicKey,
+					Message:  "no preshared key is configured",
+				})
+			}
+		}
+
+		return fs
+	},
+}
+
+// MTUMismatch reports tunnels whose ends have different MTUs.
+var MTUMismatch = Rule{
+	Name: "mtu-mismatch",
+	Description: "When the ends of a tunnel have different MTUs, packets which fit the " +
+		"larger MTU are dropped by the other end, which typically breaks large " +
+		"transfers while pings and handshakes still succeed.",
+	Check: func(devices []Device) []Finding {
+		var fs []Finding
+		forEachTunnel(devices, func(a, b *Device, ap, _ *wgtypes.PeerConfig) {
+			if a.MTU == 0 || b.MTU == 0 || a.MTU == b.MTU {
+				return
+			}
+
+			fs = append(fs, Finding{
+				Severity: Hint,
+				Device:   a.Name,
+				Peer:     ap.PublicKey,
+				Message:  fmt.Sprintf("MTU %d differs from MTU %d of %s", a.MTU, b.MTU, b.Name),
+			})
+		})
+
+		return fs
+	},
+}
+
+// DuplicateEndpoints reports peers of a device which share an endpoint.
+var DuplicateEndpoints = Rule{
+	Name: "duplicate-endpoints",
+	Description: "Two peers of a device with the same endpoint are usually the same host " +
+		"configured twice, for example after a key rotation which added the new key " +
+		"without removing the old one.",
+	Check: func(devices []Device) []Finding {
+		var fs []Finding
+		for _, d := range devices {
+			seen := make(map[string]wgtypes.Key)
+			for _, p := range d.Config.Peers {
+				if p.Remove || p.Endpoint == nil {
+					continue
+				}
+
+				ep := p.Endpoint.String()
+				other, ok := seen[ep]
+				if !ok {
+					seen[ep] = p.PublicKey
+					continue
+				}
+
+				fs = append(fs, Finding{
+					Severity: Warning,
+					Device:   d.Name,
+					Peer:     p.PublicKey,
+					Message:  fmt.Sprintf("endpoint %s is also used by peer %s", ep, other),
+				})
+			}
+		}
+
+		return fs
+	},
+}
+
+// forEachTunnel calls fn for each pair of devices which are configured as
+// peers of each other, once from the perspective of each end. ap is a's
+// configuration of b, and bp is b's configuration of a.
+func forEachTunnel(devices []Device, fn func(a, b *Device, ap, bp *wgtypes.PeerConfig)) {
+	for i := range devices {
+		a := &devices[i]
+		ak, ok := a.publicKey()
+		if !ok {
+			continue
+		}
+
+		for j := range devices {
+			b := &devices[j]
+			bk, ok := b.publicKey()
+			if i == j || !ok {
+				continue
+			}
+
+			ap, bp := findPeer(a, bk), findPeer(b, ak)
+			if ap == nil || bp == nil {
+				continue
+			}
+
+			fn(a, b, ap, bp)
+		}
+	}
+}
+
+// findPeer returns d's configuration of the peer with public key k, or nil
+// if it has none.
+func findPeer(d *Device, k wgtypes.Key) *wgtypes.PeerConfig {
+	for i := range d.Config.Peers {
+		if p := &d.Config.Peers[i]; p.PublicKey == k && !p.Remove {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// keepalive reports whether p enables persistent keepalives.
+func keepalive(p *wgtypes.PeerConfig) bool {
+	return p.PersistentKeepaliveInterval != nil && *p.PersistentKeepaliveInterval > 0
+}
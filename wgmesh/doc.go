@@ -0,0 +1,18 @@
+// Package wgmesh implements a minimal peer-exchange protocol which lets the
+// members of a WireGuard mesh discover each other, as a building block for
+// self-assembling meshes.
+//
+// Each member runs a Node, which periodically sends an Advertisement of its
+// own public key, endpoints, and allowed IPs, along with the Advertisements
+// it has learned from others, to each peer of its device over the tunnel
+// itself. Advertisements are sent over UDP to Port at the first allowed IP
+// of each peer, which must be the peer's own tunnel address. A Node adds
+// each member it learns of as a peer of its device, so that one working
+// tunnel to any member is enough to join the whole mesh.
+//
+// Messages are authenticated using HMAC-SHA256 with a secret shared by all
+// members, and Advertisements older than MaxAge are discarded. Any holder of
+// the secret may advertise any peer, so the secret must only be given to
+// trusted members. Peers are never removed by a Node; an Advertisement which
+// expires simply stops being refreshed.
+package wgmesh // import "golang.zx2c4.com/wireguard/wgctrl/wgmesh"
@@ -0,0 +1,404 @@
+package wgmesh
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Client configures devices, such as *wgctrl.Client.
+type Client interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// Defaults used by a Node.
+const (
+	DefaultPort     = 51821
+	DefaultInterval = 30 * time.Second
+	DefaultMaxAge   = 10 * time.Minute
+)
+
+// macPrefix is prepended to each message when computing its MAC, so that
+// the shared secret cannot be used to forge any other kind of message.
+const macPrefix = "wgmesh v1\n"
+
+// advertsPerMessage limits the number of Advertisements in each message, so
+// that messages fit in a single datagram.
+const advertsPerMessage = 8
+
+// An Advertisement describes a member of the mesh.
+type Advertisement struct {
+	PublicKey wgtypes.Key `json:"publicKey"`
+
+	// Endpoints are the addresses at which the member can be reached, such
+	// as "192.0.2.1:51820".
+	Endpoints []string `json:"endpoints,omitempty"`
+
+	// AllowedIPs are the member's allowed IPs. The first must be the
+	// member's tunnel address, at which its Node listens.
+	AllowedIPs []string `json:"allowedIPs"`
+
+	// Time is when the member created the Advertisement.
+	Time time.Time `json:"time"`
+}
+
+// A message is the authenticated form of a set of Advertisements.
+type message struct {
+	Payload []byte `json:"payload"`
+	MAC     []byte `json:"mac"`
+}
+
+// A Node exchanges Advertisements with the other members of a mesh, and
+// configures a device with the members it learns of.
+type Node struct {
+	// Device is the name of the member's WireGuard device.
+	Device string
+
+	// Self describes this member. Its PublicKey and AllowedIPs must be set;
+	// Time is set when it is sent.
+	Self Advertisement
+
+	// Secret authenticates messages, and must be shared by all members.
+	Secret []byte
+
+	// Conn sends and receives messages. It should be bound to Port on the
+	// member's tunnel address, so that it is only reachable through the
+	// tunnel.
+	Conn net.PacketConn
+
+	// Port is the port on which every member listens. If zero, DefaultPort
+	// is used.
+	Port int
+
+	// Interval is the interval between rounds of advertisement. If zero,
+	// DefaultInterval is used.
+	Interval time.Duration
+
+	// MaxAge is the age after which an Advertisement is discarded. If zero,
+	// DefaultMaxAge is used.
+	MaxAge time.Duration
+
+	mu    sync.Mutex
+	known map[wgtypes.Key]Advertisement
+}
+
+// Run receives Advertisements, and every interval configures the device with
+// the members learned so far and advertises to each of its peers, until ctx
+// is canceled.
+func (n *Node) Run(ctx context.Context, c Client) error {
+	if len(n.Secret) == 0 {
+		return errors.New("wgmesh: Node has no Secret")
+	}
+
+	errC := make(chan error, 1)
+	go func() { errC <- n.receive() }()
+	defer func() {
+		// Interrupt receive and wait for it to return.
+		_ = n.Conn.SetReadDeadline(time.Unix(1, 0))
+		<-errC
+	}()
+
+	interval := n.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := n.round(c, time.Now()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errC:
+			errC <- nil
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+// round configures the device with the known members and advertises to each
+// of its peers at time now.
+func (n *Node) round(c Client, now time.Time) error {
+	if err := n.Reconcile(c, now); err != nil {
+		return err
+	}
+
+	d, err := c.Device(n.Device)
+	if err != nil {
+		return err
+	}
+
+	msgs, err := n.messages(now)
+	if err != nil {
+		return err
+	}
+
+	port := n.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+
+	for _, p := range d.Peers {
+		if len(p.AllowedIPs) == 0 {
+			continue
+		}
+
+		addr := &net.UDPAddr{IP: p.AllowedIPs[0].IP, Port: port}
+		for _, m := range msgs {
+			// Peers which are unreachable are retried in the next round.
+			_, _ = n.Conn.WriteTo(m, addr)
+		}
+	}
+
+	return nil
+}
+
+// Reconcile adds each known member which is not yet a peer of the device, and
+// replaces the allowed IPs of any member whose advertised allowed IPs have
+// changed, as of time now. Endpoints are only set for new peers, so that
+// WireGuard's roaming is not overridden.
+func (n *Node) Reconcile(c Client, now time.Time) error {
+	d, err := c.Device(n.Device)
+	if err != nil {
+		return err
+	}
+
+	current := make(map[wgtypes.Key]*wgtypes.Peer, len(d.Peers))
+	for i := range d.Peers {
+		current[d.Peers[i].PublicKey] = &d.Peers[i]
+	}
+
+	var peers []wgtypes.PeerConfig
+	for _, a := range n.Advertisements(now) {
+		if a.PublicKey == n.Self.PublicKey || a.PublicKey == d.PublicKey {
+			continue
+		}
+
+		ips, err := parseAllowedIPs(a.AllowedIPs)
+		if err != nil {
+			// Authenticated, but unusable.
+			continue
+		}
+
+		pc := wgtypes.PeerConfig{
+			PublicKey:         a.PublicKey,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        ips,
+		}
+
+		p, ok := current[a.PublicKey]
+		switch {
+		case !ok:
+			for _, ep := range a.Endpoints {
+				if addr, err := net.ResolveUDPAddr("udp", ep); err == nil {
+					pc.Endpoint = addr
+					break
+				}
+			}
+		case sameIPNets(p.AllowedIPs, ips):
+			continue
+		}
+
+		peers = append(peers, pc)
+	}
+
+	if len(peers) == 0 {
+		return nil
+	}
+
+	return c.ConfigureDevice(n.Device, wgtypes.Config{Peers: peers})
+}
+
+// Advertisements returns the Advertisements which have not expired at time
+// now, ordered by public key.
+func (n *Node) Advertisements(now time.Time) []Advertisement {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	out := make([]Advertisement, 0, len(n.known))
+	for k, a := range n.known {
+		if n.expired(a, now) {
+			delete(n.known, k)
+			continue
+		}
+
+		out = append(out, a)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return bytes.Compare(out[i].PublicKey[:], out[j].PublicKey[:]) < 0
+	})
+
+	return out
+}
+
+// receive handles messages until the connection fails.
+func (n *Node) receive() error {
+	b := make([]byte, 65535)
+	for {
+		m, _, err := n.Conn.ReadFrom(b)
+		if err != nil {
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				return nil
+			}
+
+			return fmt.Errorf("wgmesh: failed to receive: %w", err)
+		}
+
+		// Messages which cannot be authenticated are ignored.
+		_ = n.handle(b[:m], time.Now())
+	}
+}
+
+// handle authenticates a message and records its Advertisements at time now.
+func (n *Node) handle(b []byte, now time.Time) error {
+	var m message
+	if err := json.Unmarshal(b, &m); err != nil {
+		return fmt.Errorf("wgmesh: invalid message: %v", err)
+	}
+
+	if !hmac.Equal(m.MAC, n.mac(m.Payload)) {
+		return errors.New("wgmesh: message authentication failed")
+	}
+
+	var ads []Advertisement
+	if err := json.Unmarshal(m.Payload, &ads); err != nil {
+		return fmt.Errorf("wgmesh: invalid advertisements: %v", err)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.known == nil {
+		n.known = make(map[wgtypes.Key]Advertisement)
+	}
+
+	for _, a := range ads {
+		// Only newer Advertisements replace older ones, and those from the
+		// future are not trusted to expire.
+		if n.expired(a, now) || a.Time.After(now.Add(n.maxAge())) {
+			continue
+		}
+		if prev, ok := n.known[a.PublicKey]; ok && !a.Time.After(prev.Time) {
+			continue
+		}
+
+		n.known[a.PublicKey] = a
+	}
+
+	return nil
+}
+
+// messages encodes this member's Advertisement and the known Advertisements
+// as authenticated messages at time now.
+func (n *Node) messages(now time.Time) ([][]byte, error) {
+	self := n.Self
+	self.Time = now
+
+	ads := []Advertisement{self}
+	for _, a := range n.Advertisements(now) {
+		if a.PublicKey != self.PublicKey {
+			ads = append(ads, a)
+		}
+	}
+
+	var msgs [][]byte
+	for len(ads) > 0 {
+		k := advertsPerMessage
+		if k > len(ads) {
+			k = len(ads)
+		}
+
+		payload, err := json.Marshal(ads[:k])
+		if err != nil {
+			return nil, fmt.Errorf("wgmesh: failed to encode advertisements: %v", err)
+		}
+
+		b, err := json.Marshal(message{Payload: payload, MAC: n.mac(payload)})
+		if err != nil {
+			return nil, fmt.Errorf("wgmesh: failed to encode message: %v", err)
+		}
+
+		msgs = append(msgs, b)
+		ads = ads[k:]
+	}
+
+	return msgs, nil
+}
+
+// mac computes the MAC of payload.
+func (n *Node) mac(payload []byte) []byte {
+	h := hmac.New(sha256.New, n.Secret)
+	h.Write([]byte(macPrefix))
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// expired reports whether a has expired at time now.
+func (n *Node) expired(a Advertisement, now time.Time) bool {
+	return now.Sub(a.Time) > n.maxAge()
+}
+
+// maxAge returns the maximum age of an Advertisement.
+func (n *Node) maxAge() time.Duration {
+	if n.MaxAge == 0 {
+		return DefaultMaxAge
+	}
+
+	return n.MaxAge
+}
+
+// parseAllowedIPs parses a list of prefixes in CIDR notation.
+func parseAllowedIPs(ss []string) ([]net.IPNet, error) {
+	ipns := make([]net.IPNet, 0, len(ss))
+	for _, s := range ss {
+		_, ipn, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+
+		ipns = append(ipns, *ipn)
+	}
+
+	return ipns, nil
+}
+
+// sameIPNets reports whether a and b contain the same prefixes in any order.
+func sameIPNets(a, b []net.IPNet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[string]int, len(a))
+	for i := range a {
+		seen[a[i].String()]++
+	}
+	for i := range b {
+		seen[b[i].String()]--
+	}
+	for _, v := range seen {
+		if v != 0 {
+			return false
+		}
+	}
+
+	return true
+}
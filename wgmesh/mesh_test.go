@@ -0,0 +1,217 @@
+package wgmesh
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestNodeExchange(t *testing.T) {
+	var (
+		a      = wgtest.MustPublicKey()
+		b      = wgtest.MustPublicKey()
+		secret = []byte("mesh secret")
+		now    = time.Unix(1600000000, 0)
+	)
+
+	na := &Node{
+		Device: "wg0",
+		Secret: secret,
+		Self: Advertisement{
+			PublicKey:  a,
+			Endpoints:  []string{"192.0.2.1:51820"},
+			AllowedIPs: []string{"10.0.0.1/32"},
+		},
+	}
+
+	nb := &Node{
+		Device: "wg0",
+		Secret: secret,
+		Self:   Advertisement{PublicKey: b, AllowedIPs: []string{"10.0.0.2/32"}},
+	}
+
+	msgs, err := na.messages(now)
+	if err != nil {
+		t.Fatalf("failed to encode messages: %v", err)
+	}
+
+	for _, m := range msgs {
+		if err := nb.handle(m, now.Add(time.Second)); err != nil {
+			t.Fatalf("failed to handle message: %v", err)
+		}
+	}
+
+	c := &testClient{d: &wgtypes.Device{Name: "wg0", PublicKey: b}}
+	if err := nb.Reconcile(c, now.Add(time.Second)); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+
+	want := []wgtypes.Config{{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:         a,
+			Endpoint:          wgtest.MustUDPAddr("192.0.2.1:51820"),
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")},
+		}},
+	}}
+
+	if diff := cmp.Diff(want, c.applied); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+
+	// Once the member is a peer with the advertised allowed IPs, nothing
+	// more is configured.
+	c.d.Peers = []wgtypes.Peer{{
+		PublicKey:  a,
+		AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")},
+	}}
+	c.applied = nil
+
+	if err := nb.Reconcile(c, now.Add(time.Second)); err != nil {
+		t.Fatalf("failed to reconcile: %v", err)
+	}
+	if len(c.applied) != 0 {
+		t.Fatalf("unexpected configurations: %d", len(c.applied))
+	}
+
+	// The Advertisement expires once it is older than MaxAge.
+	if ads := nb.Advertisements(now.Add(DefaultMaxAge + time.Second)); len(ads) != 0 {
+		t.Fatalf("unexpected advertisements: %d", len(ads))
+	}
+}
+
+func TestNodeHandleRejected(t *testing.T) {
+	var (
+		now = time.Unix(1600000000, 0)
+		ad  = Advertisement{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []string{"10.0.0.1/32"},
+		}
+	)
+
+	sender := &Node{Secret: []byte("mesh secret"), Self: ad}
+	msgs, err := sender.messages(now)
+	if err != nil {
+		t.Fatalf("failed to encode messages: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		n    *Node
+		now  time.Time
+		ok   bool
+	}{
+		{
+			name: "wrong secret",
+			n:    &Node{Secret: []byte("other secret")},
+			now:  now,
+		},
+		{
+			name: "expired",
+			n:    &Node{Secret: []byte("mesh secret")},
+			now:  now.Add(DefaultMaxAge + time.Second),
+			ok:   true,
+		},
+		{
+			name: "future",
+			n:    &Node{Secret: []byte("mesh secret")},
+			now:  now.Add(-DefaultMaxAge - time.Second),
+			ok:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.n.handle(msgs[0], tt.now)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to handle message: %v", err)
+			}
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+			}
+
+			if ads := tt.n.Advertisements(tt.now); len(ads) != 0 {
+				t.Fatalf("unexpected advertisements: %d", len(ads))
+			}
+		})
+	}
+}
+
+func TestNodeRound(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("failed to listen: %v", err)
+	}
+	defer pc.Close()
+
+	recv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("failed to listen: %v", err)
+	}
+	defer recv.Close()
+
+	var (
+		self   = wgtest.MustPublicKey()
+		secret = []byte("mesh secret")
+		now    = time.Now()
+	)
+
+	n := &Node{
+		Device: "wg0",
+		Secret: secret,
+		Conn:   pc,
+		Port:   recv.LocalAddr().(*net.UDPAddr).Port,
+		Self:   Advertisement{PublicKey: self, AllowedIPs: []string{"10.0.0.1/32"}},
+	}
+
+	// The peer's tunnel address is loopback, so the round is received by
+	// recv.
+	c := &testClient{d: &wgtypes.Device{
+		Name: "wg0",
+		Peers: []wgtypes.Peer{{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []net.IPNet{wgtest.MustCIDR("127.0.0.1/32")},
+		}},
+	}}
+
+	if err := n.round(c, now); err != nil {
+		t.Fatalf("failed to advertise: %v", err)
+	}
+
+	b := make([]byte, 65535)
+	_ = recv.SetReadDeadline(time.Now().Add(5 * time.Second))
+	m, _, err := recv.ReadFrom(b)
+	if err != nil {
+		t.Fatalf("failed to receive: %v", err)
+	}
+
+	peer := &Node{Secret: secret}
+	if err := peer.handle(b[:m], now); err != nil {
+		t.Fatalf("failed to handle message: %v", err)
+	}
+
+	ads := peer.Advertisements(now)
+	if len(ads) != 1 || ads[0].PublicKey != self {
+		t.Fatalf("unexpected advertisements: %v", ads)
+	}
+}
+
+type testClient struct {
+	d       *wgtypes.Device
+	applied []wgtypes.Config
+}
+
+func (c *testClient) Device(_ string) (*wgtypes.Device, error) { return c.d, nil }
+
+func (c *testClient) ConfigureDevice(_ string, cfg wgtypes.Config) error {
+	c.applied = append(c.applied, cfg)
+	return nil
+}
@@ -0,0 +1,33 @@
+//go:build linux
+// +build linux
+
+package wgmtu
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// dontFragment sets the don't fragment bit on packets sent by c. Probe mode
+// ignores path MTUs learned earlier, so that each probe is sent as is.
+func dontFragment(c *net.UDPConn, ipv6 bool) error {
+	rc, err := c.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	level, opt, val := unix.IPPROTO_IP, unix.IP_MTU_DISCOVER, unix.IP_PMTUDISC_PROBE
+	if ipv6 {
+		level, opt, val = unix.IPPROTO_IPV6, unix.IPV6_MTU_DISCOVER, unix.IPV6_PMTUDISC_PROBE
+	}
+
+	var serr error
+	if err := rc.Control(func(fd uintptr) {
+		serr = unix.SetsockoptInt(int(fd), level, opt, val)
+	}); err != nil {
+		return err
+	}
+
+	return serr
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package wgmtu
+
+import (
+	"errors"
+	"net"
+)
+
+// dontFragment reports that setting the don't fragment bit is not supported
+// on this platform.
+func dontFragment(_ *net.UDPConn, _ bool) error {
+	return errors.New("wgmtu: setting the don't fragment bit is not supported on this platform")
+}
@@ -0,0 +1,14 @@
+// Package wgmtu helps troubleshoot MTU problems on WireGuard tunnels, which
+// typically show up as connections which stall once they begin to transfer
+// data, because large packets are silently dropped along the path.
+//
+// Discover measures the path MTU by sending probes of decreasing and
+// increasing size which must not be fragmented, and Recommend turns the
+// result into an interface MTU, such as the MTU key used by wg-quick(8), and
+// the TCP MSS values to clamp forwarded connections to.
+//
+// UDPProber sends probes with the don't fragment bit set to a peer running
+// Echo. Probes sent to a peer's tunnel address measure the tunnel itself,
+// while probes sent to its endpoint measure the underlying path, which must
+// also fit WireGuard's encapsulation overhead.
+package wgmtu // import "golang.zx2c4.com/wireguard/wgctrl/wgmtu"
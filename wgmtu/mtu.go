@@ -0,0 +1,90 @@
+package wgmtu
+
+import (
+	"context"
+	"fmt"
+)
+
+// Encapsulation overhead added by WireGuard to each packet, including the
+// outer IP and UDP headers.
+const (
+	OverheadIPv4 = 20 + 8 + 32
+	OverheadIPv6 = 40 + 8 + 32
+)
+
+// A Prober sends probes of a given size along a path.
+type Prober interface {
+	// Probe reports whether an IP packet of size bytes, which must not be
+	// fragmented, crossed the path. A probe which is lost is not an error.
+	Probe(ctx context.Context, size int) (bool, error)
+}
+
+// Discover returns the path MTU measured by p: the largest size between min
+// and max, inclusive, for which a probe succeeds. Sizes are found by binary
+// search, so sizes above the path MTU must consistently fail. If a probe of
+// min bytes fails, an error is returned.
+//
+// Typical values for min and max are 1280, the minimum MTU of IPv6, and the
+// MTU of the interface which the probes are sent through.
+func Discover(ctx context.Context, p Prober, min, max int) (int, error) {
+	if min <= 0 || min > max {
+		return 0, fmt.Errorf("wgmtu: invalid probe size range %d-%d", min, max)
+	}
+
+	ok, err := p.Probe(ctx, min)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("wgmtu: no probe of %d bytes crossed the path", min)
+	}
+
+	// Invariant: lo is known to succeed, and sizes above hi are unknown or
+	// known to fail.
+	lo, hi := min, max
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+
+		ok, err := p.Probe(ctx, mid)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+
+	return lo, nil
+}
+
+// A Recommendation contains settings derived from a path MTU.
+type Recommendation struct {
+	// PathMTU is the measured path MTU.
+	PathMTU int
+
+	// MTU is the recommended MTU of the WireGuard interface.
+	MTU int
+
+	// MSS4 and MSS6 are the TCP MSS values which connections through the
+	// interface should be clamped to, for IPv4 and IPv6 respectively.
+	MSS4, MSS6 int
+}
+
+// Recommend produces a Recommendation from the path MTU pathMTU. If the path
+// was measured through the tunnel, overhead is 0. If it was measured outside
+// of the tunnel, between the endpoints of two peers, overhead is the
+// encapsulation overhead: OverheadIPv4 or OverheadIPv6 depending on the
+// address family of the endpoints, or OverheadIPv6 if it may be either.
+func Recommend(pathMTU, overhead int) Recommendation {
+	mtu := pathMTU - overhead
+
+	return Recommendation{
+		PathMTU: pathMTU,
+		MTU:     mtu,
+		MSS4:    mtu - 20 - 20,
+		MSS6:    mtu - 40 - 20,
+	}
+}
@@ -0,0 +1,165 @@
+package wgmtu
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// A fakeProber is a Prober for a path with a fixed MTU.
+type fakeProber struct {
+	mtu    int
+	probes int
+}
+
+func (p *fakeProber) Probe(_ context.Context, size int) (bool, error) {
+	p.probes++
+	return size <= p.mtu, nil
+}
+
+func TestDiscover(t *testing.T) {
+	tests := []struct {
+		name     string
+		mtu      int
+		min, max int
+		ok       bool
+		want     int
+	}{
+		{
+			name: "ethernet",
+			mtu:  1500,
+			min:  1280,
+			max:  1500,
+			ok:   true,
+			want: 1500,
+		},
+		{
+			name: "PPPoE",
+			mtu:  1492,
+			min:  1280,
+			max:  1500,
+			ok:   true,
+			want: 1492,
+		},
+		{
+			name: "minimum",
+			mtu:  1280,
+			min:  1280,
+			max:  1500,
+			ok:   true,
+			want: 1280,
+		},
+		{
+			name: "below minimum",
+			mtu:  1000,
+			min:  1280,
+			max:  1500,
+		},
+		{
+			name: "bad range",
+			mtu:  1500,
+			min:  1500,
+			max:  1280,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &fakeProber{mtu: tt.mtu}
+			got, err := Discover(context.Background(), p, tt.min, tt.max)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to discover: %v", err)
+			}
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+				return
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected path MTU (-want +got):\n%s", diff)
+			}
+
+			// One probe of min, and a binary search of the remaining range.
+			if p.probes > 10 {
+				t.Fatalf("too many probes: %d", p.probes)
+			}
+		})
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	tests := []struct {
+		name     string
+		pmtu     int
+		overhead int
+		want     Recommendation
+	}{
+		{
+			name: "tunnel",
+			pmtu: 1420,
+			want: Recommendation{PathMTU: 1420, MTU: 1420, MSS4: 1380, MSS6: 1360},
+		},
+		{
+			name:     "IPv4 underlay",
+			pmtu:     1500,
+			overhead: OverheadIPv4,
+			want:     Recommendation{PathMTU: 1500, MTU: 1440, MSS4: 1400, MSS6: 1380},
+		},
+		{
+			name:     "IPv6 underlay",
+			pmtu:     1500,
+			overhead: OverheadIPv6,
+			want:     Recommendation{PathMTU: 1500, MTU: 1420, MSS4: 1380, MSS6: 1360},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, Recommend(tt.pmtu, tt.overhead)); diff != "" {
+				t.Fatalf("unexpected Recommendation (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUDPProber(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("skipping, the don't fragment bit can only be set on Linux")
+	}
+
+	srv, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer srv.Close()
+
+	go func() { _ = Echo(srv) }()
+
+	p, err := DialUDP(srv.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer p.Close()
+	p.Timeout = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Loopback has a much larger MTU than the probed range.
+	got, err := Discover(ctx, p, 1280, 1500)
+	if err != nil {
+		t.Fatalf("failed to discover: %v", err)
+	}
+
+	if diff := cmp.Diff(1500, got); diff != "" {
+		t.Fatalf("unexpected path MTU (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,164 @@
+package wgmtu
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Size of the IP and UDP headers of a probe.
+const (
+	headerIPv4 = 20 + 8
+	headerIPv6 = 40 + 8
+)
+
+// tokenLen is the length of the token which identifies each probe.
+const tokenLen = 16
+
+// A UDPProber is a Prober which sends UDP probes with the don't fragment bit
+// set to a peer running Echo. Only the acknowledgement of each probe is sent
+// back, so that the path is measured in one direction.
+type UDPProber struct {
+	// Timeout is the time to wait for each probe to be acknowledged. If
+	// zero, one second is used.
+	Timeout time.Duration
+
+	// Attempts is the number of probes of each size to send before the size
+	// is considered not to cross the path. If zero, 3 is used.
+	Attempts int
+
+	c      *net.UDPConn
+	header int
+}
+
+// DialUDP creates a UDPProber which sends probes to addr, in host:port form.
+// Setting the don't fragment bit is only supported on Linux.
+func DialUDP(addr string) (*UDPProber, error) {
+	raddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("wgmtu: failed to resolve address: %v", err)
+	}
+
+	c, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil, fmt.Errorf("wgmtu: failed to dial: %v", err)
+	}
+
+	ipv6 := raddr.IP.To4() == nil
+	if err := dontFragment(c, ipv6); err != nil {
+		_ = c.Close()
+		return nil, err
+	}
+
+	header := headerIPv4
+	if ipv6 {
+		header = headerIPv6
+	}
+
+	return &UDPProber{c: c, header: header}, nil
+}
+
+// Close releases the UDPProber's socket.
+func (p *UDPProber) Close() error {
+	return p.c.Close()
+}
+
+// Probe implements Prober.
+func (p *UDPProber) Probe(ctx context.Context, size int) (bool, error) {
+	if size < p.header+tokenLen {
+		return false, fmt.Errorf("wgmtu: probe size %d is too small", size)
+	}
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = time.Second
+	}
+
+	attempts := p.Attempts
+	if attempts == 0 {
+		attempts = 3
+	}
+
+	b := make([]byte, size-p.header)
+	ack := make([]byte, tokenLen)
+
+	for i := 0; i < attempts; i++ {
+		if _, err := rand.Read(b[:tokenLen]); err != nil {
+			return false, fmt.Errorf("wgmtu: failed to generate probe token: %v", err)
+		}
+
+		if _, err := p.c.Write(b); err != nil {
+			// The probe does not fit the MTU of the local interface, or one
+			// reported by an earlier ICMP message.
+			if errors.Is(err, syscall.EMSGSIZE) {
+				return false, nil
+			}
+
+			return false, fmt.Errorf("wgmtu: failed to send probe: %v", err)
+		}
+
+		deadline := time.Now().Add(timeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		if err := p.c.SetReadDeadline(deadline); err != nil {
+			return false, err
+		}
+
+		for {
+			n, err := p.c.Read(ack)
+			if err != nil {
+				var nerr net.Error
+				if errors.As(err, &nerr) && nerr.Timeout() {
+					break
+				}
+
+				// ICMP errors reported for earlier probes surface here, and
+				// mean this probe may still be acknowledged.
+				if errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ECONNREFUSED) {
+					continue
+				}
+
+				return false, fmt.Errorf("wgmtu: failed to read acknowledgement: %v", err)
+			}
+
+			// Ignore late acknowledgements of earlier probes.
+			if n == tokenLen && string(ack) == string(b[:tokenLen]) {
+				return true, nil
+			}
+		}
+
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// Echo acknowledges the probes sent by UDPProbers to c, until c is closed.
+func Echo(c net.PacketConn) error {
+	b := make([]byte, 65536)
+	for {
+		n, addr, err := c.ReadFrom(b)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+		if n < tokenLen {
+			continue
+		}
+
+		// Failing to acknowledge one probe only fails that probe.
+		if _, err := c.WriteTo(b[:tokenLen], addr); errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Package wgnft generates and applies the nftables rules commonly used
+// alongside a WireGuard device on a Linux gateway: accepting the device's
+// listen port, clamping the TCP MSS of forwarded traffic to the path MTU, and
+// masquerading tunnel traffic which leaves through another interface.
+//
+// The rules for each device are kept in their own table, so that they can be
+// replaced or removed atomically without disturbing other rules. Because an
+// accept verdict in one nftables table does not override a drop verdict in
+// another, hosts whose own rules drop inbound traffic must still permit the
+// listen port there.
+//
+// Apply and Teardown run the nft(8) command, which must be installed.
+package wgnft // import "golang.zx2c4.com/wireguard/wgctrl/wgnft"
@@ -0,0 +1,161 @@
+package wgnft
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Rules describes the nftables rules installed for a WireGuard device.
+type Rules struct {
+	// Device is the name of the WireGuard device.
+	Device string
+
+	// ListenPort, if not 0, is the UDP port on which inbound traffic is
+	// accepted.
+	ListenPort int
+
+	// Masquerade, if set, is the name of the interface through which traffic
+	// arriving from the device is forwarded and masqueraded.
+	Masquerade string
+
+	// ClampMSS clamps the TCP MSS of connections forwarded through the
+	// device to the path MTU.
+	ClampMSS bool
+}
+
+// Table returns the name of the nftables table which holds the rules for the
+// device specified by name.
+func Table(name string) string {
+	return "wgctrl-" + name
+}
+
+// Script returns the nft(8) script which atomically replaces any rules
+// previously installed for r.Device with r.
+func (r Rules) Script() (string, error) {
+	if err := checkInterface(r.Device); err != nil {
+		return "", err
+	}
+	if r.ListenPort < 0 || r.ListenPort > 65535 {
+		return "", fmt.Errorf("wgnft: invalid listen port: %d", r.ListenPort)
+	}
+	if r.Masquerade != "" {
+		if err := checkInterface(r.Masquerade); err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(teardown(r.Device))
+
+	fmt.Fprintf(&b, "table inet %s {\n", Table(r.Device))
+
+	if r.ListenPort != 0 {
+		b.WriteString("\tchain input {\n")
+		b.WriteString("\t\ttype filter hook input priority 0; policy accept;\n")
+		fmt.Fprintf(&b, "\t\tudp dport %d accept\n", r.ListenPort)
+		b.WriteString("\t}\n")
+	}
+
+	if r.ClampMSS {
+		b.WriteString("\tchain forward {\n")
+		b.WriteString("\t\ttype filter hook forward priority -150; policy accept;\n")
+		fmt.Fprintf(&b, "\t\tiifname %q tcp flags syn tcp option maxseg size set rt mtu\n", r.Device)
+		fmt.Fprintf(&b, "\t\toifname %q tcp flags syn tcp option maxseg size set rt mtu\n", r.Device)
+		b.WriteString("\t}\n")
+	}
+
+	if r.Masquerade != "" {
+		b.WriteString("\tchain postrouting {\n")
+		b.WriteString("\t\ttype nat hook postrouting priority 100; policy accept;\n")
+		fmt.Fprintf(&b, "\t\tiifname %q oifname %q masquerade\n", r.Device, r.Masquerade)
+		b.WriteString("\t}\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// TeardownScript returns the nft(8) script which removes any rules installed
+// for the device specified by name.
+func TeardownScript(name string) (string, error) {
+	if err := checkInterface(name); err != nil {
+		return "", err
+	}
+
+	return teardown(name), nil
+}
+
+// teardown returns script which removes the table for the device name.
+// Declaring the table first means the deletion succeeds even if the table
+// does not exist.
+func teardown(name string) string {
+	return fmt.Sprintf("table inet %[1]s\ndelete table inet %[1]s\n", Table(name))
+}
+
+// Apply installs r, replacing any rules previously installed for r.Device.
+func Apply(ctx context.Context, r Rules) error {
+	s, err := r.Script()
+	if err != nil {
+		return err
+	}
+
+	return run(ctx, s)
+}
+
+// Teardown removes any rules installed for the device specified by name. It
+// is not an error if no rules are installed.
+func Teardown(ctx context.Context, name string) error {
+	s, err := TeardownScript(name)
+	if err != nil {
+		return err
+	}
+
+	return run(ctx, s)
+}
+
+// run applies script as a single nftables transaction.
+func run(ctx context.Context, script string) error {
+	cmd := exec.CommandContext(ctx, "nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := bytes.TrimSpace(out); len(msg) > 0 {
+			return fmt.Errorf("wgnft: nft failed: %v: %s", err, msg)
+		}
+
+		return fmt.Errorf("wgnft: nft failed: %w", err)
+	}
+
+	return nil
+}
+
+// checkInterface verifies that name is a valid Linux interface name which
+// consists only of letters, digits, '_', '.', and '-', so that it can be used
+// unquoted in the name of a table and safely quoted elsewhere in a script.
+func checkInterface(name string) error {
+	if name == "" {
+		return errors.New("wgnft: interface name must not be empty")
+	}
+	if len(name) > 15 {
+		return fmt.Errorf("wgnft: interface name %q is too long", name)
+	}
+	if name == "." || name == ".." {
+		return fmt.Errorf("wgnft: invalid interface name %q", name)
+	}
+
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '_', c == '.', c == '-':
+		default:
+			return fmt.Errorf("wgnft: invalid interface name %q", name)
+		}
+	}
+
+	return nil
+}
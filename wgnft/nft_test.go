@@ -0,0 +1,132 @@
+package wgnft_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgnft"
+)
+
+func TestRulesScript(t *testing.T) {
+	tests := []struct {
+		name string
+		r    wgnft.Rules
+		s    string
+	}{
+		{
+			name: "empty",
+			r:    wgnft.Rules{Device: "wg0"},
+			s: `table inet wgctrl-wg0
+delete table inet wgctrl-wg0
+table inet wgctrl-wg0 {
+}
+`,
+		},
+		{
+			name: "gateway",
+			r: wgnft.Rules{
+				Device:     "wg0",
+				ListenPort: 51820,
+				Masquerade: "eth0",
+				ClampMSS:   true,
+			},
+			s: `table inet wgctrl-wg0
+delete table inet wgctrl-wg0
+table inet wgctrl-wg0 {
+	chain input {
+		type filter hook input priority 0; policy accept;
+		udp dport 51820 accept
+	}
+	chain forward {
+		type filter hook forward priority -150; policy accept;
+		iifname "wg0" tcp flags syn tcp option maxseg size set rt mtu
+		oifname "wg0" tcp flags syn tcp option maxseg size set rt mtu
+	}
+	chain postrouting {
+		type nat hook postrouting priority 100; policy accept;
+		iifname "wg0" oifname "eth0" masquerade
+	}
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.r.Script()
+			if err != nil {
+				t.Fatalf("failed to generate script: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.s, s); diff != "" {
+				t.Fatalf("unexpected script (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRulesScriptErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		r    wgnft.Rules
+	}{
+		{
+			name: "no device",
+		},
+		{
+			name: "long device",
+			r:    wgnft.Rules{Device: "wireguard-tunnel0"},
+		},
+		{
+			name: "quoted device",
+			r:    wgnft.Rules{Device: `wg"0`},
+		},
+		{
+			name: "comment device",
+			r:    wgnft.Rules{Device: "wg#1"},
+		},
+		{
+			name: "statement device",
+			r:    wgnft.Rules{Device: "wg;flush"},
+		},
+		{
+			name: "bad port",
+			r:    wgnft.Rules{Device: "wg0", ListenPort: 65536},
+		},
+		{
+			name: "bad masquerade",
+			r:    wgnft.Rules{Device: "wg0", Masquerade: "eth 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.r.Script()
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func TestTeardownScript(t *testing.T) {
+	s, err := wgnft.TeardownScript("wg0")
+	if err != nil {
+		t.Fatalf("failed to generate script: %v", err)
+	}
+
+	want := "table inet wgctrl-wg0\ndelete table inet wgctrl-wg0\n"
+	if diff := cmp.Diff(want, s); diff != "" {
+		t.Fatalf("unexpected script (-want +got):\n%s", diff)
+	}
+
+	// The remainder of the name would be parsed as a comment, deleting the
+	// table of another interface.
+	for _, name := range []string{"wg#1", "wg{", ".."} {
+		if _, err := wgnft.TeardownScript(name); err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", name)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package wgnl exposes the generic netlink messages which package wgctrl
+// sends to configure the Linux kernel WireGuard implementation.
+//
+// EncodeConfig produces exactly the messages which wgctrl.Client's
+// ConfigureDevice would send for a configuration, so that advanced users can
+// inspect, log, or send them with their own connection, and Describe renders
+// them attribute by attribute, as shown by wgctrl.Client's
+// PlanConfigureDevice.
+//
+// Netlink is only available on Linux. On other platforms, each function
+// returns an error.
+package wgnl // import "golang.zx2c4.com/wireguard/wgctrl/wgnl"
@@ -0,0 +1,25 @@
+//go:build linux
+// +build linux
+
+package wgnl
+
+import (
+	"github.com/mdlayher/genetlink"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wglinux"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// EncodeConfig produces the generic netlink messages which configure the
+// device specified by name using cfg. Large configurations are split into
+// several messages, which must be sent in order to the WireGuard generic
+// netlink family with the Request and Acknowledge flags.
+func EncodeConfig(name string, cfg wgtypes.Config) ([]genetlink.Message, error) {
+	return wglinux.EncodeConfig(name, cfg)
+}
+
+// Describe describes a message produced by EncodeConfig: a summary line
+// followed by one line for each attribute, indented by nesting level. Private
+// and preshared keys are described as "(hidden)".
+func Describe(m genetlink.Message) (string, error) {
+	return wglinux.DescribeMessage(m)
+}
@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package wgnl_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgnl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestEncodeConfig(t *testing.T) {
+	// Enough peers to require more than one message.
+	cfg := wgtest.SyntheticConfig(64)
+	cfg.Peers[0].AllowedIPs = []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")}
+
+	msgs, err := wgnl.EncodeConfig("wg0", cfg)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	if len(msgs) < 2 {
+		t.Fatalf("expected multiple messages, but got %d", len(msgs))
+	}
+
+	s, err := wgnl.Describe(msgs[0])
+	if err != nil {
+		t.Fatalf("failed to describe: %v", err)
+	}
+
+	for _, want := range []string{
+		"netlink WG_CMD_SET_DEVICE",
+		`WGDEVICE_A_IFNAME: "wg0"`,
+		"WGPEER_A_PUBLIC_KEY: " + cfg.Peers[0].PublicKey.String(),
+		"WGALLOWEDIP_A_IPADDR: 10.0.0.1",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("description does not contain %q:\n%s", want, s)
+		}
+	}
+
+	_, err = wgnl.EncodeConfig("wg0", wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		AllowedIPs: []net.IPNet{{IP: net.IP{1}, Mask: net.CIDRMask(8, 32)}},
+	}}})
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	t.Logf("OK error: %v", err)
+}
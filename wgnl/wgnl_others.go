@@ -0,0 +1,27 @@
+//go:build !linux
+// +build !linux
+
+package wgnl
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/mdlayher/genetlink"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// EncodeConfig is not supported on this platform.
+func EncodeConfig(_ string, _ wgtypes.Config) ([]genetlink.Message, error) {
+	return nil, errUnsupported()
+}
+
+// Describe is not supported on this platform.
+func Describe(_ genetlink.Message) (string, error) {
+	return "", errUnsupported()
+}
+
+// errUnsupported returns an error for platforms without netlink.
+func errUnsupported() error {
+	return fmt.Errorf("wgnl: netlink is not supported on %s", runtime.GOOS)
+}
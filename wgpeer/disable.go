@@ -0,0 +1,226 @@
+package wgpeer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A DisabledStore stores the full configuration of peers which have been
+// disabled, so that they can later be enabled again.
+type DisabledStore interface {
+	// Put stores the configuration of a disabled peer on a device,
+	// replacing any existing configuration for the same peer.
+	Put(device string, cfg wgtypes.PeerConfig) error
+
+	// Get retrieves the configuration of a disabled peer on a device. If
+	// the peer is not disabled, an error compatible with os.ErrNotExist is
+	// returned.
+	Get(device string, peer wgtypes.Key) (*wgtypes.PeerConfig, error)
+
+	// Delete removes the configuration of a disabled peer on a device.
+	// Deleting a peer which is not disabled is not an error.
+	Delete(device string, peer wgtypes.Key) error
+
+	// List returns the configurations of all disabled peers on a device.
+	List(device string) ([]wgtypes.PeerConfig, error)
+}
+
+// A MemoryStore is an in-memory DisabledStore which is safe for concurrent
+// use. Its zero value is ready to use.
+type MemoryStore struct {
+	mu sync.Mutex
+	m  map[string]map[wgtypes.Key]wgtypes.PeerConfig
+}
+
+var _ DisabledStore = &MemoryStore{}
+
+// Put implements DisabledStore.
+func (s *MemoryStore) Put(device string, cfg wgtypes.PeerConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.m == nil {
+		s.m = make(map[string]map[wgtypes.Key]wgtypes.PeerConfig)
+	}
+	if s.m[device] == nil {
+		s.m[device] = make(map[wgtypes.Key]wgtypes.PeerConfig)
+	}
+
+	s.m[device][cfg.PublicKey] = cfg
+	return nil
+}
+
+// Get implements DisabledStore.
+func (s *MemoryStore) Get(device string, peer wgtypes.Key) (*wgtypes.PeerConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, ok := s.m[device][peer]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return &cfg, nil
+}
+
+// Delete implements DisabledStore.
+func (s *MemoryStore) Delete(device string, peer wgtypes.Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.m[device], peer)
+	return nil
+}
+
+// List implements DisabledStore.
+func (s *MemoryStore) List(device string) ([]wgtypes.PeerConfig, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfgs := make([]wgtypes.PeerConfig, 0, len(s.m[device]))
+	for _, cfg := range s.m[device] {
+		cfgs = append(cfgs, cfg)
+	}
+
+	return cfgs, nil
+}
+
+// Disable removes a peer from a device after saving its full configuration
+// in s, emulating the wg-quick(8) practice of commenting out a peer. Enable
+// restores the peer.
+func Disable(c Client, s DisabledStore, device string, peer wgtypes.Key) error {
+	d, err := c.Device(device)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range d.Peers {
+		if p.PublicKey != peer {
+			continue
+		}
+
+		cfg, err := ToConfig(p)
+		if err != nil {
+			return err
+		}
+
+		// Save the configuration before removing the peer so it cannot be
+		// lost, and forget it again if the peer could not be removed.
+		if err := s.Put(device, cfg); err != nil {
+			return err
+		}
+
+		err = c.ConfigureDevice(device, wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+			PublicKey: peer,
+			Remove:    true,
+		}}})
+		if err != nil {
+			_ = s.Delete(device, peer)
+			return err
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("wgpeer: device %q has no peer %s", device, peer)
+}
+
+// Enable restores a peer which was disabled using Disable to a device, and
+// removes it from s.
+func Enable(c Client, s DisabledStore, device string, peer wgtypes.Key) error {
+	cfg, err := s.Get(device, peer)
+	if err != nil {
+		return err
+	}
+
+	if err := c.ConfigureDevice(device, wgtypes.Config{Peers: []wgtypes.PeerConfig{*cfg}}); err != nil {
+		return err
+	}
+
+	return s.Delete(device, peer)
+}
+
+// DisablePeers disables every peer matched by sel as with Disable, saving
+// their configurations in the Group's Disabled store.
+//
+// If any device cannot be configured, the remaining devices are still
+// configured and a *BulkError is returned. The peers of devices which failed
+// are not saved in the store.
+func (g *Group) DisablePeers(sel Selector) error {
+	if g.Disabled == nil {
+		return fmt.Errorf("wgpeer: group has no disabled peer store")
+	}
+
+	ms, err := g.Select(sel)
+	if err != nil {
+		return err
+	}
+
+	// Produce every configuration before saving any, so that no peers are
+	// disabled if any cannot be restored.
+	cfgs := make([]wgtypes.PeerConfig, 0, len(ms))
+	for _, m := range ms {
+		cfg, err := ToConfig(m.Peer)
+		if err != nil {
+			return err
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	for i, m := range ms {
+		if err := g.Disabled.Put(m.Device, cfgs[i]); err != nil {
+			return err
+		}
+	}
+
+	err = g.apply(ms, func(m Member) wgtypes.PeerConfig {
+		return wgtypes.PeerConfig{
+			PublicKey: m.Peer.PublicKey,
+			Remove:    true,
+		}
+	})
+
+	if berr, ok := err.(*BulkError); ok {
+		for _, m := range ms {
+			if _, failed := berr.Errors[m.Device]; failed {
+				_ = g.Disabled.Delete(m.Device, m.Peer.PublicKey)
+			}
+		}
+	}
+
+	return err
+}
+
+// ToConfig produces a PeerConfig which fully restores p, replacing any
+// existing allowed IPs for the same peer.
+//
+// If p has a preshared key which was not retrieved, such as by a
+// *wgctrl.Client created with wgctrl.WithoutSecrets, an error is returned,
+// since the PeerConfig would restore p without its preshared key.
+func ToConfig(p wgtypes.Peer) (wgtypes.PeerConfig, error) {
+	if p.HasPresharedKey && p.PresharedKey == (wgtypes.Key{}) {
+		return wgtypes.PeerConfig{}, fmt.Errorf("wgpeer: peer %s was retrieved without its preshared key", p.PublicKey)
+	}
+
+	cfg := wgtypes.PeerConfig{
+		PublicKey:         p.PublicKey,
+		Endpoint:          p.Endpoint,
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        p.AllowedIPs,
+	}
+
+	if p.PresharedKey != (wgtypes.Key{}) {
+		psk := p.PresharedKey
+		cfg.PresharedKey = &psk
+	}
+	if p.PersistentKeepaliveInterval != 0 {
+		keepalive := p.PersistentKeepaliveInterval
+		cfg.PersistentKeepaliveInterval = &keepalive
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,202 @@
+package wgpeer_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestDisableEnable(t *testing.T) {
+	var (
+		psk       = wgtest.MustPresharedKey()
+		keepalive = 25 * time.Second
+
+		peer = wgtypes.Peer{
+			PublicKey:                   wgtest.MustPublicKey(),
+			PresharedKey:                psk,
+			Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+			PersistentKeepaliveInterval: keepalive,
+			AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+			ReceiveBytes:                1024,
+			LastHandshakeTime:           time.Unix(1, 0),
+		}
+
+		cfg = wgtypes.PeerConfig{
+			PublicKey:                   peer.PublicKey,
+			PresharedKey:                &psk,
+			Endpoint:                    peer.Endpoint,
+			PersistentKeepaliveInterval: &keepalive,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs:                  peer.AllowedIPs,
+		}
+	)
+
+	c := &testClient{
+		devices: []*wgtypes.Device{{Name: "wg0", Peers: []wgtypes.Peer{peer}}},
+	}
+
+	var s wgpeer.MemoryStore
+	if err := wgpeer.Disable(c, &s, "wg0", peer.PublicKey); err != nil {
+		t.Fatalf("failed to disable peer: %v", err)
+	}
+
+	want := wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey: peer.PublicKey,
+		Remove:    true,
+	}}}
+	if diff := cmp.Diff(want, c.cfgs["wg0"]); diff != "" {
+		t.Fatalf("unexpected disable configuration (-want +got):\n%s", diff)
+	}
+
+	cfgs, err := s.List("wg0")
+	if err != nil {
+		t.Fatalf("failed to list disabled peers: %v", err)
+	}
+	if diff := cmp.Diff([]wgtypes.PeerConfig{cfg}, cfgs); diff != "" {
+		t.Fatalf("unexpected disabled peers (-want +got):\n%s", diff)
+	}
+
+	if err := wgpeer.Enable(c, &s, "wg0", peer.PublicKey); err != nil {
+		t.Fatalf("failed to enable peer: %v", err)
+	}
+
+	want = wgtypes.Config{Peers: []wgtypes.PeerConfig{cfg}}
+	if diff := cmp.Diff(want, c.cfgs["wg0"]); diff != "" {
+		t.Fatalf("unexpected enable configuration (-want +got):\n%s", diff)
+	}
+
+	if _, err := s.Get("wg0", peer.PublicKey); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected peer to be removed from store, but got: %v", err)
+	}
+}
+
+func TestDisableErrors(t *testing.T) {
+	var (
+		known   = wgtest.MustPublicKey()
+		unknown = wgtest.MustPublicKey()
+	)
+
+	c := &testClient{
+		devices: []*wgtypes.Device{{Name: "wg0", Peers: []wgtypes.Peer{{PublicKey: known}}}},
+		errs:    map[string]error{"wg0": errors.New("device busy")},
+	}
+
+	var s wgpeer.MemoryStore
+	if err := wgpeer.Disable(c, &s, "wg0", unknown); err == nil {
+		t.Fatal("expected an error for an unknown peer, but none occurred")
+	}
+	if err := wgpeer.Disable(c, &s, "wg1", known); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist for an unknown device, but got: %v", err)
+	}
+
+	// The peer could not be removed, so it must not remain in the store.
+	if err := wgpeer.Disable(c, &s, "wg0", known); err == nil {
+		t.Fatal("expected a configuration error, but none occurred")
+	}
+	if cfgs, _ := s.List("wg0"); len(cfgs) != 0 {
+		t.Fatalf("expected no disabled peers, but got: %v", cfgs)
+	}
+
+	if err := wgpeer.Enable(c, &s, "wg0", known); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist for a peer which is not disabled, but got: %v", err)
+	}
+}
+
+func TestDisableWithoutSecrets(t *testing.T) {
+	// The peer has a preshared key, but it was not retrieved, so the peer
+	// must not be removed as it could not be restored with its key.
+	peer := wgtypes.Peer{PublicKey: wgtest.MustPublicKey(), HasPresharedKey: true}
+	c := &testClient{
+		devices: []*wgtypes.Device{{Name: "wg0", Peers: []wgtypes.Peer{peer}}},
+	}
+
+	var s wgpeer.MemoryStore
+	if err := wgpeer.Disable(c, &s, "wg0", peer.PublicKey); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	} else {
+		t.Logf("OK error: %v", err)
+	}
+
+	g := &wgpeer.Group{Client: c, Labels: wgpeer.MapLabels{}, Disabled: &s}
+	if err := g.DisablePeers(nil); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	if len(c.cfgs) != 0 {
+		t.Fatalf("expected no configurations, but got: %v", c.cfgs)
+	}
+	if cfgs, _ := s.List("wg0"); len(cfgs) != 0 {
+		t.Fatalf("expected no disabled peers, but got: %v", cfgs)
+	}
+}
+
+func TestGroupDisablePeers(t *testing.T) {
+	var (
+		laptopA = wgtest.MustPublicKey()
+		laptopB = wgtest.MustPublicKey()
+		server  = wgtest.MustPublicKey()
+	)
+
+	c := &testClient{
+		devices: []*wgtypes.Device{
+			{Name: "wg0", Peers: []wgtypes.Peer{{PublicKey: laptopA}, {PublicKey: server}}},
+			{Name: "wg1", Peers: []wgtypes.Peer{{PublicKey: laptopB}}},
+		},
+		errs: map[string]error{"wg1": errors.New("device busy")},
+	}
+
+	var s wgpeer.MemoryStore
+	g := &wgpeer.Group{
+		Client: c,
+		Labels: wgpeer.MapLabels{
+			laptopA: {"role": "laptop"},
+			laptopB: {"role": "laptop"},
+			server:  {"role": "server"},
+		},
+		Disabled: &s,
+	}
+
+	var berr *wgpeer.BulkError
+	if err := g.DisablePeers(wgpeer.Selector{"role": "laptop"}); !errors.As(err, &berr) {
+		t.Fatalf("expected BulkError, but got: %v", err)
+	}
+
+	// Only the peer on the device which was configured successfully is
+	// retained in the store.
+	for _, tt := range []struct {
+		device string
+		want   []wgtypes.PeerConfig
+	}{
+		{
+			device: "wg0",
+			want: []wgtypes.PeerConfig{{
+				PublicKey:         laptopA,
+				ReplaceAllowedIPs: true,
+			}},
+		},
+		{
+			device: "wg1",
+			want:   []wgtypes.PeerConfig{},
+		},
+	} {
+		cfgs, err := s.List(tt.device)
+		if err != nil {
+			t.Fatalf("failed to list disabled peers: %v", err)
+		}
+
+		if diff := cmp.Diff(tt.want, cfgs); diff != "" {
+			t.Fatalf("unexpected disabled peers for %q (-want +got):\n%s", tt.device, diff)
+		}
+	}
+
+	if err := (&wgpeer.Group{Client: c}).DisablePeers(nil); err == nil {
+		t.Fatal("expected an error for a group with no store, but none occurred")
+	}
+}
@@ -0,0 +1,31 @@
+// Package wgpeer provides helpers for managing groups of WireGuard peers
+// across one or more devices.
+//
+// WireGuard does not store any metadata about peers beyond their
+// configuration, so Labels for each peer are supplied by the caller through a
+// LabelSource. A Group uses a Selector to apply bulk operations to every
+// matching peer in a single call per device.
+//
+// Disable and Enable emulate the wg-quick(8) practice of commenting out a peer
+// in a configuration file: a disabled peer is removed from its device, but its
+// full configuration is kept in a DisabledStore so it can be restored later.
+//
+// A FlapDetector observes devices over time and reports peers whose
+// handshakes repeatedly stall and resume, so that operators can be alerted to
+// unstable links rather than inspecting raw counters. A StatsTracker turns
+// the same observations into per-peer traffic deltas and totals which survive
+// peer removal and counter resets, and which can be persisted in a
+// StatsStore so that they also survive restarts and reboots.
+//
+// A SessionTracker derives sessions from handshakes, so that integrations
+// such as accounting or a "connected since" display can report when a peer
+// connected and for how long, rather than raw handshake timestamps.
+//
+// A Janitor removes peers which never completed a handshake within a TTL,
+// such as peers provisioned for abandoned accounts, and reports each removal
+// so that it can be recorded in an event log.
+//
+// ImportPeers streams a CSV or JSON lines list of peers onto a device in
+// chunks, so that large migrations from other systems can report progress
+// and resume after a failure without disturbing existing peers.
+package wgpeer // import "golang.zx2c4.com/wireguard/wgctrl/wgpeer"
@@ -0,0 +1,279 @@
+package wgpeer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Default values for the fields of a FlapDetector.
+const (
+	DefaultFlapWindow    = 10 * time.Minute
+	DefaultFlapThreshold = 4
+
+	// DefaultStaleAfter is the age at which WireGuard itself stops using a
+	// session, after which a peer which has not completed a new handshake
+	// is considered to have stalled.
+	DefaultStaleAfter = 3 * time.Minute
+)
+
+// A FlapKind indicates whether a peer started or stopped flapping.
+type FlapKind int
+
+// Possible FlapKind values.
+const (
+	FlapStarted FlapKind = iota
+	FlapStopped
+)
+
+// String returns the string representation of a FlapKind.
+func (k FlapKind) String() string {
+	switch k {
+	case FlapStarted:
+		return "started"
+	case FlapStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// A FlapEvent reports that a peer started or stopped flapping, along with
+// the recent history of the peer which may explain why.
+type FlapEvent struct {
+	Kind      FlapKind
+	Time      time.Time
+	Device    string
+	PublicKey wgtypes.Key
+
+	// Transitions is the number of times the peer stalled or resumed within
+	// the FlapDetector's window.
+	Transitions int
+
+	// EndpointChanges lists the changes to the peer's endpoint within the
+	// FlapDetector's window.
+	EndpointChanges []EndpointChange
+
+	// ReceiveStalled and TransmitStalled report whether the peer's receive
+	// and transmit byte counters have not advanced for at least the
+	// FlapDetector's StaleAfter duration.
+	ReceiveStalled  bool
+	TransmitStalled bool
+
+	LastHandshakeTime time.Time
+}
+
+// An EndpointChange is a change to the endpoint of a peer.
+type EndpointChange struct {
+	Time     time.Time
+	From, To string
+}
+
+// A FlapDetector classifies peers as flapping when their handshakes
+// repeatedly stall and resume, and reports each change in classification to
+// a callback.
+//
+// A peer is up if it completed a handshake within StaleAfter, and stalled
+// otherwise. A peer is flapping if it transitioned between up and stalled at
+// least Threshold times within Window. Its methods are safe for concurrent
+// use.
+type FlapDetector struct {
+	// Window is the period over which transitions are counted. If zero,
+	// DefaultFlapWindow is used.
+	Window time.Duration
+
+	// Threshold is the number of transitions within Window at which a peer
+	// is flapping. If zero, DefaultFlapThreshold is used.
+	Threshold int
+
+	// StaleAfter is the age of the last handshake after which a peer is
+	// stalled. If zero, DefaultStaleAfter is used.
+	StaleAfter time.Duration
+
+	// OnFlap is called when a peer starts or stops flapping. It is called
+	// synchronously from Observe, and must not call Observe.
+	OnFlap func(FlapEvent)
+
+	mu    sync.Mutex
+	peers map[flapKey]*flapState
+}
+
+// A flapKey identifies a peer on a device.
+type flapKey struct {
+	device string
+	peer   wgtypes.Key
+}
+
+// flapState is the observed history of a peer.
+type flapState struct {
+	up          bool
+	flapping    bool
+	transitions []time.Time
+	endpoint    string
+	changes     []EndpointChange
+
+	rx, tx     int64
+	rxAt, txAt time.Time
+}
+
+// Observe records the state of the peers of devices at time now, and calls
+// OnFlap for each peer which started or stopped flapping. Peers which are no
+// longer present are forgotten.
+func (fd *FlapDetector) Observe(now time.Time, devices []*wgtypes.Device) {
+	var (
+		window    = durationOr(fd.Window, DefaultFlapWindow)
+		stale     = durationOr(fd.StaleAfter, DefaultStaleAfter)
+		threshold = fd.Threshold
+	)
+	if threshold <= 0 {
+		threshold = DefaultFlapThreshold
+	}
+
+	var events []FlapEvent
+
+	fd.mu.Lock()
+	if fd.peers == nil {
+		fd.peers = make(map[flapKey]*flapState)
+	}
+
+	seen := make(map[flapKey]bool)
+	for _, d := range devices {
+		for _, p := range d.Peers {
+			k := flapKey{device: d.Name, peer: p.PublicKey}
+			seen[k] = true
+
+			var endpoint string
+			if p.Endpoint != nil {
+				endpoint = p.Endpoint.String()
+			}
+
+			up := !p.LastHandshakeTime.IsZero() && now.Sub(p.LastHandshakeTime) < stale
+
+			st, ok := fd.peers[k]
+			if !ok {
+				// First observation: there is no history to compare with.
+				fd.peers[k] = &flapState{
+					up:       up,
+					endpoint: endpoint,
+					rx:       p.ReceiveBytes,
+					tx:       p.TransmitBytes,
+					rxAt:     now,
+					txAt:     now,
+				}
+				continue
+			}
+
+			if up != st.up {
+				st.up = up
+				st.transitions = append(st.transitions, now)
+			}
+			if endpoint != st.endpoint {
+				st.changes = append(st.changes, EndpointChange{Time: now, From: st.endpoint, To: endpoint})
+				st.endpoint = endpoint
+			}
+			if p.ReceiveBytes != st.rx {
+				st.rx, st.rxAt = p.ReceiveBytes, now
+			}
+			if p.TransmitBytes != st.tx {
+				st.tx, st.txAt = p.TransmitBytes, now
+			}
+
+			st.prune(now.Add(-window))
+
+			flapping := len(st.transitions) >= threshold
+			if flapping == st.flapping {
+				continue
+			}
+			st.flapping = flapping
+
+			e := FlapEvent{
+				Kind:              FlapStopped,
+				Time:              now,
+				Device:            d.Name,
+				PublicKey:         p.PublicKey,
+				Transitions:       len(st.transitions),
+				EndpointChanges:   append([]EndpointChange(nil), st.changes...),
+				ReceiveStalled:    now.Sub(st.rxAt) >= stale,
+				TransmitStalled:   now.Sub(st.txAt) >= stale,
+				LastHandshakeTime: p.LastHandshakeTime,
+			}
+			if flapping {
+				e.Kind = FlapStarted
+			}
+
+			events = append(events, e)
+		}
+	}
+
+	for k := range fd.peers {
+		if !seen[k] {
+			delete(fd.peers, k)
+		}
+	}
+	fd.mu.Unlock()
+
+	if fd.OnFlap == nil {
+		return
+	}
+
+	for _, e := range events {
+		fd.OnFlap(e)
+	}
+}
+
+// Flapping reports whether the peer on device is currently flapping.
+func (fd *FlapDetector) Flapping(device string, peer wgtypes.Key) bool {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	st, ok := fd.peers[flapKey{device: device, peer: peer}]
+	return ok && st.flapping
+}
+
+// Run retrieves all devices from c every interval and passes them to
+// Observe, until ctx is canceled or retrieving devices fails.
+func (fd *FlapDetector) Run(ctx context.Context, c Client, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		devices, err := c.Devices()
+		if err != nil {
+			return err
+		}
+
+		fd.Observe(time.Now(), devices)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// prune discards history which occurred before cutoff.
+func (st *flapState) prune(cutoff time.Time) {
+	var i int
+	for i < len(st.transitions) && st.transitions[i].Before(cutoff) {
+		i++
+	}
+	st.transitions = st.transitions[i:]
+
+	var j int
+	for j < len(st.changes) && st.changes[j].Time.Before(cutoff) {
+		j++
+	}
+	st.changes = st.changes[j:]
+}
+
+// durationOr returns d, or def if d is zero.
+func durationOr(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+
+	return d
+}
@@ -0,0 +1,101 @@
+package wgpeer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestFlapDetector(t *testing.T) {
+	var (
+		pub    = wgtest.MustPublicKey()
+		t0     = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		events []wgpeer.FlapEvent
+	)
+
+	fd := &wgpeer.FlapDetector{
+		OnFlap: func(e wgpeer.FlapEvent) { events = append(events, e) },
+	}
+
+	// observe records the peer at minute m with its last handshake at
+	// minute hs.
+	var tx int64
+	observe := func(m, hs int, endpoint string) {
+		tx++
+		fd.Observe(t0.Add(time.Duration(m)*time.Minute), []*wgtypes.Device{{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{{
+				PublicKey:         pub,
+				Endpoint:          wgtest.MustUDPAddr(endpoint),
+				LastHandshakeTime: t0.Add(time.Duration(hs) * time.Minute),
+				TransmitBytes:     tx,
+			}},
+		}})
+	}
+
+	const (
+		a = "192.0.2.1:51820"
+		b = "192.0.2.2:51820"
+	)
+
+	// The peer stalls and resumes twice, reaching the threshold of 4
+	// transitions within the window at minute 10.
+	observe(0, 0, a)
+	observe(4, 0, a)
+	observe(5, 5, b)
+	observe(9, 5, b)
+	observe(10, 10, b)
+
+	if !fd.Flapping("wg0", pub) {
+		t.Fatal("expected peer to be flapping")
+	}
+
+	// The peer then remains up until its transitions leave the window.
+	for m := 12; m <= 20; m += 2 {
+		observe(m, m, b)
+	}
+
+	if fd.Flapping("wg0", pub) {
+		t.Fatal("expected peer to have stopped flapping")
+	}
+
+	want := []wgpeer.FlapEvent{
+		{
+			Kind:        wgpeer.FlapStarted,
+			Time:        t0.Add(10 * time.Minute),
+			Device:      "wg0",
+			PublicKey:   pub,
+			Transitions: 4,
+			EndpointChanges: []wgpeer.EndpointChange{{
+				Time: t0.Add(5 * time.Minute),
+				From: a,
+				To:   b,
+			}},
+			ReceiveStalled:    true,
+			LastHandshakeTime: t0.Add(10 * time.Minute),
+		},
+		{
+			Kind:              wgpeer.FlapStopped,
+			Time:              t0.Add(16 * time.Minute),
+			Device:            "wg0",
+			PublicKey:         pub,
+			Transitions:       2,
+			ReceiveStalled:    true,
+			LastHandshakeTime: t0.Add(16 * time.Minute),
+		},
+	}
+
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	// Peers which disappear are forgotten.
+	fd.Observe(t0.Add(30*time.Minute), nil)
+	if fd.Flapping("wg0", pub) {
+		t.Fatal("expected removed peer to be forgotten")
+	}
+}
@@ -0,0 +1,170 @@
+package wgpeer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Client is a type which can retrieve and configure WireGuard devices, such
+// as *wgctrl.Client.
+type Client interface {
+	Devices() ([]*wgtypes.Device, error)
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// A Member is a peer on a device which was matched by a Selector.
+type Member struct {
+	Device string
+	Peer   wgtypes.Peer
+}
+
+// A Group applies bulk operations to peers matched by a Selector.
+type Group struct {
+	// Client is used to retrieve and configure devices.
+	Client Client
+
+	// Labels supplies the Labels for each peer.
+	Labels LabelSource
+
+	// Devices optionally restricts the Group to the named devices. If
+	// empty, peers on all devices are considered.
+	Devices []string
+
+	// Disabled stores the configurations of peers disabled using
+	// DisablePeers.
+	Disabled DisabledStore
+}
+
+// Select returns the Members which match sel, ordered by device and then by
+// their order on each device.
+func (g *Group) Select(sel Selector) ([]Member, error) {
+	devices, err := g.Client.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	var ms []Member
+	for _, d := range devices {
+		if !g.includes(d.Name) {
+			continue
+		}
+
+		for _, p := range d.Peers {
+			if sel.Matches(g.Labels.PeerLabels(d.Name, p.PublicKey)) {
+				ms = append(ms, Member{Device: d.Name, Peer: p})
+			}
+		}
+	}
+
+	return ms, nil
+}
+
+// SetKeepalive sets the persistent keepalive interval of every peer matched
+// by sel. An interval of 0 disables persistent keepalives.
+func (g *Group) SetKeepalive(sel Selector, interval time.Duration) error {
+	return g.Apply(sel, func(m Member) wgtypes.PeerConfig {
+		return wgtypes.PeerConfig{
+			PublicKey:                   m.Peer.PublicKey,
+			UpdateOnly:                  true,
+			PersistentKeepaliveInterval: &interval,
+		}
+	})
+}
+
+// RemovePeers removes every peer matched by sel.
+func (g *Group) RemovePeers(sel Selector) error {
+	return g.Apply(sel, func(m Member) wgtypes.PeerConfig {
+		return wgtypes.PeerConfig{
+			PublicKey: m.Peer.PublicKey,
+			Remove:    true,
+		}
+	})
+}
+
+// Apply configures every peer matched by sel with the PeerConfig produced by
+// fn, using a single configuration operation for each device.
+//
+// If any device cannot be configured, the remaining devices are still
+// configured and a *BulkError is returned.
+func (g *Group) Apply(sel Selector, fn func(m Member) wgtypes.PeerConfig) error {
+	ms, err := g.Select(sel)
+	if err != nil {
+		return err
+	}
+
+	return g.apply(ms, fn)
+}
+
+// apply configures each of ms with the PeerConfig produced by fn.
+func (g *Group) apply(ms []Member, fn func(m Member) wgtypes.PeerConfig) error {
+	var (
+		names []string
+		cfgs  = make(map[string][]wgtypes.PeerConfig)
+	)
+
+	for _, m := range ms {
+		if _, ok := cfgs[m.Device]; !ok {
+			names = append(names, m.Device)
+		}
+
+		cfgs[m.Device] = append(cfgs[m.Device], fn(m))
+	}
+
+	berr := &BulkError{Errors: make(map[string]error)}
+	for _, name := range names {
+		if err := g.Client.ConfigureDevice(name, wgtypes.Config{Peers: cfgs[name]}); err != nil {
+			berr.Errors[name] = err
+		}
+	}
+
+	if len(berr.Errors) > 0 {
+		return berr
+	}
+
+	return nil
+}
+
+// includes determines if the named device is part of the Group.
+func (g *Group) includes(name string) bool {
+	if len(g.Devices) == 0 {
+		return true
+	}
+
+	for _, d := range g.Devices {
+		if d == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A BulkError reports the devices which could not be configured during a
+// bulk operation. Devices which are not present in Errors were configured
+// successfully.
+type BulkError struct {
+	// Errors maps device names to their configuration errors.
+	Errors map[string]error
+}
+
+// Error implements error.
+func (e *BulkError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ss := make([]string, 0, len(names))
+	for _, name := range names {
+		ss = append(ss, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+
+	return fmt.Sprintf("wgpeer: failed to configure %d device(s): %s",
+		len(names), strings.Join(ss, "; "))
+}
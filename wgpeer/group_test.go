@@ -0,0 +1,113 @@
+package wgpeer_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestParseSelector(t *testing.T) {
+	sel, err := wgpeer.ParseSelector(" site=ams, role=laptop")
+	if err != nil {
+		t.Fatalf("failed to parse selector: %v", err)
+	}
+
+	if diff := cmp.Diff("role=laptop,site=ams", sel.String()); diff != "" {
+		t.Fatalf("unexpected selector (-want +got):\n%s", diff)
+	}
+
+	for _, s := range []string{"site", "=ams", "site=ams,"} {
+		if _, err := wgpeer.ParseSelector(s); err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", s)
+		}
+	}
+}
+
+func TestGroupSetKeepalive(t *testing.T) {
+	var (
+		laptopA = wgtest.MustPublicKey()
+		laptopB = wgtest.MustPublicKey()
+		server  = wgtest.MustPublicKey()
+	)
+
+	c := &testClient{
+		devices: []*wgtypes.Device{
+			{Name: "wg0", Peers: []wgtypes.Peer{{PublicKey: laptopA}, {PublicKey: server}}},
+			{Name: "wg1", Peers: []wgtypes.Peer{{PublicKey: laptopB}}},
+			{Name: "wg2", Peers: []wgtypes.Peer{{PublicKey: laptopB}}},
+		},
+		errs: map[string]error{"wg1": errors.New("device busy")},
+	}
+
+	g := &wgpeer.Group{
+		Client: c,
+		Labels: wgpeer.MapLabels{
+			laptopA: {"role": "laptop"},
+			laptopB: {"role": "laptop", "site": "ams"},
+			server:  {"role": "server"},
+		},
+		Devices: []string{"wg0", "wg1"},
+	}
+
+	err := g.SetKeepalive(wgpeer.Selector{"role": "laptop"}, 25*time.Second)
+
+	var berr *wgpeer.BulkError
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected BulkError, but got: %v", err)
+	}
+	if _, ok := berr.Errors["wg1"]; !ok || len(berr.Errors) != 1 {
+		t.Fatalf("unexpected bulk errors: %v", berr)
+	}
+
+	keepalive := 25 * time.Second
+	want := map[string]wgtypes.Config{
+		"wg0": {Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   laptopA,
+			UpdateOnly:                  true,
+			PersistentKeepaliveInterval: &keepalive,
+		}}},
+	}
+
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+}
+
+type testClient struct {
+	devices []*wgtypes.Device
+	errs    map[string]error
+	cfgs    map[string]wgtypes.Config
+	applied []wgtypes.Config
+}
+
+func (c *testClient) Devices() ([]*wgtypes.Device, error) { return c.devices, nil }
+
+func (c *testClient) Device(name string) (*wgtypes.Device, error) {
+	for _, d := range c.devices {
+		if d.Name == name {
+			return d, nil
+		}
+	}
+
+	return nil, os.ErrNotExist
+}
+
+func (c *testClient) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	if err := c.errs[name]; err != nil {
+		return err
+	}
+
+	if c.cfgs == nil {
+		c.cfgs = make(map[string]wgtypes.Config)
+	}
+	c.cfgs[name] = cfg
+	c.applied = append(c.applied, cfg)
+
+	return nil
+}
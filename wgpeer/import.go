@@ -0,0 +1,296 @@
+package wgpeer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// An ImportFormat is the format of a peer list read by ImportPeers.
+type ImportFormat int
+
+// Possible ImportFormat values.
+const (
+	// CSV is comma-separated values with a header row. The public_key
+	// column is required, and the optional columns are name,
+	// preshared_key, endpoint, allowed_ips, which is a comma-separated list
+	// of prefixes, and persistent_keepalive, which is a number of seconds
+	// or a duration such as "25s". Column names are matched ignoring case,
+	// underscores, hyphens, and spaces, so that "PublicKey" also matches
+	// public_key. Other columns are ignored.
+	CSV ImportFormat = iota
+
+	// JSONLines is one JSON object per line, each describing a peer as in
+	// the "peers" array of wgtypes.ConfigJSONSchema. Empty lines are
+	// ignored.
+	JSONLines
+)
+
+// DefaultImportChunk is the default number of peers applied to a device in
+// each call to ConfigureDevice by ImportPeers.
+const DefaultImportChunk = 500
+
+// ImportOptions configures ImportPeers.
+type ImportOptions struct {
+	// Chunk is the number of peers applied in each call to
+	// ConfigureDevice. If zero, DefaultImportChunk is used.
+	Chunk int
+
+	// Skip is the number of records at the start of the list which are
+	// read but not applied, so that an interrupted import can be resumed
+	// using the last count reported to Progress.
+	Skip int
+
+	// Progress, if set, is called after each chunk is applied, with the
+	// number of records which have been applied or skipped.
+	Progress func(done int)
+}
+
+// ImportPeers reads a list of peers from r in the specified format and adds
+// them to the device specified by name in chunks, without reading the whole
+// list into memory. Existing peers which are not in the list are left
+// untouched, and peers in the list replace the allowed IPs of any existing
+// peer with the same public key, so that importing a list more than once has
+// the same effect as importing it once.
+//
+// ImportPeers returns the number of records which were applied or skipped.
+// If a record is invalid or a chunk cannot be applied, the records before
+// that chunk remain applied, and the import can be resumed by setting
+// opts.Skip to the returned count.
+func ImportPeers(c Client, name string, r io.Reader, format ImportFormat, opts *ImportOptions) (int, error) {
+	if opts == nil {
+		opts = &ImportOptions{}
+	}
+
+	chunk := opts.Chunk
+	if chunk <= 0 {
+		chunk = DefaultImportChunk
+	}
+
+	var next func() (wgtypes.PeerConfig, error)
+	switch format {
+	case CSV:
+		cr, err := newCSVReader(r)
+		if err != nil {
+			return 0, err
+		}
+		next = cr.next
+	case JSONLines:
+		next = newJSONLinesReader(r).next
+	default:
+		return 0, fmt.Errorf("wgpeer: unknown import format: %d", format)
+	}
+
+	var (
+		done  int
+		peers = make([]wgtypes.PeerConfig, 0, chunk)
+	)
+
+	flush := func() error {
+		if len(peers) == 0 {
+			return nil
+		}
+
+		if err := c.ConfigureDevice(name, wgtypes.Config{Peers: peers}); err != nil {
+			return fmt.Errorf("wgpeer: failed to import records %d-%d: %w", done+1, done+len(peers), err)
+		}
+
+		// Allocate a new chunk, since c may retain the previous one.
+		done += len(peers)
+		peers = make([]wgtypes.PeerConfig, 0, chunk)
+		if opts.Progress != nil {
+			opts.Progress(done)
+		}
+
+		return nil
+	}
+
+	for n := 1; ; n++ {
+		p, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ferr := flush(); ferr != nil {
+				return done, ferr
+			}
+
+			return done, fmt.Errorf("wgpeer: record %d: %v", n, err)
+		}
+
+		if n <= opts.Skip {
+			done = n
+			continue
+		}
+
+		p.ReplaceAllowedIPs = true
+		peers = append(peers, p)
+		if len(peers) == chunk {
+			if err := flush(); err != nil {
+				return done, err
+			}
+		}
+	}
+
+	return done, flush()
+}
+
+// A csvReader reads peers from CSV records.
+type csvReader struct {
+	r    *csv.Reader
+	cols map[string]int
+}
+
+// newCSVReader reads the header of a CSV peer list from r.
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.ReuseRecord = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("wgpeer: failed to read CSV header: %v", err)
+	}
+
+	cols := make(map[string]int, len(header))
+	for i, h := range header {
+		cols[strings.NewReplacer("_", "", "-", "", " ", "").Replace(strings.ToLower(h))] = i
+	}
+
+	if _, ok := cols["publickey"]; !ok {
+		return nil, errors.New("wgpeer: CSV header has no public_key column")
+	}
+
+	return &csvReader{r: cr, cols: cols}, nil
+}
+
+// next parses the next record.
+func (r *csvReader) next() (wgtypes.PeerConfig, error) {
+	rec, err := r.r.Read()
+	if err != nil {
+		return wgtypes.PeerConfig{}, err
+	}
+
+	field := func(col string) string {
+		i, ok := r.cols[col]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+
+		return strings.TrimSpace(rec[i])
+	}
+
+	pub, err := wgtypes.ParseKey(field("publickey"))
+	if err != nil {
+		return wgtypes.PeerConfig{}, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	p := wgtypes.PeerConfig{
+		PublicKey: pub,
+		Name:      field("name"),
+	}
+
+	if s := field("presharedkey"); s != "" {
+		psk, err := wgtypes.ParseKey(s)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid preshared key: %v", err)
+		}
+		p.PresharedKey = &psk
+	}
+
+	if s := field("endpoint"); s != "" {
+		addr, err := net.ResolveUDPAddr("udp", s)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid endpoint: %v", err)
+		}
+		p.Endpoint = addr
+	}
+
+	if p.AllowedIPs, err = wgtypes.ParseAllowedIPs(field("allowedips")); err != nil {
+		return wgtypes.PeerConfig{}, err
+	}
+
+	if s := field("persistentkeepalive"); s != "" {
+		d, err := parseKeepalive(s)
+		if err != nil {
+			return wgtypes.PeerConfig{}, err
+		}
+		p.PersistentKeepaliveInterval = &d
+	}
+
+	return p, nil
+}
+
+// parseKeepalive parses a persistent keepalive interval as a number of
+// seconds or a duration.
+func parseKeepalive(s string) (time.Duration, error) {
+	var d time.Duration
+	if n, err := strconv.Atoi(s); err == nil {
+		d = time.Duration(n) * time.Second
+	} else if d, err = time.ParseDuration(s); err != nil {
+		return 0, fmt.Errorf("invalid persistent keepalive interval: %q", s)
+	}
+
+	if d < 0 || d > 65535*time.Second {
+		return 0, fmt.Errorf("invalid persistent keepalive interval: %s", d)
+	}
+
+	return d, nil
+}
+
+// A jsonLinesReader reads peers from lines of JSON.
+type jsonLinesReader struct {
+	s *bufio.Scanner
+}
+
+// newJSONLinesReader reads a JSON lines peer list from r.
+func newJSONLinesReader(r io.Reader) *jsonLinesReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	return &jsonLinesReader{s: s}
+}
+
+// next parses the next non-empty line.
+func (r *jsonLinesReader) next() (wgtypes.PeerConfig, error) {
+	for r.s.Scan() {
+		line := bytes.TrimSpace(r.s.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if line[0] != '{' || !json.Valid(line) {
+			return wgtypes.PeerConfig{}, errors.New("expected a single JSON object")
+		}
+
+		// Parse the peer as part of a Config, which validates it as
+		// described by wgtypes.ConfigJSONSchema.
+		var b bytes.Buffer
+		b.WriteString(`{"peers":[`)
+		b.Write(line)
+		b.WriteString(`]}`)
+
+		var cfg wgtypes.Config
+		if err := json.Unmarshal(b.Bytes(), &cfg); err != nil {
+			return wgtypes.PeerConfig{}, err
+		}
+
+		return cfg.Peers[0], nil
+	}
+
+	if err := r.s.Err(); err != nil {
+		return wgtypes.PeerConfig{}, err
+	}
+
+	return wgtypes.PeerConfig{}, io.EOF
+}
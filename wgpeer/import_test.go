@@ -0,0 +1,203 @@
+package wgpeer_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestImportPeers(t *testing.T) {
+	var (
+		a   = wgtest.MustPublicKey()
+		b   = wgtest.MustPublicKey()
+		c   = wgtest.MustPublicKey()
+		psk = wgtest.MustPresharedKey()
+		ka  = 25 * time.Second
+	)
+
+	want := []wgtypes.PeerConfig{
+		{
+			PublicKey:                   a,
+			Name:                        "alice",
+			PresharedKey:                &psk,
+			Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+			PersistentKeepaliveInterval: &ka,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs: []net.IPNet{
+				wgtest.MustCIDR("10.0.0.1/32"),
+				wgtest.MustCIDR("fd00::1/128"),
+			},
+		},
+		{
+			PublicKey:         b,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+		},
+		{
+			PublicKey:         c,
+			ReplaceAllowedIPs: true,
+		},
+	}
+
+	tests := []struct {
+		name   string
+		format wgpeer.ImportFormat
+		in     string
+	}{
+		{
+			name:   "CSV",
+			format: wgpeer.CSV,
+			in: fmt.Sprintf(`PublicKey,name,preshared_key,endpoint,allowed_ips,persistent_keepalive,notes
+%s,alice,%s,192.0.2.1:51820,"10.0.0.1/32, fd00::1/128",25,x
+%s,,,,10.0.0.2/32,,
+%s
+`, a, psk, b, c),
+		},
+		{
+			name:   "JSON lines",
+			format: wgpeer.JSONLines,
+			in: fmt.Sprintf(`{"publicKey":%q,"name":"alice","presharedKey":%q,"endpoint":"192.0.2.1:51820","persistentKeepaliveInterval":"25s","allowedIPs":["10.0.0.1/32","fd00::1/128"]}
+
+{"publicKey":%q,"allowedIPs":["10.0.0.2/32"]}
+{"publicKey":%q}
+`, a, psk, b, c),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				tc       testClient
+				progress []int
+			)
+
+			n, err := wgpeer.ImportPeers(&tc, "wg0", strings.NewReader(tt.in), tt.format, &wgpeer.ImportOptions{
+				Chunk:    2,
+				Progress: func(done int) { progress = append(progress, done) },
+			})
+			if err != nil {
+				t.Fatalf("failed to import peers: %v", err)
+			}
+
+			if diff := cmp.Diff(3, n); diff != "" {
+				t.Fatalf("unexpected count (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff([]int{2, 3}, progress); diff != "" {
+				t.Fatalf("unexpected progress (-want +got):\n%s", diff)
+			}
+
+			wantCfgs := []wgtypes.Config{{Peers: want[:2]}, {Peers: want[2:]}}
+			if diff := cmp.Diff(wantCfgs, tc.applied); diff != "" {
+				t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestImportPeersResume(t *testing.T) {
+	keys := make([]wgtypes.Key, 5)
+	var b strings.Builder
+	b.WriteString("public_key\n")
+	for i := range keys {
+		keys[i] = wgtest.MustPublicKey()
+		fmt.Fprintln(&b, keys[i])
+	}
+	// An invalid record after the first four.
+	in := strings.Replace(b.String(), keys[4].String(), "foo", 1)
+
+	var tc testClient
+	opts := &wgpeer.ImportOptions{Chunk: 3}
+
+	// The valid records before the invalid one are applied.
+	n, err := wgpeer.ImportPeers(&tc, "wg0", strings.NewReader(in), wgpeer.CSV, opts)
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+	t.Logf("OK error: %v", err)
+
+	if diff := cmp.Diff(4, n); diff != "" {
+		t.Fatalf("unexpected count (-want +got):\n%s", diff)
+	}
+
+	// After fixing the list, the import resumes after the applied records.
+	tc.applied = nil
+	opts.Skip = n
+	n, err = wgpeer.ImportPeers(&tc, "wg0", strings.NewReader(b.String()), wgpeer.CSV, opts)
+	if err != nil {
+		t.Fatalf("failed to resume import: %v", err)
+	}
+
+	if diff := cmp.Diff(5, n); diff != "" {
+		t.Fatalf("unexpected count (-want +got):\n%s", diff)
+	}
+
+	want := []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{PublicKey: keys[4], ReplaceAllowedIPs: true}}}}
+	if diff := cmp.Diff(want, tc.applied); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+}
+
+func TestImportPeersErrors(t *testing.T) {
+	pub := wgtest.MustPublicKey()
+
+	tests := []struct {
+		name   string
+		format wgpeer.ImportFormat
+		in     string
+		err    error
+	}{
+		{
+			name:   "no public key column",
+			format: wgpeer.CSV,
+			in:     "name\nfoo\n",
+		},
+		{
+			name:   "bad keepalive",
+			format: wgpeer.CSV,
+			in:     fmt.Sprintf("public_key,persistent_keepalive\n%s,forever\n", pub),
+		},
+		{
+			name:   "JSON injection",
+			format: wgpeer.JSONLines,
+			in:     fmt.Sprintf(`{"publicKey":%q}],"replacePeers":true,"peers":[{"publicKey":%q}`, pub, pub),
+		},
+		{
+			name:   "unknown JSON field",
+			format: wgpeer.JSONLines,
+			in:     fmt.Sprintf(`{"publicKey":%q,"foo":1}`, pub),
+		},
+		{
+			name:   "configure",
+			format: wgpeer.JSONLines,
+			in:     fmt.Sprintf(`{"publicKey":%q}`, pub),
+			err:    errors.New("device error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := &testClient{errs: map[string]error{"wg0": tt.err}}
+
+			n, err := wgpeer.ImportPeers(tc, "wg0", strings.NewReader(tt.in), tt.format, nil)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n != 0 {
+				t.Fatalf("unexpected count: %d", n)
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
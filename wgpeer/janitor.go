@@ -0,0 +1,225 @@
+package wgpeer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A JanitorEvent reports that a Janitor removed a peer which never completed
+// a handshake.
+type JanitorEvent struct {
+	Time      time.Time
+	Device    string
+	PublicKey wgtypes.Key
+
+	// FirstSeen is the time at which the Janitor first observed the peer.
+	FirstSeen time.Time
+}
+
+// A Janitor removes peers which were added to a device but never completed a
+// handshake within a TTL, such as peers provisioned for accounts which were
+// abandoned before they were ever used.
+//
+// The age of a peer is measured from the first Sweep which observed it, as
+// WireGuard does not record when a peer was added. Peers which have completed
+// a handshake are never removed. Its methods are safe for concurrent use.
+//
+// To record removals in the audit log of a *wgctrl.Client created using
+// wgctrl.WithAudit, pass the Client returned by its WithContext method with a
+// context from wgctrl.NewAuditContext which names the Janitor as the actor.
+type Janitor struct {
+	// TTL is the time a peer may remain without completing a handshake on
+	// devices which are not present in DeviceTTLs. If zero or less, peers on
+	// those devices are not removed.
+	TTL time.Duration
+
+	// DeviceTTLs overrides TTL for the named devices. A value of zero or less
+	// disables the Janitor for that device.
+	DeviceTTLs map[string]time.Duration
+
+	// Store, if set, receives the configuration of each peer before it is
+	// removed, so that it can be restored using Enable.
+	Store DisabledStore
+
+	// OnRemove, if set, is called for each peer after it is removed, so that
+	// removals can be recorded in an event log.
+	OnRemove func(JanitorEvent)
+
+	mu        sync.Mutex
+	firstSeen map[flapKey]time.Time
+}
+
+// Sweep retrieves all devices from c at time now, and removes each peer which
+// has not completed a handshake within its device's TTL using a single
+// configuration operation per device.
+//
+// If any device cannot be configured, the remaining devices are still
+// configured and a *BulkError is returned. Peers which could not be removed
+// are retried by the next Sweep.
+func (j *Janitor) Sweep(c Client, now time.Time) error {
+	devices, err := c.Devices()
+	if err != nil {
+		return err
+	}
+
+	expired := j.observe(now, devices)
+
+	names := make([]string, 0, len(expired))
+	for name := range expired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	berr := &BulkError{Errors: make(map[string]error)}
+	for _, name := range names {
+		if err := j.remove(c, now, name, expired[name]); err != nil {
+			berr.Errors[name] = err
+		}
+	}
+
+	if len(berr.Errors) > 0 {
+		return berr
+	}
+
+	return nil
+}
+
+// Run calls Sweep every interval until ctx is canceled or retrieving devices
+// fails. Errors configuring individual devices do not stop Run.
+func (j *Janitor) Run(ctx context.Context, c Client, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if err := j.Sweep(c, time.Now()); err != nil {
+			if _, ok := err.(*BulkError); !ok {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// observe records the peers of devices at time now which have never
+// completed a handshake, and returns those whose TTL has expired, keyed by
+// device name. Peers which completed a handshake or are no longer present
+// are forgotten.
+func (j *Janitor) observe(now time.Time, devices []*wgtypes.Device) map[string][]wgtypes.Peer {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.firstSeen == nil {
+		j.firstSeen = make(map[flapKey]time.Time)
+	}
+
+	var (
+		seen    = make(map[flapKey]bool)
+		expired = make(map[string][]wgtypes.Peer)
+	)
+
+	for _, d := range devices {
+		ttl := j.ttl(d.Name)
+
+		for _, p := range d.Peers {
+			if !p.LastHandshakeTime.IsZero() {
+				continue
+			}
+
+			k := flapKey{device: d.Name, peer: p.PublicKey}
+			seen[k] = true
+
+			first, ok := j.firstSeen[k]
+			if !ok {
+				first = now
+				j.firstSeen[k] = first
+			}
+
+			if ttl > 0 && now.Sub(first) >= ttl {
+				expired[d.Name] = append(expired[d.Name], p)
+			}
+		}
+	}
+
+	for k := range j.firstSeen {
+		if !seen[k] {
+			delete(j.firstSeen, k)
+		}
+	}
+
+	return expired
+}
+
+// remove removes peers from device, and reports each removal to OnRemove.
+func (j *Janitor) remove(c Client, now time.Time, device string, peers []wgtypes.Peer) error {
+	pcfgs := make([]wgtypes.PeerConfig, 0, len(peers))
+	for _, p := range peers {
+		if j.Store != nil {
+			cfg, err := ToConfig(p)
+			if err != nil {
+				return err
+			}
+
+			if err := j.Store.Put(device, cfg); err != nil {
+				return err
+			}
+		}
+
+		pcfgs = append(pcfgs, wgtypes.PeerConfig{
+			PublicKey: p.PublicKey,
+			Remove:    true,
+		})
+	}
+
+	if err := c.ConfigureDevice(device, wgtypes.Config{Peers: pcfgs}); err != nil {
+		if j.Store != nil {
+			for _, p := range peers {
+				_ = j.Store.Delete(device, p.PublicKey)
+			}
+		}
+
+		return err
+	}
+
+	events := make([]JanitorEvent, 0, len(peers))
+
+	j.mu.Lock()
+	for _, p := range peers {
+		k := flapKey{device: device, peer: p.PublicKey}
+		events = append(events, JanitorEvent{
+			Time:      now,
+			Device:    device,
+			PublicKey: p.PublicKey,
+			FirstSeen: j.firstSeen[k],
+		})
+		delete(j.firstSeen, k)
+	}
+	j.mu.Unlock()
+
+	if j.OnRemove == nil {
+		return nil
+	}
+
+	for _, e := range events {
+		j.OnRemove(e)
+	}
+
+	return nil
+}
+
+// ttl returns the TTL for device.
+func (j *Janitor) ttl(device string) time.Duration {
+	if ttl, ok := j.DeviceTTLs[device]; ok {
+		return ttl
+	}
+
+	return j.TTL
+}
@@ -0,0 +1,121 @@
+package wgpeer_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestJanitorSweep(t *testing.T) {
+	var (
+		abandoned = wgtest.MustPublicKey()
+		connected = wgtest.MustPublicKey()
+		late      = wgtest.MustPublicKey()
+		exempt    = wgtest.MustPublicKey()
+
+		t0 = time.Unix(1000, 0)
+	)
+
+	c := &testClient{
+		devices: []*wgtypes.Device{
+			{Name: "wg0", Peers: []wgtypes.Peer{
+				{PublicKey: abandoned},
+				{PublicKey: connected, LastHandshakeTime: t0},
+			}},
+			{Name: "wg1", Peers: []wgtypes.Peer{{PublicKey: exempt}}},
+		},
+	}
+
+	var (
+		store  wgpeer.MemoryStore
+		events []wgpeer.JanitorEvent
+	)
+
+	j := &wgpeer.Janitor{
+		TTL:        time.Hour,
+		DeviceTTLs: map[string]time.Duration{"wg1": 0},
+		Store:      &store,
+		OnRemove:   func(e wgpeer.JanitorEvent) { events = append(events, e) },
+	}
+
+	if err := j.Sweep(c, t0); err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+
+	// A peer added later has its own TTL.
+	c.devices[0].Peers = append(c.devices[0].Peers, wgtypes.Peer{PublicKey: late})
+	if err := j.Sweep(c, t0.Add(30*time.Minute)); err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+	if len(c.applied) != 0 {
+		t.Fatalf("expected no configurations before TTL, but got: %v", c.applied)
+	}
+
+	t1 := t0.Add(time.Hour)
+	if err := j.Sweep(c, t1); err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+
+	want := []wgtypes.Config{{Peers: []wgtypes.PeerConfig{{
+		PublicKey: abandoned,
+		Remove:    true,
+	}}}}
+	if diff := cmp.Diff(want, c.applied); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+
+	wantEvents := []wgpeer.JanitorEvent{{
+		Time:      t1,
+		Device:    "wg0",
+		PublicKey: abandoned,
+		FirstSeen: t0,
+	}}
+	if diff := cmp.Diff(wantEvents, events); diff != "" {
+		t.Fatalf("unexpected events (-want +got):\n%s", diff)
+	}
+
+	if _, err := store.Get("wg0", abandoned); err != nil {
+		t.Fatalf("failed to get removed peer from store: %v", err)
+	}
+}
+
+func TestJanitorSweepError(t *testing.T) {
+	peer := wgtest.MustPublicKey()
+
+	c := &testClient{
+		devices: []*wgtypes.Device{{Name: "wg0", Peers: []wgtypes.Peer{{PublicKey: peer}}}},
+		errs:    map[string]error{"wg0": errors.New("device busy")},
+	}
+
+	var store wgpeer.MemoryStore
+	j := &wgpeer.Janitor{TTL: time.Minute, Store: &store}
+
+	t0 := time.Unix(1000, 0)
+	if err := j.Sweep(c, t0); err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+
+	err := j.Sweep(c, t0.Add(time.Minute))
+
+	var berr *wgpeer.BulkError
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected BulkError, but got: %v", err)
+	}
+	if _, err := store.Get("wg0", peer); err == nil {
+		t.Fatal("expected peer to be forgotten by store, but it was not")
+	}
+
+	// The removal is retried once the device can be configured.
+	c.errs = nil
+	if err := j.Sweep(c, t0.Add(2*time.Minute)); err != nil {
+		t.Fatalf("failed to sweep: %v", err)
+	}
+	if len(c.applied) != 1 {
+		t.Fatalf("expected one configuration, but got: %v", c.applied)
+	}
+}
@@ -0,0 +1,71 @@
+package wgpeer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Labels are key/value metadata attached to a peer.
+type Labels map[string]string
+
+// A LabelSource supplies the Labels for a peer on a device.
+type LabelSource interface {
+	PeerLabels(device string, peer wgtypes.Key) Labels
+}
+
+// MapLabels is a LabelSource which stores Labels by peer public key,
+// regardless of device.
+type MapLabels map[wgtypes.Key]Labels
+
+// PeerLabels implements LabelSource.
+func (m MapLabels) PeerLabels(_ string, peer wgtypes.Key) Labels { return m[peer] }
+
+// A Selector matches peers whose Labels contain all of its key/value pairs.
+// An empty Selector matches all peers.
+type Selector map[string]string
+
+// ParseSelector parses a Selector from a comma-separated list of key=value
+// pairs, such as "site=ams,role=laptop".
+func ParseSelector(s string) (Selector, error) {
+	sel := make(Selector)
+	if strings.TrimSpace(s) == "" {
+		return sel, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("wgpeer: invalid selector term %q", kv)
+		}
+
+		sel[k] = v
+	}
+
+	return sel, nil
+}
+
+// Matches determines if l satisfies s.
+func (s Selector) Matches(l Labels) bool {
+	for k, v := range s {
+		if lv, ok := l[k]; !ok || lv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the string representation of a Selector, as accepted by
+// ParseSelector.
+func (s Selector) String() string {
+	kvs := make([]string, 0, len(s))
+	for k, v := range s {
+		kvs = append(kvs, k+"="+v)
+	}
+	sort.Strings(kvs)
+
+	return strings.Join(kvs, ",")
+}
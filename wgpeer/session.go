@@ -0,0 +1,247 @@
+package wgpeer
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Session is a period during which a peer completed handshakes no more
+// than StaleAfter apart, and so was able to exchange traffic.
+type Session struct {
+	Device    string
+	PublicKey wgtypes.Key
+
+	// Endpoint is the peer's most recently observed endpoint, if known.
+	Endpoint string
+
+	// Start is the time of the handshake which started the session. For a
+	// session which was already active when the peer was first observed,
+	// this is the time of its most recent handshake.
+	Start time.Time
+
+	// End is the time at which the session stopped, or zero if it is still
+	// active. A session which went stale ends StaleAfter after its last
+	// handshake, when WireGuard stops using its keys.
+	End time.Time
+
+	LastHandshakeTime time.Time
+}
+
+// Active reports whether the session has not stopped.
+func (s Session) Active() bool { return s.End.IsZero() }
+
+// Duration returns the length of the session, measured up to now if it is
+// still active.
+func (s Session) Duration(now time.Time) time.Duration {
+	if s.Active() {
+		return now.Sub(s.Start)
+	}
+
+	return s.End.Sub(s.Start)
+}
+
+// A SessionKind indicates whether a session started or stopped.
+type SessionKind int
+
+// Possible SessionKind values.
+const (
+	SessionStarted SessionKind = iota
+	SessionStopped
+)
+
+// String returns the string representation of a SessionKind.
+func (k SessionKind) String() string {
+	switch k {
+	case SessionStarted:
+		return "started"
+	case SessionStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// A SessionEvent reports that a peer's session started or stopped.
+type SessionEvent struct {
+	Kind    SessionKind
+	Time    time.Time
+	Session Session
+
+	// Removed reports whether a session stopped because its peer or device
+	// was removed, rather than because its handshakes went stale.
+	Removed bool
+}
+
+// A SessionTracker derives sessions from the handshakes of peers, and
+// reports each session's start and stop to a callback.
+//
+// A session starts at the first handshake after a peer was silent, and
+// stops when the peer's last handshake is at least StaleAfter old or the
+// peer is removed. Its methods are safe for concurrent use.
+type SessionTracker struct {
+	// StaleAfter is the age of the last handshake after which a session
+	// stops. If zero, DefaultStaleAfter is used.
+	StaleAfter time.Duration
+
+	// OnSession is called when a session starts or stops. It is called
+	// synchronously from Observe, and must not call Observe.
+	OnSession func(SessionEvent)
+
+	mu       sync.Mutex
+	sessions map[flapKey]*Session
+}
+
+// Observe records the state of the peers of devices at time now, and calls
+// OnSession for each session which started or stopped. Peers which are not
+// present in devices are treated as removed.
+func (st *SessionTracker) Observe(now time.Time, devices []*wgtypes.Device) {
+	stale := durationOr(st.StaleAfter, DefaultStaleAfter)
+
+	var events []SessionEvent
+
+	st.mu.Lock()
+	if st.sessions == nil {
+		st.sessions = make(map[flapKey]*Session)
+	}
+
+	seen := make(map[flapKey]bool)
+	for _, d := range devices {
+		for _, p := range d.Peers {
+			k := flapKey{device: d.Name, peer: p.PublicKey}
+			seen[k] = true
+
+			var endpoint string
+			if p.Endpoint != nil {
+				endpoint = p.Endpoint.String()
+			}
+
+			active := !p.LastHandshakeTime.IsZero() && now.Sub(p.LastHandshakeTime) < stale
+
+			s, ok := st.sessions[k]
+			if ok && active && p.LastHandshakeTime.Sub(s.LastHandshakeTime) >= stale {
+				// The session went stale and a new one started between
+				// observations.
+				s.End = s.LastHandshakeTime.Add(stale)
+				delete(st.sessions, k)
+				events = append(events, SessionEvent{Kind: SessionStopped, Time: now, Session: *s})
+				ok = false
+			}
+
+			switch {
+			case ok && active:
+				s.Endpoint = endpoint
+				s.LastHandshakeTime = p.LastHandshakeTime
+			case ok:
+				// The handshakes went stale, or were reset along with the
+				// peer's device.
+				s.End = now
+				if !p.LastHandshakeTime.IsZero() {
+					s.LastHandshakeTime = p.LastHandshakeTime
+					s.End = p.LastHandshakeTime.Add(stale)
+				}
+
+				delete(st.sessions, k)
+				events = append(events, SessionEvent{Kind: SessionStopped, Time: now, Session: *s})
+			case active:
+				s := &Session{
+					Device:            d.Name,
+					PublicKey:         p.PublicKey,
+					Endpoint:          endpoint,
+					Start:             p.LastHandshakeTime,
+					LastHandshakeTime: p.LastHandshakeTime,
+				}
+
+				st.sessions[k] = s
+				events = append(events, SessionEvent{Kind: SessionStarted, Time: now, Session: *s})
+			}
+		}
+	}
+
+	var removed []SessionEvent
+	for k, s := range st.sessions {
+		if seen[k] {
+			continue
+		}
+
+		s.End = now
+		delete(st.sessions, k)
+		removed = append(removed, SessionEvent{Kind: SessionStopped, Time: now, Session: *s, Removed: true})
+	}
+	st.mu.Unlock()
+
+	sort.Slice(removed, func(i, j int) bool {
+		return sessionLess(removed[i].Session, removed[j].Session)
+	})
+	events = append(events, removed...)
+
+	if st.OnSession == nil {
+		return
+	}
+
+	for _, e := range events {
+		st.OnSession(e)
+	}
+}
+
+// Session returns the active session of the peer on device, if any.
+func (st *SessionTracker) Session(device string, peer wgtypes.Key) (Session, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.sessions[flapKey{device: device, peer: peer}]
+	if !ok {
+		return Session{}, false
+	}
+
+	return *s, true
+}
+
+// Sessions returns all active sessions, ordered by device and public key.
+func (st *SessionTracker) Sessions() []Session {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	out := make([]Session, 0, len(st.sessions))
+	for _, s := range st.sessions {
+		out = append(out, *s)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return sessionLess(out[i], out[j]) })
+	return out
+}
+
+// Run retrieves all devices from c every interval and passes them to
+// Observe, until ctx is canceled or retrieving devices fails.
+func (st *SessionTracker) Run(ctx context.Context, c Client, interval time.Duration) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		devices, err := c.Devices()
+		if err != nil {
+			return err
+		}
+
+		st.Observe(time.Now(), devices)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// sessionLess orders sessions by device and public key.
+func sessionLess(a, b Session) bool {
+	if a.Device != b.Device {
+		return a.Device < b.Device
+	}
+
+	return bytes.Compare(a.PublicKey[:], b.PublicKey[:]) < 0
+}
@@ -0,0 +1,96 @@
+package wgpeer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSessionTracker(t *testing.T) {
+	var (
+		pub    = wgtest.MustPublicKey()
+		t0     = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		events []wgpeer.SessionEvent
+	)
+
+	st := &wgpeer.SessionTracker{
+		OnSession: func(e wgpeer.SessionEvent) { events = append(events, e) },
+	}
+
+	minute := func(m int) time.Time { return t0.Add(time.Duration(m) * time.Minute) }
+
+	// observe records the peer at minute m with its last handshake at
+	// minute hs, or with no handshake if hs is negative.
+	observe := func(m, hs int) {
+		var lh time.Time
+		if hs >= 0 {
+			lh = minute(hs)
+		}
+
+		st.Observe(minute(m), []*wgtypes.Device{{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{{
+				PublicKey:         pub,
+				Endpoint:          wgtest.MustUDPAddr("192.0.2.1:51820"),
+				LastHandshakeTime: lh,
+			}},
+		}})
+	}
+
+	session := func(start, end, hs int) wgpeer.Session {
+		s := wgpeer.Session{
+			Device:            "wg0",
+			PublicKey:         pub,
+			Endpoint:          "192.0.2.1:51820",
+			Start:             minute(start),
+			LastHandshakeTime: minute(hs),
+		}
+		if end >= 0 {
+			s.End = minute(end)
+		}
+
+		return s
+	}
+
+	observe(0, -1)
+	observe(1, 1)
+	observe(3, 3)
+
+	if got, ok := st.Session("wg0", pub); !ok || got.Duration(minute(4)) != 3*time.Minute {
+		t.Fatalf("unexpected active session: %v, %v", got, ok)
+	}
+
+	// Stale, then restarted within a single interval.
+	observe(10, 10)
+	// Stale.
+	observe(14, 10)
+	observe(15, 15)
+	observe(16, 15)
+
+	// Removed.
+	st.Observe(minute(17), nil)
+
+	want := []wgpeer.SessionEvent{
+		{Kind: wgpeer.SessionStarted, Time: minute(1), Session: session(1, -1, 1)},
+		{Kind: wgpeer.SessionStopped, Time: minute(10), Session: session(1, 6, 3)},
+		{Kind: wgpeer.SessionStarted, Time: minute(10), Session: session(10, -1, 10)},
+		{Kind: wgpeer.SessionStopped, Time: minute(14), Session: session(10, 13, 10)},
+		{Kind: wgpeer.SessionStarted, Time: minute(15), Session: session(15, -1, 15)},
+		{Kind: wgpeer.SessionStopped, Time: minute(17), Session: session(15, 17, 15), Removed: true},
+	}
+
+	if diff := cmp.Diff(want, events); diff != "" {
+		t.Fatalf("unexpected SessionEvents (-want +got):\n%s", diff)
+	}
+
+	if d := events[3].Session.Duration(minute(100)); d != 3*time.Minute {
+		t.Fatalf("unexpected stopped session duration: %v", d)
+	}
+	if ss := st.Sessions(); len(ss) != 0 {
+		t.Fatalf("expected no active sessions, but got: %v", ss)
+	}
+}
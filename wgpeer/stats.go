@@ -0,0 +1,261 @@
+package wgpeer
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A StatsDelta is the traffic of a peer between two observations by a
+// StatsTracker.
+type StatsDelta struct {
+	// Device and PublicKey identify the peer.
+	Device    string
+	PublicKey wgtypes.Key
+
+	// ReceiveBytes and TransmitBytes are the bytes received from and sent to
+	// the peer since the previous observation.
+	ReceiveBytes, TransmitBytes int64
+
+	// TotalReceiveBytes and TotalTransmitBytes are the bytes received from
+	// and sent to the peer since it was first observed, including traffic
+	// before any removal or counter reset.
+	TotalReceiveBytes, TotalTransmitBytes int64
+
+	// Reset reports whether the peer's counters started again from zero
+	// since the previous observation, because it was removed and added
+	// again or its device was restarted.
+	Reset bool
+}
+
+// A StatsTracker computes per-peer traffic deltas from successive device
+// snapshots, as a basis for quotas, billing, and rate calculations.
+//
+// WireGuard's counters start from zero whenever a peer is added, including
+// when it is removed and added again, or when its device is recreated. A
+// StatsTracker identifies peers by device name and public key, detects these
+// resets, and counts the traffic before and after them, so that totals never
+// decrease. The counters of peers in a device's first snapshot are the
+// baseline, since their traffic before tracking began is unknown; peers
+// which appear later are new, and all of their traffic is counted.
+//
+// The zero value is ready to use. Its methods are safe for concurrent use.
+type StatsTracker struct {
+	mu      sync.Mutex
+	devices map[string]bool
+	peers   map[flapKey]*statsState
+}
+
+// statsState is the tracked traffic of a peer.
+type statsState struct {
+	// present reports whether the peer was in the latest snapshot.
+	present bool
+
+	// rx and tx are the counters in the latest snapshot.
+	rx, tx int64
+
+	// totalRX and totalTX are the totals since tracking began.
+	totalRX, totalTX int64
+}
+
+// Observe records a snapshot of devices, and returns the delta of each of
+// their peers since the previous snapshot, sorted by device name and public
+// key. Devices which are not in devices, and peers which are not in their
+// devices, are treated as removed, but their totals are kept until Forget is
+// called.
+func (st *StatsTracker) Observe(devices []*wgtypes.Device) []StatsDelta {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.peers == nil {
+		st.devices = make(map[string]bool)
+		st.peers = make(map[flapKey]*statsState)
+	}
+
+	seen := make(map[string]bool, len(devices))
+	for _, d := range devices {
+		seen[d.Name] = true
+	}
+
+	// A device which was removed and recreated loses all of its peers, and
+	// any peer which was removed loses its counters.
+	for k, s := range st.peers {
+		if !seen[k.device] {
+			s.present = false
+		}
+	}
+	for name := range st.devices {
+		if !seen[name] {
+			delete(st.devices, name)
+		}
+	}
+
+	var out []StatsDelta
+	for _, d := range devices {
+		baseline := !st.devices[d.Name]
+		st.devices[d.Name] = true
+
+		present := make(map[wgtypes.Key]bool, len(d.Peers))
+		for _, p := range d.Peers {
+			present[p.PublicKey] = true
+
+			k := flapKey{device: d.Name, peer: p.PublicKey}
+			s, ok := st.peers[k]
+			if !ok {
+				s = &statsState{}
+				st.peers[k] = s
+			}
+
+			delta := StatsDelta{
+				Device:    d.Name,
+				PublicKey: p.PublicKey,
+			}
+
+			switch {
+			case baseline && !ok:
+				// Traffic before tracking began is unknown.
+			case !s.present || p.ReceiveBytes < s.rx || p.TransmitBytes < s.tx:
+				// The counters started again from zero.
+				delta.ReceiveBytes = p.ReceiveBytes
+				delta.TransmitBytes = p.TransmitBytes
+				delta.Reset = ok
+			default:
+				delta.ReceiveBytes = p.ReceiveBytes - s.rx
+				delta.TransmitBytes = p.TransmitBytes - s.tx
+			}
+
+			s.present = true
+			s.rx, s.tx = p.ReceiveBytes, p.TransmitBytes
+			s.totalRX += delta.ReceiveBytes
+			s.totalTX += delta.TransmitBytes
+
+			delta.TotalReceiveBytes = s.totalRX
+			delta.TotalTransmitBytes = s.totalTX
+			out = append(out, delta)
+		}
+
+		for k, s := range st.peers {
+			if k.device == d.Name && !present[k.peer] {
+				s.present = false
+			}
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Device != out[j].Device {
+			return out[i].Device < out[j].Device
+		}
+
+		return bytes.Compare(out[i].PublicKey[:], out[j].PublicKey[:]) < 0
+	})
+
+	return out
+}
+
+// Totals returns the bytes received from and sent to the peer on device since
+// it was first observed. If the peer has never been observed, or has been
+// forgotten, ok is false.
+func (st *StatsTracker) Totals(device string, peer wgtypes.Key) (rx, tx int64, ok bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	s, ok := st.peers[flapKey{device: device, peer: peer}]
+	if !ok {
+		return 0, 0, false
+	}
+
+	return s.totalRX, s.totalTX, true
+}
+
+// Forget discards the totals of the peer on device. If the peer is observed
+// again, it is counted as a new peer.
+func (st *StatsTracker) Forget(device string, peer wgtypes.Key) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.peers, flapKey{device: device, peer: peer})
+}
+
+// A StatsRecord is the state of a peer tracked by a StatsTracker, which can be
+// persisted so that totals survive restarts of the tracking process and of
+// the host.
+type StatsRecord struct {
+	// Device and PublicKey identify the peer.
+	Device    string
+	PublicKey wgtypes.Key
+
+	// ReceiveBytes and TransmitBytes are the peer's counters at the latest
+	// observation, unless Removed is set.
+	ReceiveBytes, TransmitBytes int64
+
+	// TotalReceiveBytes and TotalTransmitBytes are the totals since the peer
+	// was first observed.
+	TotalReceiveBytes, TotalTransmitBytes int64
+
+	// Removed reports whether the peer was not present at the latest
+	// observation.
+	Removed bool
+}
+
+// Records returns the state of each tracked peer, sorted by device name and
+// public key.
+func (st *StatsTracker) Records() []StatsRecord {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	out := make([]StatsRecord, 0, len(st.peers))
+	for k, s := range st.peers {
+		r := StatsRecord{
+			Device:             k.device,
+			PublicKey:          k.peer,
+			TotalReceiveBytes:  s.totalRX,
+			TotalTransmitBytes: s.totalTX,
+			Removed:            !s.present,
+		}
+		if s.present {
+			r.ReceiveBytes, r.TransmitBytes = s.rx, s.tx
+		}
+
+		out = append(out, r)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Device != out[j].Device {
+			return out[i].Device < out[j].Device
+		}
+
+		return bytes.Compare(out[i].PublicKey[:], out[j].PublicKey[:]) < 0
+	})
+
+	return out
+}
+
+// Restore replaces the state of the StatsTracker with records, typically
+// those returned by Records before the tracking process restarted.
+//
+// On the next observation, a peer whose counters are at least those in its
+// record is assumed to have kept counting, and only the difference is added
+// to its totals. A peer whose counters are lower, such as after a reboot or
+// the recreation of its device, is assumed to have started again from zero.
+// A device restarted while the tracker was not running whose counters have
+// already exceeded their recorded values cannot be detected, and its traffic
+// up to the recorded values is not counted.
+func (st *StatsTracker) Restore(records []StatsRecord) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.devices = make(map[string]bool)
+	st.peers = make(map[flapKey]*statsState, len(records))
+
+	for _, r := range records {
+		st.peers[flapKey{device: r.Device, peer: r.PublicKey}] = &statsState{
+			present: !r.Removed,
+			rx:      r.ReceiveBytes,
+			tx:      r.TransmitBytes,
+			totalRX: r.TotalReceiveBytes,
+			totalTX: r.TotalTransmitBytes,
+		}
+	}
+}
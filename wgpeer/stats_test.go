@@ -0,0 +1,190 @@
+package wgpeer_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestStatsTracker(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+	)
+
+	// Order the keys as Observe does.
+	if string(b[:]) < string(a[:]) {
+		a, b = b, a
+	}
+
+	type counters struct {
+		peer   wgtypes.Key
+		rx, tx int64
+	}
+
+	device := func(peers ...counters) []*wgtypes.Device {
+		d := &wgtypes.Device{Name: "wg0"}
+		for _, p := range peers {
+			d.Peers = append(d.Peers, wgtypes.Peer{
+				PublicKey:     p.peer,
+				ReceiveBytes:  p.rx,
+				TransmitBytes: p.tx,
+			})
+		}
+
+		return []*wgtypes.Device{d}
+	}
+
+	delta := func(peer wgtypes.Key, rx, tx, totalRX, totalTX int64, reset bool) wgpeer.StatsDelta {
+		return wgpeer.StatsDelta{
+			Device:             "wg0",
+			PublicKey:          peer,
+			ReceiveBytes:       rx,
+			TransmitBytes:      tx,
+			TotalReceiveBytes:  totalRX,
+			TotalTransmitBytes: totalTX,
+			Reset:              reset,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		devices []*wgtypes.Device
+		want    []wgpeer.StatsDelta
+	}{
+		{
+			name:    "baseline",
+			devices: device(counters{a, 100, 200}),
+			want:    []wgpeer.StatsDelta{delta(a, 0, 0, 0, 0, false)},
+		},
+		{
+			name:    "traffic and new peer",
+			devices: device(counters{a, 150, 300}, counters{b, 10, 20}),
+			want: []wgpeer.StatsDelta{
+				delta(a, 50, 100, 50, 100, false),
+				delta(b, 10, 20, 10, 20, false),
+			},
+		},
+		{
+			name:    "peer removed",
+			devices: device(counters{a, 160, 300}),
+			want:    []wgpeer.StatsDelta{delta(a, 10, 0, 60, 100, false)},
+		},
+		{
+			name:    "peer added again",
+			devices: device(counters{a, 160, 300}, counters{b, 5, 5}),
+			want: []wgpeer.StatsDelta{
+				delta(a, 0, 0, 60, 100, false),
+				delta(b, 5, 5, 15, 25, true),
+			},
+		},
+		{
+			name:    "device restarted",
+			devices: device(counters{a, 20, 30}, counters{b, 6, 7}),
+			want: []wgpeer.StatsDelta{
+				delta(a, 20, 30, 80, 130, true),
+				delta(b, 1, 2, 16, 27, false),
+			},
+		},
+		{
+			name: "device removed",
+		},
+		{
+			name:    "device recreated",
+			devices: device(counters{a, 1, 2}),
+			want:    []wgpeer.StatsDelta{delta(a, 1, 2, 81, 132, true)},
+		},
+	}
+
+	var st wgpeer.StatsTracker
+	for _, tt := range tests {
+		// Each step depends on the previous one, so the steps cannot be run
+		// as independent subtests.
+		got := st.Observe(tt.devices)
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Fatalf("%s: unexpected deltas (-want +got):\n%s", tt.name, diff)
+		}
+	}
+
+	rx, tx, ok := st.Totals("wg0", b)
+	if diff := cmp.Diff([]int64{16, 27}, []int64{rx, tx}); !ok || diff != "" {
+		t.Fatalf("unexpected totals (-want +got):\n%s", diff)
+	}
+
+	st.Forget("wg0", b)
+	if _, _, ok := st.Totals("wg0", b); ok {
+		t.Fatal("expected forgotten peer to have no totals")
+	}
+}
+
+func TestStatsTrackerPersistence(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		b = wgtest.MustPublicKey()
+	)
+
+	device := func(rxA, rxB int64) []*wgtypes.Device {
+		return []*wgtypes.Device{{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{
+				{PublicKey: a, ReceiveBytes: rxA},
+				{PublicKey: b, ReceiveBytes: rxB},
+			},
+		}}
+	}
+
+	var st wgpeer.StatsTracker
+	st.Observe(device(100, 100))
+	st.Observe(device(150, 300))
+
+	s := &wgpeer.FileStatsStore{Path: filepath.Join(t.TempDir(), "stats.json")}
+
+	// Nothing has been saved yet.
+	records, err := s.LoadStats()
+	if err != nil {
+		t.Fatalf("failed to load empty stats: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("expected no records, but got: %v", records)
+	}
+
+	if err := s.SaveStats(st.Records()); err != nil {
+		t.Fatalf("failed to save stats: %v", err)
+	}
+
+	records, err = s.LoadStats()
+	if err != nil {
+		t.Fatalf("failed to load stats: %v", err)
+	}
+
+	if diff := cmp.Diff(st.Records(), records); diff != "" {
+		t.Fatalf("unexpected records (-want +got):\n%s", diff)
+	}
+
+	// After a restart of the tracking process, peer a kept counting while
+	// peer b's device was recreated and its counters were reset.
+	var restored wgpeer.StatsTracker
+	restored.Restore(records)
+	restored.Observe(device(170, 10))
+
+	for _, tt := range []struct {
+		peer wgtypes.Key
+		rx   int64
+	}{
+		{peer: a, rx: 70},
+		{peer: b, rx: 210},
+	} {
+		rx, _, ok := restored.Totals("wg0", tt.peer)
+		if !ok {
+			t.Fatalf("no totals for peer %s", tt.peer)
+		}
+
+		if diff := cmp.Diff(tt.rx, rx); diff != "" {
+			t.Fatalf("unexpected received bytes for %s (-want +got):\n%s", tt.peer, diff)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package wgpeer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A StatsStore persists the StatsRecords of a StatsTracker.
+type StatsStore interface {
+	// SaveStats replaces the stored records with records.
+	SaveStats(records []StatsRecord) error
+
+	// LoadStats returns the stored records. If no records have been saved,
+	// it returns no records and no error.
+	LoadStats() ([]StatsRecord, error)
+}
+
+// A FileStatsStore is a StatsStore which stores records as JSON in a file.
+// The file is replaced atomically on each save, so that a crash or power
+// loss leaves either the previous or the new records in place.
+type FileStatsStore struct {
+	// Path is the path to the file.
+	Path string
+}
+
+var _ StatsStore = &FileStatsStore{}
+
+// A statsRecordJSON is the JSON representation of a StatsRecord.
+type statsRecordJSON struct {
+	Device             string `json:"device"`
+	PublicKey          string `json:"publicKey"`
+	ReceiveBytes       int64  `json:"receiveBytes"`
+	TransmitBytes      int64  `json:"transmitBytes"`
+	TotalReceiveBytes  int64  `json:"totalReceiveBytes"`
+	TotalTransmitBytes int64  `json:"totalTransmitBytes"`
+	Removed            bool   `json:"removed,omitempty"`
+}
+
+// SaveStats implements StatsStore.
+func (s *FileStatsStore) SaveStats(records []StatsRecord) error {
+	rjs := make([]statsRecordJSON, 0, len(records))
+	for _, r := range records {
+		rjs = append(rjs, statsRecordJSON{
+			Device:             r.Device,
+			PublicKey:          r.PublicKey.String(),
+			ReceiveBytes:       r.ReceiveBytes,
+			TransmitBytes:      r.TransmitBytes,
+			TotalReceiveBytes:  r.TotalReceiveBytes,
+			TotalTransmitBytes: r.TotalTransmitBytes,
+			Removed:            r.Removed,
+		})
+	}
+
+	b, err := json.MarshalIndent(rjs, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(s.Path), ".wgpeer-stats-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.Path)
+}
+
+// LoadStats implements StatsStore.
+func (s *FileStatsStore) LoadStats() ([]StatsRecord, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var rjs []statsRecordJSON
+	if err := json.Unmarshal(b, &rjs); err != nil {
+		return nil, fmt.Errorf("wgpeer: failed to parse stats file: %v", err)
+	}
+
+	records := make([]StatsRecord, 0, len(rjs))
+	for _, rj := range rjs {
+		key, err := wgtypes.ParseKey(rj.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("wgpeer: invalid public key in stats file: %v", err)
+		}
+
+		records = append(records, StatsRecord{
+			Device:             rj.Device,
+			PublicKey:          key,
+			ReceiveBytes:       rj.ReceiveBytes,
+			TransmitBytes:      rj.TransmitBytes,
+			TotalReceiveBytes:  rj.TotalReceiveBytes,
+			TotalTransmitBytes: rj.TotalTransmitBytes,
+			Removed:            rj.Removed,
+		})
+	}
+
+	return records, nil
+}
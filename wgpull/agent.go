@@ -0,0 +1,272 @@
+package wgpull
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgbundle"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Client applies configurations, such as *wgctrl.Client.
+type Client interface {
+	SyncConfig(name string, cfg wgtypes.Config) error
+}
+
+// DefaultInterval is the default interval between fetches by an Agent.
+const DefaultInterval = time.Minute
+
+// maxBundleSize is the largest signed Bundle an Agent will download.
+const maxBundleSize = 16 << 20
+
+// An Agent fetches a signed Bundle and applies it to the local devices.
+type Agent struct {
+	// URL is the HTTPS URL of the signed Bundle.
+	URL string
+
+	// Keys are the keys trusted to sign Bundles. More than one key may be
+	// trusted while keys are rotated.
+	Keys wgbundle.Keyring
+
+	// HTTPClient fetches Bundles. If nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	// Interval is the interval between fetches by Run. If zero,
+	// DefaultInterval is used.
+	Interval time.Duration
+
+	// OnSync, if set, is called by Run after each Sync with the applied
+	// Bundle, if any, and the result.
+	OnSync func(b *wgbundle.Bundle, err error)
+
+	// Now returns the current time, to check whether a Bundle has expired.
+	// If nil, time.Now is used.
+	Now func() time.Time
+
+	// SerialFile, if set, is the path of a file which stores the highest
+	// serial number of an applied Bundle, so that older Bundles are still
+	// rejected after the Agent restarts. If SerialFile is not set, the
+	// serial number is only kept in memory, and a restarted Agent accepts
+	// any unexpired Bundle.
+	SerialFile string
+
+	etag   string
+	serial uint64
+	loaded bool
+}
+
+// Run calls Sync every interval until ctx is canceled. Errors are reported
+// to OnSync rather than stopping Run, so that a host which cannot reach the
+// server, or receives a bad Bundle, recovers once a good one is available.
+func (a *Agent) Run(ctx context.Context, c Client) error {
+	interval := a.Interval
+	if interval == 0 {
+		interval = DefaultInterval
+	}
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		b, err := a.Sync(ctx, c)
+		if a.OnSync != nil {
+			a.OnSync(b, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Sync fetches the Bundle and, if it has changed since the last successful
+// Sync, verifies it and applies the configuration of each of its devices
+// using c. It returns the applied Bundle, or nil if the Bundle has not
+// changed.
+//
+// A Bundle which has expired, or whose serial number is lower than that of
+// a Bundle already applied, is rejected. If any device cannot be
+// configured, the others are still configured, and the Bundle is applied
+// again by the next Sync.
+func (a *Agent) Sync(ctx context.Context, c Client) (*wgbundle.Bundle, error) {
+	data, etag, err := a.fetch(ctx)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	b, err := wgbundle.Verify(data, a.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now
+	if a.Now != nil {
+		now = a.Now
+	}
+
+	if b.Expired(now()) {
+		return nil, fmt.Errorf("wgpull: bundle %d expired at %s", b.Serial, b.Expires.Format(time.RFC3339))
+	}
+	if !a.loaded {
+		serial, err := a.loadSerial()
+		if err != nil {
+			return nil, err
+		}
+
+		a.serial, a.loaded = serial, true
+	}
+
+	if b.Serial < a.serial {
+		return nil, fmt.Errorf("wgpull: bundle %d is older than applied bundle %d", b.Serial, a.serial)
+	}
+
+	// Record the serial before applying the Bundle, so that it is never
+	// applied without being recorded.
+	if b.Serial > a.serial {
+		if err := a.saveSerial(b.Serial); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(b.Devices))
+	for name := range b.Devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		if err := c.SyncConfig(name, b.Devices[name]); err != nil {
+			errs = append(errs, fmt.Errorf("wgpull: failed to configure %s: %w", name, err))
+		}
+	}
+
+	// Keep the serial even if some devices failed, since they were
+	// reconfigured using the Bundle, but keep fetching it until every
+	// device is configured.
+	a.serial = b.Serial
+	if err := errors.Join(errs...); err != nil {
+		a.etag = ""
+		return b, err
+	}
+
+	a.etag = etag
+	return b, nil
+}
+
+// loadSerial returns the serial number stored in SerialFile, or zero if it
+// is not set or does not exist.
+func (a *Agent) loadSerial() (uint64, error) {
+	if a.SerialFile == "" {
+		return 0, nil
+	}
+
+	b, err := os.ReadFile(a.SerialFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("wgpull: failed to read serial file: %w", err)
+	}
+
+	serial, err := strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("wgpull: invalid serial file: %v", err)
+	}
+
+	return serial, nil
+}
+
+// saveSerial atomically replaces the contents of SerialFile, if set, with
+// serial.
+func (a *Agent) saveSerial(serial uint64) error {
+	if a.SerialFile == "" {
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(a.SerialFile), ".wgpull-serial-*")
+	if err != nil {
+		return fmt.Errorf("wgpull: failed to write serial file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(strconv.FormatUint(serial, 10) + "\n"); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("wgpull: failed to write serial file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("wgpull: failed to write serial file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("wgpull: failed to write serial file: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), a.SerialFile); err != nil {
+		return fmt.Errorf("wgpull: failed to write serial file: %w", err)
+	}
+
+	return nil
+}
+
+// fetch retrieves the signed Bundle and its ETag, or returns no data if it
+// has not changed since the last successful Sync.
+func (a *Agent) fetch(ctx context.Context) ([]byte, string, error) {
+	u, err := url.Parse(a.URL)
+	if err != nil {
+		return nil, "", fmt.Errorf("wgpull: invalid URL: %v", err)
+	}
+	if u.Scheme != "https" {
+		return nil, "", fmt.Errorf("wgpull: bundles must be fetched using HTTPS, not %q", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if a.etag != "" {
+		req.Header.Set("If-None-Match", a.etag)
+	}
+
+	hc := a.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("wgpull: failed to fetch bundle: %w", err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotModified:
+		return nil, "", nil
+	default:
+		return nil, "", fmt.Errorf("wgpull: failed to fetch bundle: %s", res.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(res.Body, maxBundleSize+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("wgpull: failed to read bundle: %w", err)
+	}
+	if len(data) > maxBundleSize {
+		return nil, "", fmt.Errorf("wgpull: bundle exceeds %d bytes", maxBundleSize)
+	}
+
+	return data, res.Header.Get("ETag"), nil
+}
@@ -0,0 +1,219 @@
+package wgpull_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgbundle"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpull"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestAgentSync(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var (
+		mu      sync.Mutex
+		data    []byte
+		fetches int
+	)
+
+	// publish signs b, which is served with an ETag derived from its contents.
+	publish := func(b *wgbundle.Bundle, key ed25519.PrivateKey) {
+		t.Helper()
+
+		signed, err := wgbundle.Sign(b, key)
+		if err != nil {
+			t.Fatalf("failed to sign bundle: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		data = signed
+	}
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256(data))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	var (
+		c    testClient
+		now  = time.Unix(1600000000, 0)
+		port = 51820
+		a    = &wgpull.Agent{
+			URL:        srv.URL,
+			Keys:       wgbundle.NewKeyring(pub),
+			HTTPClient: srv.Client(),
+			Now:        func() time.Time { return now },
+		}
+	)
+
+	cfg := wgtypes.Config{
+		ListenPort: &port,
+		Peers:      []wgtypes.PeerConfig{{PublicKey: wgtest.MustPublicKey()}},
+	}
+
+	sync := func() *wgbundle.Bundle {
+		t.Helper()
+
+		b, err := a.Sync(context.Background(), &c)
+		if err != nil {
+			t.Fatalf("failed to sync: %v", err)
+		}
+
+		return b
+	}
+
+	publish(&wgbundle.Bundle{Serial: 2, Devices: map[string]wgtypes.Config{"wg0": cfg}}, priv)
+	if b := sync(); b == nil || b.Serial != 2 {
+		t.Fatalf("unexpected bundle: %v", b)
+	}
+
+	// The unchanged bundle is not downloaded or applied again.
+	if b := sync(); b != nil {
+		t.Fatalf("unexpected bundle: %v", b)
+	}
+
+	want := map[string][]wgtypes.Config{"wg0": {cfg}}
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		b    *wgbundle.Bundle
+		key  ed25519.PrivateKey
+	}{
+		{name: "wrong key", b: &wgbundle.Bundle{Serial: 3}, key: other},
+		{name: "rollback", b: &wgbundle.Bundle{Serial: 1}, key: priv},
+		{name: "expired", b: &wgbundle.Bundle{Serial: 3, Expires: now.Add(-time.Second)}, key: priv},
+	} {
+		publish(tt.b, tt.key)
+		if _, err := a.Sync(context.Background(), &c); err == nil {
+			t.Fatalf("%s: expected an error, but none occurred", tt.name)
+		} else {
+			t.Logf("OK error: %s: %v", tt.name, err)
+		}
+	}
+
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations after rejected bundles (-want +got):\n%s", diff)
+	}
+
+	// Plain HTTP is refused before any request is made.
+	fetched := fetches
+	a.URL = "http" + srv.URL[len("https"):]
+	if _, err := a.Sync(context.Background(), &c); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+	if fetches != fetched {
+		t.Fatal("bundle was fetched over plain HTTP")
+	}
+}
+
+func TestAgentSerialFile(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var data []byte
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "serial")
+	agent := func() *wgpull.Agent {
+		return &wgpull.Agent{
+			URL:        srv.URL,
+			Keys:       wgbundle.NewKeyring(pub),
+			HTTPClient: srv.Client(),
+			SerialFile: path,
+		}
+	}
+
+	publish := func(serial uint64) {
+		t.Helper()
+
+		signed, err := wgbundle.Sign(&wgbundle.Bundle{Serial: serial}, priv)
+		if err != nil {
+			t.Fatalf("failed to sign bundle: %v", err)
+		}
+
+		data = signed
+	}
+
+	publish(2)
+	if _, err := agent().Sync(context.Background(), &testClient{}); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+
+	// A restarted Agent still rejects an older Bundle.
+	a := agent()
+	publish(1)
+	if _, err := a.Sync(context.Background(), &testClient{}); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	} else {
+		t.Logf("OK error: %v", err)
+	}
+
+	publish(3)
+	if _, err := a.Sync(context.Background(), &testClient{}); err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read serial file: %v", err)
+	}
+
+	if diff := cmp.Diff("3\n", string(b)); diff != "" {
+		t.Fatalf("unexpected serial file (-want +got):\n%s", diff)
+	}
+}
+
+type testClient struct {
+	cfgs map[string][]wgtypes.Config
+}
+
+func (c *testClient) SyncConfig(name string, cfg wgtypes.Config) error {
+	if c.cfgs == nil {
+		c.cfgs = make(map[string][]wgtypes.Config)
+	}
+
+	c.cfgs[name] = append(c.cfgs[name], cfg)
+	return nil
+}
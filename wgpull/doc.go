@@ -0,0 +1,15 @@
+// Package wgpull distributes desired device configurations to many hosts
+// using a pull model: a controller publishes a signed wgbundle.Bundle at an
+// HTTPS URL, and an Agent on each host periodically fetches it and reconciles
+// the local devices it describes.
+//
+// Agents only apply Bundles signed by a trusted key, so that the web server
+// which publishes them need not be trusted. Each Bundle carries a serial
+// number, and an Agent never applies a Bundle older than one it has already
+// applied, so that an attacker cannot roll a host back to a previously
+// published configuration. An Agent must be given a SerialFile to remember
+// the serial number across restarts. Agents use ETags so that an unchanged
+// Bundle is not downloaded again.
+//
+// Bundles may contain private keys, so they are only fetched over HTTPS.
+package wgpull // import "golang.zx2c4.com/wireguard/wgctrl/wgpull"
@@ -0,0 +1,15 @@
+// Package wgrelay models hub-and-spoke WireGuard networks, in which a relay
+// or "bounce server" forwards traffic between spokes which cannot reach each
+// other directly.
+//
+// A Network describes the hub and its spokes. HubConfig and SpokeConfig
+// produce the classic configurations for each: the hub has a peer for each
+// spoke with the spoke's own allowed IPs, and each spoke has a single peer,
+// the hub, whose allowed IPs include those of every other spoke.
+//
+// Relaying doubles the path and load of traffic between spokes. Shortcuts
+// finds pairs of spokes which appear reachable from each other, judging by the
+// endpoints from which the hub sees them, and ShortcutConfigs produces the
+// changes which connect such a pair directly while the hub remains in place
+// for all other traffic.
+package wgrelay // import "golang.zx2c4.com/wireguard/wgctrl/wgrelay"
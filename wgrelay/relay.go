@@ -0,0 +1,260 @@
+package wgrelay
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Hub is the relay through which spokes reach each other.
+type Hub struct {
+	// PublicKey identifies the hub.
+	PublicKey wgtypes.Key
+
+	// Endpoint is the address at which spokes reach the hub.
+	Endpoint *net.UDPAddr
+
+	// AllowedIPs are the hub's own addresses and any networks behind it.
+	AllowedIPs []net.IPNet
+}
+
+// A Spoke is a peer of the hub which reaches other spokes through it.
+type Spoke struct {
+	// PublicKey identifies the spoke.
+	PublicKey wgtypes.Key
+
+	// Endpoint is the address from which the hub sees the spoke, or nil if
+	// the spoke has not yet completed a handshake.
+	Endpoint *net.UDPAddr
+
+	// ListenPort is the spoke's configured listen port, or zero if unknown.
+	ListenPort int
+
+	// AllowedIPs are the spoke's own addresses and any networks behind it.
+	AllowedIPs []net.IPNet
+}
+
+// A Shortcut is a pair of spokes which are connected directly instead of
+// through the hub.
+type Shortcut struct {
+	A, B wgtypes.Key
+}
+
+// A Network is a hub and its spokes.
+type Network struct {
+	Hub    Hub
+	Spokes []Spoke
+
+	// Shortcuts are the pairs of spokes which are connected directly.
+	Shortcuts []Shortcut
+
+	// PersistentKeepaliveInterval, if non-zero, is set on each spoke's peers
+	// so that the NAT mappings which spokes are reached through stay open.
+	PersistentKeepaliveInterval time.Duration
+}
+
+// FromDevice produces a Network from the hub's device, with a spoke for each
+// of its peers. Spokes' listen ports are not known to the hub, and must be
+// filled in by the caller for Shortcuts to find any pairs.
+func FromDevice(d *wgtypes.Device, hubEndpoint *net.UDPAddr, hubIPs []net.IPNet) Network {
+	n := Network{
+		Hub: Hub{
+			PublicKey:  d.PublicKey,
+			Endpoint:   hubEndpoint,
+			AllowedIPs: hubIPs,
+		},
+		Spokes: make([]Spoke, 0, len(d.Peers)),
+	}
+
+	for _, p := range d.Peers {
+		n.Spokes = append(n.Spokes, Spoke{
+			PublicKey:  p.PublicKey,
+			Endpoint:   p.Endpoint,
+			AllowedIPs: p.AllowedIPs,
+		})
+	}
+
+	return n
+}
+
+// HubConfig produces the configuration of the hub, which has a peer for each
+// spoke with the spoke's own allowed IPs. Shortcuts do not affect the hub.
+func HubConfig(n Network) wgtypes.Config {
+	peers := make([]wgtypes.PeerConfig, 0, len(n.Spokes))
+	for _, s := range n.Spokes {
+		peers = append(peers, wgtypes.PeerConfig{
+			PublicKey:         s.PublicKey,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        s.AllowedIPs,
+		})
+	}
+
+	return wgtypes.Config{ReplacePeers: true, Peers: peers}
+}
+
+// SpokeConfig produces the configuration of the spoke identified by
+// publicKey, which has the hub as a peer with the allowed IPs of the hub and
+// of every spoke it is not connected to by a Shortcut, and a peer for each
+// spoke it is.
+func SpokeConfig(n Network, publicKey wgtypes.Key) (wgtypes.Config, error) {
+	if _, ok := n.spoke(publicKey); !ok {
+		return wgtypes.Config{}, fmt.Errorf("wgrelay: network has no spoke %s", publicKey)
+	}
+
+	direct := n.shortcuts(publicKey)
+
+	hub := wgtypes.PeerConfig{
+		PublicKey:         n.Hub.PublicKey,
+		Endpoint:          n.Hub.Endpoint,
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        append([]net.IPNet(nil), n.Hub.AllowedIPs...),
+	}
+	n.keepalive(&hub)
+
+	peers := []wgtypes.PeerConfig{hub}
+	for _, s := range n.Spokes {
+		switch {
+		case s.PublicKey == publicKey:
+		case direct[s.PublicKey]:
+			peers = append(peers, n.direct(s))
+		default:
+			peers[0].AllowedIPs = append(peers[0].AllowedIPs, s.AllowedIPs...)
+		}
+	}
+
+	return wgtypes.Config{ReplacePeers: true, Peers: peers}, nil
+}
+
+// Shortcuts returns the pairs of spokes which are not yet connected by a
+// Shortcut but which appear able to reach each other directly, ordered by
+// public key.
+//
+// A spoke appears reachable when the hub sees it at its own listen port, which
+// suggests that it is not behind a NAT which translates ports, and so accepts
+// packets from any peer at that endpoint. A pair qualifies when both spokes
+// appear reachable at different IP addresses; spokes behind the same address
+// would depend on their NAT hairpinning packets between them. This is a
+// heuristic, and firewalls may still prevent a direct connection.
+func Shortcuts(n Network) []Shortcut {
+	var spokes []Spoke
+	for _, s := range n.Spokes {
+		if s.Endpoint != nil && s.ListenPort != 0 && s.Endpoint.Port == s.ListenPort {
+			spokes = append(spokes, s)
+		}
+	}
+
+	sort.Slice(spokes, func(i, j int) bool {
+		return bytes.Compare(spokes[i].PublicKey[:], spokes[j].PublicKey[:]) < 0
+	})
+
+	var out []Shortcut
+	for i := range spokes {
+		direct := n.shortcuts(spokes[i].PublicKey)
+		for j := i + 1; j < len(spokes); j++ {
+			a, b := spokes[i], spokes[j]
+			if direct[b.PublicKey] || a.Endpoint.IP.Equal(b.Endpoint.IP) {
+				continue
+			}
+
+			out = append(out, Shortcut{A: a.PublicKey, B: b.PublicKey})
+		}
+	}
+
+	return out
+}
+
+// ShortcutConfigs produces the changes which connect the spokes of s
+// directly, keyed by the public key of the spoke each applies to. Each change
+// adds the other spoke as a peer and removes its allowed IPs from the hub.
+// The hub needs no changes.
+//
+// Once applied, s should be added to n.Shortcuts so that SpokeConfig produces
+// the same configuration.
+func ShortcutConfigs(n Network, s Shortcut) (map[wgtypes.Key]wgtypes.Config, error) {
+	a, ok := n.spoke(s.A)
+	if !ok {
+		return nil, fmt.Errorf("wgrelay: network has no spoke %s", s.A)
+	}
+	b, ok := n.spoke(s.B)
+	if !ok {
+		return nil, fmt.Errorf("wgrelay: network has no spoke %s", s.B)
+	}
+	if s.A == s.B {
+		return nil, fmt.Errorf("wgrelay: cannot shortcut spoke %s to itself", s.A)
+	}
+
+	n.Shortcuts = append(append([]Shortcut(nil), n.Shortcuts...), s)
+
+	out := make(map[wgtypes.Key]wgtypes.Config, 2)
+	for _, pair := range [][2]Spoke{{a, b}, {b, a}} {
+		cfg, err := SpokeConfig(n, pair[0].PublicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only the hub's allowed IPs and the new peer change.
+		out[pair[0].PublicKey] = wgtypes.Config{Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:         n.Hub.PublicKey,
+				UpdateOnly:        true,
+				ReplaceAllowedIPs: true,
+				AllowedIPs:        cfg.Peers[0].AllowedIPs,
+			},
+			n.direct(pair[1]),
+		}}
+	}
+
+	return out, nil
+}
+
+// spoke returns the spoke identified by publicKey.
+func (n Network) spoke(publicKey wgtypes.Key) (Spoke, bool) {
+	for _, s := range n.Spokes {
+		if s.PublicKey == publicKey {
+			return s, true
+		}
+	}
+
+	return Spoke{}, false
+}
+
+// shortcuts returns the set of spokes connected directly to publicKey.
+func (n Network) shortcuts(publicKey wgtypes.Key) map[wgtypes.Key]bool {
+	direct := make(map[wgtypes.Key]bool)
+	for _, s := range n.Shortcuts {
+		switch publicKey {
+		case s.A:
+			direct[s.B] = true
+		case s.B:
+			direct[s.A] = true
+		}
+	}
+
+	return direct
+}
+
+// direct produces the configuration of a peer for a spoke which is connected
+// directly.
+func (n Network) direct(s Spoke) wgtypes.PeerConfig {
+	pc := wgtypes.PeerConfig{
+		PublicKey:         s.PublicKey,
+		Endpoint:          s.Endpoint,
+		ReplaceAllowedIPs: true,
+		AllowedIPs:        s.AllowedIPs,
+	}
+	n.keepalive(&pc)
+
+	return pc
+}
+
+// keepalive sets the persistent keepalive interval of pc, if any.
+func (n Network) keepalive(pc *wgtypes.PeerConfig) {
+	if n.PersistentKeepaliveInterval != 0 {
+		d := n.PersistentKeepaliveInterval
+		pc.PersistentKeepaliveInterval = &d
+	}
+}
@@ -0,0 +1,195 @@
+package wgrelay_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgrelay"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestRelay(t *testing.T) {
+	var (
+		hub = wgtest.MustPublicKey()
+		a   = wgtest.MustPublicKey()
+		b   = wgtest.MustPublicKey()
+		c   = wgtest.MustPublicKey()
+
+		hubEP = wgtest.MustUDPAddr("192.0.2.1:51820")
+		aEP   = wgtest.MustUDPAddr("198.51.100.1:51820")
+		bEP   = wgtest.MustUDPAddr("203.0.113.1:51820")
+
+		hubIP = wgtest.MustCIDR("10.0.0.1/32")
+		aIP   = wgtest.MustCIDR("10.0.0.2/32")
+		bIP   = wgtest.MustCIDR("10.0.0.3/32")
+		cIP   = wgtest.MustCIDR("10.0.0.4/32")
+
+		keepalive = 25 * time.Second
+	)
+
+	n := wgrelay.FromDevice(&wgtypes.Device{
+		PublicKey: hub,
+		Peers: []wgtypes.Peer{
+			{PublicKey: a, Endpoint: aEP, AllowedIPs: []net.IPNet{aIP}},
+			{PublicKey: b, Endpoint: bEP, AllowedIPs: []net.IPNet{bIP}},
+			// c is behind a NAT which translates its port.
+			{PublicKey: c, Endpoint: wgtest.MustUDPAddr("203.0.113.2:40000"), AllowedIPs: []net.IPNet{cIP}},
+		},
+	}, hubEP, []net.IPNet{hubIP})
+
+	n.PersistentKeepaliveInterval = keepalive
+	for i := range n.Spokes {
+		n.Spokes[i].ListenPort = 51820
+	}
+
+	wantHub := wgtypes.Config{
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{PublicKey: a, ReplaceAllowedIPs: true, AllowedIPs: []net.IPNet{aIP}},
+			{PublicKey: b, ReplaceAllowedIPs: true, AllowedIPs: []net.IPNet{bIP}},
+			{PublicKey: c, ReplaceAllowedIPs: true, AllowedIPs: []net.IPNet{cIP}},
+		},
+	}
+
+	if diff := cmp.Diff(wantHub, wgrelay.HubConfig(n)); diff != "" {
+		t.Fatalf("unexpected hub configuration (-want +got):\n%s", diff)
+	}
+
+	cfg, err := wgrelay.SpokeConfig(n, a)
+	if err != nil {
+		t.Fatalf("failed to produce spoke configuration: %v", err)
+	}
+
+	wantA := wgtypes.Config{
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   hub,
+			Endpoint:                    hubEP,
+			PersistentKeepaliveInterval: &keepalive,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs:                  []net.IPNet{hubIP, bIP, cIP},
+		}},
+	}
+
+	if diff := cmp.Diff(wantA, cfg); diff != "" {
+		t.Fatalf("unexpected spoke configuration (-want +got):\n%s", diff)
+	}
+
+	want := []wgrelay.Shortcut{{A: a, B: b}}
+	if string(b[:]) < string(a[:]) {
+		want = []wgrelay.Shortcut{{A: b, B: a}}
+	}
+
+	got := wgrelay.Shortcuts(n)
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected shortcuts (-want +got):\n%s", diff)
+	}
+
+	changes, err := wgrelay.ShortcutConfigs(n, got[0])
+	if err != nil {
+		t.Fatalf("failed to produce shortcut configurations: %v", err)
+	}
+
+	wantChanges := map[wgtypes.Key]wgtypes.Config{
+		a: {Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:         hub,
+				UpdateOnly:        true,
+				ReplaceAllowedIPs: true,
+				AllowedIPs:        []net.IPNet{hubIP, cIP},
+			},
+			{
+				PublicKey:                   b,
+				Endpoint:                    bEP,
+				PersistentKeepaliveInterval: &keepalive,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs:                  []net.IPNet{bIP},
+			},
+		}},
+		b: {Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:         hub,
+				UpdateOnly:        true,
+				ReplaceAllowedIPs: true,
+				AllowedIPs:        []net.IPNet{hubIP, cIP},
+			},
+			{
+				PublicKey:                   a,
+				Endpoint:                    aEP,
+				PersistentKeepaliveInterval: &keepalive,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs:                  []net.IPNet{aIP},
+			},
+		}},
+	}
+
+	if diff := cmp.Diff(wantChanges, changes); diff != "" {
+		t.Fatalf("unexpected shortcut configurations (-want +got):\n%s", diff)
+	}
+
+	// Once recorded, the shortcut is reflected in the spoke configuration and
+	// no longer suggested.
+	n.Shortcuts = got
+	if s := wgrelay.Shortcuts(n); len(s) != 0 {
+		t.Fatalf("unexpected shortcuts: %v", s)
+	}
+
+	cfg, err = wgrelay.SpokeConfig(n, a)
+	if err != nil {
+		t.Fatalf("failed to produce spoke configuration: %v", err)
+	}
+
+	wantA.Peers[0].AllowedIPs = []net.IPNet{hubIP, cIP}
+	wantA.Peers = append(wantA.Peers, wantChanges[a].Peers[1])
+	if diff := cmp.Diff(wantA, cfg); diff != "" {
+		t.Fatalf("unexpected spoke configuration (-want +got):\n%s", diff)
+	}
+}
+
+func TestRelayErrors(t *testing.T) {
+	var (
+		a = wgtest.MustPublicKey()
+		n = wgrelay.Network{Spokes: []wgrelay.Spoke{{PublicKey: a}}}
+	)
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{
+			name: "unknown spoke",
+			fn: func() error {
+				_, err := wgrelay.SpokeConfig(n, wgtest.MustPublicKey())
+				return err
+			},
+		},
+		{
+			name: "unknown shortcut spoke",
+			fn: func() error {
+				_, err := wgrelay.ShortcutConfigs(n, wgrelay.Shortcut{A: a, B: wgtest.MustPublicKey()})
+				return err
+			},
+		},
+		{
+			name: "shortcut to itself",
+			fn: func() error {
+				_, err := wgrelay.ShortcutConfigs(n, wgrelay.Shortcut{A: a, B: a})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.fn()
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
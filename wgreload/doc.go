@@ -0,0 +1,15 @@
+// Package wgreload keeps a WireGuard device in sync with a configuration file
+// on disk, so that editing the file updates the tunnel without restarting
+// wg-quick(8) or interrupting unchanged peers.
+//
+// A Reloader polls the file for changes. Once the file has stopped changing
+// for a debounce interval, so that a partially written file is not applied,
+// it is decoded, validated, and applied using Client.SyncConfig, which only
+// changes what differs, as with "wg syncconf". A file which cannot be
+// decoded or validated is reported and otherwise ignored, leaving the device
+// in its last good configuration.
+//
+// Files may be in the wg(8) configuration format, in the JSON format of
+// wgtypes.Config, or in YAML using a decoder provided by the caller, since
+// this module does not depend on a YAML library.
+package wgreload // import "golang.zx2c4.com/wireguard/wgctrl/wgreload"
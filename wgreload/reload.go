@@ -0,0 +1,235 @@
+package wgreload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgconf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"golang.zx2c4.com/wireguard/wgctrl/wgyaml"
+)
+
+// A Client applies configurations, such as *wgctrl.Client.
+type Client interface {
+	SyncConfig(name string, cfg wgtypes.Config) error
+}
+
+// A Decoder decodes the contents of a configuration file.
+type Decoder func(b []byte) (wgtypes.Config, error)
+
+// Conf decodes a configuration file in the format used by wg(8) setconf.
+func Conf(b []byte) (wgtypes.Config, error) {
+	f, err := wgconf.Parse(bytes.NewReader(b))
+	if err != nil {
+		return wgtypes.Config{}, err
+	}
+
+	return f.Config()
+}
+
+// JSON decodes a configuration file in the JSON format described by
+// wgtypes.ConfigJSONSchema.
+func JSON(b []byte) (wgtypes.Config, error) {
+	var cfg wgtypes.Config
+	err := json.Unmarshal(b, &cfg)
+	return cfg, err
+}
+
+// YAML returns a Decoder for YAML configuration files in the format of
+// wgyaml.Config, using unmarshal, such as the Unmarshal function of
+// gopkg.in/yaml.v3.
+func YAML(unmarshal func(b []byte, v any) error) Decoder {
+	return func(b []byte) (wgtypes.Config, error) {
+		var c wgyaml.Config
+		if err := unmarshal(b, &c); err != nil {
+			return wgtypes.Config{}, err
+		}
+
+		return c.ToConfig()
+	}
+}
+
+// Default intervals used by a Reloader.
+const (
+	DefaultInterval = time.Second
+	DefaultDebounce = 500 * time.Millisecond
+)
+
+// A Reloader applies a configuration file to a device whenever the file
+// changes.
+type Reloader struct {
+	// Device is the name of the device to configure.
+	Device string
+
+	// Path is the path to the configuration file.
+	Path string
+
+	// Decoder decodes the file. If nil, it is chosen by the extension of
+	// Path: Conf for ".conf", and JSON for ".json".
+	Decoder Decoder
+
+	// Validate, if set, is called with each decoded configuration, which is
+	// not applied if it returns an error.
+	Validate func(cfg wgtypes.Config) error
+
+	// Interval is how often the file is checked for changes. If zero,
+	// DefaultInterval is used.
+	Interval time.Duration
+
+	// Debounce is how long the file must remain unchanged before it is
+	// applied. If zero, DefaultDebounce is used.
+	Debounce time.Duration
+
+	// OnReload, if set, is called after each attempt to apply the file,
+	// with nil if it was applied successfully.
+	OnReload func(err error)
+
+	// Polling state.
+	stat    fileStat
+	changed time.Time
+	pending bool
+	applied [sha256.Size]byte
+	tried   bool
+}
+
+// A fileStat is the part of a file's metadata which is compared to detect
+// changes.
+type fileStat struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// Run applies the file to the device, and then applies it again each time it
+// changes, until ctx is canceled. Errors decoding, validating, or applying
+// the file are reported to OnReload rather than stopping Run, so that a
+// mistake while editing the file does not stop later corrections from being
+// applied.
+func (r *Reloader) Run(ctx context.Context, c Client) error {
+	if _, err := r.decoder(); err != nil {
+		return err
+	}
+
+	t := time.NewTicker(durationOr(r.Interval, DefaultInterval))
+	defer t.Stop()
+
+	// Apply the file immediately, rather than after the first debounce.
+	r.stat = r.statFile()
+	r.pending, r.changed = true, time.Time{}
+
+	for {
+		r.poll(c, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// poll checks the file for changes at time now, and applies it once it has
+// been unchanged for the debounce interval.
+func (r *Reloader) poll(c Client, now time.Time) {
+	if st := r.statFile(); st != r.stat {
+		r.stat = st
+		r.changed = now
+		r.pending = true
+		return
+	}
+
+	if !r.pending || now.Sub(r.changed) < durationOr(r.Debounce, DefaultDebounce) {
+		return
+	}
+	r.pending = false
+
+	b, err := os.ReadFile(r.Path)
+	if err != nil {
+		r.report(fmt.Errorf("wgreload: failed to read configuration: %w", err))
+		return
+	}
+
+	// Metadata may change without the contents changing, such as when the
+	// file is touched or saved unmodified.
+	sum := sha256.Sum256(b)
+	if r.tried && sum == r.applied {
+		return
+	}
+	r.applied, r.tried = sum, true
+
+	r.report(r.apply(c, b))
+}
+
+// statFile returns the current metadata of the file.
+func (r *Reloader) statFile() fileStat {
+	fi, err := os.Stat(r.Path)
+	if err != nil {
+		return fileStat{}
+	}
+
+	return fileStat{exists: true, size: fi.Size(), modTime: fi.ModTime()}
+}
+
+// apply decodes, validates, and applies the contents of the file.
+func (r *Reloader) apply(c Client, b []byte) error {
+	dec, err := r.decoder()
+	if err != nil {
+		return err
+	}
+
+	cfg, err := dec(b)
+	if err != nil {
+		return fmt.Errorf("wgreload: invalid configuration in %s: %w", r.Path, err)
+	}
+
+	if r.Validate != nil {
+		if err := r.Validate(cfg); err != nil {
+			return fmt.Errorf("wgreload: configuration in %s rejected: %w", r.Path, err)
+		}
+	}
+
+	if err := c.SyncConfig(r.Device, cfg); err != nil {
+		return fmt.Errorf("wgreload: failed to apply configuration to %s: %w", r.Device, err)
+	}
+
+	return nil
+}
+
+// decoder returns the Decoder for the file.
+func (r *Reloader) decoder() (Decoder, error) {
+	if r.Decoder != nil {
+		return r.Decoder, nil
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(r.Path)); ext {
+	case ".conf":
+		return Conf, nil
+	case ".json":
+		return JSON, nil
+	default:
+		return nil, fmt.Errorf("wgreload: no Decoder for file extension %q", ext)
+	}
+}
+
+// report passes err to OnReload, if set.
+func (r *Reloader) report(err error) {
+	if r.OnReload != nil {
+		r.OnReload(err)
+	}
+}
+
+// durationOr returns d, or def if d is zero.
+func durationOr(d, def time.Duration) time.Duration {
+	if d == 0 {
+		return def
+	}
+
+	return d
+}
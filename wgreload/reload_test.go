@@ -0,0 +1,150 @@
+package wgreload
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestReloaderPoll(t *testing.T) {
+	var (
+		path = filepath.Join(t.TempDir(), "wg0.conf")
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+		base = time.Unix(1600000000, 0)
+	)
+
+	// write writes s to the file, with a modification time offset from
+	// base so that each write is detected.
+	write := func(s string, mod time.Duration) {
+		t.Helper()
+
+		if err := os.WriteFile(path, []byte(s), 0o600); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+		if err := os.Chtimes(path, base, base.Add(mod)); err != nil {
+			t.Fatalf("failed to set file times: %v", err)
+		}
+	}
+
+	var (
+		c    testClient
+		errs []error
+	)
+
+	r := &Reloader{
+		Device:   "wg0",
+		Path:     path,
+		Debounce: time.Second,
+		OnReload: func(err error) { errs = append(errs, err) },
+	}
+
+	conf := fmt.Sprintf("[Interface]\nPrivateKey = %s\n", priv)
+	write(conf, 0)
+
+	// The file is applied immediately on startup.
+	r.stat = r.statFile()
+	r.pending = true
+	r.poll(&c, base)
+
+	if diff := cmp.Diff([]error{nil}, errs, cmp.Comparer(errorsEqual)); diff != "" {
+		t.Fatalf("unexpected reload results (-want +got):\n%s", diff)
+	}
+
+	// An invalid edit is only decoded once it stops changing, and is not
+	// applied.
+	write("[Interface]\nPrivateKey = foo\n", time.Second)
+	r.poll(&c, base.Add(2*time.Second))
+	r.poll(&c, base.Add(2500*time.Millisecond))
+	if n := len(errs); n != 1 {
+		t.Fatalf("file was reloaded before debounce: %d", n)
+	}
+
+	r.poll(&c, base.Add(3*time.Second))
+	if len(errs) != 2 || errs[1] == nil {
+		t.Fatalf("expected an error, but got: %v", errs)
+	}
+	t.Logf("OK error: %v", errs[1])
+
+	// A correction is applied, but saving it again unmodified is not.
+	conf += fmt.Sprintf("\n[Peer]\nPublicKey = %s\n", pub)
+	write(conf, 2*time.Second)
+	r.poll(&c, base.Add(4*time.Second))
+	r.poll(&c, base.Add(5*time.Second))
+
+	write(conf, 3*time.Second)
+	r.poll(&c, base.Add(6*time.Second))
+	r.poll(&c, base.Add(7*time.Second))
+
+	if diff := cmp.Diff([]error{nil, errs[1], nil}, errs, cmp.Comparer(errorsEqual)); diff != "" {
+		t.Fatalf("unexpected reload results (-want +got):\n%s", diff)
+	}
+
+	want := []wgtypes.Config{
+		{PrivateKey: &priv, ReplacePeers: true, Peers: []wgtypes.PeerConfig{}},
+		{
+			PrivateKey:   &priv,
+			ReplacePeers: true,
+			Peers:        []wgtypes.PeerConfig{{PublicKey: pub, ReplaceAllowedIPs: true}},
+		},
+	}
+	if diff := cmp.Diff(want, c.cfgs); diff != "" {
+		t.Fatalf("unexpected configurations (-want +got):\n%s", diff)
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	priv := wgtest.MustPrivateKey()
+	port := 51820
+
+	want := wgtypes.Config{PrivateKey: &priv, ListenPort: &port}
+	in := fmt.Sprintf(`{"privateKey":%q,"listenPort":51820}`, priv)
+
+	// wgyaml.Config carries JSON tags as well, so JSON is also valid input
+	// for the YAML decoder.
+	for name, dec := range map[string]Decoder{
+		"JSON": JSON,
+		"YAML": YAML(json.Unmarshal),
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := dec([]byte(in))
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+
+			if diff := cmp.Diff(want, cfg); diff != "" {
+				t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if _, err := (&Reloader{Path: "wg0.yaml"}).decoder(); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+type testClient struct {
+	cfgs []wgtypes.Config
+}
+
+func (c *testClient) SyncConfig(_ string, cfg wgtypes.Config) error {
+	c.cfgs = append(c.cfgs, cfg)
+	return nil
+}
+
+// errorsEqual compares errors by their presence and message.
+func errorsEqual(x, y error) bool {
+	if x == nil || y == nil {
+		return x == y
+	}
+
+	return errors.Is(x, y) || x.Error() == y.Error()
+}
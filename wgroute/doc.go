@@ -0,0 +1,18 @@
+// Package wgroute routes traffic through WireGuard devices using Linux
+// policy routing, so that several tunnels can each carry a default route at
+// the same time.
+//
+// Each Tunnel installs the routes through its device in a routing table of
+// its own, and a rule selects that table for packets carrying the tunnel's
+// firewall mark. Applications or nftables rules which mark their packets
+// then choose a tunnel, which is the building block for split tunneling and
+// per-application routing.
+//
+// One Tunnel may instead be the default, as with wg-quick(8): all packets
+// which do not carry its mark use its table, while the device marks its own
+// encapsulated packets so that they continue to use the main table.
+//
+// Apply and Teardown change routes and rules using rtnetlink, and are only
+// supported on Linux. Commands and TeardownCommands describe the equivalent
+// ip(8) commands.
+package wgroute // import "golang.zx2c4.com/wireguard/wgctrl/wgroute"
@@ -0,0 +1,414 @@
+package wgroute
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Tunnel routes traffic through a WireGuard device using its own routing
+// table.
+type Tunnel struct {
+	// Device is the name of the WireGuard device.
+	Device string
+
+	// Table is the routing table which holds the tunnel's routes.
+	Table int
+
+	// Mark is the firewall mark which selects the tunnel. If Default is set,
+	// Mark must also be the firewall mark of the device.
+	Mark int
+
+	// Default routes all packets which do not carry Mark through the
+	// tunnel, rather than only those which do. Routes in the main table
+	// which are more specific than a default route still take precedence,
+	// so that local networks remain reachable.
+	Default bool
+
+	// Priority, if not 0, is the priority of the tunnel's rules. Otherwise,
+	// the kernel chooses one.
+	Priority int
+
+	// Routes are the destinations routed through the device, typically the
+	// allowed IPs of its peers.
+	Routes []net.IPNet
+}
+
+// FromDevice creates a Tunnel which routes the allowed IPs of each of the
+// peers of d using table, and is selected by mark.
+func FromDevice(d *wgtypes.Device, table, mark int) Tunnel {
+	t := Tunnel{
+		Device: d.Name,
+		Table:  table,
+		Mark:   mark,
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range d.Peers {
+		for _, ip := range p.AllowedIPs {
+			if s := ip.String(); !seen[s] {
+				seen[s] = true
+				t.Routes = append(t.Routes, ip)
+			}
+		}
+	}
+
+	return t
+}
+
+// Check verifies that tunnels can be installed together: each must use a
+// distinct device, table, and mark, and at most one may be the default.
+func Check(tunnels ...Tunnel) error {
+	var (
+		devices = make(map[string]bool)
+		tables  = make(map[int]bool)
+		marks   = make(map[int]bool)
+		def     bool
+	)
+
+	for _, t := range tunnels {
+		if err := t.check(); err != nil {
+			return err
+		}
+
+		switch {
+		case devices[t.Device]:
+			return fmt.Errorf("wgroute: device %q is used by more than one tunnel", t.Device)
+		case tables[t.Table]:
+			return fmt.Errorf("wgroute: table %d is used by more than one tunnel", t.Table)
+		case marks[t.Mark]:
+			return fmt.Errorf("wgroute: firewall mark %#x is used by more than one tunnel", t.Mark)
+		case def && t.Default:
+			return errors.New("wgroute: more than one tunnel is the default")
+		}
+
+		devices[t.Device] = true
+		tables[t.Table] = true
+		marks[t.Mark] = true
+		def = def || t.Default
+	}
+
+	return nil
+}
+
+// check verifies the fields of t.
+func (t Tunnel) check() error {
+	switch {
+	case t.Device == "" || strings.ContainsAny(t.Device, " \t\n/"):
+		return fmt.Errorf("wgroute: invalid device name %q", t.Device)
+	// The main, default, and local tables are reserved.
+	case t.Table <= 0 || (t.Table >= 253 && t.Table <= 255):
+		return fmt.Errorf("wgroute: invalid routing table %d for device %q", t.Table, t.Device)
+	case t.Mark <= 0 || int64(t.Mark) > 0xffffffff:
+		return fmt.Errorf("wgroute: invalid firewall mark %d for device %q", t.Mark, t.Device)
+	// The default tunnel's second rule uses the priority before Priority.
+	case t.Priority < 0 || (t.Default && t.Priority == 1):
+		return fmt.Errorf("wgroute: invalid rule priority %d for device %q", t.Priority, t.Device)
+	}
+
+	return nil
+}
+
+// Commands returns the arguments to each ip(8) command which is equivalent to
+// the changes Apply makes to install t.
+func (t Tunnel) Commands() ([][]string, error) {
+	reqs, err := t.requests()
+	if err != nil {
+		return nil, err
+	}
+
+	return commands(reqs), nil
+}
+
+// TeardownCommands returns the arguments to each ip(8) command which is
+// equivalent to the changes Teardown makes to remove t. Some commands fail if
+// t is not installed.
+func (t Tunnel) TeardownCommands() ([][]string, error) {
+	reqs, err := t.teardownRequests()
+	if err != nil {
+		return nil, err
+	}
+
+	return commands(reqs), nil
+}
+
+// requests returns the requests which install t.
+func (t Tunnel) requests() ([]request, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	var reqs []request
+	for _, family := range t.families() {
+		for _, r := range t.Routes {
+			if familyOf(r) == family {
+				reqs = append(reqs, request{
+					kind:   replaceRoute,
+					family: family,
+					table:  t.Table,
+					dst:    r,
+					device: t.Device,
+				})
+			}
+		}
+
+		reqs = append(reqs, t.rules(family, addRule)...)
+	}
+
+	return reqs, nil
+}
+
+// teardownRequests returns the requests which remove t.
+func (t Tunnel) teardownRequests() ([]request, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	var reqs []request
+	for _, family := range t.families() {
+		reqs = append(reqs, t.rules(family, deleteRule)...)
+		reqs = append(reqs, request{kind: flushRoutes, family: family, table: t.Table})
+	}
+
+	return reqs, nil
+}
+
+// families returns the address families of t's routes, 4 or 6.
+func (t Tunnel) families() []int {
+	var v4, v6 bool
+	for _, r := range t.Routes {
+		if familyOf(r) == 4 {
+			v4 = true
+		} else {
+			v6 = true
+		}
+	}
+
+	var out []int
+	if v4 {
+		out = append(out, 4)
+	}
+	if v6 {
+		out = append(out, 6)
+	}
+
+	return out
+}
+
+// familyOf returns the address family of n, 4 or 6.
+func familyOf(n net.IPNet) int {
+	if n.IP.To4() != nil {
+		return 4
+	}
+
+	return 6
+}
+
+// rules returns the requests of kind which add or delete t's rules for
+// family.
+func (t Tunnel) rules(family int, kind requestKind) []request {
+	if !t.Default {
+		return []request{{
+			kind:     kind,
+			family:   family,
+			table:    t.Table,
+			mark:     t.Mark,
+			priority: t.Priority,
+		}}
+	}
+
+	// The second rule has the priority before the first, if set, so that
+	// more specific routes in the main table take precedence.
+	var priority int
+	if t.Priority != 0 {
+		priority = t.Priority - 1
+	}
+
+	return []request{
+		{
+			kind:     kind,
+			family:   family,
+			table:    t.Table,
+			mark:     t.Mark,
+			invert:   true,
+			priority: t.Priority,
+		},
+		{
+			kind:     kind,
+			family:   family,
+			table:    tableMain,
+			suppress: true,
+			priority: priority,
+		},
+	}
+}
+
+// tableMain is the number of the main routing table.
+const tableMain = 254
+
+// A requestKind is the kind of change made by a request.
+type requestKind int
+
+// Possible requestKind values.
+const (
+	replaceRoute requestKind = iota
+	flushRoutes
+	addRule
+	deleteRule
+)
+
+// A request is a single change to the routes or rules of the system.
+type request struct {
+	kind   requestKind
+	family int
+	table  int
+
+	// dst and device are the destination and device of a route.
+	dst    net.IPNet
+	device string
+
+	// mark, invert, suppress, and priority select the packets which match a
+	// rule: those which carry mark, or if invert is set, those which do
+	// not. A rule which suppresses routes only uses the routes of its table
+	// which are more specific than a default route.
+	mark     int
+	invert   bool
+	suppress bool
+	priority int
+}
+
+// args returns the arguments to the ip(8) command equivalent to r.
+func (r request) args() []string {
+	table := strconv.Itoa(r.table)
+	if r.table == tableMain {
+		table = "main"
+	}
+
+	family := "-" + strconv.Itoa(r.family)
+	switch r.kind {
+	case replaceRoute:
+		return []string{family, "route", "replace", r.dst.String(), "dev", r.device, "table", table}
+	case flushRoutes:
+		return []string{family, "route", "flush", "table", table}
+	}
+
+	args := []string{family, "rule", "add"}
+	if r.kind == deleteRule {
+		args[2] = "del"
+	}
+
+	if r.mark != 0 {
+		if r.invert {
+			args = append(args, "not")
+		}
+
+		args = append(args, "fwmark", "0x"+strconv.FormatInt(int64(r.mark), 16))
+	}
+
+	args = append(args, "table", table)
+	if r.suppress {
+		args = append(args, "suppress_prefixlength", "0")
+	}
+	if r.priority != 0 {
+		args = append(args, "priority", strconv.Itoa(r.priority))
+	}
+
+	return args
+}
+
+// String returns the ip(8) command equivalent to r.
+func (r request) String() string {
+	return "ip " + strings.Join(r.args(), " ")
+}
+
+// commands returns the arguments to the ip(8) commands equivalent to reqs.
+func commands(reqs []request) [][]string {
+	cmds := make([][]string, 0, len(reqs))
+	for _, r := range reqs {
+		cmds = append(cmds, r.args())
+	}
+
+	return cmds
+}
+
+// Apply installs tunnels, replacing any rules previously installed for them.
+func Apply(ctx context.Context, tunnels ...Tunnel) error {
+	if err := Check(tunnels...); err != nil {
+		return err
+	}
+
+	c, err := dialRoute()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	// Remove existing rules first, because adding a rule which already
+	// exists creates a duplicate.
+	if err := teardown(ctx, c, tunnels); err != nil {
+		return err
+	}
+
+	for _, t := range tunnels {
+		reqs, err := t.requests()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range reqs {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if err := c.execute(r); err != nil {
+				return fmt.Errorf("wgroute: %s failed: %w", r, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Teardown removes tunnels. It is not an error if they are not installed.
+func Teardown(ctx context.Context, tunnels ...Tunnel) error {
+	for _, t := range tunnels {
+		if err := t.check(); err != nil {
+			return err
+		}
+	}
+
+	c, err := dialRoute()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	return teardown(ctx, c, tunnels)
+}
+
+// teardown removes tunnels using c.
+func teardown(ctx context.Context, c *routeConn, tunnels []Tunnel) error {
+	for _, t := range tunnels {
+		reqs, err := t.teardownRequests()
+		if err != nil {
+			return err
+		}
+
+		// Rules and routes which are not installed cannot be removed, so
+		// errors are ignored.
+		for _, r := range reqs {
+			_ = c.execute(r)
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
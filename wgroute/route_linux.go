@@ -0,0 +1,205 @@
+//go:build linux
+// +build linux
+
+package wgroute
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"golang.org/x/sys/unix"
+)
+
+// A routeConn changes routes and rules using rtnetlink.
+type routeConn struct {
+	c *netlink.Conn
+}
+
+// dialRoute opens an rtnetlink connection.
+func dialRoute() (*routeConn, error) {
+	c, err := netlink.Dial(unix.NETLINK_ROUTE, nil)
+	if err != nil {
+		return nil, fmt.Errorf("wgroute: failed to dial rtnetlink: %w", err)
+	}
+
+	return &routeConn{c: c}, nil
+}
+
+// Close closes the connection.
+func (c *routeConn) Close() error { return c.c.Close() }
+
+// execute makes the change described by r.
+func (c *routeConn) execute(r request) error {
+	switch r.kind {
+	case replaceRoute:
+		return c.replaceRoute(r)
+	case flushRoutes:
+		return c.flushRoutes(r)
+	case addRule:
+		return c.rule(unix.RTM_NEWRULE, netlink.Create|netlink.Excl, r)
+	case deleteRule:
+		return c.rule(unix.RTM_DELRULE, 0, r)
+	default:
+		panic(fmt.Sprintf("wgroute: unhandled request kind %d", r.kind))
+	}
+}
+
+// replaceRoute creates or replaces the route described by r, like
+// "ip route replace".
+func (c *routeConn) replaceRoute(r request) error {
+	ifi, err := net.InterfaceByName(r.device)
+	if err != nil {
+		return err
+	}
+
+	dst := r.dst.IP.To4()
+	if r.family == 6 {
+		dst = r.dst.IP.To16()
+	}
+	ones, _ := r.dst.Mask.Size()
+
+	// Routes through a device with no gateway are link scoped.
+	b := rtMsg(r.family, r.table)
+	b[1] = uint8(ones)
+	b[5] = unix.RTPROT_BOOT
+	b[6] = unix.RT_SCOPE_LINK
+	b[7] = unix.RTN_UNICAST
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Bytes(unix.RTA_DST, dst.Mask(r.dst.Mask))
+	ae.Uint32(unix.RTA_OIF, uint32(ifi.Index))
+	ae.Uint32(unix.RTA_TABLE, uint32(r.table))
+
+	attrs, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_NEWROUTE,
+			Flags: netlink.Request | netlink.Acknowledge | netlink.Create | netlink.Replace,
+		},
+		Data: append(b, attrs...),
+	})
+	return err
+}
+
+// flushRoutes removes each route of r.family in r.table, like
+// "ip route flush table".
+func (c *routeConn) flushRoutes(r request) error {
+	msgs, err := c.c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  unix.RTM_GETROUTE,
+			Flags: netlink.Request | netlink.Dump,
+		},
+		Data: rtMsg(r.family, 0),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, m := range msgs {
+		if m.Header.Type != unix.RTM_NEWROUTE {
+			continue
+		}
+
+		table, err := routeTable(m.Data)
+		if err != nil {
+			return err
+		}
+		if table != r.table {
+			continue
+		}
+
+		// The dumped route identifies itself for deletion.
+		if _, err := c.c.Execute(netlink.Message{
+			Header: netlink.Header{
+				Type:  unix.RTM_DELROUTE,
+				Flags: netlink.Request | netlink.Acknowledge,
+			},
+			Data: m.Data,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rule adds or deletes the rule described by r using the rtnetlink message
+// typ, like "ip rule add" and "ip rule del".
+func (c *routeConn) rule(typ netlink.HeaderType, flags netlink.HeaderFlags, r request) error {
+	// struct fib_rule_hdr has the same layout as struct rtmsg, with the
+	// rule's action in place of the route's type.
+	b := rtMsg(r.family, r.table)
+	b[7] = unix.FR_ACT_TO_TBL
+	if r.invert {
+		nlenc.PutUint32(b[8:12], unix.FIB_RULE_INVERT)
+	}
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(unix.FRA_TABLE, uint32(r.table))
+	if r.mark != 0 {
+		ae.Uint32(unix.FRA_FWMARK, uint32(r.mark))
+	}
+	if r.suppress {
+		ae.Uint32(unix.FRA_SUPPRESS_PREFIXLEN, 0)
+	}
+	if r.priority != 0 {
+		ae.Uint32(unix.FRA_PRIORITY, uint32(r.priority))
+	}
+
+	attrs, err := ae.Encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.c.Execute(netlink.Message{
+		Header: netlink.Header{
+			Type:  typ,
+			Flags: netlink.Request | netlink.Acknowledge | flags,
+		},
+		Data: append(b, attrs...),
+	})
+	return err
+}
+
+// rtMsg produces a struct rtmsg for family, 4 or 6, and table. Tables which
+// do not fit in the header must also be set using an attribute.
+func rtMsg(family, table int) []byte {
+	b := make([]byte, unix.SizeofRtMsg)
+	b[0] = unix.AF_INET
+	if family == 6 {
+		b[0] = unix.AF_INET6
+	}
+	if table < 256 {
+		b[4] = uint8(table)
+	}
+
+	return b
+}
+
+// routeTable returns the table of the route in the RTM_NEWROUTE message data
+// b.
+func routeTable(b []byte) (int, error) {
+	if len(b) < unix.SizeofRtMsg {
+		return 0, fmt.Errorf("wgroute: rtnetlink message is too short for rtmsg: %d", len(b))
+	}
+
+	ad, err := netlink.NewAttributeDecoder(b[unix.SizeofRtMsg:])
+	if err != nil {
+		return 0, err
+	}
+
+	table := int(b[4])
+	for ad.Next() {
+		if ad.Type() == unix.RTA_TABLE {
+			table = int(ad.Uint32())
+		}
+	}
+
+	return table, ad.Err()
+}
@@ -0,0 +1,110 @@
+//go:build linux
+// +build linux
+
+package wgroute
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+// ack acknowledges the request req.
+func ack(req netlink.Message) netlink.Message {
+	return netlink.Message{
+		Header: netlink.Header{Type: netlink.Error, Sequence: req.Header.Sequence},
+		Data:   make([]byte, 4),
+	}
+}
+
+func Test_routeConnRule(t *testing.T) {
+	tun := Tunnel{Device: "wg0", Table: 51820, Mark: 0xca6c, Default: true, Priority: 100}
+
+	// The rule which selects the tunnel's table, and the rule which
+	// suppresses default routes in the main table.
+	hdr := rtMsg(4, 51820)
+	hdr[7] = unix.FR_ACT_TO_TBL
+	nlenc.PutUint32(hdr[8:12], unix.FIB_RULE_INVERT)
+
+	suppress := rtMsg(4, tableMain)
+	suppress[7] = unix.FR_ACT_TO_TBL
+
+	want := [][]byte{
+		append(hdr, nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: unix.FRA_TABLE, Data: nlenc.Uint32Bytes(51820)},
+			{Type: unix.FRA_FWMARK, Data: nlenc.Uint32Bytes(0xca6c)},
+			{Type: unix.FRA_PRIORITY, Data: nlenc.Uint32Bytes(100)},
+		})...),
+		append(suppress, nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: unix.FRA_TABLE, Data: nlenc.Uint32Bytes(tableMain)},
+			{Type: unix.FRA_SUPPRESS_PREFIXLEN, Data: nlenc.Uint32Bytes(0)},
+			{Type: unix.FRA_PRIORITY, Data: nlenc.Uint32Bytes(99)},
+		})...),
+	}
+
+	var got [][]byte
+	c := &routeConn{c: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+		if diff := cmp.Diff(netlink.HeaderType(unix.RTM_NEWRULE), req[0].Header.Type); diff != "" {
+			t.Fatalf("unexpected message type (-want +got):\n%s", diff)
+		}
+
+		got = append(got, req[0].Data)
+		return []netlink.Message{ack(req[0])}, nil
+	})}
+	defer c.Close()
+
+	for _, r := range tun.rules(4, addRule) {
+		if err := c.execute(r); err != nil {
+			t.Fatalf("failed to add rule: %v", err)
+		}
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected requests (-want +got):\n%s", diff)
+	}
+}
+
+func Test_routeConnFlushRoutes(t *testing.T) {
+	// route produces a dumped route in table.
+	route := func(table int) []byte {
+		return append(rtMsg(4, 0), nltest.MustMarshalAttributes([]netlink.Attribute{
+			{Type: unix.RTA_TABLE, Data: nlenc.Uint32Bytes(uint32(table))},
+		})...)
+	}
+
+	var deleted [][]byte
+	c := &routeConn{c: nltest.Dial(func(req []netlink.Message) ([]netlink.Message, error) {
+		switch req[0].Header.Type {
+		case unix.RTM_GETROUTE:
+			var msgs []netlink.Message
+			for _, table := range []int{tableMain, 1001, 1002} {
+				msgs = append(msgs, netlink.Message{
+					Header: netlink.Header{Type: unix.RTM_NEWROUTE, Sequence: req[0].Header.Sequence},
+					Data:   route(table),
+				})
+			}
+
+			return msgs, nil
+		case unix.RTM_DELROUTE:
+			deleted = append(deleted, req[0].Data)
+			return []netlink.Message{ack(req[0])}, nil
+		default:
+			t.Fatalf("unexpected message type: %d", req[0].Header.Type)
+			return nil, nil
+		}
+	})}
+	defer c.Close()
+
+	if err := c.execute(request{kind: flushRoutes, family: 4, table: 1001}); err != nil {
+		t.Fatalf("failed to flush routes: %v", err)
+	}
+
+	// Only the route in the tunnel's table is deleted.
+	if diff := cmp.Diff([][]byte{route(1001)}, deleted); diff != "" {
+		t.Fatalf("unexpected deleted routes (-want +got):\n%s", diff)
+	}
+}
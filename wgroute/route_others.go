@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package wgroute
+
+import "errors"
+
+// errUnsupported is returned when policy routing is not available.
+var errUnsupported = errors.New("wgroute: policy routing is only supported on Linux")
+
+// A routeConn changes routes and rules. It is only implemented on Linux.
+type routeConn struct{}
+
+// dialRoute returns an unsupported error.
+func dialRoute() (*routeConn, error) { return nil, errUnsupported }
+
+// Close implements io.Closer.
+func (c *routeConn) Close() error { return errUnsupported }
+
+// execute returns an unsupported error.
+func (c *routeConn) execute(_ request) error { return errUnsupported }
@@ -0,0 +1,182 @@
+package wgroute_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgroute"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestTunnelCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		t        wgroute.Tunnel
+		cmds     [][]string
+		teardown [][]string
+	}{
+		{
+			name: "marked",
+			t: wgroute.Tunnel{
+				Device: "wg1",
+				Table:  1001,
+				Mark:   0x1001,
+				Routes: []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+			},
+			cmds: [][]string{
+				{"-4", "route", "replace", "0.0.0.0/0", "dev", "wg1", "table", "1001"},
+				{"-4", "rule", "add", "fwmark", "0x1001", "table", "1001"},
+			},
+			teardown: [][]string{
+				{"-4", "rule", "del", "fwmark", "0x1001", "table", "1001"},
+				{"-4", "route", "flush", "table", "1001"},
+			},
+		},
+		{
+			name: "default",
+			t: wgroute.Tunnel{
+				Device:   "wg0",
+				Table:    51820,
+				Mark:     51820,
+				Default:  true,
+				Priority: 100,
+				Routes: []net.IPNet{
+					wgtest.MustCIDR("0.0.0.0/0"),
+					wgtest.MustCIDR("::/0"),
+				},
+			},
+			cmds: [][]string{
+				{"-4", "route", "replace", "0.0.0.0/0", "dev", "wg0", "table", "51820"},
+				{"-4", "rule", "add", "not", "fwmark", "0xca6c", "table", "51820", "priority", "100"},
+				{"-4", "rule", "add", "table", "main", "suppress_prefixlength", "0", "priority", "99"},
+				{"-6", "route", "replace", "::/0", "dev", "wg0", "table", "51820"},
+				{"-6", "rule", "add", "not", "fwmark", "0xca6c", "table", "51820", "priority", "100"},
+				{"-6", "rule", "add", "table", "main", "suppress_prefixlength", "0", "priority", "99"},
+			},
+			teardown: [][]string{
+				{"-4", "rule", "del", "not", "fwmark", "0xca6c", "table", "51820", "priority", "100"},
+				{"-4", "rule", "del", "table", "main", "suppress_prefixlength", "0", "priority", "99"},
+				{"-4", "route", "flush", "table", "51820"},
+				{"-6", "rule", "del", "not", "fwmark", "0xca6c", "table", "51820", "priority", "100"},
+				{"-6", "rule", "del", "table", "main", "suppress_prefixlength", "0", "priority", "99"},
+				{"-6", "route", "flush", "table", "51820"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmds, err := tt.t.Commands()
+			if err != nil {
+				t.Fatalf("failed to generate commands: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.cmds, cmds); diff != "" {
+				t.Fatalf("unexpected commands (-want +got):\n%s", diff)
+			}
+
+			teardown, err := tt.t.TeardownCommands()
+			if err != nil {
+				t.Fatalf("failed to generate teardown commands: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.teardown, teardown); diff != "" {
+				t.Fatalf("unexpected teardown commands (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tunnel := func(dev string, table, mark int, def bool) wgroute.Tunnel {
+		return wgroute.Tunnel{Device: dev, Table: table, Mark: mark, Default: def}
+	}
+
+	tests := []struct {
+		name    string
+		tunnels []wgroute.Tunnel
+		ok      bool
+	}{
+		{
+			name: "OK",
+			tunnels: []wgroute.Tunnel{
+				tunnel("wg0", 1000, 1000, true),
+				tunnel("wg1", 1001, 1001, false),
+				tunnel("wg2", 1002, 1002, false),
+			},
+			ok: true,
+		},
+		{
+			name:    "main table",
+			tunnels: []wgroute.Tunnel{tunnel("wg0", 254, 1000, false)},
+		},
+		{
+			name:    "no mark",
+			tunnels: []wgroute.Tunnel{tunnel("wg0", 1000, 0, false)},
+		},
+		{
+			name: "duplicate table",
+			tunnels: []wgroute.Tunnel{
+				tunnel("wg0", 1000, 1000, false),
+				tunnel("wg1", 1000, 1001, false),
+			},
+		},
+		{
+			name: "duplicate mark",
+			tunnels: []wgroute.Tunnel{
+				tunnel("wg0", 1000, 1000, false),
+				tunnel("wg1", 1001, 1000, false),
+			},
+		},
+		{
+			name: "two defaults",
+			tunnels: []wgroute.Tunnel{
+				tunnel("wg0", 1000, 1000, true),
+				tunnel("wg1", 1001, 1001, true),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := wgroute.Check(tt.tunnels...)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to check: %v", err)
+			}
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFromDevice(t *testing.T) {
+	d := &wgtypes.Device{
+		Name: "wg0",
+		Peers: []wgtypes.Peer{
+			{AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.0/24"), wgtest.MustCIDR("fd00::/64")}},
+			{AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.0/24"), wgtest.MustCIDR("10.0.1.0/24")}},
+		},
+	}
+
+	want := wgroute.Tunnel{
+		Device: "wg0",
+		Table:  1000,
+		Mark:   1000,
+		Routes: []net.IPNet{
+			wgtest.MustCIDR("10.0.0.0/24"),
+			wgtest.MustCIDR("fd00::/64"),
+			wgtest.MustCIDR("10.0.1.0/24"),
+		},
+	}
+
+	if diff := cmp.Diff(want, wgroute.FromDevice(d, 1000, 1000)); diff != "" {
+		t.Fatalf("unexpected Tunnel (-want +got):\n%s", diff)
+	}
+}
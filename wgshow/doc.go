@@ -0,0 +1,9 @@
+// Package wgshow formats and parses the textual output of the wg(8) "show"
+// subcommand.
+//
+// Show and ShowAll produce the human-readable output of "wg show", while Dump
+// and DumpAll produce the tab-separated output of "wg show dump" and
+// "wg show all dump" which is intended for use by scripts. ParseDump performs
+// the reverse operation, so that output gathered from a remote host's wg(8)
+// binary can be consumed using wgtypes.
+package wgshow // import "golang.zx2c4.com/wireguard/wgctrl/wgshow"
@@ -0,0 +1,187 @@
+package wgshow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// Field counts for each line type in the dump format, not including the
+// optional leading interface name.
+const (
+	deviceFields = 4
+	peerFields   = 8
+)
+
+// ParseDump parses the tab-separated output of "wg show <interface> dump" or
+// "wg show all dump" from r into Devices, as produced by Dump and DumpAll.
+//
+// The form of the input is detected from its first line. When the input is
+// from a single interface, the returned Device's Name is empty and should be
+// filled in by the caller. The Type of each returned Device is always
+// wgtypes.Unknown since the dump format does not carry this information.
+func ParseDump(r io.Reader) ([]*wgtypes.Device, error) {
+	var (
+		devices  []*wgtypes.Device
+		withName bool
+	)
+
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		if s.Text() == "" {
+			continue
+		}
+
+		fields := strings.Split(s.Text(), "\t")
+		if line == 1 {
+			// The first line always describes a device, and the number of
+			// fields indicates whether interface names are present.
+			switch len(fields) {
+			case deviceFields:
+			case deviceFields + 1:
+				withName = true
+			default:
+				return nil, fmt.Errorf("wgshow: line %d: unexpected number of device fields: %d", line, len(fields))
+			}
+		}
+
+		var name string
+		if withName {
+			name, fields = fields[0], fields[1:]
+		}
+
+		// A new device is started on a device line, or when the interface name
+		// changes in "all dump" output.
+		var (
+			d   *wgtypes.Device
+			err error
+		)
+		switch len(fields) {
+		case deviceFields:
+			d, err = parseDumpDevice(fields)
+			if err != nil {
+				return nil, fmt.Errorf("wgshow: line %d: %v", line, err)
+			}
+
+			d.Name = name
+			devices = append(devices, d)
+		case peerFields:
+			if len(devices) == 0 || devices[len(devices)-1].Name != name {
+				return nil, fmt.Errorf("wgshow: line %d: peer does not follow its device", line)
+			}
+
+			p, err := parseDumpPeer(fields)
+			if err != nil {
+				return nil, fmt.Errorf("wgshow: line %d: %v", line, err)
+			}
+
+			d = devices[len(devices)-1]
+			d.Peers = append(d.Peers, *p)
+		default:
+			return nil, fmt.Errorf("wgshow: line %d: unexpected number of fields: %d", line, len(fields))
+		}
+	}
+
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+// parseDumpDevice parses a Device from a device line's fields.
+func parseDumpDevice(fields []string) (*wgtypes.Device, error) {
+	var (
+		d   wgtypes.Device
+		err error
+	)
+
+	if d.PrivateKey, err = parseMaybeKey(fields[0]); err != nil {
+		return nil, err
+	}
+	if d.PublicKey, err = parseMaybeKey(fields[1]); err != nil {
+		return nil, err
+	}
+	if d.ListenPort, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid listen port: %v", err)
+	}
+
+	if fields[3] != "off" {
+		fwmark, err := strconv.ParseUint(fields[3], 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fwmark: %v", err)
+		}
+
+		d.FirewallMark = int(fwmark)
+	}
+
+	return &d, nil
+}
+
+// parseDumpPeer parses a Peer from a peer line's fields.
+func parseDumpPeer(fields []string) (*wgtypes.Peer, error) {
+	var (
+		p   wgtypes.Peer
+		err error
+	)
+
+	if p.PublicKey, err = wgtypes.ParseKey(fields[0]); err != nil {
+		return nil, err
+	}
+	if p.PresharedKey, err = parseMaybeKey(fields[1]); err != nil {
+		return nil, err
+	}
+
+	if fields[2] != "(none)" {
+		if p.Endpoint, err = net.ResolveUDPAddr("udp", fields[2]); err != nil {
+			return nil, fmt.Errorf("invalid endpoint: %v", err)
+		}
+	}
+
+	if fields[3] != "(none)" {
+		if p.AllowedIPs, err = wgtypes.ParseAllowedIPs(fields[3]); err != nil {
+			return nil, err
+		}
+	}
+
+	sec, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latest handshake: %v", err)
+	}
+	if sec > 0 {
+		p.LastHandshakeTime = time.Unix(sec, 0)
+	}
+
+	if p.ReceiveBytes, err = strconv.ParseInt(fields[5], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid transfer rx: %v", err)
+	}
+	if p.TransmitBytes, err = strconv.ParseInt(fields[6], 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid transfer tx: %v", err)
+	}
+
+	if fields[7] != "off" {
+		secs, err := strconv.Atoi(fields[7])
+		if err != nil {
+			return nil, fmt.Errorf("invalid persistent keepalive: %v", err)
+		}
+
+		p.PersistentKeepaliveInterval = time.Duration(secs) * time.Second
+	}
+
+	return &p, nil
+}
+
+// parseMaybeKey parses a base64 Key, treating "(none)" as the zero-value Key.
+func parseMaybeKey(s string) (wgtypes.Key, error) {
+	if s == "(none)" {
+		return wgtypes.Key{}, nil
+	}
+
+	return wgtypes.ParseKey(s)
+}
@@ -0,0 +1,87 @@
+package wgshow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestParseDumpRoundTrip(t *testing.T) {
+	want := []*wgtypes.Device{testDevice, {Name: "wg1"}}
+
+	var buf bytes.Buffer
+	if err := DumpAll(&buf, want); err != nil {
+		t.Fatalf("failed to dump all: %v", err)
+	}
+
+	got, err := ParseDump(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse dump: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected devices (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDumpSingle(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Dump(&buf, testDevice); err != nil {
+		t.Fatalf("failed to dump: %v", err)
+	}
+
+	got, err := ParseDump(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse dump: %v", err)
+	}
+
+	// The device name is not present in single interface output.
+	want := *testDevice
+	want.Name = ""
+
+	if diff := cmp.Diff([]*wgtypes.Device{&want}, got); diff != "" {
+		t.Fatalf("unexpected devices (-want +got):\n%s", diff)
+	}
+}
+
+func TestParseDumpErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{
+			name: "bad field count",
+			s:    "a\tb\n",
+		},
+		{
+			name: "bad key",
+			s:    "xxx\t(none)\t0\toff\n",
+		},
+		{
+			name: "bad fwmark",
+			s:    "(none)\t(none)\t0\tfoo\n",
+		},
+		{
+			name: "orphan peer",
+			s:    "wg0\t(none)\t(none)\t0\toff\nwg1\tWEAuaVuhdyscyTCXVfBDJR6nf9zxD75jmJzrfhkyE3Y=\t(none)\t(none)\t(none)\t0\t0\t0\toff\n",
+		},
+		{
+			name: "bad allowed IP",
+			s:    "(none)\t(none)\t0\toff\nWEAuaVuhdyscyTCXVfBDJR6nf9zxD75jmJzrfhkyE3Y=\t(none)\t(none)\tfoo\t0\t0\t0\toff\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDump(strings.NewReader(tt.s))
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
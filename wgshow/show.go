@@ -0,0 +1,291 @@
+package wgshow
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// timeNow is the current time source, which can be swapped out in tests.
+var timeNow = time.Now
+
+// Show writes the human-readable "wg show <interface>" representation of d
+// to w.
+//
+// As with wg(8), private and preshared keys are displayed as "(hidden)" and
+// peers are sorted by most recent handshake.
+func Show(w io.Writer, d *wgtypes.Device) error {
+	return ShowAll(w, []*wgtypes.Device{d})
+}
+
+// ShowAll writes the human-readable "wg show all" representation of each of
+// devices to w.
+func ShowAll(w io.Writer, devices []*wgtypes.Device) error {
+	bw := bufio.NewWriter(w)
+	now := timeNow()
+
+	for i, d := range devices {
+		writeDevice(bw, d, now)
+
+		if i < len(devices)-1 {
+			bw.WriteString("\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Dump writes the tab-separated "wg show <interface> dump" representation of
+// d to w.
+func Dump(w io.Writer, d *wgtypes.Device) error {
+	bw := bufio.NewWriter(w)
+	writeDump(bw, d, false)
+	return bw.Flush()
+}
+
+// DumpAll writes the tab-separated "wg show all dump" representation of each
+// of devices to w. Each line is prefixed with the name of its device.
+func DumpAll(w io.Writer, devices []*wgtypes.Device) error {
+	bw := bufio.NewWriter(w)
+	for _, d := range devices {
+		writeDump(bw, d, true)
+	}
+
+	return bw.Flush()
+}
+
+// writeDevice writes the pretty-printed form of d to w, computing relative
+// times using now.
+func writeDevice(w io.Writer, d *wgtypes.Device, now time.Time) {
+	fmt.Fprintf(w, "interface: %s\n", d.Name)
+
+	// A device only has a public key when its private key is set.
+	if !isZero(d.PrivateKey) {
+		fmt.Fprintf(w, "  public key: %s\n", d.PublicKey.String())
+		fmt.Fprintln(w, "  private key: (hidden)")
+	}
+	if d.ListenPort != 0 {
+		fmt.Fprintf(w, "  listening port: %d\n", d.ListenPort)
+	}
+	if d.FirewallMark != 0 {
+		fmt.Fprintf(w, "  fwmark: 0x%x\n", d.FirewallMark)
+	}
+
+	if len(d.Peers) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+
+	for i, p := range sortPeers(d.Peers) {
+		fmt.Fprintf(w, "peer: %s\n", p.PublicKey.String())
+
+		if !isZero(p.PresharedKey) {
+			fmt.Fprintln(w, "  preshared key: (hidden)")
+		}
+		if p.Endpoint != nil {
+			fmt.Fprintf(w, "  endpoint: %s\n", p.Endpoint.String())
+		}
+
+		fmt.Fprintf(w, "  allowed ips: %s\n", allowedIPs(p.AllowedIPs, ", "))
+
+		if !p.LastHandshakeTime.IsZero() {
+			fmt.Fprintf(w, "  latest handshake: %s\n", ago(now, p.LastHandshakeTime))
+		}
+		if p.ReceiveBytes != 0 || p.TransmitBytes != 0 {
+			fmt.Fprintf(w, "  transfer: %s received, %s sent\n",
+				prettyBytes(p.ReceiveBytes), prettyBytes(p.TransmitBytes))
+		}
+		if p.PersistentKeepaliveInterval > 0 {
+			fmt.Fprintf(w, "  persistent keepalive: every %s\n",
+				prettyTime(int64(p.PersistentKeepaliveInterval/time.Second)))
+		}
+
+		if i < len(d.Peers)-1 {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// writeDump writes the dump form of d to w, optionally prefixing each line
+// with the device name.
+func writeDump(w io.Writer, d *wgtypes.Device, withName bool) {
+	prefix := func() {
+		if withName {
+			fmt.Fprintf(w, "%s\t", d.Name)
+		}
+	}
+
+	prefix()
+	fmt.Fprintf(w, "%s\t%s\t%d\t%s\n",
+		maybeKey(d.PrivateKey),
+		maybeKey(d.PublicKey),
+		d.ListenPort,
+		offOr(d.FirewallMark, "0x%x"),
+	)
+
+	for _, p := range d.Peers {
+		endpoint := "(none)"
+		if p.Endpoint != nil {
+			endpoint = p.Endpoint.String()
+		}
+
+		// wg(8) reports a zero timestamp as 0 rather than the Unix time of
+		// the zero-value time.Time.
+		var handshake int64
+		if !p.LastHandshakeTime.IsZero() {
+			handshake = p.LastHandshakeTime.Unix()
+		}
+
+		prefix()
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+			p.PublicKey.String(),
+			maybeKey(p.PresharedKey),
+			endpoint,
+			allowedIPs(p.AllowedIPs, ","),
+			handshake,
+			p.ReceiveBytes,
+			p.TransmitBytes,
+			offOr(int(p.PersistentKeepaliveInterval/time.Second), "%d"),
+		)
+	}
+}
+
+// sortPeers returns a copy of ps sorted in the same order as wg(8): peers
+// with the most recent handshake first, followed by peers which have never
+// completed a handshake.
+func sortPeers(ps []wgtypes.Peer) []wgtypes.Peer {
+	out := make([]wgtypes.Peer, len(ps))
+	copy(out, ps)
+
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].LastHandshakeTime, out[j].LastHandshakeTime
+		switch {
+		case a.IsZero():
+			return false
+		case b.IsZero():
+			return true
+		default:
+			return a.After(b)
+		}
+	})
+
+	return out
+}
+
+// ago produces a relative time string such as "1 minute, 2 seconds ago".
+func ago(now, t time.Time) string {
+	secs := now.Unix() - t.Unix()
+	switch {
+	case secs == 0:
+		return "Now"
+	case secs < 0:
+		return "(System clock wound backward; connection problems may ensue.)"
+	default:
+		return prettyTime(secs) + " ago"
+	}
+}
+
+// prettyTime produces a duration string in the style of wg(8) from a number
+// of seconds.
+func prettyTime(secs int64) string {
+	const (
+		minute = 60
+		hour   = 60 * minute
+		day    = 24 * hour
+		year   = 365 * day
+	)
+
+	units := []struct {
+		name string
+		n    int64
+	}{
+		{name: "year", n: secs / year},
+		{name: "day", n: secs % year / day},
+		{name: "hour", n: secs % day / hour},
+		{name: "minute", n: secs % hour / minute},
+		{name: "second", n: secs % minute},
+	}
+
+	var ss []string
+	for _, u := range units {
+		if u.n == 0 {
+			continue
+		}
+
+		s := fmt.Sprintf("%d %s", u.n, u.name)
+		if u.n != 1 {
+			s += "s"
+		}
+
+		ss = append(ss, s)
+	}
+
+	return strings.Join(ss, ", ")
+}
+
+// prettyBytes produces a human-readable byte count string using binary
+// prefixes.
+func prettyBytes(b int64) string {
+	const (
+		kiB = 1024
+		miB = 1024 * kiB
+		giB = 1024 * miB
+		tiB = 1024 * giB
+	)
+
+	switch {
+	case b < kiB:
+		return fmt.Sprintf("%d B", b)
+	case b < miB:
+		return fmt.Sprintf("%.2f KiB", float64(b)/kiB)
+	case b < giB:
+		return fmt.Sprintf("%.2f MiB", float64(b)/miB)
+	case b < tiB:
+		return fmt.Sprintf("%.2f GiB", float64(b)/giB)
+	default:
+		return fmt.Sprintf("%.2f TiB", float64(b)/tiB)
+	}
+}
+
+// allowedIPs joins ipns using sep, or returns "(none)" if ipns is empty.
+func allowedIPs(ipns []net.IPNet, sep string) string {
+	if len(ipns) == 0 {
+		return "(none)"
+	}
+
+	ss := make([]string, 0, len(ipns))
+	for _, ipn := range ipns {
+		ss = append(ss, ipn.String())
+	}
+
+	return strings.Join(ss, sep)
+}
+
+// maybeKey returns the string form of k, or "(none)" if k is unset.
+func maybeKey(k wgtypes.Key) string {
+	if isZero(k) {
+		return "(none)"
+	}
+
+	return k.String()
+}
+
+// offOr formats v using format, or returns "off" if v is 0.
+func offOr(v int, format string) string {
+	if v == 0 {
+		return "off"
+	}
+
+	return fmt.Sprintf(format, v)
+}
+
+// isZero determines if k is the zero-value Key.
+func isZero(k wgtypes.Key) bool {
+	return k == wgtypes.Key{}
+}
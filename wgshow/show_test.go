@@ -0,0 +1,128 @@
+package wgshow
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	testNow = time.Unix(1600000000, 0)
+
+	testPrivate = wgtest.MustHexKey("e84b5a6d2717c1003a13b431570353dbaca9146cf150c5f8575680feba52027a")
+	testPeerA   = wgtest.MustHexKey("b85996fecc9c7f1fc6d2572a76eda11d59bcd20be8e543b15ce4bd85a8e75a33")
+	testPeerB   = wgtest.MustHexKey("58402e695ba1772b1cc9309755f043251ea77fdcf10fbe63989ceb7e19321376")
+	testPSK     = wgtest.MustHexKey("188515093e952f5f22e865cef3012e72f8b5f0b598ac0309d5dacce3b70fcf52")
+
+	testDevice = &wgtypes.Device{
+		Name:         "wg0",
+		PrivateKey:   testPrivate,
+		PublicKey:    testPrivate.PublicKey(),
+		ListenPort:   51820,
+		FirewallMark: 0xff,
+		Peers: []wgtypes.Peer{
+			{
+				PublicKey:  testPeerB,
+				AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.3/32")},
+			},
+			{
+				PublicKey:                   testPeerA,
+				PresharedKey:                testPSK,
+				Endpoint:                    wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+				PersistentKeepaliveInterval: 25 * time.Second,
+				LastHandshakeTime:           testNow.Add(-(time.Hour + 1*time.Minute + 5*time.Second)),
+				ReceiveBytes:                1536,
+				TransmitBytes:               3 * 1024 * 1024,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.2/32"),
+					wgtest.MustCIDR("fd00::2/128"),
+				},
+			},
+		},
+	}
+)
+
+func TestShow(t *testing.T) {
+	timeNow = func() time.Time { return testNow }
+	defer func() { timeNow = time.Now }()
+
+	const want = `interface: wg0
+  public key: wVMuGz01CPx+vDVPpnliDzPyhxSVQuaExnt7DYE2Kyk=
+  private key: (hidden)
+  listening port: 51820
+  fwmark: 0xff
+
+peer: uFmW/sycfx/G0lcqdu2hHVm80gvo5UOxXOS9hajnWjM=
+  preshared key: (hidden)
+  endpoint: [2001:db8::1]:51820
+  allowed ips: 10.0.0.2/32, fd00::2/128
+  latest handshake: 1 hour, 1 minute, 5 seconds ago
+  transfer: 1.50 KiB received, 3.00 MiB sent
+  persistent keepalive: every 25 seconds
+
+peer: WEAuaVuhdyscyTCXVfBDJR6nf9zxD75jmJzrfhkyE3Y=
+  allowed ips: 10.0.0.3/32
+
+interface: wg1
+`
+
+	var buf bytes.Buffer
+	if err := ShowAll(&buf, []*wgtypes.Device{testDevice, {Name: "wg1"}}); err != nil {
+		t.Fatalf("failed to show: %v", err)
+	}
+
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func TestDump(t *testing.T) {
+	const (
+		want = "6EtabScXwQA6E7QxVwNT26ypFGzxUMX4V1aA/rpSAno=\twVMuGz01CPx+vDVPpnliDzPyhxSVQuaExnt7DYE2Kyk=\t51820\t0xff\n" +
+			"WEAuaVuhdyscyTCXVfBDJR6nf9zxD75jmJzrfhkyE3Y=\t(none)\t(none)\t10.0.0.3/32\t0\t0\t0\toff\n" +
+			"uFmW/sycfx/G0lcqdu2hHVm80gvo5UOxXOS9hajnWjM=\tGIUVCT6VL18i6GXO8wEucvi18LWYrAMJ1drM47cPz1I=\t[2001:db8::1]:51820\t10.0.0.2/32,fd00::2/128\t1599996335\t1536\t3145728\t25\n"
+
+		wantAll = "wg1\t(none)\t(none)\t0\toff\n"
+	)
+
+	var buf bytes.Buffer
+	if err := Dump(&buf, testDevice); err != nil {
+		t.Fatalf("failed to dump: %v", err)
+	}
+
+	if diff := cmp.Diff(want, buf.String()); diff != "" {
+		t.Fatalf("unexpected dump (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := DumpAll(&buf, []*wgtypes.Device{{Name: "wg1"}}); err != nil {
+		t.Fatalf("failed to dump all: %v", err)
+	}
+
+	if diff := cmp.Diff(wantAll, buf.String()); diff != "" {
+		t.Fatalf("unexpected dump all (-want +got):\n%s", diff)
+	}
+}
+
+func Test_prettyTime(t *testing.T) {
+	tests := []struct {
+		secs int64
+		s    string
+	}{
+		{secs: 1, s: "1 second"},
+		{secs: 60, s: "1 minute"},
+		{secs: 3 * 60 * 60 * 24, s: "3 days"},
+		{secs: 365*24*60*60 + 2, s: "1 year, 2 seconds"},
+	}
+
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.s, prettyTime(tt.secs)); diff != "" {
+			t.Fatalf("unexpected time for %d (-want +got):\n%s", tt.secs, diff)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package wgsnmp
+
+import (
+	"crypto/subtle"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+)
+
+// SNMP protocol constants.
+const (
+	versionV2c = 1
+
+	// PDU types, which are context-specific constructed tags.
+	pduGetRequest     = 0
+	pduGetNextRequest = 1
+	pduResponse       = 2
+	pduSetRequest     = 3
+	pduGetBulkRequest = 5
+
+	// Application-specific value types.
+	tagGauge32   = 2
+	tagCounter64 = 6
+
+	// Context-specific exception values.
+	tagNoSuchObject   = 0
+	tagNoSuchInstance = 1
+	tagEndOfMIBView   = 2
+
+	// Error statuses.
+	errorTooBig      = 1
+	errorGenErr      = 5
+	errorNotWritable = 17
+
+	// maxMessageSize is the size of the largest response the agent sends,
+	// chosen to fit in a single Ethernet frame.
+	maxMessageSize = 1472
+
+	// maxRepetitions limits the number of repetitions of a GetBulkRequest.
+	maxRepetitions = 256
+)
+
+// An Agent is a read-only SNMPv2c agent which serves the statistics of
+// WireGuard devices.
+type Agent struct {
+	s         *wgstats.Sampler
+	community []byte
+}
+
+// NewAgent creates an Agent which serves Snapshots from s to requests
+// carrying the community string community. Requests with any other community
+// string are ignored.
+func NewAgent(s *wgstats.Sampler, community string) *Agent {
+	return &Agent{
+		s:         s,
+		community: []byte(community),
+	}
+}
+
+// Serve serves SNMP requests received on c until reading from c fails.
+func (a *Agent) Serve(c net.PacketConn) error {
+	b := make([]byte, 65535)
+	for {
+		n, addr, err := c.ReadFrom(b)
+		if err != nil {
+			return err
+		}
+
+		res, ok := a.handle(b[:n])
+		if !ok {
+			continue
+		}
+
+		// A failed write to a single client should not stop the agent.
+		_, _ = c.WriteTo(res, addr)
+	}
+}
+
+// A message is an SNMP message.
+type message struct {
+	Version   int
+	Community []byte
+	PDU       asn1.RawValue
+}
+
+// A pdu is an SNMP PDU. For a GetBulkRequest, ErrorStatus and ErrorIndex
+// hold the non-repeaters and max-repetitions fields.
+type pdu struct {
+	RequestID   int32
+	ErrorStatus int
+	ErrorIndex  int
+	VarBinds    []varBind
+}
+
+// A varBind is an object identifier and its value.
+type varBind struct {
+	Name  asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+// handle handles the request b, and returns a response and true if the
+// request should be answered.
+func (a *Agent) handle(b []byte) ([]byte, bool) {
+	var m message
+	if rest, err := asn1.Unmarshal(b, &m); err != nil || len(rest) != 0 {
+		return nil, false
+	}
+
+	if m.Version != versionV2c || subtle.ConstantTimeCompare(m.Community, a.community) != 1 {
+		return nil, false
+	}
+
+	if m.PDU.Class != asn1.ClassContextSpecific || !m.PDU.IsCompound {
+		return nil, false
+	}
+
+	req, err := parsePDU(m.PDU)
+	if err != nil {
+		return nil, false
+	}
+
+	res := pdu{RequestID: req.RequestID}
+	switch m.PDU.Tag {
+	case pduGetRequest, pduGetNextRequest, pduGetBulkRequest:
+		snap, err := a.s.Sample()
+		if err != nil {
+			res.ErrorStatus = errorGenErr
+			res.ErrorIndex = 1
+			res.VarBinds = req.VarBinds
+			break
+		}
+
+		objs := objects(snap)
+		switch m.PDU.Tag {
+		case pduGetRequest:
+			res.VarBinds = get(objs, req.VarBinds)
+		case pduGetNextRequest:
+			res.VarBinds = getNext(objs, req.VarBinds)
+		case pduGetBulkRequest:
+			res.VarBinds = getBulk(objs, req.VarBinds, req.ErrorStatus, req.ErrorIndex)
+		}
+	case pduSetRequest:
+		res.ErrorStatus = errorNotWritable
+		res.ErrorIndex = 1
+		res.VarBinds = req.VarBinds
+	default:
+		return nil, false
+	}
+
+	out, err := marshalResponse(m, res)
+	if err != nil {
+		return nil, false
+	}
+
+	// GetBulkRequest responses may be truncated to fit; other requests must
+	// fail entirely.
+	for len(out) > maxMessageSize {
+		if m.PDU.Tag != pduGetBulkRequest || len(res.VarBinds) <= 1 {
+			res = pdu{
+				RequestID:   req.RequestID,
+				ErrorStatus: errorTooBig,
+			}
+		} else {
+			res.VarBinds = res.VarBinds[:len(res.VarBinds)/2]
+		}
+
+		if out, err = marshalResponse(m, res); err != nil {
+			return nil, false
+		}
+	}
+
+	return out, true
+}
+
+// parsePDU parses the fields of the PDU v.
+func parsePDU(v asn1.RawValue) (pdu, error) {
+	// The fields of each PDU type are a SEQUENCE with an implicit tag, so
+	// decode its contents as an ordinary SEQUENCE.
+	b, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassUniversal,
+		Tag:        asn1.TagSequence,
+		IsCompound: true,
+		Bytes:      v.Bytes,
+	})
+	if err != nil {
+		return pdu{}, err
+	}
+
+	var p pdu
+	rest, err := asn1.Unmarshal(b, &p)
+	if err != nil {
+		return pdu{}, err
+	}
+	if len(rest) != 0 {
+		return pdu{}, errors.New("wgsnmp: trailing data after PDU")
+	}
+
+	return p, nil
+}
+
+// marshalResponse encodes res as a Response PDU in reply to req.
+func marshalResponse(req message, res pdu) ([]byte, error) {
+	if res.VarBinds == nil {
+		res.VarBinds = []varBind{}
+	}
+
+	b, err := asn1.Marshal(res)
+	if err != nil {
+		return nil, fmt.Errorf("wgsnmp: failed to marshal PDU: %v", err)
+	}
+
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(b, &seq); err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(message{
+		Version:   req.Version,
+		Community: req.Community,
+		PDU: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        pduResponse,
+			IsCompound: true,
+			Bytes:      seq.Bytes,
+		},
+	})
+}
+
+// get answers a GetRequest for vbs.
+func get(objs []object, vbs []varBind) []varBind {
+	out := make([]varBind, 0, len(vbs))
+	for _, vb := range vbs {
+		i := search(objs, vb.Name)
+		if i < len(objs) && compareOIDs(objs[i].oid, vb.Name) == 0 {
+			out = append(out, varBind{Name: vb.Name, Value: objs[i].value})
+			continue
+		}
+
+		// Distinguish between objects which are not defined and instances
+		// which do not exist.
+		tag := tagNoSuchObject
+		if isColumn(vb.Name) {
+			tag = tagNoSuchInstance
+		}
+
+		out = append(out, varBind{Name: vb.Name, Value: exception(tag)})
+	}
+
+	return out
+}
+
+// getNext answers a GetNextRequest for vbs.
+func getNext(objs []object, vbs []varBind) []varBind {
+	out := make([]varBind, 0, len(vbs))
+	for _, vb := range vbs {
+		out = append(out, next(objs, vb.Name))
+	}
+
+	return out
+}
+
+// getBulk answers a GetBulkRequest for vbs.
+func getBulk(objs []object, vbs []varBind, nonRepeaters, repetitions int) []varBind {
+	if nonRepeaters < 0 {
+		nonRepeaters = 0
+	}
+	if nonRepeaters > len(vbs) {
+		nonRepeaters = len(vbs)
+	}
+	if repetitions < 0 {
+		repetitions = 0
+	}
+	if repetitions > maxRepetitions {
+		repetitions = maxRepetitions
+	}
+
+	out := getNext(objs, vbs[:nonRepeaters])
+
+	names := make([]asn1.ObjectIdentifier, 0, len(vbs)-nonRepeaters)
+	for _, vb := range vbs[nonRepeaters:] {
+		names = append(names, vb.Name)
+	}
+
+	for r := 0; r < repetitions && len(names) > 0; r++ {
+		done := true
+		for i, name := range names {
+			vb := next(objs, name)
+			out = append(out, vb)
+			names[i] = vb.Name
+
+			if vb.Value.Class != asn1.ClassContextSpecific {
+				done = false
+			}
+		}
+
+		// Stop early once every repeater has reached the end of the MIB.
+		if done {
+			break
+		}
+	}
+
+	return out
+}
+
+// next returns the first object following name, or an endOfMibView exception.
+func next(objs []object, name asn1.ObjectIdentifier) varBind {
+	i := search(objs, name)
+	if i < len(objs) && compareOIDs(objs[i].oid, name) == 0 {
+		i++
+	}
+
+	if i >= len(objs) {
+		return varBind{Name: name, Value: exception(tagEndOfMIBView)}
+	}
+
+	return varBind{Name: objs[i].oid, Value: objs[i].value}
+}
+
+// search returns the index of the first object whose identifier is not less
+// than name.
+func search(objs []object, name asn1.ObjectIdentifier) int {
+	return sort.Search(len(objs), func(i int) bool {
+		return compareOIDs(objs[i].oid, name) >= 0
+	})
+}
+
+// isColumn reports whether oid names an instance of a column of one of the
+// tables of the MIB.
+func isColumn(oid asn1.ObjectIdentifier) bool {
+	n := len(Root)
+	if len(oid) <= n+3 || compareOIDs(oid[:n], Root) != 0 || oid[n+1] != 1 {
+		return false
+	}
+
+	switch table, col := oid[n], oid[n+2]; table {
+	case 1:
+		return col >= colDeviceIndex && col <= colDevicePeers
+	case 2:
+		return col >= colPeerPublicKey && col <= colPeerAllowedIPs
+	default:
+		return false
+	}
+}
+
+// integer encodes v as an INTEGER.
+func integer(v int64) asn1.RawValue {
+	b, _ := asn1.Marshal(v)
+	var rv asn1.RawValue
+	_, _ = asn1.Unmarshal(b, &rv)
+	return rv
+}
+
+// octetString encodes s as an OCTET STRING.
+func octetString(s string) asn1.RawValue {
+	return asn1.RawValue{
+		Class: asn1.ClassUniversal,
+		Tag:   asn1.TagOctetString,
+		Bytes: []byte(s),
+	}
+}
+
+// unsigned encodes v as the unsigned application type tag, such as Gauge32
+// or Counter64.
+func unsigned(tag int, v uint64) asn1.RawValue {
+	// Encode big-endian with a leading zero byte if the high bit is set, so
+	// that the value is not interpreted as negative.
+	var b []byte
+	for {
+		b = append([]byte{byte(v)}, b...)
+		v >>= 8
+		if v == 0 {
+			break
+		}
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+
+	return asn1.RawValue{
+		Class: asn1.ClassApplication,
+		Tag:   tag,
+		Bytes: b,
+	}
+}
+
+// exception encodes an SNMPv2 exception value.
+func exception(tag int) asn1.RawValue {
+	return asn1.RawValue{
+		Class: asn1.ClassContextSpecific,
+		Tag:   tag,
+		Bytes: []byte{},
+	}
+}
@@ -0,0 +1,341 @@
+package wgsnmp
+
+import (
+	"encoding/asn1"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+type testSource []*wgtypes.Device
+
+func (ts testSource) Devices() ([]*wgtypes.Device, error) { return ts, nil }
+
+var (
+	pubA = wgtest.MustPublicKey()
+	pubB = wgtest.MustPublicKey()
+)
+
+func testAgent() *Agent {
+	devices := testSource{
+		{
+			Name:       "wg1",
+			PublicKey:  pubA,
+			ListenPort: 51821,
+		},
+		{
+			Name:       "wg0",
+			PublicKey:  pubB,
+			ListenPort: 51820,
+			Peers: []wgtypes.Peer{{
+				PublicKey:         pubA,
+				Endpoint:          wgtest.MustUDPAddr("192.0.2.1:51820"),
+				LastHandshakeTime: time.Unix(1600000000, 0),
+				ReceiveBytes:      math.MaxInt64,
+				TransmitBytes:     2,
+			}},
+		},
+	}
+
+	return NewAgent(wgstats.NewSampler(devices, 0), "public")
+}
+
+// oid returns an object identifier below Root.
+func oid(arcs ...int) asn1.ObjectIdentifier {
+	return append(append(asn1.ObjectIdentifier{}, Root...), arcs...)
+}
+
+// request encodes an SNMPv2c request of type tag for names.
+func request(t *testing.T, community string, tag, a, b int, names ...asn1.ObjectIdentifier) []byte {
+	t.Helper()
+
+	vbs := make([]varBind, 0, len(names))
+	for _, n := range names {
+		vbs = append(vbs, varBind{Name: n, Value: asn1.NullRawValue})
+	}
+
+	p, err := asn1.Marshal(pdu{RequestID: 42, ErrorStatus: a, ErrorIndex: b, VarBinds: vbs})
+	if err != nil {
+		t.Fatalf("failed to marshal PDU: %v", err)
+	}
+
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(p, &seq); err != nil {
+		t.Fatalf("failed to unmarshal PDU: %v", err)
+	}
+
+	m, err := asn1.Marshal(message{
+		Version:   versionV2c,
+		Community: []byte(community),
+		PDU: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        tag,
+			IsCompound: true,
+			Bytes:      seq.Bytes,
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal message: %v", err)
+	}
+
+	return m
+}
+
+// response decodes the SNMPv2c response b.
+func response(t *testing.T, b []byte) pdu {
+	t.Helper()
+
+	var m message
+	if _, err := asn1.Unmarshal(b, &m); err != nil {
+		t.Fatalf("failed to unmarshal message: %v", err)
+	}
+	if m.PDU.Tag != pduResponse {
+		t.Fatalf("unexpected PDU type: %d", m.PDU.Tag)
+	}
+
+	p, err := parsePDU(m.PDU)
+	if err != nil {
+		t.Fatalf("failed to parse PDU: %v", err)
+	}
+	if p.RequestID != 42 {
+		t.Fatalf("unexpected request ID: %d", p.RequestID)
+	}
+
+	return p
+}
+
+// do performs a request against a and decodes its response.
+func do(t *testing.T, a *Agent, tag, x, y int, names ...asn1.ObjectIdentifier) pdu {
+	t.Helper()
+
+	res, ok := a.handle(request(t, "public", tag, x, y, names...))
+	if !ok {
+		t.Fatal("agent did not respond")
+	}
+
+	return response(t, res)
+}
+
+// value describes the value of vb for comparison.
+type value struct {
+	OID   string
+	Class int
+	Tag   int
+	Bytes []byte
+}
+
+func values(vbs []varBind) []value {
+	var vs []value
+	for _, vb := range vbs {
+		vs = append(vs, value{
+			OID:   vb.Name.String(),
+			Class: vb.Value.Class,
+			Tag:   vb.Value.Tag,
+			Bytes: vb.Value.Bytes,
+		})
+	}
+
+	return vs
+}
+
+func TestAgentGet(t *testing.T) {
+	res := do(t, testAgent(), pduGetRequest, 0, 0,
+		// Devices are ordered by name, so wg0 has index 1.
+		oid(1, 1, colDeviceName, 1),
+		oid(2, 1, colPeerReceiveBytes, 1, 1),
+		oid(2, 1, colPeerLastHandshake, 1, 1),
+		oid(1, 1, colDeviceName, 3),
+		oid(3),
+	)
+
+	want := []value{
+		{
+			OID:   oid(1, 1, colDeviceName, 1).String(),
+			Tag:   asn1.TagOctetString,
+			Bytes: []byte("wg0"),
+		},
+		{
+			OID:   oid(2, 1, colPeerReceiveBytes, 1, 1).String(),
+			Class: asn1.ClassApplication,
+			Tag:   tagCounter64,
+			Bytes: []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			OID:   oid(2, 1, colPeerLastHandshake, 1, 1).String(),
+			Class: asn1.ClassApplication,
+			Tag:   tagGauge32,
+			// 1600000000 has its high bit clear.
+			Bytes: []byte{0x5f, 0x5e, 0x10, 0x00},
+		},
+		{
+			OID:   oid(1, 1, colDeviceName, 3).String(),
+			Class: asn1.ClassContextSpecific,
+			Tag:   tagNoSuchInstance,
+			Bytes: []byte{},
+		},
+		{
+			OID:   oid(3).String(),
+			Class: asn1.ClassContextSpecific,
+			Tag:   tagNoSuchObject,
+			Bytes: []byte{},
+		},
+	}
+
+	if diff := cmp.Diff(want, values(res.VarBinds)); diff != "" {
+		t.Fatalf("unexpected values (-want +got):\n%s", diff)
+	}
+}
+
+func TestAgentWalk(t *testing.T) {
+	a := testAgent()
+
+	var names []string
+	name := Root
+	for {
+		res := do(t, a, pduGetNextRequest, 0, 0, name)
+		vb := res.VarBinds[0]
+		if vb.Value.Class == asn1.ClassContextSpecific {
+			if vb.Value.Tag != tagEndOfMIBView {
+				t.Fatalf("unexpected exception: %d", vb.Value.Tag)
+			}
+
+			break
+		}
+
+		names = append(names, vb.Name.String())
+		name = vb.Name
+	}
+
+	// 5 columns for 2 devices, and 6 columns for 1 peer.
+	if diff := cmp.Diff(16, len(names)); diff != "" {
+		t.Fatalf("unexpected number of objects (-want +got):\n%s", diff)
+	}
+
+	first := []string{
+		oid(1, 1, colDeviceIndex, 1).String(),
+		oid(1, 1, colDeviceIndex, 2).String(),
+		oid(1, 1, colDeviceName, 1).String(),
+	}
+
+	if diff := cmp.Diff(first, names[:3]); diff != "" {
+		t.Fatalf("unexpected walk order (-want +got):\n%s", diff)
+	}
+
+	// The same walk is produced by a single GetBulkRequest.
+	res := do(t, a, pduGetBulkRequest, 0, 100, Root)
+	var bulk []string
+	for _, vb := range res.VarBinds {
+		if vb.Value.Class != asn1.ClassContextSpecific {
+			bulk = append(bulk, vb.Name.String())
+		}
+	}
+
+	if diff := cmp.Diff(names, bulk); diff != "" {
+		t.Fatalf("unexpected bulk walk (-want +got):\n%s", diff)
+	}
+}
+
+func TestAgentGetBulkNonRepeaters(t *testing.T) {
+	res := do(t, testAgent(), pduGetBulkRequest, 1, 2,
+		oid(1, 1, colDeviceName),
+		oid(1, 1, colDeviceListenPort),
+	)
+
+	want := []string{
+		oid(1, 1, colDeviceName, 1).String(),
+		oid(1, 1, colDeviceListenPort, 1).String(),
+		oid(1, 1, colDeviceListenPort, 2).String(),
+	}
+
+	var got []string
+	for _, v := range values(res.VarBinds) {
+		got = append(got, v.OID)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected objects (-want +got):\n%s", diff)
+	}
+}
+
+func TestAgentSet(t *testing.T) {
+	res := do(t, testAgent(), pduSetRequest, 0, 0, oid(1, 1, colDeviceName, 1))
+	if res.ErrorStatus != errorNotWritable || res.ErrorIndex != 1 {
+		t.Fatalf("unexpected error status %d and index %d", res.ErrorStatus, res.ErrorIndex)
+	}
+}
+
+func TestAgentIgnored(t *testing.T) {
+	a := testAgent()
+
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{
+			name: "bad community",
+			b:    request(t, "private", pduGetRequest, 0, 0, Root),
+		},
+		{
+			name: "response",
+			b:    request(t, "public", pduResponse, 0, 0, Root),
+		},
+		{
+			name: "garbage",
+			b:    []byte{0x30, 0xff},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := a.handle(tt.b); ok {
+				t.Fatal("agent responded to request which should be ignored")
+			}
+		})
+	}
+}
+
+func TestAgentServe(t *testing.T) {
+	c, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("skipping, failed to listen: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- testAgent().Serve(c) }()
+
+	client, err := net.Dial("udp", c.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	if _, err := client.Write(request(t, "public", pduGetRequest, 0, 0, oid(1, 1, colDeviceName, 2))); err != nil {
+		t.Fatalf("failed to write request: %v", err)
+	}
+
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set deadline: %v", err)
+	}
+
+	b := make([]byte, maxMessageSize)
+	n, err := client.Read(b)
+	if err != nil {
+		t.Fatalf("failed to read response: %v", err)
+	}
+
+	res := response(t, b[:n])
+	if diff := cmp.Diff("wg1", string(res.VarBinds[0].Value.Bytes)); diff != "" {
+		t.Fatalf("unexpected device name (-want +got):\n%s", diff)
+	}
+
+	_ = c.Close()
+	if err := <-done; err == nil {
+		t.Fatal("expected an error after closing connection, but none occurred")
+	}
+}
@@ -0,0 +1,10 @@
+// Package wgsnmp implements a read-only SNMPv2c agent which exposes the
+// statistics of WireGuard devices and their peers, for monitoring systems
+// which only support SNMP.
+//
+// An Agent serves Snapshots from a wgstats.Sampler, so that SNMP and
+// Prometheus exporters running in the same process share a single view of
+// the devices. The objects served by the agent are described by the
+// WIREGUARD-MIB module in MIB, which is rooted at Root in the experimental
+// arc. SNMPv1 and SNMPv3 are not supported.
+package wgsnmp // import "golang.zx2c4.com/wireguard/wgctrl/wgsnmp"
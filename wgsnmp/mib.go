@@ -0,0 +1,288 @@
+package wgsnmp
+
+import (
+	"encoding/asn1"
+	"sort"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+)
+
+// Root is the object identifier of the wireguardMIB module, in the
+// experimental arc (1.3.6.1.3).
+var Root = asn1.ObjectIdentifier{1, 3, 6, 1, 3, 51820}
+
+// Columns of the wgDeviceTable, rooted at Root.1.1.
+const (
+	colDeviceIndex      = 1
+	colDeviceName       = 2
+	colDevicePublicKey  = 3
+	colDeviceListenPort = 4
+	colDevicePeers      = 5
+)
+
+// Columns of the wgPeerTable, rooted at Root.2.1.
+const (
+	colPeerPublicKey     = 1
+	colPeerEndpoint      = 2
+	colPeerLastHandshake = 3
+	colPeerReceiveBytes  = 4
+	colPeerTransmitBytes = 5
+	colPeerAllowedIPs    = 6
+)
+
+// MIB is the SMIv2 definition of the objects served by an Agent.
+const MIB = `WIREGUARD-MIB DEFINITIONS ::= BEGIN
+
+IMPORTS
+    MODULE-IDENTITY, OBJECT-TYPE, Integer32, Gauge32, Unsigned32,
+    Counter64, experimental
+        FROM SNMPv2-SMI
+    DisplayString
+        FROM SNMPv2-TC;
+
+wireguardMIB MODULE-IDENTITY
+    LAST-UPDATED "202301010000Z"
+    ORGANIZATION "WireGuard"
+    CONTACT-INFO "https://www.wireguard.com/"
+    DESCRIPTION  "Statistics of WireGuard devices and their peers."
+    ::= { experimental 51820 }
+
+wgDeviceTable OBJECT-TYPE
+    SYNTAX      SEQUENCE OF WgDeviceEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "WireGuard devices, ordered by name."
+    ::= { wireguardMIB 1 }
+
+wgDeviceEntry OBJECT-TYPE
+    SYNTAX      WgDeviceEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "A WireGuard device."
+    INDEX       { wgDeviceIndex }
+    ::= { wgDeviceTable 1 }
+
+WgDeviceEntry ::= SEQUENCE {
+    wgDeviceIndex      Integer32,
+    wgDeviceName       DisplayString,
+    wgDevicePublicKey  DisplayString,
+    wgDeviceListenPort Integer32,
+    wgDevicePeers      Gauge32
+}
+
+wgDeviceIndex OBJECT-TYPE
+    SYNTAX      Integer32 (1..2147483647)
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The position of the device in the table. Indices may change
+                 when devices are added or removed."
+    ::= { wgDeviceEntry 1 }
+
+wgDeviceName OBJECT-TYPE
+    SYNTAX      DisplayString
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The interface name of the device."
+    ::= { wgDeviceEntry 2 }
+
+wgDevicePublicKey OBJECT-TYPE
+    SYNTAX      DisplayString
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The base64-encoded public key of the device."
+    ::= { wgDeviceEntry 3 }
+
+wgDeviceListenPort OBJECT-TYPE
+    SYNTAX      Integer32 (0..65535)
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The UDP port on which the device listens."
+    ::= { wgDeviceEntry 4 }
+
+wgDevicePeers OBJECT-TYPE
+    SYNTAX      Gauge32
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The number of peers configured on the device."
+    ::= { wgDeviceEntry 5 }
+
+wgPeerTable OBJECT-TYPE
+    SYNTAX      SEQUENCE OF WgPeerEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "Peers of WireGuard devices, ordered by public key."
+    ::= { wireguardMIB 2 }
+
+wgPeerEntry OBJECT-TYPE
+    SYNTAX      WgPeerEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    DESCRIPTION "A peer of a WireGuard device."
+    INDEX       { wgDeviceIndex, wgPeerIndex }
+    ::= { wgPeerTable 1 }
+
+WgPeerEntry ::= SEQUENCE {
+    wgPeerPublicKey     DisplayString,
+    wgPeerEndpoint      DisplayString,
+    wgPeerLastHandshake Unsigned32,
+    wgPeerReceiveBytes  Counter64,
+    wgPeerTransmitBytes Counter64,
+    wgPeerAllowedIPs    Gauge32
+}
+
+wgPeerPublicKey OBJECT-TYPE
+    SYNTAX      DisplayString
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The base64-encoded public key of the peer."
+    ::= { wgPeerEntry 1 }
+
+wgPeerEndpoint OBJECT-TYPE
+    SYNTAX      DisplayString
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The IP address and port of the peer, or empty if unknown."
+    ::= { wgPeerEntry 2 }
+
+wgPeerLastHandshake OBJECT-TYPE
+    SYNTAX      Unsigned32
+    UNITS       "seconds"
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "UNIX time of the last handshake with the peer, or 0 if
+                 none has occurred."
+    ::= { wgPeerEntry 3 }
+
+wgPeerReceiveBytes OBJECT-TYPE
+    SYNTAX      Counter64
+    UNITS       "bytes"
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Bytes received from the peer."
+    ::= { wgPeerEntry 4 }
+
+wgPeerTransmitBytes OBJECT-TYPE
+    SYNTAX      Counter64
+    UNITS       "bytes"
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "Bytes transmitted to the peer."
+    ::= { wgPeerEntry 5 }
+
+wgPeerAllowedIPs OBJECT-TYPE
+    SYNTAX      Gauge32
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION "The number of allowed IP prefixes of the peer."
+    ::= { wgPeerEntry 6 }
+
+END
+`
+
+// An object is an instance of a MIB object and its encoded value.
+type object struct {
+	oid   asn1.ObjectIdentifier
+	value asn1.RawValue
+}
+
+// objects produces the instances of all MIB objects for snap, in
+// lexicographic order of their object identifiers.
+func objects(snap *wgstats.Snapshot) []object {
+	devices := make([]wgstats.DeviceStats, len(snap.Devices))
+	copy(devices, snap.Devices)
+	sort.SliceStable(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+
+	var objs []object
+	add := func(table, column int, index []int, v asn1.RawValue) {
+		oid := make(asn1.ObjectIdentifier, 0, len(Root)+3+len(index))
+		oid = append(oid, Root...)
+		oid = append(oid, table, 1, column)
+		oid = append(oid, index...)
+
+		objs = append(objs, object{oid: oid, value: v})
+	}
+
+	// Tables are walked column by column, so emit each column for every row
+	// before moving on to the next column.
+	for col := colDeviceIndex; col <= colDevicePeers; col++ {
+		for i, d := range devices {
+			var v asn1.RawValue
+			switch col {
+			case colDeviceIndex:
+				v = integer(int64(i + 1))
+			case colDeviceName:
+				v = octetString(d.Name)
+			case colDevicePublicKey:
+				v = octetString(d.PublicKey.String())
+			case colDeviceListenPort:
+				v = integer(int64(d.ListenPort))
+			case colDevicePeers:
+				v = unsigned(tagGauge32, uint64(len(d.Peers)))
+			}
+
+			add(1, col, []int{i + 1}, v)
+		}
+	}
+
+	peers := make([][]wgstats.PeerStats, len(devices))
+	for i, d := range devices {
+		peers[i] = make([]wgstats.PeerStats, len(d.Peers))
+		copy(peers[i], d.Peers)
+		sort.SliceStable(peers[i], func(a, b int) bool {
+			return peers[i][a].PublicKey.String() < peers[i][b].PublicKey.String()
+		})
+	}
+
+	for col := colPeerPublicKey; col <= colPeerAllowedIPs; col++ {
+		for i := range devices {
+			for j, p := range peers[i] {
+				var v asn1.RawValue
+				switch col {
+				case colPeerPublicKey:
+					v = octetString(p.PublicKey.String())
+				case colPeerEndpoint:
+					v = octetString(p.Endpoint)
+				case colPeerLastHandshake:
+					var secs uint64
+					if !p.LastHandshakeTime.IsZero() {
+						secs = uint64(p.LastHandshakeTime.Unix())
+					}
+					v = unsigned(tagGauge32, secs)
+				case colPeerReceiveBytes:
+					v = unsigned(tagCounter64, uint64(p.ReceiveBytes))
+				case colPeerTransmitBytes:
+					v = unsigned(tagCounter64, uint64(p.TransmitBytes))
+				case colPeerAllowedIPs:
+					v = unsigned(tagGauge32, uint64(p.AllowedIPs))
+				}
+
+				add(2, col, []int{i + 1, j + 1}, v)
+			}
+		}
+	}
+
+	return objs
+}
+
+// compareOIDs compares two object identifiers lexicographically.
+func compareOIDs(a, b asn1.ObjectIdentifier) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return 1
+	default:
+		return 0
+	}
+}
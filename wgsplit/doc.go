@@ -0,0 +1,19 @@
+// Package wgsplit implements per-application split tunneling on Linux by
+// marking the traffic of selected processes, so that wgroute's policy
+// routing steers it into or around a WireGuard device.
+//
+// Processes are selected by cgroup: either by a cgroup v2 path, which Join
+// can create and move processes into, or by a cgroup v1 net_cls class ID.
+// Their packets are given the firewall mark of a wgroute.Tunnel. If the
+// tunnel is marked, this routes the packets through the tunnel, and they are
+// masqueraded so that their source address belongs to the device. If the
+// tunnel is the default, the mark is also the device's firewall mark, so the
+// packets bypass the tunnel instead.
+//
+// Replies to masqueraded packets arrive with a source address which is only
+// routable through the tunnel, so reverse path filtering must consider
+// firewall marks, as with the net.ipv4.conf.all.src_valid_mark sysctl set by
+// wg-quick(8).
+//
+// Apply and Teardown run the nft(8) command, which must be installed.
+package wgsplit // import "golang.zx2c4.com/wireguard/wgctrl/wgsplit"
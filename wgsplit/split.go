@@ -0,0 +1,231 @@
+package wgsplit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgroute"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// CgroupRoot is the mount point of the cgroup v2 hierarchy.
+const CgroupRoot = "/sys/fs/cgroup"
+
+// A Split steers the traffic of the processes in one or more cgroups into or
+// around a tunnel.
+type Split struct {
+	// Name identifies the Split, and names the nftables table which holds
+	// its rules.
+	Name string
+
+	// Tunnel is the tunnel whose firewall mark is given to the traffic.
+	Tunnel wgroute.Tunnel
+
+	// Cgroups are cgroup v2 paths, relative to CgroupRoot, whose processes'
+	// traffic is marked. The cgroups must exist when the Split is applied.
+	Cgroups []string
+
+	// ClassID, if not 0, is a cgroup v1 net_cls class ID whose processes'
+	// traffic is marked.
+	ClassID uint32
+}
+
+// Table returns the name of the nftables table which holds the rules for
+// the Split specified by name.
+func Table(name string) string {
+	return "wgsplit-" + name
+}
+
+// CheckDevice verifies that the firewall mark of d, the tunnel's device, is
+// coordinated with the mark given to the Split's traffic: it must be the
+// tunnel's mark if the tunnel is the default, so that the traffic bypasses
+// the tunnel, and must differ otherwise, so that the device's encapsulated
+// packets are not routed back into the tunnel.
+func (s Split) CheckDevice(d *wgtypes.Device) error {
+	t := s.Tunnel
+	if d.Name != t.Device {
+		return fmt.Errorf("wgsplit: device %q is not the tunnel device %q", d.Name, t.Device)
+	}
+
+	if t.Default && d.FirewallMark != t.Mark {
+		return fmt.Errorf("wgsplit: device %q must have firewall mark %#x, but has %#x", d.Name, t.Mark, d.FirewallMark)
+	}
+	if !t.Default && d.FirewallMark == t.Mark {
+		return fmt.Errorf("wgsplit: device %q must not have the tunnel's firewall mark %#x", d.Name, t.Mark)
+	}
+
+	return nil
+}
+
+// Script returns the nft(8) script which atomically replaces any rules
+// previously installed for the Split with s.
+func (s Split) Script() (string, error) {
+	if err := checkName(s.Name); err != nil {
+		return "", err
+	}
+	if err := wgroute.Check(s.Tunnel); err != nil {
+		return "", err
+	}
+	if len(s.Cgroups) == 0 && s.ClassID == 0 {
+		return "", fmt.Errorf("wgsplit: split %q selects no processes", s.Name)
+	}
+
+	var b strings.Builder
+	b.WriteString(teardown(s.Name))
+
+	mark := "0x" + strconv.FormatInt(int64(s.Tunnel.Mark), 16)
+
+	fmt.Fprintf(&b, "table inet %s {\n", Table(s.Name))
+
+	// A route chain reroutes packets whose mark changes.
+	b.WriteString("\tchain output {\n")
+	b.WriteString("\t\ttype route hook output priority -150; policy accept;\n")
+	for _, cg := range s.Cgroups {
+		cg, err := cleanCgroup(cg)
+		if err != nil {
+			return "", err
+		}
+
+		level := strings.Count(cg, "/") + 1
+		fmt.Fprintf(&b, "\t\tsocket cgroupv2 level %d %q meta mark set %s\n", level, cg, mark)
+	}
+	if s.ClassID != 0 {
+		fmt.Fprintf(&b, "\t\tmeta cgroup %#x meta mark set %s\n", s.ClassID, mark)
+	}
+	b.WriteString("\t}\n")
+
+	// Packets rerouted into the tunnel still have the source address chosen
+	// by their original route.
+	if !s.Tunnel.Default {
+		b.WriteString("\tchain postrouting {\n")
+		b.WriteString("\t\ttype nat hook postrouting priority 100; policy accept;\n")
+		fmt.Fprintf(&b, "\t\tmeta mark %s oifname %q masquerade\n", mark, s.Tunnel.Device)
+		b.WriteString("\t}\n")
+	}
+
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// TeardownScript returns the nft(8) script which removes any rules installed
+// for the Split specified by name.
+func TeardownScript(name string) (string, error) {
+	if err := checkName(name); err != nil {
+		return "", err
+	}
+
+	return teardown(name), nil
+}
+
+// teardown returns script which removes the table for the Split name.
+// Declaring the table first means the deletion succeeds even if the table
+// does not exist.
+func teardown(name string) string {
+	return fmt.Sprintf("table inet %[1]s\ndelete table inet %[1]s\n", Table(name))
+}
+
+// Apply installs the rules for s, replacing any previously installed for a
+// Split with the same name. The routes and rules of s.Tunnel are installed
+// separately, using wgroute.
+func Apply(ctx context.Context, s Split) error {
+	script, err := s.Script()
+	if err != nil {
+		return err
+	}
+
+	return run(ctx, script)
+}
+
+// Teardown removes the rules for the Split specified by name. It is not an
+// error if no rules are installed.
+func Teardown(ctx context.Context, name string) error {
+	script, err := TeardownScript(name)
+	if err != nil {
+		return err
+	}
+
+	return run(ctx, script)
+}
+
+// Join moves the process pid into the cgroup v2 path cgroup, relative to
+// CgroupRoot, creating the cgroup if it does not exist. Processes started by
+// pid afterward are also in the cgroup.
+func Join(cgroup string, pid int) error {
+	return join(CgroupRoot, cgroup, pid)
+}
+
+// join implements Join for the cgroup v2 hierarchy mounted at root.
+func join(root, cgroup string, pid int) error {
+	cg, err := cleanCgroup(cgroup)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(root, filepath.FromSlash(cg))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("wgsplit: failed to create cgroup: %w", err)
+	}
+
+	// cgroup.procs must be written without truncation or creation.
+	f, err := os.OpenFile(filepath.Join(dir, "cgroup.procs"), os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("wgsplit: failed to open cgroup: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(strconv.Itoa(pid)); err != nil {
+		return fmt.Errorf("wgsplit: failed to move process %d into cgroup %q: %w", pid, cg, err)
+	}
+
+	return f.Close()
+}
+
+// cleanCgroup validates and cleans a cgroup v2 path relative to the root of
+// the hierarchy.
+func cleanCgroup(cgroup string) (string, error) {
+	cg := strings.Trim(filepath.ToSlash(filepath.Clean("/"+cgroup)), "/")
+	if cg == "" || strings.ContainsAny(cg, "\"\\\n") {
+		return "", fmt.Errorf("wgsplit: invalid cgroup path %q", cgroup)
+	}
+
+	return cg, nil
+}
+
+// checkName verifies that name can be used in a table name.
+func checkName(name string) error {
+	if name == "" {
+		return errors.New("wgsplit: split name must not be empty")
+	}
+
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return fmt.Errorf("wgsplit: invalid split name %q", name)
+		}
+	}
+
+	return nil
+}
+
+// run applies script as a single nftables transaction.
+func run(ctx context.Context, script string) error {
+	cmd := exec.CommandContext(ctx, "nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := bytes.TrimSpace(out); len(msg) > 0 {
+			return fmt.Errorf("wgsplit: nft failed: %v: %s", err, msg)
+		}
+
+		return fmt.Errorf("wgsplit: nft failed: %w", err)
+	}
+
+	return nil
+}
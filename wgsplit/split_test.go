@@ -0,0 +1,191 @@
+package wgsplit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgroute"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSplitScript(t *testing.T) {
+	tests := []struct {
+		name string
+		s    Split
+		want string
+	}{
+		{
+			name: "into",
+			s: Split{
+				Name:    "browser",
+				Tunnel:  wgroute.Tunnel{Device: "wg1", Table: 1001, Mark: 0x1001},
+				Cgroups: []string{"/wgsplit/browser/"},
+				ClassID: 0x100001,
+			},
+			want: `table inet wgsplit-browser
+delete table inet wgsplit-browser
+table inet wgsplit-browser {
+	chain output {
+		type route hook output priority -150; policy accept;
+		socket cgroupv2 level 2 "wgsplit/browser" meta mark set 0x1001
+		meta cgroup 0x100001 meta mark set 0x1001
+	}
+	chain postrouting {
+		type nat hook postrouting priority 100; policy accept;
+		meta mark 0x1001 oifname "wg1" masquerade
+	}
+}
+`,
+		},
+		{
+			name: "away",
+			s: Split{
+				Name:    "games",
+				Tunnel:  wgroute.Tunnel{Device: "wg0", Table: 51820, Mark: 51820, Default: true},
+				Cgroups: []string{"games"},
+			},
+			want: `table inet wgsplit-games
+delete table inet wgsplit-games
+table inet wgsplit-games {
+	chain output {
+		type route hook output priority -150; policy accept;
+		socket cgroupv2 level 1 "games" meta mark set 0xca6c
+	}
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.s.Script()
+			if err != nil {
+				t.Fatalf("failed to generate script: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("unexpected script (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSplitScriptErrors(t *testing.T) {
+	tunnel := wgroute.Tunnel{Device: "wg1", Table: 1001, Mark: 0x1001}
+
+	tests := []struct {
+		name string
+		s    Split
+	}{
+		{
+			name: "no name",
+			s:    Split{Tunnel: tunnel, Cgroups: []string{"a"}},
+		},
+		{
+			name: "bad name",
+			s:    Split{Name: "a b", Tunnel: tunnel, Cgroups: []string{"a"}},
+		},
+		{
+			name: "bad tunnel",
+			s:    Split{Name: "a", Cgroups: []string{"a"}},
+		},
+		{
+			name: "no processes",
+			s:    Split{Name: "a", Tunnel: tunnel},
+		},
+		{
+			name: "root cgroup",
+			s:    Split{Name: "a", Tunnel: tunnel, Cgroups: []string{"/"}},
+		},
+		{
+			name: "quoted cgroup",
+			s:    Split{Name: "a", Tunnel: tunnel, Cgroups: []string{`a"b`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.s.Script()
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func TestSplitCheckDevice(t *testing.T) {
+	tests := []struct {
+		name string
+		def  bool
+		mark int
+		ok   bool
+	}{
+		{
+			name: "into, other mark",
+			mark: 0x2000,
+			ok:   true,
+		},
+		{
+			name: "into, tunnel mark",
+			mark: 0x1000,
+		},
+		{
+			name: "away, tunnel mark",
+			def:  true,
+			mark: 0x1000,
+			ok:   true,
+		},
+		{
+			name: "away, no mark",
+			def:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Split{Tunnel: wgroute.Tunnel{Device: "wg0", Mark: 0x1000, Default: tt.def}}
+
+			err := s.CheckDevice(&wgtypes.Device{Name: "wg0", FirewallMark: tt.mark})
+			if tt.ok && err != nil {
+				t.Fatalf("failed to check device: %v", err)
+			}
+			if !tt.ok {
+				if err == nil {
+					t.Fatal("expected an error, but none occurred")
+				}
+
+				t.Logf("OK error: %v", err)
+			}
+		})
+	}
+}
+
+func Test_join(t *testing.T) {
+	root := t.TempDir()
+
+	// The kernel creates cgroup.procs along with each cgroup.
+	dir := filepath.Join(root, "wgsplit", "browser")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create cgroup: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cgroup.procs"), nil, 0o644); err != nil {
+		t.Fatalf("failed to create cgroup.procs: %v", err)
+	}
+
+	if err := join(root, "../wgsplit/browser", 1234); err != nil {
+		t.Fatalf("failed to join cgroup: %v", err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "cgroup.procs"))
+	if err != nil {
+		t.Fatalf("failed to read cgroup.procs: %v", err)
+	}
+
+	if diff := cmp.Diff("1234", string(b)); diff != "" {
+		t.Fatalf("unexpected cgroup.procs (-want +got):\n%s", diff)
+	}
+}
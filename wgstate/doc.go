@@ -0,0 +1,14 @@
+// Package wgstate provides an encrypted on-disk store for the configurations
+// of WireGuard devices, including their private keys and the preshared keys
+// of their peers.
+//
+// A Store encrypts its file using NaCl secretbox with a caller-supplied key,
+// so that tools which persist device state do not write plaintext key files.
+// Snapshot stores the current configuration of a device, and Restore applies
+// a stored configuration to a device, for example after a reboot.
+//
+// SnapshotSealed and RestoreSealed do the same without a Store, producing and
+// consuming an opaque blob encrypted with XChaCha20-Poly1305, so that backup
+// pipelines can store and transfer device state without handling plaintext
+// keys.
+package wgstate // import "golang.zx2c4.com/wireguard/wgctrl/wgstate"
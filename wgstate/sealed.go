@@ -0,0 +1,107 @@
+package wgstate
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// sealedMagic identifies a sealed snapshot and its format version. It is
+// also authenticated as additional data.
+var sealedMagic = []byte("wgsealed\x01")
+
+// SnapshotSealed retrieves device using c and returns its full configuration
+// encrypted with XChaCha20-Poly1305 using key, which must be kept secret.
+// The result can be handed to a backup pipeline which never sees the
+// device's private key or the preshared keys of its peers in plaintext.
+//
+// The plaintext configuration is only held in memory by SnapshotSealed. Its
+// final JSON encoding is zeroed before SnapshotSealed returns, but copies of
+// the keys made while retrieving and encoding the device, such as their
+// base64 strings, remain in memory until they are garbage collected.
+//
+// If c retrieves the device without its secrets, such as a *wgctrl.Client
+// created with wgctrl.WithoutSecrets, an error is returned, as described by
+// DeviceConfig.
+func SnapshotSealed(c Client, device string, key *[32]byte) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New("wgstate: key must not be nil")
+	}
+
+	d, err := c.Device(device)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := DeviceConfig(d)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(plain)
+
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 0, len(sealedMagic)+len(nonce)+len(plain)+aead.Overhead())
+	b = append(b, sealedMagic...)
+	b = append(b, nonce...)
+
+	return aead.Seal(b, nonce, plain, sealedMagic), nil
+}
+
+// RestoreSealed decrypts a snapshot produced by SnapshotSealed using key and
+// applies it to device using c. The snapshot may be restored to a device
+// other than the one it was taken from. If the snapshot cannot be decrypted,
+// such as when the wrong key is used or it has been modified, ErrDecrypt is
+// returned.
+func RestoreSealed(c Client, device string, key *[32]byte, sealed []byte) error {
+	if key == nil {
+		return errors.New("wgstate: key must not be nil")
+	}
+
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(sealed, sealedMagic) || len(sealed) < len(sealedMagic)+aead.NonceSize() {
+		return errors.New("wgstate: not a sealed snapshot")
+	}
+	sealed = sealed[len(sealedMagic):]
+
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], sealedMagic)
+	if err != nil {
+		return ErrDecrypt
+	}
+	defer zero(plain)
+
+	var cfg wgtypes.Config
+	if err := json.Unmarshal(plain, &cfg); err != nil {
+		return fmt.Errorf("wgstate: failed to parse snapshot: %v", err)
+	}
+
+	return c.ConfigureDevice(device, cfg)
+}
+
+// zero overwrites b with zeros.
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
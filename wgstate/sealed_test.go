@@ -0,0 +1,97 @@
+package wgstate_test
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstate"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSnapshotRestoreSealed(t *testing.T) {
+	d := &wgtypes.Device{
+		Name:       "wg0",
+		PrivateKey: wgtest.MustPrivateKey(),
+		ListenPort: 51820,
+		Peers: []wgtypes.Peer{{
+			PublicKey:    wgtest.MustPublicKey(),
+			PresharedKey: wgtest.MustPresharedKey(),
+			AllowedIPs:   []net.IPNet{wgtest.MustCIDR("10.0.0.0/24")},
+		}},
+	}
+
+	key, err := wgstate.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	var (
+		gotName string
+		got     wgtypes.Config
+	)
+
+	c := &testClient{
+		DeviceFunc: func(_ string) (*wgtypes.Device, error) { return d, nil },
+		ConfigureDeviceFunc: func(name string, cfg wgtypes.Config) error {
+			gotName, got = name, cfg
+			return nil
+		},
+	}
+
+	sealed, err := wgstate.SnapshotSealed(c, "wg0", key)
+	if err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+
+	for _, k := range []wgtypes.Key{d.PrivateKey, d.Peers[0].PresharedKey} {
+		if bytes.Contains(sealed, k[:]) || bytes.Contains(sealed, []byte(k.String())) {
+			t.Fatal("snapshot contains a plaintext key")
+		}
+	}
+
+	// Restore to a different device.
+	if err := wgstate.RestoreSealed(c, "wg1", key, sealed); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	if diff := cmp.Diff("wg1", gotName); diff != "" {
+		t.Fatalf("unexpected device (-want +got):\n%s", diff)
+	}
+	want, err := wgstate.DeviceConfig(d)
+	if err != nil {
+		t.Fatalf("failed to produce configuration: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected restored configuration (-want +got):\n%s", diff)
+	}
+
+	if err := wgstate.RestoreSealed(c, "wg0", &[32]byte{1}, sealed); !errors.Is(err, wgstate.ErrDecrypt) {
+		t.Fatalf("expected decryption error, but got: %v", err)
+	}
+
+	sealed[len(sealed)-1] ^= 0xff
+	if err := wgstate.RestoreSealed(c, "wg0", key, sealed); !errors.Is(err, wgstate.ErrDecrypt) {
+		t.Fatalf("expected decryption error, but got: %v", err)
+	}
+
+	if err := wgstate.RestoreSealed(c, "wg0", key, []byte("wgstate")); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
+
+func TestSnapshotSealedWithoutSecrets(t *testing.T) {
+	c := &testClient{
+		DeviceFunc: func(_ string) (*wgtypes.Device, error) {
+			return &wgtypes.Device{Name: "wg0", PublicKey: wgtest.MustPublicKey()}, nil
+		},
+	}
+
+	if _, err := wgstate.SnapshotSealed(c, "wg0", &[32]byte{1}); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
@@ -0,0 +1,295 @@
+package wgstate
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.zx2c4.com/wireguard/wgctrl/wgpeer"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Client is the subset of *wgctrl.Client used to snapshot and restore
+// devices.
+type Client interface {
+	Device(name string) (*wgtypes.Device, error)
+	ConfigureDevice(name string, cfg wgtypes.Config) error
+}
+
+// magic identifies a Store file and its format version. Version 2 encodes
+// each Config using its canonical JSON representation, as produced by
+// wgtypes.Config.MarshalJSON, while version 1 used the default encoding of
+// the Config structure.
+var (
+	magic   = []byte("wgstate\x02")
+	magicV1 = []byte("wgstate\x01")
+)
+
+// configV1 is a Config as encoded by version 1 of the Store format. As a
+// distinct type, it does not have the JSON methods of wgtypes.Config.
+type configV1 wgtypes.Config
+
+// nonceSize is the size of a secretbox nonce.
+const nonceSize = 24
+
+// ErrDecrypt is returned by Open if a Store file cannot be decrypted, such as
+// when the wrong key is used or the file has been modified.
+var ErrDecrypt = errors.New("wgstate: failed to decrypt state")
+
+// A Store is an encrypted file which stores device configurations. A Store
+// is safe for concurrent use, but only one Store may use a given file.
+type Store struct {
+	path string
+	key  *[32]byte
+
+	mu   sync.Mutex
+	cfgs map[string]wgtypes.Config
+}
+
+// GenerateKey generates a random key for use with Open.
+func GenerateKey() (*[32]byte, error) {
+	var key [32]byte
+	if _, err := rand.Read(key[:]); err != nil {
+		return nil, err
+	}
+
+	return &key, nil
+}
+
+// Open opens the Store in the file at path using key, which must be kept
+// secret. If the file does not exist, an empty Store is returned and the file
+// is created when the Store is first modified.
+func Open(path string, key *[32]byte) (*Store, error) {
+	if key == nil {
+		return nil, errors.New("wgstate: key must not be nil")
+	}
+
+	s := &Store{
+		path: path,
+		key:  key,
+		cfgs: make(map[string]wgtypes.Config),
+	}
+
+	b, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return s, nil
+	case err != nil:
+		return nil, err
+	}
+
+	v1 := bytes.HasPrefix(b, magicV1)
+	if (!v1 && !bytes.HasPrefix(b, magic)) || len(b) < len(magic)+nonceSize {
+		return nil, fmt.Errorf("wgstate: %q is not a state file", path)
+	}
+	b = b[len(magic):]
+
+	var nonce [nonceSize]byte
+	copy(nonce[:], b)
+
+	plain, ok := secretbox.Open(nil, b[nonceSize:], &nonce, key)
+	if !ok {
+		return nil, ErrDecrypt
+	}
+
+	if !v1 {
+		if err := json.Unmarshal(plain, &s.cfgs); err != nil {
+			return nil, fmt.Errorf("wgstate: failed to parse state: %v", err)
+		}
+
+		return s, nil
+	}
+
+	// Version 1 files are upgraded when the Store is next modified.
+	var cfgs map[string]configV1
+	if err := json.Unmarshal(plain, &cfgs); err != nil {
+		return nil, fmt.Errorf("wgstate: failed to parse state: %v", err)
+	}
+	for name, cfg := range cfgs {
+		s.cfgs[name] = wgtypes.Config(cfg)
+	}
+
+	return s, nil
+}
+
+// Devices returns the names of the devices in the Store, in sorted order.
+func (s *Store) Devices() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.cfgs))
+	for name := range s.cfgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Get returns the stored configuration of device. If no configuration is
+// stored, an error compatible with os.ErrNotExist is returned.
+func (s *Store) Get(device string) (wgtypes.Config, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, ok := s.cfgs[device]
+	if !ok {
+		return wgtypes.Config{}, os.ErrNotExist
+	}
+
+	return cfg, nil
+}
+
+// Put stores cfg as the configuration of device, replacing any existing
+// configuration, and writes the Store to its file.
+func (s *Store) Put(device string, cfg wgtypes.Config) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.cfgs[device]
+	s.cfgs[device] = cfg
+	if err := s.save(); err != nil {
+		// Keep the in-memory state consistent with the file.
+		if ok {
+			s.cfgs[device] = prev
+		} else {
+			delete(s.cfgs, device)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the configuration of device, if any, and writes the Store
+// to its file.
+func (s *Store) Delete(device string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prev, ok := s.cfgs[device]
+	if !ok {
+		return nil
+	}
+
+	delete(s.cfgs, device)
+	if err := s.save(); err != nil {
+		s.cfgs[device] = prev
+		return err
+	}
+
+	return nil
+}
+
+// Snapshot retrieves device using c and stores its full configuration. If c
+// retrieves the device without its secrets, an error is returned, as
+// described by DeviceConfig.
+func (s *Store) Snapshot(c Client, device string) error {
+	d, err := c.Device(device)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := DeviceConfig(d)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(device, cfg)
+}
+
+// Restore applies the stored configuration of device using c. If no
+// configuration is stored, an error compatible with os.ErrNotExist is
+// returned.
+func (s *Store) Restore(c Client, device string) error {
+	cfg, err := s.Get(device)
+	if err != nil {
+		return err
+	}
+
+	return c.ConfigureDevice(device, cfg)
+}
+
+// DeviceConfig produces a Config which fully restores d, replacing any
+// existing peers.
+//
+// If d was retrieved without its private key or the preshared keys of its
+// peers, such as by a *wgctrl.Client created with wgctrl.WithoutSecrets, an
+// error is returned, as the Config would clear those keys.
+func DeviceConfig(d *wgtypes.Device) (wgtypes.Config, error) {
+	if d.PrivateKey == (wgtypes.Key{}) {
+		return wgtypes.Config{}, fmt.Errorf("wgstate: device %q was retrieved without its private key", d.Name)
+	}
+
+	var (
+		priv = d.PrivateKey
+		port = d.ListenPort
+		mark = d.FirewallMark
+	)
+
+	cfg := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		FirewallMark: &mark,
+		ReplacePeers: true,
+		Peers:        make([]wgtypes.PeerConfig, 0, len(d.Peers)),
+	}
+
+	for _, p := range d.Peers {
+		pc, err := wgpeer.ToConfig(p)
+		if err != nil {
+			return wgtypes.Config{}, err
+		}
+
+		cfg.Peers = append(cfg.Peers, pc)
+	}
+
+	return cfg, nil
+}
+
+// save atomically replaces the Store's file with its current contents. The
+// caller must hold s.mu.
+func (s *Store) save() error {
+	plain, err := json.Marshal(s.cfgs)
+	if err != nil {
+		return err
+	}
+
+	var nonce [nonceSize]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+
+	b := append([]byte(nil), magic...)
+	b = append(b, nonce[:]...)
+	b = secretbox.Seal(b, plain, &nonce, s.key)
+
+	// CreateTemp creates the file with mode 0600.
+	f, err := os.CreateTemp(filepath.Dir(s.path), ".wgstate-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,212 @@
+package wgstate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstate"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestStoreSnapshotRestore(t *testing.T) {
+	d := &wgtypes.Device{
+		Name:       "wg0",
+		PrivateKey: wgtest.MustPrivateKey(),
+		ListenPort: 51820,
+		Peers: []wgtypes.Peer{{
+			PublicKey:                   wgtest.MustPublicKey(),
+			PresharedKey:                wgtest.MustPresharedKey(),
+			Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+			PersistentKeepaliveInterval: 25 * time.Second,
+			AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("10.0.0.0/24")},
+		}},
+	}
+
+	key, err := wgstate.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "state")
+	s, err := wgstate.Open(path, key)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	var got wgtypes.Config
+	c := &testClient{
+		DeviceFunc: func(_ string) (*wgtypes.Device, error) { return d, nil },
+		ConfigureDeviceFunc: func(_ string, cfg wgtypes.Config) error {
+			got = cfg
+			return nil
+		},
+	}
+
+	if err := s.Snapshot(c, "wg0"); err != nil {
+		t.Fatalf("failed to snapshot: %v", err)
+	}
+
+	// Keys must never be written in plaintext.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read store: %v", err)
+	}
+	for _, k := range []wgtypes.Key{d.PrivateKey, d.Peers[0].PresharedKey} {
+		if bytes.Contains(b, k[:]) || bytes.Contains(b, []byte(k.String())) {
+			t.Fatal("store contains a plaintext key")
+		}
+	}
+
+	// Restore from a newly opened Store, as if after a reboot.
+	s, err = wgstate.Open(path, key)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"wg0"}, s.Devices()); diff != "" {
+		t.Fatalf("unexpected devices (-want +got):\n%s", diff)
+	}
+
+	if err := s.Restore(c, "wg0"); err != nil {
+		t.Fatalf("failed to restore: %v", err)
+	}
+
+	want, err := wgstate.DeviceConfig(d)
+	if err != nil {
+		t.Fatalf("failed to produce configuration: %v", err)
+	}
+
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected restored configuration (-want +got):\n%s", diff)
+	}
+
+	if err := s.Delete("wg0"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if err := s.Restore(c, "wg0"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected is not exist error, but got: %v", err)
+	}
+}
+
+func TestStoreSnapshotWithoutSecrets(t *testing.T) {
+	tests := []struct {
+		name string
+		d    *wgtypes.Device
+	}{
+		{
+			name: "private key",
+			d:    &wgtypes.Device{Name: "wg0", PublicKey: wgtest.MustPublicKey()},
+		},
+		{
+			name: "preshared key",
+			d: &wgtypes.Device{
+				Name:       "wg0",
+				PrivateKey: wgtest.MustPrivateKey(),
+				Peers: []wgtypes.Peer{{
+					PublicKey:       wgtest.MustPublicKey(),
+					HasPresharedKey: true,
+				}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := wgstate.Open(filepath.Join(t.TempDir(), "state"), &[32]byte{1})
+			if err != nil {
+				t.Fatalf("failed to open store: %v", err)
+			}
+
+			c := &testClient{
+				DeviceFunc: func(_ string) (*wgtypes.Device, error) { return tt.d, nil },
+			}
+
+			if err := s.Snapshot(c, "wg0"); err == nil {
+				t.Fatal("expected an error, but none occurred")
+			} else {
+				t.Logf("OK error: %v", err)
+			}
+
+			if devices := s.Devices(); len(devices) != 0 {
+				t.Fatalf("expected no devices, but got: %v", devices)
+			}
+		})
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state")
+
+	s, err := wgstate.Open(path, &[32]byte{1})
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	if err := s.Put("wg0", wgtypes.Config{}); err != nil {
+		t.Fatalf("failed to put: %v", err)
+	}
+
+	if _, err := wgstate.Open(path, &[32]byte{2}); !errors.Is(err, wgstate.ErrDecrypt) {
+		t.Fatalf("expected decryption error, but got: %v", err)
+	}
+}
+
+func TestOpenVersion1(t *testing.T) {
+	port := 51820
+	cfg := wgtypes.Config{
+		ListenPort: &port,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.0/24")},
+		}},
+	}
+
+	// Version 1 used the default encoding of the Config structure.
+	type configV1 wgtypes.Config
+	plain, err := json.Marshal(map[string]configV1{"wg0": configV1(cfg)})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	key := &[32]byte{1}
+	var nonce [24]byte
+	b := append([]byte("wgstate\x01"), nonce[:]...)
+	b = secretbox.Seal(b, plain, &nonce, key)
+
+	path := filepath.Join(t.TempDir(), "state")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatalf("failed to write state: %v", err)
+	}
+
+	s, err := wgstate.Open(path, key)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+
+	got, err := s.Get("wg0")
+	if err != nil {
+		t.Fatalf("failed to get configuration: %v", err)
+	}
+
+	if diff := cmp.Diff(cfg, got); diff != "" {
+		t.Fatalf("unexpected configuration (-want +got):\n%s", diff)
+	}
+}
+
+type testClient struct {
+	DeviceFunc          func(name string) (*wgtypes.Device, error)
+	ConfigureDeviceFunc func(name string, cfg wgtypes.Config) error
+}
+
+func (c *testClient) Device(name string) (*wgtypes.Device, error) { return c.DeviceFunc(name) }
+func (c *testClient) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	return c.ConfigureDeviceFunc(name, cfg)
+}
@@ -0,0 +1,16 @@
+// Package wgstats samples statistics from WireGuard devices and exports them
+// to monitoring systems.
+//
+// A Sampler retrieves devices from a source such as a *wgctrl.Client and
+// produces Snapshots of their statistics. Snapshots are cached for a
+// configurable period, so that several exporters polling concurrently do not
+// each query the devices. WritePrometheus and Handler expose Snapshots in
+// the Prometheus text exposition format, and HealthHandler reports whether
+// devices and their peers meet HealthCriteria, for use by load balancers and
+// Kubernetes probes.
+//
+// An SLAMonitor maintains histograms of the handshake ages and rekey
+// intervals of each device's peers, and flags peers whose handshakes are
+// older than an SLA allows. SLAHandler exposes them alongside the statistics
+// served by Handler.
+package wgstats // import "golang.zx2c4.com/wireguard/wgctrl/wgstats"
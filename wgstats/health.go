@@ -0,0 +1,191 @@
+package wgstats
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthCriteria determine whether devices and their peers are healthy. The
+// zero value considers every device and peer healthy.
+type HealthCriteria struct {
+	// Devices lists devices which must be present. If a listed device is
+	// missing, the report is unhealthy.
+	Devices []string
+
+	// MaxHandshakeAge is the maximum age of a peer's last handshake. If
+	// zero, handshake age is not checked.
+	MaxHandshakeAge time.Duration
+
+	// MinHealthyPeers is the minimum number of healthy peers each device
+	// must have.
+	MinHealthyPeers int
+
+	// RequireProgress marks a peer unhealthy if, since the previous
+	// Snapshot, data was transmitted to the peer but none was received.
+	RequireProgress bool
+}
+
+// A HealthReport is the result of checking a Snapshot against
+// HealthCriteria.
+type HealthReport struct {
+	Healthy  bool           `json:"healthy"`
+	Time     time.Time      `json:"time"`
+	Problems []string       `json:"problems,omitempty"`
+	Devices  []DeviceHealth `json:"devices"`
+}
+
+// DeviceHealth reports the health of a single device.
+type DeviceHealth struct {
+	Name         string       `json:"name"`
+	Healthy      bool         `json:"healthy"`
+	HealthyPeers int          `json:"healthyPeers"`
+	Problems     []string     `json:"problems,omitempty"`
+	Peers        []PeerHealth `json:"peers"`
+}
+
+// PeerHealth reports the health of a single peer.
+type PeerHealth struct {
+	PublicKey string `json:"publicKey"`
+	Healthy   bool   `json:"healthy"`
+
+	// HandshakeAge is the number of seconds since the last handshake, or -1
+	// if no handshake has occurred.
+	HandshakeAge float64  `json:"handshakeAgeSeconds"`
+	Problems     []string `json:"problems,omitempty"`
+}
+
+// Check produces a HealthReport for cur. If prev is not nil, it is the
+// previous Snapshot of the same devices, used to check the progress of
+// transfer counters.
+func (hc HealthCriteria) Check(prev, cur *Snapshot) *HealthReport {
+	r := &HealthReport{
+		Healthy: true,
+		Time:    cur.Time,
+		Devices: make([]DeviceHealth, 0, len(cur.Devices)),
+	}
+
+	before := make(map[string]map[string]PeerStats)
+	if prev != nil {
+		for _, d := range prev.Devices {
+			m := make(map[string]PeerStats, len(d.Peers))
+			for _, p := range d.Peers {
+				m[p.PublicKey.String()] = p
+			}
+
+			before[d.Name] = m
+		}
+	}
+
+	present := make(map[string]bool, len(cur.Devices))
+	for _, d := range cur.Devices {
+		present[d.Name] = true
+
+		dh := DeviceHealth{
+			Name:    d.Name,
+			Healthy: true,
+			Peers:   make([]PeerHealth, 0, len(d.Peers)),
+		}
+
+		for _, p := range d.Peers {
+			ph := hc.checkPeer(cur.Time, p, before[d.Name])
+			if ph.Healthy {
+				dh.HealthyPeers++
+			}
+
+			dh.Peers = append(dh.Peers, ph)
+		}
+
+		if dh.HealthyPeers < hc.MinHealthyPeers {
+			dh.Healthy = false
+			dh.Problems = append(dh.Problems, fmt.Sprintf("%d healthy peer(s), want at least %d",
+				dh.HealthyPeers, hc.MinHealthyPeers))
+		}
+
+		r.Healthy = r.Healthy && dh.Healthy
+		r.Devices = append(r.Devices, dh)
+	}
+
+	for _, name := range hc.Devices {
+		if !present[name] {
+			r.Healthy = false
+			r.Problems = append(r.Problems, fmt.Sprintf("device %q not found", name))
+		}
+	}
+
+	return r
+}
+
+// checkPeer checks the health of p at time now, using its previous
+// statistics in before if present.
+func (hc HealthCriteria) checkPeer(now time.Time, p PeerStats, before map[string]PeerStats) PeerHealth {
+	ph := PeerHealth{
+		PublicKey:    p.PublicKey.String(),
+		HandshakeAge: -1,
+	}
+
+	if !p.LastHandshakeTime.IsZero() {
+		ph.HandshakeAge = now.Sub(p.LastHandshakeTime).Seconds()
+	}
+
+	if hc.MaxHandshakeAge > 0 {
+		switch {
+		case p.LastHandshakeTime.IsZero():
+			ph.Problems = append(ph.Problems, "no handshake")
+		case now.Sub(p.LastHandshakeTime) > hc.MaxHandshakeAge:
+			ph.Problems = append(ph.Problems, fmt.Sprintf("last handshake older than %s", hc.MaxHandshakeAge))
+		}
+	}
+
+	if hc.RequireProgress {
+		if b, ok := before[ph.PublicKey]; ok && p.TransmitBytes > b.TransmitBytes && p.ReceiveBytes == b.ReceiveBytes {
+			ph.Problems = append(ph.Problems, "transmitted data without receiving any")
+		}
+	}
+
+	ph.Healthy = len(ph.Problems) == 0
+	return ph
+}
+
+// HealthHandler returns an http.Handler which checks a Snapshot from s
+// against hc on each request, and serves the HealthReport as JSON. The
+// response status is 200 OK if the report is healthy, and 503 Service
+// Unavailable otherwise, so that the handler can be used directly by load
+// balancers and Kubernetes probes.
+func HealthHandler(s *Sampler, hc HealthCriteria) http.Handler {
+	var (
+		mu sync.Mutex
+
+		// cur is the most recent Snapshot, and prev is the distinct
+		// Snapshot which preceded it.
+		cur, prev *Snapshot
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap, err := s.Sample()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("wgstats: failed to sample devices: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		// Compare counters against the most recent distinct Snapshot, since
+		// the Sampler may return the same Snapshot to several requests.
+		mu.Lock()
+		if cur == nil || !cur.Time.Equal(snap.Time) {
+			prev, cur = cur, snap
+		}
+		last := prev
+		mu.Unlock()
+
+		rep := hc.Check(last, snap)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !rep.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+
+		_ = json.NewEncoder(w).Encode(rep)
+	})
+}
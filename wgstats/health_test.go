@@ -0,0 +1,177 @@
+package wgstats_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestHealthCriteriaCheck(t *testing.T) {
+	var (
+		now   = time.Date(2020, 1, 1, 0, 10, 0, 0, time.UTC)
+		fresh = wgtest.MustPublicKey()
+		stale = wgtest.MustPublicKey()
+		never = wgtest.MustPublicKey()
+	)
+
+	snapshot := func(rx, tx int64) *wgstats.Snapshot {
+		return &wgstats.Snapshot{
+			Time: now,
+			Devices: []wgstats.DeviceStats{{
+				Name: "wg0",
+				Peers: []wgstats.PeerStats{
+					{
+						PublicKey:         fresh,
+						LastHandshakeTime: now.Add(-time.Minute),
+						ReceiveBytes:      rx,
+						TransmitBytes:     tx,
+					},
+					{
+						PublicKey:         stale,
+						LastHandshakeTime: now.Add(-10 * time.Minute),
+					},
+					{PublicKey: never},
+				},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		hc       wgstats.HealthCriteria
+		prev     *wgstats.Snapshot
+		healthy  bool
+		problems []string
+		peers    []bool
+	}{
+		{
+			name:    "no criteria",
+			healthy: true,
+			peers:   []bool{true, true, true},
+		},
+		{
+			name: "handshake age",
+			hc: wgstats.HealthCriteria{
+				MaxHandshakeAge: 3 * time.Minute,
+				MinHealthyPeers: 1,
+			},
+			healthy: true,
+			peers:   []bool{true, false, false},
+		},
+		{
+			name: "too few healthy peers",
+			hc: wgstats.HealthCriteria{
+				MaxHandshakeAge: 3 * time.Minute,
+				MinHealthyPeers: 2,
+			},
+			peers: []bool{true, false, false},
+		},
+		{
+			name: "missing device",
+			hc: wgstats.HealthCriteria{
+				Devices: []string{"wg0", "wg1"},
+			},
+			problems: []string{`device "wg1" not found`},
+			peers:    []bool{true, true, true},
+		},
+		{
+			name: "no progress",
+			hc: wgstats.HealthCriteria{
+				RequireProgress: true,
+				MinHealthyPeers: 3,
+			},
+			prev:  snapshot(10, 10),
+			peers: []bool{false, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := tt.hc.Check(tt.prev, snapshot(10, 20))
+
+			if diff := cmp.Diff(tt.healthy, r.Healthy); diff != "" {
+				t.Fatalf("unexpected health (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.problems, r.Problems); diff != "" {
+				t.Fatalf("unexpected problems (-want +got):\n%s", diff)
+			}
+
+			var peers []bool
+			for _, p := range r.Devices[0].Peers {
+				peers = append(peers, p.Healthy)
+			}
+
+			if diff := cmp.Diff(tt.peers, peers); diff != "" {
+				t.Fatalf("unexpected peer health (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	pub := wgtest.MustPublicKey()
+
+	// Data is transmitted on every sample.
+	var rx, tx int64
+	src := sourceFunc(func() ([]*wgtypes.Device, error) {
+		tx++
+		return []*wgtypes.Device{{
+			Name: "wg0",
+			Peers: []wgtypes.Peer{{
+				PublicKey:         pub,
+				LastHandshakeTime: time.Now(),
+				ReceiveBytes:      rx,
+				TransmitBytes:     tx,
+			}},
+		}}, nil
+	})
+
+	h := wgstats.HealthHandler(wgstats.NewSampler(src, 0), wgstats.HealthCriteria{
+		MaxHandshakeAge: time.Minute,
+		MinHealthyPeers: 1,
+		RequireProgress: true,
+	})
+
+	// check performs a request and returns its status code.
+	check := func() (int, wgstats.HealthReport) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+		var r wgstats.HealthReport
+		if err := json.NewDecoder(w.Body).Decode(&r); err != nil {
+			t.Fatalf("failed to decode report: %v", err)
+		}
+
+		return w.Code, r
+	}
+
+	// The first check has nothing to compare counters with.
+	if code, _ := check(); code != http.StatusOK {
+		t.Fatalf("unexpected status code for first check: %d", code)
+	}
+
+	rx = 1
+	if code, _ := check(); code != http.StatusOK {
+		t.Fatalf("unexpected status code while receiving: %d", code)
+	}
+
+	code, r := check()
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected status code without progress: %d", code)
+	}
+
+	if diff := cmp.Diff([]string{"transmitted data without receiving any"}, r.Devices[0].Peers[0].Problems); diff != "" {
+		t.Fatalf("unexpected problems (-want +got):\n%s", diff)
+	}
+}
+
+type sourceFunc func() ([]*wgtypes.Device, error)
+
+func (fn sourceFunc) Devices() ([]*wgtypes.Device, error) { return fn() }
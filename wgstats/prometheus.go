@@ -0,0 +1,99 @@
+package wgstats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WritePrometheus writes the statistics in snap to w in the Prometheus text
+// exposition format.
+func WritePrometheus(w io.Writer, snap *Snapshot) error {
+	bw := bufio.NewWriter(w)
+
+	// header writes the HELP and TYPE lines of a metric.
+	header := func(name, typ, help string) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	// deviceMetric writes a metric for each device.
+	deviceMetric := func(name, typ, help string, fn func(d *DeviceStats) int64) {
+		header(name, typ, help)
+		for i := range snap.Devices {
+			d := &snap.Devices[i]
+			fmt.Fprintf(bw, "%s{device=%s} %d\n", name, quote(d.Name), fn(d))
+		}
+	}
+
+	// peerMetric writes a metric for each peer of each device.
+	peerMetric := func(name, typ, help string, fn func(p *PeerStats) int64) {
+		header(name, typ, help)
+		for i := range snap.Devices {
+			d := &snap.Devices[i]
+			for j := range d.Peers {
+				p := &d.Peers[j]
+				fmt.Fprintf(bw, "%s{device=%s,public_key=%s} %d\n",
+					name, quote(d.Name), quote(p.PublicKey.String()), fn(p))
+			}
+		}
+	}
+
+	header("wireguard_device_info", "gauge", "Metadata about a device.")
+	for _, d := range snap.Devices {
+		fmt.Fprintf(bw, "wireguard_device_info{device=%s,type=%s,public_key=%s} 1\n",
+			quote(d.Name), quote(d.Type.String()), quote(d.PublicKey.String()))
+	}
+
+	deviceMetric("wireguard_device_listen_port", "gauge", "The UDP port on which a device listens.",
+		func(d *DeviceStats) int64 { return int64(d.ListenPort) })
+	deviceMetric("wireguard_device_peers", "gauge", "The number of peers configured on a device.",
+		func(d *DeviceStats) int64 { return int64(len(d.Peers)) })
+
+	header("wireguard_peer_info", "gauge", "Metadata about a peer.")
+	for _, d := range snap.Devices {
+		for _, p := range d.Peers {
+			fmt.Fprintf(bw, "wireguard_peer_info{device=%s,public_key=%s,endpoint=%s} 1\n",
+				quote(d.Name), quote(p.PublicKey.String()), quote(p.Endpoint))
+		}
+	}
+
+	peerMetric("wireguard_peer_receive_bytes_total", "counter", "Bytes received from a peer.",
+		func(p *PeerStats) int64 { return p.ReceiveBytes })
+	peerMetric("wireguard_peer_transmit_bytes_total", "counter", "Bytes transmitted to a peer.",
+		func(p *PeerStats) int64 { return p.TransmitBytes })
+	peerMetric("wireguard_peer_last_handshake_seconds", "gauge", "UNIX time of the last handshake with a peer, or 0 if none has occurred.",
+		func(p *PeerStats) int64 {
+			if p.LastHandshakeTime.IsZero() {
+				return 0
+			}
+
+			return p.LastHandshakeTime.Unix()
+		})
+	peerMetric("wireguard_peer_allowed_ips", "gauge", "The number of allowed IP prefixes of a peer.",
+		func(p *PeerStats) int64 { return int64(p.AllowedIPs) })
+
+	return bw.Flush()
+}
+
+// Handler returns an http.Handler which serves a Snapshot from s in the
+// Prometheus text exposition format on each request.
+func Handler(s *Sampler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap, err := s.Sample()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("wgstats: failed to sample devices: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WritePrometheus(w, snap)
+	})
+}
+
+// quote quotes s as a Prometheus label value.
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
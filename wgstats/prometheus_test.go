@@ -0,0 +1,92 @@
+package wgstats_test
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+type testSource []*wgtypes.Device
+
+func (ts testSource) Devices() ([]*wgtypes.Device, error) { return ts, nil }
+
+func TestHandler(t *testing.T) {
+	pub := wgtest.MustHexKey("e84b5a6d2717c1003a13b431570353dbaca9146cf150c5f8575680feba52027a")
+
+	devices := testSource{{
+		Name:       "wg0",
+		Type:       wgtypes.LinuxKernel,
+		PublicKey:  pub,
+		ListenPort: 51820,
+		Peers: []wgtypes.Peer{
+			{
+				PublicKey:         pub,
+				Endpoint:          wgtest.MustUDPAddr("192.0.2.1:51820"),
+				LastHandshakeTime: time.Unix(1600000000, 0),
+				ReceiveBytes:      1,
+				TransmitBytes:     2,
+				AllowedIPs:        []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+			},
+		},
+	}}
+
+	srv := httptest.NewServer(wgstats.Handler(wgstats.NewSampler(devices, 0)))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to get metrics: %v", err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+
+	k := pub.String()
+	want := strings.Join([]string{
+		"# HELP wireguard_device_info Metadata about a device.",
+		"# TYPE wireguard_device_info gauge",
+		`wireguard_device_info{device="wg0",type="Linux kernel",public_key="` + k + `"} 1`,
+		"# HELP wireguard_device_listen_port The UDP port on which a device listens.",
+		"# TYPE wireguard_device_listen_port gauge",
+		`wireguard_device_listen_port{device="wg0"} 51820`,
+		"# HELP wireguard_device_peers The number of peers configured on a device.",
+		"# TYPE wireguard_device_peers gauge",
+		`wireguard_device_peers{device="wg0"} 1`,
+		"# HELP wireguard_peer_info Metadata about a peer.",
+		"# TYPE wireguard_peer_info gauge",
+		`wireguard_peer_info{device="wg0",public_key="` + k + `",endpoint="192.0.2.1:51820"} 1`,
+		"# HELP wireguard_peer_receive_bytes_total Bytes received from a peer.",
+		"# TYPE wireguard_peer_receive_bytes_total counter",
+		`wireguard_peer_receive_bytes_total{device="wg0",public_key="` + k + `"} 1`,
+		"# HELP wireguard_peer_transmit_bytes_total Bytes transmitted to a peer.",
+		"# TYPE wireguard_peer_transmit_bytes_total counter",
+		`wireguard_peer_transmit_bytes_total{device="wg0",public_key="` + k + `"} 2`,
+		"# HELP wireguard_peer_last_handshake_seconds UNIX time of the last handshake with a peer, or 0 if none has occurred.",
+		"# TYPE wireguard_peer_last_handshake_seconds gauge",
+		`wireguard_peer_last_handshake_seconds{device="wg0",public_key="` + k + `"} 1600000000`,
+		"# HELP wireguard_peer_allowed_ips The number of allowed IP prefixes of a peer.",
+		"# TYPE wireguard_peer_allowed_ips gauge",
+		`wireguard_peer_allowed_ips{device="wg0",public_key="` + k + `"} 1`,
+		"",
+	}, "\n")
+
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Fatalf("unexpected metrics (-want +got):\n%s", diff)
+	}
+
+	if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+}
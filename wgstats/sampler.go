@@ -0,0 +1,127 @@
+package wgstats
+
+import (
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Source retrieves WireGuard devices. *wgctrl.Client implements Source.
+type Source interface {
+	Devices() ([]*wgtypes.Device, error)
+}
+
+// A Snapshot contains the statistics of a set of devices at a point in time.
+type Snapshot struct {
+	Time    time.Time
+	Devices []DeviceStats
+}
+
+// DeviceStats contains the statistics of a single device.
+type DeviceStats struct {
+	Name       string
+	Type       wgtypes.DeviceType
+	PublicKey  wgtypes.Key
+	ListenPort int
+	Peers      []PeerStats
+}
+
+// PeerStats contains the statistics of a single peer of a device.
+type PeerStats struct {
+	PublicKey         wgtypes.Key
+	Endpoint          string
+	LastHandshakeTime time.Time
+	ReceiveBytes      int64
+	TransmitBytes     int64
+	AllowedIPs        int
+}
+
+// A Sampler produces Snapshots of the devices retrieved from a Source. Its
+// methods are safe for concurrent use.
+type Sampler struct {
+	src    Source
+	maxAge time.Duration
+	filter func(name string) bool
+
+	mu   sync.Mutex
+	last *Snapshot
+
+	// now is the current time source, which can be swapped out in tests.
+	now func() time.Time
+}
+
+// NewSampler creates a Sampler which retrieves devices from src. A Snapshot
+// is reused by Sample until it is older than maxAge; if maxAge is zero, each
+// call to Sample retrieves the devices again.
+func NewSampler(src Source, maxAge time.Duration) *Sampler {
+	return &Sampler{
+		src:    src,
+		maxAge: maxAge,
+		now:    time.Now,
+	}
+}
+
+// Filter restricts the Sampler to devices for which fn returns true. Filter
+// must be called before Sample.
+func (s *Sampler) Filter(fn func(name string) bool) {
+	s.filter = fn
+}
+
+// Sample returns a Snapshot of the statistics of each device. The Snapshot
+// is shared between callers and must not be modified.
+func (s *Sampler) Sample() (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.last != nil && now.Sub(s.last.Time) < s.maxAge {
+		return s.last, nil
+	}
+
+	devices, err := s.src.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{Time: now}
+	for _, d := range devices {
+		if s.filter != nil && !s.filter(d.Name) {
+			continue
+		}
+
+		snap.Devices = append(snap.Devices, deviceStats(d))
+	}
+
+	s.last = snap
+	return snap, nil
+}
+
+// deviceStats produces the statistics of d.
+func deviceStats(d *wgtypes.Device) DeviceStats {
+	ds := DeviceStats{
+		Name:       d.Name,
+		Type:       d.Type,
+		PublicKey:  d.PublicKey,
+		ListenPort: d.ListenPort,
+		Peers:      make([]PeerStats, 0, len(d.Peers)),
+	}
+
+	for _, p := range d.Peers {
+		ps := PeerStats{
+			PublicKey:         p.PublicKey,
+			LastHandshakeTime: p.LastHandshakeTime,
+			ReceiveBytes:      p.ReceiveBytes,
+			TransmitBytes:     p.TransmitBytes,
+			AllowedIPs:        len(p.AllowedIPs),
+		}
+
+		if p.Endpoint != nil {
+			ps.Endpoint = p.Endpoint.String()
+		}
+
+		ds.Peers = append(ds.Peers, ps)
+	}
+
+	return ds
+}
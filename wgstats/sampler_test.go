@@ -0,0 +1,61 @@
+package wgstats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// sourceFunc adapts a function to the Source interface.
+type sourceFunc func() ([]*wgtypes.Device, error)
+
+func (fn sourceFunc) Devices() ([]*wgtypes.Device, error) { return fn() }
+
+func TestSamplerCache(t *testing.T) {
+	var calls int
+	s := NewSampler(sourceFunc(func() ([]*wgtypes.Device, error) {
+		calls++
+		return []*wgtypes.Device{{Name: "wg0"}, {Name: "wg1"}}, nil
+	}), 10*time.Second)
+	s.Filter(func(name string) bool { return name == "wg1" })
+
+	now := time.Unix(1, 0)
+	s.now = func() time.Time { return now }
+
+	for i, d := range []time.Duration{0, 5 * time.Second, 10 * time.Second} {
+		now = now.Add(d)
+
+		snap, err := s.Sample()
+		if err != nil {
+			t.Fatalf("failed to sample: %v", err)
+		}
+
+		if diff := cmp.Diff(1, len(snap.Devices)); diff != "" {
+			t.Fatalf("unexpected number of devices (-want +got):\n%s", diff)
+		}
+		if diff := cmp.Diff("wg1", snap.Devices[0].Name); diff != "" {
+			t.Fatalf("unexpected device (-want +got):\n%s", diff)
+		}
+
+		// The second sample reuses the first, and the third retrieves the
+		// devices again.
+		want := []int{1, 1, 2}[i]
+		if diff := cmp.Diff(want, calls); diff != "" {
+			t.Fatalf("unexpected number of calls on sample %d (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestSamplerError(t *testing.T) {
+	errFail := errors.New("failed")
+	s := NewSampler(sourceFunc(func() ([]*wgtypes.Device, error) {
+		return nil, errFail
+	}), time.Minute)
+
+	if _, err := s.Sample(); !errors.Is(err, errFail) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,262 @@
+package wgstats
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// DefaultSLABuckets are the upper bounds, in seconds, of the histogram
+// buckets used when an SLA specifies none. WireGuard rekeys a session every
+// two minutes while it is in use, and discards it after three.
+var DefaultSLABuckets = []float64{5, 15, 30, 60, 90, 120, 150, 180, 300, 600}
+
+// An SLA is a handshake freshness objective for the peers of each device.
+type SLA struct {
+	// MaxHandshakeAge is the maximum age of a peer's last handshake. Peers
+	// whose handshakes are older, or which have never completed one, violate
+	// the SLA. If zero, peers are never flagged.
+	MaxHandshakeAge time.Duration
+
+	// Buckets are the upper bounds, in seconds, of the histogram buckets. If
+	// nil, DefaultSLABuckets is used.
+	Buckets []float64
+}
+
+// An SLAViolation identifies a peer which violates an SLA.
+type SLAViolation struct {
+	Device    string
+	PublicKey wgtypes.Key
+
+	// HandshakeAge is the age of the peer's last handshake, or -1 if no
+	// handshake has occurred.
+	HandshakeAge time.Duration
+}
+
+// A Histogram is a cumulative histogram in the style of Prometheus.
+type Histogram struct {
+	// Buckets are the upper bounds of each bucket, and Counts are the
+	// number of observations less than or equal to each bound.
+	Buckets []float64
+	Counts  []uint64
+
+	Count uint64
+	Sum   float64
+}
+
+// observe adds v to the histogram.
+func (h *Histogram) observe(v float64) {
+	for i, b := range h.Buckets {
+		if v <= b {
+			h.Counts[i]++
+		}
+	}
+
+	h.Count++
+	h.Sum += v
+}
+
+// An SLAMonitor observes Snapshots and maintains histograms of the handshake
+// ages and rekey intervals of the peers of each device, and flags peers which
+// violate an SLA, so that degraded paths are detected before users notice.
+// Its methods are safe for concurrent use.
+type SLAMonitor struct {
+	sla SLA
+
+	mu         sync.Mutex
+	last       time.Time
+	ages       map[string]*Histogram
+	rekeys     map[string]*Histogram
+	handshakes map[string]map[wgtypes.Key]time.Time
+	violations []SLAViolation
+}
+
+// NewSLAMonitor creates an SLAMonitor for sla.
+func NewSLAMonitor(sla SLA) *SLAMonitor {
+	if sla.Buckets == nil {
+		sla.Buckets = DefaultSLABuckets
+	}
+
+	return &SLAMonitor{
+		sla:        sla,
+		ages:       make(map[string]*Histogram),
+		rekeys:     make(map[string]*Histogram),
+		handshakes: make(map[string]map[wgtypes.Key]time.Time),
+	}
+}
+
+// Observe records the handshake age of each peer in snap which has completed
+// a handshake, the interval since each peer's previous handshake if it has
+// completed a new one, and the peers which violate the SLA. A Snapshot which
+// is not newer than the previous one, such as one cached by a Sampler, is
+// ignored.
+func (m *SLAMonitor) Observe(snap *Snapshot) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !snap.Time.After(m.last) {
+		return
+	}
+	m.last = snap.Time
+
+	m.violations = nil
+	for _, d := range snap.Devices {
+		ages := m.histogram(m.ages, d.Name)
+		rekeys := m.histogram(m.rekeys, d.Name)
+
+		prev := m.handshakes[d.Name]
+		cur := make(map[wgtypes.Key]time.Time, len(d.Peers))
+
+		for _, p := range d.Peers {
+			age := time.Duration(-1)
+			if !p.LastHandshakeTime.IsZero() {
+				age = snap.Time.Sub(p.LastHandshakeTime)
+				ages.observe(age.Seconds())
+				cur[p.PublicKey] = p.LastHandshakeTime
+
+				if before, ok := prev[p.PublicKey]; ok && p.LastHandshakeTime.After(before) {
+					rekeys.observe(p.LastHandshakeTime.Sub(before).Seconds())
+				}
+			}
+
+			if m.sla.MaxHandshakeAge > 0 && (age < 0 || age > m.sla.MaxHandshakeAge) {
+				m.violations = append(m.violations, SLAViolation{
+					Device:       d.Name,
+					PublicKey:    p.PublicKey,
+					HandshakeAge: age,
+				})
+			}
+		}
+
+		m.handshakes[d.Name] = cur
+	}
+}
+
+// Violations returns the peers which violated the SLA in the most recently
+// observed Snapshot.
+func (m *SLAMonitor) Violations() []SLAViolation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]SLAViolation(nil), m.violations...)
+}
+
+// HandshakeAges returns a copy of the histogram of handshake ages, in
+// seconds, of the peers of device.
+func (m *SLAMonitor) HandshakeAges(device string) Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.snapshot(m.ages, device)
+}
+
+// RekeyIntervals returns a copy of the histogram of intervals, in seconds,
+// between successive handshakes of the peers of device.
+func (m *SLAMonitor) RekeyIntervals(device string) Histogram {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.snapshot(m.rekeys, device)
+}
+
+// WritePrometheus writes the histograms of each device and the peers which
+// violate the SLA to w in the Prometheus text exposition format.
+func (m *SLAMonitor) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	// histogram writes a histogram metric for each device.
+	histogram := func(name, help string, hs map[string]*Histogram) {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+
+		names := make([]string, 0, len(hs))
+		for n := range hs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		for _, n := range names {
+			h := hs[n]
+			for i, b := range h.Buckets {
+				fmt.Fprintf(bw, "%s_bucket{device=%s,le=%s} %d\n",
+					name, quote(n), quote(strconv.FormatFloat(b, 'g', -1, 64)), h.Counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket{device=%s,le=\"+Inf\"} %d\n", name, quote(n), h.Count)
+			fmt.Fprintf(bw, "%s_sum{device=%s} %s\n", name, quote(n), strconv.FormatFloat(h.Sum, 'g', -1, 64))
+			fmt.Fprintf(bw, "%s_count{device=%s} %d\n", name, quote(n), h.Count)
+		}
+	}
+
+	histogram("wireguard_peer_handshake_age_seconds", "Age of the last handshake of each peer when observed.", m.ages)
+	histogram("wireguard_peer_rekey_interval_seconds", "Interval between successive handshakes of a peer.", m.rekeys)
+
+	const name = "wireguard_peer_sla_violation"
+	fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", name,
+		"Set for each peer whose last handshake is older than the SLA allows.", name)
+	for _, v := range m.violations {
+		fmt.Fprintf(bw, "%s{device=%s,public_key=%s} 1\n", name, quote(v.Device), quote(v.PublicKey.String()))
+	}
+
+	return bw.Flush()
+}
+
+// histogram returns the histogram for device in hs, creating it if
+// necessary.
+func (m *SLAMonitor) histogram(hs map[string]*Histogram, device string) *Histogram {
+	h, ok := hs[device]
+	if !ok {
+		h = &Histogram{
+			Buckets: m.sla.Buckets,
+			Counts:  make([]uint64, len(m.sla.Buckets)),
+		}
+		hs[device] = h
+	}
+
+	return h
+}
+
+// snapshot returns a deep copy of the histogram for device in hs, which is
+// empty if the device has not been observed.
+func (m *SLAMonitor) snapshot(hs map[string]*Histogram, device string) Histogram {
+	h, ok := hs[device]
+	if !ok {
+		return Histogram{
+			Buckets: append([]float64(nil), m.sla.Buckets...),
+			Counts:  make([]uint64, len(m.sla.Buckets)),
+		}
+	}
+
+	c := *h
+	c.Buckets = append([]float64(nil), h.Buckets...)
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return c
+}
+
+// SLAHandler returns an http.Handler which, on each request, observes a
+// Snapshot from s using m, and serves the statistics of the Snapshot followed
+// by the metrics of m in the Prometheus text exposition format, as with
+// Handler.
+func SLAHandler(s *Sampler, m *SLAMonitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snap, err := s.Sample()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("wgstats: failed to sample devices: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		m.Observe(snap)
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_ = WritePrometheus(w, snap)
+		_ = m.WritePrometheus(w)
+	})
+}
@@ -0,0 +1,123 @@
+package wgstats_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgstats"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSLAMonitor(t *testing.T) {
+	var (
+		a   = wgtest.MustPublicKey()
+		b   = wgtest.MustPublicKey()
+		c   = wgtest.MustPublicKey()
+		now = time.Unix(1600000000, 0)
+	)
+
+	m := wgstats.NewSLAMonitor(wgstats.SLA{
+		MaxHandshakeAge: 3 * time.Minute,
+		Buckets:         []float64{60, 180},
+	})
+
+	snap := func(at time.Time, aHS, bHS time.Time) *wgstats.Snapshot {
+		return &wgstats.Snapshot{
+			Time: at,
+			Devices: []wgstats.DeviceStats{{
+				Name: "wg0",
+				Peers: []wgstats.PeerStats{
+					{PublicKey: a, LastHandshakeTime: aHS},
+					{PublicKey: b, LastHandshakeTime: bHS},
+					{PublicKey: c},
+				},
+			}},
+		}
+	}
+
+	first := snap(now, now.Add(-30*time.Second), now.Add(-5*time.Minute))
+	m.Observe(first)
+
+	// A repeated Snapshot, as cached by a Sampler, is ignored.
+	m.Observe(first)
+
+	// a rekeys after two minutes, while b remains stale.
+	m.Observe(snap(now.Add(2*time.Minute), now.Add(90*time.Second), now.Add(-5*time.Minute)))
+
+	want := []wgstats.SLAViolation{
+		{Device: "wg0", PublicKey: b, HandshakeAge: 7 * time.Minute},
+		{Device: "wg0", PublicKey: c, HandshakeAge: -1},
+	}
+
+	if diff := cmp.Diff(want, m.Violations()); diff != "" {
+		t.Fatalf("unexpected violations (-want +got):\n%s", diff)
+	}
+
+	wantAges := wgstats.Histogram{
+		Buckets: []float64{60, 180},
+		Counts:  []uint64{2, 2},
+		Count:   4,
+		Sum:     30 + 300 + 30 + 420,
+	}
+
+	if diff := cmp.Diff(wantAges, m.HandshakeAges("wg0")); diff != "" {
+		t.Fatalf("unexpected handshake ages (-want +got):\n%s", diff)
+	}
+
+	wantRekeys := wgstats.Histogram{
+		Buckets: []float64{60, 180},
+		Counts:  []uint64{0, 1},
+		Count:   1,
+		Sum:     120,
+	}
+
+	if diff := cmp.Diff(wantRekeys, m.RekeyIntervals("wg0")); diff != "" {
+		t.Fatalf("unexpected rekey intervals (-want +got):\n%s", diff)
+	}
+}
+
+func TestSLAHandler(t *testing.T) {
+	pub := wgtest.MustHexKey("e84b5a6d2717c1003a13b431570353dbaca9146cf150c5f8575680feba52027a")
+
+	devices := testSource{{
+		Name:  "wg0",
+		Peers: []wgtypes.Peer{{PublicKey: pub}},
+	}}
+
+	m := wgstats.NewSLAMonitor(wgstats.SLA{
+		MaxHandshakeAge: time.Minute,
+		Buckets:         []float64{60},
+	})
+
+	srv := httptest.NewServer(wgstats.SLAHandler(wgstats.NewSampler(devices, 0), m))
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("failed to get metrics: %v", err)
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+
+	for _, want := range []string{
+		`wireguard_device_peers{device="wg0"} 1`,
+		`wireguard_peer_handshake_age_seconds_bucket{device="wg0",le="60"} 0`,
+		`wireguard_peer_handshake_age_seconds_bucket{device="wg0",le="+Inf"} 0`,
+		`wireguard_peer_rekey_interval_seconds_count{device="wg0"} 0`,
+		`wireguard_peer_sla_violation{device="wg0",public_key="6EtabScXwQA6E7QxVwNT26ypFGzxUMX4V1aA/rpSAno="} 1`,
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Fatalf("metrics do not contain %q:\n%s", want, b)
+		}
+	}
+}
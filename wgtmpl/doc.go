@@ -0,0 +1,22 @@
+// Package wgtmpl renders parameterized WireGuard configuration templates into
+// device configurations, for provisioning systems which manage many similar
+// devices.
+//
+// A Template is a text/template which produces a configuration file in the
+// format used by wg(8) setconf and parsed by wgconf. In addition to the
+// standard functions, templates may use functions which derive public keys
+// (pubkey), validate keys (key), compute addresses within a prefix (cidrhost
+// and cidrsubnet), and allocate listening ports (port). Each value written by
+// an action must fit on one line, so that data cannot inject additional keys
+// or sections into the rendered file. The rendered configuration is parsed
+// and validated before it is returned.
+//
+//	[Interface]
+//	PrivateKey = {{key .PrivateKey}}
+//	ListenPort = {{port 51820 .Index}}
+//	{{range .Peers}}
+//	[Peer]
+//	PublicKey = {{pubkey .PrivateKey}}
+//	AllowedIPs = {{cidrhost "10.0.0.0/24" .Index}}/32
+//	{{end}}
+package wgtmpl // import "golang.zx2c4.com/wireguard/wgctrl/wgtmpl"
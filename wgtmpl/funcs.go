@@ -0,0 +1,135 @@
+package wgtmpl
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"text/template"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// FuncMap returns the functions available to a Template, so that they may
+// also be used in other templates:
+//
+//   - key k: parses the key k, which may be a wgtypes.Key or a base64-encoded
+//     string, and returns its canonical base64 encoding.
+//   - pubkey k: returns the public key of the private key k, which may be a
+//     wgtypes.Key or a base64-encoded string.
+//   - cidrhost prefix n: returns the address of host number n within the
+//     prefix, counting from the end of the prefix if n is negative.
+//   - cidrsubnet prefix newbits n: returns subnet number n of the prefix,
+//     with its length extended by newbits.
+//   - port base n: returns base+n, which must be a valid UDP port.
+//   - add a b: returns a+b, for computing host and port numbers.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"add":        func(a, b int) int { return a + b },
+		"key":        keyFunc,
+		"pubkey":     pubkeyFunc,
+		"cidrhost":   cidrhost,
+		"cidrsubnet": cidrsubnet,
+		"port":       port,
+	}
+}
+
+// parseKey parses v as a Key.
+func parseKey(v any) (wgtypes.Key, error) {
+	switch v := v.(type) {
+	case wgtypes.Key:
+		return v, nil
+	case *wgtypes.Key:
+		if v == nil {
+			return wgtypes.Key{}, errors.New("key is nil")
+		}
+		return *v, nil
+	case string:
+		return wgtypes.ParseKey(v)
+	default:
+		return wgtypes.Key{}, fmt.Errorf("cannot use %T as a key", v)
+	}
+}
+
+// keyFunc implements the key function.
+func keyFunc(v any) (string, error) {
+	k, err := parseKey(v)
+	if err != nil {
+		return "", err
+	}
+
+	return k.String(), nil
+}
+
+// pubkeyFunc implements the pubkey function.
+func pubkeyFunc(v any) (string, error) {
+	k, err := parseKey(v)
+	if err != nil {
+		return "", err
+	}
+
+	return k.PublicKey().String(), nil
+}
+
+// cidrhost implements the cidrhost function.
+func cidrhost(prefix string, n int) (string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", err
+	}
+	p = p.Masked()
+
+	size := new(big.Int).Lsh(big.NewInt(1), uint(p.Addr().BitLen()-p.Bits()))
+	num := big.NewInt(int64(n))
+	if n < 0 {
+		num.Add(num, size)
+	}
+	if num.Sign() < 0 || num.Cmp(size) >= 0 {
+		return "", fmt.Errorf("prefix %s has no host number %d", p, n)
+	}
+
+	return addAddr(p.Addr(), num).String(), nil
+}
+
+// cidrsubnet implements the cidrsubnet function.
+func cidrsubnet(prefix string, newbits, n int) (string, error) {
+	p, err := netip.ParsePrefix(prefix)
+	if err != nil {
+		return "", err
+	}
+	p = p.Masked()
+
+	bits := p.Bits() + newbits
+	if newbits < 0 || bits > p.Addr().BitLen() {
+		return "", fmt.Errorf("cannot extend prefix %s by %d bits", p, newbits)
+	}
+
+	count := new(big.Int).Lsh(big.NewInt(1), uint(newbits))
+	num := big.NewInt(int64(n))
+	if num.Sign() < 0 || num.Cmp(count) >= 0 {
+		return "", fmt.Errorf("prefix %s has no subnet number %d of length /%d", p, n, bits)
+	}
+
+	num.Lsh(num, uint(p.Addr().BitLen()-bits))
+	return netip.PrefixFrom(addAddr(p.Addr(), num), bits).String(), nil
+}
+
+// addAddr returns addr plus n, which must not overflow the address.
+func addAddr(addr netip.Addr, n *big.Int) netip.Addr {
+	b := addr.AsSlice()
+	v := new(big.Int).SetBytes(b)
+	v.Add(v, n).FillBytes(b)
+
+	out, _ := netip.AddrFromSlice(b)
+	return out
+}
+
+// port implements the port function.
+func port(base, n int) (int, error) {
+	p := base + n
+	if p < 1 || p > 65535 {
+		return 0, fmt.Errorf("port %d+%d is out of range", base, n)
+	}
+
+	return p, nil
+}
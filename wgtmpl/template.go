@@ -0,0 +1,170 @@
+package wgtmpl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgconf"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// escapeFunc is the name of the function appended to each action which
+// writes a value, to check that the value fits on one line.
+const escapeFunc = "_wgtmplValue"
+
+// A Template is a parsed configuration template.
+type Template struct {
+	t *template.Template
+}
+
+// Parse parses text as a Template named name.
+func Parse(name, text string) (*Template, error) {
+	t, err := template.New(name).
+		Funcs(FuncMap()).
+		Funcs(template.FuncMap{escapeFunc: checkValue}).
+		Option("missingkey=error").
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("wgtmpl: %v", err)
+	}
+
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			escapeNode(tt.Tree.Root)
+		}
+	}
+
+	return &Template{t: t}, nil
+}
+
+// Execute renders the Template using data, writing the configuration file to
+// w without parsing or validating it.
+func (t *Template) Execute(w io.Writer, data any) error {
+	if err := t.t.Execute(w, data); err != nil {
+		return fmt.Errorf("wgtmpl: %v", err)
+	}
+
+	return nil
+}
+
+// File renders the Template using data and parses the result.
+func (t *Template) File(data any) (*wgconf.File, error) {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+
+	f, err := wgconf.Parse(&b)
+	if err != nil {
+		return nil, fmt.Errorf("wgtmpl: %s: rendered configuration is invalid: %v", t.t.Name(), err)
+	}
+
+	return f, nil
+}
+
+// Config renders the Template using data and produces a Config which fully
+// configures a device, as with wgconf.File.Config. The Config is validated:
+// ports, firewall marks, and keepalive intervals must be in range, each peer
+// must have a unique, non-zero public key, and no allowed IP may be assigned
+// to more than one peer.
+func (t *Template) Config(data any) (wgtypes.Config, error) {
+	f, err := t.File(data)
+	if err != nil {
+		return wgtypes.Config{}, err
+	}
+
+	cfg, err := f.Config()
+	if err != nil {
+		return wgtypes.Config{}, err
+	}
+
+	if err := validate(cfg); err != nil {
+		return wgtypes.Config{}, fmt.Errorf("wgtmpl: %s: %v", t.t.Name(), err)
+	}
+
+	return cfg, nil
+}
+
+// escapeNode appends escapeFunc to each action within n which writes a value.
+func escapeNode(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			escapeNode(c)
+		}
+	case *parse.ActionNode:
+		// Declarations and assignments write nothing.
+		if len(n.Pipe.Decl) > 0 {
+			return
+		}
+
+		n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+			NodeType: parse.NodeCommand,
+			Pos:      n.Pos,
+			Args:     []parse.Node{parse.NewIdentifier(escapeFunc).SetPos(n.Pos)},
+		})
+	case *parse.IfNode:
+		escapeNode(n.List)
+		escapeNode(n.ElseList)
+	case *parse.RangeNode:
+		escapeNode(n.List)
+		escapeNode(n.ElseList)
+	case *parse.WithNode:
+		escapeNode(n.List)
+		escapeNode(n.ElseList)
+	}
+}
+
+// checkValue formats v as an action would, returning an error if the result
+// does not fit on one line.
+func checkValue(v any) (string, error) {
+	s := fmt.Sprint(v)
+	if strings.ContainsAny(s, "\r\n\x00") {
+		// The value is not included, since it may contain a secret key.
+		return "", errors.New("value must not contain line breaks")
+	}
+
+	return s, nil
+}
+
+// validate checks cfg for values which cannot be applied to a device, using
+// wgctrl.ValidateConfig, or which are likely mistakes in a template.
+func validate(cfg wgtypes.Config) error {
+	if err := wgctrl.ValidateConfig(cfg); err != nil {
+		return err
+	}
+
+	peers := make(map[wgtypes.Key]bool, len(cfg.Peers))
+	owners := make(map[string]wgtypes.Key)
+	for _, p := range cfg.Peers {
+		if peers[p.PublicKey] {
+			return fmt.Errorf("duplicate peer %s", p.PublicKey)
+		}
+		peers[p.PublicKey] = true
+
+		for _, ipn := range p.AllowedIPs {
+			s := canonicalIPNet(ipn)
+			if k, ok := owners[s]; ok && k != p.PublicKey {
+				return fmt.Errorf("allowed IP %s is assigned to peers %s and %s", s, k, p.PublicKey)
+			}
+			owners[s] = p.PublicKey
+		}
+	}
+
+	return nil
+}
+
+// canonicalIPNet formats ipn with its address masked.
+func canonicalIPNet(ipn net.IPNet) string {
+	return (&net.IPNet{IP: ipn.IP.Mask(ipn.Mask), Mask: ipn.Mask}).String()
+}
@@ -0,0 +1,164 @@
+package wgtmpl_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtmpl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+const hub = `[Interface]
+PrivateKey = {{key .PrivateKey}}
+ListenPort = {{port 51820 .Index}}
+{{range $i, $p := .Peers}}
+[Peer]
+# Name = {{$p.Name}}
+PublicKey = {{pubkey $p.PrivateKey}}
+PersistentKeepalive = 25
+AllowedIPs = {{cidrhost "10.0.0.0/24" (add $i 2)}}/32, {{cidrsubnet "fd00::/48" 16 $i}}
+{{end}}`
+
+type peer struct {
+	Name       string
+	PrivateKey wgtypes.Key
+}
+
+func TestTemplateConfig(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		a    = wgtest.MustPrivateKey()
+		b    = wgtest.MustPrivateKey()
+	)
+
+	tmpl, err := wgtmpl.Parse("hub", hub)
+	if err != nil {
+		t.Fatalf("failed to parse template: %v", err)
+	}
+
+	cfg, err := tmpl.Config(map[string]any{
+		"PrivateKey": priv.String(),
+		"Index":      2,
+		"Peers":      []peer{{Name: "a", PrivateKey: a}, {Name: "b", PrivateKey: b}},
+	})
+	if err != nil {
+		t.Fatalf("failed to render config: %v", err)
+	}
+
+	var (
+		port = 51822
+		ka   = 25 * time.Second
+	)
+
+	want := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:                   a.PublicKey(),
+				Name:                        "a",
+				PersistentKeepaliveInterval: &ka,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.2/32"),
+					wgtest.MustCIDR("fd00::/64"),
+				},
+			},
+			{
+				PublicKey:                   b.PublicKey(),
+				Name:                        "b",
+				PersistentKeepaliveInterval: &ka,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.3/32"),
+					wgtest.MustCIDR("fd00:0:0:1::/64"),
+				},
+			},
+		},
+	}
+
+	if diff := cmp.Diff(want, cfg); diff != "" {
+		t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+	)
+
+	tests := []struct {
+		name string
+		text string
+		data any
+	}{
+		{
+			name: "injected section",
+			text: "[Interface]\nListenPort = {{.}}\n",
+			data: "51820\n[Peer]\nPublicKey = " + pub.String(),
+		},
+		{
+			name: "injected in range",
+			text: "{{range .}}[Peer]\nPublicKey = {{.}}\n{{end}}",
+			data: []string{pub.String() + "\nAllowedIPs = 0.0.0.0/0"},
+		},
+		{
+			name: "injected in sub-template",
+			text: `{{define "k"}}{{.}}{{end}}[Interface]` + "\nPrivateKey = {{template \"k\" .}}\n",
+			data: priv.String() + "\nListenPort = 1",
+		},
+		{
+			name: "missing key",
+			text: "[Interface]\nPrivateKey = {{key .PrivateKey}}\n",
+			data: map[string]any{},
+		},
+		{
+			name: "bad key",
+			text: "[Interface]\nPrivateKey = {{key .}}\n",
+			data: "foo",
+		},
+		{
+			name: "host out of range",
+			text: `[Interface]` + "\n# {{cidrhost \"10.0.0.0/30\" 4}}\n",
+		},
+		{
+			name: "subnet out of range",
+			text: `[Interface]` + "\n# {{cidrsubnet \"10.0.0.0/24\" 2 4}}\n",
+		},
+		{
+			name: "port out of range",
+			text: "[Interface]\nListenPort = {{port 65535 1}}\n",
+		},
+		{
+			name: "duplicate allowed IP",
+			text: "{{range .}}[Peer]\nPublicKey = {{pubkey .}}\nAllowedIPs = 10.0.0.0/24\n{{end}}",
+			data: []wgtypes.Key{priv, wgtest.MustPrivateKey()},
+		},
+		{
+			name: "duplicate peer",
+			text: "{{range .}}[Peer]\nPublicKey = {{.}}\n{{end}}",
+			data: []wgtypes.Key{pub, pub},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := wgtmpl.Parse(tt.name, tt.text)
+			if err != nil {
+				t.Fatalf("failed to parse template: %v", err)
+			}
+
+			_, err = tmpl.Config(tt.data)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
@@ -2,9 +2,36 @@ package wgtypes
 
 import (
 	"errors"
+	"fmt"
 )
 
 // ErrUpdateOnlyNotSupported is returned due to missing kernel support of
 // the PeerConfig UpdateOnly flag.
 var ErrUpdateOnlyNotSupported = errors.New("the UpdateOnly flag is not supported by this platform")
 
+// A UserspaceError is returned when a userspace WireGuard device reports a
+// non-zero error number in response to a configuration protocol operation.
+//
+// Err is mapped to the same set of errors returned by the kernel backends, so
+// that errors.Is(err, os.ErrNotExist) and errors.Is(err, os.ErrPermission)
+// behave identically regardless of the type of device.
+type UserspaceError struct {
+	// Op is the configuration protocol operation which failed: "get" or
+	// "set".
+	Op string
+
+	// Errno is the raw error number reported by the device. Implementations
+	// disagree on its sign, so it is always normalized to a positive value.
+	Errno int
+
+	// Err is the system error equivalent to Errno.
+	Err error
+}
+
+// Error implements error.
+func (e *UserspaceError) Error() string {
+	return fmt.Sprintf("wguser: %s: errno=%d: %v", e.Op, e.Errno, e.Err)
+}
+
+// Unwrap implements errors unwrapping.
+func (e *UserspaceError) Unwrap() error { return e.Err }
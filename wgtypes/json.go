@@ -0,0 +1,281 @@
+package wgtypes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// ConfigJSONSchema is a JSON Schema which describes the JSON representation
+// of a Config, as produced by Config.MarshalJSON.
+const ConfigJSONSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "WireGuard device configuration",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "privateKey": {"$ref": "#/$defs/key"},
+    "listenPort": {"type": "integer", "minimum": 0, "maximum": 65535},
+    "firewallMark": {"type": "integer", "minimum": 0, "maximum": 4294967295},
+    "replacePeers": {"type": "boolean"},
+    "peers": {"type": "array", "items": {"$ref": "#/$defs/peer"}}
+  },
+  "$defs": {
+    "key": {
+      "description": "A base64-encoded 32 byte key.",
+      "type": "string",
+      "pattern": "^[A-Za-z0-9+/]{42}[AEIMQUYcgkosw048]=$"
+    },
+    "peer": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["publicKey"],
+      "properties": {
+        "publicKey": {"$ref": "#/$defs/key"},
+        "name": {"type": "string"},
+        "remove": {"type": "boolean"},
+        "updateOnly": {"type": "boolean"},
+        "presharedKey": {"$ref": "#/$defs/key"},
+        "endpoint": {
+          "description": "An IP address and port, such as \"192.0.2.1:51820\" or \"[2001:db8::1]:51820\".",
+          "type": "string"
+        },
+        "persistentKeepaliveInterval": {
+          "description": "A duration in whole seconds, such as \"25s\".",
+          "type": "string"
+        },
+        "replaceAllowedIPs": {"type": "boolean"},
+        "allowedIPs": {
+          "type": "array",
+          "items": {
+            "description": "An IP address prefix in CIDR notation, such as \"10.0.0.0/24\".",
+            "type": "string"
+          }
+        }
+      }
+    }
+  }
+}`
+
+// configJSON is the JSON representation of a Config.
+type configJSON struct {
+	PrivateKey   *string          `json:"privateKey,omitempty"`
+	ListenPort   *int             `json:"listenPort,omitempty"`
+	FirewallMark *int64           `json:"firewallMark,omitempty"`
+	ReplacePeers bool             `json:"replacePeers,omitempty"`
+	Peers        []peerConfigJSON `json:"peers,omitempty"`
+}
+
+// peerConfigJSON is the JSON representation of a PeerConfig.
+type peerConfigJSON struct {
+	PublicKey                   string   `json:"publicKey"`
+	Name                        string   `json:"name,omitempty"`
+	Remove                      bool     `json:"remove,omitempty"`
+	UpdateOnly                  bool     `json:"updateOnly,omitempty"`
+	PresharedKey                *string  `json:"presharedKey,omitempty"`
+	Endpoint                    *string  `json:"endpoint,omitempty"`
+	PersistentKeepaliveInterval *string  `json:"persistentKeepaliveInterval,omitempty"`
+	ReplaceAllowedIPs           bool     `json:"replaceAllowedIPs,omitempty"`
+	AllowedIPs                  []string `json:"allowedIPs,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, producing the JSON representation of
+// a Config described by ConfigJSONSchema. Keys are base64-encoded, endpoints
+// and allowed IPs are strings, and durations are strings such as "25s".
+// Fields which are nil are omitted. Persistent keepalive intervals which are
+// not a whole number of seconds are rejected, so that the result can always
+// be decoded by UnmarshalJSON.
+func (c Config) MarshalJSON() ([]byte, error) {
+	cj := configJSON{
+		PrivateKey:   keyString(c.PrivateKey),
+		ListenPort:   c.ListenPort,
+		ReplacePeers: c.ReplacePeers,
+	}
+
+	if c.FirewallMark != nil {
+		fwmark := int64(*c.FirewallMark)
+		cj.FirewallMark = &fwmark
+	}
+
+	for _, p := range c.Peers {
+		pj := peerConfigJSON{
+			PublicKey:         p.PublicKey.String(),
+			Name:              p.Name,
+			Remove:            p.Remove,
+			UpdateOnly:        p.UpdateOnly,
+			PresharedKey:      keyString(p.PresharedKey),
+			ReplaceAllowedIPs: p.ReplaceAllowedIPs,
+		}
+
+		if p.Endpoint != nil {
+			if p.Endpoint.IP == nil {
+				return nil, fmt.Errorf("wgtypes: peer %s: endpoint has no IP address", p.PublicKey)
+			}
+
+			s := p.Endpoint.String()
+			pj.Endpoint = &s
+		}
+
+		if d := p.PersistentKeepaliveInterval; d != nil {
+			if !validKeepalive(*d) {
+				return nil, fmt.Errorf("wgtypes: peer %s: invalid persistent keepalive interval: %s", p.PublicKey, *d)
+			}
+
+			s := d.String()
+			pj.PersistentKeepaliveInterval = &s
+		}
+
+		for _, ipn := range p.AllowedIPs {
+			pj.AllowedIPs = append(pj.AllowedIPs, ipn.String())
+		}
+
+		cj.Peers = append(cj.Peers, pj)
+	}
+
+	return json.Marshal(cj)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, strictly decoding the JSON
+// representation of a Config described by ConfigJSONSchema. Unknown fields,
+// values of the wrong type, and invalid keys, ports, firewall marks,
+// endpoints, durations, and allowed IPs are rejected.
+func (c *Config) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	var cj configJSON
+	if err := dec.Decode(&cj); err != nil {
+		return fmt.Errorf("wgtypes: invalid configuration JSON: %v", err)
+	}
+
+	cfg := Config{
+		ListenPort:   cj.ListenPort,
+		ReplacePeers: cj.ReplacePeers,
+	}
+
+	var err error
+	if cfg.PrivateKey, err = parseKeyString(cj.PrivateKey); err != nil {
+		return fmt.Errorf("wgtypes: invalid private key: %v", err)
+	}
+
+	if p := cj.ListenPort; p != nil && (*p < 0 || *p > 65535) {
+		return fmt.Errorf("wgtypes: invalid listen port: %d", *p)
+	}
+
+	if m := cj.FirewallMark; m != nil {
+		if *m < 0 || *m > 0xffffffff {
+			return fmt.Errorf("wgtypes: invalid firewall mark: %d", *m)
+		}
+
+		fwmark := int(*m)
+		cfg.FirewallMark = &fwmark
+	}
+
+	for i, pj := range cj.Peers {
+		p, err := pj.peerConfig()
+		if err != nil {
+			return fmt.Errorf("wgtypes: peer %d: %v", i, err)
+		}
+
+		cfg.Peers = append(cfg.Peers, p)
+	}
+
+	*c = cfg
+	return nil
+}
+
+// peerConfig validates and converts pj to a PeerConfig.
+func (pj *peerConfigJSON) peerConfig() (PeerConfig, error) {
+	if pj.PublicKey == "" {
+		return PeerConfig{}, fmt.Errorf("missing public key")
+	}
+
+	pub, err := ParseKeyStrict(pj.PublicKey)
+	if err != nil {
+		return PeerConfig{}, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	p := PeerConfig{
+		PublicKey:         pub,
+		Name:              pj.Name,
+		Remove:            pj.Remove,
+		UpdateOnly:        pj.UpdateOnly,
+		ReplaceAllowedIPs: pj.ReplaceAllowedIPs,
+	}
+
+	if p.PresharedKey, err = parseKeyString(pj.PresharedKey); err != nil {
+		return PeerConfig{}, fmt.Errorf("invalid preshared key: %v", err)
+	}
+
+	if pj.Endpoint != nil {
+		ap, err := netip.ParseAddrPort(*pj.Endpoint)
+		if err != nil {
+			return PeerConfig{}, fmt.Errorf("invalid endpoint: %v", err)
+		}
+
+		p.Endpoint = net.UDPAddrFromAddrPort(ap)
+	}
+
+	if pj.PersistentKeepaliveInterval != nil {
+		d, err := time.ParseDuration(*pj.PersistentKeepaliveInterval)
+		if err != nil {
+			return PeerConfig{}, fmt.Errorf("invalid persistent keepalive interval: %v", err)
+		}
+		if !validKeepalive(d) {
+			return PeerConfig{}, fmt.Errorf("invalid persistent keepalive interval: %s", d)
+		}
+
+		p.PersistentKeepaliveInterval = &d
+	}
+
+	for _, s := range pj.AllowedIPs {
+		ip, ipn, err := net.ParseCIDR(s)
+		if err != nil {
+			return PeerConfig{}, fmt.Errorf("invalid allowed IP: %v", err)
+		}
+
+		// Keep the address as written rather than masking it, so that a
+		// Config survives a round trip unchanged.
+		if len(ipn.Mask) == net.IPv4len {
+			ip = ip.To4()
+		}
+
+		p.AllowedIPs = append(p.AllowedIPs, net.IPNet{IP: ip, Mask: ipn.Mask})
+	}
+
+	return p, nil
+}
+
+// validKeepalive reports whether d can be represented as a persistent
+// keepalive interval, which is a whole number of seconds.
+func validKeepalive(d time.Duration) bool {
+	return d >= 0 && d <= 65535*time.Second && d%time.Second == 0
+}
+
+// keyString returns the base64 representation of k, or nil if k is nil.
+func keyString(k *Key) *string {
+	if k == nil {
+		return nil
+	}
+
+	s := k.String()
+	return &s
+}
+
+// parseKeyString parses a base64-encoded key from s, or returns nil if s is
+// nil.
+func parseKeyString(s *string) (*Key, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	k, err := ParseKeyStrict(*s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &k, nil
+}
@@ -0,0 +1,232 @@
+package wgtypes_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestConfigJSONRoundTrip(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+		psk  = wgtest.MustPresharedKey()
+		port = 51820
+		mark = 0x7fffffff
+		ka   = 25 * time.Second
+	)
+
+	cfg := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		FirewallMark: &mark,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:                   pub,
+				Name:                        "laptop",
+				PresharedKey:                &psk,
+				Endpoint:                    wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+				PersistentKeepaliveInterval: &ka,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.2/32"),
+					wgtest.MustCIDR("fd00::/64"),
+					// Addresses with host bits set are kept as written.
+					{IP: net.IPv4(192, 0, 2, 1).To4(), Mask: net.CIDRMask(24, 32)},
+					{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(64, 128)},
+				},
+			},
+			{
+				PublicKey: wgtest.MustPublicKey(),
+				Remove:    true,
+			},
+		},
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var got wgtypes.Config
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal %s: %v", b, err)
+	}
+
+	if diff := cmp.Diff(cfg, got); diff != "" {
+		t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigJSONMarshalInvalidKeepalive(t *testing.T) {
+	ka := 1500 * time.Millisecond
+	_, err := json.Marshal(wgtypes.Config{Peers: []wgtypes.PeerConfig{{
+		PublicKey:                   wgtest.MustPublicKey(),
+		PersistentKeepaliveInterval: &ka,
+	}}})
+	if err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+
+	t.Logf("OK error: %v", err)
+}
+
+func TestConfigJSONFormat(t *testing.T) {
+	pub := wgtest.MustPublicKey()
+	ka := 25 * time.Second
+
+	cfg := wgtypes.Config{
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   pub,
+			Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+			PersistentKeepaliveInterval: &ka,
+			AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+		}},
+	}
+
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	want := `{"peers":[{"publicKey":"` + pub.String() +
+		`","endpoint":"192.0.2.1:51820","persistentKeepaliveInterval":"25s","allowedIPs":["0.0.0.0/0"]}]}`
+
+	if diff := cmp.Diff(want, string(b)); diff != "" {
+		t.Fatalf("unexpected JSON (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigJSONStrict(t *testing.T) {
+	pub := wgtest.MustPublicKey().String()
+
+	tests := []struct {
+		name, in string
+	}{
+		{
+			name: "unknown field",
+			in:   `{"listenPort":1,"mtu":1420}`,
+		},
+		{
+			name: "unknown peer field",
+			in:   `{"peers":[{"publicKey":"` + pub + `","weight":1}]}`,
+		},
+		{
+			name: "wrong type",
+			in:   `{"listenPort":"51820"}`,
+		},
+		{
+			name: "bad private key",
+			in:   `{"privateKey":"foo"}`,
+		},
+		{
+			name: "bad listen port",
+			in:   `{"listenPort":65536}`,
+		},
+		{
+			name: "bad firewall mark",
+			in:   `{"firewallMark":-1}`,
+		},
+		{
+			name: "missing public key",
+			in:   `{"peers":[{"remove":true}]}`,
+		},
+		{
+			name: "hostname endpoint",
+			in:   `{"peers":[{"publicKey":"` + pub + `","endpoint":"vpn.example.com:51820"}]}`,
+		},
+		{
+			name: "bad keepalive",
+			in:   `{"peers":[{"publicKey":"` + pub + `","persistentKeepaliveInterval":"1500ms"}]}`,
+		},
+		{
+			name: "bad allowed IP",
+			in:   `{"peers":[{"publicKey":"` + pub + `","allowedIPs":["10.0.0.1"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg wgtypes.Config
+			err := json.Unmarshal([]byte(tt.in), &cfg)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func TestConfigJSONSchema(t *testing.T) {
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Defs       struct {
+			Peer struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"peer"`
+		} `json:"$defs"`
+	}
+
+	if err := json.Unmarshal([]byte(wgtypes.ConfigJSONSchema), &schema); err != nil {
+		t.Fatalf("failed to unmarshal schema: %v", err)
+	}
+
+	// Every field produced by a fully populated Config must be described by
+	// the schema.
+	var (
+		priv = wgtest.MustPrivateKey()
+		port = 1
+		mark = 1
+		ka   = time.Second
+	)
+
+	b, err := json.Marshal(wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		FirewallMark: &mark,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{{
+			PublicKey:                   priv.PublicKey(),
+			Name:                        "peer",
+			Remove:                      true,
+			UpdateOnly:                  true,
+			PresharedKey:                &priv,
+			Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:1"),
+			PersistentKeepaliveInterval: &ka,
+			ReplaceAllowedIPs:           true,
+			AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var out struct {
+		Config map[string]json.RawMessage
+		Peers  []map[string]json.RawMessage `json:"peers"`
+	}
+	if err := json.Unmarshal(b, &out.Config); err != nil {
+		t.Fatalf("failed to unmarshal config: %v", err)
+	}
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatalf("failed to unmarshal peers: %v", err)
+	}
+
+	for k := range out.Config {
+		if _, ok := schema.Properties[k]; !ok {
+			t.Errorf("schema does not describe field %q", k)
+		}
+	}
+	for k := range out.Peers[0] {
+		if _, ok := schema.Defs.Peer.Properties[k]; !ok {
+			t.Errorf("schema does not describe peer field %q", k)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+// Package pb encodes wgtypes values as canonical protocol buffers messages.
+//
+// The messages are defined in wgtypes.proto, which is distributed alongside
+// this package so that other projects can generate code for any language
+// rather than maintaining their own definitions. This package implements the
+// protocol buffers wire format directly and does not depend on a protocol
+// buffers runtime, so its output can be decoded by any code generated from
+// wgtypes.proto and vice versa.
+//
+// Conversions are lossless: every field of the wgtypes values is preserved,
+// including the distinction between nil and zero-value pointer fields in a
+// Config or PeerConfig. Timestamps are decoded in the local time zone.
+//
+// SnapshotEncoder and SnapshotDecoder implement a compact stream of device
+// snapshots for telemetry, in which only the peers which changed since the
+// previous snapshot are encoded.
+package pb // import "golang.zx2c4.com/wireguard/wgctrl/wgtypes/pb"
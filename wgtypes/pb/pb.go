@@ -0,0 +1,441 @@
+package pb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// MarshalDevice encodes d as a Device message.
+func MarshalDevice(d *wgtypes.Device) []byte {
+	var e encoder
+	encodeDevice(&e, d)
+	return e.b
+}
+
+// UnmarshalDevice decodes a Device message from b.
+func UnmarshalDevice(b []byte) (*wgtypes.Device, error) {
+	var dev wgtypes.Device
+	if err := decodeMessage(b, func(d *decoder) { decodeDevice(d, &dev) }); err != nil {
+		return nil, err
+	}
+
+	return &dev, nil
+}
+
+// MarshalPeer encodes p as a Peer message.
+func MarshalPeer(p wgtypes.Peer) []byte {
+	var e encoder
+	encodePeer(&e, p)
+	return e.b
+}
+
+// UnmarshalPeer decodes a Peer message from b.
+func UnmarshalPeer(b []byte) (wgtypes.Peer, error) {
+	var p wgtypes.Peer
+	if err := decodeMessage(b, func(d *decoder) { decodePeer(d, &p) }); err != nil {
+		return wgtypes.Peer{}, err
+	}
+
+	return p, nil
+}
+
+// MarshalConfig encodes cfg as a Config message.
+func MarshalConfig(cfg wgtypes.Config) []byte {
+	var e encoder
+	encodeConfig(&e, cfg)
+	return e.b
+}
+
+// UnmarshalConfig decodes a Config message from b.
+func UnmarshalConfig(b []byte) (wgtypes.Config, error) {
+	var cfg wgtypes.Config
+	if err := decodeMessage(b, func(d *decoder) { decodeConfig(d, &cfg) }); err != nil {
+		return wgtypes.Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// MarshalPeerConfig encodes cfg as a PeerConfig message.
+func MarshalPeerConfig(cfg wgtypes.PeerConfig) []byte {
+	var e encoder
+	encodePeerConfig(&e, cfg)
+	return e.b
+}
+
+// UnmarshalPeerConfig decodes a PeerConfig message from b.
+func UnmarshalPeerConfig(b []byte) (wgtypes.PeerConfig, error) {
+	var cfg wgtypes.PeerConfig
+	if err := decodeMessage(b, func(d *decoder) { decodePeerConfig(d, &cfg) }); err != nil {
+		return wgtypes.PeerConfig{}, err
+	}
+
+	return cfg, nil
+}
+
+// decodeMessage calls fn for each field of the message in b.
+func decodeMessage(b []byte, fn func(d *decoder)) error {
+	d := decoder{b: b}
+	for d.Next() {
+		fn(&d)
+	}
+
+	return d.Err()
+}
+
+func encodeDevice(e *encoder, d *wgtypes.Device) {
+	e.String(1, d.Name)
+	e.Int64(2, int64(d.Type))
+	encodeKey(e, 3, d.PrivateKey)
+	encodeKey(e, 4, d.PublicKey)
+	e.Int64(5, int64(d.ListenPort))
+	e.Int64(6, int64(d.FirewallMark))
+
+	for _, p := range d.Peers {
+		e.Message(7, func(e *encoder) { encodePeer(e, p) })
+	}
+}
+
+func decodeDevice(d *decoder, dev *wgtypes.Device) {
+	switch d.num {
+	case 1:
+		dev.Name = d.String()
+	case 2:
+		dev.Type = wgtypes.DeviceType(d.Int64())
+	case 3:
+		dev.PrivateKey = d.Key()
+	case 4:
+		dev.PublicKey = d.Key()
+	case 5:
+		dev.ListenPort = d.Int()
+	case 6:
+		dev.FirewallMark = d.Int()
+	case 7:
+		var p wgtypes.Peer
+		d.Message(func(d *decoder) { decodePeer(d, &p) })
+		dev.Peers = append(dev.Peers, p)
+	}
+}
+
+func encodePeer(e *encoder, p wgtypes.Peer) {
+	encodeKey(e, 1, p.PublicKey)
+	encodeKey(e, 2, p.PresharedKey)
+	encodeUDPAddr(e, 3, p.Endpoint)
+
+	if p.PersistentKeepaliveInterval != 0 {
+		encodeDuration(e, 4, p.PersistentKeepaliveInterval)
+	}
+	if !p.LastHandshakeTime.IsZero() {
+		encodeTimestamp(e, 5, p.LastHandshakeTime)
+	}
+
+	e.Int64(6, p.ReceiveBytes)
+	e.Int64(7, p.TransmitBytes)
+	encodeIPNets(e, 8, p.AllowedIPs)
+	e.Int64(9, int64(p.ProtocolVersion))
+}
+
+func decodePeer(d *decoder, p *wgtypes.Peer) {
+	switch d.num {
+	case 1:
+		p.PublicKey = d.Key()
+	case 2:
+		p.PresharedKey = d.Key()
+	case 3:
+		p.Endpoint = d.UDPAddr()
+	case 4:
+		p.PersistentKeepaliveInterval = d.Duration()
+	case 5:
+		p.LastHandshakeTime = d.Timestamp()
+	case 6:
+		p.ReceiveBytes = d.Int64()
+	case 7:
+		p.TransmitBytes = d.Int64()
+	case 8:
+		p.AllowedIPs = append(p.AllowedIPs, d.IPNet())
+	case 9:
+		p.ProtocolVersion = d.Int()
+	}
+}
+
+func encodeConfig(e *encoder, cfg wgtypes.Config) {
+	// Optional fields are always encoded when set, so that a zero value can
+	// be distinguished from an absent one.
+	if cfg.PrivateKey != nil {
+		e.Bytes(1, cfg.PrivateKey[:])
+	}
+	if cfg.ListenPort != nil {
+		e.Varint(2, uint64(*cfg.ListenPort))
+	}
+	if cfg.FirewallMark != nil {
+		e.Varint(3, uint64(*cfg.FirewallMark))
+	}
+
+	e.Bool(4, cfg.ReplacePeers)
+
+	for _, p := range cfg.Peers {
+		e.Message(5, func(e *encoder) { encodePeerConfig(e, p) })
+	}
+}
+
+func decodeConfig(d *decoder, cfg *wgtypes.Config) {
+	switch d.num {
+	case 1:
+		k := d.Key()
+		cfg.PrivateKey = &k
+	case 2:
+		v := d.Int()
+		cfg.ListenPort = &v
+	case 3:
+		v := d.Int()
+		cfg.FirewallMark = &v
+	case 4:
+		cfg.ReplacePeers = d.Bool()
+	case 5:
+		var p wgtypes.PeerConfig
+		d.Message(func(d *decoder) { decodePeerConfig(d, &p) })
+		cfg.Peers = append(cfg.Peers, p)
+	}
+}
+
+func encodePeerConfig(e *encoder, cfg wgtypes.PeerConfig) {
+	encodeKey(e, 1, cfg.PublicKey)
+	e.Bool(2, cfg.Remove)
+	e.Bool(3, cfg.UpdateOnly)
+
+	if cfg.PresharedKey != nil {
+		e.Bytes(4, cfg.PresharedKey[:])
+	}
+
+	encodeUDPAddr(e, 5, cfg.Endpoint)
+
+	if cfg.PersistentKeepaliveInterval != nil {
+		encodeDuration(e, 6, *cfg.PersistentKeepaliveInterval)
+	}
+
+	e.Bool(7, cfg.ReplaceAllowedIPs)
+	encodeIPNets(e, 8, cfg.AllowedIPs)
+}
+
+func decodePeerConfig(d *decoder, cfg *wgtypes.PeerConfig) {
+	switch d.num {
+	case 1:
+		cfg.PublicKey = d.Key()
+	case 2:
+		cfg.Remove = d.Bool()
+	case 3:
+		cfg.UpdateOnly = d.Bool()
+	case 4:
+		k := d.Key()
+		cfg.PresharedKey = &k
+	case 5:
+		cfg.Endpoint = d.UDPAddr()
+	case 6:
+		v := d.Duration()
+		cfg.PersistentKeepaliveInterval = &v
+	case 7:
+		cfg.ReplaceAllowedIPs = d.Bool()
+	case 8:
+		cfg.AllowedIPs = append(cfg.AllowedIPs, d.IPNet())
+	}
+}
+
+// encodeKey encodes k if it is not the zero-value Key.
+func encodeKey(e *encoder, num int, k wgtypes.Key) {
+	if k != (wgtypes.Key{}) {
+		e.Bytes(num, k[:])
+	}
+}
+
+// encodeUDPAddr encodes a UDPAddr message if addr is not nil.
+func encodeUDPAddr(e *encoder, num int, addr *net.UDPAddr) {
+	if addr == nil {
+		return
+	}
+
+	e.Message(num, func(e *encoder) {
+		if len(addr.IP) > 0 {
+			e.Bytes(1, addr.IP)
+		}
+		e.Int64(2, int64(addr.Port))
+		e.String(3, addr.Zone)
+	})
+}
+
+// encodeIPNets encodes an IPNet message for each of ipns.
+func encodeIPNets(e *encoder, num int, ipns []net.IPNet) {
+	for _, ipn := range ipns {
+		e.Message(num, func(e *encoder) {
+			if len(ipn.IP) > 0 {
+				e.Bytes(1, ipn.IP)
+			}
+			if len(ipn.Mask) > 0 {
+				e.Bytes(2, ipn.Mask)
+			}
+		})
+	}
+}
+
+// encodeDuration encodes a google.protobuf.Duration message.
+func encodeDuration(e *encoder, num int, v time.Duration) {
+	e.Message(num, func(e *encoder) {
+		e.Int64(1, int64(v/time.Second))
+		e.Int64(2, int64(v%time.Second))
+	})
+}
+
+// encodeTimestamp encodes a google.protobuf.Timestamp message.
+func encodeTimestamp(e *encoder, num int, t time.Time) {
+	e.Message(num, func(e *encoder) {
+		e.Int64(1, t.Unix())
+		e.Int64(2, int64(t.Nanosecond()))
+	})
+}
+
+// Int64 decodes an int64 field.
+func (d *decoder) Int64() int64 {
+	if !d.Expect(wireVarint) {
+		return 0
+	}
+
+	return int64(d.varint)
+}
+
+// Int decodes an int64 field which must fit in an int.
+func (d *decoder) Int() int {
+	v := d.Int64()
+	if int64(int(v)) != v {
+		d.Fail(fmt.Errorf("value %d overflows int", v))
+		return 0
+	}
+
+	return int(v)
+}
+
+// Bool decodes a bool field.
+func (d *decoder) Bool() bool {
+	return d.Int64() != 0
+}
+
+// String decodes a string field.
+func (d *decoder) String() string {
+	if !d.Expect(wireBytes) {
+		return ""
+	}
+
+	return string(d.bytes)
+}
+
+// Key decodes a bytes field containing a Key.
+func (d *decoder) Key() wgtypes.Key {
+	if !d.Expect(wireBytes) {
+		return wgtypes.Key{}
+	}
+	if len(d.bytes) == 0 {
+		return wgtypes.Key{}
+	}
+
+	k, err := wgtypes.NewKey(d.bytes)
+	d.Fail(err)
+	return k
+}
+
+// Message decodes an embedded message field by calling fn for each of its
+// fields.
+func (d *decoder) Message(fn func(d *decoder)) {
+	if !d.Expect(wireBytes) {
+		return
+	}
+
+	if err := decodeMessage(d.bytes, fn); err != nil && d.err == nil {
+		d.err = err
+	}
+}
+
+// UDPAddr decodes a UDPAddr message.
+func (d *decoder) UDPAddr() *net.UDPAddr {
+	var addr net.UDPAddr
+	d.Message(func(d *decoder) {
+		switch d.num {
+		case 1:
+			addr.IP = d.IP()
+		case 2:
+			addr.Port = d.Int()
+		case 3:
+			addr.Zone = d.String()
+		}
+	})
+
+	return &addr
+}
+
+// IPNet decodes an IPNet message.
+func (d *decoder) IPNet() net.IPNet {
+	var ipn net.IPNet
+	d.Message(func(d *decoder) {
+		switch d.num {
+		case 1:
+			ipn.IP = d.IP()
+		case 2:
+			ipn.Mask = net.IPMask(d.IP())
+		}
+	})
+
+	return ipn
+}
+
+// IP decodes a bytes field containing a 4 or 16 byte IP address or mask.
+func (d *decoder) IP() net.IP {
+	if !d.Expect(wireBytes) {
+		return nil
+	}
+
+	switch len(d.bytes) {
+	case net.IPv4len, net.IPv6len:
+		// Copy to avoid retaining the caller's buffer.
+		return append(net.IP(nil), d.bytes...)
+	default:
+		d.Fail(fmt.Errorf("invalid IP address length: %d", len(d.bytes)))
+		return nil
+	}
+}
+
+// Duration decodes a google.protobuf.Duration message.
+func (d *decoder) Duration() time.Duration {
+	sec, nsec := d.secondsNanos()
+	if sec > math.MaxInt64/int64(time.Second) || sec < math.MinInt64/int64(time.Second) {
+		d.Fail(errors.New("duration out of range"))
+		return 0
+	}
+
+	return time.Duration(sec)*time.Second + time.Duration(nsec)
+}
+
+// Timestamp decodes a google.protobuf.Timestamp message.
+func (d *decoder) Timestamp() time.Time {
+	sec, nsec := d.secondsNanos()
+	return time.Unix(sec, nsec)
+}
+
+// secondsNanos decodes the fields shared by the Duration and Timestamp
+// messages.
+func (d *decoder) secondsNanos() (sec, nsec int64) {
+	d.Message(func(d *decoder) {
+		switch d.num {
+		case 1:
+			sec = d.Int64()
+		case 2:
+			nsec = d.Int64()
+			if nsec <= -int64(time.Second) || nsec >= int64(time.Second) {
+				d.Fail(fmt.Errorf("nanoseconds out of range: %d", nsec))
+			}
+		}
+	})
+
+	return sec, nsec
+}
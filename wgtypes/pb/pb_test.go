@@ -0,0 +1,228 @@
+package pb_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes/pb"
+)
+
+func TestDeviceRoundTrip(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		ipv6 = wgtest.MustUDPAddr("[fe80::1%2]:51820")
+	)
+	ipv6.Zone = "eth0"
+
+	tests := []struct {
+		name string
+		d    *wgtypes.Device
+	}{
+		{
+			name: "empty",
+			d:    &wgtypes.Device{},
+		},
+		{
+			name: "full",
+			d: &wgtypes.Device{
+				Name:         "wg0",
+				Type:         wgtypes.LinuxKernel,
+				PrivateKey:   priv,
+				PublicKey:    priv.PublicKey(),
+				ListenPort:   51820,
+				FirewallMark: 0x7fffffff,
+				Peers: []wgtypes.Peer{
+					{
+						PublicKey:                   wgtest.MustPublicKey(),
+						PresharedKey:                wgtest.MustPresharedKey(),
+						Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+						PersistentKeepaliveInterval: 25*time.Second + 1,
+						LastHandshakeTime:           time.Unix(1700000000, 123456789),
+						ReceiveBytes:                1 << 40,
+						TransmitBytes:               1,
+						AllowedIPs: []net.IPNet{
+							wgtest.MustCIDR("10.0.0.0/24"),
+							wgtest.MustCIDR("fd00::/64"),
+							// Non-canonical IPv4-in-IPv6 form is preserved.
+							{IP: net.ParseIP("192.0.2.1"), Mask: net.CIDRMask(32, 32)},
+						},
+						ProtocolVersion: 1,
+					},
+					{
+						PublicKey: wgtest.MustPublicKey(),
+						Endpoint:  ipv6,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := pb.UnmarshalDevice(pb.MarshalDevice(tt.d))
+			if err != nil {
+				t.Fatalf("failed to unmarshal device: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.d, d); diff != "" {
+				t.Fatalf("unexpected device (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	var (
+		zeroKey   wgtypes.Key
+		zeroInt   int
+		port      = 51820
+		keepalive = 25 * time.Second
+		zeroDur   time.Duration
+	)
+
+	tests := []struct {
+		name string
+		cfg  wgtypes.Config
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name: "zero values are not absent",
+			cfg: wgtypes.Config{
+				PrivateKey:   &zeroKey,
+				ListenPort:   &zeroInt,
+				FirewallMark: &zeroInt,
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:                   wgtest.MustPublicKey(),
+					PresharedKey:                &zeroKey,
+					PersistentKeepaliveInterval: &zeroDur,
+				}},
+			},
+		},
+		{
+			name: "full",
+			cfg: wgtypes.Config{
+				ListenPort:   &port,
+				ReplacePeers: true,
+				Peers: []wgtypes.PeerConfig{
+					{
+						PublicKey:                   wgtest.MustPublicKey(),
+						UpdateOnly:                  true,
+						Endpoint:                    wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+						PersistentKeepaliveInterval: &keepalive,
+						ReplaceAllowedIPs:           true,
+						AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+					},
+					{
+						PublicKey: wgtest.MustPublicKey(),
+						Remove:    true,
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := pb.UnmarshalConfig(pb.MarshalConfig(tt.cfg))
+			if err != nil {
+				t.Fatalf("failed to unmarshal config: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.cfg, cfg); diff != "" {
+				t.Fatalf("unexpected config (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMarshalWireFormat(t *testing.T) {
+	b := pb.MarshalDevice(&wgtypes.Device{
+		Name:       "wg0",
+		ListenPort: 51820,
+	})
+
+	want := []byte{
+		// name: "wg0"
+		0x0a, 0x03, 'w', 'g', '0',
+		// listen_port: 51820
+		0x28, 0xec, 0x94, 0x03,
+	}
+
+	if diff := cmp.Diff(want, b); diff != "" {
+		t.Fatalf("unexpected bytes (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnmarshalUnknownFields(t *testing.T) {
+	b := []byte{
+		// Unknown varint, fixed64, bytes, and fixed32 fields.
+		0xf8, 0x01, 0x01,
+		0xf9, 0x01, 0, 0, 0, 0, 0, 0, 0, 0,
+		0xfa, 0x01, 0x01, 0xff,
+		0xfd, 0x01, 0, 0, 0, 0,
+		// name: "wg0"
+		0x0a, 0x03, 'w', 'g', '0',
+	}
+
+	d, err := pb.UnmarshalDevice(b)
+	if err != nil {
+		t.Fatalf("failed to unmarshal device: %v", err)
+	}
+
+	if diff := cmp.Diff(&wgtypes.Device{Name: "wg0"}, d); diff != "" {
+		t.Fatalf("unexpected device (-want +got):\n%s", diff)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		b    []byte
+	}{
+		{
+			name: "truncated varint",
+			b:    []byte{0x28, 0xec},
+		},
+		{
+			name: "truncated bytes",
+			b:    []byte{0x0a, 0x03, 'w'},
+		},
+		{
+			name: "field zero",
+			b:    []byte{0x00, 0x00},
+		},
+		{
+			name: "group wire type",
+			b:    []byte{0x0b},
+		},
+		{
+			name: "wrong wire type",
+			b:    []byte{0x08, 0x01},
+		},
+		{
+			name: "short key",
+			b:    []byte{0x1a, 0x01, 0x00},
+		},
+		{
+			name: "bad peer IP",
+			b:    []byte{0x3a, 0x06, 0x1a, 0x04, 0x0a, 0x02, 0x00, 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := pb.UnmarshalDevice(tt.b)
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package pb
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ErrSnapshotGap is returned by a SnapshotDecoder when a delta snapshot does
+// not follow the previously decoded snapshot, such as when a snapshot was lost
+// in transit. Decoding can resume from the next keyframe.
+var ErrSnapshotGap = errors.New("pb: snapshot does not follow previous snapshot")
+
+// A SnapshotEncoder encodes a stream of snapshots of a single device as
+// Snapshot messages. After an initial keyframe, only the changes between
+// successive snapshots are encoded, so that frequently polling a device with
+// many mostly idle peers produces little output.
+//
+// A SnapshotEncoder retains no references to the Devices passed to Encode, so
+// callers may reuse them between calls.
+type SnapshotEncoder struct {
+	// KeyframeInterval specifies that every KeyframeInterval'th snapshot is
+	// encoded as a keyframe, allowing a SnapshotDecoder to recover from lost
+	// snapshots. If zero, only the first snapshot is a keyframe.
+	KeyframeInterval int
+
+	seq   uint64
+	peers map[wgtypes.Key]peerState
+}
+
+// peerState is the state of a peer retained by a SnapshotEncoder.
+type peerState struct {
+	config string
+	stats  peerStats
+}
+
+// peerStats are the fields of a peer which are encoded in a PeerStats message.
+type peerStats struct {
+	LastHandshakeTime           time.Time
+	ReceiveBytes, TransmitBytes int64
+}
+
+// equal reports whether s and o are equal.
+func (s peerStats) equal(o peerStats) bool {
+	return s.LastHandshakeTime.Equal(o.LastHandshakeTime) &&
+		s.ReceiveBytes == o.ReceiveBytes &&
+		s.TransmitBytes == o.TransmitBytes
+}
+
+// Reset causes the next snapshot to be encoded as a keyframe.
+func (e *SnapshotEncoder) Reset() {
+	e.seq = 0
+	e.peers = nil
+}
+
+// Encode encodes a snapshot of d, as a keyframe or as a delta against the
+// previously encoded snapshot.
+func (e *SnapshotEncoder) Encode(d *wgtypes.Device) []byte {
+	keyframe := e.peers == nil ||
+		(e.KeyframeInterval > 0 && e.seq%uint64(e.KeyframeInterval) == 0)
+
+	prev := e.peers
+	e.seq++
+	e.peers = make(map[wgtypes.Key]peerState, len(d.Peers))
+
+	var out encoder
+	out.Varint(1, e.seq)
+	if !keyframe {
+		out.Varint(2, e.seq-1)
+	}
+
+	// A delta always carries the device without its peers, which is small
+	// compared to the peers themselves.
+	if keyframe {
+		out.Message(3, func(e *encoder) { encodeDevice(e, d) })
+	} else {
+		header := *d
+		header.Peers = nil
+		out.Message(3, func(e *encoder) { encodeDevice(e, &header) })
+	}
+
+	for _, p := range d.Peers {
+		st := peerState{
+			config: peerConfig(p),
+			stats: peerStats{
+				LastHandshakeTime: p.LastHandshakeTime,
+				ReceiveBytes:      p.ReceiveBytes,
+				TransmitBytes:     p.TransmitBytes,
+			},
+		}
+		e.peers[p.PublicKey] = st
+
+		if keyframe {
+			continue
+		}
+
+		old, ok := prev[p.PublicKey]
+		switch {
+		case !ok || old.config != st.config:
+			out.Message(4, func(e *encoder) { encodePeer(e, p) })
+		case !old.stats.equal(st.stats):
+			out.Message(5, func(e *encoder) {
+				encodeKey(e, 1, p.PublicKey)
+				if !p.LastHandshakeTime.IsZero() {
+					encodeTimestamp(e, 2, p.LastHandshakeTime)
+				}
+				e.Int64(3, p.ReceiveBytes)
+				e.Int64(4, p.TransmitBytes)
+			})
+		}
+	}
+
+	for k := range prev {
+		if _, ok := e.peers[k]; !ok && !keyframe {
+			out.Bytes(6, k[:])
+		}
+	}
+
+	return out.b
+}
+
+// peerConfig encodes the fields of p which are not statistics.
+func peerConfig(p wgtypes.Peer) string {
+	p.LastHandshakeTime = time.Time{}
+	p.ReceiveBytes = 0
+	p.TransmitBytes = 0
+
+	var e encoder
+	encodePeer(&e, p)
+	return string(e.b)
+}
+
+// A SnapshotDecoder decodes a stream of Snapshot messages produced by a
+// SnapshotEncoder.
+type SnapshotDecoder struct {
+	seq    uint64
+	device *wgtypes.Device
+	index  map[wgtypes.Key]int
+}
+
+// Decode decodes the next snapshot in a stream and returns the complete
+// device it describes. Peers added by a delta are appended after existing
+// peers, so the order of peers may differ from the encoded device.
+//
+// If a delta snapshot does not follow the previously decoded snapshot,
+// ErrSnapshotGap is returned and further deltas are rejected until the next
+// keyframe.
+func (sd *SnapshotDecoder) Decode(b []byte) (*wgtypes.Device, error) {
+	var (
+		seq, base uint64
+		header    []byte
+		peers     []wgtypes.Peer
+		stats     []wgtypes.Peer
+		removed   []wgtypes.Key
+	)
+
+	err := decodeMessage(b, func(d *decoder) {
+		switch d.num {
+		case 1:
+			seq = uint64(d.Int64())
+		case 2:
+			base = uint64(d.Int64())
+		case 3:
+			if d.Expect(wireBytes) {
+				header = d.bytes
+			}
+		case 4:
+			var p wgtypes.Peer
+			d.Message(func(d *decoder) { decodePeer(d, &p) })
+			peers = append(peers, p)
+		case 5:
+			var p wgtypes.Peer
+			d.Message(func(d *decoder) {
+				switch d.num {
+				case 1:
+					p.PublicKey = d.Key()
+				case 2:
+					p.LastHandshakeTime = d.Timestamp()
+				case 3:
+					p.ReceiveBytes = d.Int64()
+				case 4:
+					p.TransmitBytes = d.Int64()
+				}
+			})
+			stats = append(stats, p)
+		case 6:
+			removed = append(removed, d.Key())
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dev, err := UnmarshalDevice(header)
+	if err != nil {
+		return nil, err
+	}
+
+	if base == 0 {
+		// Keyframe: replace all existing state.
+		sd.seq = seq
+		sd.device = dev
+		sd.reindex()
+		return sd.snapshot(), nil
+	}
+
+	if sd.device == nil || base != sd.seq {
+		sd.device = nil
+		return nil, fmt.Errorf("%w: got delta from %d, expected %d", ErrSnapshotGap, base, sd.seq)
+	}
+
+	dev.Peers = sd.device.Peers
+	sd.device = dev
+
+	if len(removed) > 0 {
+		remove := make(map[wgtypes.Key]bool, len(removed))
+		for _, k := range removed {
+			remove[k] = true
+		}
+
+		keep := dev.Peers[:0]
+		for _, p := range dev.Peers {
+			if !remove[p.PublicKey] {
+				keep = append(keep, p)
+			}
+		}
+
+		dev.Peers = keep
+		sd.reindex()
+	}
+
+	for _, p := range peers {
+		if i, ok := sd.index[p.PublicKey]; ok {
+			dev.Peers[i] = p
+			continue
+		}
+
+		sd.index[p.PublicKey] = len(dev.Peers)
+		dev.Peers = append(dev.Peers, p)
+	}
+
+	for _, s := range stats {
+		i, ok := sd.index[s.PublicKey]
+		if !ok {
+			sd.device = nil
+			return nil, fmt.Errorf("pb: statistics for unknown peer %s", s.PublicKey)
+		}
+
+		p := &dev.Peers[i]
+		p.LastHandshakeTime = s.LastHandshakeTime
+		p.ReceiveBytes = s.ReceiveBytes
+		p.TransmitBytes = s.TransmitBytes
+	}
+
+	sd.seq = seq
+	return sd.snapshot(), nil
+}
+
+// reindex rebuilds the index of peers by public key.
+func (sd *SnapshotDecoder) reindex() {
+	sd.index = make(map[wgtypes.Key]int, len(sd.device.Peers))
+	for i, p := range sd.device.Peers {
+		sd.index[p.PublicKey] = i
+	}
+}
+
+// snapshot returns a copy of the current device which the caller may modify
+// without affecting the decoder's state.
+func (sd *SnapshotDecoder) snapshot() *wgtypes.Device {
+	d := *sd.device
+	d.Peers = append([]wgtypes.Peer(nil), sd.device.Peers...)
+	return &d
+}
@@ -0,0 +1,130 @@
+package pb_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes/pb"
+)
+
+func TestSnapshotDelta(t *testing.T) {
+	var (
+		a = wgtypes.Peer{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.1/32")},
+		}
+		b = wgtypes.Peer{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []net.IPNet{wgtest.MustCIDR("10.0.0.2/32")},
+		}
+		c = wgtypes.Peer{
+			PublicKey: wgtest.MustPublicKey(),
+		}
+	)
+
+	// Each step modifies the previous device. Peers added by deltas are
+	// appended by the decoder, so each device lists new peers last.
+	steps := []func(d *wgtypes.Device){
+		func(d *wgtypes.Device) {
+			d.Name = "wg0"
+			d.ListenPort = 51820
+			d.Peers = []wgtypes.Peer{a, b}
+		},
+		// Unchanged.
+		func(d *wgtypes.Device) {},
+		// Statistics change.
+		func(d *wgtypes.Device) {
+			d.Peers[0].LastHandshakeTime = time.Unix(1700000000, 0)
+			d.Peers[0].ReceiveBytes = 1024
+		},
+		// Configuration change, removal, and addition.
+		func(d *wgtypes.Device) {
+			d.ListenPort = 51821
+			d.Peers[0].Endpoint = wgtest.MustUDPAddr("192.0.2.1:51820")
+			d.Peers = []wgtypes.Peer{d.Peers[0], c}
+		},
+		// Counters reset to zero.
+		func(d *wgtypes.Device) {
+			d.Peers[0].LastHandshakeTime = time.Time{}
+			d.Peers[0].ReceiveBytes = 0
+		},
+	}
+
+	var (
+		enc pb.SnapshotEncoder
+		dec pb.SnapshotDecoder
+		d   wgtypes.Device
+	)
+
+	for i, step := range steps {
+		// Reuse the same Device and Peers slice between snapshots, as a
+		// caller polling a device might.
+		step(&d)
+
+		got, err := dec.Decode(enc.Encode(&d))
+		if err != nil {
+			t.Fatalf("step %d: failed to decode snapshot: %v", i, err)
+		}
+
+		if diff := cmp.Diff(&d, got); diff != "" {
+			t.Fatalf("step %d: unexpected device (-want +got):\n%s", i, diff)
+		}
+	}
+}
+
+func TestSnapshotSize(t *testing.T) {
+	d := &wgtypes.Device{Name: "wg0"}
+	for i := 0; i < 10000; i++ {
+		d.Peers = append(d.Peers, wgtypes.Peer{
+			PublicKey:  wgtest.MustPublicKey(),
+			AllowedIPs: []net.IPNet{{IP: net.IP{10, 0, byte(i >> 8), byte(i)}, Mask: net.CIDRMask(32, 32)}},
+		})
+	}
+
+	var enc pb.SnapshotEncoder
+	keyframe := enc.Encode(d)
+
+	for i := 0; i < 10; i++ {
+		d.Peers[i].ReceiveBytes += 1 << 20
+		d.Peers[i].LastHandshakeTime = time.Unix(1700000000, 0)
+	}
+
+	delta := enc.Encode(d)
+	if len(delta) > 1000 {
+		t.Fatalf("delta is too large: %d bytes, keyframe is %d bytes", len(delta), len(keyframe))
+	}
+}
+
+func TestSnapshotGap(t *testing.T) {
+	var (
+		enc = pb.SnapshotEncoder{KeyframeInterval: 3}
+		dec pb.SnapshotDecoder
+		d   = &wgtypes.Device{Name: "wg0"}
+	)
+
+	if _, err := dec.Decode(enc.Encode(d)); err != nil {
+		t.Fatalf("failed to decode keyframe: %v", err)
+	}
+
+	// Lose a delta, so the next one cannot be applied.
+	_ = enc.Encode(d)
+	if _, err := dec.Decode(enc.Encode(d)); !errors.Is(err, pb.ErrSnapshotGap) {
+		t.Fatalf("expected snapshot gap, but got: %v", err)
+	}
+
+	// The fourth snapshot is a keyframe, from which decoding can resume.
+	d.ListenPort = 51820
+	got, err := dec.Decode(enc.Encode(d))
+	if err != nil {
+		t.Fatalf("failed to decode keyframe: %v", err)
+	}
+
+	if diff := cmp.Diff(d, got); diff != "" {
+		t.Fatalf("unexpected device (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,173 @@
+package pb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Protocol buffers wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// errTruncated is returned when a message ends in the middle of a field.
+var errTruncated = errors.New("pb: truncated message")
+
+// An encoder appends protocol buffers fields to a buffer.
+type encoder struct {
+	b []byte
+}
+
+// tag appends the tag for field num of type typ.
+func (e *encoder) tag(num, typ int) {
+	e.b = binary.AppendUvarint(e.b, uint64(num)<<3|uint64(typ))
+}
+
+// Varint appends a varint field, even if v is zero.
+func (e *encoder) Varint(num int, v uint64) {
+	e.tag(num, wireVarint)
+	e.b = binary.AppendUvarint(e.b, v)
+}
+
+// Int64 appends an int64 field if v is not zero.
+func (e *encoder) Int64(num int, v int64) {
+	if v != 0 {
+		e.Varint(num, uint64(v))
+	}
+}
+
+// Bool appends a bool field if v is true.
+func (e *encoder) Bool(num int, v bool) {
+	if v {
+		e.Varint(num, 1)
+	}
+}
+
+// Bytes appends a length-delimited field, even if b is empty.
+func (e *encoder) Bytes(num int, b []byte) {
+	e.tag(num, wireBytes)
+	e.b = binary.AppendUvarint(e.b, uint64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// String appends a string field if s is not empty.
+func (e *encoder) String(num int, s string) {
+	if s != "" {
+		e.Bytes(num, []byte(s))
+	}
+}
+
+// Message appends an embedded message field produced by fn.
+func (e *encoder) Message(num int, fn func(e *encoder)) {
+	var me encoder
+	fn(&me)
+	e.Bytes(num, me.b)
+}
+
+// A decoder iterates over the fields of a protocol buffers message.
+type decoder struct {
+	b   []byte
+	err error
+
+	num, typ int
+	varint   uint64
+	bytes    []byte
+}
+
+// Next advances to the next field, returning false when no fields remain or
+// an error occurs. The field's value is stored in varint or bytes depending on
+// its wire type, and fields of other wire types are skipped.
+func (d *decoder) Next() bool {
+	if d.err != nil || len(d.b) == 0 {
+		return false
+	}
+
+	tag, ok := d.uvarint()
+	if !ok {
+		return false
+	}
+
+	d.num, d.typ = int(tag>>3), int(tag&7)
+	if d.num == 0 {
+		d.err = errors.New("pb: invalid field number 0")
+		return false
+	}
+
+	switch d.typ {
+	case wireVarint:
+		d.varint, ok = d.uvarint()
+	case wireBytes:
+		var n uint64
+		if n, ok = d.uvarint(); !ok {
+			break
+		}
+		if n > uint64(len(d.b)) {
+			d.err = errTruncated
+			return false
+		}
+
+		d.bytes, d.b = d.b[:n], d.b[n:]
+	case wireFixed64:
+		ok = d.skip(8)
+	case wireFixed32:
+		ok = d.skip(4)
+	default:
+		d.err = fmt.Errorf("pb: unsupported wire type %d for field %d", d.typ, d.num)
+		return false
+	}
+
+	return ok
+}
+
+// Err returns any error encountered by Next.
+func (d *decoder) Err() error { return d.err }
+
+// Expect checks that the current field is of wire type typ.
+func (d *decoder) Expect(typ int) bool {
+	if d.err != nil {
+		return false
+	}
+	if d.typ != typ {
+		d.err = fmt.Errorf("pb: unexpected wire type %d for field %d", d.typ, d.num)
+		return false
+	}
+
+	return true
+}
+
+// Fail records err for the current field, if no other error has occurred.
+func (d *decoder) Fail(err error) {
+	if d.err == nil && err != nil {
+		d.err = fmt.Errorf("pb: field %d: %v", d.num, err)
+	}
+}
+
+// uvarint consumes a varint from the buffer.
+func (d *decoder) uvarint() (uint64, bool) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.err = errTruncated
+		if n < 0 {
+			d.err = errors.New("pb: varint overflows 64 bits")
+		}
+		return 0, false
+	}
+
+	d.b = d.b[n:]
+	return v, true
+}
+
+// skip consumes n bytes from the buffer.
+func (d *decoder) skip(n int) bool {
+	if len(d.b) < n {
+		d.err = errTruncated
+		return false
+	}
+
+	d.b = d.b[n:]
+	return true
+}
@@ -0,0 +1,131 @@
+package wgtypes
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Redacted returns a placeholder for a Key which must not be revealed, such
+// as a private or preshared key: "(hidden)", or "(none)" for the zero Key.
+func (k Key) Redacted() string {
+	if k == (Key{}) {
+		return "(none)"
+	}
+
+	return "(hidden)"
+}
+
+// Abbreviated returns the first 8 characters of the base64-encoded string
+// representation of a public Key followed by "...", which is usually enough
+// to identify a peer in logs. It must not be used for private or preshared
+// keys, since it reveals part of the key.
+func (k Key) Abbreviated() string {
+	return k.String()[:8] + "..."
+}
+
+// Redacted returns a string representation of d which is safe to log: its
+// private key and the preshared keys of its peers are replaced by
+// Key.Redacted, and public keys are abbreviated.
+func (d Device) Redacted() string {
+	peers := make([]string, 0, len(d.Peers))
+	for _, p := range d.Peers {
+		peers = append(peers, p.Redacted())
+	}
+
+	return fmt.Sprintf("{Name:%s Type:%s PrivateKey:%s PublicKey:%s ListenPort:%d FirewallMark:%d Peers:[%s]}",
+		d.Name, d.Type, d.PrivateKey.Redacted(), d.PublicKey.Abbreviated(),
+		d.ListenPort, d.FirewallMark, strings.Join(peers, " "))
+}
+
+// String returns d.Redacted, so that formatting a Device never reveals its
+// secrets.
+func (d Device) String() string { return d.Redacted() }
+
+// Redacted returns a string representation of p which is safe to log: its
+// preshared key is replaced by Key.Redacted, and its public key is
+// abbreviated.
+func (p Peer) Redacted() string {
+	return fmt.Sprintf("{PublicKey:%s PresharedKey:%s HasPresharedKey:%t Endpoint:%s PersistentKeepaliveInterval:%s LastHandshakeTime:%s ReceiveBytes:%d TransmitBytes:%d AllowedIPs:[%s] ProtocolVersion:%d}",
+		p.PublicKey.Abbreviated(), p.PresharedKey.Redacted(), p.HasPresharedKey,
+		udpAddrString(p.Endpoint), p.PersistentKeepaliveInterval, p.LastHandshakeTime,
+		p.ReceiveBytes, p.TransmitBytes, ipNetsString(p.AllowedIPs), p.ProtocolVersion)
+}
+
+// String returns p.Redacted, so that formatting a Peer never reveals its
+// preshared key.
+func (p Peer) String() string { return p.Redacted() }
+
+// Redacted returns a string representation of c which is safe to log: its
+// private key and the preshared keys of its peers are replaced by
+// Key.Redacted, and public keys are abbreviated. Unset fields are shown as
+// "<nil>".
+func (c Config) Redacted() string {
+	peers := make([]string, 0, len(c.Peers))
+	for _, p := range c.Peers {
+		peers = append(peers, p.Redacted())
+	}
+
+	return fmt.Sprintf("{PrivateKey:%s ListenPort:%s FirewallMark:%s ReplacePeers:%t Peers:[%s]}",
+		redactedKeyPtr(c.PrivateKey), intPtrString(c.ListenPort), intPtrString(c.FirewallMark),
+		c.ReplacePeers, strings.Join(peers, " "))
+}
+
+// String returns c.Redacted, so that formatting a Config never reveals its
+// secrets.
+func (c Config) String() string { return c.Redacted() }
+
+// Redacted returns a string representation of p which is safe to log: its
+// preshared key is replaced by Key.Redacted, and its public key is
+// abbreviated. Unset fields are shown as "<nil>".
+func (p PeerConfig) Redacted() string {
+	keepalive := "<nil>"
+	if p.PersistentKeepaliveInterval != nil {
+		keepalive = p.PersistentKeepaliveInterval.String()
+	}
+
+	return fmt.Sprintf("{PublicKey:%s Name:%s Remove:%t UpdateOnly:%t PresharedKey:%s Endpoint:%s PersistentKeepaliveInterval:%s ReplaceAllowedIPs:%t AllowedIPs:[%s]}",
+		p.PublicKey.Abbreviated(), p.Name, p.Remove, p.UpdateOnly, redactedKeyPtr(p.PresharedKey),
+		udpAddrString(p.Endpoint), keepalive, p.ReplaceAllowedIPs, ipNetsString(p.AllowedIPs))
+}
+
+// String returns p.Redacted, so that formatting a PeerConfig never reveals
+// its preshared key.
+func (p PeerConfig) String() string { return p.Redacted() }
+
+// redactedKeyPtr returns k.Redacted, or "<nil>" if k is nil.
+func redactedKeyPtr(k *Key) string {
+	if k == nil {
+		return "<nil>"
+	}
+
+	return k.Redacted()
+}
+
+// intPtrString formats v, which may be nil.
+func intPtrString(v *int) string {
+	if v == nil {
+		return "<nil>"
+	}
+
+	return fmt.Sprint(*v)
+}
+
+// udpAddrString formats addr, which may be nil.
+func udpAddrString(addr *net.UDPAddr) string {
+	if addr == nil {
+		return "<nil>"
+	}
+
+	return addr.String()
+}
+
+// ipNetsString formats ipns in CIDR notation, separated by spaces.
+func ipNetsString(ipns []net.IPNet) string {
+	ss := make([]string, 0, len(ipns))
+	for i := range ipns {
+		ss = append(ss, ipns[i].String())
+	}
+
+	return strings.Join(ss, " ")
+}
@@ -0,0 +1,87 @@
+package wgtypes_test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestRedacted(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+		psk  = wgtest.MustPresharedKey()
+		port = 51820
+		ka   = 25 * time.Second
+	)
+
+	abbrev := pub.String()[:8] + "..."
+
+	tests := []struct {
+		name string
+		v    fmt.Stringer
+		want string
+	}{
+		{
+			name: "device",
+			v: wgtypes.Device{
+				Name:       "wg0",
+				Type:       wgtypes.LinuxKernel,
+				PrivateKey: priv,
+				PublicKey:  pub,
+				ListenPort: port,
+				Peers: []wgtypes.Peer{{
+					PublicKey:       pub,
+					PresharedKey:    psk,
+					HasPresharedKey: true,
+					Endpoint:        wgtest.MustUDPAddr("192.0.2.1:51820"),
+					AllowedIPs:      []net.IPNet{wgtest.MustCIDR("10.0.0.0/24")},
+				}},
+			},
+			want: "{Name:wg0 Type:Linux kernel PrivateKey:(hidden) PublicKey:" + abbrev +
+				" ListenPort:51820 FirewallMark:0 Peers:[{PublicKey:" + abbrev +
+				" PresharedKey:(hidden) HasPresharedKey:true Endpoint:192.0.2.1:51820" +
+				" PersistentKeepaliveInterval:0s LastHandshakeTime:0001-01-01 00:00:00 +0000 UTC" +
+				" ReceiveBytes:0 TransmitBytes:0 AllowedIPs:[10.0.0.0/24] ProtocolVersion:0}]}",
+		},
+		{
+			name: "config",
+			v: wgtypes.Config{
+				PrivateKey: &priv,
+				ListenPort: &port,
+				Peers: []wgtypes.PeerConfig{{
+					PublicKey:                   pub,
+					PresharedKey:                &wgtypes.Key{},
+					PersistentKeepaliveInterval: &ka,
+				}},
+			},
+			want: "{PrivateKey:(hidden) ListenPort:51820 FirewallMark:<nil> ReplacePeers:false Peers:[{PublicKey:" + abbrev +
+				" Name: Remove:false UpdateOnly:false PresharedKey:(none) Endpoint:<nil>" +
+				" PersistentKeepaliveInterval:25s ReplaceAllowedIPs:false AllowedIPs:[]}]}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, tt.v.String()); diff != "" {
+				t.Fatalf("unexpected string (-want +got):\n%s", diff)
+			}
+
+			// No formatting verb may reveal a secret key.
+			for _, verb := range []string{"%v", "%+v", "%s"} {
+				s := fmt.Sprintf(verb, tt.v)
+				for _, k := range []wgtypes.Key{priv, psk} {
+					if strings.Contains(s, k.String()) {
+						t.Fatalf("%s revealed a secret key: %s", verb, s)
+					}
+				}
+			}
+		})
+	}
+}
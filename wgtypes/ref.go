@@ -0,0 +1,101 @@
+package wgtypes
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A PeerRef identifies a WireGuard device on a host, or a peer of that device,
+// so that tools which operate on several hosts share a canonical identifier.
+type PeerRef struct {
+	// Host identifies the host, such as its host name. An empty Host refers
+	// to the local host.
+	Host string
+
+	// Device is the name of the device.
+	Device string
+
+	// PublicKey is the public key of a peer of the device, or the zero Key
+	// if the PeerRef refers to the device itself.
+	PublicKey Key
+}
+
+// ParsePeerRef parses a PeerRef in the form produced by PeerRef.String, such
+// as "host/wg0" or "host/wg0/<base64 public key>".
+func ParsePeerRef(s string) (PeerRef, error) {
+	ss := strings.SplitN(s, "/", 3)
+	if len(ss) < 2 {
+		return PeerRef{}, fmt.Errorf("wgtypes: peer reference %q must be of the form host/device[/key]", s)
+	}
+
+	r := PeerRef{Host: ss[0], Device: ss[1]}
+	if len(ss) == 3 {
+		// Keys may contain '/', so the key is the remainder of the string.
+		k, err := ParseKey(ss[2])
+		if err != nil {
+			return PeerRef{}, fmt.Errorf("wgtypes: invalid key in peer reference %q: %v", s, err)
+		}
+
+		r.PublicKey = k
+	}
+
+	if err := r.validate(); err != nil {
+		return PeerRef{}, err
+	}
+
+	return r, nil
+}
+
+// IsDevice reports whether r refers to a device rather than one of its peers.
+func (r PeerRef) IsDevice() bool { return r.PublicKey == Key{} }
+
+// DeviceRef returns the PeerRef of the device on which r is a peer.
+func (r PeerRef) DeviceRef() PeerRef { return PeerRef{Host: r.Host, Device: r.Device} }
+
+// String returns the string representation of a PeerRef: the host, device,
+// and public key, if any, separated by '/'. A PeerRef for the local host
+// begins with '/'.
+//
+// ParsePeerRef can be used to produce a new PeerRef from this string.
+func (r PeerRef) String() string {
+	if r.IsDevice() {
+		return r.Host + "/" + r.Device
+	}
+
+	return r.Host + "/" + r.Device + "/" + r.PublicKey.String()
+}
+
+// MarshalText implements encoding.TextMarshaler, so that a PeerRef may be
+// used as a JSON object key.
+func (r PeerRef) MarshalText() ([]byte, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (r *PeerRef) UnmarshalText(b []byte) error {
+	ref, err := ParsePeerRef(string(b))
+	if err != nil {
+		return err
+	}
+
+	*r = ref
+	return nil
+}
+
+// validate verifies that r can be represented as a string.
+func (r PeerRef) validate() error {
+	switch {
+	case r.Device == "":
+		return fmt.Errorf("wgtypes: peer reference must specify a device")
+	case strings.ContainsAny(r.Host, "/ \t\n"):
+		return fmt.Errorf("wgtypes: invalid host %q in peer reference", r.Host)
+	case strings.ContainsAny(r.Device, "/ \t\n"):
+		return fmt.Errorf("wgtypes: invalid device %q in peer reference", r.Device)
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+package wgtypes_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestPeerRef(t *testing.T) {
+	// A key containing '/' must survive a round trip.
+	key := wgtest.MustHexKey("fcffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f")
+
+	tests := []struct {
+		name string
+		s    string
+		ref  wgtypes.PeerRef
+	}{
+		{
+			name: "device",
+			s:    "gw1.example.com/wg0",
+			ref:  wgtypes.PeerRef{Host: "gw1.example.com", Device: "wg0"},
+		},
+		{
+			name: "local device",
+			s:    "/wg0",
+			ref:  wgtypes.PeerRef{Device: "wg0"},
+		},
+		{
+			name: "peer",
+			s:    "[2001:db8::1]/wg0/" + key.String(),
+			ref:  wgtypes.PeerRef{Host: "[2001:db8::1]", Device: "wg0", PublicKey: key},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := wgtypes.ParsePeerRef(tt.s)
+			if err != nil {
+				t.Fatalf("failed to parse peer reference: %v", err)
+			}
+
+			if diff := cmp.Diff(tt.ref, ref); diff != "" {
+				t.Fatalf("unexpected peer reference (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.s, ref.String()); diff != "" {
+				t.Fatalf("unexpected string (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	for _, s := range []string{"", "host", "host/", "host/wg0/notakey", "bad host/wg0"} {
+		if _, err := wgtypes.ParsePeerRef(s); err == nil {
+			t.Fatalf("expected an error for %q, but none occurred", s)
+		}
+	}
+}
+
+func TestPeerRefJSON(t *testing.T) {
+	ref := wgtypes.PeerRef{Host: "gw1", Device: "wg0", PublicKey: wgtest.MustPublicKey()}
+
+	b, err := json.Marshal(map[wgtypes.PeerRef]int{ref: 1, ref.DeviceRef(): 2})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var got map[wgtypes.PeerRef]int
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("failed to unmarshal: %v", err)
+	}
+
+	want := map[wgtypes.PeerRef]int{ref: 1, {Host: "gw1", Device: "wg0"}: 2}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("unexpected references (-want +got):\n%s", diff)
+	}
+
+	if _, err := json.Marshal(wgtypes.PeerRef{Host: "gw1"}); err == nil {
+		t.Fatal("expected an error, but none occurred")
+	}
+}
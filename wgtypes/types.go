@@ -2,9 +2,13 @@ package wgtypes
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"hash/maphash"
 	"net"
+	"strings"
 	"time"
 
 	"golang.org/x/crypto/curve25519"
@@ -71,6 +75,12 @@ type Device struct {
 // KeyLen is the expected key length for a WireGuard key.
 const KeyLen = 32 // wgh.KeyLen
 
+// Encoded lengths of a Key in its textual forms.
+const (
+	keyLenBase64 = 44 // base64.StdEncoding.EncodedLen(KeyLen)
+	keyLenHex    = 64 // hex.EncodedLen(KeyLen)
+)
+
 // A Key is a public, private, or pre-shared secret key.  The Key constructor
 // functions in this package can be used to create Keys suitable for each of
 // these applications.
@@ -131,6 +141,63 @@ func ParseKey(s string) (Key, error) {
 	return NewKey(b)
 }
 
+// ParseKeyStrict parses a Key from a base64-encoded string with the same
+// strictness as wg(8): the string must be exactly 44 characters long, end with
+// a single '=' padding character, and be the canonical encoding of a Key.
+func ParseKeyStrict(s string) (Key, error) {
+	if len(s) != keyLenBase64 {
+		return Key{}, fmt.Errorf("wgtypes: base64-encoded key must be %d characters, but got %d", keyLenBase64, len(s))
+	}
+	if s[keyLenBase64-1] != '=' || s[keyLenBase64-2] == '=' {
+		return Key{}, fmt.Errorf("wgtypes: base64-encoded key must end with exactly one '=' padding character")
+	}
+
+	b, err := base64.StdEncoding.Strict().DecodeString(s)
+	if err != nil {
+		// Distinguish keys with stray trailing bits from invalid input.
+		if _, lerr := base64.StdEncoding.DecodeString(s); lerr == nil {
+			return Key{}, fmt.Errorf("wgtypes: base64-encoded key is not in canonical form")
+		}
+
+		return Key{}, fmt.Errorf("wgtypes: failed to parse base64-encoded key: %v", err)
+	}
+
+	return NewKey(b)
+}
+
+// ParseKeyLenient parses a Key from a base64-encoded string, tolerating
+// surrounding whitespace, missing padding, and the URL-safe base64 alphabet.
+// It is intended for keys which have been copied and pasted by humans.
+func ParseKeyLenient(s string) (Key, error) {
+	s = strings.TrimRight(strings.TrimSpace(s), "=")
+	s = strings.NewReplacer("-", "+", "_", "/").Replace(s)
+
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("wgtypes: failed to parse base64-encoded key: %v", err)
+	}
+	if len(b) != KeyLen {
+		return Key{}, fmt.Errorf("wgtypes: base64-encoded key decodes to %d bytes, but must be %d", len(b), KeyLen)
+	}
+
+	return NewKey(b)
+}
+
+// ParseKeyHex parses a Key from a hex-encoded string, as produced by the
+// Key.HexString method and used by the userspace configuration protocol.
+func ParseKeyHex(s string) (Key, error) {
+	if len(s) != keyLenHex {
+		return Key{}, fmt.Errorf("wgtypes: hex-encoded key must be %d characters, but got %d", keyLenHex, len(s))
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Key{}, fmt.Errorf("wgtypes: failed to parse hex-encoded key: %v", err)
+	}
+
+	return NewKey(b)
+}
+
 // PublicKey computes a public key from the private key k.
 //
 // PublicKey should only be called when k is a private key.
@@ -150,10 +217,46 @@ func (k Key) PublicKey() Key {
 // String returns the base64-encoded string representation of a Key.
 //
 // ParseKey can be used to produce a new Key from this string.
+//
+// String always reveals the whole Key, even if it is a private or preshared
+// key, so that it can be used to write configuration files. To log a Key, use
+// Redacted for secret keys or Abbreviated for public keys. Device, Peer,
+// Config, and PeerConfig implement fmt.Stringer using their Redacted methods,
+// so formatting them with the fmt package never reveals their secrets.
 func (k Key) String() string {
 	return base64.StdEncoding.EncodeToString(k[:])
 }
 
+// HexString returns the hex-encoded string representation of a Key, as used
+// by the userspace configuration protocol.
+//
+// ParseKeyHex can be used to produce a new Key from this string.
+func (k Key) HexString() string {
+	return hex.EncodeToString(k[:])
+}
+
+// ConstantTimeEqual reports whether k and other are equal, taking the same
+// amount of time regardless of their contents. It should be used instead of
+// == to compare private or preshared keys with untrusted input.
+func (k Key) ConstantTimeEqual(other Key) bool {
+	return subtle.ConstantTimeCompare(k[:], other[:]) == 1
+}
+
+// keyHashSeed is chosen randomly when the process starts.
+var keyHashSeed = maphash.MakeSeed()
+
+// Hash returns a hash of k, keyed with a random seed chosen when the process
+// starts, for use as a map key in caches which should not retain raw keys,
+// or in logs which should identify a key without revealing it. Equal Keys
+// have equal hashes within a process, but hashes differ between processes,
+// and cannot be reversed to recover the Key without the seed.
+//
+// Hash is not a cryptographic hash: distinct Keys may collide, so callers
+// must tolerate collisions.
+func (k Key) Hash() uint64 {
+	return maphash.Bytes(keyHashSeed, k[:])
+}
+
 // A Peer is a WireGuard peer to a Device.
 type Peer struct {
 	// PublicKey is the public key of a peer, computed from its private key.
@@ -167,6 +270,11 @@ type Peer struct {
 	// A zero-value Key means no preshared key is configured.
 	PresharedKey Key
 
+	// HasPresharedKey reports whether a preshared key is configured. Unlike
+	// PresharedKey, it is reported even when secrets are not retrieved, so
+	// that the use of preshared keys can be audited without handling them.
+	HasPresharedKey bool
+
 	// Endpoint is the most recent source address used for communication by
 	// this Peer.
 	Endpoint *net.UDPAddr
@@ -243,6 +351,11 @@ type PeerConfig struct {
 	// mandatory field for all PeerConfigs.
 	PublicKey Key
 
+	// Name is an optional display name for this peer, such as the value of a
+	// "# Name =" comment in a configuration file. It is not sent to the
+	// device.
+	Name string
+
 	// Remove specifies if the peer with this public key should be removed
 	// from a device's peer list.
 	Remove bool
@@ -274,3 +387,55 @@ type PeerConfig struct {
 	// for this peer.
 	AllowedIPs []net.IPNet
 }
+
+// ParseAllowedIPs parses a comma-separated list of IP addresses in CIDR
+// notation, such as the value of an AllowedIPs key in a wg-quick(8)
+// configuration file. Whitespace around each entry is ignored, and bare IP
+// addresses are treated as a /32 or /128 prefix for IPv4 and IPv6,
+// respectively. An empty string produces an empty list.
+func ParseAllowedIPs(s string) ([]net.IPNet, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+
+	var ipns []net.IPNet
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+
+		if !strings.Contains(f, "/") {
+			ip := net.ParseIP(f)
+			if ip == nil {
+				return nil, fmt.Errorf("wgtypes: invalid allowed IP: %q", f)
+			}
+
+			if ip4 := ip.To4(); ip4 != nil {
+				ipns = append(ipns, net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)})
+			} else {
+				ipns = append(ipns, net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+			}
+			continue
+		}
+
+		_, ipn, err := net.ParseCIDR(f)
+		if err != nil {
+			return nil, fmt.Errorf("wgtypes: invalid allowed IP: %v", err)
+		}
+
+		ipns = append(ipns, *ipn)
+	}
+
+	return ipns, nil
+}
+
+// JoinAllowedIPs produces the canonical comma-separated form of ipns used by
+// wg(8) configuration files, such as "10.0.0.0/24, fd00::/64".
+//
+// ParseAllowedIPs can be used to produce a new list from this string.
+func JoinAllowedIPs(ipns []net.IPNet) string {
+	ss := make([]string, 0, len(ipns))
+	for _, ipn := range ipns {
+		ss = append(ss, ipn.String())
+	}
+
+	return strings.Join(ss, ", ")
+}
@@ -3,10 +3,12 @@ package wgtypes_test
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/crypto/curve25519"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
 	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
 )
 
@@ -33,6 +35,93 @@ func TestPreparedKeys(t *testing.T) {
 	}
 }
 
+func TestKeyEncodings(t *testing.T) {
+	const (
+		b64 = "wVMuGz01CPx+vDVPpnliDzPyhxSVQuaExnt7DYE2Kyk="
+		hex = "c1532e1b3d3508fc7ebc354fa679620f33f287149542e684c67b7b0d81362b29"
+	)
+
+	want, err := wgtypes.ParseKey(b64)
+	if err != nil {
+		t.Fatalf("failed to parse key: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		s    string
+		fn   func(s string) (wgtypes.Key, error)
+	}{
+		{
+			name: "strict",
+			s:    b64,
+			fn:   wgtypes.ParseKeyStrict,
+		},
+		{
+			name: "lenient padded",
+			s:    b64,
+			fn:   wgtypes.ParseKeyLenient,
+		},
+		{
+			name: "lenient URL-safe unpadded",
+			s:    " wVMuGz01CPx-vDVPpnliDzPyhxSVQuaExnt7DYE2Kyk\n",
+			fn:   wgtypes.ParseKeyLenient,
+		},
+		{
+			name: "hex",
+			s:    hex,
+			fn:   wgtypes.ParseKeyHex,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(tt.s)
+			if err != nil {
+				t.Fatalf("failed to parse key: %v", err)
+			}
+
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Fatalf("unexpected key (-want +got):\n%s", diff)
+			}
+		})
+	}
+
+	if diff := cmp.Diff(hex, want.HexString()); diff != "" {
+		t.Fatalf("unexpected hex string (-want +got):\n%s", diff)
+	}
+}
+
+func TestKeyConstantTimeEqualHash(t *testing.T) {
+	a := wgtest.MustPresharedKey()
+	b := wgtest.MustPresharedKey()
+
+	c := a
+	c[wgtypes.KeyLen-1] ^= 1
+
+	tests := []struct {
+		name string
+		x, y wgtypes.Key
+		ok   bool
+	}{
+		{name: "equal", x: a, y: a, ok: true},
+		{name: "different", x: a, y: b},
+		{name: "last byte", x: a, y: c},
+		{name: "zero", x: wgtypes.Key{}, y: wgtypes.Key{}, ok: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.ok, tt.x.ConstantTimeEqual(tt.y)); diff != "" {
+				t.Fatalf("unexpected equality (-want +got):\n%s", diff)
+			}
+
+			if tt.ok && tt.x.Hash() != tt.y.Hash() {
+				t.Fatal("equal keys have different hashes")
+			}
+		})
+	}
+}
+
 func TestKeyExchange(t *testing.T) {
 	privA, pubA := mustKeyPair()
 	privB, pubB := mustKeyPair()
@@ -57,6 +146,15 @@ func TestBadKeys(t *testing.T) {
 	parseKey := func(b []byte) (wgtypes.Key, error) {
 		return wgtypes.ParseKey(string(b))
 	}
+	parseKeyStrict := func(b []byte) (wgtypes.Key, error) {
+		return wgtypes.ParseKeyStrict(string(b))
+	}
+	parseKeyLenient := func(b []byte) (wgtypes.Key, error) {
+		return wgtypes.ParseKeyLenient(string(b))
+	}
+	parseKeyHex := func(b []byte) (wgtypes.Key, error) {
+		return wgtypes.ParseKeyHex(string(b))
+	}
 
 	tests := []struct {
 		name string
@@ -88,6 +186,41 @@ func TestBadKeys(t *testing.T) {
 			b:    bytes.Repeat([]byte{0xff}, 40),
 			fn:   wgtypes.NewKey,
 		},
+		{
+			name: "strict missing padding",
+			b:    []byte("GHuMwljFfqd2a7cs6BaUOmHflK23zME8VNvC5B37S3k"),
+			fn:   parseKeyStrict,
+		},
+		{
+			name: "strict bad padding",
+			b:    []byte("GHuMwljFfqd2a7cs6BaUOmHflK23zME8VNvC5B37S3=="),
+			fn:   parseKeyStrict,
+		},
+		{
+			name: "strict non-canonical",
+			b:    []byte("GHuMwljFfqd2a7cs6BaUOmHflK23zME8VNvC5B37S3l="),
+			fn:   parseKeyStrict,
+		},
+		{
+			name: "lenient short",
+			b:    []byte("aGVsbG8"),
+			fn:   parseKeyLenient,
+		},
+		{
+			name: "lenient bad base64",
+			b:    []byte("!!!"),
+			fn:   parseKeyLenient,
+		},
+		{
+			name: "short hex",
+			b:    []byte("abcd"),
+			fn:   parseKeyHex,
+		},
+		{
+			name: "bad hex",
+			b:    bytes.Repeat([]byte("x"), 64),
+			fn:   parseKeyHex,
+		},
 	}
 
 	for _, tt := range tests {
@@ -119,3 +252,67 @@ func keyPtr(k wgtypes.Key) *[32]byte {
 func panicf(format string, a ...interface{}) {
 	panic(fmt.Sprintf(format, a...))
 }
+
+func TestAllowedIPs(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		ipns []net.IPNet
+		join string
+		ok   bool
+	}{
+		{
+			name: "empty",
+			s:    " ",
+			ok:   true,
+		},
+		{
+			name: "bad CIDR",
+			s:    "10.0.0.0/33",
+		},
+		{
+			name: "bad IP",
+			s:    "10.0.0.0, foo",
+		},
+		{
+			name: "empty entry",
+			s:    "10.0.0.0/24,,fd00::/64",
+		},
+		{
+			name: "OK",
+			s:    "10.0.0.1/24,fd00::/64 ,  192.0.2.1, 2001:db8::1",
+			ipns: []net.IPNet{
+				wgtest.MustCIDR("10.0.0.0/24"),
+				wgtest.MustCIDR("fd00::/64"),
+				wgtest.MustCIDR("192.0.2.1/32"),
+				wgtest.MustCIDR("2001:db8::1/128"),
+			},
+			join: "10.0.0.0/24, fd00::/64, 192.0.2.1/32, 2001:db8::1/128",
+			ok:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ipns, err := wgtypes.ParseAllowedIPs(tt.s)
+			if tt.ok && err != nil {
+				t.Fatalf("failed to parse allowed IPs: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+			if err != nil {
+				t.Logf("OK error: %v", err)
+				return
+			}
+
+			if diff := cmp.Diff(tt.ipns, ipns); diff != "" {
+				t.Fatalf("unexpected allowed IPs (-want +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tt.join, wgtypes.JoinAllowedIPs(ipns)); diff != "" {
+				t.Fatalf("unexpected joined allowed IPs (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
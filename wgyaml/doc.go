@@ -0,0 +1,11 @@
+// Package wgyaml provides YAML representations of WireGuard device
+// configurations and device state, for declarative tools which define
+// devices in YAML files.
+//
+// The Config, PeerConfig, Device, and Peer types mirror those in package
+// wgtypes, but store keys, endpoints, durations, and allowed IPs as strings,
+// and carry both yaml and json struct tags. They can be encoded and decoded
+// by any YAML library which honors yaml struct tags, such as gopkg.in/yaml.v3,
+// or by libraries which convert YAML to JSON first, and then converted to and
+// from the types in package wgtypes.
+package wgyaml // import "golang.zx2c4.com/wireguard/wgctrl/wgyaml"
@@ -0,0 +1,384 @@
+package wgyaml
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// A Config is the YAML representation of a wgtypes.Config.
+type Config struct {
+	PrivateKey   string       `yaml:"privateKey,omitempty" json:"privateKey,omitempty"`
+	ListenPort   *int         `yaml:"listenPort,omitempty" json:"listenPort,omitempty"`
+	FirewallMark *int         `yaml:"firewallMark,omitempty" json:"firewallMark,omitempty"`
+	ReplacePeers bool         `yaml:"replacePeers,omitempty" json:"replacePeers,omitempty"`
+	Peers        []PeerConfig `yaml:"peers,omitempty" json:"peers,omitempty"`
+}
+
+// A PeerConfig is the YAML representation of a wgtypes.PeerConfig.
+type PeerConfig struct {
+	PublicKey    string `yaml:"publicKey" json:"publicKey"`
+	Name         string `yaml:"name,omitempty" json:"name,omitempty"`
+	Remove       bool   `yaml:"remove,omitempty" json:"remove,omitempty"`
+	UpdateOnly   bool   `yaml:"updateOnly,omitempty" json:"updateOnly,omitempty"`
+	PresharedKey string `yaml:"presharedKey,omitempty" json:"presharedKey,omitempty"`
+
+	// Endpoint is a host and port, such as "192.0.2.1:51820" or
+	// "vpn.example.com:51820". Host names are resolved by ToConfig.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// PersistentKeepaliveInterval is a duration such as "25s". The value "0s"
+	// disables persistent keepalive.
+	PersistentKeepaliveInterval string `yaml:"persistentKeepaliveInterval,omitempty" json:"persistentKeepaliveInterval,omitempty"`
+
+	ReplaceAllowedIPs bool     `yaml:"replaceAllowedIPs,omitempty" json:"replaceAllowedIPs,omitempty"`
+	AllowedIPs        []string `yaml:"allowedIPs,omitempty" json:"allowedIPs,omitempty"`
+}
+
+// A Device is the YAML representation of a wgtypes.Device.
+type Device struct {
+	Name         string `yaml:"name" json:"name"`
+	Type         string `yaml:"type,omitempty" json:"type,omitempty"`
+	PrivateKey   string `yaml:"privateKey,omitempty" json:"privateKey,omitempty"`
+	PublicKey    string `yaml:"publicKey,omitempty" json:"publicKey,omitempty"`
+	ListenPort   int    `yaml:"listenPort,omitempty" json:"listenPort,omitempty"`
+	FirewallMark int    `yaml:"firewallMark,omitempty" json:"firewallMark,omitempty"`
+	Peers        []Peer `yaml:"peers,omitempty" json:"peers,omitempty"`
+}
+
+// A Peer is the YAML representation of a wgtypes.Peer.
+type Peer struct {
+	PublicKey                   string    `yaml:"publicKey" json:"publicKey"`
+	PresharedKey                string    `yaml:"presharedKey,omitempty" json:"presharedKey,omitempty"`
+	HasPresharedKey             bool      `yaml:"hasPresharedKey,omitempty" json:"hasPresharedKey,omitempty"`
+	Endpoint                    string    `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	PersistentKeepaliveInterval string    `yaml:"persistentKeepaliveInterval,omitempty" json:"persistentKeepaliveInterval,omitempty"`
+	LastHandshakeTime           time.Time `yaml:"lastHandshakeTime,omitempty" json:"lastHandshakeTime,omitempty"`
+	ReceiveBytes                int64     `yaml:"receiveBytes,omitempty" json:"receiveBytes,omitempty"`
+	TransmitBytes               int64     `yaml:"transmitBytes,omitempty" json:"transmitBytes,omitempty"`
+	AllowedIPs                  []string  `yaml:"allowedIPs,omitempty" json:"allowedIPs,omitempty"`
+	ProtocolVersion             int       `yaml:"protocolVersion,omitempty" json:"protocolVersion,omitempty"`
+}
+
+// FromConfig returns the YAML representation of cfg.
+func FromConfig(cfg wgtypes.Config) Config {
+	c := Config{
+		ListenPort:   cfg.ListenPort,
+		FirewallMark: cfg.FirewallMark,
+		ReplacePeers: cfg.ReplacePeers,
+	}
+
+	if cfg.PrivateKey != nil {
+		c.PrivateKey = cfg.PrivateKey.String()
+	}
+
+	for _, p := range cfg.Peers {
+		pc := PeerConfig{
+			PublicKey:         p.PublicKey.String(),
+			Name:              p.Name,
+			Remove:            p.Remove,
+			UpdateOnly:        p.UpdateOnly,
+			ReplaceAllowedIPs: p.ReplaceAllowedIPs,
+			AllowedIPs:        formatAllowedIPs(p.AllowedIPs),
+		}
+
+		if p.PresharedKey != nil {
+			pc.PresharedKey = p.PresharedKey.String()
+		}
+		if p.Endpoint != nil {
+			pc.Endpoint = p.Endpoint.String()
+		}
+		if p.PersistentKeepaliveInterval != nil {
+			pc.PersistentKeepaliveInterval = p.PersistentKeepaliveInterval.String()
+		}
+
+		c.Peers = append(c.Peers, pc)
+	}
+
+	return c
+}
+
+// ToConfig converts c to a wgtypes.Config, validating each of its fields and
+// resolving the endpoints of its peers.
+func (c Config) ToConfig() (wgtypes.Config, error) {
+	cfg := wgtypes.Config{
+		ListenPort:   c.ListenPort,
+		FirewallMark: c.FirewallMark,
+		ReplacePeers: c.ReplacePeers,
+	}
+
+	if c.PrivateKey != "" {
+		k, err := wgtypes.ParseKeyStrict(c.PrivateKey)
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("wgyaml: invalid private key: %v", err)
+		}
+
+		cfg.PrivateKey = &k
+	}
+
+	if p := c.ListenPort; p != nil && (*p < 0 || *p > 65535) {
+		return wgtypes.Config{}, fmt.Errorf("wgyaml: invalid listen port: %d", *p)
+	}
+
+	for i, pc := range c.Peers {
+		p, err := pc.ToPeerConfig()
+		if err != nil {
+			return wgtypes.Config{}, fmt.Errorf("wgyaml: peer %d: %v", i, err)
+		}
+
+		cfg.Peers = append(cfg.Peers, p)
+	}
+
+	return cfg, nil
+}
+
+// ToPeerConfig converts pc to a wgtypes.PeerConfig, validating each of its
+// fields and resolving its endpoint.
+func (pc PeerConfig) ToPeerConfig() (wgtypes.PeerConfig, error) {
+	if pc.PublicKey == "" {
+		return wgtypes.PeerConfig{}, fmt.Errorf("missing public key")
+	}
+
+	pub, err := wgtypes.ParseKeyStrict(pc.PublicKey)
+	if err != nil {
+		return wgtypes.PeerConfig{}, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	p := wgtypes.PeerConfig{
+		PublicKey:         pub,
+		Name:              pc.Name,
+		Remove:            pc.Remove,
+		UpdateOnly:        pc.UpdateOnly,
+		ReplaceAllowedIPs: pc.ReplaceAllowedIPs,
+	}
+
+	if pc.PresharedKey != "" {
+		k, err := wgtypes.ParseKeyStrict(pc.PresharedKey)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid preshared key: %v", err)
+		}
+
+		p.PresharedKey = &k
+	}
+
+	if pc.Endpoint != "" {
+		addr, err := net.ResolveUDPAddr("udp", pc.Endpoint)
+		if err != nil {
+			return wgtypes.PeerConfig{}, fmt.Errorf("invalid endpoint: %v", err)
+		}
+
+		p.Endpoint = addr
+	}
+
+	if pc.PersistentKeepaliveInterval != "" {
+		d, err := parseKeepalive(pc.PersistentKeepaliveInterval)
+		if err != nil {
+			return wgtypes.PeerConfig{}, err
+		}
+
+		p.PersistentKeepaliveInterval = &d
+	}
+
+	if p.AllowedIPs, err = parseAllowedIPs(pc.AllowedIPs); err != nil {
+		return wgtypes.PeerConfig{}, err
+	}
+
+	return p, nil
+}
+
+// FromDevice returns the YAML representation of d. Keys which are not set,
+// such as a private key which was not retrieved, are omitted.
+func FromDevice(d *wgtypes.Device) Device {
+	dev := Device{
+		Name:         d.Name,
+		Type:         d.Type.String(),
+		PrivateKey:   keyString(d.PrivateKey),
+		PublicKey:    keyString(d.PublicKey),
+		ListenPort:   d.ListenPort,
+		FirewallMark: d.FirewallMark,
+	}
+
+	for _, p := range d.Peers {
+		peer := Peer{
+			PublicKey:         p.PublicKey.String(),
+			PresharedKey:      keyString(p.PresharedKey),
+			HasPresharedKey:   p.HasPresharedKey,
+			LastHandshakeTime: p.LastHandshakeTime,
+			ReceiveBytes:      p.ReceiveBytes,
+			TransmitBytes:     p.TransmitBytes,
+			AllowedIPs:        formatAllowedIPs(p.AllowedIPs),
+			ProtocolVersion:   p.ProtocolVersion,
+		}
+
+		if p.Endpoint != nil {
+			peer.Endpoint = p.Endpoint.String()
+		}
+		if p.PersistentKeepaliveInterval != 0 {
+			peer.PersistentKeepaliveInterval = p.PersistentKeepaliveInterval.String()
+		}
+
+		dev.Peers = append(dev.Peers, peer)
+	}
+
+	return dev
+}
+
+// ToDevice converts d to a wgtypes.Device, validating each of its fields.
+// Unlike ToConfig, endpoints must be IP addresses and are not resolved.
+func (d Device) ToDevice() (*wgtypes.Device, error) {
+	typ, err := parseDeviceType(d.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	dev := &wgtypes.Device{
+		Name:         d.Name,
+		Type:         typ,
+		ListenPort:   d.ListenPort,
+		FirewallMark: d.FirewallMark,
+	}
+
+	if dev.PrivateKey, err = parseOptionalKey(d.PrivateKey); err != nil {
+		return nil, fmt.Errorf("wgyaml: invalid private key: %v", err)
+	}
+	if dev.PublicKey, err = parseOptionalKey(d.PublicKey); err != nil {
+		return nil, fmt.Errorf("wgyaml: invalid public key: %v", err)
+	}
+
+	for i, p := range d.Peers {
+		peer, err := p.toPeer()
+		if err != nil {
+			return nil, fmt.Errorf("wgyaml: peer %d: %v", i, err)
+		}
+
+		dev.Peers = append(dev.Peers, peer)
+	}
+
+	return dev, nil
+}
+
+// toPeer converts p to a wgtypes.Peer.
+func (p Peer) toPeer() (wgtypes.Peer, error) {
+	pub, err := wgtypes.ParseKeyStrict(p.PublicKey)
+	if err != nil {
+		return wgtypes.Peer{}, fmt.Errorf("invalid public key: %v", err)
+	}
+
+	peer := wgtypes.Peer{
+		PublicKey:         pub,
+		HasPresharedKey:   p.HasPresharedKey,
+		LastHandshakeTime: p.LastHandshakeTime,
+		ReceiveBytes:      p.ReceiveBytes,
+		TransmitBytes:     p.TransmitBytes,
+		ProtocolVersion:   p.ProtocolVersion,
+	}
+
+	if peer.PresharedKey, err = parseOptionalKey(p.PresharedKey); err != nil {
+		return wgtypes.Peer{}, fmt.Errorf("invalid preshared key: %v", err)
+	}
+
+	if p.Endpoint != "" {
+		ap, err := netip.ParseAddrPort(p.Endpoint)
+		if err != nil {
+			return wgtypes.Peer{}, fmt.Errorf("invalid endpoint: %v", err)
+		}
+
+		peer.Endpoint = net.UDPAddrFromAddrPort(ap)
+	}
+
+	if p.PersistentKeepaliveInterval != "" {
+		if peer.PersistentKeepaliveInterval, err = parseKeepalive(p.PersistentKeepaliveInterval); err != nil {
+			return wgtypes.Peer{}, err
+		}
+	}
+
+	if peer.AllowedIPs, err = parseAllowedIPs(p.AllowedIPs); err != nil {
+		return wgtypes.Peer{}, err
+	}
+
+	return peer, nil
+}
+
+// parseDeviceType parses the string representation of a wgtypes.DeviceType.
+func parseDeviceType(s string) (wgtypes.DeviceType, error) {
+	if s == "" {
+		return wgtypes.Unknown, nil
+	}
+
+	for _, dt := range []wgtypes.DeviceType{
+		wgtypes.Unknown,
+		wgtypes.LinuxKernel,
+		wgtypes.OpenBSDKernel,
+		wgtypes.FreeBSDKernel,
+		wgtypes.WindowsKernel,
+		wgtypes.Userspace,
+	} {
+		if s == dt.String() {
+			return dt, nil
+		}
+	}
+
+	return wgtypes.Unknown, fmt.Errorf("wgyaml: unknown device type: %q", s)
+}
+
+// parseKeepalive parses a persistent keepalive interval, which must be a
+// whole number of seconds.
+func parseKeepalive(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid persistent keepalive interval: %v", err)
+	}
+	if d < 0 || d > 65535*time.Second || d%time.Second != 0 {
+		return 0, fmt.Errorf("invalid persistent keepalive interval: %s", d)
+	}
+
+	return d, nil
+}
+
+// parseAllowedIPs parses a list of allowed IPs in CIDR notation.
+func parseAllowedIPs(ss []string) ([]net.IPNet, error) {
+	var ipns []net.IPNet
+	for _, s := range ss {
+		_, ipn, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed IP: %v", err)
+		}
+
+		ipns = append(ipns, *ipn)
+	}
+
+	return ipns, nil
+}
+
+// formatAllowedIPs formats a list of allowed IPs in CIDR notation.
+func formatAllowedIPs(ipns []net.IPNet) []string {
+	var ss []string
+	for _, ipn := range ipns {
+		ss = append(ss, ipn.String())
+	}
+
+	return ss
+}
+
+// keyString returns the base64 representation of k, or the empty string if k
+// is the zero key.
+func keyString(k wgtypes.Key) string {
+	if k == (wgtypes.Key{}) {
+		return ""
+	}
+
+	return k.String()
+}
+
+// parseOptionalKey parses a base64-encoded key, or returns the zero key if s
+// is empty.
+func parseOptionalKey(s string) (wgtypes.Key, error) {
+	if s == "" {
+		return wgtypes.Key{}, nil
+	}
+
+	return wgtypes.ParseKeyStrict(s)
+}
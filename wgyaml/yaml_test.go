@@ -0,0 +1,174 @@
+package wgyaml_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.zx2c4.com/wireguard/wgctrl/internal/wgtest"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+	"golang.zx2c4.com/wireguard/wgctrl/wgyaml"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	var (
+		priv = wgtest.MustPrivateKey()
+		pub  = wgtest.MustPublicKey()
+		psk  = wgtest.MustPresharedKey()
+		port = 51820
+		mark = 1
+		ka   = 25 * time.Second
+	)
+
+	cfg := wgtypes.Config{
+		PrivateKey:   &priv,
+		ListenPort:   &port,
+		FirewallMark: &mark,
+		ReplacePeers: true,
+		Peers: []wgtypes.PeerConfig{
+			{
+				PublicKey:                   pub,
+				Name:                        "laptop",
+				PresharedKey:                &psk,
+				Endpoint:                    wgtest.MustUDPAddr("192.0.2.1:51820"),
+				PersistentKeepaliveInterval: &ka,
+				ReplaceAllowedIPs:           true,
+				AllowedIPs: []net.IPNet{
+					wgtest.MustCIDR("10.0.0.2/32"),
+					wgtest.MustCIDR("fd00::/64"),
+				},
+			},
+			{
+				PublicKey: wgtest.MustPublicKey(),
+				Remove:    true,
+			},
+		},
+	}
+
+	c := wgyaml.FromConfig(cfg)
+	if diff := cmp.Diff("25s", c.Peers[0].PersistentKeepaliveInterval); diff != "" {
+		t.Fatalf("unexpected keepalive interval (-want +got):\n%s", diff)
+	}
+
+	got, err := c.ToConfig()
+	if err != nil {
+		t.Fatalf("failed to convert Config: %v", err)
+	}
+
+	if diff := cmp.Diff(cfg, got); diff != "" {
+		t.Fatalf("unexpected Config (-want +got):\n%s", diff)
+	}
+}
+
+func TestDeviceRoundTrip(t *testing.T) {
+	priv := wgtest.MustPrivateKey()
+
+	d := &wgtypes.Device{
+		Name:       "wg0",
+		Type:       wgtypes.Userspace,
+		PrivateKey: priv,
+		PublicKey:  priv.PublicKey(),
+		ListenPort: 51820,
+		Peers: []wgtypes.Peer{{
+			PublicKey:                   wgtest.MustPublicKey(),
+			HasPresharedKey:             true,
+			Endpoint:                    wgtest.MustUDPAddr("[2001:db8::1]:51820"),
+			PersistentKeepaliveInterval: 25 * time.Second,
+			LastHandshakeTime:           time.Unix(1600000000, 0).UTC(),
+			ReceiveBytes:                1,
+			TransmitBytes:               2,
+			AllowedIPs:                  []net.IPNet{wgtest.MustCIDR("0.0.0.0/0")},
+			ProtocolVersion:             1,
+		}},
+	}
+
+	dev := wgyaml.FromDevice(d)
+	if diff := cmp.Diff("", dev.Peers[0].PresharedKey); diff != "" {
+		t.Fatalf("unexpected preshared key (-want +got):\n%s", diff)
+	}
+
+	got, err := dev.ToDevice()
+	if err != nil {
+		t.Fatalf("failed to convert Device: %v", err)
+	}
+
+	if diff := cmp.Diff(d, got); diff != "" {
+		t.Fatalf("unexpected Device (-want +got):\n%s", diff)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	pub := wgtest.MustPublicKey().String()
+	port := 65536
+
+	tests := []struct {
+		name string
+		c    wgyaml.Config
+	}{
+		{
+			name: "bad private key",
+			c:    wgyaml.Config{PrivateKey: "foo"},
+		},
+		{
+			name: "bad listen port",
+			c:    wgyaml.Config{ListenPort: &port},
+		},
+		{
+			name: "missing public key",
+			c:    wgyaml.Config{Peers: []wgyaml.PeerConfig{{Remove: true}}},
+		},
+		{
+			name: "bad endpoint",
+			c: wgyaml.Config{Peers: []wgyaml.PeerConfig{{
+				PublicKey: pub,
+				Endpoint:  "192.0.2.1",
+			}}},
+		},
+		{
+			name: "bad keepalive",
+			c: wgyaml.Config{Peers: []wgyaml.PeerConfig{{
+				PublicKey:                   pub,
+				PersistentKeepaliveInterval: "25",
+			}}},
+		},
+		{
+			name: "bad allowed IP",
+			c: wgyaml.Config{Peers: []wgyaml.PeerConfig{{
+				PublicKey:  pub,
+				AllowedIPs: []string{"10.0.0.0/33"},
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.c.ToConfig()
+			if err == nil {
+				t.Fatal("expected an error, but none occurred")
+			}
+
+			t.Logf("OK error: %v", err)
+		})
+	}
+}
+
+func TestStructTags(t *testing.T) {
+	// Every field must be tagged so that YAML and JSON libraries agree on
+	// field names.
+	for _, v := range []interface{}{
+		wgyaml.Config{},
+		wgyaml.PeerConfig{},
+		wgyaml.Device{},
+		wgyaml.Peer{},
+	} {
+		typ := reflect.TypeOf(v)
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			if f.Tag.Get("yaml") == "" || f.Tag.Get("json") == "" {
+				t.Errorf("%s.%s: missing yaml or json struct tag", typ.Name(), f.Name)
+			}
+		}
+	}
+}